@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// client calls the admin API of an adbeacon server
+type client struct {
+	baseURL string
+	// user is sent in the X-Admin-User header, the server records it as the author of changes
+	user string
+	// token is the admin token, sent as a bearer token
+	token string
+	http  *http.Client
+}
+
+func newClient(addr, user, token string, timeout time.Duration) *client {
+	return &client{
+		baseURL: strings.TrimRight(addr, "/"),
+		user:    user,
+		token:   token,
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// do sends a request with body encoded as JSON, if not nil, and decodes the response into out,
+// if not nil. Error responses are returned as errors carrying the message reported by the server.
+func (c *client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.user != "" {
+		req.Header.Set("X-Admin-User", c.user)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errorResponse models.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil || errorResponse.Error == "" {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, errorResponse.Error)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
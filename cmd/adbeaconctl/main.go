@@ -0,0 +1,1113 @@
+// adbeaconctl manages a running adbeacon server through its admin API
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/blocklist"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/bundle"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/campaignstats"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reach"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/simulation"
+)
+
+// command is a subcommand of adbeaconctl
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(fs *flag.FlagSet, args []string) error
+}
+
+var (
+	// errUsage makes run exit with status 2, the problem was already reported with the usage
+	errUsage = errors.New("invalid usage")
+	// errHelp makes run exit with status 0 after the usage was printed for -h
+	errHelp = errors.New("help requested")
+	// errInvalid makes run exit with status 1 after the validation problems were printed
+	errInvalid = errors.New("invalid campaign")
+)
+
+var commands = []command{
+	{"list", "list [-addr url] [-json]", "List all campaigns, including paused ones", runList},
+	{"create", "create [-addr url] -file campaign.json [-rules expr]", "Create a campaign with its targeting rules", runCreate},
+	{"pause", "pause [-addr url] [-id-prefix prefix] [-name text] [cid...]", "Pause campaigns so they are no longer delivered, all at once", runPause},
+	{"resume", "resume [-addr url] [-id-prefix prefix] [-name text] [cid...]", "Resume paused campaigns, all at once", runResume},
+	{"set-traffic", "set-traffic [-addr url] <cid> <percent>", "Serve a campaign to a percentage of its matching traffic, 0 for all of it", runSetTraffic},
+	{"set-category", "set-category [-addr url] <cid> [category]", "Set the competitive category of a campaign, responses serve one campaign per category", runSetCategory},
+	{"set-image", "set-image [-addr url] <cid> [img]", "Set the image URL of a campaign, checked and rewritten to the CDN when the server validates creatives", runSetImage},
+	{"validate-rules", "validate-rules [-addr url] -file campaign.json [-rules expr]", "Check a campaign and its targeting rules without creating it", runValidateRules},
+	{"simulate", "simulate [-addr url] (-file campaign.json | -rules expr) [-requests requests.json] [-count n] [-seed n]", "Estimate how many requests a campaign would match, overall and per dimension", runSimulate},
+	{"invalidate-cache", "invalidate-cache [-addr url]", "Clear the cached campaigns and indexes", runInvalidateCache},
+	{"stats", "stats [-addr url] [-follow] [-interval duration]", "Show delivery totals, or tail them with -follow", runStats},
+	{"campaign-stats", "campaign-stats [-addr url] <cid>", "Show the deliveries of a campaign in the last minute, hour and day", runCampaignStats},
+	{"campaign-reach", "campaign-reach [-addr url] <cid>", "Estimate how many requests a day a campaign can reach", runCampaignReach},
+	{"set-rules", "set-rules [-addr url] [-comment text] (-file campaign.json | -rules expr <cid>)", "Replace the targeting rules of a campaign, recorded as a new rule version", runSetRules},
+	{"rules-history", "rules-history [-addr url] [-json] <cid>", "List the rule versions of a campaign with who changed what", runRulesHistory},
+	{"rules-diff", "rules-diff [-addr url] [-against version] <cid> <version>", "Show the rule changes of a version against the previous or another version", runRulesDiff},
+	{"rules-lint", "rules-lint [-addr url] (-file campaign.json | -rules expr | <cid>)", "Explain what the rules of a campaign match and warn about likely mistakes, exits 1 with warnings", runRulesLint},
+	{"rules-rollback", "rules-rollback [-addr url] <cid> <version>", "Restore the targeting rules of a version, recorded as a new rule version", runRulesRollback},
+	{"export", "export [-addr url] [-file bundle.json]", "Export all campaigns with their rules to a bundle, for another environment or a backup", runExport},
+	{"import", "import [-addr url] [-dry-run] -file bundle.json", "Import a bundle exported by another environment, showing the changes first", runImport},
+	{"blocklist", "blocklist [-addr url] [-json]", "List the apps, countries and IP ranges blocked for every campaign", runBlocklist},
+	{"block", "block [-addr url] [-comment text] <app|country|ip_range> <value>", "Block an app, country or IP range for every campaign", runBlock},
+	{"unblock", "unblock [-addr url] <id>", "Remove a blocklist entry by the ID listed by blocklist", runUnblock},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run dispatches to the subcommand named by the first argument and returns the exit status
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		printUsage(os.Stdout)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	name, args := args[0], args[1:]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		switch err := cmd.run(newFlagSet(cmd), args); {
+		case err == nil, errors.Is(err, errHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		case errors.Is(err, errInvalid):
+			return 1
+		default:
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: adbeaconctl <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-17s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'adbeaconctl <command> -h' for the flags of a command.")
+	fmt.Fprintln(w, "The server address defaults to ADBEACON_ADDR, or http://localhost:8080.")
+	fmt.Fprintln(w, "Changes are recorded as made by ADBEACON_USER, or USER, unless -user is given.")
+	fmt.Fprintln(w, "The admin token is read from ADBEACON_TOKEN, or the file named by ADBEACON_TOKEN_FILE.")
+}
+
+// newFlagSet creates the flag set of a command, printing its usage on -h and invalid flags
+func newFlagSet(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: adbeaconctl %s\n\n%s\n\nFlags:\n", cmd.usage, cmd.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// clientFlags registers the flags shared by all commands, the returned function creates
+// the admin API client once the flags are parsed
+func clientFlags(fs *flag.FlagSet) func() *client {
+	addr := os.Getenv("ADBEACON_ADDR")
+	if addr == "" {
+		addr = "http://localhost:8080"
+	}
+	user := os.Getenv("ADBEACON_USER")
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	addrFlag := fs.String("addr", addr, "base URL of the adbeacon server")
+	userFlag := fs.String("user", user, "who makes the changes, recorded with rule versions")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each admin API request")
+	return func() *client {
+		return newClient(*addrFlag, *userFlag, adminToken(), *timeout)
+	}
+}
+
+// adminToken returns the admin token from the file named by ADBEACON_TOKEN_FILE if set, otherwise
+// ADBEACON_TOKEN. It's never taken from a flag, command lines are visible to other users.
+func adminToken() string {
+	if path := os.Getenv("ADBEACON_TOKEN_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "adbeaconctl: failed to read ADBEACON_TOKEN_FILE: %v\n", err)
+		}
+		return strings.TrimRight(string(content), "\r\n")
+	}
+	return os.Getenv("ADBEACON_TOKEN")
+}
+
+// parseFlags parses args, the flag set already printed the usage when it fails
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return errHelp
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// readCampaign reads a campaign with its targeting rules from a JSON file, - reads stdin
+func readCampaign(file string) (models.CampaignWithRules, error) {
+	var campaign models.CampaignWithRules
+	err := readJSON(file, &campaign)
+	return campaign, err
+}
+
+// targetingFlag is the -rules flag, a targeting expression replacing the rules of a campaign file
+type targetingFlag struct {
+	expr *string
+}
+
+// newTargetingFlag registers the -rules flag
+func newTargetingFlag(fs *flag.FlagSet) targetingFlag {
+	return targetingFlag{expr: fs.String("rules", "", "targeting `expression` replacing the rules of the file, e.g. \"country in (us, ca) and os != ios\"")}
+}
+
+// given reports whether an expression was given
+func (f targetingFlag) given() bool {
+	return *f.expr != ""
+}
+
+// read reads the campaign in file, - for stdin, with the rules of the expression instead of its
+// own when one was given. Without a file the campaign only has those rules.
+func (f targetingFlag) read(file string) (models.CampaignWithRules, error) {
+	var campaign models.CampaignWithRules
+	if file != "" {
+		var err error
+		if campaign, err = readCampaign(file); err != nil {
+			return campaign, err
+		}
+	}
+	if f.given() {
+		rules, err := models.ParseRules(*f.expr)
+		if err != nil {
+			return campaign, fmt.Errorf("invalid -rules: %w", err)
+		}
+		campaign.Rules = rules
+	}
+	return campaign, nil
+}
+
+// readJSON decodes a JSON file into v, - reads stdin
+func readJSON(file string, v any) error {
+	var content []byte
+	var err error
+	if file == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return nil
+}
+
+func runList(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	asJSON := fs.Bool("json", false, "print the campaigns with their rules as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var campaigns []models.CampaignWithRules
+	if err := newClient().do(context.Background(), "GET", "/v1/admin/campaigns", nil, &campaigns); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(campaigns)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CID\tNAME\tSTATUS\tRULES\tUPDATED")
+	for _, campaign := range campaigns {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", campaign.ID, campaign.Name, campaign.Status, len(campaign.Rules),
+			campaign.UpdatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func runCreate(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	file := fs.String("file", "", "JSON file with the campaign and its rules, - for stdin")
+	targeting := newTargetingFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	campaign, err := targeting.read(*file)
+	if err != nil {
+		return err
+	}
+
+	var created models.CampaignWithRules
+	if err := newClient().do(context.Background(), "POST", "/v1/admin/campaigns", campaign, &created); err != nil {
+		return err
+	}
+	fmt.Printf("created campaign %s (%s, %d rules)\n", created.ID, created.Status, len(created.Rules))
+	return nil
+}
+
+func runPause(fs *flag.FlagSet, args []string) error {
+	return setCampaignStatus(fs, args, "pause")
+}
+
+func runResume(fs *flag.FlagSet, args []string) error {
+	return setCampaignStatus(fs, args, "resume")
+}
+
+// setCampaignStatus pauses or resumes the campaigns named by the arguments or matching the filter
+// flags. Several campaigns change through the bulk endpoint, all of them or none.
+func setCampaignStatus(fs *flag.FlagSet, args []string, action string) error {
+	newClient := clientFlags(fs)
+	var filter service.CampaignFilter
+	fs.StringVar(&filter.IDPrefix, "id-prefix", "", "change the campaigns whose ID starts with `prefix`")
+	fs.StringVar(&filter.Name, "name", "", "change the campaigns whose name contains `text`, ignoring case")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	// Campaigns are named either by ID or with the filter flags
+	hasFilter := !filter.IsEmpty()
+	if fs.NArg() == 0 && !hasFilter || fs.NArg() > 0 && hasFilter {
+		fs.Usage()
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		return setCampaignStatuses(newClient(), action, fs.Args(), filter)
+	}
+
+	var status struct {
+		CID    string `json:"cid"`
+		Status string `json:"status"`
+	}
+	path := fmt.Sprintf("/v1/admin/campaigns/%s/%s", url.PathEscape(fs.Arg(0)), action)
+	if err := newClient().do(context.Background(), "POST", path, nil, &status); err != nil {
+		return err
+	}
+	fmt.Printf("campaign %s is now %s\n", status.CID, status.Status)
+	return nil
+}
+
+// setCampaignStatuses pauses or resumes the campaigns listed by ID, or the ones matching filter
+func setCampaignStatuses(c *client, action string, ids []string, filter service.CampaignFilter) error {
+	body := bulkStatusRequest{Status: models.StatusInactive, CIDs: ids}
+	if action == "resume" {
+		body.Status = models.StatusActive
+	}
+	if len(ids) == 0 {
+		body.Filter = &filter
+	}
+
+	var result struct {
+		Status string   `json:"status"`
+		CIDs   []string `json:"cids"`
+	}
+	if err := c.do(context.Background(), "POST", "/v1/admin/campaigns:bulkStatus", body, &result); err != nil {
+		return err
+	}
+	if len(result.CIDs) == 0 {
+		fmt.Println("no campaign matched")
+		return nil
+	}
+	fmt.Printf("%d campaigns are now %s: %s\n", len(result.CIDs), result.Status, strings.Join(result.CIDs, ", "))
+	return nil
+}
+
+// runSetTraffic sets the traffic percentage of a campaign, the arguments name both
+func runSetTraffic(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+	pct, err := strconv.Atoi(strings.TrimSuffix(fs.Arg(1), "%"))
+	if err != nil {
+		fs.Usage()
+		return errUsage
+	}
+
+	var traffic campaignTraffic
+	path := fmt.Sprintf("/v1/admin/campaigns/%s/traffic", url.PathEscape(fs.Arg(0)))
+	if err := newClient().do(context.Background(), "PUT", path, campaignTraffic{TrafficPct: pct}, &traffic); err != nil {
+		return err
+	}
+	if traffic.TrafficPct == 0 {
+		fmt.Printf("campaign %s is served to all its matching traffic\n", traffic.CID)
+		return nil
+	}
+	fmt.Printf("campaign %s is served to %d%% of its matching traffic\n", traffic.CID, traffic.TrafficPct)
+	return nil
+}
+
+// runSetCategory sets the category of a campaign, or clears it without a category argument
+func runSetCategory(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 && fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var category campaignCategory
+	path := fmt.Sprintf("/v1/admin/campaigns/%s/category", url.PathEscape(fs.Arg(0)))
+	if err := newClient().do(context.Background(), "PUT", path, campaignCategory{Category: fs.Arg(1)}, &category); err != nil {
+		return err
+	}
+	if category.Category == "" {
+		fmt.Printf("campaign %s has no category\n", category.CID)
+		return nil
+	}
+	fmt.Printf("campaign %s is in category %s\n", category.CID, category.Category)
+	return nil
+}
+
+// runSetImage sets the image of a campaign, or clears it without an img argument
+func runSetImage(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 && fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var image campaignImage
+	path := fmt.Sprintf("/v1/admin/campaigns/%s/image", url.PathEscape(fs.Arg(0)))
+	if err := newClient().do(context.Background(), "PUT", path, campaignImage{Img: fs.Arg(1)}, &image); err != nil {
+		return err
+	}
+	if image.Img == "" {
+		fmt.Printf("campaign %s has no image\n", image.CID)
+		return nil
+	}
+	fmt.Printf("campaign %s shows %s\n", image.CID, image.Img)
+	return nil
+}
+
+func runValidateRules(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	file := fs.String("file", "", "JSON file with the campaign and its rules, - for stdin")
+	targeting := newTargetingFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	campaign, err := targeting.read(*file)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Valid    bool     `json:"valid"`
+		Errors   []string `json:"errors"`
+		Warnings []string `json:"warnings"`
+	}
+	if err := newClient().do(context.Background(), "POST", "/v1/admin/rules/validate", campaign, &result); err != nil {
+		return err
+	}
+
+	if len(result.Warnings) > 0 {
+		fmt.Printf("campaign %s has rule conflicts:\n  - %s\n", campaign.ID, strings.Join(result.Warnings, "\n  - "))
+	}
+	if !result.Valid {
+		fmt.Printf("campaign %s is invalid:\n  - %s\n", campaign.ID, strings.Join(result.Errors, "\n  - "))
+		return errInvalid
+	}
+	fmt.Printf("campaign %s is valid\n", campaign.ID)
+	return nil
+}
+
+func runSimulate(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	file := fs.String("file", "", "JSON file with the campaign and its rules, - for stdin")
+	targeting := newTargetingFlag(fs)
+	requestsFile := fs.String("requests", "", "JSON array or JSON lines of delivery requests to match, e.g. from access logs; synthetic requests when empty")
+	count := fs.Int("count", 10000, "number of synthetic requests")
+	seed := fs.Int64("seed", 1, "seed of the synthetic requests")
+	countries := fs.String("countries", "", "synthetic country values with relative weights, e.g. us:60,in:40, defaults to the server's mix")
+	oses := fs.String("os", "", "synthetic os values with relative weights, defaults to the server's mix")
+	apps := fs.String("apps", "", "synthetic app values with relative weights, defaults to the server's mix")
+	states := fs.String("states", "", "synthetic state values with relative weights, empty omits the state")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" && !targeting.given() {
+		fs.Usage()
+		return errUsage
+	}
+
+	campaign, err := targeting.read(*file)
+	if err != nil {
+		return err
+	}
+
+	body := simulationRequest{Campaign: campaign}
+	if *requestsFile != "" {
+		if body.Requests, err = readRequests(*requestsFile); err != nil {
+			return err
+		}
+	} else {
+		body.Synthetic = &simulation.SyntheticConfig{
+			Count:     *count,
+			Seed:      *seed,
+			Countries: *countries,
+			OS:        *oses,
+			Apps:      *apps,
+			States:    *states,
+		}
+	}
+
+	var result simulation.Result
+	if err := newClient().do(context.Background(), "POST", "/v1/admin/simulate", body, &result); err != nil {
+		return err
+	}
+
+	fmt.Printf("campaign %s would match %d of %d requests (%.2f%%)\n\n", campaign.ID, result.Matched, result.Requests, result.MatchRate*100)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DIMENSION\tMATCHED\tRATE")
+	for _, dimension := range result.Dimensions {
+		fmt.Fprintf(w, "%s\t%d\t%.2f%%\n", dimension.Dimension, dimension.Matched, dimension.MatchRate*100)
+	}
+	return w.Flush()
+}
+
+// bulkStatusRequest is the request body of /v1/admin/campaigns:bulkStatus
+type bulkStatusRequest struct {
+	Status models.CampaignStatus   `json:"status"`
+	CIDs   []string                `json:"cids,omitempty"`
+	Filter *service.CampaignFilter `json:"filter,omitempty"`
+}
+
+// campaignTraffic is the request and response body of /v1/admin/campaigns/{id}/traffic
+type campaignTraffic struct {
+	CID        string `json:"cid,omitempty"`
+	TrafficPct int    `json:"traffic_pct"`
+}
+
+// campaignCategory is the request and response body of /v1/admin/campaigns/{id}/category
+type campaignCategory struct {
+	CID      string `json:"cid"`
+	Category string `json:"category"`
+}
+
+// campaignImage is the request and response body of /v1/admin/campaigns/{id}/image
+type campaignImage struct {
+	CID string `json:"cid"`
+	Img string `json:"img"`
+}
+
+// rulesUpdate is the request body of /v1/admin/campaigns/{id}/rules
+type rulesUpdate struct {
+	Rules   []models.TargetingRule `json:"rules"`
+	Comment string                 `json:"comment,omitempty"`
+}
+
+// ruleVersion is a rule version with its changes, as returned by the rule version endpoints
+type ruleVersion struct {
+	models.RuleSetVersion
+	Against int                 `json:"against"`
+	Changes []models.RuleChange `json:"changes"`
+}
+
+// simulationRequest is the request body of /v1/admin/simulate
+type simulationRequest struct {
+	Campaign  models.CampaignWithRules    `json:"campaign"`
+	Requests  []models.DeliveryRequest    `json:"requests,omitempty"`
+	Synthetic *simulation.SyntheticConfig `json:"synthetic,omitempty"`
+}
+
+// readRequests reads delivery requests from a JSON array or from one JSON object per line
+func readRequests(file string) ([]models.DeliveryRequest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var requests []models.DeliveryRequest
+	decoder := json.NewDecoder(f)
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); errors.Is(err, io.EOF) {
+			return requests, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+
+		if strings.HasPrefix(string(value), "[") {
+			var batch []models.DeliveryRequest
+			if err := json.Unmarshal(value, &batch); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+			requests = append(requests, batch...)
+			continue
+		}
+
+		var req models.DeliveryRequest
+		if err := json.Unmarshal(value, &req); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		requests = append(requests, req)
+	}
+}
+
+func runInvalidateCache(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if err := newClient().do(context.Background(), "POST", "/v1/admin/cache/invalidate", nil, nil); err != nil {
+		return err
+	}
+	fmt.Println("cache invalidated")
+	return nil
+}
+
+// runCampaignStats prints the rolling deliveries of the campaign named by the only argument
+func runCampaignStats(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var stats campaignstats.Stats
+	path := fmt.Sprintf("/v1/admin/campaigns/%s/stats", url.PathEscape(fs.Arg(0)))
+	if err := newClient().do(context.Background(), "GET", path, nil, &stats); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WINDOW\tDELIVERIES")
+	for _, window := range campaignstats.Windows {
+		fmt.Fprintf(w, "%s\t%d\n", window.Name, stats.Deliveries[window.Name])
+	}
+	return w.Flush()
+}
+
+// runCampaignReach prints the estimated daily reach of the campaign named by the only argument
+func runCampaignReach(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var estimate reach.Estimate
+	path := fmt.Sprintf("/v1/admin/campaigns/%s/reach", url.PathEscape(fs.Arg(0)))
+	if err := newClient().do(context.Background(), "GET", path, nil, &estimate); err != nil {
+		return err
+	}
+
+	fmt.Printf("campaign %s can reach about %d of %d requests a day (%.2f%%)\n", estimate.CampaignID, estimate.DailyReach, estimate.DailyRequests, estimate.MatchRate*100)
+	if estimate.Days == 0 {
+		fmt.Println("no full day recorded yet, extrapolated from today's requests")
+	}
+	if len(estimate.Unestimated) > 0 {
+		fmt.Printf("not estimated, assumed to match every request: %s\n", strings.Join(estimate.Unestimated, ", "))
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DIMENSION\tRATE")
+	for _, dimension := range estimate.Dimensions {
+		fmt.Fprintf(w, "%s\t%.2f%%\n", dimension.Dimension, dimension.MatchRate*100)
+	}
+	return w.Flush()
+}
+
+func runStats(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	follow := fs.Bool("follow", false, "keep printing the requests and fill rate of every interval until interrupted")
+	interval := fs.Duration("interval", 5*time.Second, "how often to poll with -follow")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client := newClient()
+
+	var previous metrics.DeliveryStats
+	if err := client.do(ctx, "GET", "/v1/admin/stats", nil, &previous); err != nil {
+		return err
+	}
+	fmt.Printf("requests %d, filled %d, fill rate %.2f%%\n", previous.Requests, previous.Filled, previous.FillRate*100)
+	if !*follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	// Fixed widths since lines are printed one interval at a time
+	const row = "%-8s  %10v  %10v  %10v  %9v\n"
+	fmt.Printf(row, "TIME", "REQUESTS", "REQ/S", "FILLED", "FILL RATE")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		var current metrics.DeliveryStats
+		if err := client.do(ctx, "GET", "/v1/admin/stats", nil, &current); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		// Rates over the interval, the totals restart from zero when the server restarts
+		requests := current.Requests - previous.Requests
+		filled := current.Filled - previous.Filled
+		if requests < 0 {
+			requests, filled = current.Requests, current.Filled
+		}
+		fillRate := 1.0
+		if requests > 0 {
+			fillRate = float64(filled) / float64(requests)
+		}
+		fmt.Printf(row, time.Now().Format(time.TimeOnly), requests, fmt.Sprintf("%.1f", float64(requests)/interval.Seconds()),
+			filled, fmt.Sprintf("%.2f%%", fillRate*100))
+		previous = current
+	}
+}
+
+// runSetRules replaces the rules of the campaign in the file with the file's rules
+func runSetRules(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	file := fs.String("file", "", "JSON file with the campaign ID and its new rules, - for stdin")
+	targeting := newTargetingFlag(fs)
+	comment := fs.String("comment", "", "why the rules change, recorded with the version")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	// The campaign is named by the file, or by the argument when the rules are an expression
+	if *file == "" && (!targeting.given() || fs.NArg() != 1) || *file != "" && fs.NArg() > 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	campaign, err := targeting.read(*file)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 1 {
+		campaign.ID = fs.Arg(0)
+	}
+	if campaign.ID == "" {
+		return fmt.Errorf("%s has no campaign ID", *file)
+	}
+
+	var version ruleVersion
+	path := fmt.Sprintf("/v1/admin/campaigns/%s/rules", url.PathEscape(campaign.ID))
+	if err := newClient().do(context.Background(), "PUT", path, rulesUpdate{Rules: campaign.Rules, Comment: *comment}, &version); err != nil {
+		return err
+	}
+	printRuleVersion(version)
+	return nil
+}
+
+// runRulesHistory prints the rule versions of the campaign named by the only argument, newest first
+func runRulesHistory(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	asJSON := fs.Bool("json", false, "print the versions with their rules as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var versions []ruleVersion
+	path := fmt.Sprintf("/v1/admin/campaigns/%s/rules/versions", url.PathEscape(fs.Arg(0)))
+	if err := newClient().do(context.Background(), "GET", path, nil, &versions); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(versions)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tCREATED\tAUTHOR\tCOMMENT\tCHANGES")
+	for _, version := range versions {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", version.Version, version.CreatedAt.Format(time.RFC3339), version.Author,
+			version.Comment, formatRuleChanges(version.Changes))
+	}
+	return w.Flush()
+}
+
+// runRulesDiff prints the changes of a rule version of a campaign, the arguments name both
+func runRulesDiff(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	against := fs.Int("against", 0, "compare with this `version` instead of the previous one")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	path := fmt.Sprintf("/v1/admin/campaigns/%s/rules/versions/%s", url.PathEscape(fs.Arg(0)), url.PathEscape(fs.Arg(1)))
+	if *against > 0 {
+		path += fmt.Sprintf("?against=%d", *against)
+	}
+	var version ruleVersion
+	if err := newClient().do(context.Background(), "GET", path, nil, &version); err != nil {
+		return err
+	}
+	printRuleVersion(version)
+	return nil
+}
+
+// runRulesLint explains the rules of a campaign read from a file, an expression or the server and
+// prints the warnings about them
+func runRulesLint(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	file := fs.String("file", "", "JSON file with the campaign and its rules, - for stdin")
+	targeting := newTargetingFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	// The campaign is read locally or named by the only argument, not both
+	local := *file != "" || targeting.given()
+	if local == (fs.NArg() == 1) || fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var campaign models.CampaignWithRules
+	var err error
+	if local {
+		campaign, err = targeting.read(*file)
+		if campaign.Status == "" {
+			campaign.Status = models.StatusActive
+		}
+	} else {
+		campaign, err = fetchCampaign(newClient(), fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	lint := campaign.LintRules()
+	name := "the campaign"
+	if campaign.ID != "" {
+		name = "campaign " + campaign.ID
+	}
+	fmt.Printf("%s matches when:\n  - %s\n", name, strings.Join(lint.Explanation, "\n  - "))
+	if len(lint.Warnings) > 0 {
+		fmt.Printf("warnings:\n  - %s\n", strings.Join(lint.Warnings, "\n  - "))
+		return errInvalid
+	}
+	return nil
+}
+
+// fetchCampaign returns the campaign with ID id from the server
+func fetchCampaign(c *client, id string) (models.CampaignWithRules, error) {
+	var campaigns []models.CampaignWithRules
+	if err := c.do(context.Background(), "GET", "/v1/admin/campaigns", nil, &campaigns); err != nil {
+		return models.CampaignWithRules{}, err
+	}
+	for _, campaign := range campaigns {
+		if campaign.ID == id {
+			return campaign, nil
+		}
+	}
+	return models.CampaignWithRules{}, fmt.Errorf("campaign %s not found", id)
+}
+
+// runRulesRollback restores the rules of a version of a campaign, the arguments name both
+func runRulesRollback(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	path := fmt.Sprintf("/v1/admin/campaigns/%s/rules/versions/%s/rollback", url.PathEscape(fs.Arg(0)), url.PathEscape(fs.Arg(1)))
+	var version ruleVersion
+	if err := newClient().do(context.Background(), "POST", path, nil, &version); err != nil {
+		return err
+	}
+	printRuleVersion(version)
+	return nil
+}
+
+// runExport writes every campaign with its rules as a bundle to a file or stdout
+func runExport(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	file := fs.String("file", "-", "file the bundle is written to, - for stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var exported bundle.Bundle
+	if err := newClient().do(context.Background(), "GET", "/v1/admin/campaigns:export", nil, &exported); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+	if *file == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	if err := os.WriteFile(*file, content, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("exported %d campaigns to %s\n", len(exported.Campaigns), *file)
+	return nil
+}
+
+// runImport prints what importing a bundle changes, then imports it unless -dry-run is given or
+// it conflicts with the stored campaigns or holds invalid ones
+func runImport(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	file := fs.String("file", "", "JSON file with the bundle, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "only show what the import would change")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	var b bundle.Bundle
+	if err := readJSON(*file, &b); err != nil {
+		return err
+	}
+
+	c := newClient()
+	var diff bundle.Diff
+	if err := c.do(context.Background(), "POST", "/v1/admin/campaigns:import?dry_run=true", b, &diff); err != nil {
+		return err
+	}
+	printDiff(diff)
+	if len(diff.Conflicts) > 0 || len(diff.Invalid) > 0 {
+		fmt.Fprintln(os.Stderr, "import: fix the campaigns marked ! in the bundle or leave them out, nothing was imported")
+		return errInvalid
+	}
+	if *dryRun {
+		return nil
+	}
+
+	if err := c.do(context.Background(), "POST", "/v1/admin/campaigns:import", b, &diff); err != nil {
+		return err
+	}
+	fmt.Printf("imported: %d created, %d updated\n", len(diff.Create), len(diff.Update))
+	return nil
+}
+
+// printDiff prints the changes of an import, one campaign per line
+func printDiff(diff bundle.Diff) {
+	for _, id := range diff.Create {
+		fmt.Printf("+ %s\n", id)
+	}
+	for _, changes := range diff.Update {
+		fmt.Printf("~ %s: %s\n", changes.CID, strings.Join(changes.Fields, ", "))
+		if len(changes.Rules) > 0 {
+			fmt.Printf("    rules: %s\n", formatRuleChanges(changes.Rules))
+		}
+	}
+	for _, conflict := range diff.Conflicts {
+		fmt.Printf("! %s: %s can't be changed\n", conflict.CID, strings.Join(conflict.Fields, ", "))
+	}
+	for _, problems := range diff.Invalid {
+		fmt.Printf("! %s: %s\n", problems.CID, strings.Join(problems.Errors, "; "))
+	}
+	fmt.Printf("%d to create, %d to update, %d conflicting, %d invalid, %d unchanged", len(diff.Create), len(diff.Update),
+		len(diff.Conflicts), len(diff.Invalid), diff.Unchanged)
+	if len(diff.Extra) > 0 {
+		fmt.Printf(", %d not in the bundle and kept: %s", len(diff.Extra), strings.Join(diff.Extra, ", "))
+	}
+	fmt.Println()
+}
+
+// runBlocklist prints the blocklist entries
+func runBlocklist(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	asJSON := fs.Bool("json", false, "print the entries as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var entries []blocklist.Entry
+	if err := newClient().do(context.Background(), "GET", "/v1/admin/blocklist", nil, &entries); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tVALUE\tCREATED\tCOMMENT")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", entry.ID, entry.Kind, entry.Value, entry.CreatedAt.Format(time.RFC3339), entry.Comment)
+	}
+	return w.Flush()
+}
+
+// runBlock adds a blocklist entry, the arguments name its kind and value
+func runBlock(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	comment := fs.String("comment", "", "why the value is blocked")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var entry blocklist.Entry
+	request := blocklist.Entry{Kind: fs.Arg(0), Value: fs.Arg(1), Comment: *comment}
+	if err := newClient().do(context.Background(), "POST", "/v1/admin/blocklist", request, &entry); err != nil {
+		return err
+	}
+	fmt.Printf("blocked %s %s for every campaign, entry %d\n", entry.Kind, entry.Value, entry.ID)
+	return nil
+}
+
+// runUnblock removes the blocklist entry with the ID given as the only argument
+func runUnblock(fs *flag.FlagSet, args []string) error {
+	newClient := clientFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		fs.Usage()
+		return errUsage
+	}
+
+	if err := newClient().do(context.Background(), "DELETE", fmt.Sprintf("/v1/admin/blocklist/%d", id), nil, nil); err != nil {
+		return err
+	}
+	fmt.Printf("removed blocklist entry %d\n", id)
+	return nil
+}
+
+// printRuleVersion prints a rule version with one line per changed dimension and rule type
+func printRuleVersion(version ruleVersion) {
+	fmt.Printf("campaign %s rules version %d", version.CampaignID, version.Version)
+	if version.Author != "" {
+		fmt.Printf(" by %s", version.Author)
+	}
+	fmt.Printf(" at %s", version.CreatedAt.Format(time.RFC3339))
+	if version.Comment != "" {
+		fmt.Printf(": %s", version.Comment)
+	}
+	fmt.Println()
+
+	if version.Against == 0 {
+		fmt.Println("changes against no rules:")
+	} else {
+		fmt.Printf("changes against version %d:\n", version.Against)
+	}
+	if len(version.Changes) == 0 {
+		fmt.Println("  none")
+	}
+	for _, change := range version.Changes {
+		fmt.Printf("  %s\n", formatRuleChange(change))
+	}
+}
+
+// formatRuleChanges formats rule changes on one line, e.g. "country include +in -ca; os exclude +ios"
+func formatRuleChanges(changes []models.RuleChange) string {
+	formatted := make([]string, len(changes))
+	for i, change := range changes {
+		formatted[i] = formatRuleChange(change)
+	}
+	return strings.Join(formatted, "; ")
+}
+
+func formatRuleChange(change models.RuleChange) string {
+	parts := []string{string(change.Dimension), string(change.RuleType)}
+	for _, value := range change.Added {
+		parts = append(parts, "+"+value)
+	}
+	for _, value := range change.Removed {
+		parts = append(parts, "-"+value)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kit/log"
+	"github.com/go-redis/redis/v8"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/blocklist"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/campaignstats"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/middleware"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reach"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunExitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "no command", args: nil, want: 2},
+		{name: "help", args: []string{"help"}, want: 0},
+		{name: "command help", args: []string{"stats", "-h"}, want: 0},
+		{name: "unknown command", args: []string{"bogus"}, want: 2},
+		{name: "missing campaign", args: []string{"pause"}, want: 2},
+		{name: "campaigns and filter", args: []string{"resume", "-name", "music", "spotify"}, want: 2},
+		{name: "missing stats campaign", args: []string{"campaign-stats"}, want: 2},
+		{name: "missing reach campaign", args: []string{"campaign-reach"}, want: 2},
+		{name: "missing file", args: []string{"create"}, want: 2},
+		{name: "missing rules file", args: []string{"set-rules"}, want: 2},
+		{name: "rules expression without campaign", args: []string{"set-rules", "-rules", "os = ios"}, want: 2},
+		{name: "rules file and campaign", args: []string{"set-rules", "-file", "campaign.json", "spotify"}, want: 2},
+		{name: "invalid rules expression", args: []string{"simulate", "-rules", "os in (ios"}, want: 1},
+		{name: "missing bundle file", args: []string{"import", "-dry-run"}, want: 2},
+		{name: "nothing to lint", args: []string{"rules-lint"}, want: 2},
+		{name: "lint rules and campaign", args: []string{"rules-lint", "-rules", "os = ios", "spotify"}, want: 2},
+		{name: "lint clean rules", args: []string{"rules-lint", "-rules", "country in (us, ca) and os = ios"}, want: 0},
+		{name: "lint rules with warnings", args: []string{"rules-lint", "-rules", "country = us and country != de"}, want: 1},
+		{name: "invalid traffic percentage", args: []string{"set-traffic", "spotify", "half"}, want: 2},
+		{name: "missing rollback version", args: []string{"rules-rollback", "spotify"}, want: 2},
+		{name: "missing category campaign", args: []string{"set-category"}, want: 2},
+		{name: "missing image campaign", args: []string{"set-image"}, want: 2},
+		{name: "missing blocked value", args: []string{"block", "app"}, want: 2},
+		{name: "invalid blocklist entry id", args: []string{"unblock", "first"}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, run(tt.args))
+		})
+	}
+}
+
+func TestCommandsAgainstAdminAPI(t *testing.T) {
+	store := repository.NewMockRepository().(service.CampaignStore)
+	redisClient := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer redisClient.Close()
+	server := httptest.NewServer(middleware.NewAdminAuthMiddleware("s3cret").Middleware(transport.NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), transport.HandlerOptions{
+		Campaigns:     store,
+		DeliveryStats: func() metrics.DeliveryStats { return metrics.DeliveryStats{Requests: 10, Filled: 7, FillRate: 0.7} },
+		CampaignStats: campaignstats.NewTracker(redisClient, "adbeacon:"),
+		Reach:         reach.NewEstimator(redisClient, "adbeacon:", 7),
+		Blocklist:     blocklist.New(blocklist.NewMemoryStore()),
+	})))
+	defer server.Close()
+
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{"cid":"netflix","name":"Netflix","rules":[{"dimension":"country","rule_type":"include","values":["us"]}]}`), 0o644))
+	requests := filepath.Join(dir, "requests.jsonl")
+	require.NoError(t, os.WriteFile(requests, []byte("{\"country\":\"us\",\"os\":\"ios\",\"app\":\"a\"}\n{\"country\":\"in\",\"os\":\"ios\",\"app\":\"a\"}\n"), 0o644))
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{"cid":"netflix","name":"Netflix","rules":[{"dimension":"planet","rule_type":"include","values":["mars"]}]}`), 0o644))
+
+	addr := []string{"-addr", server.URL}
+	// The admin API rejects commands without the admin token
+	t.Setenv("ADBEACON_TOKEN", "")
+	assert.Equal(t, 1, run(append([]string{"list"}, addr...)))
+	t.Setenv("ADBEACON_TOKEN", "s3cret")
+	assert.Equal(t, 0, run(append([]string{"validate-rules", "-file", valid}, addr...)))
+	assert.Equal(t, 1, run(append([]string{"validate-rules", "-file", invalid}, addr...)))
+	assert.Equal(t, 0, run(append([]string{"simulate", "-file", valid, "-count", "100"}, addr...)))
+	assert.Equal(t, 0, run(append([]string{"simulate", "-file", valid, "-requests", requests}, addr...)))
+	assert.Equal(t, 0, run(append([]string{"simulate", "-rules", "country in (us, in) and os = ios", "-requests", requests}, addr...)))
+	assert.Equal(t, 1, run(append([]string{"validate-rules", "-file", valid, "-rules", "planet = mars"}, addr...)))
+	assert.Equal(t, 1, run(append([]string{"simulate", "-file", invalid}, addr...)))
+	assert.Equal(t, 0, run(append([]string{"create", "-file", valid}, addr...)))
+	// The server rejects a second campaign with the same ID
+	assert.Equal(t, 1, run(append([]string{"create", "-file", valid}, addr...)))
+	assert.Equal(t, 0, run(append(append([]string{"pause"}, addr...), "netflix")))
+	assert.Equal(t, 1, run(append(append([]string{"pause"}, addr...), "unknown")))
+	assert.Equal(t, 0, run(append([]string{"pause", "-id-prefix", "spot"}, addr...)))
+	assert.Equal(t, 0, run(append(append([]string{"resume"}, addr...), "spotify", "duolingo")))
+	assert.Equal(t, 1, run(append(append([]string{"pause"}, addr...), "spotify", "unknown")))
+	assert.Equal(t, 0, run(append([]string{"list"}, addr...)))
+	assert.Equal(t, 0, run(append(append([]string{"set-traffic"}, addr...), "netflix", "25%")))
+	assert.Equal(t, 1, run(append(append([]string{"set-traffic"}, addr...), "netflix", "150")))
+	assert.Equal(t, 0, run(append(append([]string{"set-category"}, addr...), "netflix", "streaming")))
+	assert.Equal(t, 0, run(append(append([]string{"set-category"}, addr...), "netflix")))
+	assert.Equal(t, 1, run(append(append([]string{"set-category"}, addr...), "unknown", "streaming")))
+	assert.Equal(t, 0, run(append(append([]string{"set-image"}, addr...), "netflix", "https://img.example.com/netflix.png")))
+	assert.Equal(t, 1, run(append(append([]string{"set-image"}, addr...), "unknown", "https://img.example.com/netflix.png")))
+	assert.Equal(t, 0, run(append([]string{"stats"}, addr...)))
+	assert.Equal(t, 0, run(append(append([]string{"campaign-stats"}, addr...), "spotify")))
+	assert.Equal(t, 0, run(append(append([]string{"campaign-reach"}, addr...), "spotify")))
+	assert.Equal(t, 1, run(append(append([]string{"campaign-reach"}, addr...), "unknown")))
+	assert.Equal(t, 0, run(append(append([]string{"block", "-comment", "fraudulent installs"}, addr...), "app", "com.example.app")))
+	assert.Equal(t, 1, run(append(append([]string{"block"}, addr...), "app", "com.example.app")))
+	assert.Equal(t, 1, run(append(append([]string{"block"}, addr...), "os", "ios")))
+	assert.Equal(t, 0, run(append([]string{"blocklist"}, addr...)))
+	assert.Equal(t, 0, run(append(append([]string{"unblock"}, addr...), "1")))
+	assert.Equal(t, 1, run(append(append([]string{"unblock"}, addr...), "1")))
+	assert.Equal(t, 0, run(append([]string{"set-rules", "-file", valid, "-comment", "same rules", "-user", "alice"}, addr...)))
+	assert.Equal(t, 1, run(append([]string{"set-rules", "-file", invalid}, addr...)))
+	assert.Equal(t, 0, run(append(append([]string{"set-rules", "-rules", "country in (us, ca)"}, addr...), "spotify")))
+	assert.Equal(t, 0, run(append(append([]string{"rules-lint"}, addr...), "spotify")))
+	assert.Equal(t, 1, run(append(append([]string{"rules-lint"}, addr...), "unknown")))
+	assert.Equal(t, 0, run(append(append([]string{"rules-history"}, addr...), "netflix")))
+	assert.Equal(t, 0, run(append(append([]string{"rules-diff", "-against", "1"}, addr...), "netflix", "2")))
+	assert.Equal(t, 0, run(append(append([]string{"rules-rollback"}, addr...), "netflix", "1")))
+	assert.Equal(t, 1, run(append(append([]string{"rules-rollback"}, addr...), "netflix", "9")))
+	bundleFile := filepath.Join(dir, "bundle.json")
+	assert.Equal(t, 0, run(append([]string{"export", "-file", bundleFile}, addr...)))
+	assert.Equal(t, 0, run(append([]string{"import", "-dry-run", "-file", bundleFile}, addr...)))
+	conflicting := filepath.Join(dir, "conflicting.json")
+	require.NoError(t, os.WriteFile(conflicting, []byte(`{"version":1,"campaigns":[{"cid":"spotify","name":"Spotify Premium","cta":"Download","status":"ACTIVE"}]}`), 0o644))
+	assert.Equal(t, 1, run(append([]string{"import", "-file", conflicting}, addr...)))
+	imported := filepath.Join(dir, "imported.json")
+	require.NoError(t, os.WriteFile(imported, []byte(`{"version":1,"campaigns":[{"cid":"hulu","name":"Hulu","cta":"Watch","status":"INACTIVE"}]}`), 0o644))
+	assert.Equal(t, 0, run(append([]string{"import", "-file", imported}, addr...)))
+	// The cache invalidation endpoint is not enabled without a cache
+	assert.Equal(t, 1, run(append([]string{"invalidate-cache"}, addr...)))
+
+	versions, err := store.ListRuleVersions(context.Background(), "netflix")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, "alice", versions[1].Author)
+	assert.Equal(t, "same rules", versions[1].Comment)
+
+	campaigns, err := store.ListCampaigns(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 5)
+	for _, campaign := range campaigns {
+		if campaign.ID == "netflix" {
+			assert.Equal(t, models.StatusInactive, campaign.Status)
+			assert.Equal(t, 25, campaign.TrafficPct)
+			return
+		}
+	}
+	t.Fatal("created campaign not found")
+}
@@ -0,0 +1,186 @@
+// loadgen fires synthetic delivery traffic at a running adbeacon instance and reports
+// latency percentiles and fill rate, so performance can be compared between builds
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/simulation"
+)
+
+// options configures a load test run
+type options struct {
+	addr        string
+	qps         float64
+	duration    time.Duration
+	concurrency int
+	timeout     time.Duration
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+// run parses the flags, runs the load test and writes the report to w, it returns the exit status
+func run(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	addr := fs.String("addr", "http://localhost:8080", "base URL of the adbeacon server")
+	qps := fs.Float64("qps", 100, "requests per second to send")
+	duration := fs.Duration("duration", 30*time.Second, "how long to send traffic")
+	concurrency := fs.Int("concurrency", 50, "maximum requests in flight, requests due beyond it are skipped and reported")
+	timeout := fs.Duration("timeout", 2*time.Second, "timeout of each request")
+	seed := fs.Int64("seed", 1, "seed of the dimension value picks, the same seed sends the same request sequence")
+	countries := fs.String("countries", simulation.DefaultCountries, "country values with relative weights")
+	oses := fs.String("os", simulation.DefaultOS, "os values with relative weights")
+	apps := fs.String("apps", simulation.DefaultApps, "app values with relative weights")
+	states := fs.String("states", "", "state values with relative weights, empty omits the state parameter")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: loadgen [flags]\n\nSends GET /v1/delivery at a fixed rate and reports latency percentiles and fill rate.\nWeights are comma separated value:weight pairs, e.g. us:60,in:40.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	opts := options{
+		addr:        *addr,
+		qps:         *qps,
+		duration:    *duration,
+		concurrency: *concurrency,
+		timeout:     *timeout,
+	}
+	generator, err := simulation.NewGenerator(simulation.SyntheticConfig{
+		Seed:      *seed,
+		Countries: *countries,
+		OS:        *oses,
+		Apps:      *apps,
+		States:    *states,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-%v\n", err)
+		return 2
+	}
+	if opts.qps <= 0 || opts.duration <= 0 || opts.concurrency <= 0 {
+		fmt.Fprintln(os.Stderr, "-qps, -duration and -concurrency must be positive")
+		return 2
+	}
+
+	// Stop early on interrupt and still report what was measured
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "sending %.1f requests/s to %s for %s\n", opts.qps, opts.addr, opts.duration)
+	rep := generate(ctx, opts, generator)
+	if err := rep.write(w, *asJSON); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if rep.Requests == 0 {
+		return 1
+	}
+	return 0
+}
+
+// generate sends requests at opts.qps until opts.duration elapsed or ctx is done. The rate is
+// open-loop: requests are sent on schedule whether or not earlier ones completed, so a slow
+// server shows up as latency instead of silently lowering the rate.
+func generate(ctx context.Context, opts options, generator *simulation.Generator) report {
+	ctx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+
+	client := &http.Client{
+		Timeout: opts.timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        opts.concurrency,
+			MaxIdleConnsPerHost: opts.concurrency,
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	// Requests are generated on this goroutine only, so a seed always yields the same request sequence
+	interval := time.Duration(float64(time.Second) / opts.qps)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		mu      sync.Mutex
+		results []result
+		wg      sync.WaitGroup
+		skipped int
+	)
+	inFlight := make(chan struct{}, opts.concurrency)
+	start := time.Now()
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+
+		target := opts.addr + "/v1/delivery?" + query(generator.Next()).Encode()
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			skipped++
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+
+			res := send(client, target)
+			mu.Lock()
+			results = append(results, res)
+			mu.Unlock()
+		}()
+	}
+	elapsed := time.Since(start)
+	wg.Wait()
+
+	return newReport(results, skipped, elapsed, opts.qps)
+}
+
+// query encodes a delivery request as query parameters
+func query(req models.DeliveryRequest) url.Values {
+	values := url.Values{}
+	values.Set("country", req.Country)
+	values.Set("os", req.OS)
+	values.Set("app", req.App)
+	if req.State != "" {
+		values.Set("state", req.State)
+	}
+	return values
+}
+
+// send performs one delivery request, it is not bound to the run context so in-flight
+// requests complete and are measured when the run ends
+func send(client *http.Client, target string) result {
+	start := time.Now()
+	resp, err := client.Get(target)
+	if err != nil {
+		return result{latency: time.Since(start)}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return result{latency: time.Since(start), status: resp.StatusCode}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReport(t *testing.T) {
+	var results []result
+	for i := 1; i <= 100; i++ {
+		status := http.StatusOK
+		if i%4 == 0 {
+			status = http.StatusNoContent
+		}
+		results = append(results, result{latency: time.Duration(i) * time.Millisecond, status: status})
+	}
+	results = append(results, result{status: http.StatusInternalServerError, latency: time.Second}, result{})
+
+	r := newReport(results, 3, 2*time.Second, 50)
+
+	assert.Equal(t, 102, r.Requests)
+	assert.Equal(t, 3, r.Skipped)
+	assert.Equal(t, 2, r.Errors)
+	assert.Equal(t, 51.0, r.AchievedQPS)
+	// Fill rate only counts requests answered with or without campaigns
+	assert.Equal(t, 0.75, r.FillRate)
+	assert.Equal(t, "51ms", r.Latency["p50"])
+	assert.Equal(t, "100ms", r.Latency["p99"])
+	assert.Equal(t, "1s", r.Latency["max"])
+	assert.Equal(t, map[string]int{"200": 75, "204": 25, "500": 1, "error": 1}, r.Statuses)
+}
+
+func TestRunAgainstServer(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/delivery", r.URL.Path)
+		assert.Equal(t, "us", r.URL.Query().Get("country"))
+		if requests.Add(1)%2 == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`[{"cid":"spotify"}]`))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	status := run([]string{"-addr", server.URL, "-qps", "200", "-duration", "250ms", "-countries", "us", "-json"}, &out)
+	require.Equal(t, 0, status)
+
+	var r report
+	require.NoError(t, json.Unmarshal(out.Bytes(), &r))
+	assert.Equal(t, int(requests.Load()), r.Requests)
+	assert.Positive(t, r.Requests)
+	assert.Zero(t, r.Errors)
+	assert.InDelta(t, 0.5, r.FillRate, 0.1)
+	assert.NotEmpty(t, r.Latency["p99"])
+}
+
+func TestRunInvalidFlags(t *testing.T) {
+	assert.Equal(t, 2, run([]string{"-qps", "0"}, &bytes.Buffer{}))
+	assert.Equal(t, 2, run([]string{"-countries", "us:-1"}, &bytes.Buffer{}))
+	assert.Equal(t, 0, run([]string{"-h"}, &bytes.Buffer{}))
+}
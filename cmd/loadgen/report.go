@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// percentiles reported for the request latency
+var percentiles = []float64{50, 90, 95, 99, 99.9}
+
+// result is the outcome of a single delivery request
+type result struct {
+	latency time.Duration
+	// status is the HTTP status code, 0 when the request failed without a response
+	status int
+}
+
+// report summarizes a load test run
+type report struct {
+	Duration    string            `json:"duration"`
+	TargetQPS   float64           `json:"target_qps"`
+	AchievedQPS float64           `json:"achieved_qps"`
+	Requests    int               `json:"requests"`
+	Skipped     int               `json:"skipped"`
+	Errors      int               `json:"errors"`
+	Statuses    map[string]int    `json:"statuses"`
+	FillRate    float64           `json:"fill_rate"`
+	Latency     map[string]string `json:"latency"`
+}
+
+// newReport aggregates results, skipped counts the requests not sent because the
+// concurrency limit was reached
+func newReport(results []result, skipped int, elapsed time.Duration, targetQPS float64) report {
+	r := report{
+		Duration:  elapsed.Round(time.Millisecond).String(),
+		TargetQPS: targetQPS,
+		Requests:  len(results),
+		Skipped:   skipped,
+		Statuses:  make(map[string]int),
+		Latency:   make(map[string]string),
+	}
+	if elapsed > 0 {
+		r.AchievedQPS = math.Round(float64(len(results))/elapsed.Seconds()*10) / 10
+	}
+
+	// A delivery request is filled when it returned campaigns (200) and not filled on 204
+	var filled, answered int
+	var latencies []time.Duration
+	for _, res := range results {
+		if res.status == 0 {
+			r.Errors++
+			r.Statuses["error"]++
+			continue
+		}
+		r.Statuses[strconv.Itoa(res.status)]++
+		switch res.status {
+		case http.StatusOK:
+			filled++
+			answered++
+		case http.StatusNoContent:
+			answered++
+		default:
+			if res.status >= 500 {
+				r.Errors++
+			}
+		}
+		latencies = append(latencies, res.latency)
+	}
+	if answered > 0 {
+		r.FillRate = math.Round(float64(filled)/float64(answered)*10000) / 10000
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	for _, p := range percentiles {
+		r.Latency[percentileName(p)] = percentile(latencies, p).String()
+	}
+	if len(latencies) > 0 {
+		r.Latency["max"] = latencies[len(latencies)-1].String()
+	}
+	return r
+}
+
+// percentile returns the nearest-rank percentile p of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func percentileName(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// write prints the report for humans, or as JSON for comparing runs with other tools
+func (r report) write(w io.Writer, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	}
+
+	fmt.Fprintf(w, "duration      %s\n", r.Duration)
+	fmt.Fprintf(w, "requests      %d (%.1f/s, target %.1f/s)\n", r.Requests, r.AchievedQPS, r.TargetQPS)
+	if r.Skipped > 0 {
+		fmt.Fprintf(w, "skipped       %d, the concurrency limit was reached, raise -concurrency\n", r.Skipped)
+	}
+	fmt.Fprintf(w, "errors        %d\n", r.Errors)
+	fmt.Fprintf(w, "fill rate     %.2f%%\n", r.FillRate*100)
+
+	statuses := make([]string, 0, len(r.Statuses))
+	for status := range r.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	fmt.Fprintf(w, "statuses     ")
+	for _, status := range statuses {
+		fmt.Fprintf(w, " %s=%d", status, r.Statuses[status])
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "latency")
+	for _, p := range percentiles {
+		name := percentileName(p)
+		fmt.Fprintf(w, "  %-10s  %s\n", name, r.Latency[name])
+	}
+	if max, ok := r.Latency["max"]; ok {
+		fmt.Fprintf(w, "  %-10s  %s\n", "max", max)
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/config"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/database"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/logger"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/version"
+)
+
+// command is a subcommand of the server binary
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(fs *flag.FlagSet, args []string) error
+}
+
+var (
+	// errUsage makes run exit with status 2, the problem was already reported with the usage
+	errUsage = errors.New("invalid usage")
+	// errHelp makes run exit with status 0 after the usage was printed for -h
+	errHelp = errors.New("help requested")
+)
+
+var commands = []command{
+	{"serve", "serve [-config file] [-port port]", "Run the ad delivery server (default)", runServe},
+	{"migrate", "migrate [-config file] [-migrations dir] [up|down|version|force <version>]", "Run or inspect database migrations", runMigrate},
+	{"seed", "seed [-config file] [-migrations dir] [-file campaigns.json]", "Insert campaigns, the sample campaigns by default", runSeed},
+	{"validate-config", "validate-config [-config file]", "Validate the configuration and resolve secrets", runValidateConfig},
+	{"version", "version", "Print the version", runVersion},
+}
+
+// run dispatches to the subcommand named by the first argument, serving when there is none
+// so the binary keeps working without arguments. It returns the exit status.
+func run(args []string) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		switch err := cmd.run(newFlagSet(cmd), args); {
+		case err == nil, errors.Is(err, errHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		default:
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: adbeacon <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'adbeacon <command> -h' for the flags of a command.")
+}
+
+// newFlagSet creates the flag set of a command, printing its usage on -h and invalid flags
+func newFlagSet(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: adbeacon %s\n\n%s\n\nFlags:\n", cmd.usage, cmd.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// configFlag registers the -config flag shared by the commands loading the configuration
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", "", "env file to load, defaults to CONFIG_FILE or .env")
+}
+
+// parseFlags parses args, the flag set already printed the usage when it fails
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return errHelp
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// loadConfig loads the configuration from configFile, or the default env file when empty
+func loadConfig(configFile string) *config.Config {
+	if configFile == "" {
+		return config.Load()
+	}
+	return config.LoadFrom(configFile)
+}
+
+// prepareConfig validates cfg and resolves its secrets, the configuration must not be used when it fails
+func prepareConfig(cfg *config.Config) error {
+	// Fail fast with a single report of every configuration problem
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	// Resolve passwords through the secret provider before anything connects
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := cfg.LoadSecrets(ctx); err != nil {
+		return fmt.Errorf("failed to load secrets: %w", err)
+	}
+	return nil
+}
+
+// newCommandLogger creates the logger of the operational commands
+func newCommandLogger(cfg *config.Config) kitlog.Logger {
+	return logger.New(logger.Config{
+		Service: "adbeacon",
+		Version: version.Version,
+		Format:  cfg.GeneralConfig.LogFormat,
+		Level:   cfg.GeneralConfig.LogLevel,
+	})
+}
+
+func runServe(fs *flag.FlagSet, args []string) error {
+	configFile := configFlag(fs)
+	port := fs.Int("port", 0, "port to listen on, overrides PORT")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg := loadConfig(*configFile)
+	if *port != 0 {
+		cfg.GeneralConfig.Port = *port
+	}
+	if err := prepareConfig(cfg); err != nil {
+		return err
+	}
+
+	serve(cfg)
+	return nil
+}
+
+func runMigrate(fs *flag.FlagSet, args []string) error {
+	configFile := configFlag(fs)
+	migrationsDir := fs.String("migrations", "./migrations", "directory of the migration files")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg := loadConfig(*configFile)
+	if err := prepareConfig(cfg); err != nil {
+		return err
+	}
+	logger := newCommandLogger(cfg)
+
+	manager, err := database.OpenMigrationManager(cfg.DatabaseConfig, *migrationsDir, logger)
+	if err != nil {
+		return err
+	}
+
+	action := "up"
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+	switch action {
+	case "up":
+		return manager.Up()
+	case "down":
+		return manager.Down()
+	case "version":
+		version, dirty, err := manager.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			fmt.Println("no migrations applied")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version %d, dirty %t\n", version, dirty)
+		return nil
+	case "force":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return errUsage
+		}
+		version, err := strconv.Atoi(fs.Arg(1))
+		if err != nil {
+			return fmt.Errorf("invalid version %q", fs.Arg(1))
+		}
+		return manager.Force(version)
+	default:
+		fs.Usage()
+		return errUsage
+	}
+}
+
+func runSeed(fs *flag.FlagSet, args []string) error {
+	configFile := configFlag(fs)
+	migrationsDir := fs.String("migrations", "./migrations", "directory of the migration files")
+	file := fs.String("file", "", "JSON file with the campaigns and their rules, defaults to the sample campaigns")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg := loadConfig(*configFile)
+	if err := prepareConfig(cfg); err != nil {
+		return err
+	}
+	logger := newCommandLogger(cfg)
+	ctx := context.Background()
+
+	campaigns, err := seedCampaigns(ctx, *file)
+	if err != nil {
+		return err
+	}
+
+	// Initialize also applies pending migrations, the tables must exist before seeding
+	db, cleanup, err := database.Initialize(cfg.DatabaseConfig, *migrationsDir, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	inserted, err := repository.SeedCampaigns(ctx, db, campaigns)
+	if err != nil {
+		return err
+	}
+	level.Info(logger).Log("msg", "seeded campaigns", "inserted", inserted, "skipped", len(campaigns)-inserted)
+	return nil
+}
+
+// seedCampaigns reads the campaigns to seed from file, or returns the sample campaigns
+func seedCampaigns(ctx context.Context, file string) ([]models.CampaignWithRules, error) {
+	if file == "" {
+		return repository.NewMockRepository().GetActiveCampaignsWithRules(ctx)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var campaigns []models.CampaignWithRules
+	if err := json.Unmarshal(content, &campaigns); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return campaigns, nil
+}
+
+func runValidateConfig(fs *flag.FlagSet, args []string) error {
+	configFile := configFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg := loadConfig(*configFile)
+	if err := prepareConfig(cfg); err != nil {
+		return err
+	}
+	fmt.Printf("configuration is valid (env %s, repository %s)\n", cfg.GeneralConfig.Env, cfg.GeneralConfig.Repository)
+	return nil
+}
+
+func runVersion(fs *flag.FlagSet, args []string) error {
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	fmt.Printf("adbeacon %s\n", version.Get())
+	return nil
+}
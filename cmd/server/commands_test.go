@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunExitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "version", args: []string{"version"}, want: 0},
+		{name: "help", args: []string{"help"}, want: 0},
+		{name: "command help", args: []string{"migrate", "-h"}, want: 0},
+		{name: "unknown command", args: []string{"bogus"}, want: 2},
+		{name: "unknown flag", args: []string{"validate-config", "-bogus"}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, run(tt.args))
+		})
+	}
+}
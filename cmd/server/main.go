@@ -2,39 +2,108 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/anomaly"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/blocklist"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/breaker"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/campaignstats"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/config"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/creative"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/database"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/decisionlog"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/dedup"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/errorreporter"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/events"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/fraud"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/health"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/loadshed"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/logger"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/middleware"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/overview"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reach"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/readiness"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reload"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reporting"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/runtimelimits"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/scheduler"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/signing"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/slo"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/tracking"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/transport"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/version"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/watchdog"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/webhook"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-const VERSION = "1.0.0"
-
-func init() {
-	config.LoadConfigs()
-	log.Println("AdBeacon: Loaded all configs")
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
 
-func main() {
+// serve runs the ad delivery server until it receives SIGINT or SIGTERM
+func serve(cfg *config.Config) {
+	var err error
+
+	// Log level and request log sampling can be changed at runtime via /v1/admin/logging
+	logControls := logger.NewControls(
+		cfg.GeneralConfig.LogLevel,
+		cfg.AccessLogConfig.SampleRate,
+	)
 	logger := logger.New(logger.Config{
-		Service: "adbeacon",
-		Version: VERSION,
+		Service:  "adbeacon",
+		Version:  version.Version,
+		Format:   cfg.GeneralConfig.LogFormat,
+		Controls: logControls,
+		Redaction: logger.RedactionConfig{
+			HashFields: cfg.LogRedactionConfig.HashFields,
+			DropFields: cfg.LogRedactionConfig.DropFields,
+			Salt:       cfg.LogRedactionConfig.Salt,
+		},
 	})
+	level.Info(logger).Log("msg", "loaded all configs", "env", cfg.GeneralConfig.Env)
+
+	// Size GOMAXPROCS and GOMEMLIMIT to the container before anything reads them
+	runtimeLimits, err := runtimelimits.Apply(runtimelimits.Config{
+		AutoMaxProcs:  cfg.RuntimeConfig.AutoMaxProcs,
+		AutoMemLimit:  cfg.RuntimeConfig.AutoMemLimit,
+		MemLimitRatio: cfg.RuntimeConfig.MemLimitRatio,
+	}, logger)
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to apply runtime limits", "err", err)
+	}
+	level.Info(logger).Log("msg", "runtime limits applied",
+		"gomaxprocs", runtimeLimits.GOMAXPROCS, "gomaxprocs_source", runtimeLimits.GOMAXPROCSSource,
+		"memory_limit_bytes", runtimeLimits.MemoryLimit, "memory_limit_source", runtimeLimits.MemoryLimitSource)
+	prometheus.MustRegister(runtimelimits.NewCollector(runtimeLimits))
+
+	// OS aliases apply to campaigns and requests alike, set them before any campaign is loaded
+	osAliases, err := models.ParseOSAliases(cfg.ValidationConfig.OSAliases)
+	if err != nil {
+		level.Error(logger).Log("msg", "invalid OS aliases", "err", err)
+		os.Exit(1)
+	}
+	models.SetOSAliases(osAliases)
 
 	// Initialize Prometheus metrics with caching
 	// This is a pre-cached metrics instance that can be used to avoid creating new metrics instances for each request
@@ -48,65 +117,507 @@ func main() {
 	// 		Without caching: 2000 × 30ns = 60,000ns = 0.06ms per second
 	// 		With caching: 2000 × 12ns = 24,000ns = 0.024ms per second
 	// That's a 60% reduction in metrics overhead!
-	prometheusMetrics := metrics.NewCachedMetrics()
-	log.Println("Cached Prometheus metrics initialized")
+	prometheusMetrics := metrics.NewCachedMetricsWithOptions(metrics.Options{
+		HTTPDurationBuckets:         cfg.MetricsConfig.HTTPDurationBuckets,
+		DatabaseDurationBuckets:     cfg.MetricsConfig.DatabaseDurationBuckets,
+		NativeHistogramBucketFactor: cfg.MetricsConfig.NativeHistogramBucketFactor,
+		AppLabelLimit:               cfg.MetricsConfig.AppLabelLimit,
+		CountryLabelLimit:           cfg.MetricsConfig.CountryLabelLimit,
+		OSLabelLimit:                cfg.MetricsConfig.OSLabelLimit,
+		AppLabelAllowlist:           cfg.MetricsConfig.AppLabelAllowlist,
+	})
+	prometheusMetrics.SetClientLabelLimit(cfg.MetricsConfig.ClientLabelLimit)
+	level.Info(logger).Log("msg", "cached prometheus metrics initialized")
 
-	// Initialize database
-	db, dbCleanup, err := database.Initialize(config.AppConfigInstance.DatabaseConfig, "./migrations")
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	// Go runtime, process and build info metrics under the adbeacon_ prefix
+	if err := metrics.RegisterRuntimeCollectors(prometheus.DefaultRegisterer); err != nil {
+		level.Error(logger).Log("msg", "failed to register runtime metrics", "err", err)
+		os.Exit(1)
+	}
+
+	// Log and count when goroutines or heap grow past their thresholds
+	watchdogConfig := cfg.WatchdogConfig
+	runtimeWatchdog := watchdog.New(watchdog.Config{
+		Interval:      time.Duration(watchdogConfig.Interval) * time.Second,
+		MaxGoroutines: watchdogConfig.MaxGoroutines,
+		MaxHeapBytes:  uint64(watchdogConfig.MaxHeapMB) << 20,
+	}, logger)
+	prometheus.MustRegister(runtimeWatchdog)
+	watchdogCtx, stopWatchdog := context.WithCancel(context.Background())
+	defer stopWatchdog()
+	go runtimeWatchdog.Run(watchdogCtx)
+
+	// Initialize error reporting (Sentry when a DSN is configured)
+	reporter := initializeErrorReporter(cfg.ErrorReportingConfig, logger)
+	defer reporter.Close()
+
+	// Initialize database, mock mode serves sample campaigns without one
+	var db *database.DB
+	if cfg.GeneralConfig.Repository == config.RepositoryMock {
+		level.Warn(logger).Log("msg", "mock mode, serving sample campaigns without a database")
+	} else {
+		var dbCleanup func()
+		db, dbCleanup, err = database.Initialize(cfg.DatabaseConfig, "./migrations", logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to initialize database", "err", err)
+			os.Exit(1)
+		}
+		defer func() {
+			level.Info(logger).Log("msg", "closing database connection")
+			dbCleanup()
+			level.Info(logger).Log("msg", "database connection closed")
+		}()
+		level.Info(logger).Log("msg", "database initialized successfully")
 	}
-	defer func() {
-		log.Println("Closing database connection...")
-		dbCleanup()
-		log.Println("Database connection closed")
-	}()
-	log.Println("Database initialized successfully")
 
 	// Add cache initialization example
-	cache, err := initializeCache()
+	cache, err := initializeCache(cfg.CacheConfig)
 	if err != nil {
-		log.Fatalf("Failed to initialize cache: %v", err)
+		level.Error(logger).Log("msg", "failed to initialize cache", "err", err)
+		os.Exit(1)
 	}
 	defer func() {
-		log.Println("Closing cache...")
+		level.Info(logger).Log("msg", "closing cache")
 		if err := cache.Close(); err != nil {
-			log.Printf("Error closing cache: %v", err)
+			level.Error(logger).Log("msg", "error closing cache", "err", err)
 		}
-		log.Println("Cache closed")
+		level.Info(logger).Log("msg", "cache closed")
 	}()
-	log.Println("Cache initialized successfully")
+	level.Info(logger).Log("msg", "cache initialized successfully")
+
+	// Rolling per-campaign delivery counters, shared by all replicas through Redis
+	campaignStats, stopCampaignStats := initializeCampaignStats(cfg, logger)
+	defer stopCampaignStats()
 
-	// Repository layer (data access) with caching
-	cachedRepo := setupCachedRepository(db, cache, prometheusMetrics)
+	// Daily request histograms campaign reach is estimated from, shared by all replicas through Redis
+	reachEstimator, stopReach := initializeReach(cfg, logger)
+	defer stopReach()
+
+	// Monthly delivery quotas per API key, shared by all replicas through Redis
+	quotas, stopQuotas := initializeQuotas(cfg, logger)
+	defer stopQuotas()
+
+	// Export cache statistics to Prometheus, collected at scrape time
+	prometheus.MustRegister(cache)
+	cache.SetHedgeRecorder(prometheusMetrics)
+
+	// Repository layer (data access) with caching, sample campaigns without a database in mock mode
+	var sourceRepo service.CampaignRepository = repository.NewMockRepository()
+	if db != nil {
+		sourceRepo = repository.NewPostgresRepository(db)
+	}
+	cachedRepo := setupCachedRepository(cfg, sourceRepo, cache, prometheusMetrics, logger, reporter)
+
+	// Campaigns persisted to disk by the last run spare the database load of a cold start
+	stopSnapshotPersistence := initializeSnapshotPersistence(cfg.CacheConfig, cachedRepo, cache, logger)
+	defer stopSnapshotPersistence()
+
+	// Delivery requests read a campaign snapshot rebuilt in the background as the cache reloads
+	snapshotCtx, stopSnapshotRefresh := context.WithCancel(context.Background())
+	defer stopSnapshotRefresh()
+	go cachedRepo.RunSnapshotRefresh(snapshotCtx, cfg.CacheConfig.RefreshInterval, func(err error) {
+		level.Warn(logger).Log("msg", "campaign snapshot refresh failed", "err", err)
+	})
+
+	// Campaign management for the admin API, changes bypass the read path wrappers. Changes
+	// exceeding the campaign limits are refused.
+	campaignStore, _ := sourceRepo.(service.CampaignStore)
+	if campaignStore != nil {
+		campaignStore = service.NewLimitedStore(campaignStore, campaignLimits(cfg.CampaignLimitsConfig), func(err *service.LimitError) {
+			prometheusMetrics.RecordCampaignLimitExceeded(err.Limit, "admin")
+		})
+	}
+
+	// Notify the configured webhooks of campaigns created, paused and resumed through the admin API
+	webhooks := initializeWebhooks(cfg.WebhookConfig, prometheusMetrics, logger)
+	if webhooks != nil && campaignStore != nil {
+		campaignStore = webhook.NewNotifyingStore(campaignStore, webhooks)
+	}
+
+	// Hourly delivery stats for /v1/admin/reports, kept in memory without a database
+	reportAggregator, reportStore, stopReporting := initializeReporting(cfg.ReportingConfig, sourceRepo, logger)
+	defer stopReporting()
 
 	// Service layer with middleware
-	var deliveryService service.CampaignDeliveryService
-	deliveryService = service.NewDeliveryService(cachedRepo)
-	deliveryService = middleware.NewServiceMetricsMiddleware(prometheusMetrics)(deliveryService)
-	deliveryService = middleware.NewLoggingMiddleware(logger)(deliveryService)
+	baseService := service.NewDeliveryService(cachedRepo)
+	baseService.SetMatchRecorder(prometheusMetrics)
 
-	// Endpoint layer (request/response handling)
-	endpoints := endpoint.MakeDeliveryEndpoints(deliveryService)
+	// Bursts of identical requests skip matching, admin cache invalidations also reset the memo
+	var memo *service.MatchMemo
+	if memoConfig := cfg.MatchMemoConfig; memoConfig.Enabled {
+		memo = service.NewMatchMemo(time.Duration(memoConfig.TTL)*time.Millisecond, memoConfig.MaxEntries, prometheusMetrics)
+		baseService.SetMatchMemo(memo)
+	}
 
-	// Transport layer (HTTP) with database and cache health checks
-	httpHandler := transport.NewHTTPHandlerWithCache(endpoints, logger, db, cache)
+	// Large candidate sets are matched on a worker pool shared by all requests
+	if parallelConfig := cfg.ParallelMatchConfig; parallelConfig.Threshold > 0 {
+		workers := parallelConfig.Workers
+		if workers == 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		baseService.SetParallelMatcher(service.NewParallelMatcher(parallelConfig.Threshold, workers))
+	}
 
-	// Add request ID middleware (first in chain to ensure all requests have IDs)
-	requestIDMiddleware := middleware.NewRequestIDMiddleware()
-	httpHandler = requestIDMiddleware.Middleware(httpHandler)
+	// Apps, countries and IP ranges blocked for every campaign, kept in memory without a database
+	trafficBlocklist, stopBlocklist := initializeBlocklist(cfg.BlocklistConfig, sourceRepo, logger)
+	defer stopBlocklist()
+
+	// Campaign and blocklist changes invalidate the cache, snapshot and memo and reload the
+	// blocklist on every replica
+	campaignCache := adminCache(cache, cachedRepo, memo, trafficBlocklist)
+	invalidationsCtx, stopInvalidations := context.WithCancel(context.Background())
+	defer stopInvalidations()
+	go campaignCache.subscribe(invalidationsCtx, logger)
+
+	// Campaign start and end times and delivery budgets, applied by one replica at a time
+	stopScheduler := initializeScheduler(cfg, campaignStore, reportStore, campaignCache, logger)
+	defer stopScheduler()
+
+	// Sampled decision log for offline targeting analysis, optionally shipped to S3
+	if cfg.DecisionLogConfig.Enabled {
+		decisionLog, decisionLogCleanup, err := initializeDecisionLog(cfg.DecisionLogConfig, prometheusMetrics, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to initialize decision log", "err", err)
+			os.Exit(1)
+		}
+		defer decisionLogCleanup()
+		baseService.SetDecisionRecorder(decisionLog)
+		level.Info(logger).Log("msg", "decision log enabled", "path", cfg.DecisionLogConfig.Path, "sample_rate", cfg.DecisionLogConfig.SampleRate)
+	}
+
+	// Event bus exporting delivery, impression and click events to Kafka
+	eventBus, err := initializeEventBus(cfg.EventsConfig, prometheusMetrics)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to initialize event bus", "err", err)
+		os.Exit(1)
+	}
+
+	// Endpoint layer (request/response handling) with the configured middleware chain,
+	// the delivery rate limit is adjustable on config reload
+	endpointConfig := cfg.EndpointConfig
+	deliveryLimiter := endpoint.NewRateLimiter(endpointConfig.RateLimit, endpointConfig.RateLimitBurst)
+	endpointMiddlewares, err := setupEndpointMiddlewares(cfg, deliveryLimiter, prometheusMetrics, logger, logControls)
+	if err != nil {
+		level.Error(logger).Log("msg", "invalid endpoint middleware configuration", "err", err)
+		os.Exit(1)
+	}
+	if eventBus != nil {
+		endpointMiddlewares = append(endpointMiddlewares, endpoint.ServiceMiddleware(middleware.NewEventsMiddleware(eventBus)))
+	}
+	if campaignStats != nil {
+		endpointMiddlewares = append(endpointMiddlewares, endpoint.ServiceMiddleware(middleware.NewCampaignStatsMiddleware(campaignStats)))
+	}
+	if reachEstimator != nil {
+		endpointMiddlewares = append(endpointMiddlewares, endpoint.ServiceMiddleware(middleware.NewReachMiddleware(reachEstimator)))
+	}
+	if reportAggregator != nil {
+		endpointMiddlewares = append(endpointMiddlewares, endpoint.ServiceMiddleware(middleware.NewReportingMiddleware(reportAggregator)))
+	}
+	if quotas != nil {
+		endpointMiddlewares = append(endpointMiddlewares, endpoint.ServiceMiddleware(middleware.NewQuotaUsageMiddleware(quotas)))
+	}
+
+	// Images of campaigns created or changed through the admin API are checked and rewritten to
+	// the CDN, active campaigns are checked again in the background
+	creativeValidator, creativeChecker, stopCreativeChecks := initializeCreativeValidation(cfg.CreativeValidationConfig, cachedRepo, prometheusMetrics, logger)
+	defer stopCreativeChecks()
+
+	// Campaign images served from this origin through a caching proxy, for pages with a strict CSP
+	var creativeProxy *creative.Proxy
+	if creativeConfig := cfg.CreativeProxyConfig; creativeConfig.Enabled {
+		creativeProxy = creative.New(cachedRepo, creative.Config{
+			CacheBytes:       int64(creativeConfig.CacheSize) << 20,
+			MaxCreativeBytes: int64(creativeConfig.MaxSize) << 10,
+			DefaultTTL:       time.Duration(creativeConfig.DefaultTTL) * time.Second,
+			Timeout:          time.Duration(creativeConfig.Timeout) * time.Millisecond,
+		}, prometheusMetrics)
+		endpointMiddlewares = append(endpointMiddlewares, endpoint.ServiceMiddleware(middleware.NewCreativeMiddleware(creativeConfig.BaseURL)))
+		level.Info(logger).Log("msg", "creative proxy enabled", "base_url", creativeConfig.BaseURL)
+	}
+
+	// Alert on fill rate and success rate drops, catching bad campaign pushes early
+	if detector, stopDetector := initializeAnomalyDetection(cfg.AnomalyConfig, prometheusMetrics, logger); detector != nil {
+		defer stopDetector()
+		endpointMiddlewares = append(endpointMiddlewares, endpoint.ServiceMiddleware(middleware.NewAnomalyMiddleware(detector)))
+	}
+	endpoints := endpoint.MakeDeliveryEndpoints(baseService, endpointMiddlewares...)
+
+	// SLO tracking for the delivery endpoint, exported as metrics and via /v1/admin/slo
+	sloConfig := cfg.SLOConfig
+	sloTracker := slo.NewTracker(slo.Config{
+		AvailabilityObjective: sloConfig.AvailabilityObjective,
+		LatencyTarget:         time.Duration(sloConfig.LatencyTarget) * time.Millisecond,
+		LatencyObjective:      sloConfig.LatencyObjective,
+	})
+	prometheus.MustRegister(sloTracker)
+
+	// Health check history with hysteresis so a single failed check doesn't flip overall status
+	healthConfig := cfg.HealthConfig
+	healthHistory := health.NewTracker(health.Config{
+		HistorySize:       healthConfig.HistorySize,
+		FailureThreshold:  healthConfig.FailureThreshold,
+		RecoveryThreshold: healthConfig.RecoveryThreshold,
+		FlapThreshold:     healthConfig.FlapThreshold,
+	})
+
+	// Components checked on /health, a failing critical one makes the server unhealthy while the
+	// others only degrade it
+	healthRegistry := health.NewRegistry(healthHistory)
+	if db != nil {
+		healthRegistry.Register("database", health.Critical, health.DatabaseChecker(db))
+		// Migrations are applied during warm-up, before that the state can't be read
+		healthRegistry.Register("migrations", health.NonCritical, health.MigrationChecker(db))
+	}
+	healthRegistry.Register("cache", health.Critical, health.CacheChecker(campaignCache))
+	healthRegistry.Register("cache_freshness", health.NonCritical, health.FreshnessChecker(cachedRepo.Freshness))
+
+	// Readiness gate, /readyz fails until the warm-up steps completed
+	readinessConfig := cfg.ReadinessConfig
+	warmupSteps := []string{warmupCache}
+	if db != nil {
+		warmupSteps = append(warmupSteps, warmupMigrations)
+	}
+	if readinessConfig.SelfTestDeliveries > 0 {
+		warmupSteps = append(warmupSteps, warmupSelfTest)
+	}
+	readinessGate := readiness.NewGate(warmupSteps...)
+
+	// Impression and click tracking, counted in metrics and optionally logged
+	var trackingRecorder tracking.Recorder
+	if trackingConfig := cfg.TrackingConfig; trackingConfig.Enabled {
+		recorders := tracking.MultiRecorder{tracking.NewCounterRecorder(prometheusMetrics)}
+		if trackingConfig.LogEvents {
+			recorders = append(recorders, tracking.NewLogRecorder(logger))
+		}
+		if eventBus != nil {
+			recorders = append(recorders, events.NewTrackingRecorder(eventBus))
+		}
+		if reportAggregator != nil {
+			recorders = append(recorders, reportAggregator)
+		}
+		trackingRecorder = recorders
+	}
+
+	// Campaign and delivery health in one payload for dashboards, budgets require delivery reports
+	overviewSources := overview.Sources{
+		Campaigns:     campaignStore,
+		DeliveryStats: prometheusMetrics.DeliveryStats,
+		TopNoFill:     prometheusMetrics.TopNoFill,
+		Freshness:     cachedRepo.Freshness,
+	}
+	if reportStore != nil {
+		overviewSources.Deliveries = scheduler.NewReportDeliveries(reportStore)
+	}
+
+	// Transport layer (HTTP) with database and cache health checks and admin endpoints
+	httpHandler := transport.NewHTTPHandlerWithOptions(endpoints, logger, transport.HandlerOptions{
+		DB:                db,
+		Cache:             campaignCache,
+		SLOTracker:        sloTracker,
+		LogControls:       logControls,
+		HealthHistory:     healthHistory,
+		Health:            healthRegistry,
+		Readiness:         readinessGate,
+		Config:            cfg,
+		Tunables:          currentTunables(logControls, cachedRepo, deliveryLimiter),
+		Campaigns:         campaignStore,
+		DeliveryStats:     prometheusMetrics.DeliveryStats,
+		Overview:          overview.NewBuilder(overviewSources),
+		CampaignStats:     campaignStats,
+		Reach:             reachEstimator,
+		Reports:           reportStore,
+		Quotas:            quotas,
+		Blocklist:         trafficBlocklist,
+		Tracking:          trackingRecorder,
+		ClickRedirects:    tracking.NewRedirectPolicy(cfg.TrackingConfig.ClickAllowedHosts),
+		Creatives:         creativeProxy,
+		CreativeValidator: creativeValidator,
+		CreativeChecks:    creativeChecker,
+	})
+
+	// Replay stored responses to admin mutations retried with the same Idempotency-Key
+	idempotencyMiddleware := middleware.NewIdempotencyMiddleware(middleware.IdempotencyConfig{
+		TTL:        time.Duration(cfg.IdempotencyConfig.TTL) * time.Hour,
+		PathPrefix: middleware.AdminPathPrefix,
+		MaxEntries: cfg.IdempotencyConfig.MaxEntries,
+	})
+	httpHandler = idempotencyMiddleware.Middleware(httpHandler)
+
+	// Require the admin token on the admin API (outside idempotency so unauthenticated requests store nothing)
+	adminAuthMiddleware := middleware.NewAdminAuthMiddleware(cfg.AdminConfig.Token)
+	httpHandler = adminAuthMiddleware.Middleware(httpHandler)
+	if cfg.AdminConfig.Token == "" {
+		level.Warn(logger).Log("msg", "admin API disabled, ADMIN_TOKEN isn't set")
+	}
+
+	// Report 5xx responses and panics with request context
+	errorReportingMiddleware := middleware.NewErrorReportingMiddleware(reporter)
+	httpHandler = errorReportingMiddleware.Middleware(httpHandler)
+
+	// Shed a fraction of delivery requests while latency or CPU is above threshold
+	if loadShedConfig := cfg.LoadShedConfig; loadShedConfig.Enabled {
+		shedder := loadshed.New(loadshed.Config{
+			Interval:         time.Duration(loadShedConfig.Interval) * time.Millisecond,
+			LatencyThreshold: time.Duration(loadShedConfig.LatencyThreshold) * time.Millisecond,
+			CPUThreshold:     loadShedConfig.CPUThreshold,
+			MaxShedFraction:  loadShedConfig.MaxShedFraction,
+		})
+		prometheus.MustRegister(shedder)
+		shedderCtx, stopShedder := context.WithCancel(context.Background())
+		defer stopShedder()
+		go shedder.Run(shedderCtx)
+
+		loadShedMiddleware := middleware.NewLoadShedMiddleware(shedder, middleware.LoadShedConfig{
+			RetryAfter: time.Duration(cfg.EndpointConfig.RetryAfter) * time.Second,
+			Endpoints:  []string{"/v1/delivery"},
+		}, prometheusMetrics)
+		httpHandler = loadShedMiddleware.Middleware(httpHandler)
+		level.Info(logger).Log("msg", "load shedding enabled",
+			"latency_threshold_ms", loadShedConfig.LatencyThreshold, "cpu_threshold", loadShedConfig.CPUThreshold)
+	}
+
+	// Reject delivery requests beyond the in-flight limit (inside metrics so they are counted as 503s)
+	if endpointConfig := cfg.EndpointConfig; endpointConfig.DeliveryMaxInFlight > 0 {
+		concurrencyLimitMiddleware := middleware.NewConcurrencyLimitMiddleware(middleware.ConcurrencyLimitConfig{
+			MaxInFlight: endpointConfig.DeliveryMaxInFlight,
+			RetryAfter:  time.Duration(endpointConfig.RetryAfter) * time.Second,
+			Endpoints:   []string{"/v1/delivery"},
+		}, prometheusMetrics)
+		httpHandler = concurrencyLimitMiddleware.Middleware(httpHandler)
+	}
+
+	// Reject delivery requests of API keys over their monthly quota (inside metrics so they are counted as 429s)
+	if quotas != nil {
+		quotaMiddleware := middleware.NewQuotaMiddleware(quotas, prometheusMetrics)
+		httpHandler = quotaMiddleware.Middleware(httpHandler)
+	}
+
+	// Replay the first response to delivery requests repeating a nonce, so SDK retries aren't counted twice
+	if cfg.DedupConfig.Enabled {
+		dedupMiddleware, closeDedup := initializeDeduplication(cfg, prometheusMetrics, logger)
+		defer closeDedup()
+		httpHandler = dedupMiddleware.Middleware(httpHandler)
+	}
+
+	// Signing secrets by API key ID, validated with the configuration
+	signingSecrets, _ := signing.ParseSecrets(cfg.SigningConfig.Secrets)
+
+	// Reject delivery and tracking calls of API keys not signed with their signing secret or
+	// replayed after the allowed clock skew
+	if replayConfig := cfg.ReplayProtectionConfig; replayConfig.Enabled {
+		replayMiddleware := middleware.NewReplayProtectionMiddleware(middleware.ReplayProtectionConfig{
+			MaxSkew:   time.Duration(replayConfig.MaxSkew) * time.Second,
+			Endpoints: []string{"/v1/delivery", "/v1/track/impression", "/v1/track/click"},
+			Secrets:   signingSecrets,
+		}, prometheusMetrics)
+		httpHandler = replayMiddleware.Middleware(httpHandler)
+		if !cfg.DedupConfig.Enabled || cfg.DedupConfig.Window < replayConfig.MaxSkew {
+			level.Warn(logger).Log("msg", "delivery calls repeated within the allowed clock skew aren't all deduplicated, DEDUP_WINDOW_SECONDS should be at least REPLAY_MAX_SKEW_SECONDS",
+				"dedup_enabled", cfg.DedupConfig.Enabled, "dedup_window_seconds", cfg.DedupConfig.Window, "max_skew_seconds", replayConfig.MaxSkew)
+		}
+	}
+
+	// Client IPs the blocklist, invalid traffic filters and access log see are only taken from X-Forwarded-For behind
+	// trusted proxies, any client could forge it otherwise
+	trustedProxies, err := fraud.ParsePrefixes(cfg.GeneralConfig.TrustedProxies)
+	if err != nil {
+		level.Error(logger).Log("msg", "invalid trusted proxies", "err", err)
+		os.Exit(1)
+	}
+
+	// Answer requests from blocklisted apps, countries and IP ranges without matching campaigns
+	if trafficBlocklist != nil {
+		blocklistMiddleware := middleware.NewBlocklistMiddleware(trafficBlocklist, trustedProxies, prometheusMetrics, logger)
+		httpHandler = blocklistMiddleware.Middleware(httpHandler)
+	}
+
+	// Answer invalid traffic (datacenters, bots, impossible carriers) without delivering campaigns
+	if fraudConfig := cfg.FraudConfig; fraudConfig.Enabled {
+		chain, err := initializeFraudFilters(fraudConfig, prometheusMetrics)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to initialize invalid traffic filters", "err", err)
+			os.Exit(1)
+		}
+		fraudFilterMiddleware := middleware.NewFraudFilterMiddleware(chain, trustedProxies, logger, fraudConfig.DryRun)
+		httpHandler = fraudFilterMiddleware.Middleware(httpHandler)
+		level.Info(logger).Log("msg", "invalid traffic filtering enabled", "filters", strings.Join(fraudConfig.Filters, ","), "dry_run", fraudConfig.DryRun)
+	}
+
+	// Reject oversized bodies, query strings and headers (inside metrics so they are counted)
+	requestLimits := cfg.RequestLimitsConfig
+	sizeLimitMiddleware := middleware.NewSizeLimitMiddleware(middleware.SizeLimitConfig{
+		MaxBodyBytes:   requestLimits.MaxBodyBytes,
+		MaxQueryParams: requestLimits.MaxQueryParams,
+		MaxHeaderBytes: requestLimits.MaxHeaderBytes,
+	}, prometheusMetrics)
+	httpHandler = sizeLimitMiddleware.Middleware(httpHandler)
+
+	// Sign delivery responses to API keys with a signing secret, replayed and rejected ones included
+	if len(signingSecrets) > 0 {
+		signingMiddleware := middleware.NewSigningMiddleware(signingSecrets)
+		httpHandler = signingMiddleware.Middleware(httpHandler)
+		level.Info(logger).Log("msg", "delivery response signing enabled", "api_keys", len(signingSecrets))
+	}
+
+	// Let CDN edges cache matched delivery responses, rejected and blocked ones are never cached
+	if cacheConfig := cfg.DeliveryCacheConfig; cacheConfig.Enabled {
+		deliveryCacheMiddleware := middleware.NewDeliveryCacheMiddleware(middleware.DeliveryCacheConfig{
+			MaxAge: time.Duration(cacheConfig.MaxAge) * time.Second,
+			Vary:   fraudVaryHeaders(cfg.FraudConfig),
+		})
+		httpHandler = deliveryCacheMiddleware.Middleware(httpHandler)
+		level.Info(logger).Log("msg", "delivery cache headers enabled", "max_age_seconds", cacheConfig.MaxAge)
+	}
 
 	// Add metrics middleware to HTTP handler
-	metricsMiddleware := middleware.NewMetricsMiddleware(prometheusMetrics)
+	// Optionally attribute requests to publishers or API keys for per-customer metrics
+	metricsMiddleware := middleware.NewMetricsMiddlewareWithClientLabel(prometheusMetrics, cfg.MetricsConfig.ClientLabel)
 	httpHandler = metricsMiddleware.Middleware(httpHandler)
 
+	// Record delivery outcomes for SLO tracking
+	sloMiddleware := middleware.NewSLOMiddleware(sloTracker)
+	httpHandler = sloMiddleware.Middleware(httpHandler)
+
+	// Add access log middleware
+	if accessLogConfig := cfg.AccessLogConfig; accessLogConfig.Enabled {
+		accessLogMiddleware := middleware.NewAccessLogMiddleware(logger, middleware.AccessLogConfig{
+			ExcludePaths:   accessLogConfig.ExcludePaths,
+			Controls:       logControls,
+			TrustedProxies: trustedProxies,
+		})
+		httpHandler = accessLogMiddleware.Middleware(httpHandler)
+	}
+
+	// Evaluate GDPR consent before anything logs or targets the user
+	if consentConfig := cfg.ConsentConfig; consentConfig.Enabled {
+		consentMiddleware := middleware.NewConsentMiddleware(consentConfig.VendorID, prometheusMetrics)
+		httpHandler = consentMiddleware.Middleware(httpHandler)
+	}
+
+	// Recover panics into clean 500 responses, outside every middleware that could panic itself
+	// (signing's buffered writer included), they are counted by the panic counter
+	recoveryMiddleware := middleware.NewRecoveryMiddleware(logger, prometheusMetrics)
+	httpHandler = recoveryMiddleware.Middleware(httpHandler)
+
+	// Add request ID middleware (outermost so metrics see request and trace IDs)
+	requestIDMiddleware := middleware.NewRequestIDMiddleware()
+	httpHandler = requestIDMiddleware.Middleware(httpHandler)
+
+	// Track in-flight requests so shutdown can drain them
+	drainMiddleware := middleware.NewDrainMiddleware(logger, prometheusMetrics)
+	httpHandler = drainMiddleware.Middleware(httpHandler)
+
 	// Add Prometheus metrics endpoint
-	http.Handle("/metrics", promhttp.Handler())
+	// OpenMetrics is required for exemplars to be exposed
+	http.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	))
 	http.Handle("/", httpHandler)
 
 	// HTTP server configuration
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", config.AppConfigInstance.GeneralConfig.Port),
+		Addr:         fmt.Sprintf(":%d", cfg.GeneralConfig.Port),
 		Handler:      nil, // Using default ServeMux
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
@@ -115,38 +626,246 @@ func main() {
 
 	// Start server in a goroutine so that it doesn't block the main thread
 	go func() {
-		log.Printf("AdBeacon server starting on port %d", config.AppConfigInstance.GeneralConfig.Port)
-		log.Println("Available endpoints:")
-		log.Println("   GET /v1/delivery - Campaign delivery endpoint")
-		log.Println("   GET /health      - Health check endpoint")
-		log.Println("   GET /metrics     - Prometheus metrics endpoint")
+		build := version.Get()
+		level.Info(logger).Log(
+			"msg", "adbeacon server starting",
+			"port", cfg.GeneralConfig.Port,
+			"commit", build.Commit,
+			"build_date", build.BuildDate,
+			"endpoints", "GET /v1/delivery,GET /health,GET /version,GET /metrics",
+		)
 
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			level.Error(logger).Log("msg", "failed to start server", "err", err)
+			os.Exit(1)
 		}
 	}()
 
+	// Warm up in the background while /readyz keeps load balancers away
+	warmupCtx, stopWarmup := context.WithCancel(context.Background())
+	defer stopWarmup()
+	go warmup(warmupCtx, cfg, readinessGate, db, cachedRepo, baseService, logger)
+
+	// Reload tunables on SIGHUP or when the config file changes
+	reloadConfig := cfg.ReloadConfig
+	reloader := reload.New(reloadConfig.File, cfg.Tunables(), applyTunables(logControls, cachedRepo, deliveryLimiter), logger)
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	go reloader.Run(reloadCtx, time.Duration(reloadConfig.PollInterval)*time.Second)
+
+	// Internal diagnostics listener, kept off the public port
+	var debugSrv *http.Server
+	if cfg.GeneralConfig.DebugEnabled {
+		debugSrv = &http.Server{
+			Addr:        fmt.Sprintf(":%d", cfg.GeneralConfig.DebugPort),
+			Handler:     transport.NewDebugHandler(),
+			ReadTimeout: 30 * time.Second,
+			IdleTimeout: 120 * time.Second,
+			// No WriteTimeout: CPU profiles and traces stream for the requested duration
+		}
+
+		go func() {
+			level.Info(logger).Log(
+				"msg", "debug server starting",
+				"port", cfg.GeneralConfig.DebugPort,
+				"endpoints", "GET /debug/pprof/,GET /debug/vars,GET /debug/buildinfo",
+			)
+
+			if err := debugSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				level.Error(logger).Log("msg", "debug server failed", "err", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shut down the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	level.Info(logger).Log("msg", "shutting down server")
+	stopWarmup()
+	stopReload()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+	if debugSrv != nil {
+		if err := debugSrv.Shutdown(ctx); err != nil {
+			level.Warn(logger).Log("msg", "debug server forced to shutdown", "err", err)
+		}
+	}
+
+	// Stop accepting connections and wait for in-flight requests, reporting how many remain
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- srv.Shutdown(ctx)
+	}()
+	if err := drainMiddleware.Drain(ctx); err != nil {
+		level.Warn(logger).Log("msg", "requests still in flight at shutdown deadline", "remaining", drainMiddleware.InFlight())
+	}
+
+	if err := <-shutdownErr; err != nil {
+		level.Warn(logger).Log("msg", "server forced to shutdown", "err", err)
 	} else {
-		log.Println("Server exited gracefully")
+		level.Info(logger).Log("msg", "server exited gracefully")
+	}
+
+	// Write the last counted deliveries
+	if campaignStats != nil {
+		if err := campaignStats.Flush(ctx); err != nil {
+			level.Warn(logger).Log("msg", "campaign stats not flushed", "err", err)
+		}
+	}
+	if reachEstimator != nil {
+		if err := reachEstimator.Flush(ctx); err != nil {
+			level.Warn(logger).Log("msg", "reach histograms not flushed", "err", err)
+		}
+	}
+	if quotas != nil {
+		if err := quotas.Flush(ctx); err != nil {
+			level.Warn(logger).Log("msg", "quota usage not flushed", "err", err)
+		}
+	}
+	if reportAggregator != nil {
+		if err := reportAggregator.Flush(ctx); err != nil {
+			level.Warn(logger).Log("msg", "delivery stats not flushed", "err", err)
+		}
+	}
+
+	// Export queued events, whatever is left at the deadline is lost
+	if eventBus != nil {
+		if err := eventBus.Close(ctx); err != nil {
+			level.Warn(logger).Log("msg", "queued events abandoned", "remaining", eventBus.Len(), "err", err)
+		}
+	}
+
+	// Deliver queued webhooks, whatever is left at the deadline is lost
+	if webhooks != nil {
+		if err := webhooks.Close(ctx); err != nil {
+			level.Warn(logger).Log("msg", "queued webhooks abandoned", "remaining", webhooks.Len(), "err", err)
+		}
+	}
+
+	// Finish pending cache writes, the cache and database are closed by the deferred cleanups
+	if err := cachedRepo.Flush(ctx); err != nil {
+		level.Warn(logger).Log("msg", "pending cache writes abandoned", "err", err)
 	}
 }
 
-// Add cache initialization example
-func initializeCache() (*cache.HybridCache, error) {
-	cacheConfig := config.GetCacheConfig()
+// applyTunables returns the reload hook applying reloaded tunables to the running components,
+// invalid values are rejected before anything is changed
+func applyTunables(logControls *logger.Controls, cachedRepo *cache.CachedRepository, deliveryLimiter *endpoint.RateLimiter) reload.ApplyFunc {
+	return func(tunables config.Tunables) error {
+		if _, err := logger.ParseLevel(tunables.LogLevel); err != nil {
+			return err
+		}
+		if tunables.CacheTTL <= 0 {
+			return fmt.Errorf("cache TTL must be positive")
+		}
+		if err := logControls.SetSampleRate(tunables.AccessLogSampleRate); err != nil {
+			return err
+		}
+
+		logControls.SetLevel(tunables.LogLevel)
+		cachedRepo.SetTTL(tunables.CacheTTL)
+		deliveryLimiter.SetLimit(tunables.RateLimit, tunables.RateLimitBurst)
+		return nil
+	}
+}
+
+// currentTunables returns a func reporting the tunables currently in effect on the running components
+func currentTunables(logControls *logger.Controls, cachedRepo *cache.CachedRepository, deliveryLimiter *endpoint.RateLimiter) func() config.Tunables {
+	return func() config.Tunables {
+		rate, burst := deliveryLimiter.Limit()
+		return config.Tunables{
+			LogLevel:            logControls.Level(),
+			AccessLogSampleRate: logControls.SampleRate(),
+			CacheTTL:            cachedRepo.TTL(),
+			RateLimit:           rate,
+			RateLimitBurst:      burst,
+		}
+	}
+}
+
+// Warm-up steps of the readiness gate
+const (
+	warmupMigrations = "migrations"
+	warmupCache      = "cache"
+	warmupSelfTest   = "self_test"
+)
+
+// warmup confirms migrations, warms the cache and runs self-test deliveries, marking
+// each readiness step done once it succeeds
+func warmup(ctx context.Context, cfg *config.Config, gate *readiness.Gate, db *database.DB, cachedRepo *cache.CachedRepository, deliveryService service.CampaignDeliveryService, logger kitlog.Logger) {
+	readinessConfig := cfg.ReadinessConfig
+	retryInterval := time.Duration(readinessConfig.RetryInterval) * time.Second
+
+	steps := []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{warmupMigrations, func(ctx context.Context) error {
+			return database.ConfirmMigrations(db, cfg.DatabaseConfig, "./migrations", logger)
+		}},
+		{warmupCache, func(ctx context.Context) error {
+			if err := cachedRepo.RefreshSnapshot(ctx); err != nil {
+				return err
+			}
+			return cachedRepo.Flush(ctx)
+		}},
+		{warmupSelfTest, func(ctx context.Context) error {
+			// Exercise matching on the base service so self-tests don't show up in delivery metrics
+			req := models.DeliveryRequest{
+				Country: readinessConfig.SelfTestCountry,
+				OS:      readinessConfig.SelfTestOS,
+				App:     readinessConfig.SelfTestApp,
+			}
+			for i := 0; i < readinessConfig.SelfTestDeliveries; i++ {
+				if _, err := deliveryService.GetCampaigns(ctx, req); err != nil {
+					return fmt.Errorf("self-test delivery %d: %w", i+1, err)
+				}
+			}
+			return nil
+		}},
+	}
 
+	for _, step := range steps {
+		if step.name == warmupMigrations && db == nil {
+			continue
+		}
+		if step.name == warmupSelfTest && readinessConfig.SelfTestDeliveries <= 0 {
+			continue
+		}
+		if err := gate.Run(ctx, step.name, retryInterval, step.fn); err != nil {
+			return
+		}
+		level.Info(logger).Log("msg", "warm-up step completed", "step", step.name)
+	}
+	level.Info(logger).Log("msg", "ready to serve traffic")
+}
+
+// initializeErrorReporter creates a Sentry reporter when SENTRY_DSN is set, otherwise a no-op reporter
+func initializeErrorReporter(errorReportingConfig config.ErrorReportingConfig, logger kitlog.Logger) errorreporter.Reporter {
+	if errorReportingConfig.SentryDSN == "" {
+		return errorreporter.NewNopReporter()
+	}
+
+	reporter, err := errorreporter.NewSentryReporter(errorreporter.SentryConfig{
+		DSN:         errorReportingConfig.SentryDSN,
+		Environment: errorReportingConfig.SentryEnvironment,
+		Release:     version.Version,
+	})
+	if err != nil {
+		level.Warn(logger).Log("msg", "error reporting disabled", "err", err)
+		return errorreporter.NewNopReporter()
+	}
+
+	level.Info(logger).Log("msg", "sentry error reporting enabled", "environment", errorReportingConfig.SentryEnvironment)
+	return reporter
+}
+
+// Add cache initialization example
+func initializeCache(cacheConfig cache.CacheConfig) (*cache.HybridCache, error) {
 	hybridCache, err := cache.NewHybridCache(cacheConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize cache: %w", err)
@@ -156,15 +875,575 @@ func initializeCache() (*cache.HybridCache, error) {
 }
 
 // Add this to show how to wire up cached repository
-func setupCachedRepository(db *database.DB, hybridCache *cache.HybridCache, prometheusMetrics *metrics.CachedMetrics) service.CampaignRepository {
-	// Original repository
-	baseRepo := repository.NewPostgresRepository(db)
+func setupCachedRepository(cfg *config.Config, sourceRepo service.CampaignRepository, hybridCache *cache.HybridCache, prometheusMetrics *metrics.CachedMetrics, logger kitlog.Logger, reporter errorreporter.Reporter) *cache.CachedRepository {
+	// Report failures of the original repository to the error reporter
+	baseRepo := repository.NewErrorReportingRepository(sourceRepo, reporter)
+
+	// Wrap with instrumentation (reuse existing metrics instance) and slow query logging
+	slowQueryThreshold := time.Duration(cfg.DatabaseConfig.SlowQueryThreshold) * time.Millisecond
+	instrumentedRepo := repository.NewInstrumentedRepositoryWithLogger(baseRepo, prometheusMetrics, logger, slowQueryThreshold)
 
-	// Wrap with instrumentation (reuse existing metrics instance)
-	instrumentedRepo := repository.NewInstrumentedRepository(baseRepo, prometheusMetrics)
+	// Hedge slow reads with a second attempt, the first answer wins
+	hedgeDelay := time.Duration(cfg.DatabaseConfig.HedgeDelay) * time.Millisecond
+	hedgedRepo := repository.NewHedgedRepository(instrumentedRepo, hedgeDelay, prometheusMetrics)
+
+	// Retry transient read failures, each attempt is instrumented separately
+	retryConfig := cfg.RetryConfig
+	retryingRepo := repository.NewRetryRepository(hedgedRepo, repository.RetryConfig{
+		MaxAttempts: retryConfig.MaxAttempts,
+		BaseDelay:   time.Duration(retryConfig.BaseDelay) * time.Millisecond,
+		MaxDelay:    time.Duration(retryConfig.MaxDelay) * time.Millisecond,
+	}, prometheusMetrics)
+
+	// Fail fast while the database keeps failing instead of piling up timeouts
+	var guardedRepo service.CampaignRepository = retryingRepo
+	if cfg.CircuitBreakerConfig.Enabled {
+		dbBreaker := newCircuitBreaker("database", cfg.CircuitBreakerConfig, nil, prometheusMetrics, logger)
+		guardedRepo = repository.NewCircuitBreakerRepository(retryingRepo, dbBreaker)
+	}
+
+	// Wrap with caching (CACHE_DEFAULT_TTL, 5 minutes by default), serving the last known campaigns when the database fails
+	cachedRepo := cache.NewCachedRepositoryWithStaleHandler(guardedRepo, hybridCache, cfg.CacheConfig.DefaultTTL, func(err error) {
+		prometheusMetrics.RecordStaleCampaignsServed()
+	})
+	// Load large campaign sets in batches (CACHE_LOAD_BATCH_SIZE) instead of one result set
+	cachedRepo.SetLoadBatchSize(cfg.CacheConfig.LoadBatchSize)
 
-	// Wrap with caching (5-minute TTL)
-	cachedRepo := cache.NewCachedRepository(instrumentedRepo, hybridCache, 5*time.Minute)
+	// Leave campaigns exceeding the campaign limits out of the cache, keeping delivery latency bounded
+	cachedRepo.SetLimits(campaignLimits(cfg.CampaignLimitsConfig), func(err *service.LimitError) {
+		prometheusMetrics.RecordCampaignLimitExceeded(err.Limit, "load")
+		level.Warn(logger).Log("msg", "campaigns left out of the cache", "err", err)
+	})
 
 	return cachedRepo
 }
+
+// campaignLimits returns the campaign limits of the configuration
+func campaignLimits(limitsConfig config.CampaignLimitsConfig) service.CampaignLimits {
+	return service.CampaignLimits{
+		MaxActiveCampaigns:  limitsConfig.MaxActive,
+		MaxRulesPerCampaign: limitsConfig.MaxRules,
+		MaxValuesPerRule:    limitsConfig.MaxRuleValues,
+	}
+}
+
+// newCircuitBreaker creates a circuit breaker from the circuit breaker configuration that
+// logs and exports its state changes, a nil isFailure counts every non-cancellation error
+func newCircuitBreaker(name string, breakerConfig config.CircuitBreakerConfig, isFailure func(error) bool, prometheusMetrics *metrics.CachedMetrics, logger kitlog.Logger) *breaker.CircuitBreaker {
+	cb := breaker.New(breaker.Settings{
+		Name:                name,
+		FailureThreshold:    breakerConfig.FailureThreshold,
+		OpenTimeout:         time.Duration(breakerConfig.OpenTimeout) * time.Second,
+		HalfOpenMaxRequests: breakerConfig.HalfOpenMaxRequests,
+		IsFailure:           isFailure,
+		OnStateChange: func(name string, from, to breaker.State) {
+			level.Warn(logger).Log("msg", "circuit breaker state changed", "breaker", name, "from", from, "to", to)
+			prometheusMetrics.SetCircuitBreakerState(name, int(to))
+		},
+	})
+	prometheusMetrics.SetCircuitBreakerState(cb.Name(), int(breaker.StateClosed))
+	return cb
+}
+
+// initializeEventBus creates the event bus exporting to the configured Kafka REST Proxy,
+// or nil when event export is disabled
+func initializeEventBus(eventsConfig config.EventsConfig, prometheusMetrics *metrics.CachedMetrics) (*events.Bus, error) {
+	if !eventsConfig.Enabled {
+		return nil, nil
+	}
+
+	exporter, err := events.NewKafkaRESTExporter(eventsConfig.KafkaRESTURL, eventsConfig.KafkaTopic, &http.Client{})
+	if err != nil {
+		return nil, err
+	}
+	return events.NewBus(events.Config{
+		QueueSize:     eventsConfig.QueueSize,
+		BatchSize:     eventsConfig.BatchSize,
+		FlushInterval: time.Duration(eventsConfig.FlushInterval) * time.Millisecond,
+		ExportTimeout: time.Duration(eventsConfig.ExportTimeout) * time.Millisecond,
+		MaxAttempts:   eventsConfig.MaxAttempts,
+		RetryDelay:    time.Duration(eventsConfig.RetryDelay) * time.Millisecond,
+	}, exporter, prometheusMetrics), nil
+}
+
+// adminCache returns the cache invalidated by the admin API and the scheduler, resetting the
+// campaign snapshot and memo along with it
+func adminCache(hybridCache *cache.HybridCache, cachedRepo *cache.CachedRepository, memo *service.MatchMemo, list *blocklist.Blocklist) resettingCache {
+	return resettingCache{Cache: hybridCache, hybridCache: hybridCache, cachedRepo: cachedRepo, memo: memo, blocklist: list}
+}
+
+// resettingCache resets the campaign snapshot and forgets the remembered matches whenever the
+// campaign cache is invalidated, so campaign changes made through the admin API aren't served
+// stale from either once the snapshot is rebuilt. Invalidations are published to the other replicas, see subscribe.
+type resettingCache struct {
+	cache.Cache
+	hybridCache *cache.HybridCache
+	cachedRepo  *cache.CachedRepository
+	memo        *service.MatchMemo
+	// blocklist is reloaded when another replica invalidates, nil when disabled
+	blocklist *blocklist.Blocklist
+}
+
+func (c resettingCache) InvalidateAll(ctx context.Context) error {
+	err := c.Cache.InvalidateAll(ctx)
+	c.reset()
+	return errors.Join(err, c.hybridCache.PublishInvalidation(ctx))
+}
+
+// reset resets the campaign snapshot and the remembered matches
+func (c resettingCache) reset() {
+	c.cachedRepo.ResetSnapshot()
+	if c.memo != nil {
+		c.memo.Reset()
+	}
+}
+
+// subscribe resets the memory cache, snapshot and memo and reloads the blocklist whenever another
+// replica invalidates the campaigns, until ctx is done
+func (c resettingCache) subscribe(ctx context.Context, logger kitlog.Logger) {
+	onInvalidate := func() {
+		c.reset()
+		if c.blocklist != nil {
+			if err := c.blocklist.Reload(ctx); err != nil {
+				level.Warn(logger).Log("msg", "blocklist reload failed", "err", err)
+			}
+		}
+	}
+	if err := c.hybridCache.SubscribeInvalidations(ctx, onInvalidate); err != nil && ctx.Err() == nil {
+		level.Warn(logger).Log("msg", "cache invalidation subscription ended", "err", err)
+	}
+}
+
+// initializeFraudFilters creates the chain of configured invalid traffic filters
+func initializeFraudFilters(fraudConfig config.FraudConfig, prometheusMetrics *metrics.CachedMetrics) (*fraud.Chain, error) {
+	var filters []fraud.Filter
+	for _, name := range fraudConfig.Filters {
+		switch name {
+		case fraud.FilterDatacenter:
+			prefixes, err := fraud.ParsePrefixes(fraudConfig.DatacenterCIDRs)
+			if err != nil {
+				return nil, err
+			}
+			if fraudConfig.DatacenterCIDRsFile != "" {
+				listed, err := fraud.LoadPrefixFile(fraudConfig.DatacenterCIDRsFile)
+				if err != nil {
+					return nil, err
+				}
+				prefixes = append(prefixes, listed...)
+			}
+			if len(prefixes) == 0 {
+				continue
+			}
+			filters = append(filters, fraud.NewDatacenterFilter(prefixes))
+		case fraud.FilterBotAgent:
+			filters = append(filters, fraud.NewBotAgentFilter(fraudConfig.BotAgents))
+		case fraud.FilterGeoCarrier:
+			countries, err := fraud.ParseCarrierCountries(fraudConfig.CarrierCountries)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, fraud.NewGeoCarrierFilter(countries))
+		default:
+			return nil, fmt.Errorf("unknown invalid traffic filter %q", name)
+		}
+	}
+	return fraud.NewChain(prometheusMetrics, filters...), nil
+}
+
+// fraudVaryHeaders returns the request headers the invalid traffic filters answer by, delivery
+// responses vary by them. Filters in dry run don't change responses.
+func fraudVaryHeaders(fraudConfig config.FraudConfig) []string {
+	if !fraudConfig.Enabled || fraudConfig.DryRun {
+		return nil
+	}
+	var headers []string
+	for _, name := range fraudConfig.Filters {
+		switch name {
+		case fraud.FilterBotAgent:
+			headers = append(headers, "User-Agent")
+		case fraud.FilterGeoCarrier:
+			headers = append(headers, middleware.CarrierHeader)
+		}
+	}
+	return headers
+}
+
+// initializeAnomalyDetection creates the delivery anomaly detector and starts checking it, or returns
+// nil when detection is disabled. Alerts are logged and exported as metrics, and POSTed to the alert
+// webhook when one is configured. The returned cleanup stops checking.
+func initializeAnomalyDetection(anomalyConfig config.AnomalyConfig, prometheusMetrics *metrics.CachedMetrics, logger kitlog.Logger) (*anomaly.Detector, func()) {
+	if !anomalyConfig.Enabled {
+		return nil, func() {}
+	}
+
+	notifiers := []anomaly.Notifier{anomaly.NewLogNotifier(logger), anomaly.NewMetricNotifier(prometheusMetrics)}
+	if anomalyConfig.WebhookURL != "" {
+		notifiers = append(notifiers, anomaly.NewWebhookNotifier(anomalyConfig.WebhookURL, &http.Client{}, 5*time.Second))
+	}
+	detector := anomaly.NewDetector(anomaly.Config{
+		Window:          time.Duration(anomalyConfig.Window) * time.Second,
+		Baseline:        time.Duration(anomalyConfig.Baseline) * time.Second,
+		MinRequests:     int64(anomalyConfig.MinRequests),
+		FillRateDrop:    anomalyConfig.FillRateDrop,
+		SuccessRateDrop: anomalyConfig.SuccessRateDrop,
+	}, notifiers...)
+
+	ctx, stop := context.WithCancel(context.Background())
+	go detector.Run(ctx, time.Duration(anomalyConfig.CheckInterval)*time.Second, func(err error) {
+		level.Warn(logger).Log("msg", "anomaly alert not delivered", "err", err)
+	})
+	return detector, stop
+}
+
+// initializeWebhooks creates the campaign webhook dispatcher, or returns nil without webhook URLs
+func initializeWebhooks(webhookConfig config.WebhookConfig, prometheusMetrics *metrics.CachedMetrics, logger kitlog.Logger) *webhook.Dispatcher {
+	if len(webhookConfig.URLs) == 0 {
+		return nil
+	}
+
+	level.Info(logger).Log("msg", "campaign webhooks enabled", "urls", len(webhookConfig.URLs), "signed", webhookConfig.Secret != "")
+	return webhook.NewDispatcher(webhook.Config{
+		URLs:        webhookConfig.URLs,
+		Secret:      webhookConfig.Secret,
+		QueueSize:   webhookConfig.QueueSize,
+		Timeout:     time.Duration(webhookConfig.Timeout) * time.Millisecond,
+		MaxAttempts: webhookConfig.MaxAttempts,
+		RetryDelay:  time.Duration(webhookConfig.RetryDelay) * time.Millisecond,
+	}, &http.Client{}, logger, prometheusMetrics)
+}
+
+// initializeCampaignStats creates the campaign stats tracker and starts flushing it, or returns nil
+// when the stats are disabled or Redis isn't. The returned cleanup stops flushing and closes the
+// Redis connection, flush once more before calling it.
+func initializeCampaignStats(cfg *config.Config, logger kitlog.Logger) (*campaignstats.Tracker, func()) {
+	statsConfig := cfg.CampaignStatsConfig
+	if !statsConfig.Enabled {
+		return nil, func() {}
+	}
+	if !cfg.CacheConfig.EnableRedis {
+		level.Warn(logger).Log("msg", "campaign stats disabled, they require CACHE_ENABLE_REDIS")
+		return nil, func() {}
+	}
+
+	client := cache.NewRedisClient(cfg.CacheConfig)
+	tracker := campaignstats.NewTracker(client, cfg.CacheConfig.RedisKeyPrefix())
+	ctx, stop := context.WithCancel(context.Background())
+	go tracker.Run(ctx, time.Duration(statsConfig.FlushInterval)*time.Millisecond, func(err error) {
+		level.Warn(logger).Log("msg", "campaign stats flush failed, retrying", "err", err)
+	})
+
+	return tracker, func() {
+		stop()
+		client.Close()
+	}
+}
+
+// initializeSnapshotPersistence loads the campaigns persisted by the last run, when they're still
+// current, and starts persisting the active campaigns periodically. The returned cleanup stops
+// persisting and persists the campaigns once more.
+func initializeSnapshotPersistence(cacheConfig cache.CacheConfig, cachedRepo *cache.CachedRepository, hybridCache *cache.HybridCache, logger kitlog.Logger) func() {
+	if cacheConfig.PersistPath == "" {
+		return func() {}
+	}
+
+	persister := cache.NewSnapshotPersister(cachedRepo, hybridCache, cacheConfig.PersistPath, cacheConfig.PersistMaxAge)
+	loadCtx, cancelLoad := context.WithTimeout(context.Background(), 10*time.Second)
+	loaded, err := persister.Load(loadCtx)
+	cancelLoad()
+	if err != nil {
+		level.Warn(logger).Log("msg", "persisted campaigns not loaded", "path", cacheConfig.PersistPath, "err", err)
+	} else {
+		level.Info(logger).Log("msg", "persisted campaigns loaded", "path", cacheConfig.PersistPath, "campaigns", loaded)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	go persister.Run(ctx, cacheConfig.PersistInterval, func(err error) {
+		level.Warn(logger).Log("msg", "campaign persistence failed", "err", err)
+	})
+
+	return func() {
+		stop()
+		saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := persister.Save(saveCtx); err != nil {
+			level.Warn(logger).Log("msg", "campaign persistence failed", "err", err)
+		}
+	}
+}
+
+// initializeReach creates the reach estimator and starts flushing its histograms, or returns nil
+// when reach estimation is disabled or Redis isn't. The returned cleanup stops flushing and closes
+// the Redis connection, flush once more before calling it.
+func initializeReach(cfg *config.Config, logger kitlog.Logger) (*reach.Estimator, func()) {
+	reachConfig := cfg.ReachConfig
+	if !reachConfig.Enabled {
+		return nil, func() {}
+	}
+	if !cfg.CacheConfig.EnableRedis {
+		level.Warn(logger).Log("msg", "reach estimation disabled, it requires CACHE_ENABLE_REDIS")
+		return nil, func() {}
+	}
+
+	client := cache.NewRedisClient(cfg.CacheConfig)
+	estimator := reach.NewEstimator(client, cfg.CacheConfig.RedisKeyPrefix(), reachConfig.Days)
+	ctx, stop := context.WithCancel(context.Background())
+	go estimator.Run(ctx, time.Duration(reachConfig.FlushInterval)*time.Millisecond, func(err error) {
+		level.Warn(logger).Log("msg", "reach histograms flush failed, retrying", "err", err)
+	})
+
+	return estimator, func() {
+		stop()
+		client.Close()
+	}
+}
+
+// initializeScheduler starts the campaign scheduler, unless it's disabled or campaigns can't be
+// changed. Budgets are only enforced with reporting, which counts the deliveries, and with Redis
+// replicas take turns through a lease. The returned cleanup stops the scheduler.
+func initializeScheduler(cfg *config.Config, store service.CampaignStore, reports reporting.Store, c cache.Cache, logger kitlog.Logger) func() {
+	schedulerConfig := cfg.SchedulerConfig
+	if !schedulerConfig.Enabled || store == nil {
+		return func() {}
+	}
+	interval := time.Duration(schedulerConfig.Interval) * time.Second
+
+	var config scheduler.Config
+	if reports != nil {
+		config.Deliveries = scheduler.NewReportDeliveries(reports)
+	} else {
+		level.Warn(logger).Log("msg", "campaign delivery budgets not enforced, they require REPORTING_ENABLED")
+	}
+	closeClient := func() {}
+	if cfg.CacheConfig.EnableRedis {
+		client := cache.NewRedisClient(cfg.CacheConfig)
+		config.Lease = scheduler.NewRedisLease(client, cfg.CacheConfig.RedisKeyPrefix()+"scheduler:lease", 2*interval)
+		closeClient = func() { client.Close() }
+	}
+
+	s := scheduler.NewScheduler(config, store, c.InvalidateAll, logger)
+	ctx, stop := context.WithCancel(context.Background())
+	go s.Run(ctx, interval, func(err error) {
+		level.Warn(logger).Log("msg", "campaign scheduler tick failed", "err", err)
+	})
+	level.Info(logger).Log("msg", "campaign scheduler started", "interval", interval, "budgets", config.Deliveries != nil, "lease", config.Lease != nil)
+
+	return func() {
+		stop()
+		closeClient()
+	}
+}
+
+// initializeQuotas creates the quota enforcer and starts flushing its usage, or returns nil when
+// quotas are disabled. Without Redis each replica enforces the quotas on its own deliveries only.
+// The returned cleanup stops flushing and closes the Redis connection, flush once more before calling it.
+func initializeQuotas(cfg *config.Config, logger kitlog.Logger) (*quota.Enforcer, func()) {
+	quotaConfig := cfg.QuotaConfig
+	if !quotaConfig.Enabled {
+		return nil, func() {}
+	}
+
+	// The limits were checked by config validation
+	limits, _ := quota.ParseLimits(quotaConfig.Limits)
+	enforcerConfig := quota.Config{
+		DefaultLimit: int64(quotaConfig.DefaultLimit),
+		Limits:       limits,
+		WarnFraction: quotaConfig.WarnFraction,
+	}
+
+	var store quota.Store = quota.NewMemoryStore()
+	closeStore := func() {}
+	if cfg.CacheConfig.EnableRedis {
+		client := cache.NewRedisClient(cfg.CacheConfig)
+		store = quota.NewRedisStore(client, cfg.CacheConfig.RedisKeyPrefix())
+		closeStore = func() { client.Close() }
+	} else {
+		level.Warn(logger).Log("msg", "quota usage is counted per replica, shared usage requires CACHE_ENABLE_REDIS")
+	}
+
+	enforcer := quota.NewEnforcer(store, enforcerConfig)
+	ctx, stop := context.WithCancel(context.Background())
+	go enforcer.Run(ctx, time.Duration(quotaConfig.FlushInterval)*time.Millisecond, func(err error) {
+		level.Warn(logger).Log("msg", "quota usage flush failed, retrying", "err", err)
+	})
+	level.Info(logger).Log("msg", "API key quotas enabled", "default_limit", quotaConfig.DefaultLimit, "limits", len(limits))
+
+	return enforcer, func() {
+		stop()
+		closeStore()
+	}
+}
+
+// initializeDeduplication creates the middleware deduplicating delivery requests by nonce. Without
+// Redis each replica only deduplicates the requests it receives. The returned cleanup closes the
+// Redis connection.
+func initializeDeduplication(cfg *config.Config, prometheusMetrics *metrics.CachedMetrics, logger kitlog.Logger) (*middleware.DeduplicationMiddleware, func()) {
+	var store dedup.Store = dedup.NewMemoryStore()
+	closeStore := func() {}
+	if cfg.CacheConfig.EnableRedis {
+		client := cache.NewRedisClient(cfg.CacheConfig)
+		store = dedup.NewRedisStore(client, cfg.CacheConfig.RedisKeyPrefix())
+		closeStore = func() { client.Close() }
+	} else {
+		level.Warn(logger).Log("msg", "delivery nonces are deduplicated per replica, shared deduplication requires CACHE_ENABLE_REDIS")
+	}
+
+	window := time.Duration(cfg.DedupConfig.Window) * time.Second
+	return middleware.NewDeduplicationMiddleware(store, window, prometheusMetrics, logger), closeStore
+}
+
+// initializeCreativeValidation creates the validator checking campaign images on the admin API
+// and starts checking the images of active campaigns periodically, or returns nils when it's
+// disabled. The checker is nil when periodic checks are disabled. The returned cleanup stops the
+// checks.
+func initializeCreativeValidation(validationConfig config.CreativeValidationConfig, source creative.CampaignSource, prometheusMetrics *metrics.CachedMetrics, logger kitlog.Logger) (*creative.Validator, *creative.Checker, func()) {
+	if !validationConfig.Enabled {
+		return nil, nil, func() {}
+	}
+
+	validator := creative.NewValidator(creative.ValidatorConfig{
+		MaxBytes:  int64(validationConfig.MaxSize) << 10,
+		Timeout:   time.Duration(validationConfig.Timeout) * time.Millisecond,
+		CDNPrefix: validationConfig.CDNPrefix,
+	})
+	if validationConfig.CheckInterval == 0 {
+		return validator, nil, func() {}
+	}
+
+	checker := creative.NewChecker(validator, source, prometheusMetrics, logger)
+	ctx, stop := context.WithCancel(context.Background())
+	go checker.Run(ctx, time.Duration(validationConfig.CheckInterval)*time.Second)
+	return validator, checker, stop
+}
+
+// initializeBlocklist loads the blocklist from the database, or from memory in mock mode, and
+// starts reloading it periodically, or returns nil when it's disabled. The returned cleanup stops
+// reloading.
+func initializeBlocklist(blocklistConfig config.BlocklistConfig, sourceRepo service.CampaignRepository, logger kitlog.Logger) (*blocklist.Blocklist, func()) {
+	if !blocklistConfig.Enabled {
+		return nil, func() {}
+	}
+
+	store, ok := sourceRepo.(blocklist.Store)
+	if !ok {
+		store = blocklist.NewMemoryStore()
+	}
+	list := blocklist.New(store)
+	ctx, stop := context.WithCancel(context.Background())
+	if err := list.Reload(ctx); err != nil {
+		level.Warn(logger).Log("msg", "blocklist not loaded, retrying in the background", "err", err)
+	}
+	go list.Run(ctx, time.Duration(blocklistConfig.RefreshInterval)*time.Second, func(err error) {
+		level.Warn(logger).Log("msg", "blocklist reload failed, retrying", "err", err)
+	})
+
+	return list, stop
+}
+
+// initializeReporting creates the delivery stats aggregator and starts flushing it to the database,
+// or to memory in mock mode, or returns nils when reporting is disabled. The returned cleanup stops
+// flushing, flush once more before calling it.
+func initializeReporting(reportingConfig config.ReportingConfig, sourceRepo service.CampaignRepository, logger kitlog.Logger) (*reporting.Aggregator, reporting.Store, func()) {
+	if !reportingConfig.Enabled {
+		return nil, nil, func() {}
+	}
+
+	store, ok := sourceRepo.(reporting.Store)
+	if !ok {
+		store = reporting.NewMemoryStore()
+	}
+	aggregator := reporting.NewAggregator(store)
+	ctx, stop := context.WithCancel(context.Background())
+	go aggregator.Run(ctx, time.Duration(reportingConfig.FlushInterval)*time.Second, func(err error) {
+		level.Warn(logger).Log("msg", "delivery stats flush failed, retrying", "err", err)
+	})
+
+	return aggregator, store, stop
+}
+
+// initializeDecisionLog creates the decision logger writing to a rotating file, rotated files are
+// shipped to S3 when a bucket is configured. The returned cleanup writes and ships what is queued.
+func initializeDecisionLog(decisionLogConfig config.DecisionLogConfig, prometheusMetrics *metrics.CachedMetrics, logger kitlog.Logger) (*decisionlog.Logger, func(), error) {
+	rotateConfig := decisionlog.RotateConfig{
+		MaxBytes: int64(decisionLogConfig.MaxSize) << 20,
+		MaxAge:   time.Duration(decisionLogConfig.MaxAge) * time.Minute,
+		MaxFiles: decisionLogConfig.MaxFiles,
+	}
+
+	var shipper *decisionlog.S3Shipper
+	if decisionLogConfig.S3Bucket != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client, err := decisionlog.NewS3Client(ctx, decisionLogConfig.S3Region, decisionLogConfig.S3Endpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		shipper = decisionlog.NewS3Shipper(client, decisionLogConfig.S3Bucket, decisionLogConfig.S3Prefix, logger)
+		rotateConfig.OnRotate = shipper.Ship
+	}
+
+	file, err := decisionlog.NewRotatingFile(decisionLogConfig.Path, rotateConfig)
+	if err != nil {
+		if shipper != nil {
+			shipper.Close()
+		}
+		return nil, nil, err
+	}
+	decisionLog := decisionlog.NewLogger(file, decisionlog.Config{SampleRate: decisionLogConfig.SampleRate}, prometheusMetrics)
+
+	cleanup := func() {
+		if err := decisionLog.Close(); err != nil {
+			level.Warn(logger).Log("msg", "error closing decision log", "err", err)
+		}
+		if shipper != nil {
+			shipper.Close()
+		}
+	}
+	return decisionLog, cleanup, nil
+}
+
+// setupEndpointMiddlewares builds the delivery endpoint middleware chain in configured order, outermost first
+func setupEndpointMiddlewares(cfg *config.Config, deliveryLimiter *endpoint.RateLimiter, prometheusMetrics *metrics.CachedMetrics, logger kitlog.Logger, logControls *logger.Controls) ([]kitendpoint.Middleware, error) {
+	endpointConfig := cfg.EndpointConfig
+	middlewares := make([]kitendpoint.Middleware, 0, len(endpointConfig.Middlewares))
+	for _, name := range endpointConfig.Middlewares {
+		switch name {
+		case endpoint.MiddlewareTimeout:
+			// Deadline budget for delivery, latency matters more than completeness
+			var fallback any
+			if endpointConfig.DeliveryTimeoutEmpty {
+				fallback = &endpoint.GetCampaignsResponse{}
+			}
+			deliveryTimeout := time.Duration(endpointConfig.DeliveryTimeout) * time.Millisecond
+			middlewares = append(middlewares, endpoint.TimeoutMiddleware(deliveryTimeout, fallback))
+		case endpoint.MiddlewareRateLimit:
+			middlewares = append(middlewares, endpoint.RateLimitMiddlewareWithLimiter(deliveryLimiter))
+		case endpoint.MiddlewareCircuitBreaker:
+			// Only failures to load campaigns trip the breaker, invalid requests don't
+			deliveryBreaker := newCircuitBreaker("delivery", cfg.CircuitBreakerConfig, func(err error) bool {
+				return errors.Is(err, service.ErrRetrieveCampaigns) || errors.Is(err, context.DeadlineExceeded)
+			}, prometheusMetrics, logger)
+			middlewares = append(middlewares, endpoint.CircuitBreakerMiddleware(deliveryBreaker))
+		case endpoint.MiddlewareLogging:
+			middlewares = append(middlewares, endpoint.ServiceMiddleware(middleware.NewLoggingMiddlewareWithControls(logger, logControls)))
+		case endpoint.MiddlewareMetrics:
+			middlewares = append(middlewares, endpoint.ServiceMiddleware(middleware.NewServiceMetricsMiddleware(prometheusMetrics)))
+		case endpoint.MiddlewareTracing:
+			middlewares = append(middlewares, endpoint.TracingMiddleware(logger, "delivery"))
+		case endpoint.MiddlewareValidation:
+			validationConfig := cfg.ValidationConfig
+			validator := models.NewRequestValidator(models.ValidationRules{
+				CountryCodeLengths: validationConfig.CountryCodeLengths,
+				AllowedOS:          validationConfig.AllowedOS,
+				MaxAppLength:       validationConfig.MaxAppLength,
+			})
+			middlewares = append(middlewares, endpoint.ValidationMiddleware(validator))
+		default:
+			return nil, fmt.Errorf("unknown endpoint middleware %q", name)
+		}
+	}
+
+	if !slices.Contains(endpointConfig.Middlewares, endpoint.MiddlewareValidation) {
+		level.Warn(logger).Log("msg", "delivery requests are not validated, validation is missing from the endpoint middleware chain")
+	}
+	return middlewares, nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/config"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/database"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/logger"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
@@ -55,7 +56,7 @@ func ExampleCachedRepositorySetup() {
 		Password: "adbeacon1234",
 		DBName:   "adbeacon",
 		SSLMode:  "disable",
-	}, "./migrations")
+	}, "./migrations", logger.New(logger.Config{Service: "adbeacon-example"}))
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
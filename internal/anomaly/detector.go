@@ -0,0 +1,216 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Watched metrics, used in alerts and as metric labels
+const (
+	MetricFillRate    = "fill_rate"
+	MetricSuccessRate = "success_rate"
+)
+
+// Alert states
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// bucketWidth is the resolution of the sliding windows
+const bucketWidth = 10 * time.Second
+
+// Config holds the windows and thresholds of a Detector
+type Config struct {
+	// Window is the recent window compared against the baseline
+	Window time.Duration
+	// Baseline is the window before Window that recent rates are compared against
+	Baseline time.Duration
+	// MinRequests is the number of requests both windows need before they are compared
+	MinRequests int64
+	// FillRateDrop is the relative drop of the fill rate that fires an alert, 0.3 fires when the
+	// recent fill rate is below 70% of the baseline
+	FillRateDrop float64
+	// SuccessRateDrop is the absolute drop of the success rate that fires an alert, 0.05 fires when
+	// the recent success rate is 5 points below the baseline
+	SuccessRateDrop float64
+}
+
+// Alert reports a watched metric dropping significantly below its baseline, or recovering
+type Alert struct {
+	Metric   string    `json:"metric"`
+	State    string    `json:"state"`
+	Current  float64   `json:"current"`
+	Baseline float64   `json:"baseline"`
+	Requests int64     `json:"requests"`
+	Window   string    `json:"window"`
+	Time     time.Time `json:"time"`
+}
+
+// Notifier delivers alerts, to a log, a metric or a webhook
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// NotifierFunc adapts a function to a Notifier
+type NotifierFunc func(ctx context.Context, alert Alert) error
+
+// Notify implements Notifier
+func (f NotifierFunc) Notify(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// bucket holds delivery outcomes for one bucketWidth interval
+type bucket struct {
+	start  time.Time
+	total  int64
+	errors int64
+	filled int64
+}
+
+// rates are the outcomes of a window
+type rates struct {
+	requests    int64
+	fillRate    float64
+	successRate float64
+}
+
+// Detector watches the fill rate and success rate of delivery requests and alerts when their
+// recent value drops significantly below the preceding baseline. Each drop alerts once when it
+// starts and once when it resolves.
+type Detector struct {
+	config    Config
+	notifiers []Notifier
+	now       func() time.Time
+
+	mu      sync.Mutex
+	buckets []bucket
+	firing  map[string]bool
+}
+
+// NewDetector creates a detector notifying alerts to notifiers
+func NewDetector(config Config, notifiers ...Notifier) *Detector {
+	slots := int((config.Window+config.Baseline)/bucketWidth) + 1
+	return &Detector{
+		config:    config,
+		notifiers: notifiers,
+		now:       time.Now,
+		buckets:   make([]bucket, slots),
+		firing:    make(map[string]bool),
+	}
+}
+
+// Observe records the outcome of a delivery request
+func (d *Detector) Observe(failed, filled bool) {
+	now := d.now().Truncate(bucketWidth)
+	idx := int(now.Unix()/int64(bucketWidth.Seconds())) % len(d.buckets)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b := &d.buckets[idx]
+	if !b.start.Equal(now) {
+		// The slot holds data from a previous cycle, reset it
+		*b = bucket{start: now}
+	}
+	b.total++
+	if failed {
+		b.errors++
+	}
+	if filled {
+		b.filled++
+	}
+}
+
+// window sums the buckets starting in (from, to]
+func (d *Detector) window(from, to time.Time) rates {
+	var total, errors, filled int64
+	for _, b := range d.buckets {
+		if b.total == 0 || !b.start.After(from) || b.start.After(to) {
+			continue
+		}
+		total += b.total
+		errors += b.errors
+		filled += b.filled
+	}
+
+	r := rates{requests: total, fillRate: 1, successRate: 1}
+	if total > 0 {
+		r.successRate = 1 - float64(errors)/float64(total)
+	}
+	// Failed requests can't fill, they count against the success rate only
+	if succeeded := total - errors; succeeded > 0 {
+		r.fillRate = float64(filled) / float64(succeeded)
+	}
+	return r
+}
+
+// Check compares the recent window with the baseline and notifies the alerts that started or
+// resolved since the last check, errors of the notifiers are joined
+func (d *Detector) Check(ctx context.Context) error {
+	now := d.now()
+	recentStart := now.Add(-d.config.Window)
+
+	d.mu.Lock()
+	recent := d.window(recentStart, now)
+	baseline := d.window(recentStart.Add(-d.config.Baseline), recentStart)
+
+	var alerts []Alert
+	transition := func(metric string, current, base float64, dropped bool) {
+		// Without enough traffic nothing starts, and what fires keeps firing
+		if recent.requests < d.config.MinRequests || baseline.requests < d.config.MinRequests {
+			return
+		}
+		if dropped == d.firing[metric] {
+			return
+		}
+		d.firing[metric] = dropped
+		state := StateResolved
+		if dropped {
+			state = StateFiring
+		}
+		alerts = append(alerts, Alert{
+			Metric:   metric,
+			State:    state,
+			Current:  current,
+			Baseline: base,
+			Requests: recent.requests,
+			Window:   d.config.Window.String(),
+			Time:     now,
+		})
+	}
+	transition(MetricSuccessRate, recent.successRate, baseline.successRate,
+		recent.successRate < baseline.successRate-d.config.SuccessRateDrop)
+	transition(MetricFillRate, recent.fillRate, baseline.fillRate,
+		recent.fillRate < baseline.fillRate*(1-d.config.FillRateDrop))
+	d.mu.Unlock()
+
+	var errs []error
+	for _, alert := range alerts {
+		for _, notifier := range d.notifiers {
+			if err := notifier.Notify(ctx, alert); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run checks every interval until ctx is done, failed notifications are passed to onError
+func (d *Detector) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Check(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
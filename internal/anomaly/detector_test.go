@@ -0,0 +1,96 @@
+package anomaly
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDetector creates a detector with a controllable clock collecting its alerts
+func newTestDetector(config Config) (*Detector, *time.Time, *[]Alert) {
+	var alerts []Alert
+	detector := NewDetector(config, NotifierFunc(func(_ context.Context, alert Alert) error {
+		alerts = append(alerts, alert)
+		return nil
+	}))
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	detector.now = func() time.Time { return now }
+	return detector, &now, &alerts
+}
+
+// observe records requests over the next duration, failing every failEvery-th and filling every fillEvery-th
+// successful one, 0 never fails or never fills
+func observe(detector *Detector, now *time.Time, duration time.Duration, requests, failEvery, fillEvery int) {
+	step := duration / time.Duration(requests)
+	for i := 1; i <= requests; i++ {
+		failed := failEvery > 0 && i%failEvery == 0
+		filled := !failed && fillEvery > 0 && i%fillEvery == 0
+		detector.Observe(failed, filled)
+		*now = now.Add(step)
+	}
+}
+
+var testConfig = Config{
+	Window:          5 * time.Minute,
+	Baseline:        time.Hour,
+	MinRequests:     50,
+	FillRateDrop:    0.3,
+	SuccessRateDrop: 0.05,
+}
+
+func TestDetector_FillRateDrop(t *testing.T) {
+	detector, now, alerts := newTestDetector(testConfig)
+	ctx := context.Background()
+
+	// An hour filling every request, then five minutes filling half of them
+	observe(detector, now, time.Hour, 600, 0, 1)
+	require.NoError(t, detector.Check(ctx))
+	assert.Empty(t, *alerts)
+	observe(detector, now, 5*time.Minute, 100, 0, 2)
+	require.NoError(t, detector.Check(ctx))
+
+	require.Len(t, *alerts, 1)
+	alert := (*alerts)[0]
+	assert.Equal(t, MetricFillRate, alert.Metric)
+	assert.Equal(t, StateFiring, alert.State)
+	assert.InDelta(t, 0.5, alert.Current, 0.05)
+	assert.InDelta(t, 1, alert.Baseline, 0.05)
+	assert.Equal(t, "5m0s", alert.Window)
+
+	// Still firing, nothing new to notify
+	require.NoError(t, detector.Check(ctx))
+	assert.Len(t, *alerts, 1)
+
+	// Once the recent window looks like the baseline again the alert resolves
+	observe(detector, now, 10*time.Minute, 200, 0, 1)
+	require.NoError(t, detector.Check(ctx))
+	require.Len(t, *alerts, 2)
+	assert.Equal(t, StateResolved, (*alerts)[1].State)
+}
+
+func TestDetector_SuccessRateDrop(t *testing.T) {
+	detector, now, alerts := newTestDetector(testConfig)
+
+	// One failure in a hundred, then one in five
+	observe(detector, now, time.Hour, 1000, 100, 1)
+	observe(detector, now, 5*time.Minute, 100, 5, 1)
+	require.NoError(t, detector.Check(context.Background()))
+
+	// Failed requests don't count against the fill rate
+	require.Len(t, *alerts, 1)
+	assert.Equal(t, MetricSuccessRate, (*alerts)[0].Metric)
+	assert.InDelta(t, 0.8, (*alerts)[0].Current, 0.01)
+}
+
+func TestDetector_MinRequests(t *testing.T) {
+	detector, now, alerts := newTestDetector(testConfig)
+
+	// Too little recent traffic to tell a drop from noise
+	observe(detector, now, time.Hour, 600, 0, 1)
+	observe(detector, now, 5*time.Minute, 20, 0, 0)
+	require.NoError(t, detector.Check(context.Background()))
+	assert.Empty(t, *alerts)
+}
@@ -0,0 +1,73 @@
+package anomaly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// NewLogNotifier creates a notifier logging firing alerts as errors and resolved ones as info
+func NewLogNotifier(logger log.Logger) Notifier {
+	return NotifierFunc(func(_ context.Context, alert Alert) error {
+		logLevel := level.Info
+		msg := "delivery anomaly resolved"
+		if alert.State == StateFiring {
+			logLevel = level.Error
+			msg = "delivery anomaly detected"
+		}
+		logLevel(logger).Log("msg", msg, "metric", alert.Metric, "current", alert.Current,
+			"baseline", alert.Baseline, "requests", alert.Requests, "window", alert.Window)
+		return nil
+	})
+}
+
+// Recorder exports the alert state, metrics.CachedMetrics implements it
+type Recorder interface {
+	RecordAnomalyAlert(metric string, firing bool)
+}
+
+// NewMetricNotifier creates a notifier exporting the alerts through recorder
+func NewMetricNotifier(recorder Recorder) Notifier {
+	return NotifierFunc(func(_ context.Context, alert Alert) error {
+		recorder.RecordAnomalyAlert(alert.Metric, alert.State == StateFiring)
+		return nil
+	})
+}
+
+// NewWebhookNotifier creates a notifier POSTing alerts as JSON to url, a nil client uses
+// http.DefaultClient. Alerts are sent once, a failure is returned to the caller.
+func NewWebhookNotifier(url string, client *http.Client, timeout time.Duration) Notifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return NotifierFunc(func(ctx context.Context, alert Alert) error {
+		body, err := json.Marshal(alert)
+		if err != nil {
+			return fmt.Errorf("failed to encode alert: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create alert request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send %s alert: %w", alert.Metric, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("alert webhook returned %s", resp.Status)
+		}
+		return nil
+	})
+}
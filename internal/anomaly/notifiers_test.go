@@ -0,0 +1,32 @@
+package anomaly
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var received Alert
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, server.Client(), time.Second)
+	alert := Alert{Metric: MetricFillRate, State: StateFiring, Current: 0.4, Baseline: 0.9, Requests: 120, Window: "5m0s"}
+	require.NoError(t, notifier.Notify(context.Background(), alert))
+	assert.Equal(t, alert, received)
+
+	status = http.StatusBadGateway
+	assert.Error(t, notifier.Notify(context.Background(), alert))
+}
@@ -0,0 +1,81 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+)
+
+// BenchmarkCacheActiveCampaigns reads the active campaigns from the memory cache and from Redis,
+// where every read decodes the campaigns
+func BenchmarkCacheActiveCampaigns(b *testing.B) {
+	ctx := context.Background()
+	server := miniredis.RunT(b)
+
+	layers := []struct {
+		name   string
+		config cache.CacheConfig
+	}{
+		{"layer=memory", cache.CacheConfig{DefaultTTL: time.Hour, MemoryCacheSize: 1000, EnableMemory: true}},
+		{"layer=redis", cache.CacheConfig{DefaultTTL: time.Hour, RedisAddr: server.Addr(), RedisMaxRetries: -1, EnableRedis: true}},
+	}
+
+	for _, layer := range layers {
+		for _, size := range inventorySizes {
+			b.Run(fmt.Sprintf("%s/campaigns=%d", layer.name, size), func(b *testing.B) {
+				hybridCache, err := cache.NewHybridCache(layer.config)
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer hybridCache.Close()
+				if err := hybridCache.SetActiveCampaigns(ctx, inventory(size), time.Hour); err != nil {
+					b.Fatal(err)
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := hybridCache.GetActiveCampaigns(ctx); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkCandidateLookup looks up the compiled candidates of requests in the campaign snapshot
+func BenchmarkCandidateLookup(b *testing.B) {
+	reqs := requests(b)
+	ctx := context.Background()
+
+	for _, size := range inventorySizes {
+		b.Run(fmt.Sprintf("campaigns=%d", size), func(b *testing.B) {
+			hybridCache, err := cache.NewHybridCache(cache.CacheConfig{
+				DefaultTTL:      time.Hour,
+				MemoryCacheSize: 1000,
+				EnableMemory:    true,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer hybridCache.Close()
+			cachedRepo := cache.NewCachedRepositoryWithStaleHandler(staticRepository(inventory(size)), hybridCache, time.Hour, nil)
+			if err := cachedRepo.RefreshSnapshot(ctx); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, reqs[i%len(reqs)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/transport"
+)
+
+// newDeliveryHandler builds the delivery HTTP stack over repo with a warm memory cache and
+// campaign snapshot, without the HTTP middlewares of the server
+func newDeliveryHandler(b *testing.B, repo service.CampaignRepository) http.Handler {
+	b.Helper()
+
+	hybridCache, err := cache.NewHybridCache(cache.CacheConfig{
+		DefaultTTL:      time.Hour,
+		MemoryCacheSize: 1000,
+		EnableMemory:    true,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { hybridCache.Close() })
+
+	ctx := context.Background()
+	cachedRepo := cache.NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Hour, nil)
+	if err := cachedRepo.RefreshSnapshot(ctx); err != nil {
+		b.Fatal(err)
+	}
+	if err := cachedRepo.Flush(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	endpoints := endpoint.MakeDeliveryEndpoints(service.NewDeliveryService(cachedRepo))
+	return transport.NewHTTPHandlerWithOptions(endpoints, log.NewNopLogger(), transport.HandlerOptions{Cache: hybridCache})
+}
+
+// deliveryTargets returns the request URIs of the synthetic requests
+func deliveryTargets(b *testing.B) []string {
+	targets := make([]string, 0, 1024)
+	for _, req := range requests(b) {
+		query := url.Values{"country": {req.Country}, "os": {req.OS}, "app": {req.App}}
+		targets = append(targets, "/v1/delivery?"+query.Encode())
+	}
+	return targets
+}
+
+// deliveryRepositories are the inventories of the delivery benchmarks: the sample campaigns of
+// the mock repository and synthetic inventories
+func deliveryRepositories() []struct {
+	name string
+	repo service.CampaignRepository
+} {
+	repos := []struct {
+		name string
+		repo service.CampaignRepository
+	}{{"campaigns=sample", repository.NewMockRepository()}}
+	for _, size := range inventorySizes {
+		repos = append(repos, struct {
+			name string
+			repo service.CampaignRepository
+		}{fmt.Sprintf("campaigns=%d", size), staticRepository(inventory(size))})
+	}
+	return repos
+}
+
+// BenchmarkDeliveryHandler serves delivery requests in process, measuring the stack from
+// decoding the request to encoding the response
+func BenchmarkDeliveryHandler(b *testing.B) {
+	targets := deliveryTargets(b)
+	for _, inventory := range deliveryRepositories() {
+		b.Run(inventory.name, func(b *testing.B) {
+			handler := newDeliveryHandler(b, inventory.repo)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, targets[i%len(targets)], nil))
+				if w.Code != http.StatusOK && w.Code != http.StatusNoContent {
+					b.Fatalf("unexpected status %d", w.Code)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDeliveryServer serves delivery requests through an httptest server over keep-alive
+// connections, from as many clients as GOMAXPROCS
+func BenchmarkDeliveryServer(b *testing.B) {
+	targets := deliveryTargets(b)
+	for _, inventory := range deliveryRepositories() {
+		b.Run(inventory.name, func(b *testing.B) {
+			server := httptest.NewServer(newDeliveryHandler(b, inventory.repo))
+			defer server.Close()
+			client := server.Client()
+			client.Transport.(*http.Transport).MaxIdleConnsPerHost = 64
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					resp, err := client.Get(server.URL + targets[i%len(targets)])
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+						b.Errorf("unexpected status %d", resp.StatusCode)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
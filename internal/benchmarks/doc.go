@@ -0,0 +1,9 @@
+// Package benchmarks holds the benchmarks of the delivery path: end-to-end delivery through the
+// HTTP stack, campaign matching and the cache layers, over synthetic campaign inventories of
+// growing size. Inventories and requests are generated from fixed seeds, so runs are comparable
+// between builds. Run them enough times for benchstat and compare with a baseline:
+//
+//	make bench BENCH_OUT=bench_base.txt  # on the baseline
+//	make bench                           # on the change
+//	make bench-compare
+package benchmarks
@@ -0,0 +1,82 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/simulation"
+)
+
+// inventorySizes are the campaign inventory sizes benchmarks run over
+var inventorySizes = []int{10, 100, 1000, 10000}
+
+// Dimension values of synthetic campaigns, a superset of the simulation defaults so some
+// campaigns target values no request has
+var (
+	inventoryCountries = []string{"us", "in", "de", "ca", "gb", "fr", "br", "jp", "au", "mx"}
+	inventoryOS        = []string{"android", "ios"}
+	inventoryApps      = []string{"com.spotify.music", "com.duolingo", "com.gametion.ludokinggame", "com.example.other", "com.example.news", "com.example.weather"}
+)
+
+// inventory generates n active campaigns, each including one to three countries and
+// optionally an OS, an app or an excluded country
+func inventory(n int) []models.CampaignWithRules {
+	rng := rand.New(rand.NewSource(int64(n)))
+	pick := func(values []string, count int) []string {
+		picked := make([]string, count)
+		for i := range picked {
+			picked[i] = values[rng.Intn(len(values))]
+		}
+		return picked
+	}
+
+	campaigns := make([]models.CampaignWithRules, n)
+	for i := range campaigns {
+		id := fmt.Sprintf("campaign-%d", i)
+		rules := []models.TargetingRule{
+			{CampaignID: id, Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: pick(inventoryCountries, 1+rng.Intn(3))},
+		}
+		if rng.Intn(2) == 0 {
+			rules = append(rules, models.TargetingRule{CampaignID: id, Dimension: models.DimensionOS, RuleType: models.RuleTypeInclude, Values: pick(inventoryOS, 1)})
+		}
+		if rng.Intn(4) == 0 {
+			rules = append(rules, models.TargetingRule{CampaignID: id, Dimension: models.DimensionApp, RuleType: models.RuleTypeInclude, Values: pick(inventoryApps, 1+rng.Intn(2))})
+		}
+		if rng.Intn(4) == 0 {
+			rules = append(rules, models.TargetingRule{CampaignID: id, Dimension: models.DimensionCountry, RuleType: models.RuleTypeExclude, Values: pick(inventoryCountries, 1)})
+		}
+
+		campaigns[i] = models.CampaignWithRules{
+			Campaign: models.Campaign{
+				ID:       id,
+				Name:     "Campaign " + id,
+				ImageURL: "https://example.com/" + id + ".png",
+				CTA:      "Install",
+				Status:   models.StatusActive,
+			},
+			Rules: rules,
+		}
+	}
+	return campaigns
+}
+
+// requests returns synthetic delivery requests with the simulation's default value mix
+func requests(tb testing.TB) []models.DeliveryRequest {
+	tb.Helper()
+
+	reqs, err := simulation.SyntheticRequests(simulation.SyntheticConfig{Count: 1024, Seed: 1})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return reqs
+}
+
+// staticRepository serves a fixed campaign inventory
+type staticRepository []models.CampaignWithRules
+
+func (r staticRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
+	return r, nil
+}
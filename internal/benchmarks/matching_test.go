@@ -0,0 +1,83 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// BenchmarkMatching matches every campaign of the inventory against a request, with the rules
+// interpreted by their processors and compiled ahead of time
+func BenchmarkMatching(b *testing.B) {
+	reqs := requests(b)
+	matcher := models.NewCampaignMatcher(models.GetDimensionRegistry())
+
+	for _, size := range inventorySizes {
+		campaigns := inventory(size)
+		compiled := models.CompileCampaigns(campaigns)
+
+		b.Run(fmt.Sprintf("mode=interpreted/campaigns=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				req := reqs[i%len(reqs)]
+				for j := range campaigns {
+					campaigns[j].MatchesRequestWithMatcher(req, matcher)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("mode=compiled/campaigns=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				req := reqs[i%len(reqs)]
+				for _, campaign := range compiled {
+					matcher.MatchesCompiled(campaign, req)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDeliveryService serves requests from the service over a warm campaign snapshot,
+// with and without matching large candidate sets in parallel
+func BenchmarkDeliveryService(b *testing.B) {
+	reqs := requests(b)
+	ctx := context.Background()
+
+	for _, size := range inventorySizes {
+		hybridCache, err := cache.NewHybridCache(cache.CacheConfig{
+			DefaultTTL:      time.Hour,
+			MemoryCacheSize: 1000,
+			EnableMemory:    true,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		cachedRepo := cache.NewCachedRepositoryWithStaleHandler(staticRepository(inventory(size)), hybridCache, time.Hour, nil)
+		if err := cachedRepo.RefreshSnapshot(ctx); err != nil {
+			b.Fatal(err)
+		}
+
+		for _, parallel := range []bool{false, true} {
+			b.Run(fmt.Sprintf("parallel=%t/campaigns=%d", parallel, size), func(b *testing.B) {
+				svc := service.NewDeliveryService(cachedRepo)
+				if parallel {
+					svc.SetParallelMatcher(service.NewParallelMatcher(1, 4))
+				}
+
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := svc.GetCampaigns(ctx, reqs[i%len(reqs)]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+		hybridCache.Close()
+	}
+}
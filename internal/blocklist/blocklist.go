@@ -0,0 +1,197 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/fraud"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// Kinds of blocklist entries
+const (
+	KindApp     = "app"
+	KindCountry = "country"
+	KindIPRange = "ip_range"
+)
+
+// Kinds are the valid entry kinds
+var Kinds = []string{KindApp, KindCountry, KindIPRange}
+
+var (
+	// ErrInvalidEntry is wrapped by the errors of Entry.Normalize
+	ErrInvalidEntry = errors.New("invalid blocklist entry")
+	// ErrEntryNotFound is returned when removing an entry that doesn't exist
+	ErrEntryNotFound = errors.New("blocklist entry not found")
+	// ErrEntryExists is returned when adding a value already blocked
+	ErrEntryExists = errors.New("blocklist entry already exists")
+)
+
+// Entry blocks delivery requests of an app, a country or an IP range for every campaign
+type Entry struct {
+	ID    int64  `json:"id"`
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+	// Comment says why the entry was added
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Normalize validates the entry and rewrites its value the way delivery requests are normalized:
+// countries become lowercase alpha-2 codes and IP ranges are masked, single addresses become /32 or /128
+func (e *Entry) Normalize() error {
+	e.Value = strings.TrimSpace(e.Value)
+	if e.Value == "" {
+		return fmt.Errorf("%w: value is required", ErrInvalidEntry)
+	}
+
+	switch e.Kind {
+	case KindApp:
+	case KindCountry:
+		if !models.IsCountryCode(e.Value) {
+			return fmt.Errorf("%w: unknown country code %q", ErrInvalidEntry, e.Value)
+		}
+		e.Value = models.NormalizeCountryCode(e.Value)
+	case KindIPRange:
+		prefixes, err := fraud.ParsePrefixes([]string{e.Value})
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+		}
+		e.Value = prefixes[0].String()
+	default:
+		return fmt.Errorf("%w: unknown kind %q, expected one of %v", ErrInvalidEntry, e.Kind, Kinds)
+	}
+	return nil
+}
+
+// Store keeps the blocklist entries
+type Store interface {
+	// ListBlocklist returns the entries ordered by ID
+	ListBlocklist(ctx context.Context) ([]Entry, error)
+	// AddBlocklistEntry stores a normalized entry and returns it with its ID and creation time,
+	// or ErrEntryExists if its kind and value are already blocked
+	AddBlocklistEntry(ctx context.Context, entry Entry) (Entry, error)
+	// RemoveBlocklistEntry deletes an entry, returning ErrEntryNotFound if there is none with id
+	RemoveBlocklistEntry(ctx context.Context, id int64) error
+}
+
+// compiled is the blocklist in the form delivery requests are checked against
+type compiled struct {
+	apps      map[string]bool
+	countries map[string]bool
+	prefixes  []netip.Prefix
+}
+
+func compile(entries []Entry) *compiled {
+	c := &compiled{apps: make(map[string]bool), countries: make(map[string]bool)}
+	for _, entry := range entries {
+		switch entry.Kind {
+		case KindApp:
+			c.apps[entry.Value] = true
+		case KindCountry:
+			c.countries[entry.Value] = true
+		case KindIPRange:
+			if prefix, err := netip.ParsePrefix(entry.Value); err == nil {
+				c.prefixes = append(c.prefixes, prefix)
+			}
+		}
+	}
+	return c
+}
+
+// Blocklist checks delivery requests against the stored entries. The entries are loaded into
+// memory and reloaded periodically, see Run, and after every change made through it.
+type Blocklist struct {
+	store Store
+	list  atomic.Pointer[compiled]
+}
+
+// New creates a blocklist backed by store, empty until loaded with Reload
+func New(store Store) *Blocklist {
+	b := &Blocklist{store: store}
+	b.list.Store(compile(nil))
+	return b
+}
+
+// Reload loads the entries from the store, the previous entries are kept if that fails
+func (b *Blocklist) Reload(ctx context.Context) error {
+	entries, err := b.store.ListBlocklist(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load blocklist: %w", err)
+	}
+	b.list.Store(compile(entries))
+	return nil
+}
+
+// Run reloads the entries every interval until ctx is done, reporting failures to onError
+func (b *Blocklist) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.Reload(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Check returns the kind of the entry blocking a request from app and country sent from ip, or
+// an empty string when none does. Values are normalized like DeliveryRequest.NormalizeValues,
+// ip may be invalid when the client address is unknown.
+func (b *Blocklist) Check(app, country string, ip netip.Addr) string {
+	list := b.list.Load()
+	if len(list.apps) > 0 && list.apps[strings.TrimSpace(app)] {
+		return KindApp
+	}
+	if len(list.countries) > 0 && list.countries[models.NormalizeCountryCode(country)] {
+		return KindCountry
+	}
+	if ip.IsValid() {
+		ip = ip.Unmap()
+		for _, prefix := range list.prefixes {
+			if prefix.Contains(ip) {
+				return KindIPRange
+			}
+		}
+	}
+	return ""
+}
+
+// List returns the stored entries, which may include changes made on other replicas that
+// weren't loaded yet
+func (b *Blocklist) List(ctx context.Context) ([]Entry, error) {
+	return b.store.ListBlocklist(ctx)
+}
+
+// Add normalizes and stores an entry, then reloads the entries. The entry is stored even if
+// reloading fails, it's then loaded by the next periodic reload.
+func (b *Blocklist) Add(ctx context.Context, entry Entry) (Entry, error) {
+	if err := entry.Normalize(); err != nil {
+		return Entry{}, err
+	}
+	stored, err := b.store.AddBlocklistEntry(ctx, entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	b.Reload(ctx)
+	return stored, nil
+}
+
+// Remove deletes an entry, then reloads the entries like Add
+func (b *Blocklist) Remove(ctx context.Context, id int64) error {
+	if err := b.store.RemoveBlocklistEntry(ctx, id); err != nil {
+		return err
+	}
+	b.Reload(ctx)
+	return nil
+}
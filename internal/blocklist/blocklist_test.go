@@ -0,0 +1,96 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntry_Normalize(t *testing.T) {
+	tests := []struct {
+		entry   Entry
+		want    string
+		wantErr bool
+	}{
+		{entry: Entry{Kind: KindApp, Value: " com.example.app "}, want: "com.example.app"},
+		{entry: Entry{Kind: KindCountry, Value: "DEU"}, want: "de"},
+		{entry: Entry{Kind: KindCountry, Value: "Germany"}, wantErr: true},
+		{entry: Entry{Kind: KindIPRange, Value: "10.1.2.3/8"}, want: "10.0.0.0/8"},
+		{entry: Entry{Kind: KindIPRange, Value: "2001:db8::1"}, want: "2001:db8::1/128"},
+		{entry: Entry{Kind: KindIPRange, Value: "10.0.0/8"}, wantErr: true},
+		{entry: Entry{Kind: "os", Value: "ios"}, wantErr: true},
+		{entry: Entry{Kind: KindApp, Value: " "}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry.Kind+"/"+tt.entry.Value, func(t *testing.T) {
+			err := tt.entry.Normalize()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidEntry)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tt.entry.Value)
+		})
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	ctx := context.Background()
+	list := New(NewMemoryStore())
+	ip := netip.MustParseAddr("203.0.113.7")
+
+	// Nothing is blocked until entries are added
+	assert.Empty(t, list.Check("com.example.app", "us", ip))
+
+	app, err := list.Add(ctx, Entry{Kind: KindApp, Value: "com.example.app", Comment: "fraudulent installs"})
+	require.NoError(t, err)
+	_, err = list.Add(ctx, Entry{Kind: KindCountry, Value: "USA"})
+	require.NoError(t, err)
+	_, err = list.Add(ctx, Entry{Kind: KindIPRange, Value: "203.0.113.0/24"})
+	require.NoError(t, err)
+
+	_, err = list.Add(ctx, Entry{Kind: KindCountry, Value: "us"})
+	assert.ErrorIs(t, err, ErrEntryExists)
+	_, err = list.Add(ctx, Entry{Kind: KindCountry, Value: "nowhere"})
+	assert.ErrorIs(t, err, ErrInvalidEntry)
+
+	// Request values are normalized before they are checked
+	assert.Equal(t, KindApp, list.Check(" com.example.app", "de", netip.Addr{}))
+	assert.Equal(t, KindCountry, list.Check("com.other", "US", netip.Addr{}))
+	assert.Equal(t, KindIPRange, list.Check("com.other", "de", netip.MustParseAddr("::ffff:203.0.113.7")))
+	assert.Empty(t, list.Check("com.other", "de", netip.MustParseAddr("198.51.100.1")))
+	entries, err := list.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "us", entries[1].Value)
+
+	require.NoError(t, list.Remove(ctx, app.ID))
+	assert.Empty(t, list.Check("com.example.app", "de", netip.Addr{}))
+	assert.ErrorIs(t, list.Remove(ctx, app.ID), ErrEntryNotFound)
+}
+
+// failingStore fails to list the entries
+type failingStore struct{ *MemoryStore }
+
+func (failingStore) ListBlocklist(context.Context) ([]Entry, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestBlocklist_ReloadKeepsEntriesOnFailure(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	_, err := store.AddBlocklistEntry(ctx, Entry{Kind: KindApp, Value: "com.example.app"})
+	require.NoError(t, err)
+
+	list := New(store)
+	require.NoError(t, list.Reload(ctx))
+
+	list.store = failingStore{store}
+	assert.Error(t, list.Reload(ctx))
+	assert.Equal(t, KindApp, list.Check("com.example.app", "us", netip.Addr{}))
+}
@@ -0,0 +1,55 @@
+package blocklist
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store keeping entries in memory, for the mock mode and tests
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries []Entry
+	nextID  int64
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextID: 1}
+}
+
+// ListBlocklist returns a copy of the entries ordered by ID
+func (s *MemoryStore) ListBlocklist(_ context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Entry(nil), s.entries...), nil
+}
+
+// AddBlocklistEntry appends entry with the next ID
+func (s *MemoryStore) AddBlocklistEntry(_ context.Context, entry Entry) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.entries {
+		if existing.Kind == entry.Kind && existing.Value == entry.Value {
+			return Entry{}, ErrEntryExists
+		}
+	}
+	entry.ID = s.nextID
+	entry.CreatedAt = time.Now().UTC()
+	s.nextID++
+	s.entries = append(s.entries, entry)
+	return entry, nil
+}
+
+// RemoveBlocklistEntry deletes the entry with id
+func (s *MemoryStore) RemoveBlocklistEntry(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, entry := range s.entries {
+		if entry.ID == id {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return nil
+		}
+	}
+	return ErrEntryNotFound
+}
@@ -0,0 +1,209 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// StateClosed lets all calls through and counts consecutive failures
+	StateClosed State = iota
+	// StateHalfOpen lets a limited number of trial calls through
+	StateHalfOpen
+	// StateOpen rejects all calls until the open timeout expires
+	StateOpen
+)
+
+// String returns the state name
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrOpen is returned when the breaker rejects a call
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Settings configures a circuit breaker
+type Settings struct {
+	// Name identifies the breaker in logs and metrics
+	Name string
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before allowing trial calls
+	OpenTimeout time.Duration
+	// HalfOpenMaxRequests is the number of trial calls allowed, and the number of
+	// successes needed to close the breaker again
+	HalfOpenMaxRequests int
+	// IsFailure decides whether an error counts as a failure, by default all errors
+	// except context cancellation do. Errors that aren't failures are ignored.
+	IsFailure func(err error) bool
+	// OnStateChange is called on every state transition, while holding the breaker lock
+	OnStateChange func(name string, from, to State)
+}
+
+// CircuitBreaker stops calling a failing dependency for a while so callers fail fast
+type CircuitBreaker struct {
+	settings Settings
+	now      func() time.Time
+
+	mu         sync.Mutex
+	state      State
+	generation uint64
+	failures   int
+	successes  int
+	inFlight   int
+	openedAt   time.Time
+}
+
+// New creates a new circuit breaker
+func New(settings Settings) *CircuitBreaker {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 5
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second
+	}
+	if settings.HalfOpenMaxRequests <= 0 {
+		settings.HalfOpenMaxRequests = 1
+	}
+	if settings.IsFailure == nil {
+		settings.IsFailure = defaultIsFailure
+	}
+
+	return &CircuitBreaker{
+		settings: settings,
+		now:      time.Now,
+	}
+}
+
+// defaultIsFailure ignores cancellations by the caller, they say nothing about the dependency
+func defaultIsFailure(err error) bool {
+	return !errors.Is(err, context.Canceled)
+}
+
+// Name returns the breaker name
+func (cb *CircuitBreaker) Name() string {
+	return cb.settings.Name
+}
+
+// State returns the current state
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.currentState()
+}
+
+// Execute runs fn if the breaker allows it and records the outcome
+func (cb *CircuitBreaker) Execute(fn func() error) error {
+	generation, err := cb.before()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	cb.after(generation, err)
+	return err
+}
+
+// before checks whether a call is allowed and reserves a trial slot when half-open
+func (cb *CircuitBreaker) before() (uint64, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.currentState() {
+	case StateOpen:
+		return 0, ErrOpen
+	case StateHalfOpen:
+		if cb.inFlight >= cb.settings.HalfOpenMaxRequests {
+			return 0, ErrOpen
+		}
+		cb.inFlight++
+	}
+
+	return cb.generation, nil
+}
+
+// after records the outcome of a call, outcomes from a previous state are ignored
+func (cb *CircuitBreaker) after(generation uint64, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	state := cb.currentState()
+	if generation != cb.generation {
+		return
+	}
+
+	failed := err != nil && cb.settings.IsFailure(err)
+	if err != nil && !failed {
+		// Neither success nor failure, just release a trial slot
+		if state == StateHalfOpen {
+			cb.inFlight--
+		}
+		return
+	}
+
+	switch state {
+	case StateClosed:
+		if !failed {
+			cb.failures = 0
+			return
+		}
+		cb.failures++
+		if cb.failures >= cb.settings.FailureThreshold {
+			cb.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		cb.inFlight--
+		if failed {
+			cb.setState(StateOpen)
+			return
+		}
+		cb.successes++
+		if cb.successes >= cb.settings.HalfOpenMaxRequests {
+			cb.setState(StateClosed)
+		}
+	}
+}
+
+// currentState moves an expired open breaker to half-open, callers must hold mu
+func (cb *CircuitBreaker) currentState() State {
+	if cb.state == StateOpen && !cb.now().Before(cb.openedAt.Add(cb.settings.OpenTimeout)) {
+		cb.setState(StateHalfOpen)
+	}
+	return cb.state
+}
+
+// setState transitions to a new state and resets the counters, callers must hold mu
+func (cb *CircuitBreaker) setState(state State) {
+	if cb.state == state {
+		return
+	}
+
+	from := cb.state
+	cb.state = state
+	cb.generation++
+	cb.failures = 0
+	cb.successes = 0
+	cb.inFlight = 0
+	if state == StateOpen {
+		cb.openedAt = cb.now()
+	}
+
+	if cb.settings.OnStateChange != nil {
+		cb.settings.OnStateChange(cb.settings.Name, from, state)
+	}
+}
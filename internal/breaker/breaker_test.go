@@ -0,0 +1,89 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errBoom = errors.New("boom")
+
+func fail() error    { return errBoom }
+func succeed() error { return nil }
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var transitions []string
+	cb := New(Settings{
+		Name:                "db",
+		FailureThreshold:    3,
+		OpenTimeout:         10 * time.Second,
+		HalfOpenMaxRequests: 2,
+		OnStateChange: func(name string, from, to State) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	cb.now = func() time.Time { return now }
+
+	// Failures below the threshold keep it closed, a success resets the count
+	cb.Execute(fail)
+	cb.Execute(fail)
+	cb.Execute(succeed)
+	cb.Execute(fail)
+	cb.Execute(fail)
+	assert.Equal(t, StateClosed, cb.State())
+
+	// Caller cancellations don't count
+	cb.Execute(func() error { return context.Canceled })
+	assert.Equal(t, StateClosed, cb.State())
+
+	cb.Execute(fail)
+	assert.Equal(t, StateOpen, cb.State())
+
+	// Open rejects without calling
+	called := false
+	err := cb.Execute(func() error { called = true; return nil })
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.False(t, called)
+
+	// After the timeout a failed trial reopens it
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, StateHalfOpen, cb.State())
+	cb.Execute(fail)
+	assert.Equal(t, StateOpen, cb.State())
+
+	// Enough successful trials close it
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, cb.Execute(succeed))
+	assert.Equal(t, StateHalfOpen, cb.State())
+	assert.NoError(t, cb.Execute(succeed))
+	assert.Equal(t, StateClosed, cb.State())
+
+	assert.Equal(t, []string{
+		"closed->open",
+		"open->half_open",
+		"half_open->open",
+		"open->half_open",
+		"half_open->closed",
+	}, transitions)
+}
+
+func TestCircuitBreaker_HalfOpenLimit(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cb := New(Settings{FailureThreshold: 1, OpenTimeout: time.Second, HalfOpenMaxRequests: 1})
+	cb.now = func() time.Time { return now }
+
+	cb.Execute(fail)
+	now = now.Add(time.Second)
+
+	// While one trial call is in flight further calls are rejected
+	err := cb.Execute(func() error {
+		assert.ErrorIs(t, cb.Execute(succeed), ErrOpen)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, StateClosed, cb.State())
+}
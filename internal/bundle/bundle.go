@@ -0,0 +1,335 @@
+// Package bundle exports every campaign with its targeting rules to a versioned JSON bundle and
+// imports bundles into another environment, for environment promotion and disaster recovery
+package bundle
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// Version is the bundle format written by Export, Import reads this version only
+const Version = 1
+
+// Errors returned by Import, nothing is imported then
+var (
+	// ErrConflicts means campaigns of the bundle differ from the stored ones in fields that
+	// can't be changed once created
+	ErrConflicts = errors.New("bundle conflicts with the stored campaigns")
+	// ErrInvalid means campaigns the bundle creates or updates fail validation
+	ErrInvalid = errors.New("bundle holds invalid campaigns")
+)
+
+// PrepareImage checks an image of an imported campaign and returns the URL to store for it, see
+// creative.Validator.Prepare
+type PrepareImage func(ctx context.Context, imageURL string) (string, error)
+
+// Bundle is every campaign of an environment with its targeting rules, sorted by ID
+type Bundle struct {
+	Version    int                        `json:"version"`
+	ExportedAt time.Time                  `json:"exported_at"`
+	Campaigns  []models.CampaignWithRules `json:"campaigns"`
+}
+
+// FieldChanges names the fields of a campaign that differ between the store and a bundle
+type FieldChanges struct {
+	CID    string   `json:"cid"`
+	Fields []string `json:"fields"`
+	// Rules are the rule changes when the rules differ
+	Rules []models.RuleChange `json:"rules,omitempty"`
+}
+
+// Problems are the validation problems of a campaign of a bundle
+type Problems struct {
+	CID    string   `json:"cid"`
+	Errors []string `json:"errors"`
+}
+
+// Diff is what importing a bundle changes, campaign IDs and changes are sorted by ID
+type Diff struct {
+	// Create are the campaigns of the bundle that aren't stored
+	Create []string `json:"create"`
+	// Update are the stored campaigns the bundle changes
+	Update []FieldChanges `json:"update"`
+	// Conflicts are the stored campaigns the bundle changes in fields that can't be updated:
+	// name, cta, starts_at, ends_at and delivery_budget
+	Conflicts []FieldChanges `json:"conflicts,omitempty"`
+	// Invalid are the campaigns to create or update that fail validation, those the bundle
+	// holds unchanged were accepted when stored and aren't checked again
+	Invalid []Problems `json:"invalid,omitempty"`
+	// Unchanged is the number of stored campaigns the bundle holds as they are
+	Unchanged int `json:"unchanged"`
+	// Extra are the stored campaigns missing from the bundle, imports never delete them
+	Extra []string `json:"extra,omitempty"`
+}
+
+// Export returns a bundle of every campaign in store, whatever its status
+func Export(ctx context.Context, store service.CampaignStore, now time.Time) (Bundle, error) {
+	campaigns, err := store.ListCampaigns(ctx)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+	campaigns = slices.Clone(campaigns)
+	slices.SortFunc(campaigns, func(a, b models.CampaignWithRules) int { return cmp.Compare(a.ID, b.ID) })
+	if campaigns == nil {
+		campaigns = []models.CampaignWithRules{}
+	}
+	return Bundle{Version: Version, ExportedAt: now.UTC(), Campaigns: campaigns}, nil
+}
+
+// Validate returns the problems that keep the bundle from being read, the campaigns themselves
+// are validated by Plan and Import
+func (b Bundle) Validate() []error {
+	if b.Version != Version {
+		return []error{fmt.Errorf("unsupported bundle version %d, expected %d", b.Version, Version)}
+	}
+	var problems []error
+	seen := make(map[string]bool, len(b.Campaigns))
+	for i, campaign := range b.Campaigns {
+		switch {
+		case campaign.ID == "":
+			problems = append(problems, fmt.Errorf("campaign %d has no ID", i))
+		case seen[campaign.ID]:
+			problems = append(problems, fmt.Errorf("campaign %s is in the bundle twice", campaign.ID))
+		}
+		seen[campaign.ID] = true
+	}
+	return problems
+}
+
+// Plan returns what importing b into store changes without changing anything, b must be valid
+func Plan(ctx context.Context, store service.CampaignStore, b Bundle) (Diff, error) {
+	stored, err := store.ListCampaigns(ctx)
+	if err != nil {
+		return Diff{}, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+	return plan(stored, b), nil
+}
+
+func plan(stored []models.CampaignWithRules, b Bundle) Diff {
+	byID := make(map[string]models.CampaignWithRules, len(stored))
+	for _, campaign := range stored {
+		byID[campaign.ID] = campaign
+	}
+
+	diff := Diff{Create: []string{}, Update: []FieldChanges{}}
+	inBundle := make(map[string]bool, len(b.Campaigns))
+	for _, campaign := range b.Campaigns {
+		inBundle[campaign.ID] = true
+		if current, ok := byID[campaign.ID]; !ok {
+			diff.Create = append(diff.Create, campaign.ID)
+		} else if conflicts := conflictingFields(current.Campaign, campaign.Campaign); len(conflicts) > 0 {
+			diff.Conflicts = append(diff.Conflicts, FieldChanges{CID: campaign.ID, Fields: conflicts})
+			continue
+		} else if changes := changedFields(current, campaign); len(changes.Fields) > 0 {
+			diff.Update = append(diff.Update, changes)
+		} else {
+			diff.Unchanged++
+			continue
+		}
+		campaign = withRulesOf(campaign)
+		if errs := campaign.Validate(); len(errs) > 0 {
+			problems := Problems{CID: campaign.ID}
+			for _, err := range errs {
+				problems.Errors = append(problems.Errors, err.Error())
+			}
+			diff.Invalid = append(diff.Invalid, problems)
+		}
+	}
+	for _, campaign := range stored {
+		if !inBundle[campaign.ID] {
+			diff.Extra = append(diff.Extra, campaign.ID)
+		}
+	}
+
+	slices.Sort(diff.Create)
+	slices.Sort(diff.Extra)
+	byCID := func(a, b FieldChanges) int { return cmp.Compare(a.CID, b.CID) }
+	slices.SortFunc(diff.Update, byCID)
+	slices.SortFunc(diff.Conflicts, byCID)
+	slices.SortFunc(diff.Invalid, func(a, b Problems) int { return cmp.Compare(a.CID, b.CID) })
+	return diff
+}
+
+// conflictingFields returns the fields of the stored campaign the bundled one changes that
+// the store can't update
+func conflictingFields(stored, bundled models.Campaign) []string {
+	var fields []string
+	if stored.Name != bundled.Name {
+		fields = append(fields, "name")
+	}
+	if stored.CTA != bundled.CTA {
+		fields = append(fields, "cta")
+	}
+	if !equalTimes(stored.StartsAt, bundled.StartsAt) {
+		fields = append(fields, "starts_at")
+	}
+	if !equalTimes(stored.EndsAt, bundled.EndsAt) {
+		fields = append(fields, "ends_at")
+	}
+	if stored.DeliveryBudget != bundled.DeliveryBudget {
+		fields = append(fields, "delivery_budget")
+	}
+	return fields
+}
+
+// changedFields returns the fields of the stored campaign the bundled one changes, rules
+// differing only in IDs and times are the same
+func changedFields(stored, bundled models.CampaignWithRules) FieldChanges {
+	changes := FieldChanges{CID: bundled.ID}
+	if stored.Status != bundled.Status {
+		changes.Fields = append(changes.Fields, "status")
+	}
+	if stored.TrafficPct != bundled.TrafficPct {
+		changes.Fields = append(changes.Fields, "traffic_pct")
+	}
+	if stored.Category != bundled.Category {
+		changes.Fields = append(changes.Fields, "category")
+	}
+	if stored.ImageURL != bundled.ImageURL {
+		changes.Fields = append(changes.Fields, "img")
+	}
+	if !slices.Equal(stored.Creatives, bundled.Creatives) {
+		changes.Fields = append(changes.Fields, "creatives")
+	}
+	if changes.Rules = models.DiffRules(stored.Rules, bundled.Rules); len(changes.Rules) > 0 {
+		changes.Fields = append(changes.Fields, "rules")
+	}
+	return changes
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// Import creates the campaigns of b missing from store and updates the stored ones it changes,
+// returning what changed. Campaigns missing from b are left alone. Rule changes are recorded as
+// new versions by author. When b conflicts with the store or holds invalid campaigns nothing is
+// imported and the diff is returned with ErrConflicts or ErrInvalid, b must be valid. Unless
+// prepare is nil, the images of the campaigns created and the changed images of those updated
+// are prepared with it first, campaigns with images it fails are invalid.
+//
+// Imports aren't atomic, a failed one leaves the campaigns imported before it failed and can
+// be run again.
+func Import(ctx context.Context, store service.CampaignStore, b Bundle, author string, prepare PrepareImage) (Diff, error) {
+	stored, err := store.ListCampaigns(ctx)
+	if err != nil {
+		return Diff{}, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+	diff := plan(stored, b)
+	if len(diff.Conflicts) > 0 {
+		return diff, ErrConflicts
+	}
+	if len(diff.Invalid) > 0 {
+		return diff, ErrInvalid
+	}
+
+	byID := make(map[string]models.CampaignWithRules, len(b.Campaigns))
+	for _, campaign := range b.Campaigns {
+		byID[campaign.ID] = campaign
+	}
+	if prepare != nil {
+		if diff.Invalid = prepareImages(ctx, prepare, byID, diff); len(diff.Invalid) > 0 {
+			return diff, ErrInvalid
+		}
+	}
+	for _, id := range diff.Create {
+		if err := store.CreateCampaign(ctx, withRulesOf(byID[id])); err != nil {
+			return diff, fmt.Errorf("failed to create campaign %s: %w", id, err)
+		}
+	}
+	for _, changes := range diff.Update {
+		if err := update(ctx, store, byID[changes.CID], changes.Fields, author); err != nil {
+			return diff, fmt.Errorf("failed to update campaign %s: %w", changes.CID, err)
+		}
+	}
+	return diff, nil
+}
+
+// prepareImages prepares the images of the campaigns diff creates and the changed images of those
+// it updates, replacing the campaigns in byID, and returns the campaigns with images it fails
+func prepareImages(ctx context.Context, prepare PrepareImage, byID map[string]models.CampaignWithRules, diff Diff) []Problems {
+	var invalid []Problems
+	prepareCampaign := func(id string, image, creatives bool) {
+		campaign := byID[id]
+		problems := Problems{CID: id}
+		if image {
+			img, err := prepare(ctx, campaign.ImageURL)
+			if err != nil {
+				problems.Errors = append(problems.Errors, err.Error())
+			}
+			campaign.ImageURL = img
+		}
+		if creatives {
+			campaign.Creatives = slices.Clone(campaign.Creatives)
+			for i := range campaign.Creatives {
+				img, err := prepare(ctx, campaign.Creatives[i].Img)
+				if err != nil {
+					problems.Errors = append(problems.Errors, fmt.Sprintf("creative %d: %v", i, err))
+				}
+				campaign.Creatives[i].Img = img
+			}
+		}
+		if len(problems.Errors) > 0 {
+			invalid = append(invalid, problems)
+		}
+		byID[id] = campaign
+	}
+
+	for _, id := range diff.Create {
+		prepareCampaign(id, true, true)
+	}
+	for _, changes := range diff.Update {
+		prepareCampaign(changes.CID, slices.Contains(changes.Fields, "img"), slices.Contains(changes.Fields, "creatives"))
+	}
+	slices.SortFunc(invalid, func(a, b Problems) int { return cmp.Compare(a.CID, b.CID) })
+	return invalid
+}
+
+// withRulesOf returns campaign with its rules pointing at it
+func withRulesOf(campaign models.CampaignWithRules) models.CampaignWithRules {
+	campaign.Rules = slices.Clone(campaign.Rules)
+	for i := range campaign.Rules {
+		campaign.Rules[i].CampaignID = campaign.ID
+	}
+	return campaign
+}
+
+// update sets the fields of the stored campaign to those of campaign
+func update(ctx context.Context, store service.CampaignStore, campaign models.CampaignWithRules, fields []string, author string) error {
+	for _, field := range fields {
+		var err error
+		switch field {
+		case "status":
+			err = store.SetCampaignStatus(ctx, campaign.ID, campaign.Status)
+		case "traffic_pct":
+			err = store.SetCampaignTrafficPct(ctx, campaign.ID, campaign.TrafficPct)
+		case "category":
+			err = store.SetCampaignCategory(ctx, campaign.ID, campaign.Category)
+		case "img":
+			err = store.SetCampaignImage(ctx, campaign.ID, campaign.ImageURL)
+		case "creatives":
+			err = store.SetCampaignCreatives(ctx, campaign.ID, campaign.Creatives)
+		case "rules":
+			_, err = store.SetCampaignRules(ctx, models.RuleSetVersion{
+				CampaignID: campaign.ID,
+				Rules:      withRulesOf(campaign).Rules,
+				Author:     author,
+				Comment:    "imported from bundle",
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
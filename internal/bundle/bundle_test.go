@@ -0,0 +1,199 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStore returns a mock store whose campaigns are all valid, the mock spells Canada out
+func newStore(t *testing.T) service.CampaignStore {
+	store := repository.NewMockRepository().(service.CampaignStore)
+	_, err := store.SetCampaignRules(context.Background(), models.RuleSetVersion{CampaignID: "spotify", Rules: []models.TargetingRule{
+		{CampaignID: "spotify", Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"US", "CA"}},
+	}})
+	require.NoError(t, err)
+	return store
+}
+
+// exportJSON exports store and reads the bundle back the way it travels between environments
+func exportJSON(t *testing.T, store service.CampaignStore) Bundle {
+	exported, err := Export(context.Background(), store, time.Now())
+	require.NoError(t, err)
+	content, err := json.Marshal(exported)
+	require.NoError(t, err)
+	var b Bundle
+	require.NoError(t, json.Unmarshal(content, &b))
+	return b
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	target := newStore(t)
+
+	b := exportJSON(t, source)
+	assert.Equal(t, Version, b.Version)
+	require.Len(t, b.Campaigns, 3)
+	assert.Equal(t, "duolingo", b.Campaigns[0].ID)
+	assert.Empty(t, b.Validate())
+
+	// Identical environments differ in nothing but rule IDs and times
+	diff, err := Plan(ctx, target, b)
+	require.NoError(t, err)
+	assert.Equal(t, Diff{Create: []string{}, Update: []FieldChanges{}, Unchanged: 3}, diff)
+
+	b.Campaigns[0].Status = models.StatusInactive
+	b.Campaigns[0].Category = "education"
+	b.Campaigns[0].Creatives = []models.Creative{{Lang: "es", CTA: "Aprender"}}
+	b.Campaigns[1].Rules = []models.TargetingRule{{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"US"}}}
+	b.Campaigns = append(b.Campaigns, models.CampaignWithRules{Campaign: models.Campaign{
+		ID: "netflix", Name: "Netflix", CTA: "Watch", Status: models.StatusActive, TrafficPct: 10,
+	}})
+	require.NoError(t, target.SetCampaignStatus(ctx, "subwaysurfer", models.StatusInactive))
+	require.NoError(t, target.CreateCampaign(ctx, models.CampaignWithRules{Campaign: models.Campaign{ID: "local", Name: "Local", CTA: "Go"}}))
+
+	want := Diff{
+		Create: []string{"netflix"},
+		Update: []FieldChanges{
+			{CID: "duolingo", Fields: []string{"status", "category", "creatives"}},
+			{CID: "spotify", Fields: []string{"rules"}, Rules: []models.RuleChange{
+				{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Removed: []string{"CA"}},
+			}},
+			{CID: "subwaysurfer", Fields: []string{"status"}},
+		},
+		Extra: []string{"local"},
+	}
+	diff, err = Plan(ctx, target, b)
+	require.NoError(t, err)
+	assert.Equal(t, want, diff)
+
+	diff, err = Import(ctx, target, b, "alice", nil)
+	require.NoError(t, err)
+	assert.Equal(t, want, diff)
+
+	// Importing again changes nothing, campaigns missing from the bundle are kept
+	diff, err = Plan(ctx, target, b)
+	require.NoError(t, err)
+	assert.Equal(t, Diff{Create: []string{}, Update: []FieldChanges{}, Unchanged: 4, Extra: []string{"local"}}, diff)
+
+	versions, err := target.ListRuleVersions(ctx, "spotify")
+	require.NoError(t, err)
+	assert.Equal(t, 3, versions[0].Version)
+	assert.Equal(t, "alice", versions[0].Author)
+	assert.Equal(t, "spotify", versions[0].Rules[0].CampaignID)
+}
+
+func TestImportConflicts(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	b := exportJSON(t, store)
+	b.Campaigns[0].Status = models.StatusInactive
+	b.Campaigns[1].Name = "Spotify Premium"
+	endsAt := time.Now().Add(time.Hour)
+	b.Campaigns[1].EndsAt = &endsAt
+
+	diff, err := Import(ctx, store, b, "", nil)
+	assert.ErrorIs(t, err, ErrConflicts)
+	assert.Equal(t, []FieldChanges{{CID: "spotify", Fields: []string{"name", "ends_at"}}}, diff.Conflicts)
+
+	// Nothing was imported, not even the campaigns without conflicts
+	campaigns, err := store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	for _, campaign := range campaigns {
+		assert.Equal(t, models.StatusActive, campaign.Status, campaign.ID)
+	}
+}
+
+func TestBundleValidate(t *testing.T) {
+	campaign := models.CampaignWithRules{Campaign: models.Campaign{ID: "netflix", Name: "Netflix", CTA: "Watch", Status: models.StatusActive}}
+
+	assert.Empty(t, Bundle{Version: Version, Campaigns: []models.CampaignWithRules{campaign}}.Validate())
+	assert.Len(t, Bundle{Version: 2}.Validate(), 1)
+	assert.Len(t, Bundle{Version: Version, Campaigns: []models.CampaignWithRules{campaign, campaign}}.Validate(), 1)
+	assert.Len(t, Bundle{Version: Version, Campaigns: []models.CampaignWithRules{{}}}.Validate(), 1)
+}
+
+func TestImportInvalid(t *testing.T) {
+	ctx := context.Background()
+	// The mock stores Canada spelled out, which campaigns created now can't be
+	store := repository.NewMockRepository().(service.CampaignStore)
+
+	b := exportJSON(t, store)
+	diff, err := Plan(ctx, store, b)
+	require.NoError(t, err)
+	assert.Empty(t, diff.Invalid, "unchanged campaigns aren't validated again")
+
+	b.Campaigns[1].Status = models.StatusInactive
+	b.Campaigns = append(b.Campaigns, models.CampaignWithRules{Campaign: models.Campaign{ID: "netflix", Status: models.StatusActive}})
+	diff, err = Import(ctx, store, b, "", nil)
+	assert.ErrorIs(t, err, ErrInvalid)
+	require.Len(t, diff.Invalid, 2)
+	assert.Equal(t, "netflix", diff.Invalid[0].CID)
+	assert.Equal(t, "spotify", diff.Invalid[1].CID)
+	assert.Contains(t, diff.Invalid[1].Errors[0], "Canada")
+
+	campaigns, err := store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 3)
+}
+
+func TestImportPreparesImages(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	var prepared []string
+	prepare := func(ctx context.Context, imageURL string) (string, error) {
+		prepared = append(prepared, imageURL)
+		if strings.HasPrefix(imageURL, "http://") {
+			return "", errors.New("img must be an https URL")
+		}
+		return "https://cdn.example.com/" + strings.TrimPrefix(imageURL, "https://"), nil
+	}
+
+	b := exportJSON(t, store)
+	b.Campaigns[0].Status = models.StatusInactive
+	b.Campaigns[1].ImageURL = "https://img.example.com/spotify.png"
+	b.Campaigns = append(b.Campaigns, models.CampaignWithRules{Campaign: models.Campaign{
+		ID: "netflix", Name: "Netflix", CTA: "Watch", Status: models.StatusActive, TrafficPct: 10,
+		ImageURL: "http://img.example.com/netflix.png",
+	}})
+
+	// Nothing is imported while an image fails, unchanged images aren't prepared
+	diff, err := Import(ctx, store, b, "", prepare)
+	assert.ErrorIs(t, err, ErrInvalid)
+	require.Len(t, diff.Invalid, 1)
+	assert.Equal(t, "netflix", diff.Invalid[0].CID)
+	assert.Equal(t, []string{"http://img.example.com/netflix.png", "https://img.example.com/spotify.png"}, prepared)
+	campaigns, err := store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 3)
+
+	b.Campaigns[3].ImageURL = "https://img.example.com/netflix.png"
+	b.Campaigns[3].Creatives = []models.Creative{{Lang: "es", Img: "https://img.example.com/netflix-es.png", CTA: "Mirar"}}
+	_, err = Import(ctx, store, b, "", prepare)
+	require.NoError(t, err)
+
+	campaigns, err = store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	images := make(map[string]string)
+	for _, campaign := range campaigns {
+		images[campaign.ID] = campaign.ImageURL
+		if campaign.ID == "netflix" {
+			assert.Equal(t, "https://cdn.example.com/img.example.com/netflix-es.png", campaign.Creatives[0].Img)
+		}
+	}
+	assert.Equal(t, "https://cdn.example.com/img.example.com/netflix.png", images["netflix"])
+	assert.Equal(t, "https://cdn.example.com/img.example.com/spotify.png", images["spotify"])
+	// The bundle itself is left as it was
+	assert.Equal(t, "https://img.example.com/netflix-es.png", b.Campaigns[3].Creatives[0].Img)
+}
@@ -2,10 +2,13 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/prajwalbharadwajbm/adbeacon/internal/hedge"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 )
 
@@ -39,12 +42,15 @@ type CacheStats struct {
 
 // CacheHealth represents comprehensive cache health information
 type CacheHealth struct {
-	Overall  string            `json:"overall"` // "healthy", "degraded", "unhealthy"
-	Memory   MemoryCacheHealth `json:"memory"`
-	Redis    RedisCacheHealth  `json:"redis"`
-	Stats    CacheStats        `json:"stats"`
-	Uptime   time.Duration     `json:"uptime"`
-	LastTest time.Time         `json:"last_test"`
+	Overall string            `json:"overall"` // "healthy", "degraded", "unhealthy"
+	Region  string            `json:"region,omitempty"`
+	Memory  MemoryCacheHealth `json:"memory"`
+	Redis   RedisCacheHealth  `json:"redis"`
+	// GlobalRedis is the health of the global tier, when there is one
+	GlobalRedis *RedisCacheHealth `json:"global_redis,omitempty"`
+	Stats       CacheStats        `json:"stats"`
+	Uptime      time.Duration     `json:"uptime"`
+	LastTest    time.Time         `json:"last_test"`
 }
 
 // MemoryCacheHealth represents in-memory cache health
@@ -65,6 +71,17 @@ type RedisCacheHealth struct {
 	Address   string        `json:"address"`
 	Latency   time.Duration `json:"latency"` // Ping latency
 	Error     string        `json:"error,omitempty"`
+	// Shards is the health of each shard when keys are sharded over several servers
+	Shards []RedisShardHealth `json:"shards,omitempty"`
+}
+
+// RedisShardHealth represents the health of one Redis shard
+type RedisShardHealth struct {
+	Address   string        `json:"address"`
+	Status    string        `json:"status"` // "healthy", "degraded", "unhealthy"
+	Connected bool          `json:"connected"`
+	Latency   time.Duration `json:"latency"`
+	Error     string        `json:"error,omitempty"`
 }
 
 // HybridCache implements both in-memory and Redis caching
@@ -74,6 +91,8 @@ type HybridCache struct {
 	memoryCache *memoryCache
 	// Redis cache for shared state
 	redisCache *redisCache
+	// Global Redis tier shared by all regions, writes and invalidations fan out to it
+	globalCache *redisCache
 	// Configuration
 	config CacheConfig
 	// Metrics
@@ -81,6 +100,8 @@ type HybridCache struct {
 	mu    sync.RWMutex
 	// Add startTime tracking to HybridCache
 	startTime time.Time
+	// hedgeRecorder records hedged Redis reads, optional
+	hedgeRecorder hedge.Recorder
 }
 
 // CacheConfig holds cache configuration
@@ -90,9 +111,68 @@ type CacheConfig struct {
 	RedisAddr       string
 	RedisPassword   string
 	RedisDB         int
+	// RedisClientName is set on every Redis connection so they show up in CLIENT LIST
+	RedisClientName string
+	// RedisMaxRetries is the number of retries of failed Redis commands with jittered backoff,
+	// 0 uses the go-redis default of 3 and -1 disables retries
+	RedisMaxRetries int
+	// RedisHedgeDelay sends a second Redis read when the first hasn't returned within it, 0 disables hedging
+	RedisHedgeDelay time.Duration
+	// RedisTLS connects to Redis over TLS
+	RedisTLS        bool
 	EnableMemory    bool
 	EnableRedis     bool
 	RefreshInterval time.Duration
+	// LoadBatchSize is the number of campaigns loaded from the database at once when the cache is
+	// rebuilt, bounding the rows held while loading. 0 loads them in a single query.
+	LoadBatchSize int
+	// Region labels the region the replica runs in, it is added to the Redis client name
+	Region string
+	// GlobalRedisAddr is the Redis shared by all regions, empty for a single tier. Reads stay
+	// on the regional Redis at RedisAddr, writes and invalidations go to both.
+	GlobalRedisAddr     string
+	GlobalRedisPassword string
+	// PersistPath is the file the active campaigns are persisted to every PersistInterval for
+	// fast cold starts, empty disables persistence. Persisted campaigns older than
+	// PersistMaxAge aren't loaded.
+	PersistPath     string
+	PersistInterval time.Duration
+	PersistMaxAge   time.Duration
+	// KeyPrefix namespaces the adbeacon keys, so environments or tenants can share a Redis
+	// server. Empty uses DefaultKeyPrefix.
+	KeyPrefix string
+	// RedisShardAddrs shards the cache keys over these servers with client-side consistent
+	// hashing instead of RedisAddr, for deployments without Redis Cluster
+	RedisShardAddrs []string
+}
+
+// DefaultKeyPrefix is the Redis key prefix of a configuration without one
+const DefaultKeyPrefix = "adbeacon:"
+
+// RedisKeyPrefix returns the prefix of every Redis key adbeacon writes
+func (c CacheConfig) RedisKeyPrefix() string {
+	if c.KeyPrefix == "" {
+		return DefaultKeyPrefix
+	}
+	return c.KeyPrefix
+}
+
+// redisAddress returns the address of the regional Redis, or of its shards separated by commas
+func (c CacheConfig) redisAddress() string {
+	if len(c.RedisShardAddrs) > 0 {
+		return strings.Join(c.RedisShardAddrs, ",")
+	}
+	return c.RedisAddr
+}
+
+// globalTier returns the configuration of the global Redis tier
+func (c CacheConfig) globalTier() CacheConfig {
+	global := c
+	global.RedisAddr = c.GlobalRedisAddr
+	global.RedisPassword = c.GlobalRedisPassword
+	global.RedisDB = 0
+	global.RedisShardAddrs = nil
+	return global
 }
 
 // NewHybridCache creates a new hybrid cache
@@ -117,11 +197,23 @@ func NewHybridCache(config CacheConfig) (*HybridCache, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize Redis cache: %w", err)
 		}
+		if config.GlobalRedisAddr != "" {
+			hc.globalCache, err = newRedisCache(config.globalTier())
+			if err != nil {
+				hc.redisCache.close()
+				return nil, fmt.Errorf("failed to initialize global Redis cache: %w", err)
+			}
+		}
 	}
 
 	return hc, nil
 }
 
+// SetHedgeRecorder sets the recorder notified of hedged Redis reads
+func (hc *HybridCache) SetHedgeRecorder(recorder hedge.Recorder) {
+	hc.hedgeRecorder = recorder
+}
+
 // GetActiveCampaigns retrieves campaigns from cache (memory first, then Redis, then miss)
 func (hc *HybridCache) GetActiveCampaigns(ctx context.Context) ([]models.CampaignWithRules, error) {
 	// Try memory cache first
@@ -134,7 +226,8 @@ func (hc *HybridCache) GetActiveCampaigns(ctx context.Context) ([]models.Campaig
 
 	// Try Redis cache
 	if hc.redisCache != nil {
-		campaigns, err := hc.redisCache.getActiveCampaigns(ctx)
+		campaigns, winner, err := hedge.Do(ctx, hc.config.RedisHedgeDelay, hc.redisCache.getActiveCampaigns)
+		hc.recordHedge(winner)
 		if err == nil {
 			hc.recordHit()
 			// Warm memory cache
@@ -159,8 +252,8 @@ func (hc *HybridCache) SetActiveCampaigns(ctx context.Context, campaigns []model
 	}
 
 	// Store in Redis cache
-	if hc.redisCache != nil {
-		if err := hc.redisCache.setActiveCampaigns(ctx, campaigns, ttl); err != nil {
+	for _, rc := range hc.redisTiers() {
+		if err := rc.setActiveCampaigns(ctx, campaigns, ttl); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -187,7 +280,10 @@ func (hc *HybridCache) GetCampaignIndex(ctx context.Context, dimension models.Ta
 
 	// Try Redis cache
 	if hc.redisCache != nil {
-		campaignIDs, err := hc.redisCache.getCampaignIndex(ctx, key)
+		campaignIDs, winner, err := hedge.Do(ctx, hc.config.RedisHedgeDelay, func(ctx context.Context) ([]string, error) {
+			return hc.redisCache.getCampaignIndex(ctx, key)
+		})
+		hc.recordHedge(winner)
 		if err == nil {
 			hc.recordHit()
 			// Warm memory cache
@@ -213,8 +309,8 @@ func (hc *HybridCache) SetCampaignIndex(ctx context.Context, dimension models.Ta
 	}
 
 	// Store in Redis cache
-	if hc.redisCache != nil {
-		if err := hc.redisCache.setCampaignIndex(ctx, key, campaignIDs, ttl); err != nil {
+	for _, rc := range hc.redisTiers() {
+		if err := rc.setCampaignIndex(ctx, key, campaignIDs, ttl); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -236,9 +332,12 @@ func (hc *HybridCache) InvalidateAll(ctx context.Context) error {
 		hc.memoryCache.clear()
 	}
 
-	// Clear Redis cache
-	if hc.redisCache != nil {
-		if err := hc.redisCache.clear(ctx); err != nil {
+	// Clear Redis cache, outdating the campaigns persisted to disk first
+	for _, rc := range hc.redisTiers() {
+		if err := rc.bumpGeneration(ctx); err != nil {
+			errs = append(errs, err)
+		}
+		if err := rc.clear(ctx); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -250,6 +349,72 @@ func (hc *HybridCache) InvalidateAll(ctx context.Context) error {
 	return nil
 }
 
+// ErrNoGeneration is returned by Generation without Redis
+var ErrNoGeneration = errors.New("campaign generation requires Redis")
+
+// Generation returns the campaign generation, which changes whenever the campaigns are
+// invalidated on any replica. When Redis lost it, e.g. after a restart, a new generation is
+// started that matches no campaigns persisted before.
+func (hc *HybridCache) Generation(ctx context.Context) (int64, error) {
+	if hc.redisCache == nil {
+		return 0, ErrNoGeneration
+	}
+	generation, err := hc.redisCache.getGeneration(ctx)
+	if errors.Is(err, ErrCacheMiss) {
+		if err := hc.redisCache.initGeneration(ctx); err != nil {
+			return 0, err
+		}
+		generation, err = hc.redisCache.getGeneration(ctx)
+	}
+	return generation, err
+}
+
+// PublishInvalidation tells the other replicas sharing Redis that the campaigns changed, so they
+// drop their in-memory copies. With a global tier every region hears it there. Without Redis
+// there is nobody to tell.
+func (hc *HybridCache) PublishInvalidation(ctx context.Context) error {
+	rc := hc.invalidationTier()
+	if rc == nil {
+		return nil
+	}
+	return rc.publishCacheInvalidation(ctx, "campaigns")
+}
+
+// SubscribeInvalidations clears the memory cache and calls onInvalidate whenever a replica
+// publishes an invalidation, until ctx is done. Without Redis it returns right away.
+func (hc *HybridCache) SubscribeInvalidations(ctx context.Context, onInvalidate func()) error {
+	rc := hc.invalidationTier()
+	if rc == nil {
+		return nil
+	}
+	return rc.subscribeCacheInvalidation(ctx, func(string) {
+		if hc.memoryCache != nil {
+			hc.memoryCache.clear()
+		}
+		onInvalidate()
+	})
+}
+
+// redisTiers returns the Redis tiers writes go to, the regional one first
+func (hc *HybridCache) redisTiers() []*redisCache {
+	var tiers []*redisCache
+	if hc.redisCache != nil {
+		tiers = append(tiers, hc.redisCache)
+	}
+	if hc.globalCache != nil {
+		tiers = append(tiers, hc.globalCache)
+	}
+	return tiers
+}
+
+// invalidationTier returns the Redis invalidations are published on, the one all replicas share
+func (hc *HybridCache) invalidationTier() *redisCache {
+	if hc.globalCache != nil {
+		return hc.globalCache
+	}
+	return hc.redisCache
+}
+
 // GetStats returns cache statistics
 func (hc *HybridCache) GetStats() CacheStats {
 	hc.mu.RLock()
@@ -283,6 +448,16 @@ func (hc *HybridCache) recordError() {
 	hc.mu.Unlock()
 }
 
+// recordHedge reports a hedged Redis read, winner is empty when no hedge was sent
+func (hc *HybridCache) recordHedge(winner string) {
+	if winner != "" && hc.hedgeRecorder != nil {
+		hc.hedgeRecorder.RecordHedgedRead(HedgeTargetRedis, winner)
+	}
+}
+
+// HedgeTargetRedis is the hedge metric target of Redis reads
+const HedgeTargetRedis = "redis"
+
 // Custom errors
 var (
 	ErrCacheMiss = fmt.Errorf("cache miss")
@@ -295,6 +470,7 @@ func (hc *HybridCache) HealthCheck(ctx context.Context) CacheHealth {
 	hc.mu.RUnlock()
 
 	health := CacheHealth{
+		Region:   hc.config.Region,
 		Stats:    hc.GetStats(),
 		Uptime:   time.Since(startTime),
 		LastTest: time.Now(),
@@ -304,11 +480,20 @@ func (hc *HybridCache) HealthCheck(ctx context.Context) CacheHealth {
 	health.Memory = hc.checkMemoryHealth()
 
 	// Check Redis cache health
-	health.Redis = hc.checkRedisHealth(ctx)
+	health.Redis = hc.checkRedisHealth(ctx, hc.redisCache, hc.config.redisAddress())
 
 	// Determine overall health
 	health.Overall = hc.determineOverallHealth(health.Memory, health.Redis)
 
+	// Reads don't depend on the global tier, losing it only degrades the cache
+	if hc.globalCache != nil {
+		global := hc.checkRedisHealth(ctx, hc.globalCache, hc.config.GlobalRedisAddr)
+		health.GlobalRedis = &global
+		if global.Status != "healthy" && health.Overall == "healthy" {
+			health.Overall = "degraded"
+		}
+	}
+
 	return health
 }
 
@@ -344,30 +529,44 @@ func (hc *HybridCache) checkMemoryHealth() MemoryCacheHealth {
 	return health
 }
 
-// checkRedisHealth evaluates Redis cache health
-func (hc *HybridCache) checkRedisHealth(ctx context.Context) RedisCacheHealth {
+// checkRedisHealth evaluates the health of the Redis tier rc at addr
+func (hc *HybridCache) checkRedisHealth(ctx context.Context, rc *redisCache, addr string) RedisCacheHealth {
 	health := RedisCacheHealth{
 		Enabled:   hc.config.EnableRedis,
 		Status:    "unhealthy",
 		Connected: false,
-		Address:   hc.config.RedisAddr,
+		Address:   addr,
 	}
 
-	if !hc.config.EnableRedis || hc.redisCache == nil {
+	if !hc.config.EnableRedis || rc == nil {
 		health.Status = "disabled"
 		return health
 	}
 
-	// Test Redis connection with ping
-	start := time.Now()
-	err := hc.redisCache.healthCheck(ctx)
-	health.Latency = time.Since(start)
+	// Test Redis connection with ping, the cache is as slow as its slowest shard
+	shards := rc.checkShards(ctx)
+	var up []RedisShardHealth
+	for _, shard := range shards {
+		health.Latency = max(health.Latency, shard.Latency)
+		if shard.Connected {
+			up = append(up, shard)
+		}
+	}
+	if len(shards) > 1 {
+		health.Shards = shards
+	}
 
-	if err != nil {
+	switch {
+	case len(up) == 0:
 		health.Status = "unhealthy"
 		health.Connected = false
-		health.Error = err.Error()
-	} else {
+		health.Error = shards[0].Error
+	case len(up) < len(shards):
+		// Keys of the shards down miss, the others are still served
+		health.Status = "degraded"
+		health.Connected = true
+		health.Error = fmt.Sprintf("%d of %d shards down", len(shards)-len(up), len(shards))
+	default:
 		health.Status = "healthy"
 		health.Connected = true
 
@@ -433,8 +632,8 @@ func (hc *HybridCache) Close() error {
 	}
 
 	// Close Redis cache
-	if hc.redisCache != nil {
-		if err := hc.redisCache.close(); err != nil {
+	for _, rc := range hc.redisTiers() {
+		if err := rc.close(); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -3,10 +3,12 @@ package cache
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -317,3 +319,34 @@ func TestHybridCache_HealthCheck_WithData(t *testing.T) {
 	assert.True(t, health.Memory.UtilPct > 50) // Should be fairly utilized
 	assert.True(t, health.Memory.Size > 0)
 }
+
+func TestHybridCache_Collector(t *testing.T) {
+	config := CacheConfig{
+		DefaultTTL:      time.Minute,
+		MemoryCacheSize: 100,
+		EnableMemory:    true,
+		EnableRedis:     false,
+	}
+
+	cache, err := NewHybridCache(config)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, _ = cache.GetActiveCampaigns(ctx) // miss
+	require.NoError(t, cache.SetActiveCampaigns(ctx, []models.CampaignWithRules{}, time.Minute))
+	_, _ = cache.GetActiveCampaigns(ctx) // hit
+
+	// Redis is disabled so only stats and memory metrics are exported
+	assert.Equal(t, 7, testutil.CollectAndCount(cache))
+
+	expected := `
+# HELP adbeacon_cache_hits_total Total number of cache hits
+# TYPE adbeacon_cache_hits_total counter
+adbeacon_cache_hits_total 1
+# HELP adbeacon_cache_memory_items Current number of items in the in-memory cache
+# TYPE adbeacon_cache_memory_items gauge
+adbeacon_cache_memory_items 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(cache, strings.NewReader(expected),
+		"adbeacon_cache_hits_total", "adbeacon_cache_memory_items"))
+}
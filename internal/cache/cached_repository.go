@@ -2,27 +2,87 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"golang.org/x/sync/singleflight"
 )
 
 // CachedRepository wraps a repository with caching capabilities
 type CachedRepository struct {
 	repo  service.CampaignRepository
 	cache Cache
-	ttl   time.Duration
+	// ttl is a time.Duration, changeable at runtime through SetTTL
+	ttl atomic.Int64
+	// loadBatchSize is the number of campaigns loaded from the repository at once, see
+	// SetLoadBatchSize
+	loadBatchSize atomic.Int64
+
+	// stale holds the last campaigns seen, served when both cache and repository fail
+	stale atomic.Pointer[[]models.CampaignWithRules]
+	// onStale is called with the repository error whenever stale campaigns are served
+	onStale func(err error)
+
+	// limits are enforced on the campaigns loaded from the repository, see SetLimits
+	limits           service.CampaignLimits
+	onLimitsExceeded func(err *service.LimitError)
+
+	// pending tracks asynchronous cache writes so shutdown can wait for them
+	pending sync.WaitGroup
+
+	// snapshot holds the compiled and indexed campaigns served to delivery requests, see
+	// RunSnapshotRefresh
+	snapshot atomic.Pointer[campaignSnapshot]
+	// rebuild shares a single snapshot rebuild between the requests and refreshes needing one
+	rebuild singleflight.Group
 }
 
 // NewCachedRepository creates a new cached repository
 func NewCachedRepository(repo service.CampaignRepository, cache Cache, ttl time.Duration) service.CampaignRepository {
-	return &CachedRepository{
-		repo:  repo,
-		cache: cache,
-		ttl:   ttl,
+	return NewCachedRepositoryWithStaleHandler(repo, cache, ttl, nil)
+}
+
+// NewCachedRepositoryWithStaleHandler creates a new cached repository that calls onStale
+// whenever it serves stale campaigns because the repository failed
+func NewCachedRepositoryWithStaleHandler(repo service.CampaignRepository, cache Cache, ttl time.Duration, onStale func(err error)) *CachedRepository {
+	cr := &CachedRepository{
+		repo:    repo,
+		cache:   cache,
+		onStale: onStale,
 	}
+	cr.SetTTL(ttl)
+	return cr
+}
+
+// TTL returns how long campaigns are cached
+func (cr *CachedRepository) TTL() time.Duration {
+	return time.Duration(cr.ttl.Load())
+}
+
+// SetTTL changes how long campaigns are cached, entries already cached keep their TTL
+func (cr *CachedRepository) SetTTL(ttl time.Duration) {
+	cr.ttl.Store(int64(ttl))
+}
+
+// SetLoadBatchSize loads campaigns from repositories implementing service.CampaignStreamer in
+// batches of n, so a load holds the scanned rows of one batch besides the campaigns loaded so far.
+// 0 loads them in a single batch.
+func (cr *CachedRepository) SetLoadBatchSize(n int) {
+	cr.loadBatchSize.Store(int64(n))
+}
+
+// SetLimits drops the campaigns loaded from the repository that exceed limits: campaigns with
+// too many rules or rule values, and the campaigns beyond the maximum number of active campaigns
+// in the order of the repository, i.e. those changed least recently. onExceeded is called with
+// each limit exceeded when not nil. SetLimits must be called before the repository is used.
+func (cr *CachedRepository) SetLimits(limits service.CampaignLimits, onExceeded func(err *service.LimitError)) {
+	cr.limits = limits
+	cr.onLimitsExceeded = onExceeded
 }
 
 // GetActiveCampaignsWithRules retrieves campaigns from cache first, then database
@@ -30,22 +90,31 @@ func (cr *CachedRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]
 	// Try cache first
 	campaigns, err := cr.cache.GetActiveCampaigns(ctx)
 	if err == nil {
+		cr.stale.Store(&campaigns)
 		return campaigns, nil
 	}
 
 	// If cache miss, get from database
-	campaigns, err = cr.repo.GetActiveCampaignsWithRules(ctx)
+	campaigns, stale, err := cr.loadFromRepository(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	// Don't cache stale campaigns, the next miss should try the database again
+	if stale {
+		return campaigns, nil
+	}
+
 	// Store in cache for next time (async to not block the response)
+	cr.pending.Add(1)
 	go func() {
+		defer cr.pending.Done()
+
 		// Use a new context to avoid timeout issues
 		cacheCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		if err := cr.cache.SetActiveCampaigns(cacheCtx, campaigns, cr.ttl); err != nil {
+		if err := cr.cache.SetActiveCampaigns(cacheCtx, campaigns, cr.TTL()); err != nil {
 			// Log error but don't fail the request
 			fmt.Printf("Failed to cache campaigns: %v\n", err)
 		}
@@ -75,6 +144,24 @@ func (cr *CachedRepository) GetCampaignsByRequest(ctx context.Context, req model
 	return []models.CampaignWithRules{}, nil
 }
 
+// GetCompiledCampaignsByRequest is GetCampaignsByRequest returning the campaigns compiled for
+// matching, implementing service.CompiledCampaignRepository. Campaigns come from the snapshot
+// without going through the cache. Requests only wait for it to be built after startup, a reset
+// or expired snapshot keeps being served while a single rebuild runs in the background.
+func (cr *CachedRepository) GetCompiledCampaignsByRequest(ctx context.Context, req models.DeliveryRequest) ([]*models.CompiledCampaign, error) {
+	snapshot := cr.snapshot.Load()
+	if snapshot == nil {
+		var err error
+		if snapshot, err = cr.awaitSnapshot(ctx); err != nil {
+			return nil, err
+		}
+	} else if snapshot.expired(time.Now(), cr.TTL()) {
+		// Rebuild errors are left to RunSnapshotRefresh, the request is served either way
+		cr.startSnapshotRebuild(ctx)
+	}
+	return snapshot.candidates(req), nil
+}
+
 // getCandidateIDs retrieves campaign IDs that match the request using indexes
 func (cr *CachedRepository) getCandidateIDs(ctx context.Context, req models.DeliveryRequest) ([]string, error) {
 	var candidateSets [][]string
@@ -123,7 +210,7 @@ func (cr *CachedRepository) getCampaignsByIDs(ctx context.Context, campaignIDs [
 	allCampaigns, err := cr.cache.GetActiveCampaigns(ctx)
 	if err != nil {
 		// If cache miss, get from database
-		allCampaigns, err = cr.repo.GetActiveCampaignsWithRules(ctx)
+		allCampaigns, _, err = cr.loadFromRepository(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -145,6 +232,91 @@ func (cr *CachedRepository) getCampaignsByIDs(ctx context.Context, campaignIDs [
 	return filteredCampaigns, nil
 }
 
+// loadFromRepository loads campaigns from the repository, falling back to the last
+// campaigns seen when it fails (e.g. while the database circuit breaker is open)
+// The returned bool reports whether the campaigns are stale
+func (cr *CachedRepository) loadFromRepository(ctx context.Context) ([]models.CampaignWithRules, bool, error) {
+	campaigns, err := cr.loadCampaigns(ctx)
+	if err == nil {
+		cr.stale.Store(&campaigns)
+		return campaigns, false, nil
+	}
+
+	stale := cr.stale.Load()
+	if stale == nil {
+		return nil, false, err
+	}
+
+	if cr.onStale != nil {
+		cr.onStale(err)
+	}
+	return *stale, true, nil
+}
+
+// loadCampaigns loads the active campaigns from the repository in batches of loadBatchSize
+// within the limits, partially loaded campaigns are dropped when the load fails
+func (cr *CachedRepository) loadCampaigns(ctx context.Context) ([]models.CampaignWithRules, error) {
+	batchSize := int(cr.loadBatchSize.Load())
+
+	// Size the result like the last load, so growing it doesn't copy the campaigns loaded so far
+	var campaigns []models.CampaignWithRules
+	if last := cr.stale.Load(); last != nil && batchSize > 0 {
+		campaigns = make([]models.CampaignWithRules, 0, len(*last))
+	}
+	// Campaigns beyond the limit are still counted, to report how many there are
+	active := 0
+	err := service.StreamActiveCampaigns(ctx, cr.repo, batchSize, func(batch []models.CampaignWithRules) error {
+		batch = cr.withinRuleLimits(batch)
+		active += len(batch)
+		if maxActive := cr.limits.MaxActiveCampaigns; maxActive > 0 && len(campaigns)+len(batch) > maxActive {
+			batch = batch[:max(maxActive-len(campaigns), 0)]
+		}
+
+		if campaigns == nil {
+			campaigns = batch
+		} else {
+			campaigns = append(campaigns, batch...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if maxActive := cr.limits.MaxActiveCampaigns; maxActive > 0 && active > maxActive {
+		cr.limitExceeded(&service.LimitError{Limit: service.LimitActiveCampaigns, Count: active, Max: maxActive})
+	}
+	return campaigns, nil
+}
+
+// withinRuleLimits returns the campaigns of batch within the rule limits, reporting the others.
+// batch is only copied when a campaign is dropped, repositories may share it.
+func (cr *CachedRepository) withinRuleLimits(batch []models.CampaignWithRules) []models.CampaignWithRules {
+	var kept []models.CampaignWithRules
+	for i, campaign := range batch {
+		var limitErr *service.LimitError
+		if errors.As(cr.limits.CheckRules(campaign), &limitErr) {
+			cr.limitExceeded(limitErr)
+			if kept == nil {
+				kept = append(make([]models.CampaignWithRules, 0, len(batch)-1), batch[:i]...)
+			}
+			continue
+		}
+		if kept != nil {
+			kept = append(kept, campaign)
+		}
+	}
+	if kept == nil {
+		return batch
+	}
+	return kept
+}
+
+func (cr *CachedRepository) limitExceeded(err *service.LimitError) {
+	if cr.onLimitsExceeded != nil {
+		cr.onLimitsExceeded(err)
+	}
+}
+
 // unionSlices combines multiple slices and removes duplicates
 func (cr *CachedRepository) unionSlices(slices ...[]string) []string {
 	seen := make(map[string]bool)
@@ -197,7 +369,7 @@ func (cr *CachedRepository) buildAndCacheIndexes(ctx context.Context, campaigns
 	}
 
 	// Cache the indexes
-	indexTTL := cr.ttl + time.Minute // Index TTL slightly longer than campaign TTL
+	indexTTL := cr.TTL() + time.Minute // Index TTL slightly longer than campaign TTL
 
 	// Cache country indexes
 	for country, campaignIDs := range countryIndex {
@@ -215,9 +387,27 @@ func (cr *CachedRepository) buildAndCacheIndexes(ctx context.Context, campaigns
 	}
 }
 
+// Flush waits for pending asynchronous cache writes, or until ctx is done
+func (cr *CachedRepository) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		cr.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // InvalidateCache clears all cached data
 func (cr *CachedRepository) InvalidateCache(ctx context.Context) error {
-	return cr.cache.InvalidateAll(ctx)
+	err := cr.cache.InvalidateAll(ctx)
+	cr.ResetSnapshot()
+	return err
 }
 
 // GetCacheStats returns cache performance statistics
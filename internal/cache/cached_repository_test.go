@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRepository returns the configured campaigns or error
+type stubRepository struct {
+	campaigns []models.CampaignWithRules
+	err       error
+}
+
+func (r *stubRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
+	return r.campaigns, r.err
+}
+
+func TestCachedRepository_ServesStaleOnRepositoryFailure(t *testing.T) {
+	hybridCache, err := NewHybridCache(CacheConfig{
+		DefaultTTL:      time.Minute,
+		MemoryCacheSize: 100,
+		EnableMemory:    true,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	repo := &stubRepository{
+		campaigns: []models.CampaignWithRules{{Campaign: models.Campaign{ID: "spotify", Status: models.StatusActive}}},
+	}
+
+	var staleErrs []error
+	cachedRepo := NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Minute, func(err error) {
+		staleErrs = append(staleErrs, err)
+	})
+
+	// Without anything seen yet the repository error is returned
+	repo.err = errors.New("database down")
+	_, err = cachedRepo.GetActiveCampaignsWithRules(ctx)
+	assert.Error(t, err)
+
+	// A successful load is remembered
+	repo.err = nil
+	campaigns, err := cachedRepo.GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 1)
+
+	// Wait for the async cache write before expiring the cache
+	require.NoError(t, cachedRepo.Flush(ctx))
+	_, err = hybridCache.GetActiveCampaigns(ctx)
+	require.NoError(t, err)
+
+	// Once the cache expires and the repository fails, the last campaigns are served
+	require.NoError(t, hybridCache.InvalidateAll(ctx))
+	repo.err = errors.New("database down")
+	repo.campaigns = nil
+
+	campaigns, err = cachedRepo.GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "spotify", campaigns[0].ID)
+	assert.Len(t, staleErrs, 1)
+
+	// Stale campaigns are not written back to the cache
+	require.NoError(t, cachedRepo.Flush(ctx))
+	_, err = hybridCache.GetActiveCampaigns(ctx)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+}
+
+// streamingRepository streams its campaigns in batches, failing with err after the first batch
+type streamingRepository struct {
+	stubRepository
+	batchSizes []int
+}
+
+func (r *streamingRepository) StreamActiveCampaignsWithRules(ctx context.Context, batchSize int, fn func(batch []models.CampaignWithRules) error) error {
+	r.batchSizes = append(r.batchSizes, batchSize)
+	for start := 0; start < len(r.campaigns); start += batchSize {
+		if start > 0 && r.err != nil {
+			return r.err
+		}
+		if err := fn(r.campaigns[start:min(start+batchSize, len(r.campaigns))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCachedRepository_LoadsInBatches(t *testing.T) {
+	hybridCache, err := NewHybridCache(CacheConfig{
+		DefaultTTL:      time.Minute,
+		MemoryCacheSize: 100,
+		EnableMemory:    true,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	repo := &streamingRepository{}
+	for _, id := range []string{"spotify", "duolingo", "subwaysurfer"} {
+		repo.campaigns = append(repo.campaigns, models.CampaignWithRules{Campaign: models.Campaign{ID: id, Status: models.StatusActive}})
+	}
+	cachedRepo := NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Minute, nil)
+	cachedRepo.SetLoadBatchSize(2)
+
+	campaigns, err := cachedRepo.GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, repo.campaigns, campaigns)
+	assert.Equal(t, []int{2}, repo.batchSizes)
+
+	// A load failing midway serves the last campaigns rather than the batches read
+	require.NoError(t, cachedRepo.Flush(ctx))
+	require.NoError(t, hybridCache.InvalidateAll(ctx))
+	repo.campaigns = []models.CampaignWithRules{campaigns[0], {Campaign: models.Campaign{ID: "tinder", Status: models.StatusActive}}, campaigns[2]}
+	repo.err = errors.New("connection reset")
+
+	campaigns, err = cachedRepo.GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 3)
+	assert.Equal(t, "duolingo", campaigns[1].ID)
+}
+
+func TestCachedRepository_Limits(t *testing.T) {
+	hybridCache, err := NewHybridCache(CacheConfig{
+		DefaultTTL:      time.Minute,
+		MemoryCacheSize: 100,
+		EnableMemory:    true,
+	})
+	require.NoError(t, err)
+
+	country := func(values ...string) models.TargetingRule {
+		return models.TargetingRule{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: values}
+	}
+	repo := &streamingRepository{stubRepository: stubRepository{campaigns: []models.CampaignWithRules{
+		{Campaign: models.Campaign{ID: "spotify", Status: models.StatusActive}, Rules: []models.TargetingRule{country("US")}},
+		{Campaign: models.Campaign{ID: "duolingo", Status: models.StatusActive}, Rules: []models.TargetingRule{country("US", "CA", "MX")}},
+		{Campaign: models.Campaign{ID: "subwaysurfer", Status: models.StatusActive}, Rules: []models.TargetingRule{country("US"), country("CA")}},
+		{Campaign: models.Campaign{ID: "netflix", Status: models.StatusActive}},
+		{Campaign: models.Campaign{ID: "tinder", Status: models.StatusActive}},
+	}}}
+	cachedRepo := NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Minute, nil)
+	cachedRepo.SetLoadBatchSize(2)
+	var exceeded []string
+	cachedRepo.SetLimits(service.CampaignLimits{MaxActiveCampaigns: 2, MaxRulesPerCampaign: 1, MaxValuesPerRule: 2}, func(err *service.LimitError) {
+		exceeded = append(exceeded, err.Error())
+	})
+
+	// Campaigns over the rule limits are left out, then those beyond the active campaigns limit
+	campaigns, err := cachedRepo.GetActiveCampaignsWithRules(context.Background())
+	require.NoError(t, err)
+	ids := make([]string, len(campaigns))
+	for i, campaign := range campaigns {
+		ids[i] = campaign.ID
+	}
+	assert.Equal(t, []string{"spotify", "netflix"}, ids)
+	assert.Equal(t, []string{
+		"rule 0 of campaign duolingo has 3 values, more than the limit of 2 per rule",
+		"campaign subwaysurfer has 2 rules, more than the limit of 1 per campaign",
+		"3 active campaigns, more than the limit of 2",
+	}, exceeded)
+	// The campaigns of the repository are left alone
+	assert.Equal(t, "duolingo", repo.campaigns[1].ID)
+}
+
+func TestCachedRepository_GetCompiledCampaignsByRequest(t *testing.T) {
+	hybridCache, err := NewHybridCache(CacheConfig{
+		DefaultTTL:      time.Minute,
+		MemoryCacheSize: 100,
+		EnableMemory:    true,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	repo := &stubRepository{
+		campaigns: []models.CampaignWithRules{
+			{
+				Campaign: models.Campaign{ID: "spotify", Status: models.StatusActive},
+				Rules:    []models.TargetingRule{{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"us"}}},
+			},
+			{
+				Campaign: models.Campaign{ID: "duolingo", Status: models.StatusActive},
+				Rules:    []models.TargetingRule{{Dimension: models.DimensionOS, RuleType: models.RuleTypeInclude, Values: []string{"ios"}}},
+			},
+		},
+	}
+	cachedRepo := NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Minute, nil)
+
+	req := models.DeliveryRequest{Country: "us", OS: "android", App: "com.example"}
+	compiled, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, req)
+	require.NoError(t, err)
+	require.NotEmpty(t, compiled)
+	for _, campaign := range compiled {
+		assert.True(t, campaign.CompiledBy(models.GetDimensionRegistry()))
+	}
+	require.NoError(t, cachedRepo.Flush(ctx))
+
+	// Campaigns served from the memory cache are compiled once
+	first, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, req)
+	require.NoError(t, err)
+	second, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, len(first), len(second))
+	for i := range first {
+		assert.Same(t, first[i], second[i])
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Descriptors for the cache collector, exported under the adbeacon_cache namespace
+var (
+	cacheHitsDesc = prometheus.NewDesc(
+		"adbeacon_cache_hits_total",
+		"Total number of cache hits",
+		nil, nil,
+	)
+	cacheMissesDesc = prometheus.NewDesc(
+		"adbeacon_cache_misses_total",
+		"Total number of cache misses",
+		nil, nil,
+	)
+	cacheErrorsDesc = prometheus.NewDesc(
+		"adbeacon_cache_errors_total",
+		"Total number of cache errors",
+		nil, nil,
+	)
+	cacheHitRatioDesc = prometheus.NewDesc(
+		"adbeacon_cache_hit_ratio",
+		"Cache hit ratio (0-1) since startup",
+		nil, nil,
+	)
+	cacheMemoryItemsDesc = prometheus.NewDesc(
+		"adbeacon_cache_memory_items",
+		"Current number of items in the in-memory cache",
+		nil, nil,
+	)
+	cacheMemoryMaxItemsDesc = prometheus.NewDesc(
+		"adbeacon_cache_memory_max_items",
+		"Maximum number of items in the in-memory cache",
+		nil, nil,
+	)
+	cacheMemoryUtilizationDesc = prometheus.NewDesc(
+		"adbeacon_cache_memory_utilization_ratio",
+		"In-memory cache utilization (0-1)",
+		nil, nil,
+	)
+	cacheRedisUpDesc = prometheus.NewDesc(
+		"adbeacon_cache_redis_up",
+		"Whether the last Redis ping succeeded (1 = up, 0 = down)",
+		nil, nil,
+	)
+	cacheRedisLatencyDesc = prometheus.NewDesc(
+		"adbeacon_cache_redis_ping_latency_seconds",
+		"Latency of the last Redis ping in seconds",
+		nil, nil,
+	)
+	cacheGlobalRedisUpDesc = prometheus.NewDesc(
+		"adbeacon_cache_global_redis_up",
+		"Whether the last ping of the global Redis tier succeeded (1 = up, 0 = down)",
+		nil, nil,
+	)
+)
+
+// collectorPingTimeout bounds the Redis ping done on every scrape
+const collectorPingTimeout = time.Second
+
+// Describe implements prometheus.Collector
+func (hc *HybridCache) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheErrorsDesc
+	ch <- cacheHitRatioDesc
+	ch <- cacheMemoryItemsDesc
+	ch <- cacheMemoryMaxItemsDesc
+	ch <- cacheMemoryUtilizationDesc
+	ch <- cacheRedisUpDesc
+	ch <- cacheRedisLatencyDesc
+	ch <- cacheGlobalRedisUpDesc
+}
+
+// Collect implements prometheus.Collector, reading cache statistics at scrape time
+func (hc *HybridCache) Collect(ch chan<- prometheus.Metric) {
+	stats := hc.GetStats()
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(cacheErrorsDesc, prometheus.CounterValue, float64(stats.Errors))
+	ch <- prometheus.MustNewConstMetric(cacheHitRatioDesc, prometheus.GaugeValue, stats.HitRatio)
+
+	// Memory cache metrics are only reported when the memory cache is enabled
+	if memory := hc.checkMemoryHealth(); memory.Status != "disabled" {
+		ch <- prometheus.MustNewConstMetric(cacheMemoryItemsDesc, prometheus.GaugeValue, float64(memory.Size))
+		ch <- prometheus.MustNewConstMetric(cacheMemoryMaxItemsDesc, prometheus.GaugeValue, float64(memory.MaxSize))
+		ch <- prometheus.MustNewConstMetric(cacheMemoryUtilizationDesc, prometheus.GaugeValue, memory.UtilPct/100)
+	}
+
+	// Redis metrics are only reported when Redis is enabled
+	ctx, cancel := context.WithTimeout(context.Background(), collectorPingTimeout)
+	defer cancel()
+
+	if redis := hc.checkRedisHealth(ctx, hc.redisCache, hc.config.redisAddress()); redis.Status != "disabled" {
+		ch <- prometheus.MustNewConstMetric(cacheRedisUpDesc, prometheus.GaugeValue, boolValue(redis.Connected))
+		ch <- prometheus.MustNewConstMetric(cacheRedisLatencyDesc, prometheus.GaugeValue, redis.Latency.Seconds())
+	}
+	if hc.globalCache != nil {
+		global := hc.checkRedisHealth(ctx, hc.globalCache, hc.config.GlobalRedisAddr)
+		ch <- prometheus.MustNewConstMetric(cacheGlobalRedisUpDesc, prometheus.GaugeValue, boolValue(global.Connected))
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
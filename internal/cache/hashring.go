@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// hashRingReplicas is the number of points each shard gets on the ring, enough for keys to
+// spread roughly evenly
+const hashRingReplicas = 160
+
+// hashRing maps keys to shards with consistent hashing, so adding or removing a shard only
+// moves the keys of that shard. Shards are named by address, every replica configured with the
+// same addresses maps keys the same way whatever their order.
+type hashRing struct {
+	points []uint64
+	owners []int
+}
+
+// newHashRing creates a ring of the shards at addrs, owners are indexes into addrs
+func newHashRing(addrs []string) *hashRing {
+	type point struct {
+		hash  uint64
+		owner int
+	}
+	points := make([]point, 0, len(addrs)*hashRingReplicas)
+	for owner, addr := range addrs {
+		for i := range hashRingReplicas {
+			points = append(points, point{hash: xxhash.Sum64String(addr + "#" + strconv.Itoa(i)), owner: owner})
+		}
+	}
+	// Colliding points go to the same shard on every replica
+	slices.SortFunc(points, func(a, b point) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(addrs[a.owner], addrs[b.owner]))
+	})
+
+	ring := &hashRing{points: make([]uint64, len(points)), owners: make([]int, len(points))}
+	for i, p := range points {
+		ring.points[i] = p.hash
+		ring.owners[i] = p.owner
+	}
+	return ring
+}
+
+// get returns the shard owning key, the first point at or after its hash
+func (r *hashRing) get(key string) int {
+	i, _ := slices.BinarySearch(r.points, xxhash.Sum64String(key))
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[i]
+}
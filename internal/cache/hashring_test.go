@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashRing(t *testing.T) {
+	addrs := []string{"redis-a:6379", "redis-b:6379", "redis-c:6379"}
+	ring := newHashRing(addrs)
+
+	keys := make([]string, 3000)
+	counts := make([]int, len(addrs))
+	for i := range keys {
+		keys[i] = "adbeacon:index:country:" + strconv.Itoa(i)
+		counts[ring.get(keys[i])]++
+	}
+	for i, count := range counts {
+		assert.InDelta(t, 1000, count, 250, addrs[i])
+	}
+
+	// The order of the addresses doesn't matter
+	reordered := newHashRing([]string{addrs[2], addrs[0], addrs[1]})
+	for _, key := range keys {
+		assert.Equal(t, addrs[ring.get(key)], []string{addrs[2], addrs[0], addrs[1]}[reordered.get(key)])
+	}
+
+	// Removing a shard only moves its own keys
+	shrunk := newHashRing(addrs[:2])
+	for _, key := range keys {
+		if owner := ring.get(key); owner != 2 {
+			assert.Equal(t, owner, shrunk.get(key), key)
+		}
+	}
+}
@@ -24,6 +24,9 @@ type memoryCache struct {
 	mu       sync.RWMutex
 	maxSize  int
 	stopChan chan struct{}
+	// stopped is closed once the cleanup goroutine exited
+	stopped   chan struct{}
+	closeOnce sync.Once
 }
 
 // newMemoryCache creates a new in-memory cache
@@ -32,6 +35,7 @@ func newMemoryCache(maxSize int) *memoryCache {
 		items:    make(map[string]*cacheItem),
 		maxSize:  maxSize,
 		stopChan: make(chan struct{}),
+		stopped:  make(chan struct{}),
 	}
 
 	// Start cleanup goroutine
@@ -128,6 +132,8 @@ func (mc *memoryCache) evictIfNeeded() {
 
 // cleanup periodically removes expired items
 func (mc *memoryCache) cleanup() {
+	defer close(mc.stopped)
+
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
@@ -147,9 +153,12 @@ func (mc *memoryCache) cleanup() {
 	}
 }
 
-// close stops the cleanup goroutine
+// close stops the cleanup goroutine and waits for it to exit, it is safe to call more than once
 func (mc *memoryCache) close() {
-	close(mc.stopChan)
+	mc.closeOnce.Do(func() {
+		close(mc.stopChan)
+	})
+	<-mc.stopped
 }
 
 // size returns the current number of items in cache
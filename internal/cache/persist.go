@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// GenerationSource tells the campaign generation persisted campaigns are validated against,
+// see HybridCache.Generation
+type GenerationSource interface {
+	Generation(ctx context.Context) (int64, error)
+}
+
+// persistedCampaigns is the file format of the persisted campaigns
+type persistedCampaigns struct {
+	Generation int64                      `json:"generation"`
+	WrittenAt  time.Time                  `json:"written_at"`
+	Campaigns  []models.CampaignWithRules `json:"campaigns"`
+}
+
+// SnapshotPersister periodically writes the active campaigns of a cached repository to local
+// disk and loads them back on startup, so a full restart serves without loading the campaigns
+// from the database. Persisted campaigns are only loaded while the campaign generation they
+// were written with is current, i.e. no replica changed the campaigns since.
+type SnapshotPersister struct {
+	repo        *CachedRepository
+	generations GenerationSource
+	path        string
+	maxAge      time.Duration
+	now         func() time.Time
+
+	// mu serializes saves, saved is what the file holds
+	mu    sync.Mutex
+	saved persistedState
+}
+
+// persistedState identifies the campaigns in the file without comparing them
+type persistedState struct {
+	generation int64
+	source     *models.CampaignWithRules
+	count      int
+}
+
+// NewSnapshotPersister creates a persister writing the campaigns of repo to path, persisted
+// campaigns older than maxAge aren't loaded
+func NewSnapshotPersister(repo *CachedRepository, generations GenerationSource, path string, maxAge time.Duration) *SnapshotPersister {
+	return &SnapshotPersister{
+		repo:        repo,
+		generations: generations,
+		path:        path,
+		maxAge:      maxAge,
+		now:         time.Now,
+	}
+}
+
+// Load loads the persisted campaigns into the cache and the campaign snapshot, and returns how
+// many were loaded. Missing, outdated and too old files load nothing and aren't errors.
+func (p *SnapshotPersister) Load(ctx context.Context) (int, error) {
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read persisted campaigns: %w", err)
+	}
+
+	var persisted persistedCampaigns
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return 0, fmt.Errorf("failed to decode persisted campaigns: %w", err)
+	}
+	if p.now().Sub(persisted.WrittenAt) > p.maxAge {
+		return 0, nil
+	}
+	generation, err := p.generations.Generation(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read campaign generation: %w", err)
+	}
+	if generation != persisted.Generation {
+		return 0, nil
+	}
+
+	campaigns := models.CompactCampaigns(persisted.Campaigns)
+	repo := p.repo
+	if err := repo.cache.SetActiveCampaigns(ctx, campaigns, repo.TTL()); err != nil {
+		return 0, fmt.Errorf("failed to cache persisted campaigns: %w", err)
+	}
+	repo.buildAndCacheIndexes(ctx, campaigns)
+	repo.stale.Store(&campaigns)
+	repo.snapshot.Store(newCampaignSnapshot(campaigns, models.GetDimensionRegistry().Version()))
+	return len(campaigns), nil
+}
+
+// Save writes the active campaigns to the file, unless it already holds them. The generation
+// is read before the campaigns, so campaigns changing meanwhile are written outdated rather
+// than current.
+func (p *SnapshotPersister) Save(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	generation, err := p.generations.Generation(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read campaign generation: %w", err)
+	}
+	// Campaigns not cached yet are saved on the next run
+	campaigns, err := p.repo.cache.GetActiveCampaigns(ctx)
+	if errors.Is(err, ErrCacheMiss) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	state := persistedState{generation: generation, source: firstCampaign(campaigns), count: len(campaigns)}
+	if state == p.saved {
+		return nil
+	}
+	data, err := json.Marshal(persistedCampaigns{Generation: generation, WrittenAt: p.now().UTC(), Campaigns: campaigns})
+	if err != nil {
+		return fmt.Errorf("failed to encode campaigns: %w", err)
+	}
+	if err := writeFileAtomic(p.path, data); err != nil {
+		return fmt.Errorf("failed to persist campaigns: %w", err)
+	}
+	p.saved = state
+	return nil
+}
+
+// Run saves the campaigns every interval until ctx is done
+func (p *SnapshotPersister) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Save(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// writeFileAtomic replaces the file at path with data, readers see the old or the new file but
+// never a partial one
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
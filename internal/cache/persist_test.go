@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotPersister(t *testing.T) {
+	server, config := newTestRedis(t)
+	config.EnableMemory = true
+	config.MemoryCacheSize = 100
+	path := filepath.Join(t.TempDir(), "campaigns.json")
+	ctx := context.Background()
+
+	// start runs a replica on the shared Redis, as after a restart
+	start := func(repo *stubRepository) (*HybridCache, *CachedRepository, *SnapshotPersister) {
+		hybridCache, err := NewHybridCache(config)
+		require.NoError(t, err)
+		t.Cleanup(func() { hybridCache.Close() })
+		cachedRepo := NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Minute, nil)
+		return hybridCache, cachedRepo, NewSnapshotPersister(cachedRepo, hybridCache, path, time.Hour)
+	}
+
+	_, cachedRepo, persister := start(&stubRepository{campaigns: testCampaigns()})
+	loaded, err := persister.Load(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, loaded)
+
+	// Nothing is persisted until the campaigns are cached
+	require.NoError(t, persister.Save(ctx))
+	assert.NoFileExists(t, path)
+	require.NoError(t, cachedRepo.RefreshSnapshot(ctx))
+	require.NoError(t, cachedRepo.Flush(ctx))
+	require.NoError(t, persister.Save(ctx))
+	assert.FileExists(t, path)
+
+	// After a full restart, with the campaigns expired from Redis, the persisted campaigns are
+	// served without the database
+	server.FastForward(2 * time.Minute)
+	_, cachedRepo, persister = start(&stubRepository{err: errors.New("database down")})
+	loaded, err = persister.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loaded)
+	assert.True(t, cachedRepo.Freshness().Built)
+	compiled, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, models.DeliveryRequest{Country: "us"})
+	require.NoError(t, err)
+	require.Len(t, compiled, 1)
+	assert.Equal(t, "spotify", compiled[0].ID)
+	campaigns, err := cachedRepo.GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, testCampaigns(), campaigns)
+
+	// Campaigns changed on any replica since they were persisted aren't loaded
+	hybridCache, _, persister := start(&stubRepository{})
+	require.NoError(t, hybridCache.InvalidateAll(ctx))
+	loaded, err = persister.Load(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, loaded)
+
+	// persist writes campaigns of generation at writtenAt
+	persist := func(generation int64, writtenAt time.Time) {
+		data, err := json.Marshal(persistedCampaigns{Generation: generation, WrittenAt: writtenAt, Campaigns: testCampaigns()})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+	}
+	generation, err := hybridCache.Generation(ctx)
+	require.NoError(t, err)
+	persist(generation, time.Now())
+	loaded, err = persister.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loaded)
+
+	// Neither are too old campaigns, nor campaigns persisted before Redis lost the generation
+	persist(generation, time.Now().Add(-2*time.Hour))
+	loaded, err = persister.Load(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, loaded)
+
+	persist(generation, time.Now())
+	server.FlushAll()
+	loaded, err = persister.Load(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, loaded)
+	restarted, err := hybridCache.Generation(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, generation, restarted)
+}
@@ -2,47 +2,118 @@ package cache
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 )
 
-// redisCache implements Redis-based caching
+// redisCache implements Redis-based caching, sharding keys over several servers with client-side
+// consistent hashing when configured with more than one
 type redisCache struct {
-	client *redis.Client
+	shards []redisShard
+	ring   *hashRing
 	config CacheConfig
 }
 
-// newRedisCache creates a new Redis cache client
-func newRedisCache(config CacheConfig) (*redisCache, error) {
-	client := redis.NewClient(&redis.Options{
+// redisShard is a Redis server of the cache
+type redisShard struct {
+	addr   string
+	client *redis.Client
+}
+
+// cacheKeyNamespaces hold the keys owned by the cache under the key prefix, other adbeacon keys
+// such as campaign stats share the Redis server and must survive invalidation, as must the keys
+// of other prefixes
+var cacheKeyNamespaces = []string{"campaigns:", "index:"}
+
+// key returns the Redis key of name under the key prefix
+func (rc *redisCache) key(name string) string {
+	return rc.config.RedisKeyPrefix() + name
+}
+
+// NewRedisClient creates a client for the Redis server of config without connecting, so other
+// components can use the same server with the same settings as the cache
+func NewRedisClient(config CacheConfig) *redis.Client {
+	options := &redis.Options{
 		Addr:     config.RedisAddr,
 		Password: config.RedisPassword,
 		DB:       config.RedisDB,
-	})
+		// go-redis retries network errors with jittered backoff (8ms-512ms) itself
+		MaxRetries: config.RedisMaxRetries,
+	}
+
+	if config.RedisTLS {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	// Name each pooled connection, CLIENT SETNAME is per connection so it can't carry request IDs.
+	// The region tells apart the replicas of each region on a shared Redis.
+	if name := clientName(config); name != "" {
+		options.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+			return cn.ClientSetName(ctx, name).Err()
+		}
+	}
+
+	return redis.NewClient(options)
+}
+
+// clientName returns the Redis client name of config, suffixed with its region. Client names
+// can't contain spaces.
+func clientName(config CacheConfig) string {
+	if config.RedisClientName == "" || config.Region == "" {
+		return config.RedisClientName
+	}
+	return config.RedisClientName + "@" + strings.ReplaceAll(config.Region, " ", "-")
+}
+
+// newRedisCache creates a new Redis cache client, connected to every shard
+func newRedisCache(config CacheConfig) (*redisCache, error) {
+	addrs := config.RedisShardAddrs
+	if len(addrs) == 0 {
+		addrs = []string{config.RedisAddr}
+	}
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	rc := &redisCache{
+		ring:   newHashRing(addrs),
+		config: config,
 	}
+	for _, addr := range addrs {
+		shardConfig := config
+		shardConfig.RedisAddr = addr
+		client := NewRedisClient(shardConfig)
+		rc.shards = append(rc.shards, redisShard{addr: addr, client: client})
+
+		if err := client.Ping(ctx).Err(); err != nil {
+			rc.close()
+			return nil, fmt.Errorf("failed to connect to Redis at %s: %w", addr, err)
+		}
+	}
+	return rc, nil
+}
 
-	return &redisCache{
-		client: client,
-		config: config,
-	}, nil
+// client returns the client of the shard owning key
+func (rc *redisCache) client(key string) *redis.Client {
+	if len(rc.shards) == 1 {
+		return rc.shards[0].client
+	}
+	return rc.shards[rc.ring.get(key)].client
 }
 
 // getActiveCampaigns retrieves active campaigns from Redis
 func (rc *redisCache) getActiveCampaigns(ctx context.Context) ([]models.CampaignWithRules, error) {
-	key := "adbeacon:campaigns:active"
+	key := rc.key("campaigns:active")
 
-	data, err := rc.client.Get(ctx, key).Result()
+	data, err := rc.client(key).Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, ErrCacheMiss
@@ -50,24 +121,56 @@ func (rc *redisCache) getActiveCampaigns(ctx context.Context) ([]models.Campaign
 		return nil, fmt.Errorf("Redis get error: %w", err)
 	}
 
-	var campaigns []models.CampaignWithRules
-	if err := json.Unmarshal([]byte(data), &campaigns); err != nil {
+	return decodeCampaigns([]byte(data))
+}
+
+// decodeScratch holds campaigns decoded from Redis until they are compacted. encoding/json
+// decodes into the slices it finds, so reusing them spares a rules slice per campaign and a
+// values slice per rule on every load.
+var decodeScratch = sync.Pool{
+	New: func() any { return new([]models.CampaignWithRules) },
+}
+
+// decodeCampaigns decodes the campaigns of data into pooled slices and returns a compacted copy
+// for the memory cache, which keeps one copy of each rule value
+func decodeCampaigns(data []byte) ([]models.CampaignWithRules, error) {
+	scratch := decodeScratch.Get().(*[]models.CampaignWithRules)
+	defer func() {
+		resetScratch(*scratch)
+		decodeScratch.Put(scratch)
+	}()
+
+	if err := json.Unmarshal(data, scratch); err != nil {
 		return nil, fmt.Errorf("JSON unmarshal error: %w", err)
 	}
+	return models.CompactCampaigns(*scratch), nil
+}
 
-	return campaigns, nil
+// resetScratch clears decoded campaigns for the next decode, keeping the capacity of their
+// slices. Fields missing from the next JSON would otherwise keep the values of this one.
+func resetScratch(campaigns []models.CampaignWithRules) {
+	campaigns = campaigns[:cap(campaigns)]
+	for i := range campaigns {
+		rules := campaigns[i].Rules[:cap(campaigns[i].Rules)]
+		for j := range rules {
+			values := rules[j].Values[:cap(rules[j].Values)]
+			clear(values)
+			rules[j] = models.TargetingRule{Values: values[:0]}
+		}
+		campaigns[i] = models.CampaignWithRules{Rules: rules[:0]}
+	}
 }
 
 // setActiveCampaigns stores active campaigns in Redis
 func (rc *redisCache) setActiveCampaigns(ctx context.Context, campaigns []models.CampaignWithRules, ttl time.Duration) error {
-	key := "adbeacon:campaigns:active"
+	key := rc.key("campaigns:active")
 
 	data, err := json.Marshal(campaigns)
 	if err != nil {
 		return fmt.Errorf("JSON marshal error: %w", err)
 	}
 
-	if err := rc.client.Set(ctx, key, data, ttl).Err(); err != nil {
+	if err := rc.client(key).Set(ctx, key, data, ttl).Err(); err != nil {
 		return fmt.Errorf("Redis set error: %w", err)
 	}
 
@@ -76,9 +179,9 @@ func (rc *redisCache) setActiveCampaigns(ctx context.Context, campaigns []models
 
 // getCampaignIndex retrieves campaign index from Redis
 func (rc *redisCache) getCampaignIndex(ctx context.Context, key string) ([]string, error) {
-	redisKey := fmt.Sprintf("adbeacon:index:%s", key)
+	redisKey := rc.key("index:" + key)
 
-	data, err := rc.client.Get(ctx, redisKey).Result()
+	data, err := rc.client(redisKey).Get(ctx, redisKey).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, ErrCacheMiss
@@ -91,31 +194,49 @@ func (rc *redisCache) getCampaignIndex(ctx context.Context, key string) ([]strin
 		return nil, fmt.Errorf("JSON unmarshal error: %w", err)
 	}
 
+	// A campaign appears in the index of each value it targets
+	for i, id := range campaignIDs {
+		campaignIDs[i] = models.Intern(id)
+	}
 	return campaignIDs, nil
 }
 
 // setCampaignIndex stores campaign index in Redis
 func (rc *redisCache) setCampaignIndex(ctx context.Context, key string, campaignIDs []string, ttl time.Duration) error {
-	redisKey := fmt.Sprintf("adbeacon:index:%s", key)
+	redisKey := rc.key("index:" + key)
 
 	data, err := json.Marshal(campaignIDs)
 	if err != nil {
 		return fmt.Errorf("JSON marshal error: %w", err)
 	}
 
-	if err := rc.client.Set(ctx, redisKey, data, ttl).Err(); err != nil {
+	if err := rc.client(redisKey).Set(ctx, redisKey, data, ttl).Err(); err != nil {
 		return fmt.Errorf("Redis set error: %w", err)
 	}
 
 	return nil
 }
 
-// clear removes all adbeacon cache keys from Redis
+// clear removes the cache keys of the key prefix from every shard
 func (rc *redisCache) clear(ctx context.Context) error {
-	// Get all keys matching our pattern
-	keys, err := rc.client.Keys(ctx, "adbeacon:*").Result()
-	if err != nil {
-		return fmt.Errorf("Redis keys error: %w", err)
+	for _, shard := range rc.shards {
+		if err := rc.clearShard(ctx, shard.client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearShard removes the cache keys of the key prefix from a shard
+func (rc *redisCache) clearShard(ctx context.Context, client *redis.Client) error {
+	// Get all keys matching our patterns, the prefix is matched literally
+	var keys []string
+	for _, namespace := range cacheKeyNamespaces {
+		matched, err := client.Keys(ctx, escapePattern(rc.key(namespace))+"*").Result()
+		if err != nil {
+			return fmt.Errorf("Redis keys error: %w", err)
+		}
+		keys = append(keys, matched...)
 	}
 
 	if len(keys) == 0 {
@@ -123,39 +244,127 @@ func (rc *redisCache) clear(ctx context.Context) error {
 	}
 
 	// Delete all keys
-	if err := rc.client.Del(ctx, keys...).Err(); err != nil {
+	if err := client.Del(ctx, keys...).Err(); err != nil {
 		return fmt.Errorf("Redis delete error: %w", err)
 	}
 
 	return nil
 }
 
-// publishCacheInvalidation publishes cache invalidation event
+// generationKey names the campaign generation, outside the cache namespaces so clearing the
+// cache keeps it
+const generationKey = "generation:campaigns"
+
+// getGeneration returns the campaign generation, ErrCacheMiss when Redis doesn't know it, e.g.
+// after a restart
+func (rc *redisCache) getGeneration(ctx context.Context) (int64, error) {
+	key := rc.key(generationKey)
+	generation, err := rc.client(key).Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, ErrCacheMiss
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Redis get error: %w", err)
+	}
+	return generation, nil
+}
+
+// initGeneration starts a campaign generation unknown to Redis from the current time, so it
+// doesn't repeat the generations Redis knew before losing its data
+func (rc *redisCache) initGeneration(ctx context.Context) error {
+	key := rc.key(generationKey)
+	if err := rc.client(key).SetNX(ctx, key, time.Now().UnixNano(), 0).Err(); err != nil {
+		return fmt.Errorf("Redis setnx error: %w", err)
+	}
+	return nil
+}
+
+// bumpGeneration moves the campaign generation on, campaigns persisted with an earlier one are
+// outdated
+func (rc *redisCache) bumpGeneration(ctx context.Context) error {
+	key := rc.key(generationKey)
+	pipe := rc.client(key).TxPipeline()
+	pipe.SetNX(ctx, key, time.Now().UnixNano(), 0)
+	pipe.Incr(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("Redis incr error: %w", err)
+	}
+	return nil
+}
+
+// escapePattern escapes the glob characters of s for KEYS
+func escapePattern(s string) string {
+	return globReplacer.Replace(s)
+}
+
+var globReplacer = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// publishCacheInvalidation publishes cache invalidation event, on the shard owning the channel
 func (rc *redisCache) publishCacheInvalidation(ctx context.Context, event string) error {
-	channel := "adbeacon:cache:invalidate"
-	return rc.client.Publish(ctx, channel, event).Err()
+	channel := rc.key("cache:invalidate")
+	return rc.client(channel).Publish(ctx, channel, event).Err()
 }
 
-// subscribeCacheInvalidation subscribes to cache invalidation events
+// subscribeCacheInvalidation subscribes to cache invalidation events until ctx is done
 func (rc *redisCache) subscribeCacheInvalidation(ctx context.Context, handler func(string)) error {
-	channel := "adbeacon:cache:invalidate"
-	pubsub := rc.client.Subscribe(ctx, channel)
+	channel := rc.key("cache:invalidate")
+	pubsub := rc.client(channel).Subscribe(ctx, channel)
 	defer pubsub.Close()
 
 	ch := pubsub.Channel()
-	for msg := range ch {
-		handler(msg.Payload)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			handler(msg.Payload)
+		}
 	}
-
-	return nil
 }
 
-// close closes the Redis connection
+// close closes the Redis connections
 func (rc *redisCache) close() error {
-	return rc.client.Close()
+	var firstErr error
+	for _, shard := range rc.shards {
+		if err := shard.client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
 
-// healthCheck checks Redis connection health
+// healthCheck checks the connection health of every shard
 func (rc *redisCache) healthCheck(ctx context.Context) error {
-	return rc.client.Ping(ctx).Err()
+	for _, shard := range rc.checkShards(ctx) {
+		if shard.Error != "" {
+			return fmt.Errorf("Redis at %s: %s", shard.Address, shard.Error)
+		}
+	}
+	return nil
+}
+
+// checkShards pings every shard concurrently
+func (rc *redisCache) checkShards(ctx context.Context) []RedisShardHealth {
+	health := make([]RedisShardHealth, len(rc.shards))
+	var wg sync.WaitGroup
+	for i, shard := range rc.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := shard.client.Ping(ctx).Err()
+			health[i] = RedisShardHealth{Address: shard.addr, Status: "healthy", Connected: err == nil, Latency: time.Since(start)}
+			if err != nil {
+				health[i].Status = "unhealthy"
+				health[i].Error = err.Error()
+			} else if health[i].Latency > 50*time.Millisecond {
+				health[i].Status = "degraded"
+			}
+		}()
+	}
+	wg.Wait()
+	return health
 }
@@ -0,0 +1,531 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedis starts an in-process Redis server for the test and returns it with a
+// configuration using only the Redis cache, so every read goes through the L2 path
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, CacheConfig) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	return server, CacheConfig{
+		DefaultTTL:      time.Minute,
+		RedisAddr:       server.Addr(),
+		RedisMaxRetries: -1,
+		EnableRedis:     true,
+	}
+}
+
+// newTestRedisCache creates a redisCache connected to a fresh in-process Redis server
+func newTestRedisCache(t *testing.T) (*miniredis.Miniredis, *redisCache) {
+	t.Helper()
+
+	server, config := newTestRedis(t)
+	rc, err := newRedisCache(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { rc.close() })
+	return server, rc
+}
+
+func testCampaigns() []models.CampaignWithRules {
+	return []models.CampaignWithRules{
+		{
+			Campaign: models.Campaign{
+				ID:       "spotify",
+				Name:     "Spotify",
+				ImageURL: "https://somelink",
+				CTA:      "Download",
+				Status:   models.StatusActive,
+			},
+			Rules: []models.TargetingRule{
+				{
+					CampaignID: "spotify",
+					Dimension:  models.DimensionCountry,
+					RuleType:   models.RuleTypeInclude,
+					Values:     []string{"us", "ca"},
+				},
+			},
+		},
+	}
+}
+
+func TestRedisCache_ActiveCampaigns(t *testing.T) {
+	server, rc := newTestRedisCache(t)
+	ctx := context.Background()
+
+	_, err := rc.getActiveCampaigns(ctx)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	campaigns := testCampaigns()
+	require.NoError(t, rc.setActiveCampaigns(ctx, campaigns, time.Minute))
+	assert.Equal(t, time.Minute, server.TTL("adbeacon:campaigns:active"))
+
+	cached, err := rc.getActiveCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, campaigns, cached)
+
+	// Entries expire with their TTL
+	server.FastForward(time.Minute)
+	_, err = rc.getActiveCampaigns(ctx)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+}
+
+func TestRedisCache_ActiveCampaignsInterned(t *testing.T) {
+	_, rc := newTestRedisCache(t)
+	ctx := context.Background()
+
+	campaigns := append(testCampaigns(), models.CampaignWithRules{
+		Campaign: models.Campaign{ID: "duolingo", Status: models.StatusActive},
+		Rules:    []models.TargetingRule{{CampaignID: "duolingo", Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"us"}}},
+	})
+	require.NoError(t, rc.setActiveCampaigns(ctx, campaigns, time.Minute))
+
+	// Values decoded from Redis are shared between campaigns
+	cached, err := rc.getActiveCampaigns(ctx)
+	require.NoError(t, err)
+	first, second := cached[0].Rules[0].Values[0], cached[1].Rules[0].Values[0]
+	assert.Equal(t, "us", first)
+	assert.Same(t, unsafe.StringData(first), unsafe.StringData(second))
+}
+
+func TestDecodeCampaigns_ReusedScratch(t *testing.T) {
+	// A load with more campaigns, rules and values than the next leaves them in the scratch
+	_, err := decodeCampaigns([]byte(`[
+		{"cid":"spotify","name":"Spotify","status":"active","rules":[
+			{"campaign_id":"spotify","dimension":"country","rule_type":"include","values":["us","ca","de"]},
+			{"campaign_id":"spotify","dimension":"os","rule_type":"exclude","values":["ios"]}]},
+		{"cid":"duolingo","rules":[{"campaign_id":"duolingo","dimension":"app","rule_type":"include","values":["com.duolingo"]}]}
+	]`))
+	require.NoError(t, err)
+
+	campaigns, err := decodeCampaigns([]byte(`[
+		{"cid":"subwaysurfer","rules":[{"campaign_id":"subwaysurfer","dimension":"os","rule_type":"include","values":["android"]}]}
+	]`))
+	require.NoError(t, err)
+	assert.Equal(t, []models.CampaignWithRules{
+		{
+			Campaign: models.Campaign{ID: "subwaysurfer"},
+			Rules:    []models.TargetingRule{{CampaignID: "subwaysurfer", Dimension: models.DimensionOS, RuleType: models.RuleTypeInclude, Values: []string{"android"}}},
+		},
+	}, campaigns)
+
+	// Campaigns without rules don't inherit those of the previous load
+	campaigns, err = decodeCampaigns([]byte(`[{"cid":"spotify","name":"Spotify"}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []models.CampaignWithRules{{Campaign: models.Campaign{ID: "spotify", Name: "Spotify"}}}, campaigns)
+
+	_, err = decodeCampaigns([]byte(`[{"cid":`))
+	assert.Error(t, err)
+}
+
+func TestRedisCache_CampaignIndex(t *testing.T) {
+	server, rc := newTestRedisCache(t)
+	ctx := context.Background()
+
+	_, err := rc.getCampaignIndex(ctx, "index:country:us")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	require.NoError(t, rc.setCampaignIndex(ctx, "index:country:us", []string{"spotify", "duolingo"}, time.Minute))
+	assert.True(t, server.Exists("adbeacon:index:index:country:us"))
+
+	ids, err := rc.getCampaignIndex(ctx, "index:country:us")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spotify", "duolingo"}, ids)
+}
+
+func TestRedisCache_CorruptEntry(t *testing.T) {
+	server, rc := newTestRedisCache(t)
+	ctx := context.Background()
+
+	require.NoError(t, server.Set("adbeacon:campaigns:active", "not json"))
+	_, err := rc.getActiveCampaigns(ctx)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCacheMiss)
+}
+
+func TestRedisCache_Clear(t *testing.T) {
+	server, rc := newTestRedisCache(t)
+	ctx := context.Background()
+
+	// Clearing an empty cache is a no-op
+	require.NoError(t, rc.clear(ctx))
+
+	require.NoError(t, rc.setActiveCampaigns(ctx, testCampaigns(), time.Minute))
+	require.NoError(t, rc.setCampaignIndex(ctx, "index:os:android", []string{"spotify"}, time.Minute))
+	require.NoError(t, server.Set("other:key", "kept"))
+	require.NoError(t, server.Set("adbeacon:stats:spotify:1", "3"))
+
+	require.NoError(t, rc.clear(ctx))
+
+	// Only cache keys are removed
+	assert.Equal(t, []string{"adbeacon:stats:spotify:1", "other:key"}, server.Keys())
+}
+
+func TestHybridCache_InvalidationAcrossReplicas(t *testing.T) {
+	server, config := newTestRedis(t)
+	config.EnableMemory = true
+	config.MemoryCacheSize = 10
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two replicas sharing Redis, each with its own memory cache
+	publisher, err := NewHybridCache(config)
+	require.NoError(t, err)
+	defer publisher.Close()
+	subscriber, err := NewHybridCache(config)
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	require.NoError(t, subscriber.SetActiveCampaigns(ctx, testCampaigns(), time.Minute))
+	invalidated := make(chan struct{}, 1)
+	go subscriber.SubscribeInvalidations(ctx, func() { invalidated <- struct{}{} })
+	require.Eventually(t, func() bool {
+		return server.PubSubNumSub("adbeacon:cache:invalidate")["adbeacon:cache:invalidate"] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, publisher.InvalidateAll(ctx))
+	require.NoError(t, publisher.PublishInvalidation(ctx))
+	select {
+	case <-invalidated:
+	case <-time.After(time.Second):
+		t.Fatal("invalidation not received")
+	}
+
+	// The subscriber's memory cache was cleared along with Redis
+	_, err = subscriber.GetActiveCampaigns(ctx)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+}
+
+func TestRedisCache_PubSub(t *testing.T) {
+	server, rc := newTestRedisCache(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- rc.subscribeCacheInvalidation(ctx, func(event string) { events <- event })
+	}()
+
+	// Publishing before the subscription is registered would lose the event
+	require.Eventually(t, func() bool {
+		return server.PubSubNumSub("adbeacon:cache:invalidate")["adbeacon:cache:invalidate"] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, rc.publishCacheInvalidation(ctx, "campaigns"))
+	select {
+	case event := <-events:
+		assert.Equal(t, "campaigns", event)
+	case <-time.After(time.Second):
+		t.Fatal("invalidation event not received")
+	}
+
+	// The subscription ends with its context
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("subscription did not stop after cancel")
+	}
+}
+
+func TestRedisCache_HealthCheck(t *testing.T) {
+	server, rc := newTestRedisCache(t)
+	ctx := context.Background()
+
+	assert.NoError(t, rc.healthCheck(ctx))
+
+	server.Close()
+	assert.Error(t, rc.healthCheck(ctx))
+}
+
+func TestNewRedisCache_Unreachable(t *testing.T) {
+	server, config := newTestRedis(t)
+	server.Close()
+
+	_, err := NewHybridCache(config)
+	assert.Error(t, err)
+}
+
+func TestHybridCache_RedisOnly(t *testing.T) {
+	_, config := newTestRedis(t)
+	cache, err := NewHybridCache(config)
+	require.NoError(t, err)
+	defer cache.Close()
+	ctx := context.Background()
+
+	_, err = cache.GetActiveCampaigns(ctx)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	campaigns := testCampaigns()
+	require.NoError(t, cache.SetActiveCampaigns(ctx, campaigns, time.Minute))
+	require.NoError(t, cache.SetCampaignIndex(ctx, models.DimensionCountry, "us", []string{"spotify"}, time.Minute))
+
+	cached, err := cache.GetActiveCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, campaigns, cached)
+
+	ids, err := cache.GetCampaignIndex(ctx, models.DimensionCountry, "us")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spotify"}, ids)
+
+	stats := cache.GetStats()
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+
+	require.NoError(t, cache.InvalidateAll(ctx))
+	_, err = cache.GetActiveCampaigns(ctx)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+}
+
+func TestHybridCache_RedisWarmsMemory(t *testing.T) {
+	server, config := newTestRedis(t)
+	config.EnableMemory = true
+	config.MemoryCacheSize = 100
+	ctx := context.Background()
+
+	// A second instance shares Redis, as another replica would
+	writer, err := NewHybridCache(config)
+	require.NoError(t, err)
+	defer writer.Close()
+	reader, err := NewHybridCache(config)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	campaigns := testCampaigns()
+	require.NoError(t, writer.SetActiveCampaigns(ctx, campaigns, time.Minute))
+
+	cached, err := reader.GetActiveCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, campaigns, cached)
+
+	// The Redis hit warmed the reader's memory cache, it keeps serving without Redis
+	server.FlushAll()
+	cached, err = reader.GetActiveCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, campaigns, cached)
+}
+
+func TestHybridCache_RedisStoreError(t *testing.T) {
+	server, config := newTestRedis(t)
+	config.EnableMemory = true
+	config.MemoryCacheSize = 100
+	cache, err := NewHybridCache(config)
+	require.NoError(t, err)
+	defer cache.Close()
+	ctx := context.Background()
+
+	server.Close()
+
+	// The memory cache is still written, the Redis failure is reported and counted
+	err = cache.SetActiveCampaigns(ctx, testCampaigns(), time.Minute)
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), cache.GetStats().Errors)
+
+	_, err = cache.GetActiveCampaigns(ctx)
+	assert.NoError(t, err)
+}
+
+func TestHybridCache_HealthCheck_Redis(t *testing.T) {
+	server, config := newTestRedis(t)
+	cache, err := NewHybridCache(config)
+	require.NoError(t, err)
+	defer cache.Close()
+	ctx := context.Background()
+
+	health := cache.HealthCheck(ctx)
+	assert.True(t, health.Redis.Connected)
+	assert.Equal(t, server.Addr(), health.Redis.Address)
+	assert.Equal(t, "disabled", health.Memory.Status)
+
+	server.Close()
+	health = cache.HealthCheck(ctx)
+	assert.False(t, health.Redis.Connected)
+	assert.Equal(t, "unhealthy", health.Redis.Status)
+	assert.Equal(t, "unhealthy", health.Overall)
+}
+
+func TestHybridCache_GlobalTier(t *testing.T) {
+	local, config := newTestRedis(t)
+	global := miniredis.RunT(t)
+	config.Region = "eu-west-1"
+	config.GlobalRedisAddr = global.Addr()
+	cache, err := NewHybridCache(config)
+	require.NoError(t, err)
+	defer cache.Close()
+	ctx := context.Background()
+
+	// Writes fan out to both tiers
+	campaigns := testCampaigns()
+	require.NoError(t, cache.SetActiveCampaigns(ctx, campaigns, time.Minute))
+	require.NoError(t, cache.SetCampaignIndex(ctx, models.DimensionCountry, "us", []string{"spotify"}, time.Minute))
+	for _, server := range []*miniredis.Miniredis{local, global} {
+		assert.True(t, server.Exists("adbeacon:campaigns:active"))
+		assert.True(t, server.Exists("adbeacon:index:index:country:us"))
+	}
+
+	// Reads come from the regional tier only
+	global.FlushAll()
+	ids, err := cache.GetCampaignIndex(ctx, models.DimensionCountry, "us")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spotify"}, ids)
+	local.FlushAll()
+	require.NoError(t, global.Set("adbeacon:index:index:country:us", `["netflix"]`))
+	_, err = cache.GetCampaignIndex(ctx, models.DimensionCountry, "us")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	// Invalidation clears both tiers
+	require.NoError(t, cache.InvalidateAll(ctx))
+	assert.False(t, global.Exists("adbeacon:index:index:country:us"))
+
+	// Losing the global tier degrades the cache without failing reads
+	health := cache.HealthCheck(ctx)
+	assert.Equal(t, "eu-west-1", health.Region)
+	assert.Equal(t, "healthy", health.Overall)
+	require.NotNil(t, health.GlobalRedis)
+	assert.Equal(t, global.Addr(), health.GlobalRedis.Address)
+	global.Close()
+	health = cache.HealthCheck(ctx)
+	assert.Equal(t, "degraded", health.Overall)
+	assert.Equal(t, "unhealthy", health.GlobalRedis.Status)
+}
+
+func TestHybridCache_GlobalInvalidations(t *testing.T) {
+	global := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Replicas of two regions share only the global tier
+	newRegion := func(region string) *HybridCache {
+		_, config := newTestRedis(t)
+		config.Region = region
+		config.GlobalRedisAddr = global.Addr()
+		cache, err := NewHybridCache(config)
+		require.NoError(t, err)
+		t.Cleanup(func() { cache.Close() })
+		return cache
+	}
+	publisher, subscriber := newRegion("eu-west-1"), newRegion("us-east-1")
+
+	invalidated := make(chan struct{}, 1)
+	go subscriber.SubscribeInvalidations(ctx, func() { invalidated <- struct{}{} })
+	require.Eventually(t, func() bool {
+		return len(global.PubSubChannels("adbeacon:cache:invalidate")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, publisher.PublishInvalidation(ctx))
+	select {
+	case <-invalidated:
+	case <-time.After(time.Second):
+		t.Fatal("the other region wasn't told about the invalidation")
+	}
+}
+
+func TestClientName(t *testing.T) {
+	assert.Equal(t, "adbeacon", clientName(CacheConfig{RedisClientName: "adbeacon"}))
+	assert.Equal(t, "adbeacon@eu-west-1", clientName(CacheConfig{RedisClientName: "adbeacon", Region: "eu-west-1"}))
+	assert.Equal(t, "", clientName(CacheConfig{Region: "eu-west-1"}))
+}
+
+func TestHybridCache_Shards(t *testing.T) {
+	shards := []*miniredis.Miniredis{miniredis.RunT(t), miniredis.RunT(t), miniredis.RunT(t)}
+	_, config := newTestRedis(t)
+	config.RedisShardAddrs = []string{shards[0].Addr(), shards[1].Addr(), shards[2].Addr()}
+	cache, err := NewHybridCache(config)
+	require.NoError(t, err)
+	defer cache.Close()
+	ctx := context.Background()
+
+	// Each key lives on exactly one shard, and the keys spread over the shards
+	countries := []string{"us", "ca", "de", "fr", "in", "br", "jp", "au", "mx", "es"}
+	for _, country := range countries {
+		require.NoError(t, cache.SetCampaignIndex(ctx, models.DimensionCountry, country, []string{"spotify"}, time.Minute))
+	}
+	used := 0
+	total := 0
+	for _, shard := range shards {
+		if n := len(shard.Keys()); n > 0 {
+			used++
+			total += n
+		}
+	}
+	assert.Equal(t, len(countries), total)
+	assert.Greater(t, used, 1)
+	for _, country := range countries {
+		ids, err := cache.GetCampaignIndex(ctx, models.DimensionCountry, country)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"spotify"}, ids)
+	}
+
+	// Invalidation clears every shard, only the campaign generation is left
+	require.NoError(t, cache.InvalidateAll(ctx))
+	var left []string
+	for _, shard := range shards {
+		left = append(left, shard.Keys()...)
+	}
+	assert.Equal(t, []string{"adbeacon:generation:campaigns"}, left)
+
+	// Each shard reports its health, a shard down degrades the cache
+	health := cache.HealthCheck(ctx)
+	assert.Equal(t, "healthy", health.Redis.Status)
+	require.Len(t, health.Redis.Shards, 3)
+	assert.Equal(t, shards[1].Addr(), health.Redis.Shards[1].Address)
+	shards[1].Close()
+	health = cache.HealthCheck(ctx)
+	assert.Equal(t, "degraded", health.Redis.Status)
+	assert.True(t, health.Redis.Connected)
+	assert.Equal(t, "1 of 3 shards down", health.Redis.Error)
+	assert.False(t, health.Redis.Shards[1].Connected)
+	assert.Equal(t, "unhealthy", health.Redis.Shards[1].Status)
+	assert.True(t, health.Redis.Shards[0].Connected)
+}
+
+func TestHybridCache_KeyPrefix(t *testing.T) {
+	server, config := newTestRedis(t)
+	ctx := context.Background()
+
+	// Staging and prod share the Redis server, prefixes with glob characters are matched literally
+	newCache := func(prefix string) *HybridCache {
+		config.KeyPrefix = prefix
+		cache, err := NewHybridCache(config)
+		require.NoError(t, err)
+		t.Cleanup(func() { cache.Close() })
+		return cache
+	}
+	staging, prod, tenant := newCache("staging:"), newCache(""), newCache("tenant[1]:")
+
+	require.NoError(t, staging.SetActiveCampaigns(ctx, testCampaigns(), time.Minute))
+	require.NoError(t, prod.SetCampaignIndex(ctx, models.DimensionCountry, "us", []string{"spotify"}, time.Minute))
+	require.NoError(t, tenant.SetCampaignIndex(ctx, models.DimensionCountry, "us", []string{"netflix"}, time.Minute))
+	require.NoError(t, server.Set("tenant1:index:index:country:us", "kept"))
+	assert.True(t, server.Exists("staging:campaigns:active"))
+	assert.True(t, server.Exists("adbeacon:index:index:country:us"))
+
+	_, err := prod.GetActiveCampaigns(ctx)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	// Clearing only removes the cache keys of the prefix, the campaign generations are kept
+	require.NoError(t, staging.InvalidateAll(ctx))
+	require.NoError(t, tenant.InvalidateAll(ctx))
+	assert.Equal(t, []string{
+		"adbeacon:index:index:country:us", "staging:generation:campaigns",
+		"tenant1:index:index:country:us", "tenant[1]:generation:campaigns",
+	}, server.Keys())
+
+	require.NoError(t, prod.InvalidateAll(ctx))
+	assert.Equal(t, []string{
+		"adbeacon:generation:campaigns", "staging:generation:campaigns",
+		"tenant1:index:index:country:us", "tenant[1]:generation:campaigns",
+	}, server.Keys())
+}
@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"golang.org/x/sync/singleflight"
+)
+
+// campaignSnapshot is an immutable view of the active campaigns, compiled for matching and
+// indexed by the values their include rules target. Snapshots are built off the request path
+// and swapped in whole, so requests read them without taking any lock.
+type campaignSnapshot struct {
+	// source is the first campaign of the slice the snapshot was built from, the memory cache
+	// serves the same slice until it's rebuilt
+	source  *models.CampaignWithRules
+	count   int
+	version uint64
+	all     []*models.CompiledCampaign
+	// countries, oses and apps index the active campaigns by the values their include rules
+	// target, with the same normalization as the cached indexes
+	countries map[string][]*models.CompiledCampaign
+	oses      map[string][]*models.CompiledCampaign
+	apps      map[string][]*models.CompiledCampaign
+	// checked is when the campaigns were last found unchanged, in Unix nanoseconds
+	checked atomic.Int64
+	// reset is set on the snapshots replacing one that was reset, they are served until rebuilt
+	// but never reused
+	reset bool
+}
+
+// expired reports whether the campaigns weren't checked for changes within ttl, e.g. because
+// nothing runs RunSnapshotRefresh, or the snapshot was reset
+func (s *campaignSnapshot) expired(now time.Time, ttl time.Duration) bool {
+	return s.reset || now.UnixNano()-s.checked.Load() > int64(ttl)
+}
+
+// builtFrom reports whether the snapshot was built from campaigns with the current dimension
+// processors, so it can be reused for them
+func (s *campaignSnapshot) builtFrom(campaigns []models.CampaignWithRules, version uint64) bool {
+	return s != nil && !s.reset && s.source == firstCampaign(campaigns) && s.count == len(campaigns) && s.version == version
+}
+
+// resetCopy returns a copy of the snapshot that is served until it is rebuilt
+func (s *campaignSnapshot) resetCopy() *campaignSnapshot {
+	snapshot := &campaignSnapshot{
+		source:    s.source,
+		count:     s.count,
+		version:   s.version,
+		all:       s.all,
+		countries: s.countries,
+		oses:      s.oses,
+		apps:      s.apps,
+		reset:     true,
+	}
+	snapshot.checked.Store(s.checked.Load())
+	return snapshot
+}
+
+// newCampaignSnapshot compiles campaigns with the default matcher and indexes them
+func newCampaignSnapshot(campaigns []models.CampaignWithRules, version uint64) *campaignSnapshot {
+	snapshot := &campaignSnapshot{
+		source:    firstCampaign(campaigns),
+		count:     len(campaigns),
+		version:   version,
+		all:       models.CompileCampaigns(campaigns),
+		countries: make(map[string][]*models.CompiledCampaign),
+		oses:      make(map[string][]*models.CompiledCampaign),
+		apps:      make(map[string][]*models.CompiledCampaign),
+	}
+	snapshot.checked.Store(time.Now().UnixNano())
+
+	for _, campaign := range snapshot.all {
+		if !campaign.IsActive() {
+			continue
+		}
+
+		for _, rule := range campaign.Rules {
+			if rule.RuleType != models.RuleTypeInclude {
+				continue
+			}
+
+			switch rule.Dimension {
+			case models.DimensionCountry:
+				indexCampaign(snapshot.countries, rule.NormalizeValues(), campaign)
+			case models.DimensionOS:
+				indexCampaign(snapshot.oses, rule.NormalizeValues(), campaign)
+			case models.DimensionApp:
+				indexCampaign(snapshot.apps, rule.Values, campaign) // Don't normalize app IDs
+			}
+		}
+	}
+	return snapshot
+}
+
+// indexCampaign adds campaign to the index entry of each value, once per entry
+func indexCampaign(index map[string][]*models.CompiledCampaign, values []string, campaign *models.CompiledCampaign) {
+	for _, value := range values {
+		entry := index[value]
+		// Campaigns are indexed one after the other, a repeated value ends with the campaign
+		if len(entry) > 0 && entry[len(entry)-1] == campaign {
+			continue
+		}
+		index[models.Intern(value)] = append(entry, campaign)
+	}
+}
+
+// candidates returns the campaigns indexed under any of the request's country, OS or app, or
+// every campaign when none of them is indexed. The result is shared and must not be modified.
+func (s *campaignSnapshot) candidates(req models.DeliveryRequest) []*models.CompiledCampaign {
+	var sets [3][]*models.CompiledCampaign
+	n := 0
+	for _, set := range [...][]*models.CompiledCampaign{s.countries[req.Country], s.oses[req.OS], s.apps[req.App]} {
+		if len(set) > 0 {
+			sets[n] = set
+			n++
+		}
+	}
+
+	switch n {
+	case 0:
+		return s.all
+	case 1:
+		return sets[0]
+	}
+
+	// Union of the sets in order, a campaign might not target every dimension
+	total := len(sets[0]) + len(sets[1]) + len(sets[2])
+	seen := make(map[*models.CompiledCampaign]struct{}, total)
+	union := make([]*models.CompiledCampaign, 0, total)
+	for _, set := range sets[:n] {
+		for _, campaign := range set {
+			if _, ok := seen[campaign]; !ok {
+				seen[campaign] = struct{}{}
+				union = append(union, campaign)
+			}
+		}
+	}
+	return union
+}
+
+func firstCampaign(campaigns []models.CampaignWithRules) *models.CampaignWithRules {
+	if len(campaigns) == 0 {
+		return nil
+	}
+	return &campaigns[0]
+}
+
+// RefreshSnapshot rebuilds the campaign snapshot served to delivery requests when the active
+// campaigns changed since it was built
+func (cr *CachedRepository) RefreshSnapshot(ctx context.Context) error {
+	_, err := cr.awaitSnapshot(ctx)
+	return err
+}
+
+// awaitSnapshot waits for the snapshot rebuild started by startSnapshotRebuild and returns the
+// snapshot, or ctx.Err() when ctx is done first
+func (cr *CachedRepository) awaitSnapshot(ctx context.Context) (*campaignSnapshot, error) {
+	select {
+	case result := <-cr.startSnapshotRebuild(ctx):
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*campaignSnapshot), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startSnapshotRebuild refreshes the snapshot unless a refresh is running already, callers
+// needing it meanwhile share its result. The refresh isn't canceled with ctx, other callers may
+// be waiting for it.
+func (cr *CachedRepository) startSnapshotRebuild(ctx context.Context) <-chan singleflight.Result {
+	return cr.rebuild.DoChan("snapshot", func() (interface{}, error) {
+		return cr.refreshSnapshot(context.WithoutCancel(ctx))
+	})
+}
+
+// refreshSnapshot loads the active campaigns and returns their snapshot, swapping it in unless
+// the snapshot was reset or replaced meanwhile
+func (cr *CachedRepository) refreshSnapshot(ctx context.Context) (*campaignSnapshot, error) {
+	last := cr.snapshot.Load()
+	campaigns, err := cr.GetActiveCampaignsWithRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version := models.GetDimensionRegistry().Version()
+	if last.builtFrom(campaigns, version) {
+		last.checked.Store(time.Now().UnixNano())
+		return last, nil
+	}
+	snapshot := newCampaignSnapshot(campaigns, version)
+	cr.snapshot.CompareAndSwap(last, snapshot)
+	return snapshot, nil
+}
+
+// Freshness describes the campaign snapshot served to delivery requests
+type Freshness struct {
+	// Built is false until the first delivery request or refresh after startup
+	Built bool `json:"built"`
+	// Campaigns is the number of active campaigns in the snapshot
+	Campaigns int `json:"campaigns"`
+	// CheckedAt is when the campaigns were last found unchanged, AgeSeconds how long ago
+	CheckedAt  time.Time `json:"checked_at,omitempty"`
+	AgeSeconds float64   `json:"age_seconds"`
+	TTLSeconds float64   `json:"ttl_seconds"`
+	// Expired is set when the campaigns weren't checked within the TTL or were reset since
+	Expired bool `json:"expired"`
+}
+
+// Freshness reports how recently the campaign snapshot was checked against the cache
+func (cr *CachedRepository) Freshness() Freshness {
+	ttl := cr.TTL()
+	freshness := Freshness{TTLSeconds: ttl.Seconds()}
+	snapshot := cr.snapshot.Load()
+	if snapshot == nil {
+		return freshness
+	}
+
+	now := time.Now()
+	checked := time.Unix(0, snapshot.checked.Load())
+	freshness.Built = true
+	freshness.Campaigns = snapshot.count
+	freshness.CheckedAt = checked.UTC()
+	freshness.AgeSeconds = now.Sub(checked).Seconds()
+	freshness.Expired = snapshot.expired(now, ttl)
+	return freshness
+}
+
+// ResetSnapshot expires the campaign snapshot, the next delivery request starts rebuilding it
+// from the cache or the repository and the reset one is served meanwhile. Call it when
+// campaigns change, after invalidating the cache.
+func (cr *CachedRepository) ResetSnapshot() {
+	for {
+		last := cr.snapshot.Load()
+		// A rebuild already running can't swap in its snapshot of the old campaigns
+		if last == nil || cr.snapshot.CompareAndSwap(last, last.resetCopy()) {
+			return
+		}
+	}
+}
+
+// RunSnapshotRefresh refreshes the campaign snapshot every interval until ctx is done, so
+// campaigns reloaded into the cache reach delivery requests without them waiting on the load
+func (cr *CachedRepository) RunSnapshotRefresh(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cr.RefreshSnapshot(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
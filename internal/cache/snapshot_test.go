@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func snapshotCampaign(id string, rules ...models.TargetingRule) models.CampaignWithRules {
+	return models.CampaignWithRules{Campaign: models.Campaign{ID: id, Status: models.StatusActive}, Rules: rules}
+}
+
+func includeRule(dimension models.TargetDimension, values ...string) models.TargetingRule {
+	return models.TargetingRule{Dimension: dimension, RuleType: models.RuleTypeInclude, Values: values}
+}
+
+// candidateIDs returns the IDs of the snapshot candidates of req
+func candidateIDs(snapshot *campaignSnapshot, req models.DeliveryRequest) []string {
+	var ids []string
+	for _, campaign := range snapshot.candidates(req) {
+		ids = append(ids, campaign.ID)
+	}
+	return ids
+}
+
+func TestCampaignSnapshot_Candidates(t *testing.T) {
+	inactive := snapshotCampaign("paused", includeRule(models.DimensionCountry, "us"))
+	inactive.Status = models.StatusInactive
+	snapshot := newCampaignSnapshot([]models.CampaignWithRules{
+		snapshotCampaign("spotify", includeRule(models.DimensionCountry, "US", "us", "CA")),
+		snapshotCampaign("duolingo", includeRule(models.DimensionOS, "Android"), includeRule(models.DimensionCountry, "us")),
+		snapshotCampaign("ludo", includeRule(models.DimensionApp, "com.gametion.ludokinggame")),
+		snapshotCampaign("everyone"),
+		inactive,
+	}, models.GetDimensionRegistry().Version())
+
+	tests := []struct {
+		name string
+		req  models.DeliveryRequest
+		want []string
+	}{
+		{"country", models.DeliveryRequest{Country: "us"}, []string{"spotify", "duolingo"}},
+		{"union in order", models.DeliveryRequest{Country: "ca", OS: "android", App: "com.gametion.ludokinggame"}, []string{"spotify", "duolingo", "ludo"}},
+		{"overlapping sets", models.DeliveryRequest{Country: "us", OS: "android"}, []string{"spotify", "duolingo"}},
+		{"nothing indexed", models.DeliveryRequest{Country: "de", OS: "ios"}, []string{"spotify", "duolingo", "ludo", "everyone", "paused"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, candidateIDs(snapshot, tt.req))
+		})
+	}
+}
+
+func TestCachedRepository_Snapshot(t *testing.T) {
+	hybridCache, err := NewHybridCache(CacheConfig{
+		DefaultTTL:      time.Minute,
+		MemoryCacheSize: 100,
+		EnableMemory:    true,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	repo := &stubRepository{campaigns: []models.CampaignWithRules{snapshotCampaign("spotify", includeRule(models.DimensionCountry, "us"))}}
+	cachedRepo := NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Minute, nil)
+	req := models.DeliveryRequest{Country: "us"}
+
+	// The first request builds the snapshot
+	campaigns, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, campaigns, 1)
+	require.NoError(t, cachedRepo.Flush(ctx))
+
+	// Later requests are served from it even once the cache changed
+	repo.campaigns = []models.CampaignWithRules{
+		snapshotCampaign("spotify", includeRule(models.DimensionCountry, "us")),
+		snapshotCampaign("duolingo", includeRule(models.DimensionCountry, "us")),
+	}
+	require.NoError(t, hybridCache.InvalidateAll(ctx))
+	campaigns, err = cachedRepo.GetCompiledCampaignsByRequest(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 1)
+
+	// Refreshing picks up the reloaded campaigns
+	require.NoError(t, cachedRepo.RefreshSnapshot(ctx))
+	require.NoError(t, cachedRepo.Flush(ctx))
+	campaigns, err = cachedRepo.GetCompiledCampaignsByRequest(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 2)
+
+	// Refreshing unchanged campaigns keeps the snapshot
+	snapshot := cachedRepo.snapshot.Load()
+	require.NoError(t, cachedRepo.RefreshSnapshot(ctx))
+	assert.Same(t, snapshot, cachedRepo.snapshot.Load())
+
+	// Invalidating the cache through the repository resets the snapshot, it is served until the
+	// request finding it reset rebuilt it
+	repo.campaigns = repo.campaigns[:1]
+	require.NoError(t, cachedRepo.InvalidateCache(ctx))
+	assert.True(t, cachedRepo.Freshness().Expired)
+	campaigns, err = cachedRepo.GetCompiledCampaignsByRequest(ctx, req)
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 2)
+	assert.Eventually(t, func() bool {
+		campaigns, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, req)
+		return err == nil && len(campaigns) == 1
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, cachedRepo.Flush(ctx))
+}
+
+func TestCachedRepository_SnapshotExpires(t *testing.T) {
+	hybridCache, err := NewHybridCache(CacheConfig{DefaultTTL: time.Minute, MemoryCacheSize: 100, EnableMemory: true})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	repo := &stubRepository{campaigns: []models.CampaignWithRules{snapshotCampaign("spotify")}}
+	cachedRepo := NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Minute, nil)
+	assert.Equal(t, Freshness{TTLSeconds: 60}, cachedRepo.Freshness())
+
+	_, err = cachedRepo.GetCompiledCampaignsByRequest(ctx, models.DeliveryRequest{})
+	require.NoError(t, err)
+	require.NoError(t, cachedRepo.Flush(ctx))
+	freshness := cachedRepo.Freshness()
+	assert.True(t, freshness.Built)
+	assert.Equal(t, 1, freshness.Campaigns)
+	assert.False(t, freshness.Expired)
+
+	// Without background refreshes, a request finding the snapshot unchecked for longer than
+	// the TTL is served from it and starts refreshing it
+	repo.campaigns = append(repo.campaigns, snapshotCampaign("duolingo"))
+	require.NoError(t, hybridCache.InvalidateAll(ctx))
+	cachedRepo.snapshot.Load().checked.Add(-int64(2 * time.Minute))
+	freshness = cachedRepo.Freshness()
+	assert.True(t, freshness.Expired)
+	assert.GreaterOrEqual(t, freshness.AgeSeconds, 120.0)
+
+	campaigns, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, models.DeliveryRequest{})
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 1)
+	assert.Eventually(t, func() bool {
+		return len(cachedRepo.snapshot.Load().all) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.False(t, cachedRepo.Freshness().Expired)
+	require.NoError(t, cachedRepo.Flush(ctx))
+}
+
+// blockingRepository counts the loads of its campaigns, which wait for release
+type blockingRepository struct {
+	campaigns []models.CampaignWithRules
+	loads     atomic.Int32
+	release   chan struct{}
+}
+
+func (r *blockingRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
+	r.loads.Add(1)
+	<-r.release
+	return r.campaigns, nil
+}
+
+func TestCachedRepository_SnapshotRebuiltOnce(t *testing.T) {
+	hybridCache, err := NewHybridCache(CacheConfig{DefaultTTL: time.Minute, MemoryCacheSize: 100, EnableMemory: true})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	repo := &blockingRepository{campaigns: []models.CampaignWithRules{snapshotCampaign("spotify")}, release: make(chan struct{})}
+	cachedRepo := NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Minute, nil)
+
+	// Requests arriving before the first snapshot wait for a single build
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			campaigns, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, models.DeliveryRequest{})
+			assert.NoError(t, err)
+			assert.Len(t, campaigns, 1)
+		}()
+	}
+	assert.Eventually(t, func() bool { return repo.loads.Load() == 1 }, time.Second, time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+	require.NoError(t, cachedRepo.Flush(ctx))
+	assert.Equal(t, int32(1), repo.loads.Load())
+
+	// Once reset, requests are served from the reset snapshot while a single rebuild runs
+	repo.release = make(chan struct{})
+	repo.campaigns = append(repo.campaigns, snapshotCampaign("duolingo"))
+	require.NoError(t, cachedRepo.InvalidateCache(ctx))
+	for i := 0; i < 8; i++ {
+		campaigns, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, models.DeliveryRequest{})
+		require.NoError(t, err)
+		assert.Len(t, campaigns, 1)
+	}
+	close(repo.release)
+	assert.Eventually(t, func() bool {
+		return !cachedRepo.Freshness().Expired
+	}, time.Second, time.Millisecond)
+	require.NoError(t, cachedRepo.Flush(ctx))
+	assert.Equal(t, int32(2), repo.loads.Load())
+	assert.Len(t, cachedRepo.snapshot.Load().all, 2)
+}
+
+func TestCachedRepository_RunSnapshotRefresh(t *testing.T) {
+	hybridCache, err := NewHybridCache(CacheConfig{DefaultTTL: time.Minute, MemoryCacheSize: 100, EnableMemory: true})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := &stubRepository{campaigns: []models.CampaignWithRules{snapshotCampaign("spotify")}}
+	cachedRepo := NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Minute, nil)
+	require.NoError(t, cachedRepo.RefreshSnapshot(ctx))
+	require.NoError(t, cachedRepo.Flush(ctx))
+	first := cachedRepo.snapshot.Load()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cachedRepo.RunSnapshotRefresh(ctx, 5*time.Millisecond, nil)
+	}()
+
+	// Requests keep reading while a reloaded cache replaces the snapshot
+	readers := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-readers:
+					return
+				default:
+					_, err := cachedRepo.GetCompiledCampaignsByRequest(ctx, models.DeliveryRequest{Country: "us"})
+					assert.NoError(t, err)
+				}
+			}
+		}()
+	}
+
+	hybridCache.SetActiveCampaigns(ctx, []models.CampaignWithRules{snapshotCampaign("spotify"), snapshotCampaign("duolingo")}, time.Minute)
+	assert.Eventually(t, func() bool {
+		return cachedRepo.snapshot.Load() != first
+	}, time.Second, 5*time.Millisecond)
+	assert.Len(t, cachedRepo.snapshot.Load().all, 2)
+
+	close(readers)
+	cancel()
+	wg.Wait()
+	require.NoError(t, cachedRepo.Flush(context.Background()))
+}
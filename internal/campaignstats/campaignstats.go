@@ -0,0 +1,182 @@
+package campaignstats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// keyNamespace namespaces the per-minute delivery counters under the Redis key prefix,
+// {prefix}stats:{campaign}:{unix minute}
+const keyNamespace = "stats:"
+
+// Window is a rolling window deliveries are reported for
+type Window struct {
+	Name    string
+	Minutes int
+}
+
+// Windows are the rolling windows reported by Get, the longest one bounds how long counters are kept
+var Windows = []Window{
+	{Name: "1m", Minutes: 1},
+	{Name: "1h", Minutes: 60},
+	{Name: "24h", Minutes: 24 * 60},
+}
+
+// Stats are the deliveries of a campaign in each rolling window, keyed by window name
+type Stats struct {
+	CampaignID string           `json:"cid"`
+	Deliveries map[string]int64 `json:"deliveries"`
+	AsOf       time.Time        `json:"as_of"`
+}
+
+// Tracker counts deliveries per campaign in per-minute Redis counters shared by all replicas.
+// Deliveries are aggregated in memory and flushed in one pipeline per interval, so the
+// delivery path never waits on Redis.
+type Tracker struct {
+	client redis.Cmdable
+	prefix string
+	now    func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]map[int64]int64 // campaign ID to unix minute to deliveries
+}
+
+// NewTracker creates a tracker storing counters through client under keyPrefix
+func NewTracker(client redis.Cmdable, keyPrefix string) *Tracker {
+	return &Tracker{
+		client:  client,
+		prefix:  keyPrefix + keyNamespace,
+		now:     time.Now,
+		pending: make(map[string]map[int64]int64),
+	}
+}
+
+// RecordDeliveries counts a delivery of each campaign, it only touches memory
+func (t *Tracker) RecordDeliveries(campaignIDs []string) {
+	if len(campaignIDs) == 0 {
+		return
+	}
+	minute := t.now().Unix() / 60
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, id := range campaignIDs {
+		counts := t.pending[id]
+		if counts == nil {
+			counts = make(map[int64]int64)
+			t.pending[id] = counts
+		}
+		counts[minute]++
+	}
+}
+
+// Flush adds the pending deliveries to the Redis counters. Counters expire once they fall out
+// of the longest window. The counters are updated in a transaction, on failure none of them
+// changed and the deliveries are kept for the next flush.
+func (t *Tracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[string]map[int64]int64)
+	t.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ttl := time.Duration(Windows[len(Windows)-1].Minutes+2) * time.Minute
+	pipe := t.client.TxPipeline()
+	for id, counts := range pending {
+		for minute, count := range counts {
+			key := t.counterKey(id, minute)
+			pipe.IncrBy(ctx, key, count)
+			pipe.Expire(ctx, key, ttl)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		t.restore(pending)
+		return fmt.Errorf("failed to flush campaign stats: %w", err)
+	}
+	return nil
+}
+
+// restore merges deliveries that failed to flush back into the pending ones
+func (t *Tracker) restore(failed map[string]map[int64]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, counts := range failed {
+		pending := t.pending[id]
+		if pending == nil {
+			t.pending[id] = counts
+			continue
+		}
+		for minute, count := range counts {
+			pending[minute] += count
+		}
+	}
+}
+
+// Run flushes every interval until ctx is done, failed flushes are passed to onError.
+// Flush once more after stopping it to keep the last deliveries.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.Flush(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Get returns the deliveries of a campaign in each window. Counters have minute granularity:
+// a window covers its last full minutes plus the elapsed part of the current one, and the
+// minute sliding out of it is weighted by the part still inside. Deliveries not flushed yet
+// are not included.
+func (t *Tracker) Get(ctx context.Context, campaignID string) (Stats, error) {
+	now := t.now()
+	current := now.Unix() / 60
+	elapsed := float64(now.Unix()%60) / 60
+
+	// One counter per minute of the longest window, plus the one sliding out of it
+	longest := Windows[len(Windows)-1].Minutes
+	keys := make([]string, longest+1)
+	for i := range keys {
+		keys[i] = t.counterKey(campaignID, current-int64(i))
+	}
+	values, err := t.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to read campaign stats: %w", err)
+	}
+
+	counts := make([]int64, len(values))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			counts[i], _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+
+	stats := Stats{CampaignID: campaignID, Deliveries: make(map[string]int64, len(Windows)), AsOf: now.UTC()}
+	for _, window := range Windows {
+		var total int64
+		for _, count := range counts[:window.Minutes] {
+			total += count
+		}
+		total += int64(float64(counts[window.Minutes])*(1-elapsed) + 0.5)
+		stats.Deliveries[window.Name] = total
+	}
+	return stats, nil
+}
+
+func (t *Tracker) counterKey(campaignID string, minute int64) string {
+	return t.prefix + campaignID + ":" + strconv.FormatInt(minute, 10)
+}
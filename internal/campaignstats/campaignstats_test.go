@@ -0,0 +1,103 @@
+package campaignstats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTracker creates a tracker on an in-process Redis server with a controllable clock
+func newTestTracker(t *testing.T) (*miniredis.Miniredis, *Tracker, *time.Time) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(client, "adbeacon:")
+	tracker.now = func() time.Time { return now }
+	return server, tracker, &now
+}
+
+func TestTracker_RollingWindows(t *testing.T) {
+	_, tracker, now := newTestTracker(t)
+	ctx := context.Background()
+
+	// 4 deliveries 23 hours ago, 3 half an hour ago and 2 in the current minute
+	*now = now.Add(-23 * time.Hour)
+	tracker.RecordDeliveries([]string{"spotify", "spotify", "spotify", "spotify"})
+	*now = now.Add(23*time.Hour - 30*time.Minute)
+	tracker.RecordDeliveries([]string{"spotify", "spotify", "spotify", "duolingo"})
+	*now = now.Add(30 * time.Minute)
+	tracker.RecordDeliveries([]string{"spotify", "spotify"})
+	require.NoError(t, tracker.Flush(ctx))
+
+	stats, err := tracker.Get(ctx, "spotify")
+	require.NoError(t, err)
+	assert.Equal(t, "spotify", stats.CampaignID)
+	assert.Equal(t, map[string]int64{"1m": 2, "1h": 5, "24h": 9}, stats.Deliveries)
+
+	// Only the campaign asked for is counted
+	stats, err = tracker.Get(ctx, "duolingo")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"1m": 0, "1h": 1, "24h": 1}, stats.Deliveries)
+
+	// Deliveries slide out of the windows
+	*now = now.Add(2 * time.Hour)
+	stats, err = tracker.Get(ctx, "spotify")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"1m": 0, "1h": 0, "24h": 5}, stats.Deliveries)
+}
+
+func TestTracker_SlidingMinute(t *testing.T) {
+	_, tracker, now := newTestTracker(t)
+	ctx := context.Background()
+
+	tracker.RecordDeliveries([]string{"spotify", "spotify", "spotify", "spotify"})
+	require.NoError(t, tracker.Flush(ctx))
+
+	// A quarter into the next minute, three quarters of the previous one are still in the window
+	*now = now.Add(75 * time.Second)
+	stats, err := tracker.Get(ctx, "spotify")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Deliveries["1m"])
+}
+
+func TestTracker_CountersExpire(t *testing.T) {
+	server, tracker, _ := newTestTracker(t)
+
+	tracker.RecordDeliveries([]string{"spotify"})
+	require.NoError(t, tracker.Flush(context.Background()))
+
+	// Counters outlive the longest window, but not by much
+	keys := server.Keys()
+	require.Len(t, keys, 1)
+	assert.Equal(t, 24*time.Hour+2*time.Minute, server.TTL(keys[0]))
+}
+
+func TestTracker_FlushFailure(t *testing.T) {
+	server, tracker, _ := newTestTracker(t)
+	ctx := context.Background()
+
+	tracker.RecordDeliveries([]string{"spotify", "spotify"})
+	server.Close()
+	assert.Error(t, tracker.Flush(ctx))
+
+	// Deliveries of a failed flush are written by the next one
+	require.NoError(t, server.Restart())
+	tracker.RecordDeliveries([]string{"spotify"})
+	require.NoError(t, tracker.Flush(ctx))
+
+	stats, err := tracker.Get(ctx, "spotify")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Deliveries["1m"])
+
+	// Nothing pending, nothing to write
+	assert.NoError(t, tracker.Flush(ctx))
+}
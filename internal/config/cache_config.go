@@ -11,14 +11,27 @@ import (
 // GetCacheConfig creates cache configuration from environment variables
 func GetCacheConfig() cache.CacheConfig {
 	return cache.CacheConfig{
-		DefaultTTL:      getDurationEnv("CACHE_DEFAULT_TTL", 5*time.Minute),
-		MemoryCacheSize: getIntEnv("CACHE_MEMORY_SIZE", 1000),
-		RedisAddr:       getStringEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword:   getStringEnv("REDIS_PASSWORD", ""),
-		RedisDB:         getIntEnv("REDIS_DB", 0),
-		EnableMemory:    getBoolEnv("CACHE_ENABLE_MEMORY", true),
-		EnableRedis:     getBoolEnv("CACHE_ENABLE_REDIS", true),
-		RefreshInterval: getDurationEnv("CACHE_REFRESH_INTERVAL", 1*time.Minute),
+		DefaultTTL:          getDurationEnv("CACHE_DEFAULT_TTL", 5*time.Minute),
+		MemoryCacheSize:     getIntEnv("CACHE_MEMORY_SIZE", 1000),
+		RedisAddr:           getStringEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:       getSecretEnv("REDIS_PASSWORD", ""),
+		RedisDB:             getIntEnv("REDIS_DB", 0),
+		RedisClientName:     getStringEnv("REDIS_CLIENT_NAME", "adbeacon"),
+		RedisMaxRetries:     getIntEnv("REDIS_MAX_RETRIES", 3),
+		RedisHedgeDelay:     getDurationEnv("REDIS_HEDGE_DELAY", 0),
+		RedisTLS:            getBoolEnv("REDIS_TLS", false),
+		EnableMemory:        getBoolEnv("CACHE_ENABLE_MEMORY", true),
+		EnableRedis:         getBoolEnv("CACHE_ENABLE_REDIS", true),
+		RefreshInterval:     getDurationEnv("CACHE_REFRESH_INTERVAL", 1*time.Minute),
+		LoadBatchSize:       getIntEnv("CACHE_LOAD_BATCH_SIZE", 1000),
+		Region:              getStringEnv("CACHE_REGION", ""),
+		GlobalRedisAddr:     getStringEnv("REDIS_GLOBAL_ADDR", ""),
+		GlobalRedisPassword: getSecretEnv("REDIS_GLOBAL_PASSWORD", ""),
+		RedisShardAddrs:     getEnvList("REDIS_SHARD_ADDRS", nil),
+		KeyPrefix:           getStringEnv("REDIS_KEY_PREFIX", cache.DefaultKeyPrefix),
+		PersistPath:         getStringEnv("CACHE_PERSIST_PATH", ""),
+		PersistInterval:     getDurationEnv("CACHE_PERSIST_INTERVAL", time.Minute),
+		PersistMaxAge:       getDurationEnv("CACHE_PERSIST_MAX_AGE", 24*time.Hour),
 	}
 }
 
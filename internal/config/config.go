@@ -4,14 +4,35 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/fraud"
 )
 
 type GeneralConfig struct {
-	Env      string
-	LogLevel string
-	Port     int
+	// Env selects the profile changing defaults: dev, staging or prod
+	Env       string
+	LogLevel  string
+	LogFormat string // logfmt or json
+	Port      int
+	// DebugEnabled exposes pprof, expvar and build info on the internal DebugPort
+	DebugEnabled bool
+	DebugPort    int
+	// Repository is the campaign source: postgres, or mock to serve sample campaigns without a database
+	Repository string
+	// TrustedProxies are the networks of the proxies in front of the server, X-Forwarded-For is
+	// only believed for requests coming through them
+	TrustedProxies []string
+}
+
+type LogRedactionConfig struct {
+	// HashFields are replaced with a salted hash in logs, e.g. client_ip, remote_addr
+	HashFields []string
+	// DropFields are removed from logs entirely, e.g. user_agent
+	DropFields []string
+	Salt       string
 }
 
 type DatabaseConfig struct {
@@ -25,45 +46,835 @@ type DatabaseConfig struct {
 	MaxIdleConns    int
 	ConnMaxLifetime int // in minutes
 	ConnMaxIdleTime int // in minutes
+	// Queries slower than this are logged, 0 disables slow query logging
+	SlowQueryThreshold int // in milliseconds
+	// PgBouncerMode makes the connection safe for transaction-pooling PgBouncer
+	PgBouncerMode bool
+	// DirectHost and DirectPort bypass the pooler for migrations and database creation,
+	// since those rely on session-level advisory locks. They default to Host and Port.
+	DirectHost string
+	DirectPort int
+	// ApplicationName is reported to Postgres to identify our connections
+	ApplicationName string
+	// HedgeDelay sends a second read when the first hasn't returned within it, 0 disables hedging
+	HedgeDelay int // in milliseconds
+}
+
+type AccessLogConfig struct {
+	Enabled      bool
+	SampleRate   float64 // fraction of successful requests logged, 0-1
+	ExcludePaths []string
+}
+
+type ErrorReportingConfig struct {
+	// SentryDSN enables error reporting to Sentry when set
+	SentryDSN         string
+	SentryEnvironment string
+}
+
+type SLOConfig struct {
+	AvailabilityObjective float64 // fraction of non-5xx delivery requests, e.g. 0.999
+	LatencyTarget         int     // in milliseconds
+	LatencyObjective      float64 // fraction of delivery requests under LatencyTarget, e.g. 0.99
+}
+
+type MetricsConfig struct {
+	// ClientLabel attributes requests to clients in metrics: none, publisher or api_key
+	ClientLabel string
+	// ClientLabelLimit caps distinct client label values, further clients are reported as "other"
+	ClientLabelLimit int
+	// AppLabelLimit, CountryLabelLimit and OSLabelLimit cap the distinct values of the labels of
+	// the delivery metrics, values seen once the limit is reached are reported as "other"
+	AppLabelLimit     int
+	CountryLabelLimit int
+	OSLabelLimit      int
+	// AppLabelAllowlist lists apps always reported under their own label
+	AppLabelAllowlist []string
+	// Histogram bucket upper bounds in seconds, empty uses the built-in defaults
+	HTTPDurationBuckets     []float64
+	DatabaseDurationBuckets []float64
+	// NativeHistogramBucketFactor also exposes the duration histograms as native histograms with
+	// buckets growing by this factor, 0 disables them
+	NativeHistogramBucketFactor float64
+}
+
+type HealthConfig struct {
+	HistorySize       int // number of recent results kept per component
+	FailureThreshold  int // consecutive failed checks before a component is reported unhealthy
+	RecoveryThreshold int // consecutive passed checks before a component is reported healthy again
+	FlapThreshold     int // status changes within the history that mark a component as flapping
+}
+
+type WatchdogConfig struct {
+	Interval      int // in seconds
+	MaxGoroutines int // 0 disables the goroutine check
+	MaxHeapMB     int // 0 disables the heap check
+}
+
+type CircuitBreakerConfig struct {
+	Enabled             bool
+	FailureThreshold    int // consecutive database failures that open the breaker
+	OpenTimeout         int // in seconds, how long the breaker stays open before trial requests
+	HalfOpenMaxRequests int // trial requests allowed, and successes needed to close again
+}
+
+type EndpointConfig struct {
+	// DeliveryTimeout is the deadline budget of the delivery endpoint, 0 disables it
+	DeliveryTimeout int // in milliseconds
+	// DeliveryTimeoutEmpty answers timed out delivery requests with an empty 204 instead of a 504
+	DeliveryTimeoutEmpty bool
+	// DeliveryMaxInFlight caps concurrent delivery requests, further requests get a 503, 0 disables it
+	DeliveryMaxInFlight int
+	// RetryAfter is advertised to clients whose requests were rejected
+	RetryAfter int // in seconds
+	// Middlewares is the ordered delivery endpoint middleware chain, outermost first, of timeout,
+	// rate_limit, circuit_breaker, tracing, logging, metrics and validation
+	Middlewares []string
+	// RateLimit caps delivery requests per second when rate_limit is in the chain, 0 disables it
+	RateLimit      float64
+	RateLimitBurst int
+}
+
+type LoadShedConfig struct {
+	Enabled          bool
+	Interval         int     // in milliseconds, how often the shed fraction is recomputed
+	LatencyThreshold int     // in milliseconds, smoothed delivery latency above which requests are shed, 0 disables it
+	CPUThreshold     float64 // process CPU utilization (0-1) above which requests are shed, 0 disables it
+	MaxShedFraction  float64 // upper bound of the fraction of delivery requests shed
+}
+
+type ValidationConfig struct {
+	CountryCodeLengths []int    // accepted country code lengths, e.g. 2,3 to also allow ISO alpha-3 codes
+	AllowedOS          []string // empty allows any os
+	MaxAppLength       int      // 0 disables the check
+	OSAliases          []string // alias:os entries extending the built-in OS aliases, e.g. "tizen os:tizen"
+}
+
+type RequestLimitsConfig struct {
+	MaxBodyBytes   int64 // larger request bodies get a 413, 0 disables the check
+	MaxQueryParams int   // more query parameters get a 414, 0 disables the check
+	MaxHeaderBytes int   // larger request headers get a 431, 0 disables the check
+}
+
+type ReadinessConfig struct {
+	SelfTestDeliveries int    // successful self-test deliveries required before ready, 0 skips the self-test
+	SelfTestCountry    string // request used for self-test deliveries
+	SelfTestOS         string
+	SelfTestApp        string
+	RetryInterval      int // in seconds, wait between failed warm-up attempts
+}
+
+type ReloadConfig struct {
+	// File is the env file loaded at startup and re-read on reload
+	File string
+	// PollInterval is how often File is checked for changes, 0 only reloads on SIGHUP
+	PollInterval int // in seconds
+}
+
+type SecretsConfig struct {
+	// Provider resolves DB_PASSWORD, REDIS_PASSWORD, REDIS_GLOBAL_PASSWORD, WEBHOOK_SECRET and
+	// ADMIN_TOKEN: env (variables or *_FILE paths) or vault
+	Provider string
+	// Vault KV version 2 secret whose fields db_password, redis_password, redis_global_password,
+	// webhook_secret and admin_token hold the secrets
+	VaultAddr    string
+	VaultToken   string
+	VaultMount   string
+	VaultPath    string
+	VaultTimeout int // in seconds
+}
+
+type AdminConfig struct {
+	// Token is the bearer token every /v1/admin/ request must carry, the admin API rejects every
+	// request without one set
+	Token string
+}
+
+type IdempotencyConfig struct {
+	TTL        int // in hours, how long admin mutation responses are kept for replay
+	MaxEntries int // number of kept responses, the oldest are forgotten beyond it
+}
+
+type TrackingConfig struct {
+	Enabled bool
+	// LogEvents writes a log line per tracked impression and click
+	LogEvents bool
+	// ClickAllowedHosts are the hosts, with their subdomains, clicks may redirect to
+	ClickAllowedHosts []string
+}
+
+type EventsConfig struct {
+	// Enabled publishes delivery, impression and click events to Kafka
+	Enabled bool
+	// KafkaRESTURL is the Kafka REST Proxy events are produced through
+	KafkaRESTURL  string
+	KafkaTopic    string
+	QueueSize     int // events waiting for export, further events are dropped
+	BatchSize     int
+	FlushInterval int // in milliseconds
+	ExportTimeout int // in milliseconds
+	MaxAttempts   int // export attempts of a batch before its events are given up on
+	RetryDelay    int // in milliseconds, backoff before the first retry
+}
+
+type DecisionLogConfig struct {
+	// Enabled writes sampled delivery decisions to a local JSON lines file
+	Enabled    bool
+	Path       string
+	SampleRate float64 // fraction (0-1) of decisions logged, failed requests are always logged
+	MaxSize    int     // in megabytes, the file is rotated before growing beyond it
+	MaxAge     int     // in minutes, the file is rotated once it is this old
+	MaxFiles   int     // rotated files kept locally, 0 keeps all
+	// S3Bucket ships rotated files to S3 when set, S3Endpoint targets S3 compatible stores
+	S3Bucket   string
+	S3Prefix   string
+	S3Region   string
+	S3Endpoint string
+}
+
+type CampaignStatsConfig struct {
+	// Enabled keeps rolling per-campaign delivery counters in Redis, it requires CACHE_ENABLE_REDIS
+	Enabled       bool
+	FlushInterval int // in milliseconds, how often counted deliveries are written to Redis
+}
+
+type SchedulerConfig struct {
+	// Enabled applies campaign start and end times and delivery budgets
+	Enabled  bool
+	Interval int // in seconds, how often campaigns are checked
+}
+
+type DedupConfig struct {
+	// Enabled replays the first response to delivery requests repeating a nonce, shared through
+	// Redis with CACHE_ENABLE_REDIS
+	Enabled bool
+	Window  int // in seconds, how long nonces are remembered
+}
+
+type ReplayProtectionConfig struct {
+	// Enabled rejects delivery and tracking requests with an API key whose ts parameter is
+	// missing, not signed with the signing secret of the key or further than MaxSkew from the
+	// server clock
+	Enabled bool
+	MaxSkew int // in seconds
+}
+
+type DeliveryCacheConfig struct {
+	// Enabled sets Cache-Control, Age and Vary on delivery responses so CDN edges can cache them,
+	// responses to API keys and failed requests are never cached
+	Enabled bool
+	MaxAge  int // in seconds, how long caches may serve a delivery response
 }
 
-type appConfig struct {
-	GeneralConfig  GeneralConfig
-	DatabaseConfig DatabaseConfig
+type CreativeProxyConfig struct {
+	// Enabled serves campaign images through /v1/creative/{id} and points delivered campaigns to it
+	Enabled bool
+	// BaseURL is the public URL of the ad server images are served from, empty for relative URLs
+	BaseURL    string
+	CacheSize  int // in megabytes, the size of the cached images
+	MaxSize    int // in kilobytes, larger images aren't served
+	DefaultTTL int // in seconds, how long images without a max-age are cached
+	Timeout    int // in milliseconds, for fetching an image
 }
 
-// LoadConfigs loads the configurations from the environment variables
-func LoadConfigs() {
-	err := godotenv.Load()
+type CreativeValidationConfig struct {
+	// Enabled checks the images of campaigns created or changed through the admin API: https,
+	// reachable, an image and within MaxSize
+	Enabled bool
+	MaxSize int // in kilobytes, larger images are rejected
+	Timeout int // in milliseconds, for fetching an image
+	// CDNPrefix is prepended to the host and path of checked images, empty keeps them as they are
+	CDNPrefix     string
+	CheckInterval int // in seconds, how often the images of active campaigns are checked again, 0 to never
+}
+
+type BlocklistConfig struct {
+	// Enabled answers delivery requests from blocklisted apps, countries and IP ranges with 204
+	Enabled         bool
+	RefreshInterval int // in seconds, how often the blocklist is reloaded from the database
+}
+
+// CampaignLimitsConfig bounds the campaigns served, enforced when the cache loads campaigns and
+// when the admin API changes them. 0 disables a limit.
+type CampaignLimitsConfig struct {
+	// MaxActive is the number of active campaigns served, the least recently changed ones beyond
+	// it are left out of the cache
+	MaxActive int
+	// MaxRules is the number of targeting rules per campaign, campaigns with more aren't served
+	MaxRules int
+	// MaxRuleValues is the number of values per targeting rule, campaigns with more aren't served
+	MaxRuleValues int
+}
+
+type ReachConfig struct {
+	// Enabled keeps daily histograms of the request dimensions in Redis to estimate campaign reach,
+	// it requires CACHE_ENABLE_REDIS
+	Enabled       bool
+	FlushInterval int // in milliseconds, how often counted requests are written to Redis
+	Days          int // full days reach estimates average, histograms are kept as long
+}
+
+type FraudConfig struct {
+	// Enabled filters invalid traffic out of delivery requests, blocked requests get no campaigns
+	Enabled bool
+	// DryRun counts and logs invalid traffic without blocking it
+	DryRun  bool
+	Filters []string // filters run in order, see the fraud.Filter* names
+	// DatacenterCIDRs and the networks listed in DatacenterCIDRsFile are blocked by the datacenter_ip filter
+	DatacenterCIDRs     []string
+	DatacenterCIDRsFile string
+	BotAgents           []string // user agent fragments blocked by the bot_user_agent filter
+	CarrierCountries    []string // carrier:country|country entries checked by the geo_carrier filter
+}
+
+type MatchMemoConfig struct {
+	// Enabled answers bursts of identical delivery requests from recently matched campaigns
+	Enabled    bool
+	TTL        int // in milliseconds, how long matches are remembered and may be stale
+	MaxEntries int // number of remembered requests, the oldest are evicted beyond it
+}
+
+type ParallelMatchConfig struct {
+	// Threshold is the number of candidate campaigns from which a request is matched in
+	// parallel, 0 matches every request sequentially
+	Threshold int
+	Workers   int // goroutines shared by all requests, 0 uses GOMAXPROCS
+}
+
+type RuntimeConfig struct {
+	AutoMaxProcs  bool    // set GOMAXPROCS from the container's CPU quota
+	AutoMemLimit  bool    // set GOMEMLIMIT from the container's memory limit
+	MemLimitRatio float64 // share of the memory limit used as GOMEMLIMIT
+}
+
+type ConsentConfig struct {
+	// Enabled evaluates the gdpr and gdpr_consent parameters of delivery requests, without it
+	// requests are treated as outside GDPR
+	Enabled  bool
+	VendorID int // TCF vendor ID users must consent to, 0 to only check the purposes
+}
+
+type AnomalyConfig struct {
+	// Enabled watches the fill rate and success rate of deliveries and alerts on significant drops
+	Enabled         bool
+	Window          int     // in seconds, the recent window compared against the baseline
+	Baseline        int     // in seconds, the window before the recent one
+	MinRequests     int     // requests both windows need before they are compared
+	FillRateDrop    float64 // relative fill rate drop (0-1) that fires an alert
+	SuccessRateDrop float64 // absolute success rate drop (0-1) that fires an alert
+	CheckInterval   int     // in seconds
+	// WebhookURL additionally receives alerts as JSON POSTs, they are always logged and exported as metrics
+	WebhookURL string
+}
+
+type WebhookConfig struct {
+	// URLs receive a POST for every created, paused and resumed campaign, webhooks are off without any
+	URLs []string
+	// Secret signs the requests with HMAC-SHA256, unsigned when empty
+	Secret      string
+	QueueSize   int // events waiting for delivery, further events are dead-lettered
+	Timeout     int // in milliseconds, of each delivery attempt
+	MaxAttempts int // delivery attempts per URL before an event is dead-lettered
+	RetryDelay  int // in milliseconds, backoff before the first retry
+}
+
+type QuotaConfig struct {
+	// Enabled counts delivered impressions per API key and enforces monthly quotas
+	Enabled      bool
+	DefaultLimit int      // monthly deliveries of API keys without their own limit, 0 is unlimited
+	Limits       []string // key_id:limit monthly limits of individual API keys
+	WarnFraction float64  // fraction of a limit after which responses carry X-Quota-Warning
+	// FlushInterval in milliseconds, how often usage is written to and read back from Redis
+	FlushInterval int
+}
+
+type SigningConfig struct {
+	// Secrets are key_id:secret entries, delivery responses to API keys with a secret are signed
+	// with HMAC-SHA256 and, with replay protection, their requests are verified with it
+	Secrets []string
+}
+
+type ReportingConfig struct {
+	// Enabled aggregates deliveries, impressions and clicks into hourly rows for /v1/admin/reports
+	Enabled       bool
+	FlushInterval int // in seconds, how often aggregated rows are added to the database
+}
+
+type RetryConfig struct {
+	MaxAttempts int // total attempts of a repository read, 1 disables retries
+	BaseDelay   int // in milliseconds, backoff before the first retry
+	MaxDelay    int // in milliseconds
+}
+
+// Config holds the application configuration, loaded once at startup by Load and passed
+// explicitly to the components that need it
+type Config struct {
+	GeneralConfig            GeneralConfig
+	LogRedactionConfig       LogRedactionConfig
+	DatabaseConfig           DatabaseConfig
+	AccessLogConfig          AccessLogConfig
+	ErrorReportingConfig     ErrorReportingConfig
+	SLOConfig                SLOConfig
+	MetricsConfig            MetricsConfig
+	HealthConfig             HealthConfig
+	WatchdogConfig           WatchdogConfig
+	CircuitBreakerConfig     CircuitBreakerConfig
+	EndpointConfig           EndpointConfig
+	RetryConfig              RetryConfig
+	LoadShedConfig           LoadShedConfig
+	ValidationConfig         ValidationConfig
+	IdempotencyConfig        IdempotencyConfig
+	RequestLimitsConfig      RequestLimitsConfig
+	ReadinessConfig          ReadinessConfig
+	ReloadConfig             ReloadConfig
+	SecretsConfig            SecretsConfig
+	AdminConfig              AdminConfig
+	TrackingConfig           TrackingConfig
+	EventsConfig             EventsConfig
+	DecisionLogConfig        DecisionLogConfig
+	CampaignStatsConfig      CampaignStatsConfig
+	ReachConfig              ReachConfig
+	SchedulerConfig          SchedulerConfig
+	BlocklistConfig          BlocklistConfig
+	CampaignLimitsConfig     CampaignLimitsConfig
+	DedupConfig              DedupConfig
+	ReplayProtectionConfig   ReplayProtectionConfig
+	DeliveryCacheConfig      DeliveryCacheConfig
+	CreativeProxyConfig      CreativeProxyConfig
+	CreativeValidationConfig CreativeValidationConfig
+	ReportingConfig          ReportingConfig
+	QuotaConfig              QuotaConfig
+	SigningConfig            SigningConfig
+	WebhookConfig            WebhookConfig
+	AnomalyConfig            AnomalyConfig
+	FraudConfig              FraudConfig
+	ConsentConfig            ConsentConfig
+	MatchMemoConfig          MatchMemoConfig
+	ParallelMatchConfig      ParallelMatchConfig
+	RuntimeConfig            RuntimeConfig
+	CacheConfig              cache.CacheConfig
+}
+
+// Load loads the configuration from the env file named by CONFIG_FILE (.env by default)
+// and the environment variables
+func Load() *Config {
+	return LoadFrom(getEnv("CONFIG_FILE", ".env"))
+}
+
+// LoadFrom loads the configuration from the env file at path and the environment variables,
+// variables already set in the environment take precedence over the file
+func LoadFrom(path string) *Config {
+	err := godotenv.Load(path)
 	if err != nil {
 		log.Printf("Warning: Error loading .env files: %v", err)
 	}
 
-	loadGeneralConfigs()
-	loadDatabaseConfigs()
+	c := &Config{}
+	c.loadGeneralConfigs()
+	c.loadLogRedactionConfigs()
+	c.loadDatabaseConfigs()
+	c.loadAccessLogConfigs()
+	c.loadErrorReportingConfigs()
+	c.loadSLOConfigs()
+	c.loadMetricsConfigs()
+	c.loadHealthConfigs()
+	c.loadWatchdogConfigs()
+	c.loadCircuitBreakerConfigs()
+	c.loadEndpointConfigs()
+	c.loadRetryConfigs()
+	c.loadLoadShedConfigs()
+	c.loadValidationConfigs()
+	c.loadAdminConfigs()
+	c.loadIdempotencyConfigs()
+	c.loadRequestLimitsConfigs()
+	c.loadReadinessConfigs()
+	c.loadReloadConfigs(path)
+	c.loadSecretsConfigs()
+	c.loadTrackingConfigs()
+	c.loadEventsConfigs()
+	c.loadDecisionLogConfigs()
+	c.loadCampaignStatsConfigs()
+	c.loadReachConfigs()
+	c.loadSchedulerConfigs()
+	c.loadBlocklistConfigs()
+	c.loadCampaignLimitsConfigs()
+	c.loadDedupConfigs()
+	c.loadReplayProtectionConfigs()
+	c.loadDeliveryCacheConfigs()
+	c.loadCreativeProxyConfigs()
+	c.loadCreativeValidationConfigs()
+	c.loadReportingConfigs()
+	c.loadQuotaConfigs()
+	c.loadSigningConfigs()
+	c.loadWebhookConfigs()
+	c.loadAnomalyConfigs()
+	c.loadFraudConfigs()
+	c.loadConsentConfigs()
+	c.loadMatchMemoConfigs()
+	c.loadParallelMatchConfigs()
+	c.loadRuntimeConfigs()
+	c.CacheConfig = GetCacheConfig()
+	c.applyProfile()
+	return c
 }
 
-var AppConfigInstance appConfig
-
 // loadGeneralConfigs loads the general configurations from the environment variables
-func loadGeneralConfigs() {
-	AppConfigInstance.GeneralConfig.Env = getEnv("APP_ENV", "dev")
-	AppConfigInstance.GeneralConfig.LogLevel = getEnv("LOG_LEVEL", "info")
-	AppConfigInstance.GeneralConfig.Port = getEnvInt("PORT", 8080)
+func (c *Config) loadGeneralConfigs() {
+	c.GeneralConfig.Env = getEnv("APP_ENV", "dev")
+	c.GeneralConfig.LogLevel = getEnv("LOG_LEVEL", "info")
+	c.GeneralConfig.LogFormat = getEnv("LOG_FORMAT", "logfmt")
+	c.GeneralConfig.Port = getEnvInt("PORT", 8080)
+	c.GeneralConfig.DebugEnabled = getEnvBool("DEBUG_ENABLED", false)
+	c.GeneralConfig.DebugPort = getEnvInt("DEBUG_PORT", 6060)
+	c.GeneralConfig.Repository = getEnv("REPOSITORY", RepositoryPostgres)
+	c.GeneralConfig.TrustedProxies = getEnvList("TRUSTED_PROXIES", nil)
+}
+
+// loadLogRedactionConfigs loads the log field redaction configurations from the environment variables
+func (c *Config) loadLogRedactionConfigs() {
+	c.LogRedactionConfig.HashFields = getEnvList("LOG_REDACT_HASH_FIELDS", nil)
+	c.LogRedactionConfig.DropFields = getEnvList("LOG_REDACT_DROP_FIELDS", nil)
+	c.LogRedactionConfig.Salt = getEnv("LOG_REDACT_SALT", "")
 }
 
 // loadDatabaseConfigs loads the database configurations from the environment variables
-func loadDatabaseConfigs() {
-	AppConfigInstance.DatabaseConfig.Host = getEnv("DB_HOST", "localhost")
-	AppConfigInstance.DatabaseConfig.Port = getEnvInt("DB_PORT", 5432)
-	AppConfigInstance.DatabaseConfig.User = getEnv("DB_USER", "adbeacon_dev_user")
-	AppConfigInstance.DatabaseConfig.Password = getEnv("DB_PASSWORD", "")
-	AppConfigInstance.DatabaseConfig.DBName = getEnv("DB_NAME", "adbeacon")
-	AppConfigInstance.DatabaseConfig.SSLMode = getEnv("DB_SSLMODE", "disable")
-	AppConfigInstance.DatabaseConfig.MaxOpenConns = getEnvInt("DB_MAX_OPEN_CONNS", 25)
-	AppConfigInstance.DatabaseConfig.MaxIdleConns = getEnvInt("DB_MAX_IDLE_CONNS", 25)
-	AppConfigInstance.DatabaseConfig.ConnMaxLifetime = getEnvInt("DB_CONN_MAX_LIFETIME", 5)
-	AppConfigInstance.DatabaseConfig.ConnMaxIdleTime = getEnvInt("DB_CONN_MAX_IDLE_TIME", 5)
+func (c *Config) loadDatabaseConfigs() {
+	c.DatabaseConfig.Host = getEnv("DB_HOST", "localhost")
+	c.DatabaseConfig.Port = getEnvInt("DB_PORT", 5432)
+	c.DatabaseConfig.User = getEnv("DB_USER", "adbeacon_dev_user")
+	c.DatabaseConfig.Password = getSecretEnv("DB_PASSWORD", "")
+	c.DatabaseConfig.DBName = getEnv("DB_NAME", "adbeacon")
+	c.DatabaseConfig.SSLMode = getEnv("DB_SSLMODE", "disable")
+	c.DatabaseConfig.MaxOpenConns = getEnvInt("DB_MAX_OPEN_CONNS", 25)
+	c.DatabaseConfig.MaxIdleConns = getEnvInt("DB_MAX_IDLE_CONNS", 25)
+	c.DatabaseConfig.ConnMaxLifetime = getEnvInt("DB_CONN_MAX_LIFETIME", 5)
+	c.DatabaseConfig.ConnMaxIdleTime = getEnvInt("DB_CONN_MAX_IDLE_TIME", 5)
+	c.DatabaseConfig.SlowQueryThreshold = getEnvInt("DB_SLOW_QUERY_THRESHOLD_MS", 200)
+	c.DatabaseConfig.PgBouncerMode = getEnvBool("DB_PGBOUNCER_MODE", false)
+	c.DatabaseConfig.DirectHost = getEnv("DB_DIRECT_HOST", c.DatabaseConfig.Host)
+	c.DatabaseConfig.DirectPort = getEnvInt("DB_DIRECT_PORT", c.DatabaseConfig.Port)
+	c.DatabaseConfig.ApplicationName = getEnv("DB_APPLICATION_NAME", "adbeacon")
+	c.DatabaseConfig.HedgeDelay = getEnvInt("DB_HEDGE_DELAY_MS", 0)
+}
+
+// loadAccessLogConfigs loads the access log configurations from the environment variables
+func (c *Config) loadAccessLogConfigs() {
+	c.AccessLogConfig.Enabled = getEnvBool("ACCESS_LOG_ENABLED", true)
+	c.AccessLogConfig.SampleRate = getEnvFloat("ACCESS_LOG_SAMPLE_RATE", 1.0)
+	c.AccessLogConfig.ExcludePaths = getEnvList("ACCESS_LOG_EXCLUDE_PATHS", []string{"/health", "/metrics"})
+}
+
+// loadErrorReportingConfigs loads the error reporting configurations from the environment variables
+func (c *Config) loadErrorReportingConfigs() {
+	c.ErrorReportingConfig.SentryDSN = getEnv("SENTRY_DSN", "")
+	c.ErrorReportingConfig.SentryEnvironment = getEnv("SENTRY_ENVIRONMENT", c.GeneralConfig.Env)
+}
+
+// loadSLOConfigs loads the service level objectives from the environment variables
+func (c *Config) loadSLOConfigs() {
+	c.SLOConfig.AvailabilityObjective = getEnvFloat("SLO_AVAILABILITY_OBJECTIVE", 0.999)
+	c.SLOConfig.LatencyTarget = getEnvInt("SLO_LATENCY_TARGET_MS", 50)
+	c.SLOConfig.LatencyObjective = getEnvFloat("SLO_LATENCY_OBJECTIVE", 0.99)
+}
+
+// loadMetricsConfigs loads the metrics configurations from the environment variables
+func (c *Config) loadMetricsConfigs() {
+	c.MetricsConfig.ClientLabel = strings.ToLower(getEnv("METRICS_CLIENT_LABEL", "none"))
+	c.MetricsConfig.ClientLabelLimit = getEnvInt("METRICS_CLIENT_LABEL_LIMIT", 100)
+	c.MetricsConfig.AppLabelLimit = getEnvInt("METRICS_APP_LABEL_LIMIT", 200)
+	c.MetricsConfig.CountryLabelLimit = getEnvInt("METRICS_COUNTRY_LABEL_LIMIT", 250)
+	c.MetricsConfig.OSLabelLimit = getEnvInt("METRICS_OS_LABEL_LIMIT", 20)
+	c.MetricsConfig.AppLabelAllowlist = getEnvList("METRICS_APP_LABEL_ALLOWLIST", nil)
+	c.MetricsConfig.HTTPDurationBuckets = getEnvFloatList("METRICS_HTTP_DURATION_BUCKETS", nil)
+	c.MetricsConfig.DatabaseDurationBuckets = getEnvFloatList("METRICS_DB_DURATION_BUCKETS", nil)
+	c.MetricsConfig.NativeHistogramBucketFactor = getEnvFloat("METRICS_NATIVE_HISTOGRAM_BUCKET_FACTOR", 0)
+}
+
+// loadHealthConfigs loads the health check history configurations from the environment variables
+func (c *Config) loadHealthConfigs() {
+	c.HealthConfig.HistorySize = getEnvInt("HEALTH_HISTORY_SIZE", 20)
+	c.HealthConfig.FailureThreshold = getEnvInt("HEALTH_FAILURE_THRESHOLD", 3)
+	c.HealthConfig.RecoveryThreshold = getEnvInt("HEALTH_RECOVERY_THRESHOLD", 2)
+	c.HealthConfig.FlapThreshold = getEnvInt("HEALTH_FLAP_THRESHOLD", 4)
+}
+
+// loadWatchdogConfigs loads the runtime watchdog configurations from the environment variables
+func (c *Config) loadWatchdogConfigs() {
+	c.WatchdogConfig.Interval = getEnvInt("WATCHDOG_INTERVAL_SECONDS", 15)
+	c.WatchdogConfig.MaxGoroutines = getEnvInt("WATCHDOG_MAX_GOROUTINES", 10000)
+	c.WatchdogConfig.MaxHeapMB = getEnvInt("WATCHDOG_MAX_HEAP_MB", 1024)
+}
+
+// loadCircuitBreakerConfigs loads the repository circuit breaker configurations from the environment variables
+func (c *Config) loadCircuitBreakerConfigs() {
+	c.CircuitBreakerConfig.Enabled = getEnvBool("CIRCUIT_BREAKER_ENABLED", true)
+	c.CircuitBreakerConfig.FailureThreshold = getEnvInt("CIRCUIT_BREAKER_FAILURE_THRESHOLD", 5)
+	c.CircuitBreakerConfig.OpenTimeout = getEnvInt("CIRCUIT_BREAKER_OPEN_TIMEOUT_SECONDS", 30)
+	c.CircuitBreakerConfig.HalfOpenMaxRequests = getEnvInt("CIRCUIT_BREAKER_HALF_OPEN_REQUESTS", 1)
+}
+
+// loadEndpointConfigs loads the endpoint deadline configurations from the environment variables
+func (c *Config) loadEndpointConfigs() {
+	c.EndpointConfig.DeliveryTimeout = getEnvInt("DELIVERY_TIMEOUT_MS", 0)
+	c.EndpointConfig.DeliveryTimeoutEmpty = getEnvBool("DELIVERY_TIMEOUT_EMPTY_RESPONSE", true)
+	c.EndpointConfig.DeliveryMaxInFlight = getEnvInt("DELIVERY_MAX_IN_FLIGHT", 1000)
+	c.EndpointConfig.RetryAfter = getEnvInt("RETRY_AFTER_SECONDS", 1)
+	c.EndpointConfig.Middlewares = getEnvList("DELIVERY_ENDPOINT_MIDDLEWARES", []string{"timeout", "logging", "metrics", "validation"})
+	c.EndpointConfig.RateLimit = getEnvFloat("DELIVERY_RATE_LIMIT_RPS", 0)
+	c.EndpointConfig.RateLimitBurst = getEnvInt("DELIVERY_RATE_LIMIT_BURST", 100)
+}
+
+// loadRetryConfigs loads the repository retry configurations from the environment variables
+func (c *Config) loadRetryConfigs() {
+	c.RetryConfig.MaxAttempts = getEnvInt("REPOSITORY_RETRY_MAX_ATTEMPTS", 3)
+	c.RetryConfig.BaseDelay = getEnvInt("REPOSITORY_RETRY_BASE_DELAY_MS", 10)
+	c.RetryConfig.MaxDelay = getEnvInt("REPOSITORY_RETRY_MAX_DELAY_MS", 100)
+}
+
+// loadLoadShedConfigs loads the load shedding configurations from the environment variables
+func (c *Config) loadLoadShedConfigs() {
+	c.LoadShedConfig.Enabled = getEnvBool("LOAD_SHED_ENABLED", false)
+	c.LoadShedConfig.Interval = getEnvInt("LOAD_SHED_INTERVAL_MS", 1000)
+	c.LoadShedConfig.LatencyThreshold = getEnvInt("LOAD_SHED_LATENCY_THRESHOLD_MS", 100)
+	c.LoadShedConfig.CPUThreshold = getEnvFloat("LOAD_SHED_CPU_THRESHOLD", 0.9)
+	c.LoadShedConfig.MaxShedFraction = getEnvFloat("LOAD_SHED_MAX_SHED_FRACTION", 0.9)
+}
+
+// loadValidationConfigs loads the delivery request validation rules from the environment variables
+func (c *Config) loadValidationConfigs() {
+	c.ValidationConfig.CountryCodeLengths = getEnvIntList("VALIDATION_COUNTRY_CODE_LENGTHS", []int{2})
+	c.ValidationConfig.AllowedOS = getEnvList("VALIDATION_ALLOWED_OS", nil)
+	c.ValidationConfig.MaxAppLength = getEnvInt("VALIDATION_MAX_APP_LENGTH", 0)
+	c.ValidationConfig.OSAliases = getEnvList("OS_ALIASES", nil)
+}
+
+// loadAdminConfigs loads the admin API configurations from the environment variables, the
+// token may be read from ADMIN_TOKEN_FILE
+func (c *Config) loadAdminConfigs() {
+	c.AdminConfig.Token = getSecretEnv("ADMIN_TOKEN", "")
+}
+
+// loadIdempotencyConfigs loads the Idempotency-Key configurations from the environment variables
+func (c *Config) loadIdempotencyConfigs() {
+	c.IdempotencyConfig.TTL = getEnvInt("IDEMPOTENCY_TTL_HOURS", 24)
+	c.IdempotencyConfig.MaxEntries = getEnvInt("IDEMPOTENCY_MAX_ENTRIES", 10000)
+}
+
+// loadRequestLimitsConfigs loads the request size limit configurations from the environment variables
+func (c *Config) loadRequestLimitsConfigs() {
+	c.RequestLimitsConfig.MaxBodyBytes = int64(getEnvInt("MAX_REQUEST_BODY_BYTES", 1<<20))
+	c.RequestLimitsConfig.MaxQueryParams = getEnvInt("MAX_QUERY_PARAMS", 50)
+	c.RequestLimitsConfig.MaxHeaderBytes = getEnvInt("MAX_HEADER_BYTES", 16<<10)
+}
+
+// loadSecretsConfigs loads the secret provider configurations from the environment variables
+func (c *Config) loadSecretsConfigs() {
+	c.SecretsConfig.Provider = getEnv("SECRETS_PROVIDER", SecretsProviderEnv)
+	c.SecretsConfig.VaultAddr = getEnv("VAULT_ADDR", "")
+	c.SecretsConfig.VaultToken = getSecretEnv("VAULT_TOKEN", "")
+	c.SecretsConfig.VaultMount = getEnv("VAULT_MOUNT", "secret")
+	c.SecretsConfig.VaultPath = getEnv("VAULT_SECRET_PATH", "adbeacon")
+	c.SecretsConfig.VaultTimeout = getEnvInt("VAULT_TIMEOUT_SECONDS", 5)
+}
+
+// loadTrackingConfigs loads the impression and click tracking configurations from the environment variables
+func (c *Config) loadTrackingConfigs() {
+	c.TrackingConfig.Enabled = getEnvBool("TRACKING_ENABLED", true)
+	c.TrackingConfig.LogEvents = getEnvBool("TRACKING_LOG_EVENTS", false)
+	c.TrackingConfig.ClickAllowedHosts = getEnvList("TRACKING_CLICK_ALLOWED_HOSTS", nil)
+}
+
+// loadEventsConfigs loads the event bus and Kafka export configurations from the environment variables
+func (c *Config) loadEventsConfigs() {
+	c.EventsConfig.Enabled = getEnvBool("EVENTS_ENABLED", false)
+	c.EventsConfig.KafkaRESTURL = getEnv("EVENTS_KAFKA_REST_URL", "")
+	c.EventsConfig.KafkaTopic = getEnv("EVENTS_KAFKA_TOPIC", "adbeacon-events")
+	c.EventsConfig.QueueSize = getEnvInt("EVENTS_QUEUE_SIZE", 10000)
+	c.EventsConfig.BatchSize = getEnvInt("EVENTS_BATCH_SIZE", 500)
+	c.EventsConfig.FlushInterval = getEnvInt("EVENTS_FLUSH_INTERVAL_MS", 1000)
+	c.EventsConfig.ExportTimeout = getEnvInt("EVENTS_EXPORT_TIMEOUT_MS", 5000)
+	c.EventsConfig.MaxAttempts = getEnvInt("EVENTS_EXPORT_MAX_ATTEMPTS", 3)
+	c.EventsConfig.RetryDelay = getEnvInt("EVENTS_EXPORT_RETRY_DELAY_MS", 200)
+}
+
+// loadDecisionLogConfigs loads the delivery decision log configurations from the environment variables
+func (c *Config) loadDecisionLogConfigs() {
+	c.DecisionLogConfig.Enabled = getEnvBool("DECISION_LOG_ENABLED", false)
+	c.DecisionLogConfig.Path = getEnv("DECISION_LOG_PATH", "logs/decisions.jsonl")
+	c.DecisionLogConfig.SampleRate = getEnvFloat("DECISION_LOG_SAMPLE_RATE", 0.01)
+	c.DecisionLogConfig.MaxSize = getEnvInt("DECISION_LOG_MAX_SIZE_MB", 100)
+	c.DecisionLogConfig.MaxAge = getEnvInt("DECISION_LOG_MAX_AGE_MINUTES", 60)
+	c.DecisionLogConfig.MaxFiles = getEnvInt("DECISION_LOG_MAX_FILES", 24)
+	c.DecisionLogConfig.S3Bucket = getEnv("DECISION_LOG_S3_BUCKET", "")
+	c.DecisionLogConfig.S3Prefix = getEnv("DECISION_LOG_S3_PREFIX", "decisions")
+	c.DecisionLogConfig.S3Region = getEnv("DECISION_LOG_S3_REGION", "")
+	c.DecisionLogConfig.S3Endpoint = getEnv("DECISION_LOG_S3_ENDPOINT", "")
+}
+
+// loadCampaignStatsConfigs loads the rolling campaign stats configurations from the environment variables
+func (c *Config) loadCampaignStatsConfigs() {
+	c.CampaignStatsConfig.Enabled = getEnvBool("CAMPAIGN_STATS_ENABLED", true)
+	c.CampaignStatsConfig.FlushInterval = getEnvInt("CAMPAIGN_STATS_FLUSH_INTERVAL_MS", 1000)
+}
+
+// loadReachConfigs loads the reach estimation configurations from the environment variables
+func (c *Config) loadReachConfigs() {
+	c.ReachConfig.Enabled = getEnvBool("REACH_ENABLED", true)
+	c.ReachConfig.FlushInterval = getEnvInt("REACH_FLUSH_INTERVAL_MS", 1000)
+	c.ReachConfig.Days = getEnvInt("REACH_DAYS", 7)
+}
+
+// loadSchedulerConfigs loads the campaign scheduler configurations from the environment variables
+func (c *Config) loadSchedulerConfigs() {
+	c.SchedulerConfig.Enabled = getEnvBool("SCHEDULER_ENABLED", true)
+	c.SchedulerConfig.Interval = getEnvInt("SCHEDULER_INTERVAL_SECONDS", 10)
+}
+
+// loadBlocklistConfigs loads the blocklist configurations from the environment variables
+func (c *Config) loadBlocklistConfigs() {
+	c.BlocklistConfig.Enabled = getEnvBool("BLOCKLIST_ENABLED", true)
+	c.BlocklistConfig.RefreshInterval = getEnvInt("BLOCKLIST_REFRESH_INTERVAL_SECONDS", 30)
+}
+
+// loadCampaignLimitsConfigs loads the campaign limits from the environment variables
+func (c *Config) loadCampaignLimitsConfigs() {
+	c.CampaignLimitsConfig.MaxActive = getEnvInt("CAMPAIGN_MAX_ACTIVE", 0)
+	c.CampaignLimitsConfig.MaxRules = getEnvInt("CAMPAIGN_MAX_RULES", 50)
+	c.CampaignLimitsConfig.MaxRuleValues = getEnvInt("CAMPAIGN_MAX_RULE_VALUES", 5000)
+}
+
+// loadDedupConfigs loads the request deduplication configurations from the environment variables
+func (c *Config) loadDedupConfigs() {
+	c.DedupConfig.Enabled = getEnvBool("DEDUP_ENABLED", true)
+	c.DedupConfig.Window = getEnvInt("DEDUP_WINDOW_SECONDS", 60)
+}
+
+// loadReplayProtectionConfigs loads the request replay protection configurations from the
+// environment variables
+func (c *Config) loadReplayProtectionConfigs() {
+	c.ReplayProtectionConfig.Enabled = getEnvBool("REPLAY_PROTECTION_ENABLED", false)
+	c.ReplayProtectionConfig.MaxSkew = getEnvInt("REPLAY_MAX_SKEW_SECONDS", 300)
+}
+
+// loadDeliveryCacheConfigs loads the delivery response caching configurations from the
+// environment variables
+func (c *Config) loadDeliveryCacheConfigs() {
+	c.DeliveryCacheConfig.Enabled = getEnvBool("DELIVERY_CACHE_HEADERS_ENABLED", false)
+	c.DeliveryCacheConfig.MaxAge = getEnvInt("DELIVERY_CACHE_MAX_AGE_SECONDS", 30)
+}
+
+// loadCreativeProxyConfigs loads the creative proxy configurations from the environment variables
+func (c *Config) loadCreativeProxyConfigs() {
+	c.CreativeProxyConfig.Enabled = getEnvBool("CREATIVE_PROXY_ENABLED", false)
+	c.CreativeProxyConfig.BaseURL = getEnv("CREATIVE_PROXY_BASE_URL", "")
+	c.CreativeProxyConfig.CacheSize = getEnvInt("CREATIVE_PROXY_CACHE_SIZE_MB", 64)
+	c.CreativeProxyConfig.MaxSize = getEnvInt("CREATIVE_PROXY_MAX_SIZE_KB", 512)
+	c.CreativeProxyConfig.DefaultTTL = getEnvInt("CREATIVE_PROXY_DEFAULT_TTL_SECONDS", 300)
+	c.CreativeProxyConfig.Timeout = getEnvInt("CREATIVE_PROXY_TIMEOUT_MS", 2000)
+}
+
+// loadCreativeValidationConfigs loads the creative validation configurations from the environment variables
+func (c *Config) loadCreativeValidationConfigs() {
+	c.CreativeValidationConfig.Enabled = getEnvBool("CREATIVE_VALIDATION_ENABLED", false)
+	c.CreativeValidationConfig.MaxSize = getEnvInt("CREATIVE_VALIDATION_MAX_SIZE_KB", 512)
+	c.CreativeValidationConfig.Timeout = getEnvInt("CREATIVE_VALIDATION_TIMEOUT_MS", 3000)
+	c.CreativeValidationConfig.CDNPrefix = getEnv("CREATIVE_CDN_PREFIX", "")
+	c.CreativeValidationConfig.CheckInterval = getEnvInt("CREATIVE_CHECK_INTERVAL_SECONDS", 3600)
+}
+
+// loadReportingConfigs loads the delivery report configurations from the environment variables
+func (c *Config) loadReportingConfigs() {
+	c.ReportingConfig.Enabled = getEnvBool("REPORTING_ENABLED", true)
+	c.ReportingConfig.FlushInterval = getEnvInt("REPORTING_FLUSH_INTERVAL_SECONDS", 60)
+}
+
+// loadQuotaConfigs loads the API key quota configurations from the environment variables
+func (c *Config) loadQuotaConfigs() {
+	c.QuotaConfig.Enabled = getEnvBool("QUOTA_ENABLED", false)
+	c.QuotaConfig.DefaultLimit = getEnvInt("QUOTA_DEFAULT_LIMIT", 0)
+	c.QuotaConfig.Limits = getEnvList("QUOTA_LIMITS", nil)
+	c.QuotaConfig.WarnFraction = getEnvFloat("QUOTA_WARN_FRACTION", 0.8)
+	c.QuotaConfig.FlushInterval = getEnvInt("QUOTA_FLUSH_INTERVAL_MS", 1000)
+}
+
+// loadSigningConfigs loads the response signing configurations from the environment variables,
+// the secrets may be read from RESPONSE_SIGNING_SECRETS_FILE
+func (c *Config) loadSigningConfigs() {
+	c.SigningConfig.Secrets = splitList(getSecretEnv("RESPONSE_SIGNING_SECRETS", ""))
+}
+
+// loadWebhookConfigs loads the campaign webhook configurations from the environment variables
+func (c *Config) loadWebhookConfigs() {
+	c.WebhookConfig.URLs = getEnvList("WEBHOOK_URLS", nil)
+	c.WebhookConfig.Secret = getSecretEnv("WEBHOOK_SECRET", "")
+	c.WebhookConfig.QueueSize = getEnvInt("WEBHOOK_QUEUE_SIZE", 1000)
+	c.WebhookConfig.Timeout = getEnvInt("WEBHOOK_TIMEOUT_MS", 5000)
+	c.WebhookConfig.MaxAttempts = getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5)
+	c.WebhookConfig.RetryDelay = getEnvInt("WEBHOOK_RETRY_DELAY_MS", 1000)
+}
+
+// loadAnomalyConfigs loads the delivery anomaly detection configurations from the environment variables
+func (c *Config) loadAnomalyConfigs() {
+	c.AnomalyConfig.Enabled = getEnvBool("ANOMALY_ENABLED", true)
+	c.AnomalyConfig.Window = getEnvInt("ANOMALY_WINDOW_SECONDS", 300)
+	c.AnomalyConfig.Baseline = getEnvInt("ANOMALY_BASELINE_SECONDS", 3600)
+	c.AnomalyConfig.MinRequests = getEnvInt("ANOMALY_MIN_REQUESTS", 100)
+	c.AnomalyConfig.FillRateDrop = getEnvFloat("ANOMALY_FILL_RATE_DROP", 0.3)
+	c.AnomalyConfig.SuccessRateDrop = getEnvFloat("ANOMALY_SUCCESS_RATE_DROP", 0.05)
+	c.AnomalyConfig.CheckInterval = getEnvInt("ANOMALY_CHECK_INTERVAL_SECONDS", 30)
+	c.AnomalyConfig.WebhookURL = getEnv("ANOMALY_WEBHOOK_URL", "")
+}
+
+// loadFraudConfigs loads the invalid traffic filtering configurations from the environment variables
+func (c *Config) loadFraudConfigs() {
+	c.FraudConfig.Enabled = getEnvBool("FRAUD_ENABLED", false)
+	c.FraudConfig.DryRun = getEnvBool("FRAUD_DRY_RUN", false)
+	c.FraudConfig.Filters = getEnvList("FRAUD_FILTERS", []string{fraud.FilterDatacenter, fraud.FilterBotAgent, fraud.FilterGeoCarrier})
+	c.FraudConfig.DatacenterCIDRs = getEnvList("FRAUD_DATACENTER_CIDRS", nil)
+	c.FraudConfig.DatacenterCIDRsFile = getEnv("FRAUD_DATACENTER_CIDRS_FILE", "")
+	c.FraudConfig.BotAgents = getEnvList("FRAUD_BOT_USER_AGENTS", fraud.DefaultBotAgents)
+	c.FraudConfig.CarrierCountries = getEnvList("FRAUD_CARRIER_COUNTRIES", fraud.DefaultCarrierCountries)
+}
+
+// loadConsentConfigs loads the GDPR consent configurations from the environment variables
+func (c *Config) loadConsentConfigs() {
+	c.ConsentConfig.Enabled = getEnvBool("CONSENT_ENABLED", true)
+	c.ConsentConfig.VendorID = getEnvInt("CONSENT_VENDOR_ID", 0)
+}
+
+// loadMatchMemoConfigs loads the match memoization configurations from the environment variables
+func (c *Config) loadMatchMemoConfigs() {
+	c.MatchMemoConfig.Enabled = getEnvBool("MATCH_MEMO_ENABLED", true)
+	c.MatchMemoConfig.TTL = getEnvInt("MATCH_MEMO_TTL_MS", 1000)
+	c.MatchMemoConfig.MaxEntries = getEnvInt("MATCH_MEMO_MAX_ENTRIES", 10000)
+}
+
+// loadParallelMatchConfigs loads the parallel matching configurations from the environment variables
+func (c *Config) loadParallelMatchConfigs() {
+	c.ParallelMatchConfig.Threshold = getEnvInt("MATCH_PARALLEL_THRESHOLD", 1000)
+	c.ParallelMatchConfig.Workers = getEnvInt("MATCH_PARALLEL_WORKERS", 0)
+}
+
+// loadRuntimeConfigs loads the Go runtime limit configurations from the environment variables
+func (c *Config) loadRuntimeConfigs() {
+	c.RuntimeConfig.AutoMaxProcs = getEnvBool("RUNTIME_AUTO_MAXPROCS", true)
+	c.RuntimeConfig.AutoMemLimit = getEnvBool("RUNTIME_AUTO_MEMLIMIT", true)
+	c.RuntimeConfig.MemLimitRatio = getEnvFloat("RUNTIME_MEMLIMIT_RATIO", 0.9)
+}
+
+// loadReloadConfigs loads the configuration reload configurations from the environment variables
+func (c *Config) loadReloadConfigs(path string) {
+	c.ReloadConfig.File = path
+	c.ReloadConfig.PollInterval = getEnvInt("CONFIG_RELOAD_INTERVAL_SECONDS", 10)
+}
+
+// loadReadinessConfigs loads the readiness warm-up configurations from the environment variables
+func (c *Config) loadReadinessConfigs() {
+	c.ReadinessConfig.SelfTestDeliveries = getEnvInt("READINESS_SELF_TEST_DELIVERIES", 3)
+	c.ReadinessConfig.SelfTestCountry = getEnv("READINESS_SELF_TEST_COUNTRY", "us")
+	c.ReadinessConfig.SelfTestOS = getEnv("READINESS_SELF_TEST_OS", "android")
+	c.ReadinessConfig.SelfTestApp = getEnv("READINESS_SELF_TEST_APP", "adbeacon.selftest")
+	c.ReadinessConfig.RetryInterval = getEnvInt("READINESS_RETRY_INTERVAL_SECONDS", 2)
 }
 
 // getEnv returns the environment variable value if it exists, otherwise returns the fallback value
@@ -83,3 +894,83 @@ func getEnvInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvBool returns the environment variable value as bool if it exists, otherwise returns the fallback value
+func getEnvBool(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return fallback
+}
+
+// getEnvFloat returns the environment variable value as float64 if it exists, otherwise returns the fallback value
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return fallback
+}
+
+// getEnvList returns the comma separated environment variable value as a list if it exists, otherwise returns the fallback value
+func getEnvList(key string, fallback []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	return splitList(value)
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvFloatList returns a comma separated environment variable as a list of floats if it exists,
+// otherwise returns the fallback value. The fallback is also used if any item is not a number.
+func getEnvFloatList(key string, fallback []float64) []float64 {
+	items := getEnvList(key, nil)
+	if len(items) == 0 {
+		return fallback
+	}
+
+	list := make([]float64, 0, len(items))
+	for _, item := range items {
+		floatVal, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			log.Printf("Warning: invalid number %q in %s, using defaults", item, key)
+			return fallback
+		}
+		list = append(list, floatVal)
+	}
+	return list
+}
+
+// getEnvIntList returns a comma separated environment variable as a list of integers if it exists,
+// otherwise returns the fallback value. The fallback is also used if any item is not an integer.
+func getEnvIntList(key string, fallback []int) []int {
+	items := getEnvList(key, nil)
+	if len(items) == 0 {
+		return fallback
+	}
+
+	list := make([]int, 0, len(items))
+	for _, item := range items {
+		intVal, err := strconv.Atoi(item)
+		if err != nil {
+			log.Printf("Warning: invalid integer %q in %s, using defaults", item, key)
+			return fallback
+		}
+		list = append(list, intVal)
+	}
+	return list
+}
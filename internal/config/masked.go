@@ -0,0 +1,60 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// maskedValue replaces secrets that are set, unset secrets stay empty
+const maskedValue = "********"
+
+// Masked returns a copy of the configuration with secrets masked, safe to show to operators
+func (c *Config) Masked() Config {
+	masked := *c
+	masked.DatabaseConfig.Password = mask(c.DatabaseConfig.Password)
+	masked.CacheConfig.RedisPassword = mask(c.CacheConfig.RedisPassword)
+	masked.CacheConfig.GlobalRedisPassword = mask(c.CacheConfig.GlobalRedisPassword)
+	masked.SecretsConfig.VaultToken = mask(c.SecretsConfig.VaultToken)
+	// The DSN embeds the Sentry key
+	masked.ErrorReportingConfig.SentryDSN = mask(c.ErrorReportingConfig.SentryDSN)
+	masked.LogRedactionConfig.Salt = mask(c.LogRedactionConfig.Salt)
+	masked.WebhookConfig.Secret = mask(c.WebhookConfig.Secret)
+	masked.AdminConfig.Token = mask(c.AdminConfig.Token)
+	// Webhook URLs often carry their token in the path or query (Slack, PagerDuty)
+	masked.WebhookConfig.URLs = nil
+	for _, webhookURL := range c.WebhookConfig.URLs {
+		masked.WebhookConfig.URLs = append(masked.WebhookConfig.URLs, maskURL(webhookURL))
+	}
+	masked.AnomalyConfig.WebhookURL = maskURL(c.AnomalyConfig.WebhookURL)
+	// The key IDs aren't secret, they name the API keys that get signed responses
+	masked.SigningConfig.Secrets = nil
+	for _, entry := range c.SigningConfig.Secrets {
+		if keyID, secret, ok := strings.Cut(entry, ":"); ok {
+			masked.SigningConfig.Secrets = append(masked.SigningConfig.Secrets, keyID+":"+mask(secret))
+		} else {
+			masked.SigningConfig.Secrets = append(masked.SigningConfig.Secrets, mask(entry))
+		}
+	}
+	return masked
+}
+
+// maskURL keeps the scheme and host of rawURL so operators can tell where it points, and masks
+// everything else (user info included). URLs that don't parse are masked entirely.
+func maskURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return mask(rawURL)
+	}
+	masked := u.Scheme + "://" + u.Host
+	if rawURL != masked && rawURL != masked+"/" {
+		masked += "/" + maskedValue
+	}
+	return masked
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return maskedValue
+}
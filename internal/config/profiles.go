@@ -0,0 +1,47 @@
+package config
+
+import "os"
+
+// Profiles selected by APP_ENV, other environments use the plain defaults
+const (
+	ProfileDev     = "dev"
+	ProfileStaging = "staging"
+	ProfileProd    = "prod"
+)
+
+// Campaign repositories
+const (
+	RepositoryPostgres = "postgres"
+	RepositoryMock     = "mock"
+)
+
+// profileDefault overrides the default of the setting loaded from key
+type profileDefault struct {
+	key   string
+	apply func(c *Config)
+}
+
+// profiles change defaults per environment, variables set explicitly always win.
+// Staging runs with the plain defaults, prod additionally has the requirements checked by Validate.
+var profiles = map[string][]profileDefault{
+	// Runs without infrastructure
+	ProfileDev: {
+		{"REPOSITORY", func(c *Config) { c.GeneralConfig.Repository = RepositoryMock }},
+		{"CACHE_ENABLE_REDIS", func(c *Config) { c.CacheConfig.EnableRedis = false }},
+	},
+	ProfileProd: {
+		{"LOG_FORMAT", func(c *Config) { c.GeneralConfig.LogFormat = "json" }},
+		{"DB_SSLMODE", func(c *Config) { c.DatabaseConfig.SSLMode = "require" }},
+		{"REDIS_TLS", func(c *Config) { c.CacheConfig.RedisTLS = true }},
+	},
+}
+
+// applyProfile applies the defaults of the profile selected by APP_ENV to the settings
+// whose environment variable isn't set
+func (c *Config) applyProfile() {
+	for _, d := range profiles[c.GeneralConfig.Env] {
+		if _, set := os.LookupEnv(d.key); !set {
+			d.apply(c)
+		}
+	}
+}
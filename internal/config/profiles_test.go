@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyProfile(t *testing.T) {
+	t.Run("dev runs without infrastructure", func(t *testing.T) {
+		c := &Config{}
+		c.GeneralConfig = GeneralConfig{Env: ProfileDev, Repository: RepositoryPostgres}
+		c.CacheConfig.EnableRedis = true
+
+		c.applyProfile()
+		assert.Equal(t, RepositoryMock, c.GeneralConfig.Repository)
+		assert.False(t, c.CacheConfig.EnableRedis)
+	})
+
+	t.Run("explicit variables win", func(t *testing.T) {
+		t.Setenv("REPOSITORY", RepositoryPostgres)
+		c := &Config{}
+		c.GeneralConfig = GeneralConfig{Env: ProfileDev, Repository: RepositoryPostgres}
+
+		c.applyProfile()
+		assert.Equal(t, RepositoryPostgres, c.GeneralConfig.Repository)
+	})
+
+	t.Run("unknown environments keep the defaults", func(t *testing.T) {
+		c := &Config{}
+		c.GeneralConfig = GeneralConfig{Env: "docker", Repository: RepositoryPostgres}
+
+		c.applyProfile()
+		assert.Equal(t, RepositoryPostgres, c.GeneralConfig.Repository)
+	})
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/secrets"
+)
+
+// Secret providers
+const (
+	SecretsProviderEnv   = "env"
+	SecretsProviderVault = "vault"
+)
+
+// LoadSecrets resolves the passwords through the configured secret provider, so they don't
+// have to be passed as plaintext environment variables. With the env provider it does nothing.
+func (c *Config) LoadSecrets(ctx context.Context) error {
+	secretsConfig := c.SecretsConfig
+
+	var provider secrets.Provider
+	switch secretsConfig.Provider {
+	case SecretsProviderEnv:
+		return nil
+	case SecretsProviderVault:
+		var err error
+		provider, err = secrets.NewVaultProvider(secrets.VaultConfig{
+			Addr:    secretsConfig.VaultAddr,
+			Token:   secretsConfig.VaultToken,
+			Mount:   secretsConfig.VaultMount,
+			Path:    secretsConfig.VaultPath,
+			Timeout: time.Duration(secretsConfig.VaultTimeout) * time.Second,
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown secrets provider %q", secretsConfig.Provider)
+	}
+
+	return c.loadSecretsFrom(ctx, provider)
+}
+
+// loadSecretsFrom resolves the passwords through provider, secrets the provider doesn't
+// have keep their environment value
+func (c *Config) loadSecretsFrom(ctx context.Context, provider secrets.Provider) error {
+	passwords := map[string]*string{
+		"db_password":           &c.DatabaseConfig.Password,
+		"redis_password":        &c.CacheConfig.RedisPassword,
+		"redis_global_password": &c.CacheConfig.GlobalRedisPassword,
+		"webhook_secret":        &c.WebhookConfig.Secret,
+		"admin_token":           &c.AdminConfig.Token,
+	}
+	for name, password := range passwords {
+		value, err := provider.GetSecret(ctx, name)
+		if errors.Is(err, secrets.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", name, err)
+		}
+		*password = value
+	}
+	return nil
+}
+
+// getSecretEnv returns the contents of the file named by key_FILE (Docker and Kubernetes
+// secrets) if set, otherwise the environment variable
+func getSecretEnv(key, fallback string) string {
+	if path, exists := os.LookupEnv(key + "_FILE"); exists {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			return strings.TrimRight(string(content), "\r\n")
+		}
+		log.Printf("Warning: failed to read %s_FILE: %v", key, err)
+	}
+
+	return getEnv(key, fallback)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticProvider map[string]string
+
+func (p staticProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	value, ok := p[name]
+	if !ok {
+		return "", secrets.ErrNotFound
+	}
+	return value, nil
+}
+
+func TestGetSecretEnv(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "from-env")
+	assert.Equal(t, "from-env", getSecretEnv("DB_PASSWORD", ""))
+
+	// A *_FILE path takes precedence, the trailing newline is dropped
+	path := filepath.Join(t.TempDir(), "db_password")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0o600))
+	t.Setenv("DB_PASSWORD_FILE", path)
+	assert.Equal(t, "from-file", getSecretEnv("DB_PASSWORD", ""))
+}
+
+func TestLoadSecretsFrom(t *testing.T) {
+	c := &Config{}
+	c.CacheConfig.RedisPassword = "from-env"
+
+	require.NoError(t, c.loadSecretsFrom(context.Background(), staticProvider{"db_password": "from-vault", "admin_token": "token-from-vault"}))
+	assert.Equal(t, "from-vault", c.DatabaseConfig.Password)
+	assert.Equal(t, "token-from-vault", c.AdminConfig.Token)
+	// Secrets missing from the provider keep their environment value
+	assert.Equal(t, "from-env", c.CacheConfig.RedisPassword)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Tunables are the settings that can be changed at runtime by reloading the config file
+type Tunables struct {
+	LogLevel            string
+	AccessLogSampleRate float64
+	CacheTTL            time.Duration
+	RateLimit           float64 // delivery requests per second, 0 disables the limit
+	RateLimitBurst      int
+}
+
+// Tunables returns the tunables of the loaded configuration
+func (c *Config) Tunables() Tunables {
+	return Tunables{
+		LogLevel:            c.GeneralConfig.LogLevel,
+		AccessLogSampleRate: c.AccessLogConfig.SampleRate,
+		CacheTTL:            c.CacheConfig.DefaultTTL,
+		RateLimit:           c.EndpointConfig.RateLimit,
+		RateLimitBurst:      c.EndpointConfig.RateLimitBurst,
+	}
+}
+
+// LoadTunables re-reads the env file at path, overriding the environment, and returns the tunables.
+// Variables removed from the file keep their previous value.
+func LoadTunables(path string) (Tunables, error) {
+	if err := godotenv.Overload(path); err != nil {
+		return Tunables{}, err
+	}
+
+	return Tunables{
+		LogLevel:            getEnv("LOG_LEVEL", "info"),
+		AccessLogSampleRate: getEnvFloat("ACCESS_LOG_SAMPLE_RATE", 1.0),
+		CacheTTL:            getDurationEnv("CACHE_DEFAULT_TTL", 5*time.Minute),
+		RateLimit:           getEnvFloat("DELIVERY_RATE_LIMIT_RPS", 0),
+		RateLimitBurst:      getEnvInt("DELIVERY_RATE_LIMIT_BURST", 100),
+	}, nil
+}
@@ -0,0 +1,338 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/fraud"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/logger"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/signing"
+)
+
+// ValidationError lists every invalid setting found in the loaded configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration (%d problems):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate checks the loaded configuration as a whole, so startup can fail with a single
+// report instead of deep inside subsystem initialization. It returns a *ValidationError
+// listing all problems, or nil.
+func (c *Config) Validate() error {
+	v := &validator{}
+	cacheConfig := c.CacheConfig
+
+	// General
+	v.port("PORT", c.GeneralConfig.Port)
+	if c.GeneralConfig.DebugEnabled {
+		v.port("DEBUG_PORT", c.GeneralConfig.DebugPort)
+		v.check(c.GeneralConfig.DebugPort != c.GeneralConfig.Port, "DEBUG_PORT must differ from PORT, got %d for both", c.GeneralConfig.Port)
+	}
+	if _, err := logger.ParseLevel(c.GeneralConfig.LogLevel); err != nil {
+		v.add("LOG_LEVEL: %v", err)
+	}
+	v.check(c.GeneralConfig.LogFormat == logger.FormatLogfmt || c.GeneralConfig.LogFormat == logger.FormatJSON,
+		"LOG_FORMAT must be %s or %s, got %q", logger.FormatLogfmt, logger.FormatJSON, c.GeneralConfig.LogFormat)
+	v.check(len(c.LogRedactionConfig.HashFields) == 0 || c.LogRedactionConfig.Salt != "",
+		"LOG_REDACT_SALT is required when LOG_REDACT_HASH_FIELDS is set")
+	v.fraction("ACCESS_LOG_SAMPLE_RATE", c.AccessLogConfig.SampleRate)
+	if _, err := fraud.ParsePrefixes(c.GeneralConfig.TrustedProxies); err != nil {
+		v.add("TRUSTED_PROXIES: %v", err)
+	}
+
+	// Database, unused by the mock repository
+	db := c.DatabaseConfig
+	switch c.GeneralConfig.Repository {
+	case RepositoryMock:
+	case RepositoryPostgres:
+		v.required("DB_HOST", db.Host)
+		v.required("DB_USER", db.User)
+		v.required("DB_NAME", db.DBName)
+		// With vault the password is resolved after validation
+		v.check(c.GeneralConfig.Env == ProfileDev || db.Password != "" || c.SecretsConfig.Provider == SecretsProviderVault,
+			"DB_PASSWORD (or DB_PASSWORD_FILE) is required outside the dev environment")
+		v.port("DB_PORT", db.Port)
+		v.port("DB_DIRECT_PORT", db.DirectPort)
+		v.check(db.MaxOpenConns > 0, "DB_MAX_OPEN_CONNS must be positive, got %d", db.MaxOpenConns)
+		v.check(db.MaxIdleConns <= db.MaxOpenConns, "DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", db.MaxIdleConns, db.MaxOpenConns)
+		v.nonNegative("DB_HEDGE_DELAY_MS", db.HedgeDelay)
+	default:
+		v.add("REPOSITORY must be %s or %s, got %q", RepositoryPostgres, RepositoryMock, c.GeneralConfig.Repository)
+	}
+
+	// Cache
+	v.check(cacheConfig.DefaultTTL > 0, "CACHE_DEFAULT_TTL must be positive, got %s", cacheConfig.DefaultTTL)
+	v.check(cacheConfig.RefreshInterval > 0, "CACHE_REFRESH_INTERVAL must be positive, got %s", cacheConfig.RefreshInterval)
+	v.check(cacheConfig.RefreshInterval < cacheConfig.DefaultTTL,
+		"CACHE_REFRESH_INTERVAL (%s) must be shorter than CACHE_DEFAULT_TTL (%s), or the cache expires before it is refreshed",
+		cacheConfig.RefreshInterval, cacheConfig.DefaultTTL)
+	v.nonNegative("CACHE_LOAD_BATCH_SIZE", cacheConfig.LoadBatchSize)
+	v.check(!cacheConfig.EnableMemory || cacheConfig.MemoryCacheSize > 0, "CACHE_MEMORY_SIZE must be positive when the memory cache is enabled")
+	v.check(cacheConfig.EnableRedis || cacheConfig.RedisHedgeDelay == 0, "REDIS_HEDGE_DELAY requires CACHE_ENABLE_REDIS")
+	v.check(cacheConfig.EnableRedis || cacheConfig.RedisPassword == "", "REDIS_PASSWORD is set but CACHE_ENABLE_REDIS is false")
+	v.check(cacheConfig.RedisHedgeDelay >= 0, "REDIS_HEDGE_DELAY must not be negative")
+	if cacheConfig.GlobalRedisAddr != "" {
+		v.check(cacheConfig.EnableRedis, "REDIS_GLOBAL_ADDR requires CACHE_ENABLE_REDIS")
+		v.check(cacheConfig.Region != "", "CACHE_REGION is required with REDIS_GLOBAL_ADDR")
+		v.check(cacheConfig.GlobalRedisAddr != cacheConfig.RedisAddr, "REDIS_GLOBAL_ADDR must differ from REDIS_ADDR, got %q for both", cacheConfig.RedisAddr)
+	}
+	v.check(cacheConfig.GlobalRedisAddr != "" || cacheConfig.GlobalRedisPassword == "", "REDIS_GLOBAL_PASSWORD is set but REDIS_GLOBAL_ADDR is not")
+	if cacheConfig.PersistPath != "" {
+		v.check(cacheConfig.EnableRedis, "CACHE_PERSIST_PATH requires CACHE_ENABLE_REDIS, persisted campaigns are validated against its generation")
+		v.check(cacheConfig.PersistInterval > 0, "CACHE_PERSIST_INTERVAL must be positive, got %s", cacheConfig.PersistInterval)
+		v.check(cacheConfig.PersistMaxAge > 0, "CACHE_PERSIST_MAX_AGE must be positive, got %s", cacheConfig.PersistMaxAge)
+	}
+	if len(cacheConfig.RedisShardAddrs) > 0 {
+		v.check(cacheConfig.EnableRedis, "REDIS_SHARD_ADDRS requires CACHE_ENABLE_REDIS")
+		seen := make(map[string]bool, len(cacheConfig.RedisShardAddrs))
+		for _, addr := range cacheConfig.RedisShardAddrs {
+			v.check(!seen[addr], "REDIS_SHARD_ADDRS lists %q twice", addr)
+			seen[addr] = true
+		}
+	}
+
+	// Production requirements
+	if c.GeneralConfig.Env == ProfileProd {
+		v.check(c.GeneralConfig.Repository == RepositoryPostgres, "REPOSITORY must be %s in prod", RepositoryPostgres)
+		v.check(cacheConfig.EnableRedis, "CACHE_ENABLE_REDIS is required in prod")
+		v.check(cacheConfig.RedisTLS, "REDIS_TLS is required in prod")
+		v.check(db.SSLMode == "require" || db.SSLMode == "verify-ca" || db.SSLMode == "verify-full",
+			"DB_SSLMODE must be require, verify-ca or verify-full in prod, got %q", db.SSLMode)
+	}
+
+	// Objectives and thresholds
+	v.check(c.SLOConfig.AvailabilityObjective > 0 && c.SLOConfig.AvailabilityObjective < 1,
+		"SLO_AVAILABILITY_OBJECTIVE must be between 0 and 1 exclusive, got %v", c.SLOConfig.AvailabilityObjective)
+	v.check(c.SLOConfig.LatencyObjective > 0 && c.SLOConfig.LatencyObjective < 1,
+		"SLO_LATENCY_OBJECTIVE must be between 0 and 1 exclusive, got %v", c.SLOConfig.LatencyObjective)
+	v.check(c.SLOConfig.LatencyTarget > 0, "SLO_LATENCY_TARGET_MS must be positive, got %d", c.SLOConfig.LatencyTarget)
+	v.nonNegative("METRICS_APP_LABEL_LIMIT", c.MetricsConfig.AppLabelLimit)
+	v.nonNegative("METRICS_COUNTRY_LABEL_LIMIT", c.MetricsConfig.CountryLabelLimit)
+	v.nonNegative("METRICS_OS_LABEL_LIMIT", c.MetricsConfig.OSLabelLimit)
+	if factor := c.MetricsConfig.NativeHistogramBucketFactor; factor != 0 {
+		v.check(factor > 1, "METRICS_NATIVE_HISTOGRAM_BUCKET_FACTOR must be 0 or greater than 1, got %g", factor)
+	}
+	if c.CircuitBreakerConfig.Enabled {
+		v.check(c.CircuitBreakerConfig.FailureThreshold > 0, "CIRCUIT_BREAKER_FAILURE_THRESHOLD must be positive, got %d", c.CircuitBreakerConfig.FailureThreshold)
+		v.check(c.CircuitBreakerConfig.OpenTimeout > 0, "CIRCUIT_BREAKER_OPEN_TIMEOUT_SECONDS must be positive, got %d", c.CircuitBreakerConfig.OpenTimeout)
+	}
+	if c.LoadShedConfig.Enabled {
+		v.check(c.LoadShedConfig.Interval > 0, "LOAD_SHED_INTERVAL_MS must be positive, got %d", c.LoadShedConfig.Interval)
+		v.fraction("LOAD_SHED_CPU_THRESHOLD", c.LoadShedConfig.CPUThreshold)
+		v.fraction("LOAD_SHED_MAX_SHED_FRACTION", c.LoadShedConfig.MaxShedFraction)
+	}
+
+	// Endpoint and request handling
+	v.nonNegative("DELIVERY_TIMEOUT_MS", c.EndpointConfig.DeliveryTimeout)
+	v.nonNegative("DELIVERY_MAX_IN_FLIGHT", c.EndpointConfig.DeliveryMaxInFlight)
+	v.check(c.EndpointConfig.RateLimit >= 0, "DELIVERY_RATE_LIMIT_RPS must not be negative, got %v", c.EndpointConfig.RateLimit)
+	v.check(c.RetryConfig.MaxAttempts >= 1, "REPOSITORY_RETRY_MAX_ATTEMPTS must be at least 1, got %d", c.RetryConfig.MaxAttempts)
+	v.check(c.RetryConfig.BaseDelay <= c.RetryConfig.MaxDelay, "REPOSITORY_RETRY_BASE_DELAY_MS (%d) must not exceed REPOSITORY_RETRY_MAX_DELAY_MS (%d)", c.RetryConfig.BaseDelay, c.RetryConfig.MaxDelay)
+	v.check(c.IdempotencyConfig.TTL > 0, "IDEMPOTENCY_TTL_HOURS must be positive, got %d", c.IdempotencyConfig.TTL)
+	v.check(c.IdempotencyConfig.MaxEntries > 0, "IDEMPOTENCY_MAX_ENTRIES must be positive, got %d", c.IdempotencyConfig.MaxEntries)
+	v.check(c.ReadinessConfig.RetryInterval > 0, "READINESS_RETRY_INTERVAL_SECONDS must be positive, got %d", c.ReadinessConfig.RetryInterval)
+	v.nonNegative("CONFIG_RELOAD_INTERVAL_SECONDS", c.ReloadConfig.PollInterval)
+
+	// Event export
+	if events := c.EventsConfig; events.Enabled {
+		v.required("EVENTS_KAFKA_REST_URL", events.KafkaRESTURL)
+		v.required("EVENTS_KAFKA_TOPIC", events.KafkaTopic)
+		v.check(events.QueueSize > 0, "EVENTS_QUEUE_SIZE must be positive, got %d", events.QueueSize)
+		v.check(events.BatchSize > 0 && events.BatchSize <= events.QueueSize,
+			"EVENTS_BATCH_SIZE must be positive and not exceed EVENTS_QUEUE_SIZE (%d), got %d", events.QueueSize, events.BatchSize)
+		v.check(events.FlushInterval > 0, "EVENTS_FLUSH_INTERVAL_MS must be positive, got %d", events.FlushInterval)
+		v.check(events.ExportTimeout > 0, "EVENTS_EXPORT_TIMEOUT_MS must be positive, got %d", events.ExportTimeout)
+		v.check(events.MaxAttempts >= 1, "EVENTS_EXPORT_MAX_ATTEMPTS must be at least 1, got %d", events.MaxAttempts)
+		v.nonNegative("EVENTS_EXPORT_RETRY_DELAY_MS", events.RetryDelay)
+	}
+
+	if c.CampaignStatsConfig.Enabled {
+		v.check(c.CampaignStatsConfig.FlushInterval > 0, "CAMPAIGN_STATS_FLUSH_INTERVAL_MS must be positive, got %d", c.CampaignStatsConfig.FlushInterval)
+	}
+	if c.ReachConfig.Enabled {
+		v.check(c.ReachConfig.FlushInterval > 0, "REACH_FLUSH_INTERVAL_MS must be positive, got %d", c.ReachConfig.FlushInterval)
+		v.check(c.ReachConfig.Days > 0, "REACH_DAYS must be positive, got %d", c.ReachConfig.Days)
+	}
+	if c.SchedulerConfig.Enabled {
+		v.check(c.SchedulerConfig.Interval > 0, "SCHEDULER_INTERVAL_SECONDS must be positive, got %d", c.SchedulerConfig.Interval)
+	}
+	if c.BlocklistConfig.Enabled {
+		v.check(c.BlocklistConfig.RefreshInterval > 0, "BLOCKLIST_REFRESH_INTERVAL_SECONDS must be positive, got %d", c.BlocklistConfig.RefreshInterval)
+	}
+	v.nonNegative("CAMPAIGN_MAX_ACTIVE", c.CampaignLimitsConfig.MaxActive)
+	v.nonNegative("CAMPAIGN_MAX_RULES", c.CampaignLimitsConfig.MaxRules)
+	v.nonNegative("CAMPAIGN_MAX_RULE_VALUES", c.CampaignLimitsConfig.MaxRuleValues)
+	if c.DedupConfig.Enabled {
+		v.check(c.DedupConfig.Window > 0, "DEDUP_WINDOW_SECONDS must be positive, got %d", c.DedupConfig.Window)
+	}
+	if c.ReplayProtectionConfig.Enabled {
+		v.check(c.ReplayProtectionConfig.MaxSkew > 0, "REPLAY_MAX_SKEW_SECONDS must be positive, got %d", c.ReplayProtectionConfig.MaxSkew)
+		v.check(len(c.SigningConfig.Secrets) > 0, "REPLAY_PROTECTION_ENABLED requires RESPONSE_SIGNING_SECRETS, requests are verified with the signing secret of their API key")
+	}
+	if c.DeliveryCacheConfig.Enabled {
+		v.check(c.DeliveryCacheConfig.MaxAge > 0, "DELIVERY_CACHE_MAX_AGE_SECONDS must be positive, got %d", c.DeliveryCacheConfig.MaxAge)
+	}
+	if creative := c.CreativeProxyConfig; creative.Enabled {
+		if creative.BaseURL != "" {
+			u, err := url.Parse(creative.BaseURL)
+			v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "CREATIVE_PROXY_BASE_URL must be an http(s) URL, got %q", creative.BaseURL)
+		}
+		v.check(creative.CacheSize > 0, "CREATIVE_PROXY_CACHE_SIZE_MB must be positive, got %d", creative.CacheSize)
+		v.check(creative.MaxSize > 0, "CREATIVE_PROXY_MAX_SIZE_KB must be positive, got %d", creative.MaxSize)
+		v.nonNegative("CREATIVE_PROXY_DEFAULT_TTL_SECONDS", creative.DefaultTTL)
+		v.check(creative.Timeout > 0, "CREATIVE_PROXY_TIMEOUT_MS must be positive, got %d", creative.Timeout)
+	}
+	if validation := c.CreativeValidationConfig; validation.Enabled {
+		v.check(validation.MaxSize > 0, "CREATIVE_VALIDATION_MAX_SIZE_KB must be positive, got %d", validation.MaxSize)
+		v.check(validation.Timeout > 0, "CREATIVE_VALIDATION_TIMEOUT_MS must be positive, got %d", validation.Timeout)
+		if validation.CDNPrefix != "" {
+			u, err := url.Parse(validation.CDNPrefix)
+			v.check(err == nil && u.Scheme == "https" && u.Host != "", "CREATIVE_CDN_PREFIX must be an https URL, got %q", validation.CDNPrefix)
+		}
+		v.nonNegative("CREATIVE_CHECK_INTERVAL_SECONDS", validation.CheckInterval)
+	}
+	if c.ReportingConfig.Enabled {
+		v.check(c.ReportingConfig.FlushInterval > 0, "REPORTING_FLUSH_INTERVAL_SECONDS must be positive, got %d", c.ReportingConfig.FlushInterval)
+	}
+
+	// Quotas
+	if quotaConfig := c.QuotaConfig; quotaConfig.Enabled {
+		v.nonNegative("QUOTA_DEFAULT_LIMIT", quotaConfig.DefaultLimit)
+		v.fraction("QUOTA_WARN_FRACTION", quotaConfig.WarnFraction)
+		v.check(quotaConfig.FlushInterval > 0, "QUOTA_FLUSH_INTERVAL_MS must be positive, got %d", quotaConfig.FlushInterval)
+		if _, err := quota.ParseLimits(quotaConfig.Limits); err != nil {
+			v.add("QUOTA_LIMITS: %v", err)
+		}
+	}
+
+	// Response signing
+	if _, err := signing.ParseSecrets(c.SigningConfig.Secrets); err != nil {
+		v.add("RESPONSE_SIGNING_SECRETS: %v", err)
+	}
+
+	// Invalid traffic filtering
+	if fraudConfig := c.FraudConfig; fraudConfig.Enabled {
+		for _, filter := range fraudConfig.Filters {
+			v.check(slices.Contains([]string{fraud.FilterDatacenter, fraud.FilterBotAgent, fraud.FilterGeoCarrier}, filter),
+				"FRAUD_FILTERS must list %s, %s or %s, got %q", fraud.FilterDatacenter, fraud.FilterBotAgent, fraud.FilterGeoCarrier, filter)
+		}
+		if _, err := fraud.ParsePrefixes(fraudConfig.DatacenterCIDRs); err != nil {
+			v.add("FRAUD_DATACENTER_CIDRS: %v", err)
+		}
+		if _, err := fraud.ParseCarrierCountries(fraudConfig.CarrierCountries); err != nil {
+			v.add("FRAUD_CARRIER_COUNTRIES: %v", err)
+		}
+	}
+
+	// Match memoization
+	if memo := c.MatchMemoConfig; memo.Enabled {
+		v.check(memo.TTL > 0, "MATCH_MEMO_TTL_MS must be positive, got %d", memo.TTL)
+		v.check(memo.MaxEntries > 0, "MATCH_MEMO_MAX_ENTRIES must be positive, got %d", memo.MaxEntries)
+	}
+
+	// OS aliases
+	if _, err := models.ParseOSAliases(c.ValidationConfig.OSAliases); err != nil {
+		v.add("OS_ALIASES: %v", err)
+	}
+
+	// Parallel matching
+	v.check(c.ParallelMatchConfig.Threshold >= 0, "MATCH_PARALLEL_THRESHOLD must not be negative, got %d", c.ParallelMatchConfig.Threshold)
+	v.check(c.ParallelMatchConfig.Workers >= 0, "MATCH_PARALLEL_WORKERS must not be negative, got %d", c.ParallelMatchConfig.Workers)
+
+	// Runtime limits
+	if c.RuntimeConfig.AutoMemLimit {
+		v.check(c.RuntimeConfig.MemLimitRatio > 0 && c.RuntimeConfig.MemLimitRatio <= 1,
+			"RUNTIME_MEMLIMIT_RATIO must be greater than 0 and at most 1, got %v", c.RuntimeConfig.MemLimitRatio)
+	}
+
+	// Consent
+	if c.ConsentConfig.Enabled {
+		v.check(c.ConsentConfig.VendorID >= 0 && c.ConsentConfig.VendorID <= 65535,
+			"CONSENT_VENDOR_ID must be between 0 and 65535, got %d", c.ConsentConfig.VendorID)
+	}
+
+	// Anomaly detection
+	if anomaly := c.AnomalyConfig; anomaly.Enabled {
+		v.check(anomaly.Window >= 10, "ANOMALY_WINDOW_SECONDS must be at least 10, got %d", anomaly.Window)
+		v.check(anomaly.Baseline >= anomaly.Window, "ANOMALY_BASELINE_SECONDS must be at least ANOMALY_WINDOW_SECONDS (%d), got %d", anomaly.Window, anomaly.Baseline)
+		v.nonNegative("ANOMALY_MIN_REQUESTS", anomaly.MinRequests)
+		v.fraction("ANOMALY_FILL_RATE_DROP", anomaly.FillRateDrop)
+		v.fraction("ANOMALY_SUCCESS_RATE_DROP", anomaly.SuccessRateDrop)
+		v.check(anomaly.CheckInterval > 0, "ANOMALY_CHECK_INTERVAL_SECONDS must be positive, got %d", anomaly.CheckInterval)
+	}
+
+	// Webhooks
+	if webhooks := c.WebhookConfig; len(webhooks.URLs) > 0 {
+		for _, rawURL := range webhooks.URLs {
+			u, err := url.Parse(rawURL)
+			v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "WEBHOOK_URLS must be http(s) URLs, got %q", rawURL)
+		}
+		v.check(webhooks.QueueSize > 0, "WEBHOOK_QUEUE_SIZE must be positive, got %d", webhooks.QueueSize)
+		v.check(webhooks.Timeout > 0, "WEBHOOK_TIMEOUT_MS must be positive, got %d", webhooks.Timeout)
+		v.check(webhooks.MaxAttempts >= 1, "WEBHOOK_MAX_ATTEMPTS must be at least 1, got %d", webhooks.MaxAttempts)
+		v.nonNegative("WEBHOOK_RETRY_DELAY_MS", webhooks.RetryDelay)
+	}
+
+	// Decision log
+	if decisionLog := c.DecisionLogConfig; decisionLog.Enabled {
+		v.required("DECISION_LOG_PATH", decisionLog.Path)
+		v.fraction("DECISION_LOG_SAMPLE_RATE", decisionLog.SampleRate)
+		v.nonNegative("DECISION_LOG_MAX_SIZE_MB", decisionLog.MaxSize)
+		v.nonNegative("DECISION_LOG_MAX_AGE_MINUTES", decisionLog.MaxAge)
+		v.nonNegative("DECISION_LOG_MAX_FILES", decisionLog.MaxFiles)
+	}
+
+	// Secrets
+	switch c.SecretsConfig.Provider {
+	case SecretsProviderEnv:
+	case SecretsProviderVault:
+		v.required("VAULT_ADDR", c.SecretsConfig.VaultAddr)
+		v.required("VAULT_TOKEN", c.SecretsConfig.VaultToken)
+	default:
+		v.add("SECRETS_PROVIDER must be %s or %s, got %q", SecretsProviderEnv, SecretsProviderVault, c.SecretsConfig.Provider)
+	}
+
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// validator collects configuration problems
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+// check adds a problem unless ok
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.add(format, args...)
+	}
+}
+
+func (v *validator) required(key, value string) {
+	v.check(value != "", "%s is required", key)
+}
+
+func (v *validator) port(key string, port int) {
+	v.check(port > 0 && port <= 65535, "%s must be between 1 and 65535, got %d", key, port)
+}
+
+func (v *validator) fraction(key string, value float64) {
+	v.check(value >= 0 && value <= 1, "%s must be between 0 and 1, got %v", key, value)
+}
+
+func (v *validator) nonNegative(key string, value int) {
+	v.check(value >= 0, "%s must not be negative, got %d", key, value)
+}
@@ -0,0 +1,263 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validConfig() *Config {
+	c := &Config{}
+	c.GeneralConfig = GeneralConfig{Env: "dev", LogLevel: "info", LogFormat: "logfmt", Port: 8080, Repository: RepositoryPostgres}
+	c.AccessLogConfig.SampleRate = 1
+	c.DatabaseConfig = DatabaseConfig{Host: "localhost", Port: 5432, DirectPort: 5432, User: "adbeacon", DBName: "adbeacon", MaxOpenConns: 25, MaxIdleConns: 5}
+	c.SLOConfig = SLOConfig{AvailabilityObjective: 0.999, LatencyTarget: 50, LatencyObjective: 0.99}
+	c.RetryConfig = RetryConfig{MaxAttempts: 3, BaseDelay: 10, MaxDelay: 100}
+	c.IdempotencyConfig.TTL = 24
+	c.IdempotencyConfig.MaxEntries = 10000
+	c.ReadinessConfig.RetryInterval = 2
+	c.SecretsConfig.Provider = SecretsProviderEnv
+
+	c.CacheConfig = cache.CacheConfig{
+		DefaultTTL:      5 * time.Minute,
+		RefreshInterval: time.Minute,
+		MemoryCacheSize: 1000,
+		EnableMemory:    true,
+		EnableRedis:     true,
+	}
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, validConfig().Validate())
+	})
+
+	t.Run("reports every problem", func(t *testing.T) {
+		c := validConfig()
+		c.GeneralConfig.Env = "staging"
+		c.GeneralConfig.Port = 70000
+		c.GeneralConfig.LogLevel = "verbose"
+		c.CacheConfig.RefreshInterval = 10 * time.Minute
+		c.CacheConfig.EnableRedis = false
+		c.CacheConfig.RedisHedgeDelay = 5 * time.Millisecond
+
+		err := c.Validate()
+		var validationErr *ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		assert.Len(t, validationErr.Problems, 5)
+		assert.Contains(t, err.Error(), "invalid configuration (5 problems)")
+		assert.Contains(t, err.Error(), "PORT must be between 1 and 65535, got 70000")
+		assert.Contains(t, err.Error(), "DB_PASSWORD (or DB_PASSWORD_FILE) is required outside the dev environment")
+		assert.Contains(t, err.Error(), "REDIS_HEDGE_DELAY requires CACHE_ENABLE_REDIS")
+	})
+
+	t.Run("native histograms", func(t *testing.T) {
+		c := validConfig()
+		c.MetricsConfig.NativeHistogramBucketFactor = 1.1
+		assert.NoError(t, c.Validate())
+
+		c.MetricsConfig.NativeHistogramBucketFactor = 0.5
+		assert.ErrorContains(t, c.Validate(), "METRICS_NATIVE_HISTOGRAM_BUCKET_FACTOR must be 0 or greater than 1, got 0.5")
+	})
+
+	t.Run("global redis", func(t *testing.T) {
+		c := validConfig()
+		c.CacheConfig.RedisAddr = "redis.eu-west-1.internal:6379"
+		c.CacheConfig.GlobalRedisAddr = c.CacheConfig.RedisAddr
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "CACHE_REGION is required with REDIS_GLOBAL_ADDR")
+		assert.Contains(t, err.Error(), "REDIS_GLOBAL_ADDR must differ from REDIS_ADDR")
+
+		c.CacheConfig.Region = "eu-west-1"
+		c.CacheConfig.GlobalRedisAddr = "redis.global.internal:6379"
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("cache persistence", func(t *testing.T) {
+		c := validConfig()
+		c.CacheConfig.EnableRedis = false
+		c.CacheConfig.PersistPath = "/var/lib/adbeacon/campaigns.json"
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "CACHE_PERSIST_PATH requires CACHE_ENABLE_REDIS")
+		assert.Contains(t, err.Error(), "CACHE_PERSIST_INTERVAL must be positive")
+
+		c.CacheConfig.EnableRedis = true
+		c.CacheConfig.PersistInterval = time.Minute
+		c.CacheConfig.PersistMaxAge = time.Hour
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("redis shards", func(t *testing.T) {
+		c := validConfig()
+		c.CacheConfig.RedisShardAddrs = []string{"redis-a:6379", "redis-b:6379", "redis-a:6379"}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `REDIS_SHARD_ADDRS lists "redis-a:6379" twice`)
+
+		c.CacheConfig.RedisShardAddrs = c.CacheConfig.RedisShardAddrs[:2]
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("events", func(t *testing.T) {
+		c := validConfig()
+		c.EventsConfig = EventsConfig{Enabled: true, KafkaTopic: "adbeacon-events", QueueSize: 100, BatchSize: 500, FlushInterval: 1000, ExportTimeout: 5000, MaxAttempts: 3}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "EVENTS_KAFKA_REST_URL is required")
+		assert.Contains(t, err.Error(), "EVENTS_BATCH_SIZE must be positive and not exceed EVENTS_QUEUE_SIZE (100), got 500")
+
+		// Disabled export isn't validated
+		c.EventsConfig.Enabled = false
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("quotas", func(t *testing.T) {
+		c := validConfig()
+		c.QuotaConfig = QuotaConfig{Enabled: true, Limits: []string{"3f2a9c1b7e4d:1000", "5b8e:lots"}, WarnFraction: 0.8, FlushInterval: 1000}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `QUOTA_LIMITS: invalid quota "5b8e:lots"`)
+
+		c.QuotaConfig.Limits = c.QuotaConfig.Limits[:1]
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("response signing secrets", func(t *testing.T) {
+		c := validConfig()
+		c.SigningConfig.Secrets = []string{"3f2a9c1b7e4d:s3cret", "5b8e"}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "RESPONSE_SIGNING_SECRETS: entry 2 isn't key_id:secret")
+
+		c.SigningConfig.Secrets = append(c.SigningConfig.Secrets[:1], "3f2a9c1b7e4d:other")
+		err = c.Validate()
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "other")
+
+		c.SigningConfig.Secrets = c.SigningConfig.Secrets[:1]
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("OS aliases", func(t *testing.T) {
+		c := validConfig()
+		c.ValidationConfig.OSAliases = []string{"tizen os:tizen", "kaios"}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `OS_ALIASES: invalid OS alias "kaios", expected alias:os`)
+	})
+
+	t.Run("runtime limits", func(t *testing.T) {
+		c := validConfig()
+		c.RuntimeConfig = RuntimeConfig{AutoMemLimit: true, MemLimitRatio: 1.5}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "RUNTIME_MEMLIMIT_RATIO must be greater than 0 and at most 1, got 1.5")
+
+		// The ratio only matters when the memory limit is derived
+		c.RuntimeConfig.AutoMemLimit = false
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("scheduler", func(t *testing.T) {
+		c := validConfig()
+		c.SchedulerConfig = SchedulerConfig{Enabled: true}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "SCHEDULER_INTERVAL_SECONDS must be positive, got 0")
+	})
+
+	t.Run("blocklist", func(t *testing.T) {
+		c := validConfig()
+		c.BlocklistConfig = BlocklistConfig{Enabled: true}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "BLOCKLIST_REFRESH_INTERVAL_SECONDS must be positive, got 0")
+	})
+
+	t.Run("dedup", func(t *testing.T) {
+		c := validConfig()
+		c.DedupConfig = DedupConfig{Enabled: true}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "DEDUP_WINDOW_SECONDS must be positive, got 0")
+	})
+
+	t.Run("replay protection", func(t *testing.T) {
+		c := validConfig()
+		c.ReplayProtectionConfig = ReplayProtectionConfig{Enabled: true, MaxSkew: 300}
+		assert.ErrorContains(t, c.Validate(), "REPLAY_PROTECTION_ENABLED requires RESPONSE_SIGNING_SECRETS")
+
+		c.SigningConfig.Secrets = []string{"3f2a9c1b7e4d:s3cret"}
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("creative proxy", func(t *testing.T) {
+		c := validConfig()
+		c.CreativeProxyConfig = CreativeProxyConfig{Enabled: true, BaseURL: "ads.example.com", CacheSize: 64, MaxSize: 512, Timeout: 2000}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `CREATIVE_PROXY_BASE_URL must be an http(s) URL, got "ads.example.com"`)
+
+		c.CreativeProxyConfig.BaseURL = "https://ads.example.com"
+		c.CreativeProxyConfig.MaxSize = 0
+		err = c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "CREATIVE_PROXY_MAX_SIZE_KB must be positive, got 0")
+	})
+
+	t.Run("creative validation", func(t *testing.T) {
+		c := validConfig()
+		c.CreativeValidationConfig = CreativeValidationConfig{Enabled: true, MaxSize: 512, Timeout: 3000, CDNPrefix: "http://cdn.example.com"}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `CREATIVE_CDN_PREFIX must be an https URL, got "http://cdn.example.com"`)
+	})
+
+	t.Run("reach", func(t *testing.T) {
+		c := validConfig()
+		c.ReachConfig = ReachConfig{Enabled: true, FlushInterval: 1000}
+
+		err := c.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "REACH_DAYS must be positive, got 0")
+
+		c.ReachConfig.Enabled = false
+		assert.NoError(t, c.Validate())
+	})
+}
+
+func TestValidateProd(t *testing.T) {
+	c := validConfig()
+	c.GeneralConfig.Env = ProfileProd
+	c.DatabaseConfig.Password = "s3cret"
+	c.DatabaseConfig.SSLMode = "disable"
+
+	err := c.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "REDIS_TLS is required in prod")
+	assert.Contains(t, err.Error(), `DB_SSLMODE must be require, verify-ca or verify-full in prod, got "disable"`)
+
+	c.DatabaseConfig.SSLMode = "verify-full"
+	c.CacheConfig.RedisTLS = true
+	assert.NoError(t, c.Validate())
+}
@@ -0,0 +1,64 @@
+package consent
+
+// Status of the consent of a delivery request, used as metric label
+const (
+	StatusNotApplicable = "not_applicable"
+	StatusGranted       = "granted"
+	StatusDenied        = "denied"
+	StatusMissing       = "missing"
+	StatusInvalid       = "invalid"
+)
+
+// Purposes the user must consent to for personalised ads: storing and accessing information on
+// the device, creating a personalised ads profile and selecting personalised ads
+var Purposes = []int{1, 3, 4}
+
+// Consent is what a delivery request allows doing with personal data
+type Consent struct {
+	// Applies reports whether GDPR applies to the request
+	Applies bool
+	// Status is one of the Status* values, empty for requests that weren't checked
+	Status string
+	// TCString is the parsed consent string, nil when missing or invalid
+	TCString *TCString
+}
+
+// AllowsPersonalData reports whether user based targeting and logging of user identifiers are
+// allowed, which is the case when GDPR doesn't apply or the user consented
+func (c Consent) AllowsPersonalData() bool {
+	return !c.Applies || c.Status == StatusGranted
+}
+
+// FromRequest evaluates the gdpr and gdpr_consent parameters of a request. GDPR applies when gdpr is
+// 1, or when it's missing but a consent string was sent, any other gdpr value fails closed. With
+// vendorID above 0 the user must also have consented to that vendor.
+func FromRequest(gdpr, tcString string, vendorID int) Consent {
+	switch {
+	case gdpr == "0", gdpr == "" && tcString == "":
+		return Consent{Status: StatusNotApplicable}
+	case gdpr != "1" && gdpr != "":
+		return Consent{Applies: true, Status: StatusInvalid}
+	case tcString == "":
+		return Consent{Applies: true, Status: StatusMissing}
+	}
+
+	tc, err := ParseTCString(tcString)
+	if err != nil {
+		return Consent{Applies: true, Status: StatusInvalid}
+	}
+	consent := Consent{Applies: true, Status: StatusGranted, TCString: tc}
+	for _, purpose := range Purposes {
+		if !tc.PurposeConsent(purpose) {
+			consent.Status = StatusDenied
+		}
+	}
+	if vendorID > 0 && !tc.VendorConsent(vendorID) {
+		consent.Status = StatusDenied
+	}
+	return consent
+}
+
+// Recorder counts delivery requests by consent status, metrics.CachedMetrics implements it
+type Recorder interface {
+	RecordConsent(status string)
+}
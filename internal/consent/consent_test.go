@@ -0,0 +1,43 @@
+package consent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromRequest(t *testing.T) {
+	deniedTCString := coreSegment(1, 3).int(755, 16).int(0, 1).flags(755, 2, 755).encode()
+
+	tests := []struct {
+		name       string
+		gdpr       string
+		tcString   string
+		vendorID   int
+		status     string
+		applies    bool
+		personalOK bool
+	}{
+		{"no parameters", "", "", 0, StatusNotApplicable, false, true},
+		{"outside GDPR", "0", bitfieldTCString, 0, StatusNotApplicable, false, true},
+		{"granted", "1", bitfieldTCString, 0, StatusGranted, true, true},
+		{"granted to vendor", "1", rangeTCString, 755, StatusGranted, true, true},
+		{"consent string without gdpr", "", bitfieldTCString, 0, StatusGranted, true, true},
+		{"purpose denied", "1", deniedTCString, 0, StatusDenied, true, false},
+		{"vendor denied", "1", bitfieldTCString, 42, StatusDenied, true, false},
+		{"missing", "1", "", 0, StatusMissing, true, false},
+		{"invalid consent string", "1", "garbage!", 0, StatusInvalid, true, false},
+		{"invalid gdpr", "yes", bitfieldTCString, 0, StatusInvalid, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consent := FromRequest(tt.gdpr, tt.tcString, tt.vendorID)
+			assert.Equal(t, tt.status, consent.Status)
+			assert.Equal(t, tt.applies, consent.Applies)
+			assert.Equal(t, tt.personalOK, consent.AllowsPersonalData())
+		})
+	}
+
+	// Requests that weren't checked aren't under GDPR
+	assert.True(t, Consent{}.AllowsPersonalData())
+}
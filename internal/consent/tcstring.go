@@ -0,0 +1,146 @@
+package consent
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTCString is wrapped by the errors of ParseTCString
+var ErrInvalidTCString = errors.New("invalid TC string")
+
+// TCString is the core segment of an IAB TCF v2 transparency and consent string
+type TCString struct {
+	Version           int
+	Created           time.Time
+	LastUpdated       time.Time
+	CMPID             int
+	CMPVersion        int
+	ConsentLanguage   string
+	VendorListVersion int
+	PolicyVersion     int
+	// PurposeConsents holds the consent to purposes 1-24, bit i-1 for purpose i
+	PurposeConsents uint32
+	// SpecialFeatureOptIns holds the opt-ins to special features 1-12, bit i-1 for feature i
+	SpecialFeatureOptIns uint16
+	// VendorConsents are the IDs of the vendors consented to
+	VendorConsents map[int]bool
+}
+
+// PurposeConsent reports whether the user consented to purpose
+func (tc *TCString) PurposeConsent(purpose int) bool {
+	return purpose >= 1 && purpose <= 24 && tc.PurposeConsents&(1<<(purpose-1)) != 0
+}
+
+// VendorConsent reports whether the user consented to the vendor with id
+func (tc *TCString) VendorConsent(id int) bool {
+	return tc.VendorConsents[id]
+}
+
+// ParseTCString decodes the core segment of a TCF v2 consent string, the other segments are ignored
+func ParseTCString(s string) (*TCString, error) {
+	core, _, _ := strings.Cut(strings.TrimSpace(s), ".")
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(core, "="))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTCString, err)
+	}
+
+	r := &bitReader{data: data}
+	tc := &TCString{Version: r.int(6)}
+	if tc.Version != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidTCString, tc.Version)
+	}
+	tc.Created = r.deciseconds()
+	tc.LastUpdated = r.deciseconds()
+	tc.CMPID = r.int(12)
+	tc.CMPVersion = r.int(12)
+	r.int(6) // consent screen
+	tc.ConsentLanguage = r.letters()
+	tc.VendorListVersion = r.int(12)
+	tc.PolicyVersion = r.int(6)
+	r.int(1) // is service specific
+	r.int(1) // use non-standard texts
+	tc.SpecialFeatureOptIns = uint16(r.bitfield(12))
+	tc.PurposeConsents = r.bitfield(24)
+	r.int(24) // purposes legitimate interest transparency
+	r.int(1)  // purpose one treatment
+	r.letters()
+
+	tc.VendorConsents = r.vendors()
+	if r.overflow {
+		return nil, fmt.Errorf("%w: core segment is truncated", ErrInvalidTCString)
+	}
+	return tc, nil
+}
+
+// bitReader reads big-endian bit fields, reads past the end return zeros and set overflow
+type bitReader struct {
+	data     []byte
+	offset   int
+	overflow bool
+}
+
+func (r *bitReader) int(bits int) int {
+	value := 0
+	for range bits {
+		value <<= 1
+		if r.offset >= len(r.data)*8 {
+			r.overflow = true
+		} else if r.data[r.offset/8]&(0x80>>(r.offset%8)) != 0 {
+			value |= 1
+		}
+		r.offset++
+	}
+	return value
+}
+
+// bitfield reads bits flags, the first one into the lowest bit of the result
+func (r *bitReader) bitfield(bits int) uint32 {
+	var value uint32
+	for i := range bits {
+		if r.int(1) == 1 {
+			value |= 1 << i
+		}
+	}
+	return value
+}
+
+func (r *bitReader) deciseconds() time.Time {
+	return time.UnixMilli(int64(r.int(36)) * 100).UTC()
+}
+
+// letters reads two letters of 6 bits each, 0 being 'A'
+func (r *bitReader) letters() string {
+	return string([]byte{byte('A' + r.int(6)), byte('A' + r.int(6))})
+}
+
+// vendors reads a vendor section, either a bitfield or a list of ranges
+func (r *bitReader) vendors() map[int]bool {
+	maxVendorID := r.int(16)
+	vendors := make(map[int]bool)
+	if r.int(1) == 0 {
+		for id := 1; id <= maxVendorID; id++ {
+			if r.int(1) == 1 {
+				vendors[id] = true
+			}
+		}
+		return vendors
+	}
+
+	entries := r.int(12)
+	for range entries {
+		isRange := r.int(1) == 1
+		start := r.int(16)
+		end := start
+		if isRange {
+			end = r.int(16)
+		}
+		// Ranges beyond the declared maximum are malformed, don't let them allocate
+		for id := start; id <= end && id <= maxVendorID && !r.overflow; id++ {
+			vendors[id] = true
+		}
+	}
+	return vendors
+}
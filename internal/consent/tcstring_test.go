@@ -0,0 +1,115 @@
+package consent
+
+import (
+	"encoding/base64"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bitWriter builds TC strings for tests
+type bitWriter struct {
+	bits []bool
+}
+
+func (w *bitWriter) int(value, bits int) *bitWriter {
+	for i := bits - 1; i >= 0; i-- {
+		w.bits = append(w.bits, value&(1<<i) != 0)
+	}
+	return w
+}
+
+func (w *bitWriter) flags(bits int, set ...int) *bitWriter {
+	for i := 1; i <= bits; i++ {
+		w.int(boolInt(slices.Contains(set, i)), 1)
+	}
+	return w
+}
+
+func (w *bitWriter) encode() string {
+	data := make([]byte, (len(w.bits)+7)/8)
+	for i, bit := range w.bits {
+		if bit {
+			data[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// coreSegment writes the core segment fields up to the vendor section
+func coreSegment(purposes ...int) *bitWriter {
+	updated := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC).UnixMilli() / 100
+	return new(bitWriter).
+		int(2, 6).              // version
+		int(int(updated), 36).  // created
+		int(int(updated), 36).  // last updated
+		int(7, 12).             // CMP ID
+		int(1, 12).             // CMP version
+		int(0, 6).              // consent screen
+		int(4, 6).int(13, 6).   // consent language "EN"
+		int(150, 12).           // vendor list version
+		int(4, 6).              // policy version
+		int(0, 1).int(0, 1).    // service specific, non-standard texts
+		flags(12, 1).           // special features
+		flags(24, purposes...). // purpose consents
+		flags(24).              // purpose legitimate interests
+		int(0, 1).              // purpose one treatment
+		int(3, 6).int(4, 6)     // publisher country "DE"
+}
+
+// TC strings consenting to purposes 1, 3 and 4 and vendors 2 and 755, with both vendor encodings
+var (
+	bitfieldTCString = coreSegment(1, 3, 4).int(755, 16).int(0, 1).flags(755, 2, 755).encode()
+	rangeTCString    = coreSegment(1, 3, 4).int(755, 16).int(1, 1).int(2, 12).int(0, 1).int(2, 16).int(1, 1).int(755, 16).int(755, 16).encode()
+)
+
+func TestParseTCString(t *testing.T) {
+	for name, s := range map[string]string{"bitfield": bitfieldTCString, "range": rangeTCString} {
+		t.Run(name, func(t *testing.T) {
+			// Other segments after the core one are ignored
+			tc, err := ParseTCString(s + ".YAAAAAAAAAAA")
+			require.NoError(t, err)
+
+			assert.Equal(t, 2, tc.Version)
+			assert.Equal(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), tc.LastUpdated)
+			assert.Equal(t, 7, tc.CMPID)
+			assert.Equal(t, "EN", tc.ConsentLanguage)
+			assert.Equal(t, 150, tc.VendorListVersion)
+			assert.Equal(t, uint16(1), tc.SpecialFeatureOptIns)
+
+			assert.True(t, tc.PurposeConsent(1))
+			assert.False(t, tc.PurposeConsent(2))
+			assert.True(t, tc.PurposeConsent(4))
+			assert.False(t, tc.PurposeConsent(0))
+
+			assert.True(t, tc.VendorConsent(2))
+			assert.True(t, tc.VendorConsent(755))
+			assert.False(t, tc.VendorConsent(3))
+		})
+	}
+}
+
+func TestParseTCString_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"empty":      "",
+		"not base64": "not a consent string!",
+		"version 1":  new(bitWriter).int(1, 6).int(0, 200).encode(),
+		"truncated":  coreSegment(1, 3, 4).encode(),
+	}
+	for name, s := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTCString(s)
+			assert.ErrorIs(t, err, ErrInvalidTCString)
+		})
+	}
+}
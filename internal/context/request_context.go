@@ -2,9 +2,12 @@ package context
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/consent"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 )
 
 // RequestContextKey represents keys used in request context
@@ -19,6 +22,20 @@ const (
 	UserAgentKey RequestContextKey = "user_agent"
 	// RemoteAddrKey is the context key for remote address
 	RemoteAddrKey RequestContextKey = "remote_addr"
+	// TraceIDKey is the context key for the distributed trace ID
+	TraceIDKey RequestContextKey = "trace_id"
+	// SpanIDKey is the context key for the ID of the current span of the trace
+	SpanIDKey RequestContextKey = "span_id"
+	// ClientIDKey is the context key for the client (publisher or API key) used in metric labels
+	ClientIDKey RequestContextKey = "client_id"
+	// ClockKey is the context key for the clock overriding the delivery service clock
+	ClockKey RequestContextKey = "clock"
+	// APIKeyIDKey is the context key for the ID of the API key deliveries are counted against
+	APIKeyIDKey RequestContextKey = "api_key_id"
+	// ConsentKey is the context key for the GDPR consent of the request
+	ConsentKey RequestContextKey = "consent"
+	// DeliveryOutcomeKey is the context key for the DeliveryOutcome of a delivery request
+	DeliveryOutcomeKey RequestContextKey = "delivery_outcome"
 )
 
 // RequestInfo holds information about the current request
@@ -81,6 +98,149 @@ func GetRemoteAddr(ctx context.Context) string {
 	return ""
 }
 
+// WithTraceID adds a trace ID to the context
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, TraceIDKey, traceID)
+}
+
+// GetTraceID retrieves the trace ID from context
+func GetTraceID(ctx context.Context) string {
+	if traceID, ok := ctx.Value(TraceIDKey).(string); ok {
+		return traceID
+	}
+	return ""
+}
+
+// WithSpanID adds the ID of the current span to the context
+func WithSpanID(ctx context.Context, spanID string) context.Context {
+	return context.WithValue(ctx, SpanIDKey, spanID)
+}
+
+// GetSpanID retrieves the ID of the current span from context
+func GetSpanID(ctx context.Context) string {
+	if spanID, ok := ctx.Value(SpanIDKey).(string); ok {
+		return spanID
+	}
+	return ""
+}
+
+// WithClientID adds a client ID to the context
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, ClientIDKey, clientID)
+}
+
+// GetClientID retrieves the client ID from context
+func GetClientID(ctx context.Context) string {
+	if clientID, ok := ctx.Value(ClientIDKey).(string); ok {
+		return clientID
+	}
+	return ""
+}
+
+// WithAPIKeyID adds the ID of the API key of the request to the context
+func WithAPIKeyID(ctx context.Context, keyID string) context.Context {
+	return context.WithValue(ctx, APIKeyIDKey, keyID)
+}
+
+// GetAPIKeyID retrieves the API key ID from context
+func GetAPIKeyID(ctx context.Context) string {
+	if keyID, ok := ctx.Value(APIKeyIDKey).(string); ok {
+		return keyID
+	}
+	return ""
+}
+
+// WithConsent adds the GDPR consent of the request to the context
+func WithConsent(ctx context.Context, c consent.Consent) context.Context {
+	return context.WithValue(ctx, ConsentKey, c)
+}
+
+// GetConsent retrieves the consent from context, requests without one aren't under GDPR
+func GetConsent(ctx context.Context) consent.Consent {
+	if c, ok := ctx.Value(ConsentKey).(consent.Consent); ok {
+		return c
+	}
+	return consent.Consent{}
+}
+
+// DeliveryOutcome tells HTTP middlewares how the delivery service answered a request, the service
+// fills it in
+type DeliveryOutcome struct {
+	// Matched is set when the campaigns served were matched to the request, not when it failed or
+	// was answered before reaching the service
+	Matched bool
+	// Age is how long ago the campaigns served were matched, 0 unless they were remembered
+	Age time.Duration
+}
+
+// WithDeliveryOutcome adds an empty delivery outcome to the context, returning it to read once the
+// request was answered
+func WithDeliveryOutcome(ctx context.Context) (context.Context, *DeliveryOutcome) {
+	outcome := &DeliveryOutcome{}
+	return context.WithValue(ctx, DeliveryOutcomeKey, outcome), outcome
+}
+
+// GetDeliveryOutcome retrieves the delivery outcome from context, nil when there is none
+func GetDeliveryOutcome(ctx context.Context) *DeliveryOutcome {
+	if outcome, ok := ctx.Value(DeliveryOutcomeKey).(*DeliveryOutcome); ok {
+		return outcome
+	}
+	return nil
+}
+
+// WithClock adds a clock to the context, the delivery service reads the request time from it
+func WithClock(ctx context.Context, clock models.Clock) context.Context {
+	return context.WithValue(ctx, ClockKey, clock)
+}
+
+// GetClock retrieves the clock from context, nil when there is none
+func GetClock(ctx context.Context) models.Clock {
+	if clock, ok := ctx.Value(ClockKey).(models.Clock); ok {
+		return clock
+	}
+	return nil
+}
+
+// ParseTraceParent extracts the trace ID from a W3C traceparent header
+// (version-traceid-parentid-flags), returns empty string if the header is invalid
+func ParseTraceParent(header string) string {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 {
+		return ""
+	}
+
+	traceID := strings.ToLower(parts[1])
+	if !isTraceParentID(traceID) {
+		return ""
+	}
+	return traceID
+}
+
+// ParseTraceParentSpanID extracts the parent span ID from a W3C traceparent header, returns
+// empty string if the header is invalid
+func ParseTraceParentSpanID(header string) string {
+	if ParseTraceParent(header) == "" {
+		return ""
+	}
+
+	spanID := strings.ToLower(strings.Split(strings.TrimSpace(header), "-")[2])
+	if len(spanID) != 16 || !isTraceParentID(spanID) {
+		return ""
+	}
+	return spanID
+}
+
+// isTraceParentID reports whether id is lowercase hex and not all zeros, which is invalid per
+// the spec
+func isTraceParentID(id string) bool {
+	for _, c := range id {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return strings.Trim(id, "0") != ""
+}
+
 // NewRequestContext creates a new request context with all necessary information
 func NewRequestContext(ctx context.Context, userAgent, remoteAddr string) context.Context {
 	requestID := uuid.New().String()
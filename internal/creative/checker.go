@@ -0,0 +1,135 @@
+package creative
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// BrokenRecorder reports the number of active campaigns with a broken image,
+// metrics.CachedMetrics implements it
+type BrokenRecorder interface {
+	SetBrokenCreatives(count int)
+}
+
+// BrokenCreative is an active campaign whose image failed its last check
+type BrokenCreative struct {
+	CID   string `json:"cid"`
+	Img   string `json:"img"`
+	Error string `json:"error"`
+	// Since is when the image was first found broken, CheckedAt when it was last checked
+	Since     time.Time `json:"since"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Checker periodically revalidates the images of active campaigns, which may break long after
+// the campaigns were created, and keeps the ones that failed. Each replica runs its own checks.
+type Checker struct {
+	validator *Validator
+	source    CampaignSource
+	recorder  BrokenRecorder
+	logger    log.Logger
+	now       func() time.Time
+
+	mu     sync.Mutex
+	broken map[string]BrokenCreative
+}
+
+// NewChecker creates a checker validating the images of the campaigns of source, recorder may be nil
+func NewChecker(validator *Validator, source CampaignSource, recorder BrokenRecorder, logger log.Logger) *Checker {
+	return &Checker{
+		validator: validator,
+		source:    source,
+		recorder:  recorder,
+		logger:    logger,
+		now:       time.Now,
+		broken:    make(map[string]BrokenCreative),
+	}
+}
+
+// Run checks the images every interval until ctx is done
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Check(ctx); err != nil {
+			level.Warn(c.logger).Log("msg", "creative check failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check validates the image of every active campaign once, images shared by several campaigns
+// are fetched once. Campaigns no longer active or whose image recovered are forgotten.
+func (c *Checker) Check(ctx context.Context) error {
+	campaigns, err := c.source.GetActiveCampaignsWithRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := c.now()
+	results := make(map[string]error)
+	broken := make(map[string]BrokenCreative)
+	for _, campaign := range campaigns {
+		if campaign.ImageURL == "" {
+			continue
+		}
+		checkErr, checked := results[campaign.ImageURL]
+		if !checked {
+			checkErr = c.validator.Check(ctx, campaign.ImageURL)
+			results[campaign.ImageURL] = checkErr
+		}
+		if checkErr == nil {
+			continue
+		}
+		broken[campaign.ID] = BrokenCreative{
+			CID:       campaign.ID,
+			Img:       campaign.ImageURL,
+			Error:     checkErr.Error(),
+			Since:     now,
+			CheckedAt: now,
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	c.mu.Lock()
+	for id, creative := range broken {
+		if previous, ok := c.broken[id]; ok && previous.Img == creative.Img {
+			creative.Since = previous.Since
+			broken[id] = creative
+			continue
+		}
+		level.Warn(c.logger).Log("msg", "campaign image is broken", "campaign_id", id, "img", creative.Img, "err", creative.Error)
+	}
+	c.broken = broken
+	c.mu.Unlock()
+
+	if c.recorder != nil {
+		c.recorder.SetBrokenCreatives(len(broken))
+	}
+	return nil
+}
+
+// Broken returns the campaigns whose image failed the last check, ordered by campaign ID
+func (c *Checker) Broken() []BrokenCreative {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	broken := make([]BrokenCreative, 0, len(c.broken))
+	for _, creative := range c.broken {
+		broken = append(broken, creative)
+	}
+	sort.Slice(broken, func(i, j int) bool { return broken[i].CID < broken[j].CID })
+	return broken
+}
@@ -0,0 +1,328 @@
+package creative
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+var (
+	// ErrNotFound is returned for campaigns that aren't active or have no image
+	ErrNotFound = errors.New("creative not found")
+	// ErrUpstream is returned when the image can't be fetched or isn't an image the proxy serves
+	ErrUpstream = errors.New("creative unavailable")
+)
+
+// Outcomes of creative requests reported to the Recorder
+const (
+	OutcomeHit         = "hit"
+	OutcomeMiss        = "miss"
+	OutcomeRevalidated = "revalidated"
+	OutcomeError       = "error"
+)
+
+// Recorder counts creative requests by outcome, metrics.CachedMetrics implements it
+type Recorder interface {
+	RecordCreativeRequest(outcome string)
+}
+
+// CampaignSource provides the active campaigns whose images are served, the cached repository
+// implements it
+type CampaignSource interface {
+	GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error)
+}
+
+// Config configures the proxy
+type Config struct {
+	// CacheBytes bounds the size of the cached images, the least recently used are evicted
+	CacheBytes int64
+	// MaxCreativeBytes bounds the size of a single image, larger images aren't served
+	MaxCreativeBytes int64
+	// DefaultTTL is how long images are cached when the upstream doesn't send a max-age
+	DefaultTTL time.Duration
+	// Timeout bounds fetching an image from the upstream
+	Timeout time.Duration
+}
+
+// Creative is an image as served by the proxy
+type Creative struct {
+	Body        []byte
+	ContentType string
+	// ETag is a strong validator of the body, independent of the upstream's validators
+	ETag string
+	// MaxAge is how long clients may cache the image, 0 when they must revalidate it
+	MaxAge time.Duration
+	// NoStore is set when the upstream forbids storing the image
+	NoStore bool
+}
+
+// entry is a cached image with the validators to revalidate it against the upstream
+type entry struct {
+	url          string
+	creative     Creative
+	upstreamETag string
+	lastModified string
+	expires      time.Time
+}
+
+// call is a fetch in flight, concurrent misses of the same URL wait for it
+type call struct {
+	done     chan struct{}
+	creative Creative
+	err      error
+}
+
+// Proxy serves campaign images from the ad server's own origin, for pages whose Content
+// Security Policy doesn't allow loading images from advertisers' hosts. Images are cached in
+// memory, bounded in size, for the max-age sent by the upstream and revalidated with its ETag
+// or Last-Modified once stale. Only raster images are served, SVGs can carry scripts.
+type Proxy struct {
+	source   CampaignSource
+	config   Config
+	client   *http.Client
+	recorder Recorder
+	now      func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List // of *entry, most recently used first
+	size     int64
+	inFlight map[string]*call
+}
+
+// New creates a proxy serving the images of the campaigns of source, recorder may be nil
+func New(source CampaignSource, config Config, recorder Recorder) *Proxy {
+	return &Proxy{
+		source:   source,
+		config:   config,
+		client:   &http.Client{Timeout: config.Timeout},
+		recorder: recorder,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		inFlight: make(map[string]*call),
+	}
+}
+
+// Get returns the image of the active campaign campaignID served to requests in lang, a
+// normalized language tag or empty, from the cache while it's fresh
+func (p *Proxy) Get(ctx context.Context, campaignID, lang string) (Creative, error) {
+	imageURL, err := p.resolve(ctx, campaignID, lang)
+	if err != nil {
+		return Creative{}, err
+	}
+
+	p.mu.Lock()
+	var cached *entry
+	if element, ok := p.entries[imageURL]; ok {
+		cached = element.Value.(*entry)
+		if now := p.now(); now.Before(cached.expires) {
+			p.order.MoveToFront(element)
+			creative := cached.creative
+			creative.MaxAge = cached.expires.Sub(now).Truncate(time.Second)
+			p.mu.Unlock()
+			p.record(OutcomeHit)
+			return creative, nil
+		}
+	}
+	// Concurrent misses of the same image share one fetch
+	if inFlight, ok := p.inFlight[imageURL]; ok {
+		p.mu.Unlock()
+		select {
+		case <-inFlight.done:
+			return inFlight.creative, inFlight.err
+		case <-ctx.Done():
+			return Creative{}, ctx.Err()
+		}
+	}
+	fetch := &call{done: make(chan struct{})}
+	p.inFlight[imageURL] = fetch
+	p.mu.Unlock()
+
+	// The fetch outlives a waiting client that goes away, the others still need it
+	fetch.creative, fetch.err = p.fetch(context.WithoutCancel(ctx), imageURL, cached)
+
+	p.mu.Lock()
+	delete(p.inFlight, imageURL)
+	p.mu.Unlock()
+	close(fetch.done)
+	return fetch.creative, fetch.err
+}
+
+// resolve returns the image URL of the active campaign campaignID in lang, see
+// models.CampaignWithRules.Creative
+func (p *Proxy) resolve(ctx context.Context, campaignID, lang string) (string, error) {
+	campaigns, err := p.source.GetActiveCampaignsWithRules(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load campaigns: %w", err)
+	}
+	for _, campaign := range campaigns {
+		if campaign.ID == campaignID {
+			imageURL := campaign.Creative(lang).Img
+			if imageURL == "" {
+				return "", ErrNotFound
+			}
+			return imageURL, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// fetch requests imageURL from the upstream, conditionally when a stale entry is cached, and
+// caches the result
+func (p *Proxy) fetch(ctx context.Context, imageURL string, stale *entry) (Creative, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		p.record(OutcomeError)
+		return Creative{}, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	if stale != nil {
+		if stale.upstreamETag != "" {
+			req.Header.Set("If-None-Match", stale.upstreamETag)
+		}
+		if stale.lastModified != "" {
+			req.Header.Set("If-Modified-Since", stale.lastModified)
+		}
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.record(OutcomeError)
+		return Creative{}, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+
+	ttl, noStore := p.freshness(resp.Header)
+
+	// The cached image is still current, only its expiry moves
+	if resp.StatusCode == http.StatusNotModified && stale != nil {
+		p.record(OutcomeRevalidated)
+		updated := *stale
+		updated.expires = p.now().Add(ttl)
+		updated.creative.MaxAge = ttl
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			updated.upstreamETag = etag
+		}
+		p.store(&updated)
+		return updated.creative, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		p.record(OutcomeError)
+		return Creative{}, fmt.Errorf("%w: upstream answered %d", ErrUpstream, resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !servable(contentType) {
+		p.record(OutcomeError)
+		return Creative{}, fmt.Errorf("%w: content type %q is not served", ErrUpstream, contentType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, p.config.MaxCreativeBytes+1))
+	if err != nil {
+		p.record(OutcomeError)
+		return Creative{}, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	if int64(len(body)) > p.config.MaxCreativeBytes {
+		p.record(OutcomeError)
+		return Creative{}, fmt.Errorf("%w: image exceeds %d bytes", ErrUpstream, p.config.MaxCreativeBytes)
+	}
+
+	p.record(OutcomeMiss)
+	sum := sha256.Sum256(body)
+	creative := Creative{
+		Body:        body,
+		ContentType: contentType,
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		MaxAge:      ttl,
+		NoStore:     noStore,
+	}
+	if noStore {
+		p.remove(imageURL)
+		return creative, nil
+	}
+	p.store(&entry{
+		url:          imageURL,
+		creative:     creative,
+		upstreamETag: resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		expires:      p.now().Add(ttl),
+	})
+	return creative, nil
+}
+
+// freshness returns how long a response may be cached from its Cache-Control header, and
+// whether it may be stored at all
+func (p *Proxy) freshness(header http.Header) (time.Duration, bool) {
+	ttl := p.config.DefaultTTL
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "private":
+			return 0, true
+		case "no-cache":
+			ttl = 0
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds >= 0 {
+				ttl = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return ttl, false
+}
+
+// servable reports whether images of contentType are served, SVGs are excluded as they can
+// run scripts when opened directly from the ad server's origin
+func servable(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.HasPrefix(mediaType, "image/") && mediaType != "image/svg+xml"
+}
+
+// store caches e, evicting the least recently used images beyond the size bound. Images larger
+// than the whole cache aren't cached.
+func (p *Proxy) store(e *entry) {
+	size := int64(len(e.creative.Body))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.removeLocked(e.url)
+	if size > p.config.CacheBytes {
+		return
+	}
+	p.entries[e.url] = p.order.PushFront(e)
+	p.size += size
+	for p.size > p.config.CacheBytes {
+		p.removeLocked(p.order.Back().Value.(*entry).url)
+	}
+}
+
+// remove forgets the cached image of url
+func (p *Proxy) remove(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeLocked(url)
+}
+
+func (p *Proxy) removeLocked(url string) {
+	if element, ok := p.entries[url]; ok {
+		p.order.Remove(element)
+		delete(p.entries, url)
+		p.size -= int64(len(element.Value.(*entry).creative.Body))
+	}
+}
+
+func (p *Proxy) record(outcome string) {
+	if p.recorder != nil {
+		p.recorder.RecordCreativeRequest(outcome)
+	}
+}
@@ -0,0 +1,166 @@
+package creative
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// campaignList is a CampaignSource serving a fixed list of campaigns
+type campaignList []models.CampaignWithRules
+
+func (l campaignList) GetActiveCampaignsWithRules(context.Context) ([]models.CampaignWithRules, error) {
+	return l, nil
+}
+
+// outcomeCounter counts creative requests by outcome
+type outcomeCounter map[string]int
+
+func (c outcomeCounter) RecordCreativeRequest(outcome string) { c[outcome]++ }
+
+func TestProxy(t *testing.T) {
+	fetches := 0
+	revalidations := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		switch r.URL.Path {
+		case "/banner.png":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				revalidations++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png-bytes"))
+		case "/private.jpg":
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("jpeg-bytes"))
+		case "/logo.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte("<svg/>"))
+		case "/huge.gif":
+			w.Header().Set("Content-Type", "image/gif")
+			w.Write([]byte(strings.Repeat("x", 100)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	campaigns := campaignList{
+		{Campaign: models.Campaign{ID: "spotify", ImageURL: upstream.URL + "/banner.png"}},
+		{Campaign: models.Campaign{ID: "private", ImageURL: upstream.URL + "/private.jpg"}},
+		{Campaign: models.Campaign{ID: "svg", ImageURL: upstream.URL + "/logo.svg"}},
+		{Campaign: models.Campaign{ID: "huge", ImageURL: upstream.URL + "/huge.gif"}},
+		{Campaign: models.Campaign{ID: "gone", ImageURL: upstream.URL + "/gone.png"}},
+		{Campaign: models.Campaign{ID: "noimage"}},
+	}
+	recorder := outcomeCounter{}
+	proxy := New(campaigns, Config{CacheBytes: 1024, MaxCreativeBytes: 64, DefaultTTL: time.Minute, Timeout: time.Second}, recorder)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	proxy.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Images are fetched once and served from the cache while fresh
+	creative, err := proxy.Get(ctx, "spotify", "")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("png-bytes"), creative.Body)
+	assert.Equal(t, "image/png", creative.ContentType)
+	assert.Equal(t, time.Minute, creative.MaxAge)
+	assert.NotEqual(t, `"v1"`, creative.ETag)
+
+	now = now.Add(20 * time.Second)
+	cached, err := proxy.Get(ctx, "spotify", "")
+	require.NoError(t, err)
+	assert.Equal(t, creative.ETag, cached.ETag)
+	assert.Equal(t, 40*time.Second, cached.MaxAge)
+	assert.Equal(t, 1, fetches)
+
+	// Stale images are revalidated with the upstream's ETag
+	now = now.Add(time.Minute)
+	revalidated, err := proxy.Get(ctx, "spotify", "")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("png-bytes"), revalidated.Body)
+	assert.Equal(t, creative.ETag, revalidated.ETag)
+	assert.Equal(t, time.Minute, revalidated.MaxAge)
+	assert.Equal(t, 1, revalidations)
+
+	// Images the upstream forbids storing are fetched every time
+	for i := 0; i < 2; i++ {
+		private, err := proxy.Get(ctx, "private", "")
+		require.NoError(t, err)
+		assert.True(t, private.NoStore)
+	}
+	assert.Equal(t, 4, fetches)
+
+	// SVGs, oversized images and upstream errors aren't served
+	for _, id := range []string{"svg", "huge", "gone"} {
+		_, err := proxy.Get(ctx, id, "")
+		assert.ErrorIs(t, err, ErrUpstream, id)
+	}
+	for _, id := range []string{"noimage", "unknown"} {
+		_, err := proxy.Get(ctx, id, "")
+		assert.ErrorIs(t, err, ErrNotFound, id)
+	}
+
+	assert.Equal(t, outcomeCounter{OutcomeMiss: 3, OutcomeHit: 1, OutcomeRevalidated: 1, OutcomeError: 3}, recorder)
+}
+
+func TestProxyEvictsLeastRecentlyUsed(t *testing.T) {
+	fetches := map[string]int{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches[r.URL.Path]++
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(strings.Repeat("x", 40)))
+	}))
+	defer upstream.Close()
+
+	campaigns := campaignList{
+		{Campaign: models.Campaign{ID: "a", ImageURL: upstream.URL + "/a.png"}},
+		{Campaign: models.Campaign{ID: "b", ImageURL: upstream.URL + "/b.png"}},
+		{Campaign: models.Campaign{ID: "c", ImageURL: upstream.URL + "/c.png"}},
+	}
+	proxy := New(campaigns, Config{CacheBytes: 100, MaxCreativeBytes: 64, DefaultTTL: time.Minute, Timeout: time.Second}, nil)
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b", "a", "c", "a", "b"} {
+		_, err := proxy.Get(ctx, id, "")
+		require.NoError(t, err)
+	}
+	// Caching c evicted b, which was used less recently than a
+	assert.Equal(t, map[string]int{"/a.png": 1, "/b.png": 2, "/c.png": 1}, fetches)
+	assert.LessOrEqual(t, proxy.size, int64(100))
+}
+
+func TestProxyServesLocalizedImages(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	campaigns := campaignList{
+		{Campaign: models.Campaign{ID: "spotify", ImageURL: upstream.URL + "/banner.png"}, Creatives: []models.Creative{
+			{Lang: "es", Img: upstream.URL + "/banner-es.png", CTA: "Descargar"},
+			{Lang: "de", CTA: "Herunterladen"},
+		}},
+	}
+	proxy := New(campaigns, Config{CacheBytes: 1024, MaxCreativeBytes: 64, DefaultTTL: time.Minute, Timeout: time.Second}, nil)
+	ctx := context.Background()
+
+	for lang, want := range map[string]string{"": "/banner.png", "es-mx": "/banner-es.png", "de": "/banner.png", "fr": "/banner.png"} {
+		creative, err := proxy.Get(ctx, "spotify", lang)
+		require.NoError(t, err, lang)
+		assert.Equal(t, want, string(creative.Body), lang)
+	}
+}
@@ -0,0 +1,109 @@
+package creative
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidImage is returned for campaign images that aren't https, can't be fetched, aren't
+// images or are too large
+var ErrInvalidImage = errors.New("invalid image")
+
+// ValidatorConfig configures the validator
+type ValidatorConfig struct {
+	// MaxBytes bounds the size of campaign images
+	MaxBytes int64
+	// Timeout bounds fetching an image
+	Timeout time.Duration
+	// CDNPrefix is prepended to the host and path of validated images, so they are served through
+	// a pull-through CDN. Empty keeps the images as they are.
+	CDNPrefix string
+}
+
+// Validator checks campaign images when campaigns are created or their image changes, and
+// rewrites them to the CDN
+type Validator struct {
+	config ValidatorConfig
+	client *http.Client
+}
+
+// NewValidator creates a validator fetching images with config.Timeout
+func NewValidator(config ValidatorConfig) *Validator {
+	return &Validator{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Check fetches imageURL and checks that it's an https URL answering 200 with an image of at
+// most MaxBytes. Images already on the CDN are checked like any other.
+func (v *Validator) Check(ctx context.Context, imageURL string) error {
+	u, err := url.Parse(imageURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: img must be an https URL, got %q", ErrInvalidImage, imageURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: img is not reachable: %v", ErrInvalidImage, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: img answered %d", ErrInvalidImage, resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || !strings.HasPrefix(mediaType, "image/") {
+		return fmt.Errorf("%w: img must be an image, got content type %q", ErrInvalidImage, contentType)
+	}
+	// The Content-Length can't be trusted to be present or right, the body is what counts
+	size, err := io.Copy(io.Discard, io.LimitReader(resp.Body, v.config.MaxBytes+1))
+	if err != nil {
+		return fmt.Errorf("%w: failed to read img: %v", ErrInvalidImage, err)
+	}
+	if size > v.config.MaxBytes {
+		return fmt.Errorf("%w: img must be at most %d bytes", ErrInvalidImage, v.config.MaxBytes)
+	}
+	return nil
+}
+
+// Rewrite returns imageURL on the CDN: the CDN prefix followed by the host, path and query of
+// the image. Images already on the CDN, and all images without a CDN prefix, are returned as
+// they are.
+func (v *Validator) Rewrite(imageURL string) string {
+	prefix := strings.TrimSuffix(v.config.CDNPrefix, "/")
+	if prefix == "" || strings.HasPrefix(imageURL, prefix+"/") {
+		return imageURL
+	}
+	u, err := url.Parse(imageURL)
+	if err != nil || u.Host == "" {
+		return imageURL
+	}
+	rewritten := prefix + "/" + u.Host + u.EscapedPath()
+	if u.RawQuery != "" {
+		rewritten += "?" + u.RawQuery
+	}
+	return rewritten
+}
+
+// Prepare checks imageURL and returns it rewritten to the CDN, empty images are left alone
+func (v *Validator) Prepare(ctx context.Context, imageURL string) (string, error) {
+	if imageURL == "" {
+		return "", nil
+	}
+	if err := v.Check(ctx, imageURL); err != nil {
+		return "", err
+	}
+	return v.Rewrite(imageURL), nil
+}
@@ -0,0 +1,134 @@
+package creative
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// imageHost serves a small PNG at /banner.png, an HTML page at /page.html, an oversized GIF at
+// /huge.gif and 404 for everything else
+func imageHost(t *testing.T) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/banner.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png-bytes"))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/huge.gif":
+			w.Header().Set("Content-Type", "image/gif")
+			w.Write([]byte(strings.Repeat("x", 100)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testValidator creates a validator trusting server's certificate
+func testValidator(server *httptest.Server, cdnPrefix string) *Validator {
+	validator := NewValidator(ValidatorConfig{MaxBytes: 64, Timeout: time.Second, CDNPrefix: cdnPrefix})
+	validator.client = server.Client()
+	return validator
+}
+
+func TestValidatorCheck(t *testing.T) {
+	server := imageHost(t)
+	validator := testValidator(server, "")
+	ctx := context.Background()
+
+	assert.NoError(t, validator.Check(ctx, server.URL+"/banner.png"))
+
+	for url, message := range map[string]string{
+		"http://example.com/banner.png": "img must be an https URL",
+		"banner.png":                    "img must be an https URL",
+		server.URL + "/missing.png":     "img answered 404",
+		server.URL + "/page.html":       `img must be an image, got content type "text/html"`,
+		server.URL + "/huge.gif":        "img must be at most 64 bytes",
+	} {
+		err := validator.Check(ctx, url)
+		assert.ErrorIs(t, err, ErrInvalidImage, url)
+		assert.ErrorContains(t, err, message, url)
+	}
+}
+
+func TestValidatorRewrite(t *testing.T) {
+	validator := NewValidator(ValidatorConfig{CDNPrefix: "https://cdn.adbeacon.example/"})
+
+	assert.Equal(t, "https://cdn.adbeacon.example/images.spotify.com/ads/banner%20v2.png?w=320",
+		validator.Rewrite("https://images.spotify.com/ads/banner%20v2.png?w=320"))
+	// Images already on the CDN aren't rewritten twice
+	assert.Equal(t, "https://cdn.adbeacon.example/images.spotify.com/banner.png",
+		validator.Rewrite("https://cdn.adbeacon.example/images.spotify.com/banner.png"))
+
+	assert.Equal(t, "https://images.spotify.com/banner.png",
+		NewValidator(ValidatorConfig{}).Rewrite("https://images.spotify.com/banner.png"))
+}
+
+func TestValidatorPrepare(t *testing.T) {
+	server := imageHost(t)
+	validator := testValidator(server, "https://cdn.adbeacon.example")
+	ctx := context.Background()
+
+	prepared, err := validator.Prepare(ctx, server.URL+"/banner.png")
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.adbeacon.example/"+strings.TrimPrefix(server.URL, "https://")+"/banner.png", prepared)
+
+	// Campaigns without an image are left alone
+	prepared, err = validator.Prepare(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, prepared)
+
+	_, err = validator.Prepare(ctx, server.URL+"/missing.png")
+	assert.ErrorIs(t, err, ErrInvalidImage)
+}
+
+// brokenGauge keeps the last number of broken creatives reported
+type brokenGauge struct{ count int }
+
+func (g *brokenGauge) SetBrokenCreatives(count int) { g.count = count }
+
+func TestChecker(t *testing.T) {
+	server := imageHost(t)
+	campaigns := campaignList{
+		{Campaign: models.Campaign{ID: "spotify", ImageURL: server.URL + "/banner.png"}},
+		{Campaign: models.Campaign{ID: "netflix", ImageURL: server.URL + "/missing.png"}},
+		{Campaign: models.Campaign{ID: "duolingo", ImageURL: server.URL + "/page.html"}},
+		{Campaign: models.Campaign{ID: "text-only"}},
+	}
+	gauge := &brokenGauge{}
+	checker := NewChecker(testValidator(server, ""), campaigns, gauge, log.NewNopLogger())
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	checker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.NoError(t, checker.Check(ctx))
+	broken := checker.Broken()
+	require.Len(t, broken, 2)
+	assert.Equal(t, "duolingo", broken[0].CID)
+	assert.Equal(t, "netflix", broken[1].CID)
+	assert.Contains(t, broken[1].Error, "img answered 404")
+	assert.Equal(t, 2, gauge.count)
+
+	// Images still broken keep the time they broke, fixed images are forgotten
+	now = now.Add(time.Hour)
+	campaigns[1].ImageURL = server.URL + "/banner.png"
+	require.NoError(t, checker.Check(ctx))
+	broken = checker.Broken()
+	require.Len(t, broken, 1)
+	assert.Equal(t, "duolingo", broken[0].CID)
+	assert.Equal(t, now.Add(-time.Hour), broken[0].Since)
+	assert.Equal(t, now, broken[0].CheckedAt)
+	assert.Equal(t, 1, gauge.count)
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -23,10 +26,46 @@ type DB struct {
 	*sql.DB
 }
 
+// buildDSN builds a lib/pq connection string for the given database
+// dsnValueEscaper escapes a value for use inside single quotes in a lib/pq DSN
+var dsnValueEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func buildDSN(cfg config.DatabaseConfig, dbName string) string {
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.User, cfg.Password, dbName, cfg.SSLMode)
+
+	// Transaction-pooling PgBouncer may hand each statement to a different server
+	// connection, so the unnamed prepared statement created by the separate
+	// Parse/Bind round trips can disappear between them. binary_parameters makes
+	// lib/pq send parse, bind and execute in a single round trip instead.
+	if cfg.PgBouncerMode {
+		dsn += " binary_parameters=yes"
+	}
+
+	// Identifies our connections in pg_stat_activity and the server logs
+	if cfg.ApplicationName != "" {
+		dsn += fmt.Sprintf(" application_name='%s'", dsnValueEscaper.Replace(cfg.ApplicationName))
+	}
+
+	return dsn
+}
+
+// directConfig returns a copy of cfg that bypasses the pooler.
+// Migrations use session-level advisory locks which don't survive transaction pooling.
+func directConfig(cfg config.DatabaseConfig) config.DatabaseConfig {
+	if cfg.DirectHost != "" {
+		cfg.Host = cfg.DirectHost
+	}
+	if cfg.DirectPort != 0 {
+		cfg.Port = cfg.DirectPort
+	}
+	cfg.PgBouncerMode = false
+	return cfg
+}
+
 // NewConnection creates a new database connection with connection pooling
 func NewConnection(cfg config.DatabaseConfig) (*DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
+	dsn := buildDSN(cfg, cfg.DBName)
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -38,6 +77,11 @@ func NewConnection(cfg config.DatabaseConfig) (*DB, error) {
 	db.SetMaxIdleConns(cfg.MaxIdleConns)
 	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Minute)
 	db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime) * time.Minute)
+	if cfg.PgBouncerMode {
+		// PgBouncer owns server connections, keeping client connections around
+		// only holds on to pooler slots without any benefit
+		db.SetMaxIdleConns(min(cfg.MaxIdleConns, cfg.MaxOpenConns/2))
+	}
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
@@ -93,10 +137,22 @@ func (db *DB) Close() error {
 	return db.DB.Close()
 }
 
+// ConfirmMigrations checks that migrations were applied and none was left half-applied
+func ConfirmMigrations(db *DB, cfg config.DatabaseConfig, migrationsPath string, logger log.Logger) error {
+	version, dirty, err := NewMigrationManager(db, migrationsPath, directConfig(cfg), logger).Version()
+	if err != nil {
+		return fmt.Errorf("failed to read migration version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	return nil
+}
+
 // Initialize sets up the complete database with connection, migrations, and returns cleanup function
-func Initialize(cfg config.DatabaseConfig, migrationsPath string) (*DB, func(), error) {
-	// Ensure database exists
-	if err := EnsureDatabase(cfg); err != nil {
+func Initialize(cfg config.DatabaseConfig, migrationsPath string, logger log.Logger) (*DB, func(), error) {
+	// Ensure database exists, always over a direct connection
+	if err := EnsureDatabase(directConfig(cfg), logger); err != nil {
 		return nil, nil, fmt.Errorf("failed to ensure database exists: %w", err)
 	}
 
@@ -107,7 +163,7 @@ func Initialize(cfg config.DatabaseConfig, migrationsPath string) (*DB, func(),
 	}
 
 	// Run migrations
-	migrationManager := NewMigrationManager(db, migrationsPath)
+	migrationManager := NewMigrationManager(db, migrationsPath, directConfig(cfg), logger)
 	if err := migrationManager.Up(); err != nil {
 		db.Close()
 		return nil, nil, fmt.Errorf("failed to run migrations: %w", err)
@@ -116,9 +172,7 @@ func Initialize(cfg config.DatabaseConfig, migrationsPath string) (*DB, func(),
 	// Create cleanup function
 	cleanup := func() {
 		if err := db.Close(); err != nil {
-			// Note: Using fmt.Printf instead of log to avoid importing log package
-			// The caller can handle logging as needed
-			fmt.Printf("Error closing database connection: %v\n", err)
+			level.Error(logger).Log("msg", "error closing database connection", "err", err)
 		}
 	}
 
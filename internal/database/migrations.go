@@ -1,11 +1,13 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"path/filepath"
 
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -17,16 +19,30 @@ import (
 type MigrationManager struct {
 	db            *DB
 	migrationsDir string
+	cfg           config.DatabaseConfig
+	logger        log.Logger
 }
 
-// NewMigrationManager creates a new migration manager
-func NewMigrationManager(db *DB, migrationsDir string) *MigrationManager {
+// NewMigrationManager creates a new migration manager that connects using the given config
+func NewMigrationManager(db *DB, migrationsDir string, cfg config.DatabaseConfig, logger log.Logger) *MigrationManager {
 	return &MigrationManager{
 		db:            db,
 		migrationsDir: migrationsDir,
+		cfg:           cfg,
+		logger:        logger,
 	}
 }
 
+// OpenMigrationManager creates a migration manager for running migrations on their own,
+// creating the database if it doesn't exist. Like Initialize it bypasses the pooler.
+func OpenMigrationManager(cfg config.DatabaseConfig, migrationsDir string, logger log.Logger) (*MigrationManager, error) {
+	cfg = directConfig(cfg)
+	if err := EnsureDatabase(cfg, logger); err != nil {
+		return nil, fmt.Errorf("failed to ensure database exists: %w", err)
+	}
+	return NewMigrationManager(nil, migrationsDir, cfg, logger), nil
+}
+
 // Up runs all up migrations
 func (m *MigrationManager) Up() error {
 	migration, err := m.createMigrationInstance()
@@ -39,7 +55,7 @@ func (m *MigrationManager) Up() error {
 		return fmt.Errorf("failed to run up migrations: %w", err)
 	}
 
-	log.Println("Database migrations completed successfully")
+	level.Info(m.logger).Log("msg", "database migrations completed successfully")
 	return nil
 }
 
@@ -55,13 +71,13 @@ func (m *MigrationManager) Down() error {
 		return fmt.Errorf("failed to run down migrations: %w", err)
 	}
 
-	log.Println("Database down migrations completed successfully")
+	level.Info(m.logger).Log("msg", "database down migrations completed successfully")
 	return nil
 }
 
 // Reset drops all tables and re-runs migrations
 func (m *MigrationManager) Reset() error {
-	log.Println("Resetting database...")
+	level.Info(m.logger).Log("msg", "resetting database")
 
 	if err := m.Down(); err != nil {
 		return fmt.Errorf("failed to run down migrations during reset: %w", err)
@@ -71,7 +87,7 @@ func (m *MigrationManager) Reset() error {
 		return fmt.Errorf("failed to run up migrations during reset: %w", err)
 	}
 
-	log.Println("Database reset completed successfully")
+	level.Info(m.logger).Log("msg", "database reset completed successfully")
 	return nil
 }
 
@@ -100,9 +116,8 @@ func (m *MigrationManager) Force(version int) error {
 // createMigrationInstance creates a new migration instance
 func (m *MigrationManager) createMigrationInstance() (*migrate.Migrate, error) {
 	// Create a separate connection for migrations to avoid closing the main connection
-	cfg := config.AppConfigInstance.DatabaseConfig
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
+	cfg := m.cfg
+	dsn := buildDSN(cfg, cfg.DBName)
 
 	migrationDB, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -132,10 +147,9 @@ func (m *MigrationManager) createMigrationInstance() (*migrate.Migrate, error) {
 }
 
 // EnsureDatabase creates the database if it doesn't exist
-func EnsureDatabase(cfg config.DatabaseConfig) error {
+func EnsureDatabase(cfg config.DatabaseConfig, logger log.Logger) error {
 	// Connect to postgres database to create the target database
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=postgres sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.SSLMode)
+	dsn := buildDSN(cfg, "postgres")
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -152,15 +166,26 @@ func EnsureDatabase(cfg config.DatabaseConfig) error {
 	}
 
 	if !exists {
-		log.Printf("Creating database: %s", cfg.DBName)
+		level.Info(logger).Log("msg", "creating database", "database", cfg.DBName)
 		_, err = db.Exec(fmt.Sprintf("CREATE DATABASE %s", cfg.DBName))
 		if err != nil {
 			return fmt.Errorf("failed to create database: %w", err)
 		}
-		log.Printf("Database %s created successfully", cfg.DBName)
+		level.Info(logger).Log("msg", "database created successfully", "database", cfg.DBName)
 	} else {
-		log.Printf("Database %s already exists", cfg.DBName)
+		level.Debug(logger).Log("msg", "database already exists", "database", cfg.DBName)
 	}
 
 	return nil
 }
+
+// MigrationState returns the version of the last applied migration and whether it was left
+// half-applied, reading the migrate table over the pool so it's cheap enough for health checks
+func (db *DB) MigrationState(ctx context.Context) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	if err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty); err != nil {
+		return 0, false, fmt.Errorf("failed to read migration state: %w", err)
+	}
+	return uint(version), dirty, nil
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+)
+
+// maxCommentValueLength bounds client supplied values (X-Request-ID) embedded in queries
+const maxCommentValueLength = 128
+
+// AnnotateQuery appends a sqlcommenter style comment carrying the request and trace IDs
+// from ctx, so statements in Postgres logs and pg_stat_activity can be tied back to API
+// requests. Queries are returned unchanged when ctx carries neither ID.
+func AnnotateQuery(ctx context.Context, query string) string {
+	var tags []string
+	if requestID := reqcontext.GetRequestID(ctx); requestID != "" {
+		tags = append(tags, "request_id='"+commentValue(requestID)+"'")
+	}
+	if traceID := reqcontext.GetTraceID(ctx); traceID != "" {
+		tags = append(tags, "trace_id='"+commentValue(traceID)+"'")
+	}
+
+	if len(tags) == 0 {
+		return query
+	}
+	return query + " /*" + strings.Join(tags, ",") + "*/"
+}
+
+// commentValue URL-encodes a value so it can't terminate the comment or the quoted string
+func commentValue(value string) string {
+	if len(value) > maxCommentValueLength {
+		value = value[:maxCommentValueLength]
+	}
+	return url.QueryEscape(value)
+}
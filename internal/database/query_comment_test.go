@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotateQuery(t *testing.T) {
+	query := "SELECT 1"
+
+	assert.Equal(t, query, AnnotateQuery(context.Background(), query))
+
+	ctx := reqcontext.WithRequestID(context.Background(), "req-123")
+	ctx = reqcontext.WithTraceID(ctx, "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t,
+		"SELECT 1 /*request_id='req-123',trace_id='4bf92f3577b34da6a3ce929d0e0e4736'*/",
+		AnnotateQuery(ctx, query))
+
+	// Client supplied request IDs must not be able to break out of the comment
+	ctx = reqcontext.WithRequestID(context.Background(), "x'*/; DROP TABLE campaigns; --")
+	annotated := AnnotateQuery(ctx, query)
+	assert.Equal(t, 1, strings.Count(annotated, "*/"))
+	assert.NotContains(t, annotated, "x'")
+}
@@ -0,0 +1,165 @@
+package decisionlog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// Outcomes of decision records, used as metric labels
+const (
+	OutcomeWritten = "written"
+	// OutcomeDropped records were sampled but the queue was full or the log closed
+	OutcomeDropped = "dropped"
+	// OutcomeFailed records could not be written to the log file
+	OutcomeFailed = "failed"
+)
+
+// Record is one line of the decision log
+type Record struct {
+	Time        time.Time `json:"time"`
+	RequestID   string    `json:"request_id,omitempty"`
+	ClientID    string    `json:"client_id,omitempty"`
+	Country     string    `json:"country"`
+	OS          string    `json:"os"`
+	App         string    `json:"app"`
+	State       string    `json:"state,omitempty"`
+	RequestTime time.Time `json:"request_time"` // time targeting was evaluated at
+	Source      string    `json:"source"`
+	Candidates  int       `json:"candidates"`
+	Matched     int       `json:"matched"`
+	CampaignIDs []string  `json:"campaign_ids"`
+	LookupUS    int64     `json:"lookup_us"`
+	MatchUS     int64     `json:"match_us"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Recorder counts decision records by outcome, metrics.CachedMetrics implements it
+type Recorder interface {
+	RecordDecisionLog(outcome string)
+}
+
+// Config holds the sampling and queueing settings of a Logger
+type Config struct {
+	// SampleRate is the fraction (0-1) of decisions logged, failed requests are always logged
+	SampleRate float64
+	// QueueSize bounds the records waiting to be written, further records are dropped
+	QueueSize int
+}
+
+// Logger writes sampled delivery decisions as JSON lines from a single goroutine, so
+// logging never adds disk latency to the request path. It implements service.DecisionRecorder.
+type Logger struct {
+	out        io.WriteCloser
+	sampleRate float64
+	recorder   Recorder
+	records    chan Record
+	wg         sync.WaitGroup
+	mu         sync.RWMutex
+	closed     bool
+}
+
+// NewLogger creates a decision logger writing to out, e.g. a RotatingFile
+func NewLogger(out io.WriteCloser, config Config, recorder Recorder) *Logger {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1000
+	}
+
+	l := &Logger{
+		out:        out,
+		sampleRate: config.SampleRate,
+		recorder:   recorder,
+		records:    make(chan Record, config.QueueSize),
+	}
+
+	l.wg.Add(1)
+	go l.run()
+
+	return l
+}
+
+// RecordDecision queues a record of decision if it is sampled, dropping it if the queue is full
+func (l *Logger) RecordDecision(ctx context.Context, decision service.Decision) {
+	if decision.Err == nil && rand.Float64() >= l.sampleRate {
+		return
+	}
+
+	record := Record{
+		Time:        time.Now().UTC(),
+		RequestID:   reqcontext.GetRequestID(ctx),
+		ClientID:    reqcontext.GetClientID(ctx),
+		Country:     decision.Request.Country,
+		OS:          decision.Request.OS,
+		App:         decision.Request.App,
+		State:       decision.Request.State,
+		RequestTime: decision.Request.Time,
+		Source:      decision.Source,
+		Candidates:  decision.Candidates,
+		Matched:     len(decision.CampaignIDs),
+		CampaignIDs: decision.CampaignIDs,
+		LookupUS:    decision.LookupDuration.Microseconds(),
+		MatchUS:     decision.MatchDuration.Microseconds(),
+	}
+	if record.CampaignIDs == nil {
+		record.CampaignIDs = []string{}
+	}
+	if decision.Err != nil {
+		record.Error = decision.Err.Error()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		l.record(OutcomeDropped)
+		return
+	}
+
+	select {
+	case l.records <- record:
+	default:
+		// Queue full, drop rather than block the caller
+		l.record(OutcomeDropped)
+	}
+}
+
+// run writes queued records until the logger is closed
+func (l *Logger) run() {
+	defer l.wg.Done()
+
+	for record := range l.records {
+		line, err := json.Marshal(record)
+		if err == nil {
+			_, err = l.out.Write(append(line, '\n'))
+		}
+		if err != nil {
+			l.record(OutcomeFailed)
+			continue
+		}
+		l.record(OutcomeWritten)
+	}
+}
+
+func (l *Logger) record(outcome string) {
+	if l.recorder != nil {
+		l.recorder.RecordDecisionLog(outcome)
+	}
+}
+
+// Close stops accepting records, writes the queued ones and closes the output
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.records)
+	}
+	l.mu.Unlock()
+
+	l.wg.Wait()
+	return l.out.Close()
+}
@@ -0,0 +1,142 @@
+package decisionlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bufferOutput collects written records, failing writes while fail is set
+type bufferOutput struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	fail   bool
+	closed bool
+}
+
+func (o *bufferOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.fail {
+		return 0, errors.New("disk full")
+	}
+	return o.buf.Write(p)
+}
+
+func (o *bufferOutput) Close() error {
+	o.closed = true
+	return nil
+}
+
+func (o *bufferOutput) records(t *testing.T) []Record {
+	t.Helper()
+	var records []Record
+	scanner := bufio.NewScanner(&o.buf)
+	for scanner.Scan() {
+		var record Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+// countingRecorder counts records by outcome
+type countingRecorder struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (r *countingRecorder) RecordDecisionLog(outcome string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = map[string]int{}
+	}
+	r.counts[outcome]++
+}
+
+func testDecision() service.Decision {
+	return service.Decision{
+		Request: models.DeliveryRequest{
+			Country: "us",
+			OS:      "android",
+			App:     "com.spotify",
+			Time:    time.Date(2025, 1, 1, 21, 30, 0, 0, time.UTC),
+		},
+		Source:         service.MatchSourceIndex,
+		Candidates:     3,
+		CampaignIDs:    []string{"spotify", "subwaysurfer"},
+		LookupDuration: 1500 * time.Microsecond,
+		MatchDuration:  20 * time.Microsecond,
+	}
+}
+
+func TestLogger_RecordDecision(t *testing.T) {
+	out := &bufferOutput{}
+	recorder := &countingRecorder{}
+	logger := NewLogger(out, Config{SampleRate: 1}, recorder)
+
+	ctx := reqcontext.WithRequestID(context.Background(), "req-1")
+	ctx = reqcontext.WithClientID(ctx, "publisher-a")
+	logger.RecordDecision(ctx, testDecision())
+	require.NoError(t, logger.Close())
+	assert.True(t, out.closed)
+
+	records := out.records(t)
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.Equal(t, "req-1", record.RequestID)
+	assert.Equal(t, "publisher-a", record.ClientID)
+	assert.Equal(t, "us", record.Country)
+	assert.Equal(t, service.MatchSourceIndex, record.Source)
+	assert.Equal(t, 3, record.Candidates)
+	assert.Equal(t, 2, record.Matched)
+	assert.Equal(t, []string{"spotify", "subwaysurfer"}, record.CampaignIDs)
+	assert.Equal(t, int64(1500), record.LookupUS)
+	assert.Equal(t, int64(20), record.MatchUS)
+	assert.Equal(t, time.Date(2025, 1, 1, 21, 30, 0, 0, time.UTC), record.RequestTime)
+	assert.Empty(t, record.Error)
+	assert.Equal(t, 1, recorder.counts[OutcomeWritten])
+}
+
+func TestLogger_Sampling(t *testing.T) {
+	out := &bufferOutput{}
+	logger := NewLogger(out, Config{SampleRate: 0}, nil)
+
+	logger.RecordDecision(context.Background(), testDecision())
+
+	// Failed requests are logged regardless of sampling, without matches
+	failed := service.Decision{Request: testDecision().Request, Source: service.MatchSourceIndex, Err: service.ErrRetrieveCampaigns}
+	logger.RecordDecision(context.Background(), failed)
+	require.NoError(t, logger.Close())
+
+	records := out.records(t)
+	require.Len(t, records, 1)
+	assert.Equal(t, service.ErrRetrieveCampaigns.Error(), records[0].Error)
+	assert.Equal(t, []string{}, records[0].CampaignIDs)
+}
+
+func TestLogger_WriteFailure(t *testing.T) {
+	out := &bufferOutput{fail: true}
+	recorder := &countingRecorder{}
+	logger := NewLogger(out, Config{SampleRate: 1}, recorder)
+
+	logger.RecordDecision(context.Background(), testDecision())
+	require.NoError(t, logger.Close())
+	assert.Equal(t, 1, recorder.counts[OutcomeFailed])
+
+	// Decisions after close are dropped
+	logger.RecordDecision(context.Background(), testDecision())
+	assert.Equal(t, 1, recorder.counts[OutcomeDropped])
+}
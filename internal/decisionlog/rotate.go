@@ -0,0 +1,178 @@
+package decisionlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat names rotated files, it sorts chronologically
+const rotatedTimeFormat = "20060102T150405.000"
+
+// RotateConfig holds when a RotatingFile is rotated and how many rotated files are kept
+type RotateConfig struct {
+	// MaxBytes rotates the file before it grows beyond this size, 0 disables size rotation
+	MaxBytes int64
+	// MaxAge rotates the file once it has been written to for this long, 0 disables age rotation
+	MaxAge time.Duration
+	// MaxFiles is the number of rotated files kept, older ones are removed, 0 keeps all
+	MaxFiles int
+	// OnRotate is called with the path of each rotated file, e.g. to ship it
+	OnRotate func(path string)
+}
+
+// RotatingFile is a file that is renamed with a timestamp suffix once it grows too large or
+// too old, writing continues in a new file at the original path
+type RotatingFile struct {
+	path   string
+	config RotateConfig
+	now    func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// NewRotatingFile opens the file at path for appending, creating it and its directory
+func NewRotatingFile(path string, config RotateConfig) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create decision log directory: %w", err)
+	}
+
+	f := &RotatingFile{path: path, config: config, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p, rotating first if p would make the file too large or the file is too old
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.shouldRotate(len(p)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close rotates a non-empty file, so its records are shipped, and closes it
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	if f.size == 0 {
+		err := f.file.Close()
+		f.file = nil
+		return err
+	}
+	rotated, err := f.closeAndRename()
+	f.file = nil
+	if err != nil {
+		return err
+	}
+	f.rotated(rotated)
+	return nil
+}
+
+func (f *RotatingFile) shouldRotate(size int) bool {
+	if f.config.MaxBytes > 0 && f.size+int64(size) > f.config.MaxBytes {
+		return true
+	}
+	return f.config.MaxAge > 0 && f.now().Sub(f.opened) >= f.config.MaxAge
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open decision log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open decision log: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+	return nil
+}
+
+// rotate renames the current file and opens a new one at the original path
+func (f *RotatingFile) rotate() error {
+	rotated, err := f.closeAndRename()
+	if err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		f.file = nil
+		return err
+	}
+	f.rotated(rotated)
+	return nil
+}
+
+// closeAndRename closes the current file and renames it, returning the new name
+func (f *RotatingFile) closeAndRename() (string, error) {
+	if err := f.file.Close(); err != nil {
+		return "", fmt.Errorf("failed to close decision log: %w", err)
+	}
+	rotated := f.rotatedPath(f.now())
+	if err := os.Rename(f.path, rotated); err != nil {
+		return "", fmt.Errorf("failed to rotate decision log: %w", err)
+	}
+	return rotated, nil
+}
+
+// rotated prunes old files and hands the rotated file to OnRotate
+func (f *RotatingFile) rotated(path string) {
+	f.prune()
+	if f.config.OnRotate != nil {
+		f.config.OnRotate(path)
+	}
+}
+
+// rotatedPath inserts the rotation time before the extension, decisions.jsonl becomes
+// decisions-20250101T120000.000.jsonl
+func (f *RotatingFile) rotatedPath(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-" + t.UTC().Format(rotatedTimeFormat) + ext
+}
+
+// rotatedFiles returns the rotated files of the log, oldest first
+func (f *RotatingFile) rotatedFiles() []string {
+	ext := filepath.Ext(f.path)
+	matches, _ := filepath.Glob(strings.TrimSuffix(f.path, ext) + "-*" + ext)
+	sort.Strings(matches)
+	return matches
+}
+
+// prune removes the oldest rotated files beyond MaxFiles
+func (f *RotatingFile) prune() {
+	if f.config.MaxFiles <= 0 {
+		return
+	}
+	rotated := f.rotatedFiles()
+	for len(rotated) > f.config.MaxFiles {
+		os.Remove(rotated[0])
+		rotated = rotated[1:]
+	}
+}
@@ -0,0 +1,114 @@
+package decisionlog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRotatingFile creates a rotating file in a temporary directory with a controllable clock
+func newTestRotatingFile(t *testing.T, config RotateConfig) (*RotatingFile, *time.Time) {
+	t.Helper()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	f, err := NewRotatingFile(filepath.Join(t.TempDir(), "logs", "decisions.jsonl"), config)
+	require.NoError(t, err)
+	f.now = func() time.Time { return now }
+	f.opened = now
+	t.Cleanup(func() { f.Close() })
+	return f, &now
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRotatingFile_MaxBytes(t *testing.T) {
+	var rotated []string
+	f, now := newTestRotatingFile(t, RotateConfig{MaxBytes: 11, OnRotate: func(path string) { rotated = append(rotated, path) }})
+
+	_, err := f.Write([]byte("12345\n"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("1234\n"))
+	require.NoError(t, err)
+	assert.Empty(t, rotated)
+
+	// The next write would exceed the limit, the full file is rotated first
+	*now = now.Add(time.Second)
+	_, err = f.Write([]byte("abc\n"))
+	require.NoError(t, err)
+
+	require.Len(t, rotated, 1)
+	assert.Equal(t, filepath.Join(filepath.Dir(f.path), "decisions-20250101T120001.000.jsonl"), rotated[0])
+	assert.Equal(t, "12345\n1234\n", readFile(t, rotated[0]))
+	assert.Equal(t, "abc\n", readFile(t, f.path))
+}
+
+func TestRotatingFile_MaxAge(t *testing.T) {
+	f, now := newTestRotatingFile(t, RotateConfig{MaxAge: time.Hour})
+
+	_, err := f.Write([]byte("first\n"))
+	require.NoError(t, err)
+	*now = now.Add(time.Hour)
+	_, err = f.Write([]byte("second\n"))
+	require.NoError(t, err)
+
+	assert.Len(t, f.rotatedFiles(), 1)
+	assert.Equal(t, "second\n", readFile(t, f.path))
+}
+
+func TestRotatingFile_MaxFiles(t *testing.T) {
+	f, now := newTestRotatingFile(t, RotateConfig{MaxBytes: 1, MaxFiles: 2})
+
+	for _, line := range []string{"a", "b", "c", "d"} {
+		*now = now.Add(time.Second)
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	// Three files were rotated, only the newest two are kept
+	rotated := f.rotatedFiles()
+	require.Len(t, rotated, 2)
+	assert.Equal(t, "b", readFile(t, rotated[0]))
+	assert.Equal(t, "c", readFile(t, rotated[1]))
+}
+
+func TestRotatingFile_Close(t *testing.T) {
+	var rotated []string
+	f, _ := newTestRotatingFile(t, RotateConfig{OnRotate: func(path string) { rotated = append(rotated, path) }})
+
+	_, err := f.Write([]byte("pending\n"))
+	require.NoError(t, err)
+
+	// Closing rotates what was written, so it is shipped
+	require.NoError(t, f.Close())
+	require.Len(t, rotated, 1)
+	assert.Equal(t, "pending\n", readFile(t, rotated[0]))
+	assert.NoFileExists(t, f.path)
+
+	_, err = f.Write([]byte("late\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+	assert.NoError(t, f.Close())
+}
+
+func TestRotatingFile_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("existing\n"), 0o644))
+
+	// An existing file is appended to and counts towards the size limit
+	f, err := NewRotatingFile(path, RotateConfig{MaxBytes: 12})
+	require.NoError(t, err)
+	_, err = f.Write([]byte("new\n"))
+	require.NoError(t, err)
+
+	assert.Len(t, f.rotatedFiles(), 1)
+	assert.Equal(t, "new\n", readFile(t, path))
+	require.NoError(t, f.Close())
+}
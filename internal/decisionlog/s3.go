@@ -0,0 +1,143 @@
+package decisionlog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// S3Client is the part of the S3 API the shipper uses, *s3.Client implements it
+type S3Client interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// NewS3Client creates an S3 client with the default AWS credential chain. A non-empty
+// endpoint targets an S3 compatible store such as MinIO, addressed by path.
+func NewS3Client(ctx context.Context, region, endpoint string) (*s3.Client, error) {
+	var options []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		options = append(options, awsconfig.WithRegion(region))
+	}
+	awsConfig, err := awsconfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws configuration: %w", err)
+	}
+
+	return s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	}), nil
+}
+
+// S3Shipper uploads rotated decision log files to S3 in the background, keyed by
+// prefix/host/file so replicas never overwrite each other's files
+type S3Shipper struct {
+	client  S3Client
+	bucket  string
+	prefix  string
+	host    string
+	timeout time.Duration
+	logger  kitlog.Logger
+	files   chan string
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// NewS3Shipper creates a shipper uploading to bucket under prefix
+func NewS3Shipper(client S3Client, bucket, prefix string, logger kitlog.Logger) *S3Shipper {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "unknown"
+	}
+
+	s := &S3Shipper{
+		client:  client,
+		bucket:  bucket,
+		prefix:  strings.Trim(prefix, "/"),
+		host:    host,
+		timeout: time.Minute,
+		logger:  logger,
+		files:   make(chan string, 100),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+// Ship queues a rotated file for upload, it can be passed as RotateConfig.OnRotate
+func (s *S3Shipper) Ship(file string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.files <- file:
+	default:
+		level.Warn(s.logger).Log("msg", "decision log upload queue full, file not shipped", "file", file)
+	}
+}
+
+// run uploads queued files until the shipper is closed
+func (s *S3Shipper) run() {
+	defer s.wg.Done()
+
+	for file := range s.files {
+		if err := s.upload(file); err != nil {
+			level.Error(s.logger).Log("msg", "failed to ship decision log", "file", file, "bucket", s.bucket, "err", err)
+		}
+	}
+}
+
+// key returns the object key of a rotated file
+func (s *S3Shipper) key(file string) string {
+	return path.Join(s.prefix, s.host, filepath.Base(file))
+}
+
+func (s *S3Shipper) upload(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.key(file)),
+		Body:        f,
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	return err
+}
+
+// Close stops accepting files and waits for queued uploads
+func (s *S3Shipper) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.files)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
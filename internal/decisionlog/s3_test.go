@@ -0,0 +1,72 @@
+package decisionlog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	kitlog "github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 keeps uploaded objects in memory
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+	err     error
+}
+
+func (c *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = string(body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestS3Shipper_Ship(t *testing.T) {
+	client := &fakeS3{objects: map[string]string{}}
+	shipper := NewS3Shipper(client, "analytics", "/decisions/", kitlog.NewNopLogger())
+	shipper.host = "replica-1"
+
+	file := filepath.Join(t.TempDir(), "decisions-20250101T120000.000.jsonl")
+	require.NoError(t, os.WriteFile(file, []byte("{}\n"), 0o644))
+
+	shipper.Ship(file)
+	require.NoError(t, shipper.Close())
+
+	assert.Equal(t, map[string]string{
+		"analytics/decisions/replica-1/decisions-20250101T120000.000.jsonl": "{}\n",
+	}, client.objects)
+
+	// Files rotated after close are not shipped
+	shipper.Ship(file)
+	assert.Len(t, client.objects, 1)
+}
+
+func TestS3Shipper_UploadError(t *testing.T) {
+	client := &fakeS3{objects: map[string]string{}, err: errors.New("access denied")}
+	shipper := NewS3Shipper(client, "analytics", "decisions", kitlog.NewNopLogger())
+
+	file := filepath.Join(t.TempDir(), "decisions-20250101T120000.000.jsonl")
+	require.NoError(t, os.WriteFile(file, []byte("{}\n"), 0o644))
+	assert.Error(t, shipper.upload(file))
+
+	// Missing files are reported, not fatal
+	assert.Error(t, shipper.upload(filepath.Join(t.TempDir(), "missing.jsonl")))
+	require.NoError(t, shipper.Close())
+}
@@ -0,0 +1,37 @@
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Record is what is stored for a nonce: the fingerprint of the request that claimed it and,
+// once that request was answered, its response
+type Record struct {
+	Fingerprint string `json:"fingerprint"`
+	// Done is set once the response is stored, a retry arriving earlier finds the request in progress
+	Done        bool   `json:"done,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Store keeps the records of recent nonces for a window
+type Store interface {
+	// Claim stores a pending record for key unless one exists, returning the existing record and
+	// true when the nonce was already claimed
+	Claim(ctx context.Context, key string, fingerprint string, window time.Duration) (Record, bool, error)
+	// Complete stores the response of a claimed key, keeping the expiry of the claim
+	Complete(ctx context.Context, key string, record Record) error
+	// Release forgets key, so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// Key returns the store key of a nonce sent with an API key, which may be empty. Nonces are
+// hashed so clients can't choose the store keys and nonces of different API keys can't collide.
+func Key(apiKey, nonce string) string {
+	sum := sha256.Sum256([]byte(apiKey + "\n" + nonce))
+	return hex.EncodeToString(sum[:16])
+}
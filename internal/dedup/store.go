@@ -0,0 +1,144 @@
+package dedup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStore keeps the nonce records in Redis, shared by all replicas. Claims use SET NX, so
+// of concurrent requests with the same nonce only one is served.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisStore creates a store keeping the records in client under keyPrefix
+func NewRedisStore(client redis.Cmdable, keyPrefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: keyPrefix + "nonce:"}
+}
+
+// nonceKey returns the Redis key of the record of key
+func (s *RedisStore) nonceKey(key string) string {
+	return s.prefix + key
+}
+
+// Claim implements Store. A claim expiring between the SET NX and the GET is claimed again.
+func (s *RedisStore) Claim(ctx context.Context, key string, fingerprint string, window time.Duration) (Record, bool, error) {
+	pending, err := json.Marshal(Record{Fingerprint: fingerprint})
+	if err != nil {
+		return Record{}, false, err
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		claimed, err := s.client.SetNX(ctx, s.nonceKey(key), pending, window).Result()
+		if err != nil {
+			return Record{}, false, fmt.Errorf("failed to claim nonce: %w", err)
+		}
+		if claimed {
+			return Record{}, false, nil
+		}
+
+		stored, err := s.client.Get(ctx, s.nonceKey(key)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, fmt.Errorf("failed to read nonce: %w", err)
+		}
+		var record Record
+		if err := json.Unmarshal(stored, &record); err != nil {
+			return Record{}, false, fmt.Errorf("failed to decode nonce record: %w", err)
+		}
+		return record, true, nil
+	}
+	return Record{}, false, errors.New("failed to claim nonce: claim keeps expiring")
+}
+
+// Complete implements Store, nothing is stored when the claim already expired
+func (s *RedisStore) Complete(ctx context.Context, key string, record Record) error {
+	record.Done = true
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := s.client.SetXX(ctx, s.nonceKey(key), encoded, redis.KeepTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store nonce response: %w", err)
+	}
+	return nil
+}
+
+// Release implements Store
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.nonceKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to release nonce: %w", err)
+	}
+	return nil
+}
+
+// memoryRecord is a record of the MemoryStore with its expiry
+type memoryRecord struct {
+	Record
+	expires time.Time
+}
+
+// MemoryStore keeps the nonce records in memory, each replica only deduplicates the requests it
+// receives. Expired records are swept at most once per window.
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]*memoryRecord
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*memoryRecord), now: time.Now}
+}
+
+// Claim implements Store
+func (s *MemoryStore) Claim(_ context.Context, key string, fingerprint string, window time.Duration) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !now.Before(s.nextSweep) {
+		for k, record := range s.records {
+			if !now.Before(record.expires) {
+				delete(s.records, k)
+			}
+		}
+		s.nextSweep = now.Add(window)
+	}
+
+	if record, ok := s.records[key]; ok && now.Before(record.expires) {
+		return record.Record, true, nil
+	}
+	s.records[key] = &memoryRecord{Record: Record{Fingerprint: fingerprint}, expires: now.Add(window)}
+	return Record{}, false, nil
+}
+
+// Complete implements Store
+func (s *MemoryStore) Complete(_ context.Context, key string, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.records[key]; ok {
+		record.Done = true
+		stored.Record = record
+	}
+	return nil
+}
+
+// Release implements Store
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,73 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStore runs the behavior shared by the stores, expire moves time past the window
+func testStore(t *testing.T, store Store, expire func(time.Duration)) {
+	ctx := context.Background()
+	key := Key("api-key", "nonce-1")
+
+	_, claimed, err := store.Claim(ctx, key, "fp", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// A retry while the first request is served finds it in progress
+	record, claimed, err := store.Claim(ctx, key, "fp", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, Record{Fingerprint: "fp"}, record)
+
+	require.NoError(t, store.Complete(ctx, key, Record{Fingerprint: "fp", Status: 200, ContentType: "application/json", Body: []byte(`[]`)}))
+	record, claimed, err = store.Claim(ctx, key, "other", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, Record{Fingerprint: "fp", Done: true, Status: 200, ContentType: "application/json", Body: []byte(`[]`)}, record)
+
+	// Released nonces can be claimed again
+	require.NoError(t, store.Release(ctx, key))
+	_, claimed, err = store.Claim(ctx, key, "fp", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Nonces are forgotten after the window
+	expire(time.Minute)
+	_, claimed, err = store.Claim(ctx, key, "fp", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Other API keys don't share nonces
+	_, claimed, err = store.Claim(ctx, Key("other-key", "nonce-1"), "fp", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	testStore(t, NewRedisStore(client, "adbeacon:"), server.FastForward)
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	testStore(t, store, func(d time.Duration) { now = now.Add(d) })
+
+	// Expired records are swept by later claims
+	now = now.Add(time.Minute)
+	_, _, err := store.Claim(context.Background(), Key("api-key", "nonce-2"), "fp", time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, store.records, 1)
+}
@@ -2,6 +2,7 @@ package endpoint
 
 import (
 	"context"
+	"sync"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
@@ -13,47 +14,110 @@ type DeliveryEndpoints struct {
 	GetCampaignsEndpoint endpoint.Endpoint
 }
 
-// MakeDeliveryEndpoints creates endpoints for delivery service
-func MakeDeliveryEndpoints(s service.CampaignDeliveryService) DeliveryEndpoints {
+// MakeDeliveryEndpoints creates endpoints for delivery service wrapped in the given
+// middlewares, the first middleware is the outermost
+func MakeDeliveryEndpoints(s service.CampaignDeliveryService, middlewares ...endpoint.Middleware) DeliveryEndpoints {
+	getCampaignsEndpoint := makeGetCampaignsEndpoint(s)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		getCampaignsEndpoint = middlewares[i](getCampaignsEndpoint)
+	}
+
 	return DeliveryEndpoints{
-		GetCampaignsEndpoint: makeGetCampaignsEndpoint(s),
+		GetCampaignsEndpoint: getCampaignsEndpoint,
 	}
 }
 
+// Requests and responses pass through the endpoint chain as pointers taken from pools, boxing
+// them as values allocates at every endpoint. The caller of an endpoint owns both: endpoints
+// don't keep them after returning and the caller releases them once it's done with them.
+var (
+	getCampaignsRequests  = sync.Pool{New: func() any { return new(GetCampaignsRequest) }}
+	getCampaignsResponses = sync.Pool{New: func() any { return new(GetCampaignsResponse) }}
+)
+
 // GetCampaignsRequest represents the request for getting campaigns
 type GetCampaignsRequest struct {
 	DeliveryRequest models.DeliveryRequest
+
+	// pooled marks requests from NewGetCampaignsRequest, only those are released
+	pooled bool
+}
+
+// NewGetCampaignsRequest returns a pooled request for req, Release returns it to the pool
+func NewGetCampaignsRequest(req models.DeliveryRequest) *GetCampaignsRequest {
+	r := getCampaignsRequests.Get().(*GetCampaignsRequest)
+	r.DeliveryRequest = req
+	r.pooled = true
+	return r
+}
+
+// Release returns a pooled request to the pool, the request must not be used afterwards.
+// Requests that weren't taken from the pool are left alone.
+func (r *GetCampaignsRequest) Release() {
+	if r == nil || !r.pooled {
+		return
+	}
+	*r = GetCampaignsRequest{}
+	getCampaignsRequests.Put(r)
 }
 
 // GetCampaignsResponse represents the response for getting campaigns
 type GetCampaignsResponse struct {
 	Campaigns []models.CampaignResponse `json:"campaigns,omitempty"`
 	Err       error                     `json:"error,omitempty"`
+	// Slots is the number of ad slots the request asked to fill, 0 when it asked for every
+	// matching campaign
+	Slots int `json:"slots,omitempty"`
+
+	// pooled marks responses from newGetCampaignsResponse, only those are released
+	pooled bool
+}
+
+// newGetCampaignsResponse returns a pooled response, Release returns it to the pool
+func newGetCampaignsResponse(campaigns []models.CampaignResponse, err error) *GetCampaignsResponse {
+	r := getCampaignsResponses.Get().(*GetCampaignsResponse)
+	r.Campaigns = campaigns
+	r.Err = err
+	r.pooled = true
+	return r
+}
+
+// Release returns a pooled response to the pool, the response must not be used afterwards.
+// Responses that weren't taken from the pool, such as a shared timeout fallback, are left alone.
+func (r *GetCampaignsResponse) Release() {
+	if r == nil || !r.pooled {
+		return
+	}
+	*r = GetCampaignsResponse{}
+	getCampaignsResponses.Put(r)
 }
 
 // Failed implements the endpoint.Failer interface
-func (r GetCampaignsResponse) Failed() error {
+func (r *GetCampaignsResponse) Failed() error {
 	return r.Err
 }
 
 // makeGetCampaignsEndpoint creates the endpoint for getting campaigns
 func makeGetCampaignsEndpoint(s service.CampaignDeliveryService) endpoint.Endpoint {
 	return func(ctx context.Context, request any) (any, error) {
-		req := request.(GetCampaignsRequest)
+		req := request.(*GetCampaignsRequest)
 		campaigns, err := s.GetCampaigns(ctx, req.DeliveryRequest)
-		return GetCampaignsResponse{
-			Campaigns: campaigns,
-			Err:       err,
-		}, nil
+		response := newGetCampaignsResponse(campaigns, err)
+		response.Slots = req.DeliveryRequest.Slots
+		return response, nil
 	}
 }
 
 // GetCampaigns is a helper method to call the endpoint
 func (e DeliveryEndpoints) GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
-	response, err := e.GetCampaignsEndpoint(ctx, GetCampaignsRequest{DeliveryRequest: req})
+	request := NewGetCampaignsRequest(req)
+	defer request.Release()
+
+	response, err := e.GetCampaignsEndpoint(ctx, request)
 	if err != nil {
 		return nil, err
 	}
-	resp := response.(GetCampaignsResponse)
+	resp := response.(*GetCampaignsResponse)
+	defer resp.Release()
 	return resp.Campaigns, resp.Err
 }
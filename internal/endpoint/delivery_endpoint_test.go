@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -43,7 +44,7 @@ func TestGetCampaignsEndpoint_Success(t *testing.T) {
 	})).Return(expectedCampaigns, nil)
 
 	// Create request
-	request := GetCampaignsRequest{
+	request := &GetCampaignsRequest{
 		DeliveryRequest: models.DeliveryRequest{
 			App:     "com.test.app",
 			Country: "US",
@@ -55,9 +56,9 @@ func TestGetCampaignsEndpoint_Success(t *testing.T) {
 	response, err := endpoints.GetCampaignsEndpoint(context.Background(), request)
 
 	assert.NoError(t, err)
-	assert.IsType(t, GetCampaignsResponse{}, response)
+	assert.IsType(t, &GetCampaignsResponse{}, response)
 
-	getCampaignsResponse := response.(GetCampaignsResponse)
+	getCampaignsResponse := response.(*GetCampaignsResponse)
 	assert.Equal(t, expectedCampaigns, getCampaignsResponse.Campaigns)
 	assert.Nil(t, getCampaignsResponse.Err)
 
@@ -71,7 +72,7 @@ func TestGetCampaignsEndpoint_NoResults(t *testing.T) {
 	// Setup mock to return empty results
 	mockService.On("GetCampaigns", mock.Anything, mock.Anything).Return([]models.CampaignResponse{}, nil)
 
-	request := GetCampaignsRequest{
+	request := &GetCampaignsRequest{
 		DeliveryRequest: models.DeliveryRequest{
 			App:     "com.test.app",
 			Country: "CA",
@@ -82,9 +83,9 @@ func TestGetCampaignsEndpoint_NoResults(t *testing.T) {
 	response, err := endpoints.GetCampaignsEndpoint(context.Background(), request)
 
 	assert.NoError(t, err)
-	assert.IsType(t, GetCampaignsResponse{}, response)
+	assert.IsType(t, &GetCampaignsResponse{}, response)
 
-	getCampaignsResponse := response.(GetCampaignsResponse)
+	getCampaignsResponse := response.(*GetCampaignsResponse)
 	assert.Empty(t, getCampaignsResponse.Campaigns)
 	assert.Nil(t, getCampaignsResponse.Err)
 
@@ -99,7 +100,7 @@ func TestGetCampaignsEndpoint_ServiceError(t *testing.T) {
 	serviceError := errors.New("service error")
 	mockService.On("GetCampaigns", mock.Anything, mock.Anything).Return([]models.CampaignResponse{}, serviceError)
 
-	request := GetCampaignsRequest{
+	request := &GetCampaignsRequest{
 		DeliveryRequest: models.DeliveryRequest{
 			App:     "com.test.app",
 			Country: "US",
@@ -110,9 +111,9 @@ func TestGetCampaignsEndpoint_ServiceError(t *testing.T) {
 	response, err := endpoints.GetCampaignsEndpoint(context.Background(), request)
 
 	assert.NoError(t, err) // Endpoint itself doesn't return error, error is in response
-	assert.IsType(t, GetCampaignsResponse{}, response)
+	assert.IsType(t, &GetCampaignsResponse{}, response)
 
-	getCampaignsResponse := response.(GetCampaignsResponse)
+	getCampaignsResponse := response.(*GetCampaignsResponse)
 	assert.Empty(t, getCampaignsResponse.Campaigns)
 	assert.Equal(t, serviceError, getCampaignsResponse.Err)
 
@@ -127,7 +128,7 @@ func TestGetCampaignsEndpoint_ValidationError(t *testing.T) {
 	validationError := errors.New("missing app param")
 	mockService.On("GetCampaigns", mock.Anything, mock.Anything).Return([]models.CampaignResponse{}, validationError)
 
-	request := GetCampaignsRequest{
+	request := &GetCampaignsRequest{
 		DeliveryRequest: models.DeliveryRequest{
 			App:     "",
 			Country: "US",
@@ -138,9 +139,9 @@ func TestGetCampaignsEndpoint_ValidationError(t *testing.T) {
 	response, err := endpoints.GetCampaignsEndpoint(context.Background(), request)
 
 	assert.NoError(t, err)
-	assert.IsType(t, GetCampaignsResponse{}, response)
+	assert.IsType(t, &GetCampaignsResponse{}, response)
 
-	getCampaignsResponse := response.(GetCampaignsResponse)
+	getCampaignsResponse := response.(*GetCampaignsResponse)
 	assert.Empty(t, getCampaignsResponse.Campaigns)
 	assert.Equal(t, validationError, getCampaignsResponse.Err)
 
@@ -178,7 +179,7 @@ func TestGetCampaignsEndpoint_ContextHandling(t *testing.T) {
 	// Create context with value
 	ctx := context.WithValue(context.Background(), "test-key", "test-value")
 
-	request := GetCampaignsRequest{
+	request := &GetCampaignsRequest{
 		DeliveryRequest: models.DeliveryRequest{
 			App:     "com.test.app",
 			Country: "US",
@@ -203,7 +204,7 @@ func TestGetCampaignsEndpoint_RequestTransformation(t *testing.T) {
 			req.OS == "Android"
 	})).Return([]models.CampaignResponse{}, nil)
 
-	request := GetCampaignsRequest{
+	request := &GetCampaignsRequest{
 		DeliveryRequest: models.DeliveryRequest{
 			App:     "com.test.app",
 			Country: "US",
@@ -216,3 +217,77 @@ func TestGetCampaignsEndpoint_RequestTransformation(t *testing.T) {
 	assert.NoError(t, err)
 	mockService.AssertExpectations(t)
 }
+
+func TestGetCampaignsRequestRelease(t *testing.T) {
+	request := NewGetCampaignsRequest(models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android"})
+	assert.Equal(t, "com.test.app", request.DeliveryRequest.App)
+
+	// Released requests are cleared before reuse
+	request.Release()
+	assert.Equal(t, models.DeliveryRequest{}, request.DeliveryRequest)
+
+	// Requests and responses that weren't pooled are left alone
+	literal := &GetCampaignsRequest{DeliveryRequest: models.DeliveryRequest{App: "com.test.app"}}
+	literal.Release()
+	assert.Equal(t, "com.test.app", literal.DeliveryRequest.App)
+
+	fallback := &GetCampaignsResponse{Campaigns: []models.CampaignResponse{{CID: "spotify"}}}
+	fallback.Release()
+	assert.Len(t, fallback.Campaigns, 1)
+
+	var nilResponse *GetCampaignsResponse
+	nilResponse.Release()
+}
+
+func TestGetCampaigns_PooledAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items at random under the race detector")
+	}
+	campaigns := []models.CampaignResponse{{CID: "spotify"}}
+	passthrough := func(next service.CampaignDeliveryService) service.CampaignDeliveryService { return next }
+	endpoints := MakeDeliveryEndpoints(
+		serviceFunc(func(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+			return campaigns, nil
+		}),
+		ServiceMiddleware(passthrough),
+		ServiceMiddleware(passthrough),
+	)
+	ctx := context.Background()
+	req := models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android"}
+
+	// Requests and responses crossing each endpoint come from the pools
+	allocs := testing.AllocsPerRun(100, func() {
+		endpoints.GetCampaigns(ctx, req)
+	})
+	assert.Zero(t, allocs)
+}
+
+// BenchmarkGetCampaignsEndpoint measures a delivery through a chain of service middlewares,
+// each of which passes a request and a response across an endpoint
+func BenchmarkGetCampaignsEndpoint(b *testing.B) {
+	campaigns := []models.CampaignResponse{
+		{CID: "spotify", Img: "https://example.com/spotify.jpg", CTA: "Download"},
+		{CID: "duolingo", Img: "https://example.com/duolingo.jpg", CTA: "Install"},
+	}
+	passthrough := func(next service.CampaignDeliveryService) service.CampaignDeliveryService { return next }
+	endpoints := MakeDeliveryEndpoints(
+		serviceFunc(func(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+			return campaigns, nil
+		}),
+		ValidationMiddleware(models.NewRequestValidator(models.DefaultValidationRules())),
+		ServiceMiddleware(passthrough),
+		ServiceMiddleware(passthrough),
+		ServiceMiddleware(passthrough),
+	)
+	ctx := context.Background()
+	req := models.DeliveryRequest{App: "com.test.app", Country: "us", OS: "android"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		request := NewGetCampaignsRequest(req)
+		response, _ := endpoints.GetCampaignsEndpoint(ctx, request)
+		response.(*GetCampaignsResponse).Release()
+		request.Release()
+	}
+}
@@ -0,0 +1,283 @@
+package endpoint
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/breaker"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// Names of the endpoint middlewares that can be chained from configuration
+const (
+	MiddlewareTimeout        = "timeout"
+	MiddlewareRateLimit      = "rate_limit"
+	MiddlewareCircuitBreaker = "circuit_breaker"
+	MiddlewareLogging        = "logging"
+	MiddlewareMetrics        = "metrics"
+	MiddlewareValidation     = "validation"
+	MiddlewareTracing        = "tracing"
+)
+
+var (
+	// ErrTimeout is returned when an endpoint exceeds its deadline budget
+	ErrTimeout = errors.New("request timed out")
+	// ErrRateLimited is returned when an endpoint is called faster than its rate limit
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+// ServiceMiddleware adapts a service middleware to an endpoint middleware, so logging and
+// metrics can be ordered in the same chain as the endpoint middlewares
+func ServiceMiddleware(mw func(service.CampaignDeliveryService) service.CampaignDeliveryService) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return makeGetCampaignsEndpoint(mw(DeliveryEndpoints{GetCampaignsEndpoint: next}))
+	}
+}
+
+// ValidationMiddleware rejects invalid delivery requests before they reach the service,
+// answering with a *models.ValidationError that lists every invalid field
+func ValidationMiddleware(validator *models.RequestValidator) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request any) (any, error) {
+			if req, ok := request.(*GetCampaignsRequest); ok {
+				if err := validator.Validate(req.DeliveryRequest); err != nil {
+					return newGetCampaignsResponse(nil, err), nil
+				}
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// TracingMiddleware runs each request in a span of its trace: the trace ID of an upstream
+// traceparent header is kept, or a new one started, and the request context carries a new span
+// ID, so database queries, metric exemplars and error reports are tagged with them. The span
+// is logged at debug level with its parent, the upstream span, and its duration.
+func TracingMiddleware(logger log.Logger, name string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request any) (response any, err error) {
+			traceID := reqcontext.GetTraceID(ctx)
+			if traceID == "" {
+				traceID = newTraceID(16)
+				ctx = reqcontext.WithTraceID(ctx, traceID)
+			}
+			parentID, spanID := reqcontext.GetSpanID(ctx), newTraceID(8)
+			ctx = reqcontext.WithSpanID(ctx, spanID)
+
+			defer func(begin time.Time) {
+				fields := []any{"msg", "span", "name", name, "trace_id", traceID, "span_id", spanID,
+					"request_id", reqcontext.GetRequestID(ctx), "took", time.Since(begin)}
+				if parentID != "" {
+					fields = append(fields, "parent_span_id", parentID)
+				}
+				if failed(response, err) {
+					fields = append(fields, "failed", true)
+				}
+				level.Debug(logger).Log(fields...)
+			}(time.Now())
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// newTraceID returns a random hex trace or span ID of size bytes
+func newTraceID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// RateLimitMiddleware allows rate requests per second with bursts of up to burst requests,
+// further requests fail with ErrRateLimited. A zero or negative rate disables the middleware.
+func RateLimitMiddleware(rate float64, burst int) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		if rate <= 0 {
+			return next
+		}
+
+		limiter := newTokenBucket(rate, burst)
+		return func(ctx context.Context, request any) (any, error) {
+			if !limiter.allow() {
+				return nil, ErrRateLimited
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// RateLimitMiddlewareWithLimiter rejects requests limiter doesn't allow with ErrRateLimited,
+// the limit can be changed at runtime through limiter.SetLimit
+func RateLimitMiddlewareWithLimiter(limiter *RateLimiter) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request any) (any, error) {
+			if !limiter.Allow() {
+				return nil, ErrRateLimited
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// CircuitBreakerMiddleware runs requests through cb, failing fast with breaker.ErrOpen
+// while it is open. Failed responses (see endpoint.Failer) count as failures.
+func CircuitBreakerMiddleware(cb *breaker.CircuitBreaker) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request any) (response any, err error) {
+			breakerErr := cb.Execute(func() error {
+				response, err = next(ctx, request)
+				if err != nil {
+					return err
+				}
+				if failer, ok := response.(endpoint.Failer); ok {
+					return failer.Failed()
+				}
+				return nil
+			})
+			if errors.Is(breakerErr, breaker.ErrOpen) {
+				return nil, breakerErr
+			}
+			return response, err
+		}
+	}
+}
+
+// TimeoutMiddleware gives each request a deadline budget. Downstream work observes the
+// budget through the context (database and Redis calls are canceled), and a request that
+// fails after its budget ran out returns fallback instead, or ErrTimeout if fallback is nil.
+// A zero or negative timeout disables the middleware.
+func TimeoutMiddleware(timeout time.Duration, fallback any) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		if timeout <= 0 {
+			return next
+		}
+
+		return func(ctx context.Context, request any) (any, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			response, err := next(ctx, request)
+			if failed(response, err) && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				if fallback != nil {
+					return fallback, nil
+				}
+				return nil, ErrTimeout
+			}
+
+			return response, err
+		}
+	}
+}
+
+// failed reports whether an endpoint call failed, either directly or through endpoint.Failer
+func failed(response any, err error) bool {
+	if err != nil {
+		return true
+	}
+	if failer, ok := response.(endpoint.Failer); ok {
+		return failer.Failed() != nil
+	}
+	return false
+}
+
+// RateLimiter is a token bucket rate limiter whose limit can be changed at runtime,
+// a zero or negative rate allows every request
+type RateLimiter struct {
+	bucket *tokenBucket
+}
+
+// NewRateLimiter creates a rate limiter allowing rate requests per second with bursts of up to burst requests
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{bucket: newTokenBucket(rate, burst)}
+}
+
+// Allow takes a token if one is available
+func (l *RateLimiter) Allow() bool {
+	return l.bucket.allow()
+}
+
+// Limit returns the current rate and burst
+func (l *RateLimiter) Limit() (rate float64, burst int) {
+	l.bucket.mu.Lock()
+	defer l.bucket.mu.Unlock()
+	return l.bucket.rate, int(l.bucket.burst)
+}
+
+// SetLimit changes the rate and burst, the bucket keeps its tokens up to the new burst
+func (l *RateLimiter) SetLimit(rate float64, burst int) {
+	l.bucket.setLimit(rate, burst)
+}
+
+// tokenBucket is a minimal token bucket rate limiter
+type tokenBucket struct {
+	rate  float64 // tokens added per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// allow takes a token if one is available
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate <= 0 {
+		return true
+	}
+
+	now := b.now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// setLimit changes the rate and burst
+func (b *tokenBucket) setLimit(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Credit tokens earned under the old rate before switching
+	now := b.now()
+	if b.rate > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+	}
+	b.last = now
+	b.rate = rate
+	b.burst = float64(burst)
+	b.tokens = math.Min(b.burst, b.tokens)
+}
@@ -0,0 +1,243 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/breaker"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// slowEndpoint waits for delay or the context, like a database call would
+func slowEndpoint(delay time.Duration) func(ctx context.Context, request any) (any, error) {
+	return func(ctx context.Context, request any) (any, error) {
+		select {
+		case <-time.After(delay):
+			return &GetCampaignsResponse{}, nil
+		case <-ctx.Done():
+			return &GetCampaignsResponse{Err: ctx.Err()}, nil
+		}
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	t.Run("within budget", func(t *testing.T) {
+		ep := TimeoutMiddleware(time.Second, nil)(slowEndpoint(time.Millisecond))
+		response, err := ep(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.NoError(t, response.(*GetCampaignsResponse).Err)
+	})
+
+	t.Run("exceeded returns ErrTimeout", func(t *testing.T) {
+		ep := TimeoutMiddleware(10*time.Millisecond, nil)(slowEndpoint(time.Second))
+		start := time.Now()
+		_, err := ep(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrTimeout)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("exceeded returns fallback", func(t *testing.T) {
+		ep := TimeoutMiddleware(10*time.Millisecond, &GetCampaignsResponse{})(slowEndpoint(time.Second))
+		response, err := ep(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, &GetCampaignsResponse{}, response)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		next := slowEndpoint(time.Millisecond)
+		ep := TimeoutMiddleware(0, nil)(next)
+		_, err := ep(context.Background(), nil)
+		assert.NoError(t, err)
+	})
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	ep := RateLimitMiddleware(1, 2)(slowEndpoint(0))
+
+	// The burst is allowed, then requests are rejected until tokens refill
+	for i := 0; i < 2; i++ {
+		_, err := ep(context.Background(), nil)
+		assert.NoError(t, err)
+	}
+	_, err := ep(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(10, 1)
+	bucket.now = func() time.Time { return now }
+	bucket.last = now
+
+	assert.True(t, bucket.allow())
+	assert.False(t, bucket.allow())
+
+	now = now.Add(100 * time.Millisecond)
+	assert.True(t, bucket.allow())
+}
+
+func TestRateLimiterSetLimit(t *testing.T) {
+	limiter := NewRateLimiter(0, 1)
+	ep := RateLimitMiddlewareWithLimiter(limiter)(slowEndpoint(0))
+
+	// A zero rate allows every request
+	for i := 0; i < 5; i++ {
+		_, err := ep(context.Background(), nil)
+		assert.NoError(t, err)
+	}
+
+	limiter.SetLimit(1, 1)
+	_, err := ep(context.Background(), nil)
+	assert.NoError(t, err)
+	_, err = ep(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
+
+func TestCircuitBreakerMiddleware(t *testing.T) {
+	cb := breaker.New(breaker.Settings{Name: "delivery", FailureThreshold: 2, OpenTimeout: time.Minute})
+	failing := func(ctx context.Context, request any) (any, error) {
+		return &GetCampaignsResponse{Err: errors.New("failed to retrieve campaigns")}, nil
+	}
+	ep := CircuitBreakerMiddleware(cb)(failing)
+
+	// Failed responses pass through and count as failures
+	for i := 0; i < 2; i++ {
+		response, err := ep(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.Error(t, response.(*GetCampaignsResponse).Err)
+	}
+
+	_, err := ep(context.Background(), nil)
+	assert.ErrorIs(t, err, breaker.ErrOpen)
+}
+
+func TestServiceMiddlewareOrder(t *testing.T) {
+	var calls []string
+	recording := func(name string) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
+		return func(next service.CampaignDeliveryService) service.CampaignDeliveryService {
+			return serviceFunc(func(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+				calls = append(calls, name)
+				return next.GetCampaigns(ctx, req)
+			})
+		}
+	}
+
+	mockService := &MockDeliveryService{}
+	expected := []models.CampaignResponse{{CID: "spotify"}}
+	mockService.On("GetCampaigns", mock.Anything, mock.Anything).Return(expected, nil)
+
+	endpoints := MakeDeliveryEndpoints(mockService,
+		ServiceMiddleware(recording("first")),
+		ServiceMiddleware(recording("second")),
+	)
+
+	campaigns, err := endpoints.GetCampaigns(context.Background(), models.DeliveryRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, campaigns)
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+// serviceFunc adapts a function to service.CampaignDeliveryService
+type serviceFunc func(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error)
+
+func (f serviceFunc) GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+	return f(ctx, req)
+}
+
+func TestValidationMiddleware(t *testing.T) {
+	mockService := &MockDeliveryService{}
+	endpoints := MakeDeliveryEndpoints(mockService, ValidationMiddleware(models.NewRequestValidator(models.DefaultValidationRules())))
+
+	tests := []struct {
+		name    string
+		request models.DeliveryRequest
+		wantErr string
+	}{
+		{
+			name:    "missing app",
+			request: models.DeliveryRequest{Country: "US", OS: "Android"},
+			wantErr: "app is required",
+		},
+		{
+			name:    "missing country",
+			request: models.DeliveryRequest{App: "com.test.app", OS: "Android"},
+			wantErr: "country is required",
+		},
+		{
+			name:    "missing os",
+			request: models.DeliveryRequest{App: "com.test.app", Country: "US"},
+			wantErr: "os is required",
+		},
+		{
+			name:    "all missing",
+			request: models.DeliveryRequest{},
+			wantErr: "country is required; os is required; app is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := endpoints.GetCampaigns(context.Background(), tt.request)
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	// Invalid requests never reach the service
+	mockService.AssertNotCalled(t, "GetCampaigns", mock.Anything, mock.Anything)
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	var logged []map[string]any
+	logger := log.LoggerFunc(func(keyvals ...any) error {
+		fields := make(map[string]any, len(keyvals)/2)
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			fields[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+		}
+		logged = append(logged, fields)
+		return nil
+	})
+
+	var traceID, spanID string
+	ep := TracingMiddleware(logger, "delivery")(func(ctx context.Context, request any) (any, error) {
+		traceID, spanID = reqcontext.GetTraceID(ctx), reqcontext.GetSpanID(ctx)
+		return &GetCampaignsResponse{}, nil
+	})
+
+	t.Run("propagates the upstream trace", func(t *testing.T) {
+		logged = nil
+		ctx := reqcontext.WithSpanID(reqcontext.WithTraceID(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736"), "00f067aa0ba902b7")
+		_, err := ep(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+		assert.Len(t, spanID, 16)
+		assert.NotEqual(t, "00f067aa0ba902b7", spanID)
+
+		assert.Len(t, logged, 1)
+		assert.Equal(t, "span", logged[0]["msg"])
+		assert.Equal(t, traceID, logged[0]["trace_id"])
+		assert.Equal(t, spanID, logged[0]["span_id"])
+		assert.Equal(t, "00f067aa0ba902b7", logged[0]["parent_span_id"])
+	})
+
+	t.Run("starts a trace", func(t *testing.T) {
+		logged = nil
+		_, err := ep(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.Len(t, traceID, 32)
+		assert.Len(t, spanID, 16)
+
+		assert.Len(t, logged, 1)
+		assert.Equal(t, traceID, logged[0]["trace_id"])
+		assert.NotContains(t, logged[0], "parent_span_id")
+	})
+}
@@ -0,0 +1,6 @@
+//go:build !race
+
+package endpoint
+
+// raceEnabled reports whether tests run with the race detector, which makes sync.Pool drop items
+const raceEnabled = false
@@ -0,0 +1,48 @@
+package errorreporter
+
+import (
+	"context"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+)
+
+// Reporter sends errors to an external error tracking service
+type Reporter interface {
+	// Report captures an error with request context and extra tags attached
+	Report(ctx context.Context, err error, tags map[string]string)
+	// Close flushes pending reports and releases resources
+	Close() error
+}
+
+// nopReporter discards all reports, used when error reporting is not configured
+type nopReporter struct{}
+
+// NewNopReporter creates a reporter that discards all reports
+func NewNopReporter() Reporter {
+	return nopReporter{}
+}
+
+func (nopReporter) Report(context.Context, error, map[string]string) {}
+
+func (nopReporter) Close() error { return nil }
+
+// requestTags extracts request context information attached to every report
+func requestTags(ctx context.Context) map[string]string {
+	tags := make(map[string]string)
+
+	info := reqcontext.GetRequestInfo(ctx)
+	if info.ID != "" {
+		tags["request_id"] = info.ID
+	}
+	if info.UserAgent != "" {
+		tags["user_agent"] = info.UserAgent
+	}
+	if info.RemoteAddr != "" {
+		tags["remote_addr"] = info.RemoteAddr
+	}
+	if traceID := reqcontext.GetTraceID(ctx); traceID != "" {
+		tags["trace_id"] = traceID
+	}
+
+	return tags
+}
@@ -0,0 +1,180 @@
+package errorreporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SentryConfig holds configuration for the Sentry reporter
+type SentryConfig struct {
+	DSN         string
+	Environment string
+	Release     string
+	// QueueSize bounds the number of pending events, further events are dropped
+	QueueSize int
+}
+
+// sentryEvent is the subset of the Sentry event payload we send
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Message     string            `json:"message"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// sentryReporter sends events to Sentry's store endpoint asynchronously
+// so reporting never adds latency to the request path
+type sentryReporter struct {
+	storeURL   string
+	authHeader string
+	config     SentryConfig
+	serverName string
+	client     *http.Client
+	events     chan sentryEvent
+	wg         sync.WaitGroup
+	mu         sync.RWMutex
+	closed     bool
+}
+
+// NewSentryReporter creates a reporter for the given Sentry DSN
+// (https://<public_key>@<host>/<project_id>)
+func NewSentryReporter(config SentryConfig) (Reporter, error) {
+	dsn, err := url.Parse(config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sentry DSN: %w", err)
+	}
+
+	publicKey := dsn.User.Username()
+	projectID := strings.Trim(dsn.Path, "/")
+	if publicKey == "" || projectID == "" {
+		return nil, fmt.Errorf("invalid sentry DSN: missing public key or project id")
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+
+	serverName, _ := os.Hostname()
+
+	r := &sentryReporter{
+		storeURL: fmt.Sprintf("%s://%s/api/%s/store/", dsn.Scheme, dsn.Host, projectID),
+		authHeader: fmt.Sprintf("Sentry sentry_version=7, sentry_client=adbeacon/%s, sentry_key=%s",
+			config.Release, publicKey),
+		config:     config,
+		serverName: serverName,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		events:     make(chan sentryEvent, config.QueueSize),
+	}
+
+	r.wg.Add(1)
+	go r.run()
+
+	return r, nil
+}
+
+// Report queues an error event, dropping it if the queue is full
+func (r *sentryReporter) Report(ctx context.Context, err error, tags map[string]string) {
+	if err == nil {
+		return
+	}
+
+	event := sentryEvent{
+		EventID:     strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Level:       "error",
+		Platform:    "go",
+		Logger:      "adbeacon",
+		ServerName:  r.serverName,
+		Environment: r.config.Environment,
+		Release:     r.config.Release,
+		Message:     err.Error(),
+		Tags:        requestTags(ctx),
+	}
+	for key, value := range tags {
+		event.Tags[key] = value
+	}
+	event.Exception.Values = []sentryException{{
+		Type:  reflect.TypeOf(err).String(),
+		Value: err.Error(),
+	}}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+
+	select {
+	case r.events <- event:
+	default:
+		// Queue full, drop rather than block the caller
+	}
+}
+
+// run sends queued events until the reporter is closed
+func (r *sentryReporter) run() {
+	defer r.wg.Done()
+
+	for event := range r.events {
+		r.send(event)
+	}
+}
+
+// send posts a single event to Sentry, failures are ignored
+func (r *sentryReporter) send(event sentryEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.storeURL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.authHeader)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+// Close stops accepting events and waits for queued events to be sent
+func (r *sentryReporter) Close() error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return nil
+}
@@ -0,0 +1,63 @@
+package errorreporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSentryReporter_InvalidDSN(t *testing.T) {
+	_, err := NewSentryReporter(SentryConfig{DSN: "https://sentry.example.com/42"})
+	assert.Error(t, err)
+}
+
+func TestSentryReporter_Report(t *testing.T) {
+	var mu sync.Mutex
+	var received []sentryEvent
+	var authHeader, path string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event sentryEvent
+		json.NewDecoder(r.Body).Decode(&event)
+
+		mu.Lock()
+		received = append(received, event)
+		authHeader = r.Header.Get("X-Sentry-Auth")
+		path = r.URL.Path
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "http://", "http://publickey@", 1) + "/42"
+	reporter, err := NewSentryReporter(SentryConfig{DSN: dsn, Environment: "test", Release: "1.0.0"})
+	require.NoError(t, err)
+
+	ctx := reqcontext.WithRequestID(context.Background(), "req-123")
+	reporter.Report(ctx, errors.New("database error"), map[string]string{"component": "repository"})
+
+	// Close flushes queued events
+	require.NoError(t, reporter.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Len(t, received, 1)
+	assert.Equal(t, "/api/42/store/", path)
+	assert.Contains(t, authHeader, "sentry_key=publickey")
+	assert.Equal(t, "database error", received[0].Message)
+	assert.Equal(t, "test", received[0].Environment)
+	assert.Equal(t, "req-123", received[0].Tags["request_id"])
+	assert.Equal(t, "repository", received[0].Tags["component"])
+
+	// Reports after close are dropped instead of panicking
+	reporter.Report(ctx, errors.New("late error"), nil)
+}
@@ -0,0 +1,232 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/tracking"
+)
+
+// Type is the kind of a published event
+type Type string
+
+const (
+	// TypeDelivery is published when a delivery request was answered
+	TypeDelivery Type = "delivery"
+	// TypeImpression is published when a delivered campaign rendered
+	TypeImpression Type = Type(tracking.EventImpression)
+	// TypeClick is published when a delivered campaign was clicked
+	TypeClick Type = Type(tracking.EventClick)
+)
+
+// Outcomes of published events, used as metric labels
+const (
+	OutcomePublished = "published"
+	OutcomeExported  = "exported"
+	// OutcomeDropped events were rejected because the queue was full or the bus closed
+	OutcomeDropped = "dropped"
+	// OutcomeFailed events were given up on after every export attempt failed
+	OutcomeFailed = "failed"
+)
+
+// Event is a delivery, impression or click as consumed by analytics and billing. Delivery
+// events list every campaign served in CampaignIDs, tracked events name theirs in CampaignID.
+type Event struct {
+	Type        Type      `json:"type"`
+	Time        time.Time `json:"time"`
+	RequestID   string    `json:"request_id,omitempty"`
+	ClientID    string    `json:"client_id,omitempty"`
+	CampaignID  string    `json:"campaign_id,omitempty"`
+	CampaignIDs []string  `json:"campaign_ids,omitempty"`
+	Country     string    `json:"country,omitempty"`
+	OS          string    `json:"os,omitempty"`
+	App         string    `json:"app,omitempty"`
+	State       string    `json:"state,omitempty"`
+}
+
+// Exporter ships batches of events downstream, a returned error makes the bus retry the batch
+type Exporter interface {
+	Export(ctx context.Context, batch []Event) error
+}
+
+// Recorder counts events by outcome, metrics.CachedMetrics implements it
+type Recorder interface {
+	RecordBusEvents(outcome string, count int)
+}
+
+// Config holds the batching and retry settings of a Bus
+type Config struct {
+	// QueueSize bounds the events waiting for export, further events are dropped
+	QueueSize int
+	// BatchSize is the most events exported at once, a full batch is exported immediately
+	BatchSize int
+	// FlushInterval is the longest an event waits for its batch to fill
+	FlushInterval time.Duration
+	// ExportTimeout bounds a single export attempt
+	ExportTimeout time.Duration
+	// MaxAttempts is the number of export attempts of a batch before it is given up on
+	MaxAttempts int
+	// RetryDelay is the backoff before the first retry, doubled for every further one
+	RetryDelay time.Duration
+}
+
+// Bus queues events and exports them in batches from a single goroutine. Publishing never
+// blocks: while the exporter is slow or retrying, the queue fills up and new events are
+// dropped and counted, so backpressure never reaches the request path.
+type Bus struct {
+	config   Config
+	exporter Exporter
+	recorder Recorder
+	queue    chan Event
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewBus creates a bus exporting to exporter and starts its export loop, zero config values
+// are replaced by defaults
+func NewBus(config Config, exporter Exporter, recorder Recorder) *Bus {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10000
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.ExportTimeout <= 0 {
+		config.ExportTimeout = 5 * time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+
+	b := &Bus{
+		config:   config,
+		exporter: exporter,
+		recorder: recorder,
+		queue:    make(chan Event, config.QueueSize),
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Publish queues an event for export, it reports false if the event was dropped
+func (b *Bus) Publish(event Event) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		b.record(OutcomeDropped, 1)
+		return false
+	}
+
+	select {
+	case b.queue <- event:
+		b.record(OutcomePublished, 1)
+		return true
+	default:
+		// Queue full, drop rather than block the caller
+		b.record(OutcomeDropped, 1)
+		return false
+	}
+}
+
+// Len returns the number of queued events
+func (b *Bus) Len() int {
+	return len(b.queue)
+}
+
+// Close stops accepting events and waits until the queued events are exported or ctx is done
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run batches queued events until the queue is closed and drained
+func (b *Bus) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, b.config.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			b.export(batch)
+			batch = make([]Event, 0, b.config.BatchSize)
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-b.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= b.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// export sends a batch, retrying with exponential backoff. Events keep queueing meanwhile,
+// which is what throttles publishers once the queue is full.
+func (b *Bus) export(batch []Event) {
+	delay := b.config.RetryDelay
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), b.config.ExportTimeout)
+		err := b.exporter.Export(ctx, batch)
+		cancel()
+		if err == nil {
+			b.record(OutcomeExported, len(batch))
+			return
+		}
+		if attempt >= b.config.MaxAttempts {
+			b.record(OutcomeFailed, len(batch))
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (b *Bus) record(outcome string, count int) {
+	if b.recorder != nil {
+		b.recorder.RecordBusEvents(outcome, count)
+	}
+}
+
+// NewTrackingRecorder creates a tracking recorder publishing impressions and clicks to bus
+func NewTrackingRecorder(bus *Bus) tracking.Recorder {
+	return tracking.RecorderFunc(func(_ context.Context, event tracking.Event) {
+		bus.Publish(Event{
+			Type:       Type(event.Type),
+			Time:       event.Time,
+			RequestID:  event.RequestID,
+			CampaignID: event.CampaignID,
+			Country:    event.Country,
+			OS:         event.OS,
+			App:        event.App,
+			State:      event.State,
+		})
+	})
+}
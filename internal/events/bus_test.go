@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/tracking"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExporter records exported batches, failing while fail is set or blocking while block is open
+type fakeExporter struct {
+	mu      sync.Mutex
+	batches [][]Event
+	fail    bool
+	calls   int
+	block   chan struct{}
+}
+
+func (e *fakeExporter) Export(ctx context.Context, batch []Event) error {
+	if e.block != nil {
+		<-e.block
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls++
+	if e.fail {
+		return errors.New("broker unavailable")
+	}
+	e.batches = append(e.batches, append([]Event(nil), batch...))
+	return nil
+}
+
+func (e *fakeExporter) exported() [][]Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.batches
+}
+
+// countingRecorder counts events by outcome
+type countingRecorder struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (r *countingRecorder) RecordBusEvents(outcome string, count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = map[string]int{}
+	}
+	r.counts[outcome] += count
+}
+
+func (r *countingRecorder) count(outcome string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[outcome]
+}
+
+func TestBus_FullBatchExportedImmediately(t *testing.T) {
+	exporter := &fakeExporter{}
+	recorder := &countingRecorder{}
+	bus := NewBus(Config{BatchSize: 2, FlushInterval: time.Hour}, exporter, recorder)
+	defer bus.Close(context.Background())
+
+	assert.True(t, bus.Publish(Event{Type: TypeDelivery, RequestID: "r1"}))
+	assert.True(t, bus.Publish(Event{Type: TypeImpression, RequestID: "r1", CampaignID: "spotify"}))
+
+	require.Eventually(t, func() bool { return len(exporter.exported()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Len(t, exporter.exported()[0], 2)
+	assert.Equal(t, 2, recorder.count(OutcomePublished))
+	assert.Equal(t, 2, recorder.count(OutcomeExported))
+}
+
+func TestBus_FlushInterval(t *testing.T) {
+	exporter := &fakeExporter{}
+	bus := NewBus(Config{BatchSize: 100, FlushInterval: 10 * time.Millisecond}, exporter, nil)
+	defer bus.Close(context.Background())
+
+	bus.Publish(Event{Type: TypeClick, CampaignID: "spotify"})
+
+	// A partial batch is exported once the interval passes
+	require.Eventually(t, func() bool { return len(exporter.exported()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "spotify", exporter.exported()[0][0].CampaignID)
+}
+
+func TestBus_DropsWhenQueueFull(t *testing.T) {
+	exporter := &fakeExporter{block: make(chan struct{})}
+	recorder := &countingRecorder{}
+	bus := NewBus(Config{QueueSize: 2, BatchSize: 1, FlushInterval: time.Hour}, exporter, recorder)
+
+	// The first event is taken by the blocked export, two more fill the queue
+	require.True(t, bus.Publish(Event{RequestID: "r1"}))
+	require.Eventually(t, func() bool { return bus.Len() == 0 }, time.Second, 5*time.Millisecond)
+	require.True(t, bus.Publish(Event{RequestID: "r2"}))
+	require.True(t, bus.Publish(Event{RequestID: "r3"}))
+
+	// Publishing doesn't block on a full queue, the event is dropped
+	assert.False(t, bus.Publish(Event{RequestID: "r4"}))
+	assert.Equal(t, 1, recorder.count(OutcomeDropped))
+
+	close(exporter.block)
+	require.NoError(t, bus.Close(context.Background()))
+	assert.Len(t, exporter.exported(), 3)
+	assert.Equal(t, 3, recorder.count(OutcomeExported))
+}
+
+func TestBus_RetriesFailedExports(t *testing.T) {
+	exporter := &fakeExporter{fail: true}
+	recorder := &countingRecorder{}
+	bus := NewBus(Config{BatchSize: 1, FlushInterval: time.Hour, MaxAttempts: 3, RetryDelay: time.Millisecond}, exporter, recorder)
+
+	bus.Publish(Event{RequestID: "r1"})
+	require.NoError(t, bus.Close(context.Background()))
+
+	// The batch is given up on after the last attempt
+	assert.Equal(t, 3, exporter.calls)
+	assert.Equal(t, 1, recorder.count(OutcomeFailed))
+	assert.Equal(t, 0, recorder.count(OutcomeExported))
+}
+
+func TestBus_Close(t *testing.T) {
+	exporter := &fakeExporter{}
+	recorder := &countingRecorder{}
+	bus := NewBus(Config{BatchSize: 100, FlushInterval: time.Hour}, exporter, recorder)
+
+	bus.Publish(Event{RequestID: "r1"})
+	bus.Publish(Event{RequestID: "r2"})
+
+	// Closing exports the pending partial batch
+	require.NoError(t, bus.Close(context.Background()))
+	require.Len(t, exporter.exported(), 1)
+	assert.Len(t, exporter.exported()[0], 2)
+
+	// Events published after close are dropped, closing again is a no-op
+	assert.False(t, bus.Publish(Event{RequestID: "r3"}))
+	assert.Equal(t, 1, recorder.count(OutcomeDropped))
+	assert.NoError(t, bus.Close(context.Background()))
+}
+
+func TestBus_CloseDeadline(t *testing.T) {
+	exporter := &fakeExporter{block: make(chan struct{})}
+	defer close(exporter.block)
+	bus := NewBus(Config{BatchSize: 1, FlushInterval: time.Hour}, exporter, nil)
+	bus.Publish(Event{RequestID: "r1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bus.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestNewTrackingRecorder(t *testing.T) {
+	exporter := &fakeExporter{}
+	bus := NewBus(Config{BatchSize: 100, FlushInterval: time.Hour}, exporter, nil)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	recorder := NewTrackingRecorder(bus)
+	recorder.Record(context.Background(), tracking.Event{
+		Type:       tracking.EventClick,
+		CampaignID: "spotify",
+		RequestID:  "r1",
+		Country:    "us",
+		OS:         "android",
+		App:        "com.example",
+		Time:       now,
+	})
+	require.NoError(t, bus.Close(context.Background()))
+
+	require.Len(t, exporter.exported(), 1)
+	assert.Equal(t, Event{
+		Type:       TypeClick,
+		Time:       now,
+		RequestID:  "r1",
+		CampaignID: "spotify",
+		Country:    "us",
+		OS:         "android",
+		App:        "com.example",
+	}, exporter.exported()[0][0])
+}
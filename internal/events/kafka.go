@@ -0,0 +1,88 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// kafkaRESTContentType is the Kafka REST Proxy v2 content type for JSON-encoded records
+const kafkaRESTContentType = "application/vnd.kafka.json.v2+json"
+
+// kafkaRecord is a record of a Kafka REST Proxy produce request
+type kafkaRecord struct {
+	Key   *string `json:"key"`
+	Value Event   `json:"value"`
+}
+
+// kafkaProduceRequest is the body of a Kafka REST Proxy produce request
+type kafkaProduceRequest struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+// KafkaRESTExporter produces events to a Kafka topic through a Kafka REST Proxy, so the
+// service needs no Kafka client or broker connections of its own. Records are keyed by
+// request ID, keeping a delivery and its impressions and clicks on the same partition.
+type KafkaRESTExporter struct {
+	produceURL string
+	client     *http.Client
+}
+
+// NewKafkaRESTExporter creates an exporter producing to topic through the REST proxy at proxyURL
+func NewKafkaRESTExporter(proxyURL, topic string, client *http.Client) (*KafkaRESTExporter, error) {
+	base, err := url.Parse(proxyURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid kafka rest proxy url %q", proxyURL)
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("kafka topic is required")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &KafkaRESTExporter{
+		produceURL: strings.TrimSuffix(base.String(), "/") + "/topics/" + url.PathEscape(topic),
+		client:     client,
+	}, nil
+}
+
+// Export produces batch in a single request
+func (e *KafkaRESTExporter) Export(ctx context.Context, batch []Event) error {
+	records := make([]kafkaRecord, len(batch))
+	for i, event := range batch {
+		records[i].Value = event
+		if event.RequestID != "" {
+			key := event.RequestID
+			records[i].Key = &key
+		}
+	}
+	body, err := json.Marshal(kafkaProduceRequest{Records: records})
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.produceURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaRESTContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to produce events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("kafka rest proxy returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafkaRESTExporter_Export(t *testing.T) {
+	var (
+		path        string
+		contentType string
+		body        struct {
+			Records []struct {
+				Key   *string `json:"key"`
+				Value Event   `json:"value"`
+			} `json:"records"`
+		}
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		contentType = r.Header.Get("Content-Type")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(`{"offsets":[]}`))
+	}))
+	defer proxy.Close()
+
+	exporter, err := NewKafkaRESTExporter(proxy.URL+"/", "adbeacon-events", proxy.Client())
+	require.NoError(t, err)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	err = exporter.Export(context.Background(), []Event{
+		{Type: TypeDelivery, Time: now, RequestID: "r1", CampaignIDs: []string{"spotify", "duolingo"}, Country: "us"},
+		{Type: TypeImpression, Time: now, CampaignID: "spotify"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "/topics/adbeacon-events", path)
+	assert.Equal(t, "application/vnd.kafka.json.v2+json", contentType)
+	require.Len(t, body.Records, 2)
+
+	// Records are keyed by request ID, events without one are left to the partitioner
+	require.NotNil(t, body.Records[0].Key)
+	assert.Equal(t, "r1", *body.Records[0].Key)
+	assert.Equal(t, []string{"spotify", "duolingo"}, body.Records[0].Value.CampaignIDs)
+	assert.Nil(t, body.Records[1].Key)
+	assert.Equal(t, TypeImpression, body.Records[1].Value.Type)
+}
+
+func TestKafkaRESTExporter_Error(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error_code":40401,"message":"Topic not found."}`))
+	}))
+	defer proxy.Close()
+
+	exporter, err := NewKafkaRESTExporter(proxy.URL, "missing", proxy.Client())
+	require.NoError(t, err)
+
+	err = exporter.Export(context.Background(), []Event{{Type: TypeClick, CampaignID: "spotify"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "kafka rest proxy returned 404")
+	assert.Contains(t, err.Error(), "Topic not found.")
+}
+
+func TestNewKafkaRESTExporter_Invalid(t *testing.T) {
+	_, err := NewKafkaRESTExporter("localhost:8082", "adbeacon-events", nil)
+	assert.Error(t, err)
+
+	_, err = NewKafkaRESTExporter("http://localhost:8082", "", nil)
+	assert.Error(t, err)
+}
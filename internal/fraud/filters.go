@@ -0,0 +1,171 @@
+package fraud
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// Names of the built-in filters
+const (
+	FilterDatacenter = "datacenter_ip"
+	FilterBotAgent   = "bot_user_agent"
+	FilterGeoCarrier = "geo_carrier"
+)
+
+// DefaultBotAgents are user agent fragments of crawlers, headless browsers and HTTP libraries,
+// none of which render ads for people
+var DefaultBotAgents = []string{
+	"bot", "crawler", "spider", "slurp", "headlesschrome", "phantomjs", "puppeteer", "selenium",
+	"curl/", "wget/", "python-requests", "python-urllib", "go-http-client", "java/", "libwww-perl", "scrapy",
+}
+
+// DefaultCarrierCountries are carriers that only operate in the listed countries
+var DefaultCarrierCountries = []string{
+	"verizon:us", "sprint:us", "jio:in", "rogers:ca", "ntt docomo:jp", "kddi:jp", "softbank:jp",
+}
+
+// datacenterFilter blocks clients in datacenter networks
+type datacenterFilter struct {
+	prefixes []netip.Prefix
+}
+
+// NewDatacenterFilter creates a filter blocking clients whose IP is in one of prefixes
+func NewDatacenterFilter(prefixes []netip.Prefix) Filter {
+	return &datacenterFilter{prefixes: prefixes}
+}
+
+func (f *datacenterFilter) Name() string { return FilterDatacenter }
+
+func (f *datacenterFilter) Block(traffic Traffic) bool {
+	if !traffic.IP.IsValid() {
+		return false
+	}
+	ip := traffic.IP.Unmap()
+	for _, prefix := range f.prefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePrefixes parses CIDR prefixes, single addresses are accepted as /32 or /128
+func ParsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// LoadPrefixFile reads CIDR prefixes from a file with one per line, blank lines and lines
+// starting with # are ignored
+func LoadPrefixFile(path string) ([]netip.Prefix, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open network list: %w", err)
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read network list: %w", err)
+	}
+	return ParsePrefixes(entries)
+}
+
+// botAgentFilter blocks user agents of known bots
+type botAgentFilter struct {
+	fragments []string
+}
+
+// NewBotAgentFilter creates a filter blocking user agents containing one of fragments, case insensitively
+func NewBotAgentFilter(fragments []string) Filter {
+	lowered := make([]string, len(fragments))
+	for i, fragment := range fragments {
+		lowered[i] = strings.ToLower(fragment)
+	}
+	return &botAgentFilter{fragments: lowered}
+}
+
+func (f *botAgentFilter) Name() string { return FilterBotAgent }
+
+func (f *botAgentFilter) Block(traffic Traffic) bool {
+	userAgent := strings.ToLower(traffic.UserAgent)
+	if userAgent == "" {
+		return false
+	}
+	for _, fragment := range f.fragments {
+		if strings.Contains(userAgent, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// geoCarrierFilter blocks carriers reported in countries they don't operate in
+type geoCarrierFilter struct {
+	countries map[string][]string
+}
+
+// NewGeoCarrierFilter creates a filter blocking requests whose carrier is not known to operate in
+// the request country. Carriers missing from countries are never blocked.
+func NewGeoCarrierFilter(countries map[string][]string) Filter {
+	return &geoCarrierFilter{countries: countries}
+}
+
+func (f *geoCarrierFilter) Name() string { return FilterGeoCarrier }
+
+func (f *geoCarrierFilter) Block(traffic Traffic) bool {
+	country := models.NormalizeCountryCode(traffic.Request.Country)
+	if traffic.Carrier == "" || country == "" {
+		return false
+	}
+	countries, ok := f.countries[strings.ToLower(strings.TrimSpace(traffic.Carrier))]
+	return ok && !slices.Contains(countries, country)
+}
+
+// ParseCarrierCountries parses carrier:country|country entries into the countries of each carrier,
+// lowercased
+func ParseCarrierCountries(entries []string) (map[string][]string, error) {
+	countries := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		carrier, list, ok := strings.Cut(entry, ":")
+		carrier = strings.ToLower(strings.TrimSpace(carrier))
+		if !ok || carrier == "" || strings.TrimSpace(list) == "" {
+			return nil, fmt.Errorf("invalid carrier countries %q, expected carrier:country|country", entry)
+		}
+		for _, country := range strings.Split(list, "|") {
+			if country = models.NormalizeCountryCode(country); country != "" {
+				countries[carrier] = append(countries[carrier], country)
+			}
+		}
+	}
+	return countries, nil
+}
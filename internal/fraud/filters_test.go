@@ -0,0 +1,122 @@
+package fraud
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatacenterFilter(t *testing.T) {
+	prefixes, err := ParsePrefixes([]string{"203.0.113.0/24", "198.51.100.7", "2001:db8::/32"})
+	require.NoError(t, err)
+	filter := NewDatacenterFilter(prefixes)
+	assert.Equal(t, FilterDatacenter, filter.Name())
+
+	tests := []struct {
+		ip    string
+		block bool
+	}{
+		{"203.0.113.42", true},
+		{"198.51.100.7", true},
+		{"198.51.100.8", false},
+		{"2001:db8::1", true},
+		{"::ffff:203.0.113.1", true},
+		{"192.0.2.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.block, filter.Block(Traffic{IP: netip.MustParseAddr(tt.ip)}))
+		})
+	}
+
+	// Unknown addresses are never blocked
+	assert.False(t, filter.Block(Traffic{}))
+}
+
+func TestParsePrefixes(t *testing.T) {
+	prefixes, err := ParsePrefixes([]string{"10.1.2.3/8"})
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, prefixes)
+
+	_, err = ParsePrefixes([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+	_, err = ParsePrefixes([]string{"not-an-ip"})
+	assert.Error(t, err)
+}
+
+func TestLoadPrefixFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "datacenters.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# cloud provider ranges\n203.0.113.0/24\n\n  198.51.100.7  \n"), 0o600))
+
+	prefixes, err := LoadPrefixFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24"), netip.MustParsePrefix("198.51.100.7/32")}, prefixes)
+
+	_, err = LoadPrefixFile(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestBotAgentFilter(t *testing.T) {
+	filter := NewBotAgentFilter(DefaultBotAgents)
+	assert.Equal(t, FilterBotAgent, filter.Name())
+
+	tests := []struct {
+		userAgent string
+		block     bool
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36", true},
+		{"curl/8.4.0", true},
+		{"python-requests/2.31.0", true},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userAgent, func(t *testing.T) {
+			assert.Equal(t, tt.block, filter.Block(Traffic{UserAgent: tt.userAgent}))
+		})
+	}
+}
+
+func TestGeoCarrierFilter(t *testing.T) {
+	countries, err := ParseCarrierCountries([]string{"Verizon:US", "vodafone:de|gb|in"})
+	require.NoError(t, err)
+	filter := NewGeoCarrierFilter(countries)
+	assert.Equal(t, FilterGeoCarrier, filter.Name())
+
+	tests := []struct {
+		name    string
+		carrier string
+		country string
+		block   bool
+	}{
+		{"home country", "Verizon", "us", false},
+		{"abroad", "verizon", "IN", true},
+		{"one of several countries", "Vodafone", "gb", false},
+		{"unknown carrier", "airtel", "us", false},
+		{"no carrier", "", "in", false},
+		{"no country", "verizon", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			traffic := Traffic{Carrier: tt.carrier, Request: models.DeliveryRequest{Country: tt.country}}
+			assert.Equal(t, tt.block, filter.Block(traffic))
+		})
+	}
+}
+
+func TestParseCarrierCountries(t *testing.T) {
+	countries, err := ParseCarrierCountries(DefaultCarrierCountries)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jp"}, countries["ntt docomo"])
+
+	for _, entry := range []string{"verizon", ":us", "verizon:"} {
+		_, err := ParseCarrierCountries([]string{entry})
+		assert.Error(t, err, entry)
+	}
+}
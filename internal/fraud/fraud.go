@@ -0,0 +1,54 @@
+package fraud
+
+import (
+	"net/netip"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// Traffic is what filters see of a delivery request
+type Traffic struct {
+	// IP is the client address, invalid when it couldn't be parsed
+	IP        netip.Addr
+	UserAgent string
+	// Carrier is the mobile carrier reported by the client, empty when unknown
+	Carrier string
+	Request models.DeliveryRequest
+}
+
+// Filter recognizes invalid traffic before campaigns are delivered to it
+type Filter interface {
+	// Name identifies the filter in metrics and logs
+	Name() string
+	// Block reports whether traffic is invalid
+	Block(traffic Traffic) bool
+}
+
+// Recorder counts blocked traffic by filter, metrics.CachedMetrics implements it
+type Recorder interface {
+	RecordTrafficBlocked(filter string)
+}
+
+// Chain runs filters in order, the first blocking filter decides
+type Chain struct {
+	filters  []Filter
+	recorder Recorder
+}
+
+// NewChain creates a chain of filters, recorder may be nil
+func NewChain(recorder Recorder, filters ...Filter) *Chain {
+	return &Chain{filters: filters, recorder: recorder}
+}
+
+// Check returns the name of the filter blocking traffic, or an empty string when all let it pass
+func (c *Chain) Check(traffic Traffic) string {
+	for _, filter := range c.filters {
+		if filter.Block(traffic) {
+			if c.recorder != nil {
+				c.recorder.RecordTrafficBlocked(filter.Name())
+			}
+			return filter.Name()
+		}
+	}
+	return ""
+}
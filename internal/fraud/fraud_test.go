@@ -0,0 +1,48 @@
+package fraud
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// filterFunc is a named filter blocking what block returns true for
+type filterFunc struct {
+	name  string
+	block func(Traffic) bool
+}
+
+func (f filterFunc) Name() string               { return f.name }
+func (f filterFunc) Block(traffic Traffic) bool { return f.block(traffic) }
+
+// countingRecorder counts blocked traffic by filter
+type countingRecorder map[string]int
+
+func (r countingRecorder) RecordTrafficBlocked(filter string) { r[filter]++ }
+
+func TestChain_FirstBlockingFilterDecides(t *testing.T) {
+	var calls []string
+	filter := func(name string, block bool) Filter {
+		return filterFunc{name: name, block: func(Traffic) bool {
+			calls = append(calls, name)
+			return block
+		}}
+	}
+	recorder := countingRecorder{}
+	chain := NewChain(recorder, filter("first", false), filter("second", true), filter("third", true))
+
+	assert.Equal(t, "second", chain.Check(Traffic{}))
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Equal(t, countingRecorder{"second": 1}, recorder)
+}
+
+func TestChain_Pass(t *testing.T) {
+	recorder := countingRecorder{}
+	chain := NewChain(recorder, filterFunc{name: "never", block: func(Traffic) bool { return false }})
+
+	assert.Empty(t, chain.Check(Traffic{}))
+	assert.Empty(t, recorder)
+
+	// Without filters or recorder everything passes
+	assert.Empty(t, NewChain(nil).Check(Traffic{}))
+}
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/database"
+)
+
+// DatabaseChecker pings the database, reporting the connection pool statistics
+func DatabaseChecker(db *database.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) CheckResult {
+		result := CheckResult{Status: StatusHealthy}
+		if err := db.HealthCheck(); err != nil {
+			result.Status, result.Err = StatusUnhealthy, err
+		}
+		stats := db.GetConnectionStats()
+		result.Details = map[string]any{
+			"stats": map[string]any{
+				"open_connections":     stats.OpenConnections,
+				"in_use":               stats.InUse,
+				"idle":                 stats.Idle,
+				"wait_count":           stats.WaitCount,
+				"wait_duration":        stats.WaitDuration.String(),
+				"max_idle_closed":      stats.MaxIdleClosed,
+				"max_idle_time_closed": stats.MaxIdleTimeClosed,
+				"max_lifetime_closed":  stats.MaxLifetimeClosed,
+			},
+		}
+		return result
+	})
+}
+
+// MigrationChecker reports the applied migration version, a migration left half-applied by a
+// failed run is unhealthy
+func MigrationChecker(db *database.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) CheckResult {
+		version, dirty, err := db.MigrationState(ctx)
+		if err != nil {
+			return CheckResult{Status: StatusUnhealthy, Err: err}
+		}
+		result := CheckResult{Status: StatusHealthy, Details: map[string]any{"version": version, "dirty": dirty}}
+		if dirty {
+			result.Status, result.Err = StatusUnhealthy, fmt.Errorf("migration %d is dirty", version)
+		}
+		return result
+	})
+}
+
+// CacheChecker checks the memory and Redis tiers of the cache, reporting their health and
+// statistics
+func CacheChecker(c cache.Cache) Checker {
+	return CheckerFunc(func(ctx context.Context) CheckResult {
+		cacheHealth := c.HealthCheck(ctx)
+		details := map[string]any{
+			"memory":    cacheHealth.Memory,
+			"redis":     cacheHealth.Redis,
+			"stats":     cacheHealth.Stats,
+			"uptime":    cacheHealth.Uptime,
+			"last_test": cacheHealth.LastTest,
+		}
+		if cacheHealth.Region != "" {
+			details["region"] = cacheHealth.Region
+		}
+		if cacheHealth.GlobalRedis != nil {
+			details["global_redis"] = cacheHealth.GlobalRedis
+		}
+		return CheckResult{Status: cacheHealth.Overall, Details: details}
+	})
+}
+
+// FreshnessChecker reports how recently the campaign snapshot was checked against the cache,
+// degraded once it wasn't within the cache TTL and deliveries may serve stale campaigns
+func FreshnessChecker(freshness func() cache.Freshness) Checker {
+	return CheckerFunc(func(ctx context.Context) CheckResult {
+		snapshot := freshness()
+		result := CheckResult{Status: StatusHealthy, Details: map[string]any{"snapshot": snapshot}}
+		if snapshot.Expired {
+			result.Status = StatusDegraded
+			result.Err = fmt.Errorf("campaigns not checked for %.0fs, longer than the %.0fs cache TTL", snapshot.AgeSeconds, snapshot.TTLSeconds)
+		}
+		return result
+	})
+}
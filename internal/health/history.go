@@ -0,0 +1,153 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// Component health statuses, matching the cache health statuses
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Config controls history length and hysteresis
+type Config struct {
+	// HistorySize is the number of recent results kept per component
+	HistorySize int
+	// FailureThreshold is the number of consecutive non-healthy results before a healthy component is reported as failing
+	FailureThreshold int
+	// RecoveryThreshold is the number of consecutive healthy results before a failing component is reported as healthy
+	RecoveryThreshold int
+	// FlapThreshold is the number of status changes within the history that marks a component as flapping
+	FlapThreshold int
+}
+
+// Result is a single health check outcome
+type Result struct {
+	Time   time.Time `json:"time"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// ComponentHistory is the reported state of a single component
+type ComponentHistory struct {
+	// Status is the status after hysteresis, Results hold the raw check outcomes (oldest first)
+	Status   string   `json:"status"`
+	Flapping bool     `json:"flapping"`
+	Results  []Result `json:"results"`
+}
+
+// component holds the history and hysteresis state of a single component
+type component struct {
+	results     []Result
+	status      string
+	consecutive int // consecutive raw results disagreeing with status
+}
+
+// Tracker records health check results per component and smooths the reported status
+// so that a single failed check doesn't flip overall health
+type Tracker struct {
+	config     Config
+	mu         sync.Mutex
+	components map[string]*component
+	now        func() time.Time
+}
+
+// NewTracker creates a new health history tracker
+func NewTracker(config Config) *Tracker {
+	if config.HistorySize <= 0 {
+		config.HistorySize = 20
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+	if config.RecoveryThreshold <= 0 {
+		config.RecoveryThreshold = 1
+	}
+
+	return &Tracker{
+		config:     config,
+		components: make(map[string]*component),
+		now:        time.Now,
+	}
+}
+
+// Record stores a raw check result and returns the component status after hysteresis
+func (t *Tracker) Record(name, status string, err error) string {
+	result := Result{Time: t.now(), Status: status}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.components[name]
+	if !ok {
+		// Nothing to smooth against yet, take the first result as is
+		c = &component{status: status}
+		t.components[name] = c
+	}
+
+	c.results = append(c.results, result)
+	if len(c.results) > t.config.HistorySize {
+		c.results = c.results[len(c.results)-t.config.HistorySize:]
+	}
+
+	switch {
+	case status == c.status:
+		c.consecutive = 0
+	case c.status != StatusHealthy && status != StatusHealthy:
+		// Already failing, follow changes between degraded and unhealthy immediately
+		c.status = status
+		c.consecutive = 0
+	default:
+		c.consecutive++
+		threshold := t.config.FailureThreshold
+		if status == StatusHealthy {
+			threshold = t.config.RecoveryThreshold
+		}
+		if c.consecutive >= threshold {
+			c.status = status
+			c.consecutive = 0
+		}
+	}
+
+	return c.status
+}
+
+// Snapshot returns the history of all components
+func (t *Tracker) Snapshot() map[string]ComponentHistory {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := make(map[string]ComponentHistory, len(t.components))
+	for name, c := range t.components {
+		results := make([]Result, len(c.results))
+		copy(results, c.results)
+
+		snapshot[name] = ComponentHistory{
+			Status:   c.status,
+			Flapping: t.isFlapping(results),
+			Results:  results,
+		}
+	}
+	return snapshot
+}
+
+// isFlapping reports whether the raw status changed at least FlapThreshold times
+func (t *Tracker) isFlapping(results []Result) bool {
+	if t.config.FlapThreshold <= 0 {
+		return false
+	}
+
+	changes := 0
+	for i := 1; i < len(results); i++ {
+		if results[i].Status != results[i-1].Status {
+			changes++
+		}
+	}
+	return changes >= t.config.FlapThreshold
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker_Hysteresis(t *testing.T) {
+	tracker := NewTracker(Config{HistorySize: 10, FailureThreshold: 3, RecoveryThreshold: 2})
+	errPing := errors.New("ping failed")
+
+	assert.Equal(t, StatusHealthy, tracker.Record("redis", StatusHealthy, nil))
+
+	// A single failure doesn't flip the status
+	assert.Equal(t, StatusHealthy, tracker.Record("redis", StatusUnhealthy, errPing))
+	assert.Equal(t, StatusHealthy, tracker.Record("redis", StatusHealthy, nil))
+
+	// Three consecutive failures do
+	tracker.Record("redis", StatusUnhealthy, errPing)
+	tracker.Record("redis", StatusUnhealthy, errPing)
+	assert.Equal(t, StatusUnhealthy, tracker.Record("redis", StatusUnhealthy, errPing))
+
+	// Moving between failing statuses is immediate
+	assert.Equal(t, StatusDegraded, tracker.Record("redis", StatusDegraded, nil))
+
+	// Recovery needs two consecutive passes
+	assert.Equal(t, StatusDegraded, tracker.Record("redis", StatusHealthy, nil))
+	assert.Equal(t, StatusHealthy, tracker.Record("redis", StatusHealthy, nil))
+}
+
+func TestTracker_Snapshot(t *testing.T) {
+	tracker := NewTracker(Config{HistorySize: 4, FlapThreshold: 3})
+
+	for i := 0; i < 6; i++ {
+		status := StatusHealthy
+		if i%2 == 1 {
+			status = StatusUnhealthy
+		}
+		tracker.Record("database", status, nil)
+	}
+
+	history := tracker.Snapshot()["database"]
+	assert.Len(t, history.Results, 4)
+	assert.True(t, history.Flapping)
+	// Without hysteresis thresholds the latest result is reported
+	assert.Equal(t, StatusUnhealthy, history.Status)
+}
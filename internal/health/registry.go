@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"sync"
+)
+
+// Criticality tells how a failing component affects the overall health
+type Criticality int
+
+const (
+	// Critical components failing make the server unhealthy, their degradation degrades it
+	Critical Criticality = iota
+	// NonCritical components failing only degrade the server, it can serve without them
+	NonCritical
+)
+
+// CheckResult is the outcome of a component check
+type CheckResult struct {
+	// Status is StatusHealthy, StatusDegraded or StatusUnhealthy
+	Status string
+	// Err is why the component isn't healthy, if known
+	Err error
+	// Details are reported next to the status, e.g. connection pool statistics
+	Details map[string]any
+}
+
+// Checker checks the health of a component
+type Checker interface {
+	Check(ctx context.Context) CheckResult
+}
+
+// CheckerFunc adapts a function to a Checker
+type CheckerFunc func(ctx context.Context) CheckResult
+
+// Check implements Checker
+func (f CheckerFunc) Check(ctx context.Context) CheckResult {
+	return f(ctx)
+}
+
+// Report is the health of the server and of each registered component
+type Report struct {
+	// Status is the overall status: unhealthy when a critical component is, degraded when any
+	// component isn't healthy
+	Status string
+	// Components are keyed by name, each holding its details, status, error and criticality
+	Components map[string]map[string]any
+}
+
+// registration is a registered component
+type registration struct {
+	name        string
+	criticality Criticality
+	checker     Checker
+}
+
+// Registry runs the health checks of the registered components, so a new dependency adds a
+// checker instead of changing the health endpoint. With a history tracker component
+// statuses are smoothed by its hysteresis.
+type Registry struct {
+	history *Tracker
+
+	mu         sync.RWMutex
+	components []registration
+}
+
+// NewRegistry creates an empty registry, history may be nil
+func NewRegistry(history *Tracker) *Registry {
+	return &Registry{history: history}
+}
+
+// Register adds a component checked under name, replacing one registered under the same name
+func (r *Registry) Register(name string, criticality Criticality, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.components {
+		if r.components[i].name == name {
+			r.components[i] = registration{name: name, criticality: criticality, checker: checker}
+			return
+		}
+	}
+	r.components = append(r.components, registration{name: name, criticality: criticality, checker: checker})
+}
+
+// Check runs the checks of all components concurrently and reports their health
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	components := make([]registration, len(r.components))
+	copy(components, r.components)
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(components))
+	var wg sync.WaitGroup
+	for i, component := range components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = component.checker.Check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusHealthy, Components: make(map[string]map[string]any, len(components))}
+	for i, component := range components {
+		result := results[i]
+		status := result.Status
+		if r.history != nil {
+			status = r.history.Record(component.name, result.Status, result.Err)
+		}
+
+		entry := make(map[string]any, len(result.Details)+3)
+		for key, value := range result.Details {
+			entry[key] = value
+		}
+		entry["status"] = status
+		entry["critical"] = component.criticality == Critical
+		// A failure suppressed by hysteresis is still worth showing
+		if result.Err != nil {
+			entry["error"] = result.Err.Error()
+		}
+		report.Components[component.name] = entry
+
+		switch {
+		case status == StatusHealthy:
+		case status == StatusUnhealthy && component.criticality == Critical:
+			report.Status = StatusUnhealthy
+		case report.Status == StatusHealthy:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
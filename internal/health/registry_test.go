@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func staticChecker(status string, err error) Checker {
+	return CheckerFunc(func(ctx context.Context) CheckResult {
+		return CheckResult{Status: status, Err: err, Details: map[string]any{"probe": "static"}}
+	})
+}
+
+func TestRegistry_Check(t *testing.T) {
+	registry := NewRegistry(nil)
+	assert.Equal(t, StatusHealthy, registry.Check(context.Background()).Status)
+
+	registry.Register("database", Critical, staticChecker(StatusHealthy, nil))
+	registry.Register("enrichment", NonCritical, staticChecker(StatusUnhealthy, errors.New("timeout")))
+
+	// A failing non-critical component only degrades the server
+	report := registry.Check(context.Background())
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.Equal(t, map[string]any{"probe": "static", "status": StatusUnhealthy, "critical": false, "error": "timeout"}, report.Components["enrichment"])
+	assert.Equal(t, map[string]any{"probe": "static", "status": StatusHealthy, "critical": true}, report.Components["database"])
+
+	// Degraded critical components degrade it too, failing ones make it unhealthy
+	registry.Register("database", Critical, staticChecker(StatusDegraded, nil))
+	assert.Equal(t, StatusDegraded, registry.Check(context.Background()).Status)
+
+	registry.Register("database", Critical, staticChecker(StatusUnhealthy, errors.New("refused")))
+	report = registry.Check(context.Background())
+	assert.Equal(t, StatusUnhealthy, report.Status)
+	assert.Len(t, report.Components, 2)
+}
+
+func TestRegistry_History(t *testing.T) {
+	history := NewTracker(Config{HistorySize: 5, FailureThreshold: 2, RecoveryThreshold: 1})
+	registry := NewRegistry(history)
+	registry.Register("healthy", Critical, staticChecker(StatusHealthy, nil))
+	registry.Check(context.Background())
+
+	// A single failure is smoothed away but its error still shown
+	registry.Register("healthy", Critical, staticChecker(StatusUnhealthy, errors.New("refused")))
+	report := registry.Check(context.Background())
+	assert.Equal(t, StatusHealthy, report.Status)
+	assert.Equal(t, "refused", report.Components["healthy"]["error"])
+
+	report = registry.Check(context.Background())
+	assert.Equal(t, StatusUnhealthy, report.Status)
+	assert.Len(t, history.Snapshot()["healthy"].Results, 3)
+}
+
+func TestFreshnessChecker(t *testing.T) {
+	snapshot := cache.Freshness{Built: true, Campaigns: 3, AgeSeconds: 10, TTLSeconds: 300}
+	checker := FreshnessChecker(func() cache.Freshness { return snapshot })
+
+	result := checker.Check(context.Background())
+	assert.Equal(t, StatusHealthy, result.Status)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, snapshot, result.Details["snapshot"])
+
+	snapshot.AgeSeconds, snapshot.Expired = 600, true
+	result = checker.Check(context.Background())
+	assert.Equal(t, StatusDegraded, result.Status)
+	assert.EqualError(t, result.Err, "campaigns not checked for 600s, longer than the 300s cache TTL")
+}
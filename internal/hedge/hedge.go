@@ -0,0 +1,65 @@
+package hedge
+
+import (
+	"context"
+	"time"
+)
+
+// Winners of a hedged call, used as metric labels
+const (
+	WinnerPrimary = "primary"
+	WinnerHedge   = "hedge"
+)
+
+// Recorder records hedged calls, metrics.CachedMetrics implements it
+type Recorder interface {
+	RecordHedgedRead(target, winner string)
+}
+
+// Do calls fn and, if it hasn't returned within delay, calls it a second time and returns
+// whichever call succeeds first, canceling the other. Errors are not hedged: a call failing
+// before the delay returns its error, and after the delay an error is only returned once
+// both calls failed. winner is empty unless a second call was sent and one succeeded.
+// A zero or negative delay disables hedging.
+func Do[T any](ctx context.Context, delay time.Duration, fn func(context.Context) (T, error)) (result T, winner string, err error) {
+	if delay <= 0 {
+		result, err = fn(ctx)
+		return result, "", err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		result T
+		err    error
+		winner string
+	}
+	// Buffered so the losing call never blocks after we returned
+	answers := make(chan answer, 2)
+	call := func(winner string) {
+		result, err := fn(ctx)
+		answers <- answer{result: result, err: err, winner: winner}
+	}
+
+	go call(WinnerPrimary)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case first := <-answers:
+		return first.result, "", first.err
+	case <-timer.C:
+		go call(WinnerHedge)
+	}
+
+	var last answer
+	for i := 0; i < 2; i++ {
+		last = <-answers
+		if last.err == nil {
+			return last.result, last.winner, nil
+		}
+	}
+	return last.result, "", last.err
+}
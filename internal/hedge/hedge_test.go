@@ -0,0 +1,77 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	t.Run("fast primary is not hedged", func(t *testing.T) {
+		var calls atomic.Int32
+		result, winner, err := Do(context.Background(), 50*time.Millisecond, func(ctx context.Context) (int, error) {
+			calls.Add(1)
+			return 1, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result)
+		assert.Empty(t, winner)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("slow primary loses to hedge", func(t *testing.T) {
+		var calls atomic.Int32
+		canceled := make(chan struct{})
+		result, winner, err := Do(context.Background(), 10*time.Millisecond, func(ctx context.Context) (int, error) {
+			if calls.Add(1) == 1 {
+				// The primary hangs until the hedge wins and cancels it
+				<-ctx.Done()
+				close(canceled)
+				return 0, ctx.Err()
+			}
+			return 2, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, result)
+		assert.Equal(t, WinnerHedge, winner)
+
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("primary call was not canceled")
+		}
+	})
+
+	t.Run("error before delay is returned", func(t *testing.T) {
+		var calls atomic.Int32
+		_, _, err := Do(context.Background(), 50*time.Millisecond, func(ctx context.Context) (int, error) {
+			calls.Add(1)
+			return 0, errors.New("boom")
+		})
+		assert.EqualError(t, err, "boom")
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("both failing returns error", func(t *testing.T) {
+		_, winner, err := Do(context.Background(), time.Millisecond, func(ctx context.Context) (int, error) {
+			time.Sleep(5 * time.Millisecond)
+			return 0, errors.New("boom")
+		})
+		assert.EqualError(t, err, "boom")
+		assert.Empty(t, winner)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		result, winner, err := Do(context.Background(), 0, func(ctx context.Context) (int, error) {
+			time.Sleep(5 * time.Millisecond)
+			return 3, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, result)
+		assert.Empty(t, winner)
+	})
+}
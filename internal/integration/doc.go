@@ -0,0 +1,7 @@
+// Package integration holds the tests exercising the Postgres and Redis code paths against real
+// instances: the Postgres repository, migrations, the hybrid cache with Redis enabled and the full
+// HTTP stack. They are behind the integration build tag and start throwaway containers through the
+// docker CLI, or use running instances given by INTEGRATION_POSTGRES_ADDR and INTEGRATION_REDIS_ADDR:
+//
+//	go test -tags integration ./internal/integration
+package integration
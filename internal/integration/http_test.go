@@ -0,0 +1,96 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPStack(t *testing.T) {
+	db, _ := newDatabase(t)
+
+	cacheConfig := redisCacheConfig()
+	cacheConfig.EnableMemory = true
+	cacheConfig.MemoryCacheSize = 100
+	hybridCache, err := cache.NewHybridCache(cacheConfig)
+	require.NoError(t, err)
+	require.NoError(t, hybridCache.InvalidateAll(context.Background()))
+	t.Cleanup(func() { hybridCache.Close() })
+
+	repo := repository.NewPostgresRepository(db)
+	cachedRepo := cache.NewCachedRepositoryWithStaleHandler(repo, hybridCache, time.Minute, nil)
+	endpoints := endpoint.MakeDeliveryEndpoints(service.NewDeliveryService(cachedRepo))
+	server := httptest.NewServer(transport.NewHTTPHandlerWithOptions(endpoints, log.NewNopLogger(), transport.HandlerOptions{
+		DB:        db,
+		Cache:     hybridCache,
+		Campaigns: repo.(service.CampaignStore),
+	}))
+	defer server.Close()
+
+	deliver := func(query string) (int, []string) {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/v1/delivery?" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var campaigns []models.CampaignResponse
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&campaigns))
+		}
+		ids := []string{}
+		for _, campaign := range campaigns {
+			ids = append(ids, campaign.CID)
+		}
+		return resp.StatusCode, ids
+	}
+	post := func(path, body string) int {
+		t.Helper()
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	resp, err := http.Get(server.URL + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Sample campaigns from the initial migration, served through the cache
+	status, ids := deliver("country=us&os=android&app=com.example.app")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"spotify"}, ids)
+	status, ids = deliver("country=in&os=android&app=com.example.app&state=gj")
+	require.Equal(t, http.StatusOK, status)
+	assert.ElementsMatch(t, []string{"duolingo", "gujarat-campaign", "multi-state-campaign"}, ids)
+
+	status, _ = deliver("country=usa&os=android&app=com.example.app")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Admin changes are visible right away, the cache is invalidated
+	require.Equal(t, http.StatusCreated, post("/v1/admin/campaigns",
+		`{"cid":"netflix","name":"Netflix","img":"https://img","cta":"Watch","rules":[{"dimension":"country","rule_type":"include","values":["us"]}]}`))
+	_, ids = deliver("country=us&os=android&app=com.example.app")
+	assert.ElementsMatch(t, []string{"spotify", "netflix"}, ids)
+
+	require.Equal(t, http.StatusOK, post("/v1/admin/campaigns/spotify/pause", ""))
+	require.Equal(t, http.StatusOK, post("/v1/admin/campaigns/netflix/pause", ""))
+	status, _ = deliver("country=us&os=android&app=com.example.app")
+	assert.Equal(t, http.StatusNoContent, status)
+}
@@ -0,0 +1,216 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/uuid"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/config"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/database"
+	"github.com/stretchr/testify/require"
+)
+
+// Credentials of the Postgres container, instances given by INTEGRATION_POSTGRES_ADDR must accept them
+const (
+	postgresUser     = "adbeacon"
+	postgresPassword = "adbeacon"
+)
+
+// migrationsDir holds the migrations applied to every test database
+var migrationsDir = filepath.Join("..", "..", "migrations")
+
+var (
+	// postgresConfig connects to the Postgres instance, tests use their own database on it
+	postgresConfig config.DatabaseConfig
+	// redisAddr is the address of the Redis instance
+	redisAddr string
+)
+
+func TestMain(m *testing.M) {
+	var cleanups []func()
+	cleanup := func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+
+	postgresAddr := os.Getenv("INTEGRATION_POSTGRES_ADDR")
+	if postgresAddr == "" {
+		addr, stop, err := startContainer("postgres:15-alpine", "5432/tcp",
+			"-e", "POSTGRES_USER="+postgresUser, "-e", "POSTGRES_PASSWORD="+postgresPassword, "-e", "POSTGRES_DB=postgres")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to start postgres, set INTEGRATION_POSTGRES_ADDR to use a running instance: %v\n", err)
+			os.Exit(1)
+		}
+		postgresAddr = addr
+		cleanups = append(cleanups, stop)
+	}
+
+	redisAddr = os.Getenv("INTEGRATION_REDIS_ADDR")
+	if redisAddr == "" {
+		addr, stop, err := startContainer("redis:7-alpine", "6379/tcp")
+		if err != nil {
+			cleanup()
+			fmt.Fprintf(os.Stderr, "failed to start redis, set INTEGRATION_REDIS_ADDR to use a running instance: %v\n", err)
+			os.Exit(1)
+		}
+		redisAddr = addr
+		cleanups = append(cleanups, stop)
+	}
+
+	host, port, err := net.SplitHostPort(postgresAddr)
+	if err == nil {
+		postgresConfig = config.DatabaseConfig{
+			Host:         host,
+			User:         postgresUser,
+			Password:     postgresPassword,
+			DBName:       "postgres",
+			SSLMode:      "disable",
+			MaxOpenConns: 10,
+			MaxIdleConns: 5,
+		}
+		postgresConfig.Port, err = strconv.Atoi(port)
+	}
+	if err == nil {
+		err = waitFor(30*time.Second, pingPostgres, pingRedis)
+	}
+	if err != nil {
+		cleanup()
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	cleanup()
+	os.Exit(code)
+}
+
+// startContainer runs image in the background with port published on a random host port,
+// it returns the host address of the port and a function removing the container
+func startContainer(image, port string, args ...string) (string, func(), error) {
+	runArgs := append([]string{"run", "--detach", "--rm", "--publish", "127.0.0.1::" + strings.TrimSuffix(port, "/tcp")}, args...)
+	out, err := docker(append(runArgs, image)...)
+	if err != nil {
+		return "", nil, err
+	}
+	id := strings.TrimSpace(out)
+	stop := func() { docker("rm", "--force", "--volumes", id) }
+
+	out, err = docker("port", id, port)
+	if err != nil {
+		stop()
+		return "", nil, err
+	}
+	// docker port prints one line per address family, the first one is the IPv4 binding
+	addr := strings.TrimSpace(strings.SplitN(out, "\n", 2)[0])
+	return addr, stop, nil
+}
+
+func docker(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("docker %s: %w: %s", args[0], err, message)
+		}
+		return "", fmt.Errorf("docker %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// waitFor retries each check until it succeeds, failing once timeout elapsed
+func waitFor(timeout time.Duration, checks ...func() error) error {
+	deadline := time.Now().Add(timeout)
+	for _, check := range checks {
+		for {
+			err := check()
+			if err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("services not ready after %s: %w", timeout, err)
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+func pingPostgres() error {
+	db, err := database.NewConnection(postgresConfig)
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+func pingRedis() error {
+	hc, err := cache.NewHybridCache(redisCacheConfig())
+	if err != nil {
+		return err
+	}
+	return hc.Close()
+}
+
+// redisCacheConfig returns a Redis only cache configuration, so reads can only be served by Redis
+func redisCacheConfig() cache.CacheConfig {
+	return cache.CacheConfig{
+		DefaultTTL:      time.Minute,
+		RedisAddr:       redisAddr,
+		RedisMaxRetries: -1,
+		EnableRedis:     true,
+		RefreshInterval: 30 * time.Second,
+	}
+}
+
+// newDatabase creates a database of its own for the test, with the migrations applied, and drops it afterwards
+func newDatabase(t *testing.T) (*database.DB, config.DatabaseConfig) {
+	t.Helper()
+
+	cfg := postgresConfig
+	cfg.DBName = "it_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:16]
+
+	db, cleanup, err := database.Initialize(cfg, migrationsDir, log.NewNopLogger())
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		cleanup()
+		admin, err := database.NewConnection(postgresConfig)
+		if err != nil {
+			return
+		}
+		defer admin.Close()
+		admin.ExecContext(context.Background(), "DROP DATABASE IF EXISTS "+cfg.DBName+" WITH (FORCE)")
+	})
+	return db, cfg
+}
+
+// newRedisCache creates a Redis only cache and clears it when the test ends
+func newRedisCache(t *testing.T) *cache.HybridCache {
+	t.Helper()
+
+	hc, err := cache.NewHybridCache(redisCacheConfig())
+	require.NoError(t, err)
+	require.NoError(t, hc.InvalidateAll(context.Background()))
+
+	t.Cleanup(func() {
+		hc.InvalidateAll(context.Background())
+		hc.Close()
+	})
+	return hc
+}
@@ -0,0 +1,267 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/blocklist"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/config"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/database"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reporting"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	_, cfg := newDatabase(t)
+
+	manager, err := database.OpenMigrationManager(cfg, migrationsDir, log.NewNopLogger())
+	require.NoError(t, err)
+
+	version, dirty, err := manager.Version()
+	require.NoError(t, err)
+	assert.EqualValues(t, 9, version)
+	assert.False(t, dirty)
+
+	// The schema can be torn down and rebuilt
+	require.NoError(t, manager.Down())
+	require.NoError(t, manager.Up())
+	require.NoError(t, database.ConfirmMigrations(mustConnect(t, cfg), cfg, migrationsDir, log.NewNopLogger()))
+}
+
+func TestPostgresRepository(t *testing.T) {
+	db, _ := newDatabase(t)
+	ctx := context.Background()
+	repo := repository.NewPostgresRepository(db)
+	store := repo.(service.CampaignStore)
+
+	// The initial migration inserts the sample campaigns with their rules
+	campaigns, err := repo.GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	spotify := findCampaign(campaigns, "spotify")
+	require.NotNil(t, spotify)
+	require.Len(t, spotify.Rules, 1)
+	assert.Equal(t, models.DimensionCountry, spotify.Rules[0].Dimension)
+	assert.Equal(t, []string{"US", "Canada"}, spotify.Rules[0].Values)
+
+	netflix := models.CampaignWithRules{
+		Campaign: models.Campaign{ID: "netflix", Name: "Netflix", ImageURL: "https://img", CTA: "Watch", Status: models.StatusActive},
+		Rules: []models.TargetingRule{
+			{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"de"}},
+			{Dimension: models.DimensionOS, RuleType: models.RuleTypeExclude, Values: []string{"ios"}},
+		},
+	}
+	require.NoError(t, store.CreateCampaign(ctx, netflix))
+	assert.ErrorIs(t, store.CreateCampaign(ctx, netflix), service.ErrCampaignExists)
+
+	campaigns, err = repo.GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	created := findCampaign(campaigns, "netflix")
+	require.NotNil(t, created)
+	assert.Len(t, created.Rules, 2)
+
+	// Streams page through the same campaigns in the same order
+	var streamed []models.CampaignWithRules
+	batches := 0
+	require.NoError(t, repo.(service.CampaignStreamer).StreamActiveCampaignsWithRules(ctx, 2, func(batch []models.CampaignWithRules) error {
+		assert.LessOrEqual(t, len(batch), 2)
+		streamed = append(streamed, batch...)
+		batches++
+		return nil
+	}))
+	assert.Equal(t, campaigns, streamed)
+	assert.Equal(t, (len(campaigns)+1)/2, batches)
+	assert.Nil(t, created.StartsAt)
+	assert.Zero(t, created.DeliveryBudget)
+
+	// Schedules and budgets are stored with the campaign
+	endsAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	scheduled := models.CampaignWithRules{Campaign: models.Campaign{ID: "scheduled", Name: "Scheduled", ImageURL: "https://img", CTA: "Go", Status: models.StatusInactive, EndsAt: &endsAt, DeliveryBudget: 500}}
+	require.NoError(t, store.CreateCampaign(ctx, scheduled))
+	listed, err := store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	stored := findCampaign(listed, "scheduled")
+	require.NotNil(t, stored)
+	require.NotNil(t, stored.EndsAt)
+	assert.True(t, endsAt.Equal(*stored.EndsAt))
+	assert.Equal(t, int64(500), stored.DeliveryBudget)
+
+	// Staged rollouts change the traffic percentage of existing campaigns
+	require.NoError(t, store.SetCampaignTrafficPct(ctx, "scheduled", 20))
+	assert.ErrorIs(t, store.SetCampaignTrafficPct(ctx, "unknown", 20), service.ErrCampaignNotFound)
+	listed, err = store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 20, findCampaign(listed, "scheduled").TrafficPct)
+
+	// Categories are stored with created campaigns and can be changed
+	ride := models.CampaignWithRules{Campaign: models.Campaign{ID: "uber", Name: "Uber", ImageURL: "https://img", CTA: "Ride", Status: models.StatusActive, Category: "ride_hailing"}}
+	require.NoError(t, store.CreateCampaign(ctx, ride))
+	require.NoError(t, store.SetCampaignCategory(ctx, "scheduled", "ride_hailing"))
+	assert.ErrorIs(t, store.SetCampaignCategory(ctx, "unknown", "ride_hailing"), service.ErrCampaignNotFound)
+	listed, err = store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "ride_hailing", findCampaign(listed, "uber").Category)
+	assert.Equal(t, "ride_hailing", findCampaign(listed, "scheduled").Category)
+
+	// Images can be changed
+	require.NoError(t, store.SetCampaignImage(ctx, "scheduled", "https://cdn.example.com/scheduled.png"))
+	assert.ErrorIs(t, store.SetCampaignImage(ctx, "unknown", ""), service.ErrCampaignNotFound)
+	listed, err = store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/scheduled.png", findCampaign(listed, "scheduled").ImageURL)
+
+	// Paused campaigns are no longer active but still listed
+	require.NoError(t, store.SetCampaignStatus(ctx, "netflix", models.StatusInactive))
+	assert.ErrorIs(t, store.SetCampaignStatus(ctx, "unknown", models.StatusInactive), service.ErrCampaignNotFound)
+	// Bulk changes apply to every campaign or none
+	assert.ErrorIs(t, store.SetCampaignStatuses(ctx, []string{"netflix", "unknown"}, models.StatusActive), service.ErrCampaignNotFound)
+	require.NoError(t, store.SetCampaignStatuses(ctx, []string{"netflix"}, models.StatusInactive))
+
+	campaigns, err = repo.GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, findCampaign(campaigns, "netflix"))
+
+	all, err := store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	paused := findCampaign(all, "netflix")
+	require.NotNil(t, paused)
+	assert.Equal(t, models.StatusInactive, paused.Status)
+
+	// The migration recorded the sample rules as version 1, created campaigns start there too
+	versions, err := store.ListRuleVersions(ctx, "spotify")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, []string{"US", "Canada"}, versions[0].Rules[0].Values)
+
+	// Rule changes are numbered in order and leave the status change time alone
+	changed, err := store.SetCampaignRules(ctx, models.RuleSetVersion{
+		CampaignID: "netflix",
+		Rules:      []models.TargetingRule{{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"de", "at"}}},
+		Author:     "alice",
+		Comment:    "add Austria",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, changed.Version)
+	versions, err = store.ListRuleVersions(ctx, "netflix")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "alice", versions[0].Author)
+	assert.Len(t, versions[1].Rules, 2)
+
+	all, err = store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	updated := findCampaign(all, "netflix")
+	require.Len(t, updated.Rules, 1)
+	assert.Equal(t, []string{"de", "at"}, updated.Rules[0].Values)
+	assert.Equal(t, paused.UpdatedAt, updated.UpdatedAt)
+
+	_, err = store.SetCampaignRules(ctx, models.RuleSetVersion{CampaignID: "unknown"})
+	assert.ErrorIs(t, err, service.ErrCampaignNotFound)
+	_, err = store.ListRuleVersions(ctx, "unknown")
+	assert.ErrorIs(t, err, service.ErrCampaignNotFound)
+}
+
+func TestPostgresDeliveryStats(t *testing.T) {
+	db, _ := newDatabase(t)
+	ctx := context.Background()
+	store := repository.NewPostgresRepository(db).(reporting.Store)
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rows := []reporting.Row{
+		{Hour: day.Add(10 * time.Hour), CampaignID: "spotify", Country: "US", Deliveries: 5, Impressions: 4, Clicks: 1},
+		{Hour: day.Add(11 * time.Hour), CampaignID: "duolingo", Country: "Germany", Deliveries: 2},
+		{Hour: day.Add(25 * time.Hour), CampaignID: "spotify", Country: "US", Deliveries: 1},
+	}
+	require.NoError(t, store.AddDeliveryStats(ctx, rows))
+	// Adding the same rows again adds to their counts
+	require.NoError(t, store.AddDeliveryStats(ctx, rows[:1]))
+
+	report, err := store.QueryDeliveryStats(ctx, reporting.Query{From: day, To: day.Add(48 * time.Hour), GroupBy: []string{"day", "campaign"}})
+	require.NoError(t, err)
+	assert.Equal(t, []reporting.Row{
+		{Hour: day, CampaignID: "duolingo", Deliveries: 2},
+		{Hour: day, CampaignID: "spotify", Deliveries: 10, Impressions: 8, Clicks: 2},
+		{Hour: day.Add(24 * time.Hour), CampaignID: "spotify", Deliveries: 1},
+	}, report)
+
+	report, err = store.QueryDeliveryStats(ctx, reporting.Query{From: day, To: day.Add(11 * time.Hour), CampaignID: "spotify"})
+	require.NoError(t, err)
+	assert.Equal(t, []reporting.Row{{Deliveries: 10, Impressions: 8, Clicks: 2}}, report)
+
+	// Nothing in range, no rows
+	report, err = store.QueryDeliveryStats(ctx, reporting.Query{From: day.Add(-time.Hour), To: day})
+	require.NoError(t, err)
+	assert.Empty(t, report)
+}
+
+func TestPostgresBlocklist(t *testing.T) {
+	db, _ := newDatabase(t)
+	ctx := context.Background()
+	store := repository.NewPostgresRepository(db).(blocklist.Store)
+
+	app, err := store.AddBlocklistEntry(ctx, blocklist.Entry{Kind: blocklist.KindApp, Value: "com.example.app", Comment: "fraudulent installs"})
+	require.NoError(t, err)
+	assert.NotZero(t, app.ID)
+	assert.False(t, app.CreatedAt.IsZero())
+	_, err = store.AddBlocklistEntry(ctx, blocklist.Entry{Kind: blocklist.KindIPRange, Value: "203.0.113.0/24"})
+	require.NoError(t, err)
+	_, err = store.AddBlocklistEntry(ctx, blocklist.Entry{Kind: blocklist.KindApp, Value: "com.example.app"})
+	assert.ErrorIs(t, err, blocklist.ErrEntryExists)
+
+	entries, err := store.ListBlocklist(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "fraudulent installs", entries[0].Comment)
+	assert.Equal(t, blocklist.KindIPRange, entries[1].Kind)
+
+	require.NoError(t, store.RemoveBlocklistEntry(ctx, app.ID))
+	assert.ErrorIs(t, store.RemoveBlocklistEntry(ctx, app.ID), blocklist.ErrEntryNotFound)
+	entries, err = store.ListBlocklist(ctx)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestSeedCampaigns(t *testing.T) {
+	db, _ := newDatabase(t)
+	ctx := context.Background()
+
+	campaigns, err := repository.NewMockRepository().GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	campaigns = append(campaigns, models.CampaignWithRules{
+		Campaign: models.Campaign{ID: "seeded", Name: "Seeded", ImageURL: "https://img", CTA: "Go", Status: models.StatusActive},
+		Rules:    []models.TargetingRule{{Dimension: models.DimensionApp, RuleType: models.RuleTypeInclude, Values: []string{"com.example.app"}}},
+	})
+
+	// The sample campaigns already exist from the initial migration
+	inserted, err := repository.SeedCampaigns(ctx, db, campaigns)
+	require.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+
+	inserted, err = repository.SeedCampaigns(ctx, db, campaigns)
+	require.NoError(t, err)
+	assert.Zero(t, inserted)
+}
+
+func mustConnect(t *testing.T, cfg config.DatabaseConfig) *database.DB {
+	t.Helper()
+	db, err := database.NewConnection(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func findCampaign(campaigns []models.CampaignWithRules, id string) *models.CampaignWithRules {
+	for i := range campaigns {
+		if campaigns[i].ID == id {
+			return &campaigns[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHybridCacheWithRedis(t *testing.T) {
+	ctx := context.Background()
+	writer := newRedisCache(t)
+	// A second instance stands in for another server sharing the Redis cache
+	reader := newRedisCache(t)
+
+	_, err := reader.GetActiveCampaigns(ctx)
+	assert.ErrorIs(t, err, cache.ErrCacheMiss)
+
+	campaigns, err := repository.NewMockRepository().GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	require.NoError(t, writer.SetActiveCampaigns(ctx, campaigns, time.Minute))
+	require.NoError(t, writer.SetCampaignIndex(ctx, models.DimensionCountry, "us", []string{"spotify"}, time.Minute))
+
+	cached, err := reader.GetActiveCampaigns(ctx)
+	require.NoError(t, err)
+	require.Len(t, cached, len(campaigns))
+	assert.Equal(t, campaigns[0].ID, cached[0].ID)
+	assert.Equal(t, campaigns[0].Rules[0].Values, cached[0].Rules[0].Values)
+
+	ids, err := reader.GetCampaignIndex(ctx, models.DimensionCountry, "us")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spotify"}, ids)
+
+	health := reader.HealthCheck(ctx)
+	assert.True(t, health.Redis.Connected)
+	assert.Equal(t, redisAddr, health.Redis.Address)
+
+	// Invalidation is shared as well
+	require.NoError(t, writer.InvalidateAll(ctx))
+	_, err = reader.GetActiveCampaigns(ctx)
+	assert.ErrorIs(t, err, cache.ErrCacheMiss)
+	_, err = reader.GetCampaignIndex(ctx, models.DimensionCountry, "us")
+	assert.ErrorIs(t, err, cache.ErrCacheMiss)
+}
+
+// failingRepository fails every read, so campaigns can only come from the cache
+type failingRepository struct{}
+
+func (failingRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestCachedRepositoryWithPostgresAndRedis(t *testing.T) {
+	db, _ := newDatabase(t)
+	ctx := context.Background()
+
+	cachedRepo := cache.NewCachedRepositoryWithStaleHandler(repository.NewPostgresRepository(db), newRedisCache(t), time.Minute, nil)
+	campaigns, err := cachedRepo.GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, campaigns)
+	require.NoError(t, cachedRepo.Flush(ctx))
+
+	// Another instance without a working database is served from Redis
+	otherRepo := cache.NewCachedRepositoryWithStaleHandler(failingRepository{}, newRedisCache(t), time.Minute, nil)
+	cached, err := otherRepo.GetActiveCampaignsWithRules(ctx)
+	require.NoError(t, err)
+	assert.Len(t, cached, len(campaigns))
+
+	matched, err := otherRepo.GetCampaignsByRequest(ctx, models.DeliveryRequest{Country: "us", OS: "android", App: "com.example.app"})
+	require.NoError(t, err)
+	assert.NotNil(t, findCampaign(matched, "spotify"))
+}
@@ -0,0 +1,40 @@
+package loadshed
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Descriptors for the load shedder collector
+var (
+	probabilityDesc = prometheus.NewDesc(
+		"adbeacon_load_shed_probability",
+		"Fraction of delivery requests currently being shed",
+		nil, nil,
+	)
+	latencyDesc = prometheus.NewDesc(
+		"adbeacon_load_shed_latency_seconds",
+		"Smoothed delivery latency used as the load shedding signal",
+		nil, nil,
+	)
+	cpuDesc = prometheus.NewDesc(
+		"adbeacon_load_shed_cpu_utilization_ratio",
+		"Process CPU utilization relative to GOMAXPROCS used as the load shedding signal",
+		nil, nil,
+	)
+)
+
+// Describe implements prometheus.Collector
+func (s *Shedder) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probabilityDesc
+	ch <- latencyDesc
+	ch <- cpuDesc
+}
+
+// Collect implements prometheus.Collector
+func (s *Shedder) Collect(ch chan<- prometheus.Metric) {
+	latency, cpu := s.signals()
+
+	ch <- prometheus.MustNewConstMetric(probabilityDesc, prometheus.GaugeValue, s.Probability())
+	ch <- prometheus.MustNewConstMetric(latencyDesc, prometheus.GaugeValue, latency.Seconds())
+	ch <- prometheus.MustNewConstMetric(cpuDesc, prometheus.GaugeValue, cpu)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package loadshed
+
+// newCPUSampler returns a sampler that always reports idle, CPU based shedding
+// is only supported on unix systems
+func newCPUSampler() func() float64 {
+	return func() float64 { return 0 }
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package loadshed
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// newCPUSampler returns a function reporting the process CPU utilization since its previous call
+func newCPUSampler() func() float64 {
+	lastCPU := processCPUTime()
+	lastWall := time.Now()
+
+	return func() float64 {
+		cpu := processCPUTime()
+		now := time.Now()
+
+		wall := now.Sub(lastWall)
+		used := cpu - lastCPU
+		lastCPU, lastWall = cpu, now
+
+		if wall <= 0 {
+			return 0
+		}
+		return float64(used) / (float64(wall) * float64(runtime.GOMAXPROCS(0)))
+	}
+}
+
+// processCPUTime returns the user and system CPU time consumed by the process
+func processCPUTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
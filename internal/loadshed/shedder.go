@@ -0,0 +1,131 @@
+package loadshed
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// smoothing is the weight of the latest interval in the latency moving average
+const smoothing = 0.5
+
+// Config holds the load shedding thresholds, a zero threshold disables that signal
+type Config struct {
+	// Interval is how often the shed probability is recomputed
+	Interval time.Duration
+	// LatencyThreshold is the smoothed request latency above which requests are shed
+	LatencyThreshold time.Duration
+	// CPUThreshold is the process CPU utilization (0-1 of GOMAXPROCS cores) above which requests are shed
+	CPUThreshold float64
+	// MaxShedFraction caps the fraction of requests shed, so some traffic keeps
+	// flowing and the latency signal can recover
+	MaxShedFraction float64
+}
+
+// Shedder decides which requests to reject under overload. The shed fraction grows
+// linearly with how far the worst signal is above its threshold: at 1.5x the latency
+// threshold half the requests are shed.
+type Shedder struct {
+	config Config
+
+	// Latency of admitted requests since the last update
+	latencySum   atomic.Int64
+	latencyCount atomic.Int64
+
+	// probability is the current shed fraction as float64 bits
+	probability atomic.Uint64
+
+	mu      sync.Mutex
+	latency time.Duration
+	cpu     float64
+
+	// Overridable for tests
+	cpuUsage func() float64
+	random   func() float64
+}
+
+// New creates a new load shedder, call Run to start adapting the shed probability
+func New(config Config) *Shedder {
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.MaxShedFraction <= 0 || config.MaxShedFraction > 1 {
+		config.MaxShedFraction = 1
+	}
+
+	return &Shedder{
+		config:   config,
+		cpuUsage: newCPUSampler(),
+		random:   rand.Float64,
+	}
+}
+
+// Observe records the latency of an admitted request
+func (s *Shedder) Observe(latency time.Duration) {
+	s.latencySum.Add(int64(latency))
+	s.latencyCount.Add(1)
+}
+
+// Shed reports whether the current request should be rejected
+func (s *Shedder) Shed() bool {
+	probability := s.Probability()
+	return probability > 0 && s.random() < probability
+}
+
+// Probability returns the current fraction of requests being shed
+func (s *Shedder) Probability() float64 {
+	return math.Float64frombits(s.probability.Load())
+}
+
+// Run recomputes the shed probability every interval until ctx is canceled
+func (s *Shedder) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.update()
+		}
+	}
+}
+
+// update folds the latest interval into the signals and recomputes the shed probability
+func (s *Shedder) update() {
+	sum := s.latencySum.Swap(0)
+	count := s.latencyCount.Swap(0)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Without traffic the latency decays towards zero so shedding stops
+	var average time.Duration
+	if count > 0 {
+		average = time.Duration(sum / count)
+	}
+	s.latency = time.Duration(smoothing*float64(average) + (1-smoothing)*float64(s.latency))
+	s.cpu = s.cpuUsage()
+
+	overload := 0.0
+	if s.config.LatencyThreshold > 0 {
+		overload = math.Max(overload, float64(s.latency)/float64(s.config.LatencyThreshold))
+	}
+	if s.config.CPUThreshold > 0 {
+		overload = math.Max(overload, s.cpu/s.config.CPUThreshold)
+	}
+
+	probability := math.Min(math.Max(overload-1, 0), s.config.MaxShedFraction)
+	s.probability.Store(math.Float64bits(probability))
+}
+
+// signals returns the smoothed latency and the last CPU utilization sample
+func (s *Shedder) signals() (time.Duration, float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latency, s.cpu
+}
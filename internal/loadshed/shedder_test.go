@@ -0,0 +1,64 @@
+package loadshed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestShedder(config Config, cpu *float64) *Shedder {
+	s := New(config)
+	s.cpuUsage = func() float64 { return *cpu }
+	return s
+}
+
+func TestShedderLatency(t *testing.T) {
+	cpu := 0.0
+	s := newTestShedder(Config{LatencyThreshold: 100 * time.Millisecond, MaxShedFraction: 0.9}, &cpu)
+
+	// Below the threshold nothing is shed
+	s.Observe(50 * time.Millisecond)
+	s.update()
+	assert.Zero(t, s.Probability())
+	assert.False(t, s.Shed())
+
+	// Smoothed latency of 137.5ms is 1.375x the threshold, 37.5% of requests are shed
+	s.Observe(250 * time.Millisecond)
+	s.update()
+	assert.InDelta(t, 0.375, s.Probability(), 0.001)
+
+	s.random = func() float64 { return 0.3 }
+	assert.True(t, s.Shed())
+	s.random = func() float64 { return 0.4 }
+	assert.False(t, s.Shed())
+
+	// Far above the threshold the shed fraction is capped
+	for i := 0; i < 5; i++ {
+		s.Observe(time.Second)
+		s.update()
+	}
+	assert.InDelta(t, 0.9, s.Probability(), 0.001)
+
+	// Without traffic the latency decays and shedding stops
+	for i := 0; i < 10; i++ {
+		s.update()
+	}
+	assert.Zero(t, s.Probability())
+}
+
+func TestShedderCPU(t *testing.T) {
+	cpu := 0.5
+	s := newTestShedder(Config{CPUThreshold: 0.8}, &cpu)
+
+	s.update()
+	assert.Zero(t, s.Probability())
+
+	cpu = 1.0
+	s.update()
+	assert.InDelta(t, 0.25, s.Probability(), 0.001)
+
+	latency, utilization := s.signals()
+	assert.Zero(t, latency)
+	assert.Equal(t, 1.0, utilization)
+}
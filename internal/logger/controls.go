@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Supported log levels
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Controls holds logging settings that can be changed at runtime
+// Loggers and request logging middlewares sharing a Controls pick up changes immediately
+type Controls struct {
+	level      atomic.Value  // string
+	sampleRate atomic.Uint64 // float64 bits
+}
+
+// NewControls creates logging controls with the initial level and request log sample rate
+// An invalid level falls back to info and the sample rate is clamped to 0-1
+func NewControls(levelName string, sampleRate float64) *Controls {
+	c := &Controls{}
+	if err := c.SetLevel(levelName); err != nil {
+		c.level.Store(LevelInfo)
+	}
+	c.sampleRate.Store(math.Float64bits(math.Min(math.Max(sampleRate, 0), 1)))
+	return c
+}
+
+// Level returns the current minimum log level
+func (c *Controls) Level() string {
+	return c.level.Load().(string)
+}
+
+// SetLevel changes the minimum log level
+func (c *Controls) SetLevel(name string) error {
+	normalized, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	c.level.Store(normalized)
+	return nil
+}
+
+// SampleRate returns the fraction of successful requests that are logged
+func (c *Controls) SampleRate() float64 {
+	return math.Float64frombits(c.sampleRate.Load())
+}
+
+// SetSampleRate changes the fraction of successful requests that are logged
+func (c *Controls) SetSampleRate(rate float64) error {
+	if math.IsNaN(rate) || rate < 0 || rate > 1 {
+		return fmt.Errorf("sample rate must be between 0 and 1")
+	}
+	c.sampleRate.Store(math.Float64bits(rate))
+	return nil
+}
+
+// Sample reports whether a successful request should be logged under the current sample rate
+func (c *Controls) Sample() bool {
+	rate := c.SampleRate()
+	if rate >= 1 {
+		return true
+	}
+	return rand.Float64() < rate
+}
+
+// ParseLevel validates a level name and returns its canonical form
+func ParseLevel(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case LevelDebug:
+		return LevelDebug, nil
+	case "", LevelInfo:
+		return LevelInfo, nil
+	case LevelWarn, "warning":
+		return LevelWarn, nil
+	case LevelError:
+		return LevelError, nil
+	default:
+		return "", fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// dynamicLevelLogger filters by the level currently set in Controls
+// It keeps one go-kit filter per level and delegates to the active one
+type dynamicLevelLogger struct {
+	controls *Controls
+	filtered map[string]kitlog.Logger
+}
+
+// newDynamicLevelLogger wraps next with a level filter driven by controls
+func newDynamicLevelLogger(next kitlog.Logger, controls *Controls) kitlog.Logger {
+	filtered := make(map[string]kitlog.Logger, 4)
+	for _, name := range []string{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		filtered[name] = level.NewFilter(next, LevelOption(name))
+	}
+	return &dynamicLevelLogger{controls: controls, filtered: filtered}
+}
+
+// Log implements kitlog.Logger
+func (l *dynamicLevelLogger) Log(keyvals ...interface{}) error {
+	return l.filtered[l.controls.Level()].Log(keyvals...)
+}
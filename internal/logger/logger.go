@@ -1,20 +1,57 @@
 package logger
 
 import (
+	"io"
 	"os"
+	"strings"
 
 	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Supported output formats
+const (
+	FormatLogfmt = "logfmt"
+	FormatJSON   = "json"
 )
 
 type Config struct {
 	Service string
 	Version string
+	// Format is either "logfmt" (default) or "json"
+	Format string
+	// Level is the minimum level emitted: debug, info (default), warn or error
+	Level string
+	// Redaction hashes or drops personal data fields before they are written
+	Redaction RedactionConfig
+	// Controls makes the level adjustable at runtime, when set Level is ignored
+	Controls *Controls
 }
 
 // New creates a new structured logger using go-kit/log
 func New(config Config) kitlog.Logger {
-	// Using logfmt format, human readable and easy to parse by log aggregators like datadog, ELK stack etc.
-	logger := kitlog.NewLogfmtLogger(os.Stderr)
+	return NewWithWriter(config, os.Stderr)
+}
+
+// NewWithWriter creates a new structured logger writing to w
+func NewWithWriter(config Config, w io.Writer) kitlog.Logger {
+	var logger kitlog.Logger
+	switch strings.ToLower(config.Format) {
+	case FormatJSON:
+		// JSON output for log pipelines that don't parse logfmt
+		logger = kitlog.NewJSONLogger(kitlog.NewSyncWriter(w))
+	default:
+		// Using logfmt format, human readable and easy to parse by log aggregators like datadog, ELK stack etc.
+		logger = kitlog.NewLogfmtLogger(kitlog.NewSyncWriter(w))
+	}
+	// Drop log lines below the configured level
+	if config.Controls != nil {
+		logger = newDynamicLevelLogger(logger, config.Controls)
+	} else {
+		logger = level.NewFilter(logger, LevelOption(config.Level))
+	}
+	// Scrub personal data, fields bound via With below pass through this as well
+	logger = NewRedactingLogger(logger, config.Redaction)
 	// Add timestamp with UTC timezone
 	logger = kitlog.With(logger, "ts", kitlog.DefaultTimestampUTC)
 	// Add caller information, which is the file and line number of the code that called the logger
@@ -23,3 +60,17 @@ func New(config Config) kitlog.Logger {
 	logger = kitlog.With(logger, "service", config.Service, "version", config.Version)
 	return logger
 }
+
+// LevelOption maps a level name to a go-kit level filter, unknown names default to info
+func LevelOption(name string) level.Option {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return level.AllowDebug()
+	case "warn", "warning":
+		return level.AllowWarn()
+	case "error":
+		return level.AllowError()
+	default:
+		return level.AllowInfo()
+	}
+}
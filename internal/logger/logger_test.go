@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log/level"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_JSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewWithWriter(Config{Service: "adbeacon", Version: "test", Format: FormatJSON}, &buf)
+
+	level.Info(logger).Log("msg", "hello")
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "hello", line["msg"])
+	assert.Equal(t, "info", line["level"])
+	assert.Equal(t, "adbeacon", line["service"])
+}
+
+func TestNew_LevelFiltering(t *testing.T) {
+	tests := []struct {
+		level     string
+		wantDebug bool
+		wantInfo  bool
+		wantWarn  bool
+	}{
+		{level: "debug", wantDebug: true, wantInfo: true, wantWarn: true},
+		{level: "info", wantDebug: false, wantInfo: true, wantWarn: true},
+		{level: "warn", wantDebug: false, wantInfo: false, wantWarn: true},
+		{level: "error", wantDebug: false, wantInfo: false, wantWarn: false},
+		{level: "bogus", wantDebug: false, wantInfo: true, wantWarn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := NewWithWriter(Config{Level: tt.level}, &buf)
+
+			level.Debug(logger).Log("msg", "debug-line")
+			level.Info(logger).Log("msg", "info-line")
+			level.Warn(logger).Log("msg", "warn-line")
+
+			out := buf.String()
+			assert.Equal(t, tt.wantDebug, strings.Contains(out, "debug-line"))
+			assert.Equal(t, tt.wantInfo, strings.Contains(out, "info-line"))
+			assert.Equal(t, tt.wantWarn, strings.Contains(out, "warn-line"))
+		})
+	}
+}
+
+func TestNew_Redaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewWithWriter(Config{
+		Format: FormatJSON,
+		Redaction: RedactionConfig{
+			HashFields: []string{"client_ip"},
+			DropFields: []string{"user_agent"},
+			Salt:       "pepper",
+		},
+	}, &buf)
+
+	level.Info(logger).Log("msg", "access", "client_ip", "203.0.113.7", "user_agent", "curl/8.0")
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "access", line["msg"])
+	assert.NotContains(t, line, "user_agent")
+	require.Contains(t, line, "client_ip")
+	assert.NotEqual(t, "203.0.113.7", line["client_ip"])
+	assert.Len(t, line["client_ip"], 16)
+
+	// Hashes are stable so the same client can be correlated across lines
+	buf.Reset()
+	level.Info(logger).Log("msg", "again", "client_ip", "203.0.113.7")
+	var second map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &second))
+	assert.Equal(t, line["client_ip"], second["client_ip"])
+}
+
+func TestNew_RuntimeLevelControl(t *testing.T) {
+	var buf bytes.Buffer
+	controls := NewControls("info", 1)
+	logger := NewWithWriter(Config{Controls: controls}, &buf)
+
+	level.Debug(logger).Log("msg", "before")
+	assert.NotContains(t, buf.String(), "before")
+
+	require.NoError(t, controls.SetLevel("debug"))
+	level.Debug(logger).Log("msg", "after")
+	assert.Contains(t, buf.String(), "after")
+
+	assert.Error(t, controls.SetLevel("verbose"))
+	assert.Equal(t, LevelDebug, controls.Level())
+	assert.Error(t, controls.SetSampleRate(1.5))
+}
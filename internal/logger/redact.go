@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	kitlog "github.com/go-kit/log"
+)
+
+// RedactionConfig lists log fields holding personal data
+type RedactionConfig struct {
+	// HashFields are replaced by a salted hash, keeping them correlatable without exposing the value
+	HashFields []string
+	// DropFields are removed from log lines entirely
+	DropFields []string
+	// Salt is mixed into hashes so they can't be reversed with a lookup table
+	Salt string
+}
+
+// redactingLogger hashes or drops configured fields before passing log lines on
+type redactingLogger struct {
+	next kitlog.Logger
+	hash map[string]bool
+	drop map[string]bool
+	salt string
+}
+
+// NewRedactingLogger wraps next so configured fields are hashed or dropped
+// It returns next unchanged if no fields are configured
+func NewRedactingLogger(next kitlog.Logger, config RedactionConfig) kitlog.Logger {
+	if len(config.HashFields) == 0 && len(config.DropFields) == 0 {
+		return next
+	}
+
+	l := &redactingLogger{
+		next: next,
+		hash: make(map[string]bool, len(config.HashFields)),
+		drop: make(map[string]bool, len(config.DropFields)),
+		salt: config.Salt,
+	}
+	for _, field := range config.HashFields {
+		l.hash[field] = true
+	}
+	for _, field := range config.DropFields {
+		l.drop[field] = true
+	}
+	return l
+}
+
+// Log implements kitlog.Logger
+func (l *redactingLogger) Log(keyvals ...interface{}) error {
+	redacted := make([]interface{}, 0, len(keyvals))
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		var value interface{} = kitlog.ErrMissingValue
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+
+		switch {
+		case l.drop[key]:
+			continue
+		case l.hash[key]:
+			value = l.hashValue(value)
+		}
+
+		redacted = append(redacted, keyvals[i], value)
+	}
+	return l.next.Log(redacted...)
+}
+
+// hashValue returns a truncated salted SHA-256 of the value, empty values stay empty
+func (l *redactingLogger) hashValue(value interface{}) string {
+	s := fmt.Sprint(value)
+	if value == nil || s == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(l.salt + s))
+	return hex.EncodeToString(sum[:8])
+}
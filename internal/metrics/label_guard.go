@@ -0,0 +1,69 @@
+package metrics
+
+import "sync"
+
+// OverflowLabelValue replaces label values once a LabelGuard reaches its limit
+const OverflowLabelValue = "other"
+
+// LabelGuard caps the number of distinct values a label can take so that
+// customer-controlled values can't blow up the number of time series. Values are admitted
+// while there is room and kept for good, later ones are reported as OverflowLabelValue.
+// Allowlisted values are always kept and don't count toward the limit.
+type LabelGuard struct {
+	limit   int
+	allowed map[string]bool
+
+	mu   sync.RWMutex
+	seen map[string]struct{}
+}
+
+// NewLabelGuard creates a guard admitting at most limit distinct values besides the allowlist
+func NewLabelGuard(limit int, allowlist ...string) *LabelGuard {
+	allowed := make(map[string]bool, len(allowlist))
+	for _, v := range allowlist {
+		allowed[v] = true
+	}
+	return &LabelGuard{
+		limit:   limit,
+		allowed: allowed,
+		seen:    make(map[string]struct{}),
+	}
+}
+
+// Value returns v if it is allowlisted, already known or there is room for it, otherwise
+// OverflowLabelValue
+func (g *LabelGuard) Value(v string) string {
+	if g.allowed[v] {
+		return v
+	}
+	g.mu.RLock()
+	_, ok := g.seen[v]
+	full := len(g.seen) >= g.limit
+	g.mu.RUnlock()
+	if ok {
+		return v
+	}
+	// Once full, unknown values never take the write lock
+	if full {
+		return OverflowLabelValue
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.seen[v]; ok {
+		return v
+	}
+	if len(g.seen) >= g.limit {
+		return OverflowLabelValue
+	}
+	g.seen[v] = struct{}{}
+	return v
+}
+
+// Len returns the number of values tracked individually, allowlisted values included
+func (g *LabelGuard) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.seen) + len(g.allowed)
+}
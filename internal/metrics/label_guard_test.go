@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestLabelGuard(t *testing.T) {
+	guard := NewLabelGuard(2)
+
+	if got := guard.Value("a"); got != "a" {
+		t.Errorf("expected a, got %s", got)
+	}
+	if got := guard.Value("b"); got != "b" {
+		t.Errorf("expected b, got %s", got)
+	}
+	if got := guard.Value("c"); got != OverflowLabelValue {
+		t.Errorf("expected %s once the limit is reached, got %s", OverflowLabelValue, got)
+	}
+	// Known values keep their label after the limit is reached
+	if got := guard.Value("a"); got != "a" {
+		t.Errorf("expected a, got %s", got)
+	}
+}
+
+func TestLabelGuardAllowlist(t *testing.T) {
+	guard := NewLabelGuard(1, "com.partner")
+
+	guard.Value("a")
+	// Allowlisted values don't take the room of others
+	if got := guard.Value("com.partner"); got != "com.partner" {
+		t.Errorf("expected com.partner, got %s", got)
+	}
+	if got := guard.Len(); got != 2 {
+		t.Errorf("expected 2 tracked values, got %d", got)
+	}
+}
+
+func TestLabelGuardNeverEvicts(t *testing.T) {
+	guard := NewLabelGuard(2)
+	guard.Value("a")
+	guard.Value("b")
+
+	// Values seen however often once the guard is full stay bucketed, so the series of the
+	// admitted values are the only ones ever created
+	for i := 0; i < 100; i++ {
+		if got := guard.Value("frequent"); got != OverflowLabelValue {
+			t.Fatalf("expected %s for a value seen after the limit was reached, got %s", OverflowLabelValue, got)
+		}
+		if got := guard.Value(strconv.Itoa(i)); got != OverflowLabelValue {
+			t.Fatalf("expected %s for a one-off value, got %s", OverflowLabelValue, got)
+		}
+	}
+	if got := guard.Len(); got != 2 {
+		t.Errorf("expected 2 tracked values, got %d", got)
+	}
+	if got := guard.Value("b"); got != "b" {
+		t.Errorf("expected b, got %s", got)
+	}
+}
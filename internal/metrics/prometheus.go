@@ -1,10 +1,19 @@
 package metrics
 
 import (
+	"cmp"
+	"slices"
+	"sync/atomic"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
 )
 
+// QueryActiveCampaignsWithRules is the query label used for loading active campaigns with their rules
+const QueryActiveCampaignsWithRules = "active_campaigns_with_rules"
+
 // Metrics holds all the Prometheus metrics for our service
 type Metrics struct {
 	// Request counters
@@ -14,11 +23,88 @@ type Metrics struct {
 
 	// Business logic metrics
 	CampaignsDelivered *prometheus.CounterVec
-	DatabaseQueries    *prometheus.CounterVec
-	DatabaseErrors     *prometheus.CounterVec
+
+	// Fill metrics, a request is filled when at least one campaign matched
+	DeliveryRequests prometheus.Counter
+	NoFillRequests   *prometheus.CounterVec
+	FillRate         prometheus.GaugeFunc
+	deliveryTotal    atomic.Int64
+	deliveryFilled   atomic.Int64
+	DatabaseQueries  *prometheus.CounterVec
+	DatabaseErrors   *prometheus.CounterVec
+
+	// Per-query database metrics
+	DatabaseQueryDuration *prometheus.HistogramVec
+	DatabaseRowsReturned  *prometheus.HistogramVec
+
+	// Matching-stage metrics
+	MatchingCampaignsEvaluated *prometheus.HistogramVec
+	MatchingCandidateSetSize   prometheus.Histogram
+	MatchingDuration           *prometheus.HistogramVec
+	MatchingDimensionMatches   *prometheus.CounterVec
 
 	// Health check metrics
 	HealthCheckStatus *prometheus.GaugeVec
+
+	// Panics recovered by the HTTP recovery middleware
+	PanicsRecovered *prometheus.CounterVec
+
+	// Circuit breaker state (0 = closed, 1 = half-open, 2 = open) and stale cache serving
+	CircuitBreakerState  *prometheus.GaugeVec
+	StaleCampaignsServed prometheus.Counter
+
+	// Retries of failed repository reads
+	RepositoryRetries *prometheus.CounterVec
+
+	// Campaigns and admin changes exceeding the campaign limits, by limit and where they were refused
+	CampaignLimitsExceeded *prometheus.CounterVec
+
+	// Requests rejected before reaching the handler (concurrency limit, load shedding)
+	RequestsRejected *prometheus.CounterVec
+
+	// Hedged reads against Redis and Postgres by which attempt answered first
+	HedgedReads *prometheus.CounterVec
+
+	// Requests still in flight while shutting down
+	ShutdownRemainingRequests prometheus.Gauge
+
+	// Per-client (publisher or API key) metrics, only populated when client labels are enabled
+	ClientRequestsTotal      *prometheus.CounterVec
+	ClientCampaignsDelivered *prometheus.CounterVec
+
+	// Impressions and clicks reported to the tracking endpoints
+	TrackedEvents *prometheus.CounterVec
+
+	// Events passing through the event bus by outcome
+	BusEvents *prometheus.CounterVec
+
+	// Sampled delivery decisions by outcome
+	DecisionLogRecords *prometheus.CounterVec
+
+	// Campaign webhook deliveries by outcome
+	Webhooks *prometheus.CounterVec
+
+	// Fill rate and success rate anomaly alerts
+	AnomalyAlerts *prometheus.CounterVec
+	AnomalyFiring *prometheus.GaugeVec
+
+	// Delivery requests recognized as invalid traffic by filter
+	TrafficBlocked *prometheus.CounterVec
+
+	// Delivery requests by GDPR consent status
+	ConsentRequests *prometheus.CounterVec
+
+	// Match memo lookups by outcome (hit, miss, evicted)
+	MatchMemo *prometheus.CounterVec
+
+	// Delivery requests with a nonce by deduplication outcome
+	NonceRequests *prometheus.CounterVec
+
+	// Creative proxy requests by outcome
+	CreativeRequests *prometheus.CounterVec
+
+	// Active campaigns whose image failed its last check
+	BrokenCreatives prometheus.Gauge
 }
 
 // CachedMetrics wraps Metrics with pre-cached common metric combinations
@@ -44,13 +130,98 @@ type CachedMetrics struct {
 	dbTargetingRulesSelect prometheus.Counter
 	dbQueryError           prometheus.Counter
 
+	// Pre-cached per-query metrics for the active campaigns query
+	dbActiveCampaignsDuration prometheus.Observer
+	dbActiveCampaignsRows     prometheus.Observer
+
+	// Pre-cached matching metrics per source
+	matchingEvaluatedIndex    prometheus.Observer
+	matchingEvaluatedFullScan prometheus.Observer
+	matchingDurationIndex     prometheus.Observer
+	matchingDurationFullScan  prometheus.Observer
+
+	// Pre-cached match memo outcomes
+	matchMemoHit  prometheus.Counter
+	matchMemoMiss prometheus.Counter
+
 	// Pre-cached health check metrics
 	healthCheckDB    prometheus.Gauge
 	healthCheckCache prometheus.Gauge
+
+	// Caps the number of distinct client label values
+	clientGuard *LabelGuard
+	// Cap the number of distinct app, country and os label values on delivery and no-fill metrics
+	appGuard     *LabelGuard
+	countryGuard *LabelGuard
+	osGuard      *LabelGuard
+}
+
+// DefaultHTTPDurationBuckets extend the Prometheus default buckets with sub-10ms
+// boundaries so the delivery latency targets can be measured precisely
+var DefaultHTTPDurationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.0075, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultDatabaseDurationBuckets are the default buckets for per-query database durations
+var DefaultDatabaseDurationBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// nativeHistogramMaxBuckets caps the buckets of a native histogram, its resolution is reduced
+// beyond them
+const nativeHistogramMaxBuckets = 160
+
+// Options configures metric construction
+type Options struct {
+	// HTTPDurationBuckets are the bucket upper bounds of adbeacon_http_request_duration_seconds
+	HTTPDurationBuckets []float64
+	// DatabaseDurationBuckets are the bucket upper bounds of adbeacon_database_query_duration_seconds
+	DatabaseDurationBuckets []float64
+	// NativeHistogramBucketFactor also exposes the HTTP and database duration histograms as
+	// native histograms to scrapers asking for them, each bucket at most this factor wider than
+	// the previous one, e.g. 1.1. 0 exposes the classic buckets only.
+	NativeHistogramBucketFactor float64
+	// AppLabelLimit, CountryLabelLimit and OSLabelLimit cap the distinct values of the labels of
+	// the delivery and no-fill metrics, values seen once a limit is reached are reported as
+	// OverflowLabelValue
+	AppLabelLimit     int
+	CountryLabelLimit int
+	OSLabelLimit      int
+	// AppLabelAllowlist lists apps always reported under their own label, beyond AppLabelLimit
+	AppLabelAllowlist []string
+}
+
+// Default label limits of the delivery and no-fill metrics
+const (
+	DefaultAppLabelLimit     = 200
+	DefaultCountryLabelLimit = 250
+	DefaultOSLabelLimit      = 20
+)
+
+// DefaultOptions returns the default metric options
+func DefaultOptions() Options {
+	return Options{
+		HTTPDurationBuckets:     DefaultHTTPDurationBuckets,
+		DatabaseDurationBuckets: DefaultDatabaseDurationBuckets,
+		AppLabelLimit:           DefaultAppLabelLimit,
+		CountryLabelLimit:       DefaultCountryLabelLimit,
+		OSLabelLimit:            DefaultOSLabelLimit,
+	}
+}
+
+// normalizeBuckets returns sorted, deduplicated buckets, or fallback if none are given
+func normalizeBuckets(buckets, fallback []float64) []float64 {
+	if len(buckets) == 0 {
+		return fallback
+	}
+	sorted := slices.Clone(buckets)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
 }
 
 // NewPrometheusMetrics creates and registers all Prometheus metrics
 func NewPrometheusMetrics() *Metrics {
+	return NewPrometheusMetricsWithOptions(DefaultOptions())
+}
+
+// NewPrometheusMetricsWithOptions creates and registers all Prometheus metrics using the given options
+func NewPrometheusMetricsWithOptions(opts Options) *Metrics {
 	metrics := &Metrics{
 		// HTTP request metrics
 		HTTPRequestsTotal: promauto.NewCounterVec(
@@ -63,9 +234,11 @@ func NewPrometheusMetrics() *Metrics {
 
 		HTTPRequestDuration: promauto.NewHistogramVec(
 			prometheus.HistogramOpts{
-				Name:    "adbeacon_http_request_duration_seconds",
-				Help:    "HTTP request duration in seconds",
-				Buckets: prometheus.DefBuckets, // Standard buckets: 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10
+				Name:                           "adbeacon_http_request_duration_seconds",
+				Help:                           "HTTP request duration in seconds",
+				Buckets:                        normalizeBuckets(opts.HTTPDurationBuckets, DefaultHTTPDurationBuckets),
+				NativeHistogramBucketFactor:    opts.NativeHistogramBucketFactor,
+				NativeHistogramMaxBucketNumber: nativeHistogramMaxBuckets,
 			},
 			[]string{"method", "endpoint"},
 		),
@@ -87,6 +260,21 @@ func NewPrometheusMetrics() *Metrics {
 			[]string{"app", "country", "os"},
 		),
 
+		DeliveryRequests: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "adbeacon_delivery_requests_total",
+				Help: "Total number of successfully processed delivery requests",
+			},
+		),
+
+		NoFillRequests: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_delivery_no_fill_total",
+				Help: "Total number of delivery requests that matched no campaigns",
+			},
+			[]string{"country", "os", "app"},
+		),
+
 		DatabaseQueries: promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "adbeacon_database_queries_total",
@@ -103,6 +291,61 @@ func NewPrometheusMetrics() *Metrics {
 			[]string{"operation", "error_type"},
 		),
 
+		DatabaseQueryDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:                           "adbeacon_database_query_duration_seconds",
+				Help:                           "Database query duration in seconds by query name",
+				Buckets:                        normalizeBuckets(opts.DatabaseDurationBuckets, DefaultDatabaseDurationBuckets),
+				NativeHistogramBucketFactor:    opts.NativeHistogramBucketFactor,
+				NativeHistogramMaxBucketNumber: nativeHistogramMaxBuckets,
+			},
+			[]string{"query"},
+		),
+
+		DatabaseRowsReturned: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "adbeacon_database_rows_returned",
+				Help:    "Number of rows returned by database queries by query name",
+				Buckets: prometheus.ExponentialBuckets(1, 4, 8), // 1, 4, 16, ... 16384
+			},
+			[]string{"query"},
+		),
+
+		// Matching-stage metrics
+		MatchingCampaignsEvaluated: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "adbeacon_matching_campaigns_evaluated",
+				Help:    "Number of campaigns evaluated by the matcher per request",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 12), // 1 ... 2048
+			},
+			[]string{"source"},
+		),
+
+		MatchingCandidateSetSize: promauto.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "adbeacon_matching_candidate_set_size",
+				Help:    "Number of candidate campaigns returned by the index lookup per request",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+			},
+		),
+
+		MatchingDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "adbeacon_matching_duration_seconds",
+				Help:    "Time spent matching campaigns against a request in seconds",
+				Buckets: []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01},
+			},
+			[]string{"source"},
+		),
+
+		MatchingDimensionMatches: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_matching_dimension_matches_total",
+				Help: "Total number of matched campaigns by targeted dimension",
+			},
+			[]string{"dimension"},
+		),
+
 		// Health check metrics
 		HealthCheckStatus: promauto.NewGaugeVec(
 			prometheus.GaugeOpts{
@@ -111,14 +354,267 @@ func NewPrometheusMetrics() *Metrics {
 			},
 			[]string{"check_type"},
 		),
+
+		PanicsRecovered: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_panics_recovered_total",
+				Help: "Total number of panics recovered in HTTP handlers",
+			},
+			[]string{"endpoint"},
+		),
+
+		CircuitBreakerState: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "adbeacon_circuit_breaker_state",
+				Help: "Circuit breaker state (0 = closed, 1 = half-open, 2 = open)",
+			},
+			[]string{"name"},
+		),
+
+		StaleCampaignsServed: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "adbeacon_stale_campaigns_served_total",
+				Help: "Total number of times stale campaigns were served because the repository failed",
+			},
+		),
+
+		CampaignLimitsExceeded: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_campaign_limits_exceeded_total",
+				Help: "Total number of campaign limits exceeded by limit and source (load when dropped from the cache, admin when a change was refused)",
+			},
+			[]string{"limit", "source"},
+		),
+
+		RepositoryRetries: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_repository_retries_total",
+				Help: "Total number of repository read retries by error type",
+			},
+			[]string{"error_type"},
+		),
+
+		RequestsRejected: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_requests_rejected_total",
+				Help: "Total number of requests rejected before reaching the handler by reason",
+			},
+			[]string{"endpoint", "reason"},
+		),
+
+		HedgedReads: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_hedged_reads_total",
+				Help: "Total number of reads that sent a hedge request by target and winning attempt",
+			},
+			[]string{"target", "winner"},
+		),
+
+		ShutdownRemainingRequests: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "adbeacon_shutdown_remaining_requests",
+				Help: "Number of requests still in flight while the server drains for shutdown",
+			},
+		),
+
+		ClientRequestsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_client_requests_total",
+				Help: "Total number of HTTP requests by client (publisher or API key)",
+			},
+			[]string{"client", "endpoint", "status_code"},
+		),
+
+		ClientCampaignsDelivered: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_client_campaigns_delivered_total",
+				Help: "Total number of campaigns delivered by client (publisher or API key)",
+			},
+			[]string{"client"},
+		),
+
+		TrackedEvents: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_tracked_events_total",
+				Help: "Total number of tracked events by type (impression or click)",
+			},
+			[]string{"type"},
+		),
+
+		BusEvents: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_events_total",
+				Help: "Total number of events on the event bus by outcome (published, exported, dropped or failed)",
+			},
+			[]string{"outcome"},
+		),
+
+		DecisionLogRecords: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_decision_log_records_total",
+				Help: "Total number of sampled delivery decisions by outcome (written, dropped or failed)",
+			},
+			[]string{"outcome"},
+		),
+
+		Webhooks: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_webhooks_total",
+				Help: "Total number of campaign webhook deliveries by outcome (delivered, retried or dead_lettered)",
+			},
+			[]string{"outcome"},
+		),
+
+		AnomalyAlerts: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_anomaly_alerts_total",
+				Help: "Total number of fired anomaly alerts by metric (fill_rate or success_rate)",
+			},
+			[]string{"metric"},
+		),
+
+		AnomalyFiring: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "adbeacon_anomaly_firing",
+				Help: "Whether an anomaly alert is firing for the metric (1) or not (0)",
+			},
+			[]string{"metric"},
+		),
+
+		TrafficBlocked: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_traffic_blocked_total",
+				Help: "Total number of delivery requests recognized as invalid traffic by filter",
+			},
+			[]string{"filter"},
+		),
+
+		ConsentRequests: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_consent_requests_total",
+				Help: "Total number of delivery requests by GDPR consent status",
+			},
+			[]string{"status"},
+		),
+
+		NonceRequests: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_nonce_requests_total",
+				Help: "Total number of delivery requests with a nonce by deduplication outcome (first, replayed, in_progress, mismatch, store_error)",
+			},
+			[]string{"outcome"},
+		),
+
+		CreativeRequests: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_creative_requests_total",
+				Help: "Total number of creative proxy requests by outcome (hit, miss, revalidated, error)",
+			},
+			[]string{"outcome"},
+		),
+
+		BrokenCreatives: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "adbeacon_broken_creatives",
+				Help: "Number of active campaigns whose image failed its last check",
+			},
+		),
+
+		MatchMemo: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_match_memo_total",
+				Help: "Total number of match memo hits, misses and entries evicted to stay within its size",
+			},
+			[]string{"outcome"},
+		),
 	}
 
+	metrics.FillRate = promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "adbeacon_delivery_fill_rate",
+			Help: "Fraction of delivery requests that matched at least one campaign since process start",
+		},
+		metrics.fillRate,
+	)
+
 	return metrics
 }
 
+// fillRate returns the fraction of filled delivery requests, 1 before any request
+func (m *Metrics) fillRate() float64 {
+	total := m.deliveryTotal.Load()
+	if total == 0 {
+		return 1
+	}
+	return float64(m.deliveryFilled.Load()) / float64(total)
+}
+
+// DeliveryStats are the delivery request totals since startup
+type DeliveryStats struct {
+	Requests int64   `json:"requests"`
+	Filled   int64   `json:"filled"`
+	FillRate float64 `json:"fill_rate"`
+}
+
+// DeliveryStats returns the delivery request totals since startup
+func (m *Metrics) DeliveryStats() DeliveryStats {
+	return DeliveryStats{
+		Requests: m.deliveryTotal.Load(),
+		Filled:   m.deliveryFilled.Load(),
+		FillRate: m.fillRate(),
+	}
+}
+
+// NoFillCount is the number of delivery requests with a dimension value that matched no campaign
+type NoFillCount struct {
+	Value    string `json:"value"`
+	Requests int64  `json:"requests"`
+}
+
+// TopNoFill returns for each of the country, os and app dimensions the n values with the most
+// delivery requests that matched no campaign since startup, most first. Values beyond the label
+// limits are counted together as OverflowLabelValue.
+func (m *Metrics) TopNoFill(n int) map[string][]NoFillCount {
+	totals := map[string]map[string]int64{"country": {}, "os": {}, "app": {}}
+	collected := make(chan prometheus.Metric)
+	go func() {
+		m.NoFillRequests.Collect(collected)
+		close(collected)
+	}()
+	for metric := range collected {
+		var written dto.Metric
+		if err := metric.Write(&written); err != nil {
+			continue
+		}
+		for _, label := range written.GetLabel() {
+			if values, ok := totals[label.GetName()]; ok {
+				values[label.GetValue()] += int64(written.GetCounter().GetValue())
+			}
+		}
+	}
+
+	top := make(map[string][]NoFillCount, len(totals))
+	for dimension, values := range totals {
+		counts := make([]NoFillCount, 0, len(values))
+		for value, requests := range values {
+			counts = append(counts, NoFillCount{Value: value, Requests: requests})
+		}
+		slices.SortFunc(counts, func(a, b NoFillCount) int {
+			return cmp.Or(cmp.Compare(b.Requests, a.Requests), cmp.Compare(a.Value, b.Value))
+		})
+		top[dimension] = counts[:min(n, len(counts))]
+	}
+	return top
+}
+
 // NewCachedMetrics creates a new CachedMetrics with pre-cached common combinations
 func NewCachedMetrics() *CachedMetrics {
-	baseMetrics := NewPrometheusMetrics()
+	return NewCachedMetricsWithOptions(DefaultOptions())
+}
+
+// NewCachedMetricsWithOptions creates a new CachedMetrics using the given options
+func NewCachedMetricsWithOptions(opts Options) *CachedMetrics {
+	baseMetrics := NewPrometheusMetricsWithOptions(opts)
 
 	// Pre-cache common HTTP request combinations
 	deliveryRequests200, _ := baseMetrics.HTTPRequestsTotal.GetMetricWithLabelValues("GET", "/v1/delivery", "200")
@@ -137,12 +633,24 @@ func NewCachedMetrics() *CachedMetrics {
 	dbCampaignsSelect, _ := baseMetrics.DatabaseQueries.GetMetricWithLabelValues("select", "campaigns")
 	dbTargetingRulesSelect, _ := baseMetrics.DatabaseQueries.GetMetricWithLabelValues("select", "targeting_rules")
 	dbQueryError, _ := baseMetrics.DatabaseErrors.GetMetricWithLabelValues("select", "query_error")
+	dbActiveCampaignsDuration, _ := baseMetrics.DatabaseQueryDuration.GetMetricWithLabelValues(QueryActiveCampaignsWithRules)
+	dbActiveCampaignsRows, _ := baseMetrics.DatabaseRowsReturned.GetMetricWithLabelValues(QueryActiveCampaignsWithRules)
+
+	// Pre-cache matching sources
+	matchingEvaluatedIndex, _ := baseMetrics.MatchingCampaignsEvaluated.GetMetricWithLabelValues("index")
+	matchingEvaluatedFullScan, _ := baseMetrics.MatchingCampaignsEvaluated.GetMetricWithLabelValues("full_scan")
+	matchingDurationIndex, _ := baseMetrics.MatchingDuration.GetMetricWithLabelValues("index")
+	matchingDurationFullScan, _ := baseMetrics.MatchingDuration.GetMetricWithLabelValues("full_scan")
+
+	// Pre-cache match memo lookups
+	matchMemoHit, _ := baseMetrics.MatchMemo.GetMetricWithLabelValues("hit")
+	matchMemoMiss, _ := baseMetrics.MatchMemo.GetMetricWithLabelValues("miss")
 
 	// Pre-cache health check statuses
 	healthCheckDB, _ := baseMetrics.HealthCheckStatus.GetMetricWithLabelValues("database")
 	healthCheckCache, _ := baseMetrics.HealthCheckStatus.GetMetricWithLabelValues("cache")
 
-	return &CachedMetrics{
+	m := &CachedMetrics{
 		Metrics: baseMetrics,
 
 		// HTTP request caches
@@ -162,17 +670,109 @@ func NewCachedMetrics() *CachedMetrics {
 		dbTargetingRulesSelect: dbTargetingRulesSelect,
 		dbQueryError:           dbQueryError,
 
+		dbActiveCampaignsDuration: dbActiveCampaignsDuration,
+		dbActiveCampaignsRows:     dbActiveCampaignsRows,
+
+		// Matching caches
+		matchingEvaluatedIndex:    matchingEvaluatedIndex,
+		matchingEvaluatedFullScan: matchingEvaluatedFullScan,
+		matchingDurationIndex:     matchingDurationIndex,
+		matchingDurationFullScan:  matchingDurationFullScan,
+
+		// Match memo caches
+		matchMemoHit:  matchMemoHit,
+		matchMemoMiss: matchMemoMiss,
+
 		// Health check caches
 		healthCheckDB:    healthCheckDB,
 		healthCheckCache: healthCheckCache,
+
+		clientGuard:  NewLabelGuard(DefaultClientLabelLimit),
+		appGuard:     NewLabelGuard(opts.AppLabelLimit, opts.AppLabelAllowlist...),
+		countryGuard: NewLabelGuard(opts.CountryLabelLimit),
+		osGuard:      NewLabelGuard(opts.OSLabelLimit),
 	}
+
+	// The guards are read through m, SetClientLabelLimit replaces the client guard
+	for label, guard := range map[string]func() *LabelGuard{
+		"app":     func() *LabelGuard { return m.appGuard },
+		"country": func() *LabelGuard { return m.countryGuard },
+		"os":      func() *LabelGuard { return m.osGuard },
+		"client":  func() *LabelGuard { return m.clientGuard },
+	} {
+		promauto.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        "adbeacon_metric_label_values",
+				Help:        "Number of values of a label reported individually, the others are reported as other",
+				ConstLabels: prometheus.Labels{"label": label},
+			},
+			func() float64 { return float64(guard().Len()) },
+		)
+	}
+	return m
+}
+
+// DefaultClientLabelLimit is the default number of distinct clients tracked before
+// further clients are reported as OverflowLabelValue
+const DefaultClientLabelLimit = 100
+
+// SetClientLabelLimit sets the maximum number of distinct client label values
+// It must be called before any client metrics are recorded
+func (m *CachedMetrics) SetClientLabelLimit(limit int) {
+	m.clientGuard = NewLabelGuard(limit)
+}
+
+// RecordClientRequest records a request for a client, empty clients are ignored
+func (m *CachedMetrics) RecordClientRequest(client, endpoint, statusCode string) {
+	if client == "" {
+		return
+	}
+	m.ClientRequestsTotal.WithLabelValues(m.clientGuard.Value(client), endpoint, statusCode).Inc()
+}
+
+// RecordClientDelivery records delivered campaigns for a client, empty clients are ignored
+func (m *CachedMetrics) RecordClientDelivery(client string, count int) {
+	if client == "" {
+		return
+	}
+	m.ClientCampaignsDelivered.WithLabelValues(m.clientGuard.Value(client)).Add(float64(count))
 }
 
 // RecordHTTPRequest records an HTTP request with its duration and status
 // Uses fast path for common combinations, falls back to original method for others
 func (m *CachedMetrics) RecordHTTPRequest(method, endpoint, statusCode string, duration float64) {
+	m.durationObserver(method, endpoint).Observe(duration)
+	m.incHTTPRequests(method, endpoint, statusCode)
+}
+
+// RecordHTTPRequestWithExemplar records an HTTP request and attaches the trace ID
+// as an exemplar to the duration histogram
+func (m *CachedMetrics) RecordHTTPRequestWithExemplar(method, endpoint, statusCode string, duration float64, traceID string) {
+	observer := m.durationObserver(method, endpoint)
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok {
+		exemplarObserver.ObserveWithExemplar(duration, prometheus.Labels{"trace_id": traceID})
+	} else {
+		observer.Observe(duration)
+	}
+	m.incHTTPRequests(method, endpoint, statusCode)
+}
+
+// durationObserver returns the request duration observer, pre-cached for common endpoints
+func (m *CachedMetrics) durationObserver(method, endpoint string) prometheus.Observer {
+	if method == "GET" && endpoint == "/v1/delivery" {
+		return m.deliveryDuration
+	}
+
+	if method == "GET" && endpoint == "/health" {
+		return m.healthDuration
+	}
+
+	return m.HTTPRequestDuration.WithLabelValues(method, endpoint)
+}
+
+// incHTTPRequests increments the request counter, using pre-cached counters for common combinations
+func (m *CachedMetrics) incHTTPRequests(method, endpoint, statusCode string) {
 	if method == "GET" && endpoint == "/v1/delivery" {
-		m.deliveryDuration.Observe(duration)
 		switch statusCode {
 		case "200":
 			m.deliveryRequests200.Inc()
@@ -187,7 +787,6 @@ func (m *CachedMetrics) RecordHTTPRequest(method, endpoint, statusCode string, d
 	}
 
 	if method == "GET" && endpoint == "/health" {
-		m.healthDuration.Observe(duration)
 		switch statusCode {
 		case "200":
 			m.healthRequests200.Inc()
@@ -198,8 +797,8 @@ func (m *CachedMetrics) RecordHTTPRequest(method, endpoint, statusCode string, d
 		}
 	}
 
-	// Fallback to original method for uncommon combinations
-	m.Metrics.RecordHTTPRequest(method, endpoint, statusCode, duration)
+	// Fallback for uncommon combinations
+	m.HTTPRequestsTotal.WithLabelValues(method, endpoint, statusCode).Inc()
 }
 
 // IncRequestsInFlight increments the in-flight requests counter
@@ -265,6 +864,49 @@ func (m *CachedMetrics) RecordDatabaseError(operation, errorType string) {
 	m.Metrics.RecordDatabaseError(operation, errorType)
 }
 
+// ObserveDatabaseQuery records the latency and row count of a named query
+func (m *CachedMetrics) ObserveDatabaseQuery(query string, duration float64, rows int) {
+	if query == QueryActiveCampaignsWithRules {
+		m.dbActiveCampaignsDuration.Observe(duration)
+		m.dbActiveCampaignsRows.Observe(float64(rows))
+		return
+	}
+
+	// Fallback to original method
+	m.Metrics.ObserveDatabaseQuery(query, duration, rows)
+}
+
+// RecordMatching records matching-stage measurements for a single request
+// For the index source the evaluated count is also the candidate set size
+func (m *CachedMetrics) RecordMatching(source string, evaluated, matched int, duration time.Duration) {
+	switch source {
+	case "index":
+		m.matchingEvaluatedIndex.Observe(float64(evaluated))
+		m.matchingDurationIndex.Observe(duration.Seconds())
+		m.MatchingCandidateSetSize.Observe(float64(evaluated))
+		return
+	case "full_scan":
+		m.matchingEvaluatedFullScan.Observe(float64(evaluated))
+		m.matchingDurationFullScan.Observe(duration.Seconds())
+		return
+	}
+
+	// Fallback to original method
+	m.Metrics.RecordMatching(source, evaluated, matched, duration)
+}
+
+// RecordMatchMemo counts a match memo outcome, lookups use the pre-cached counters
+func (m *CachedMetrics) RecordMatchMemo(outcome string) {
+	switch outcome {
+	case "hit":
+		m.matchMemoHit.Inc()
+	case "miss":
+		m.matchMemoMiss.Inc()
+	default:
+		m.MatchMemo.WithLabelValues(outcome).Inc()
+	}
+}
+
 // SetHealthCheckStatus sets the health check status
 func (m *CachedMetrics) SetHealthCheckStatus(checkType string, healthy bool) {
 	status := 0.0
@@ -285,10 +927,26 @@ func (m *CachedMetrics) SetHealthCheckStatus(checkType string, healthy bool) {
 	m.Metrics.SetHealthCheckStatus(checkType, healthy)
 }
 
-// RecordCampaignDelivery records a campaign delivery
+// RecordCampaignDelivery records a campaign delivery, rare apps, countries and OSes beyond the
+// label limits are reported as OverflowLabelValue
 // This method doesn't need caching as it has many unique combinations
 func (m *CachedMetrics) RecordCampaignDelivery(app, country, os string, count int) {
+	app, country, os = m.appGuard.Value(app), m.countryGuard.Value(country), m.osGuard.Value(os)
 	m.Metrics.RecordCampaignDelivery(app, country, os, count)
+	m.recordFill(app, country, os, count)
+}
+
+// recordFill updates the fill metrics of a request with guarded labels
+func (m *CachedMetrics) recordFill(app, country, os string, count int) {
+	m.DeliveryRequests.Inc()
+	m.deliveryTotal.Add(1)
+
+	if count > 0 {
+		m.deliveryFilled.Add(1)
+		return
+	}
+
+	m.NoFillRequests.WithLabelValues(country, os, app).Inc()
 }
 
 // Original methods kept for backward compatibility
@@ -309,6 +967,97 @@ func (m *Metrics) RecordDatabaseError(operation, errorType string) {
 	m.DatabaseErrors.WithLabelValues(operation, errorType).Inc()
 }
 
+func (m *Metrics) ObserveDatabaseQuery(query string, duration float64, rows int) {
+	m.DatabaseQueryDuration.WithLabelValues(query).Observe(duration)
+	m.DatabaseRowsReturned.WithLabelValues(query).Observe(float64(rows))
+}
+
+func (m *Metrics) RecordMatching(source string, evaluated, matched int, duration time.Duration) {
+	m.MatchingCampaignsEvaluated.WithLabelValues(source).Observe(float64(evaluated))
+	m.MatchingDuration.WithLabelValues(source).Observe(duration.Seconds())
+}
+
+func (m *Metrics) RecordDimensionMatch(dimension string) {
+	m.MatchingDimensionMatches.WithLabelValues(dimension).Inc()
+}
+
+func (m *Metrics) RecordPanic(endpoint string) {
+	m.PanicsRecovered.WithLabelValues(endpoint).Inc()
+}
+
+func (m *Metrics) SetCircuitBreakerState(name string, state int) {
+	m.CircuitBreakerState.WithLabelValues(name).Set(float64(state))
+}
+
+func (m *Metrics) RecordStaleCampaignsServed() {
+	m.StaleCampaignsServed.Inc()
+}
+
+func (m *Metrics) RecordCampaignLimitExceeded(limit, source string) {
+	m.CampaignLimitsExceeded.WithLabelValues(limit, source).Inc()
+}
+
+func (m *Metrics) RecordRepositoryRetry(errorType string) {
+	m.RepositoryRetries.WithLabelValues(errorType).Inc()
+}
+
+func (m *Metrics) RecordRequestRejected(endpoint, reason string) {
+	m.RequestsRejected.WithLabelValues(endpoint, reason).Inc()
+}
+
+func (m *Metrics) RecordHedgedRead(target, winner string) {
+	m.HedgedReads.WithLabelValues(target, winner).Inc()
+}
+
+func (m *Metrics) RecordTrackedEvent(eventType string) {
+	m.TrackedEvents.WithLabelValues(eventType).Inc()
+}
+
+func (m *Metrics) RecordBusEvents(outcome string, count int) {
+	m.BusEvents.WithLabelValues(outcome).Add(float64(count))
+}
+
+func (m *Metrics) RecordDecisionLog(outcome string) {
+	m.DecisionLogRecords.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) RecordWebhook(outcome string) {
+	m.Webhooks.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) RecordTrafficBlocked(filter string) {
+	m.TrafficBlocked.WithLabelValues(filter).Inc()
+}
+
+func (m *Metrics) RecordNonceRequest(outcome string) {
+	m.NonceRequests.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) RecordCreativeRequest(outcome string) {
+	m.CreativeRequests.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) SetBrokenCreatives(count int) {
+	m.BrokenCreatives.Set(float64(count))
+}
+
+func (m *Metrics) RecordConsent(status string) {
+	m.ConsentRequests.WithLabelValues(status).Inc()
+}
+
+func (m *Metrics) RecordAnomalyAlert(metric string, firing bool) {
+	if !firing {
+		m.AnomalyFiring.WithLabelValues(metric).Set(0)
+		return
+	}
+	m.AnomalyAlerts.WithLabelValues(metric).Inc()
+	m.AnomalyFiring.WithLabelValues(metric).Set(1)
+}
+
+func (m *Metrics) SetShutdownRemainingRequests(remaining int) {
+	m.ShutdownRemainingRequests.Set(float64(remaining))
+}
+
 func (m *Metrics) SetHealthCheckStatus(checkType string, healthy bool) {
 	status := 0.0
 	if healthy {
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeBuckets(t *testing.T) {
+	fallback := []float64{1, 2}
+
+	assert.Equal(t, fallback, normalizeBuckets(nil, fallback))
+	assert.Equal(t, []float64{0.001, 0.005, 0.01}, normalizeBuckets([]float64{0.01, 0.001, 0.005, 0.001}, fallback))
+}
+
+func TestTopNoFill(t *testing.T) {
+	m := NewCachedMetrics()
+	for i := 0; i < 3; i++ {
+		m.RecordCampaignDelivery("com.game", "de", "android", 0)
+	}
+	m.RecordCampaignDelivery("com.news", "de", "ios", 0)
+	m.RecordCampaignDelivery("com.news", "fr", "ios", 0)
+	m.RecordCampaignDelivery("com.news", "us", "ios", 1)
+
+	top := m.TopNoFill(1)
+	assert.Equal(t, []NoFillCount{{Value: "de", Requests: 4}}, top["country"])
+	assert.Equal(t, []NoFillCount{{Value: "android", Requests: 3}}, top["os"])
+	assert.Equal(t, []NoFillCount{{Value: "com.game", Requests: 3}}, top["app"])
+	assert.Len(t, m.TopNoFill(10)["country"], 2)
+}
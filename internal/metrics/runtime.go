@@ -0,0 +1,31 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// RegisterRuntimeCollectors registers Go runtime, process and build info metrics under
+// the adbeacon_ prefix (adbeacon_go_goroutines, adbeacon_go_gc_duration_seconds,
+// adbeacon_process_open_fds, adbeacon_go_build_info, ...) so they sit next to the
+// service metrics in dashboards. The unprefixed default collectors are removed from
+// the default registry so runtime stats aren't gathered twice per scrape.
+func RegisterRuntimeCollectors(registerer prometheus.Registerer) error {
+	processOpts := prometheus.ProcessCollectorOpts{}
+
+	if registerer == prometheus.DefaultRegisterer {
+		prometheus.Unregister(prometheus.NewGoCollector())
+		prometheus.Unregister(prometheus.NewProcessCollector(processOpts))
+	}
+
+	prefixed := prometheus.WrapRegistererWithPrefix("adbeacon_", registerer)
+	collectors := []prometheus.Collector{
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(processOpts),
+		prometheus.NewBuildInfoCollector(),
+	}
+	for _, collector := range collectors {
+		if err := prefixed.Register(collector); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
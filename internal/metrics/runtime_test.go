@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterRuntimeCollectors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	require.NoError(t, RegisterRuntimeCollectors(registry))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	names := make(map[string]bool, len(families))
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	assert.True(t, names["adbeacon_go_goroutines"])
+	assert.True(t, names["adbeacon_go_gc_duration_seconds"])
+	assert.True(t, names["adbeacon_go_build_info"])
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/logger"
+)
+
+// AccessLogConfig configures the access log middleware
+type AccessLogConfig struct {
+	// SampleRate is the fraction (0-1) of successful requests that are logged.
+	// Server errors are always logged regardless of sampling.
+	SampleRate float64
+	// ExcludePaths are never logged (e.g. /health, /metrics)
+	ExcludePaths []string
+	// Controls shares a runtime adjustable sample rate, when set SampleRate is ignored
+	Controls *logger.Controls
+	// TrustedProxies are believed about the client IP, see TrustedProxies.ClientAddr
+	TrustedProxies TrustedProxies
+}
+
+// AccessLogMiddleware emits one structured log line per HTTP request
+type AccessLogMiddleware struct {
+	logger       log.Logger
+	controls     *logger.Controls
+	excludePaths map[string]bool
+	proxies      TrustedProxies
+}
+
+// NewAccessLogMiddleware creates a new access log middleware
+func NewAccessLogMiddleware(l log.Logger, config AccessLogConfig) *AccessLogMiddleware {
+	excludePaths := make(map[string]bool, len(config.ExcludePaths))
+	for _, path := range config.ExcludePaths {
+		excludePaths[strings.TrimSuffix(path, "/")] = true
+	}
+
+	controls := config.Controls
+	if controls == nil {
+		controls = logger.NewControls(logger.LevelInfo, config.SampleRate)
+	}
+
+	return &AccessLogMiddleware{
+		logger:       l,
+		controls:     controls,
+		excludePaths: excludePaths,
+		proxies:      config.TrustedProxies,
+	}
+}
+
+// Middleware returns the HTTP middleware function for access logging
+func (m *AccessLogMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.excludePaths[strings.TrimSuffix(r.URL.Path, "/")] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: 200}
+
+		next.ServeHTTP(wrapped, r)
+
+		if !m.shouldLog(wrapped.statusCode) {
+			return
+		}
+
+		// Logged like the blocklist and invalid traffic filters see it
+		var ip string
+		if addr := m.proxies.ClientAddr(r); addr.IsValid() {
+			ip = addr.String()
+		}
+
+		level.Info(m.logger).Log(
+			"msg", "access",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", wrapped.statusCode,
+			"bytes", wrapped.bytes,
+			"duration", time.Since(start),
+			"request_id", reqcontext.GetRequestID(r.Context()),
+			"client_ip", loggableIP(r, ip),
+			"user_agent", r.UserAgent(),
+		)
+	})
+}
+
+// shouldLog applies sampling, server errors are always logged
+func (m *AccessLogMiddleware) shouldLog(statusCode int) bool {
+	if statusCode >= http.StatusInternalServerError {
+		return true
+	}
+	return m.controls.Sample()
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("hello"))
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		sampleRate float64
+		wantLogged bool
+		wantIP     string
+	}{
+		{name: "logged", path: "/v1/delivery", sampleRate: 1, wantLogged: true},
+		{name: "excluded path", path: "/health", sampleRate: 1, wantLogged: false},
+		{name: "sampled out", path: "/v1/delivery", sampleRate: 0, wantLogged: false},
+		{name: "server errors bypass sampling", path: "/fail", sampleRate: 0, wantLogged: true},
+		{name: "forwarded for an untrusted peer", path: "/v1/delivery", remoteAddr: "198.51.100.1:41000", sampleRate: 1, wantLogged: true, wantIP: "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			mw := NewAccessLogMiddleware(log.NewLogfmtLogger(&buf), AccessLogConfig{
+				SampleRate:     tt.sampleRate,
+				ExcludePaths:   []string{"/health", "/metrics"},
+				TrustedProxies: TrustedProxies{netip.MustParsePrefix("10.0.0.0/8")},
+			})
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RemoteAddr = "10.0.0.2:41000"
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+			w := httptest.NewRecorder()
+
+			mw.Middleware(handler).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantLogged, buf.Len() > 0)
+			if tt.wantLogged {
+				assert.Contains(t, buf.String(), "path="+tt.path)
+				assert.Contains(t, buf.String(), "bytes=5")
+				wantIP := "203.0.113.7"
+				if tt.wantIP != "" {
+					wantIP = tt.wantIP
+				}
+				assert.Contains(t, buf.String(), "client_ip="+wantIP)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// AdminPathPrefix is the path prefix of the admin API
+const AdminPathPrefix = "/v1/admin/"
+
+// AdminAuthMiddleware requires the admin token as a bearer token on every request to the admin
+// API, other requests are left alone. Without a token the admin API rejects every request.
+type AdminAuthMiddleware struct {
+	token string
+}
+
+// NewAdminAuthMiddleware creates a new admin authentication middleware with the admin token
+func NewAdminAuthMiddleware(token string) *AdminAuthMiddleware {
+	return &AdminAuthMiddleware{token: token}
+}
+
+// Middleware returns the HTTP middleware function for admin authentication
+func (m *AdminAuthMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cleaned like the router does, so /v1//admin/ and /v1/x/../admin/ are covered too
+		if !strings.HasPrefix(path.Clean(r.URL.Path)+"/", AdminPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || m.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(m.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="adbeacon admin"`)
+			writeJSONError(w, http.StatusUnauthorized, "the admin API requires the admin token as a bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
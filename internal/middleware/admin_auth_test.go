@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := NewAdminAuthMiddleware("s3cret").Middleware(next)
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "admin with token", path: "/v1/admin/campaigns", authorization: "Bearer s3cret", wantStatus: http.StatusNoContent},
+		{name: "admin without token", path: "/v1/admin/campaigns", wantStatus: http.StatusUnauthorized},
+		{name: "admin with another token", path: "/v1/admin/campaigns", authorization: "Bearer other", wantStatus: http.StatusUnauthorized},
+		{name: "admin with basic auth", path: "/v1/admin/campaigns", authorization: "Basic czNjcmV0", wantStatus: http.StatusUnauthorized},
+		{name: "admin user header only", path: "/v1/admin/config", wantStatus: http.StatusUnauthorized},
+		{name: "unclean admin path", path: "/v1//admin/campaigns", wantStatus: http.StatusUnauthorized},
+		{name: "dot segments", path: "/v1/delivery/../admin/logging", wantStatus: http.StatusUnauthorized},
+		{name: "delivery", path: "/v1/delivery?country=us", wantStatus: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", tt.path, nil)
+			req.Header.Set("X-Admin-User", "alice")
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	// Without a token the admin API is closed
+	req := httptest.NewRequest("GET", "/v1/admin/campaigns", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	NewAdminAuthMiddleware("").Middleware(next).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/anomaly"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// anomalyMiddleware feeds delivery outcomes to the anomaly detector
+type anomalyMiddleware struct {
+	detector *anomaly.Detector
+	next     service.CampaignDeliveryService
+}
+
+// NewAnomalyMiddleware creates a new anomaly detection middleware
+func NewAnomalyMiddleware(detector *anomaly.Detector) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
+	return func(next service.CampaignDeliveryService) service.CampaignDeliveryService {
+		return &anomalyMiddleware{
+			detector: detector,
+			next:     next,
+		}
+	}
+}
+
+// GetCampaigns implements service.DeliveryService, observing whether the request failed or was filled
+func (mw *anomalyMiddleware) GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+	campaigns, err := mw.next.GetCampaigns(ctx, req)
+	mw.detector.Observe(err != nil, len(campaigns) > 0)
+	return campaigns, err
+}
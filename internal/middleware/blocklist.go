@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/blocklist"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/fraud"
+)
+
+// BlocklistMiddleware answers delivery requests from blocked apps, countries and IP ranges with
+// 204, like requests nothing matched, before any campaign is matched
+type BlocklistMiddleware struct {
+	list     *blocklist.Blocklist
+	proxies  TrustedProxies
+	recorder fraud.Recorder
+	logger   log.Logger
+}
+
+// NewBlocklistMiddleware creates a new blocklist middleware, blocked requests are counted by
+// recorder as blocked traffic of the "blocklist_<kind>" filter. recorder may be nil. Client IPs
+// are taken from X-Forwarded-For only behind proxies.
+func NewBlocklistMiddleware(list *blocklist.Blocklist, proxies TrustedProxies, recorder fraud.Recorder, logger log.Logger) *BlocklistMiddleware {
+	return &BlocklistMiddleware{
+		list:     list,
+		proxies:  proxies,
+		recorder: recorder,
+		logger:   logger,
+	}
+}
+
+// Middleware returns the HTTP middleware function for the blocklist
+func (m *BlocklistMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if normalizeEndpoint(r.URL.Path) != "/v1/delivery" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		ip := m.proxies.ClientAddr(r)
+		kind := m.list.Check(query.Get("app"), query.Get("country"), ip)
+		if kind == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if m.recorder != nil {
+			m.recorder.RecordTrafficBlocked("blocklist_" + kind)
+		}
+		level.Debug(m.logger).Log("msg", "blocklisted request", "kind", kind,
+			"request_id", reqcontext.GetRequestID(r.Context()), "ip", loggableIP(r, ip.String()))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/blocklist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlocklistMiddleware(t *testing.T) {
+	ctx := context.Background()
+	list := blocklist.New(blocklist.NewMemoryStore())
+	for _, entry := range []blocklist.Entry{
+		{Kind: blocklist.KindApp, Value: "com.blocked"},
+		{Kind: blocklist.KindCountry, Value: "de"},
+		{Kind: blocklist.KindIPRange, Value: "203.0.113.0/24"},
+	} {
+		_, err := list.Add(ctx, entry)
+		require.NoError(t, err)
+	}
+
+	recorder := blockedCounter{}
+	proxies := TrustedProxies{netip.MustParsePrefix("10.0.0.0/8")}
+	handler := NewBlocklistMiddleware(list, proxies, recorder, log.NewNopLogger()).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serveFrom := func(remoteAddr, path, forwardedFor string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	serve := func(path, forwardedFor string) int {
+		return serveFrom("10.0.0.2:41000", path, forwardedFor)
+	}
+
+	// Blocked requests look like requests nothing matched
+	assert.Equal(t, http.StatusNoContent, serve("/v1/delivery?app=com.blocked&country=us&os=android", ""))
+	assert.Equal(t, http.StatusNoContent, serve("/v1/delivery?app=com.test&country=DEU&os=android", ""))
+	assert.Equal(t, http.StatusNoContent, serve("/v1/delivery?app=com.test&country=us&os=android", "203.0.113.9"))
+	assert.Equal(t, http.StatusOK, serve("/v1/delivery?app=com.test&country=us&os=android", "198.51.100.1"))
+	assert.Equal(t, blockedCounter{"blocklist_app": 1, "blocklist_country": 1, "blocklist_ip_range": 1}, recorder)
+
+	// A blocked client can't pass as another by forging X-Forwarded-For, neither directly nor
+	// through the proxy
+	assert.Equal(t, http.StatusNoContent, serveFrom("203.0.113.9:41000", "/v1/delivery?app=com.test&country=us&os=android", "1.2.3.4"))
+	assert.Equal(t, http.StatusNoContent, serve("/v1/delivery?app=com.test&country=us&os=android", "1.2.3.4, 203.0.113.9"))
+
+	// Only deliveries are blocked
+	assert.Equal(t, http.StatusOK, serve("/health?app=com.blocked", ""))
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/campaignstats"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// campaignStatsMiddleware counts the deliveries of each campaign for the rolling campaign stats
+type campaignStatsMiddleware struct {
+	tracker *campaignstats.Tracker
+	next    service.CampaignDeliveryService
+}
+
+// NewCampaignStatsMiddleware creates a new campaign stats middleware
+func NewCampaignStatsMiddleware(tracker *campaignstats.Tracker) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
+	return func(next service.CampaignDeliveryService) service.CampaignDeliveryService {
+		return &campaignStatsMiddleware{
+			tracker: tracker,
+			next:    next,
+		}
+	}
+}
+
+// GetCampaigns implements service.DeliveryService, counting each delivered campaign
+func (mw *campaignStatsMiddleware) GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+	campaigns, err := mw.next.GetCampaigns(ctx, req)
+	if err != nil || len(campaigns) == 0 {
+		return campaigns, err
+	}
+
+	campaignIDs := make([]string, len(campaigns))
+	for i, campaign := range campaigns {
+		campaignIDs[i] = campaign.CID
+	}
+	mw.tracker.RecordDeliveries(campaignIDs)
+
+	return campaigns, nil
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies are the networks of the load balancers and proxies in front of the server, only
+// the X-Forwarded-For entries they appended are believed
+type TrustedProxies []netip.Prefix
+
+// ClientAddr returns the address r came from, in a way a client can't forge. It is the peer
+// address, or when the peer is a trusted proxy the rightmost X-Forwarded-For entry that isn't
+// one: entries left of it were sent by the client. The zero Addr is returned when the peer
+// address doesn't parse.
+func (p TrustedProxies) ClientAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	addr = addr.Unmap()
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0 && p.contains(addr); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Trusted proxies append valid addresses, the rest came from the client
+			break
+		}
+		addr = hop.Unmap()
+	}
+	return addr
+}
+
+// contains reports whether addr is a trusted proxy
+func (p TrustedProxies) contains(addr netip.Addr) bool {
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedProxies_ClientAddr(t *testing.T) {
+	proxies := TrustedProxies{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{name: "direct", remoteAddr: "198.51.100.1:41000", want: "198.51.100.1"},
+		{name: "forged by a direct client", remoteAddr: "198.51.100.1:41000", forwardedFor: []string{"1.2.3.4"}, want: "198.51.100.1"},
+		{name: "behind a proxy", remoteAddr: "10.0.0.2:41000", forwardedFor: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "forged behind a proxy", remoteAddr: "10.0.0.2:41000", forwardedFor: []string{"1.2.3.4, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "behind proxies", remoteAddr: "10.0.0.2:41000", forwardedFor: []string{"198.51.100.1, 10.0.0.3", "10.0.0.4"}, want: "198.51.100.1"},
+		{name: "only proxies", remoteAddr: "10.0.0.2:41000", forwardedFor: []string{"10.0.0.3"}, want: "10.0.0.3"},
+		{name: "garbage behind a proxy", remoteAddr: "10.0.0.2:41000", forwardedFor: []string{"unknown"}, want: "10.0.0.2"},
+		{name: "proxy without header", remoteAddr: "10.0.0.2:41000", want: "10.0.0.2"},
+		{name: "ipv6 behind a proxy", remoteAddr: "[fd00::1]:41000", forwardedFor: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "unparseable peer", remoteAddr: "pipe", want: "invalid IP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/delivery", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			assert.Equal(t, tt.want, proxies.ClientAddr(req).String())
+		})
+	}
+}
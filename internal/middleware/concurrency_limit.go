@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+)
+
+// Reasons a request can be rejected before reaching the handler, used as metric labels
+const (
+	RejectReasonConcurrencyLimit = "concurrency_limit"
+)
+
+// ConcurrencyLimitConfig configures the concurrency limit middleware
+type ConcurrencyLimitConfig struct {
+	// MaxInFlight is the maximum number of concurrent requests per limited endpoint
+	MaxInFlight int
+	// RetryAfter is advertised to rejected clients
+	RetryAfter time.Duration
+	// Endpoints are the normalized endpoints to limit, e.g. /v1/delivery
+	Endpoints []string
+}
+
+// ConcurrencyLimitMiddleware rejects requests with 503 once too many are in flight, so a
+// traffic spike degrades gracefully instead of exhausting database connections and memory
+type ConcurrencyLimitMiddleware struct {
+	metrics    *metrics.CachedMetrics
+	retryAfter string
+	// One slot semaphore per limited endpoint
+	slots map[string]chan struct{}
+}
+
+// NewConcurrencyLimitMiddleware creates a new concurrency limit middleware, metrics may be nil
+func NewConcurrencyLimitMiddleware(config ConcurrencyLimitConfig, metrics *metrics.CachedMetrics) *ConcurrencyLimitMiddleware {
+	slots := make(map[string]chan struct{}, len(config.Endpoints))
+	for _, endpoint := range config.Endpoints {
+		slots[endpoint] = make(chan struct{}, config.MaxInFlight)
+	}
+
+	retryAfter := int(config.RetryAfter.Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	return &ConcurrencyLimitMiddleware{
+		metrics:    metrics,
+		retryAfter: strconv.Itoa(retryAfter),
+		slots:      slots,
+	}
+}
+
+// Middleware returns the HTTP middleware function for concurrency limiting
+func (m *ConcurrencyLimitMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := normalizeEndpoint(r.URL.Path)
+		slots, limited := m.slots[endpoint]
+		if !limited {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		default:
+			if m.metrics != nil {
+				m.metrics.RecordRequestRejected(endpoint, RejectReasonConcurrencyLimit)
+			}
+			writeUnavailable(w, m.retryAfter, "too many requests in flight")
+		}
+	})
+}
+
+// writeUnavailable writes a 503 response asking the client to retry later
+func writeUnavailable(w http.ResponseWriter, retryAfter, message string) {
+	w.Header().Set("Retry-After", retryAfter)
+	writeJSONError(w, http.StatusServiceUnavailable, message)
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("block") == "true" {
+			started <- struct{}{}
+			<-release
+		}
+	})
+
+	mw := NewConcurrencyLimitMiddleware(ConcurrencyLimitConfig{
+		MaxInFlight: 2,
+		RetryAfter:  2 * time.Second,
+		Endpoints:   []string{"/v1/delivery"},
+	}, nil)
+	limited := mw.Middleware(handler)
+
+	// Fill both slots
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limited.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/delivery?block=true", nil))
+		}()
+		<-started
+	}
+
+	// The next delivery request is rejected
+	w := httptest.NewRecorder()
+	limited.ServeHTTP(w, httptest.NewRequest("GET", "/v1/delivery", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	// Other endpoints are not limited
+	w = httptest.NewRecorder()
+	limited.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Slots are freed once requests finish
+	close(release)
+	wg.Wait()
+
+	w = httptest.NewRecorder()
+	limited.ServeHTTP(w, httptest.NewRequest("GET", "/v1/delivery", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/consent"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+)
+
+// ConsentMiddleware evaluates the GDPR consent of delivery requests from their gdpr and
+// gdpr_consent (TCF v2 consent string) parameters and adds it to the request context, so
+// targeting and logging down the chain can honour it. It must run before the access log.
+type ConsentMiddleware struct {
+	// vendorID is the TCF vendor ID the user must consent to, 0 to only check purposes
+	vendorID int
+	recorder consent.Recorder
+}
+
+// NewConsentMiddleware creates a new consent middleware, recorder may be nil
+func NewConsentMiddleware(vendorID int, recorder consent.Recorder) *ConsentMiddleware {
+	return &ConsentMiddleware{
+		vendorID: vendorID,
+		recorder: recorder,
+	}
+}
+
+// Middleware returns the HTTP middleware function for consent handling
+func (m *ConsentMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if normalizeEndpoint(r.URL.Path) != "/v1/delivery" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		c := consent.FromRequest(query.Get("gdpr"), query.Get("gdpr_consent"), m.vendorID)
+		if m.recorder != nil {
+			m.recorder.RecordConsent(c.Status)
+		}
+		ctx := reqcontext.WithConsent(r.Context(), c)
+		if remoteAddr := reqcontext.GetRemoteAddr(ctx); remoteAddr != "" && !c.AllowsPersonalData() {
+			// The service logs and error reports read the remote address from the context
+			host, _, err := net.SplitHostPort(remoteAddr)
+			if err != nil {
+				host = remoteAddr
+			}
+			ctx = reqcontext.WithRemoteAddr(ctx, anonymizeIP(host))
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// loggableIP returns ip as it may be logged for r, anonymized without consent
+func loggableIP(r *http.Request, ip string) string {
+	if reqcontext.GetConsent(r.Context()).AllowsPersonalData() {
+		return ip
+	}
+	return anonymizeIP(ip)
+}
+
+// anonymizeIP removes the host part of ip, the last octet of IPv4 and everything after the /48
+// of IPv6 addresses, invalid addresses are dropped
+func anonymizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/consent"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/stretchr/testify/assert"
+)
+
+// consentCounter counts requests by consent status
+type consentCounter map[string]int
+
+func (c consentCounter) RecordConsent(status string) { c[status]++ }
+
+func TestConsentMiddleware(t *testing.T) {
+	recorder := consentCounter{}
+	var seen consent.Consent
+	var remoteAddr string
+	handler := NewConsentMiddleware(0, recorder).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = reqcontext.GetConsent(r.Context())
+		remoteAddr = reqcontext.GetRemoteAddr(r.Context())
+	}))
+	serve := func(path string) {
+		req := httptest.NewRequest("GET", path, nil)
+		req = req.WithContext(reqcontext.WithRemoteAddr(req.Context(), "203.0.113.7:52100"))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve("/v1/delivery?app=com.test&country=de&os=android&gdpr=1")
+	assert.Equal(t, consent.StatusMissing, seen.Status)
+	assert.False(t, seen.AllowsPersonalData())
+	assert.Equal(t, "203.0.113.0", remoteAddr)
+
+	serve("/v1/delivery?app=com.test&country=us&os=android&gdpr=0")
+	assert.Equal(t, consent.StatusNotApplicable, seen.Status)
+	assert.Equal(t, "203.0.113.7:52100", remoteAddr)
+
+	assert.Equal(t, consentCounter{consent.StatusMissing: 1, consent.StatusNotApplicable: 1}, recorder)
+
+	// Only deliveries are checked
+	seen = consent.Consent{}
+	serve("/health?gdpr=1")
+	assert.Empty(t, seen.Status)
+}
+
+func TestConsentMiddleware_AnonymizesAccessLog(t *testing.T) {
+	tests := []struct {
+		query string
+		ip    string
+		want  string
+	}{
+		{"gdpr=0", "203.0.113.7", "client_ip=203.0.113.7"},
+		{"gdpr=1", "203.0.113.7", "client_ip=203.0.113.0"},
+		{"gdpr=1", "2001:db8:85a3::8a2e:370:7334", "client_ip=2001:db8:85a3::"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query+" "+tt.ip, func(t *testing.T) {
+			var buf bytes.Buffer
+			accessLog := NewAccessLogMiddleware(log.NewLogfmtLogger(&buf), AccessLogConfig{SampleRate: 1})
+			handler := NewConsentMiddleware(0, nil).Middleware(accessLog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+			req := httptest.NewRequest("GET", "/v1/delivery?app=com.test&country=de&os=android&"+tt.query, nil)
+			req.RemoteAddr = net.JoinHostPort(tt.ip, "41000")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Contains(t, buf.String(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// creativeMiddleware points the images of delivered campaigns to the creative proxy
+type creativeMiddleware struct {
+	baseURL string
+	next    service.CampaignDeliveryService
+}
+
+// NewCreativeMiddleware creates a new middleware rewriting campaign images to baseURL followed
+// by /v1/creative/{id}, baseURL is empty for URLs relative to the ad server
+func NewCreativeMiddleware(baseURL string) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
+	return func(next service.CampaignDeliveryService) service.CampaignDeliveryService {
+		return &creativeMiddleware{
+			baseURL: strings.TrimSuffix(baseURL, "/"),
+			next:    next,
+		}
+	}
+}
+
+// GetCampaigns implements service.DeliveryService. The campaigns are copied before rewriting,
+// the service may share them with later requests. Requests with a language get proxy URLs with
+// it, so the proxy serves the image of the localized creative.
+func (mw *creativeMiddleware) GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+	campaigns, err := mw.next.GetCampaigns(ctx, req)
+	if err != nil || len(campaigns) == 0 {
+		return campaigns, err
+	}
+
+	query := ""
+	if req.Lang != "" {
+		query = "?lang=" + url.QueryEscape(req.Lang)
+	}
+	rewritten := make([]models.CampaignResponse, len(campaigns))
+	for i, campaign := range campaigns {
+		if campaign.Img != "" {
+			campaign.Img = mw.baseURL + "/v1/creative/" + url.PathEscape(campaign.CID) + query
+		}
+		rewritten[i] = campaign
+	}
+	return rewritten, nil
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// imageService delivers the same campaigns to every request
+type imageService []models.CampaignResponse
+
+func (s imageService) GetCampaigns(context.Context, models.DeliveryRequest) ([]models.CampaignResponse, error) {
+	return s, nil
+}
+
+func TestCreativeMiddleware(t *testing.T) {
+	delivered := imageService{
+		{CID: "spotify", Img: "https://cdn.spotify.com/banner.png", CTA: "Download"},
+		{CID: "summer sale", Img: "https://cdn.example.com/sale.jpg"},
+		{CID: "text-only"},
+	}
+
+	campaigns, err := NewCreativeMiddleware("https://ads.example.com/")(delivered).GetCampaigns(context.Background(), models.DeliveryRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []models.CampaignResponse{
+		{CID: "spotify", Img: "https://ads.example.com/v1/creative/spotify", CTA: "Download"},
+		{CID: "summer sale", Img: "https://ads.example.com/v1/creative/summer%20sale"},
+		{CID: "text-only"},
+	}, campaigns)
+	// The service's campaigns are left alone
+	assert.Equal(t, "https://cdn.spotify.com/banner.png", delivered[0].Img)
+
+	campaigns, err = NewCreativeMiddleware("")(delivered).GetCampaigns(context.Background(), models.DeliveryRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/creative/spotify", campaigns[0].Img)
+
+	// Localized images are served for the language of the request
+	campaigns, err = NewCreativeMiddleware("")(delivered).GetCampaigns(context.Background(), models.DeliveryRequest{Lang: "pt-br"})
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/creative/spotify?lang=pt-br", campaigns[0].Img)
+}
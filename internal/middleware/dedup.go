@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/dedup"
+)
+
+// NonceParam is the delivery request parameter carrying the client-generated nonce
+const NonceParam = "nonce"
+
+// NonceReplayedHeader marks responses replayed for a repeated nonce
+const NonceReplayedHeader = "X-Nonce-Replayed"
+
+// maxNonceLength bounds the nonces accepted, UUIDs and similar tokens fit easily
+const maxNonceLength = 128
+
+// Outcomes of delivery requests with a nonce reported to the NonceRecorder
+const (
+	NonceFirst      = "first"
+	NonceReplayed   = "replayed"
+	NonceInProgress = "in_progress"
+	NonceMismatch   = "mismatch"
+	NonceStoreError = "store_error"
+)
+
+// NonceRecorder counts delivery requests with a nonce by outcome, metrics.CachedMetrics implements it
+type NonceRecorder interface {
+	RecordNonceRequest(outcome string)
+}
+
+// DeduplicationMiddleware answers delivery requests repeating the nonce of an earlier request
+// within the window with the response of the first one, so retries of SDKs don't deliver and
+// count campaigns twice. A retry while the first request is still served gets a 409 and reusing a
+// nonce for a different request a 422. Server errors are not stored so the request can be
+// retried. When the store fails requests are served without deduplication.
+type DeduplicationMiddleware struct {
+	store    dedup.Store
+	window   time.Duration
+	recorder NonceRecorder
+	logger   log.Logger
+}
+
+// NewDeduplicationMiddleware creates a new deduplication middleware, recorder may be nil
+func NewDeduplicationMiddleware(store dedup.Store, window time.Duration, recorder NonceRecorder, logger log.Logger) *DeduplicationMiddleware {
+	return &DeduplicationMiddleware{
+		store:    store,
+		window:   window,
+		recorder: recorder,
+		logger:   logger,
+	}
+}
+
+// Middleware returns the HTTP middleware function for request deduplication
+func (m *DeduplicationMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce := r.URL.Query().Get(NonceParam)
+		if nonce == "" || normalizeEndpoint(r.URL.Path) != "/v1/delivery" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(nonce) > maxNonceLength {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("nonce must be at most %d characters", maxNonceLength))
+			return
+		}
+
+		// The stored response is kept when the client goes away before it's written
+		ctx := context.WithoutCancel(r.Context())
+		key := dedup.Key(r.Header.Get(APIKeyHeader), nonce)
+		fingerprint := nonceFingerprint(r)
+		record, claimed, err := m.store.Claim(ctx, key, fingerprint, m.window)
+		if err != nil {
+			m.record(NonceStoreError)
+			level.Warn(m.logger).Log("msg", "request deduplication unavailable", "err", err,
+				"request_id", reqcontext.GetRequestID(r.Context()))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if claimed {
+			switch {
+			case record.Fingerprint != fingerprint:
+				m.record(NonceMismatch)
+				writeJSONError(w, http.StatusUnprocessableEntity, "nonce was already used for a different request")
+			case !record.Done:
+				m.record(NonceInProgress)
+				writeJSONError(w, http.StatusConflict, "a request with this nonce is still in progress")
+			default:
+				m.record(NonceReplayed)
+				if record.ContentType != "" {
+					w.Header().Set("Content-Type", record.ContentType)
+				}
+				w.Header().Set(NonceReplayedHeader, "true")
+				w.WriteHeader(record.Status)
+				w.Write(record.Body)
+			}
+			return
+		}
+
+		m.record(NonceFirst)
+		recorder := &recordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			// Release the nonce if the handler panicked or failed so the client can retry
+			if !completed || recorder.status >= http.StatusInternalServerError {
+				err = m.store.Release(ctx, key)
+			} else {
+				err = m.store.Complete(ctx, key, dedup.Record{
+					Fingerprint: fingerprint,
+					Status:      recorder.status,
+					ContentType: recorder.Header().Get("Content-Type"),
+					Body:        recorder.body.Bytes(),
+				})
+			}
+			if err != nil {
+				level.Warn(m.logger).Log("msg", "failed to store deduplicated response", "err", err,
+					"request_id", reqcontext.GetRequestID(r.Context()))
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+		completed = true
+	})
+}
+
+func (m *DeduplicationMiddleware) record(outcome string) {
+	if m.recorder != nil {
+		m.recorder.RecordNonceRequest(outcome)
+	}
+}
+
+// nonceFingerprint identifies a delivery request like requestFingerprint, without its timestamp
+// parameter: clients retrying a call with the same nonce may send the time of the retry
+func nonceFingerprint(r *http.Request) string {
+	query := r.URL.Query()
+	if !query.Has(TimestampParam) {
+		return requestFingerprint(r, nil)
+	}
+	query.Del(TimestampParam)
+	stripped := *r
+	stripped.URL = &url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: query.Encode()}
+	return requestFingerprint(&stripped, nil)
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/dedup"
+	"github.com/stretchr/testify/assert"
+)
+
+// nonceCounter counts delivery requests with a nonce by outcome
+type nonceCounter map[string]int
+
+func (c nonceCounter) RecordNonceRequest(outcome string) { c[outcome]++ }
+
+func TestDeduplicationMiddleware(t *testing.T) {
+	served := 0
+	status := http.StatusOK
+	var inFlight func(w http.ResponseWriter)
+	recorder := nonceCounter{}
+	handler := NewDeduplicationMiddleware(dedup.NewMemoryStore(), time.Minute, recorder, log.NewNopLogger()).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		if inFlight != nil {
+			inFlight(w)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`[{"cid":"spotify"}]`))
+	}))
+	serve := func(path, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Retries get the first response without being served again
+	first := serve("/v1/delivery?app=com.test&country=us&os=android&nonce=n1", "")
+	assert.Equal(t, http.StatusOK, first.Code)
+	retry := serve("/v1/delivery?app=com.test&country=us&os=android&nonce=n1", "")
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, "true", retry.Header().Get(NonceReplayedHeader))
+	assert.Equal(t, 1, served)
+
+	// A nonce reused for a different request is rejected, other API keys have their own nonces
+	assert.Equal(t, http.StatusUnprocessableEntity, serve("/v1/delivery?app=com.test&country=de&os=android&nonce=n1", "").Code)
+	assert.Equal(t, http.StatusOK, serve("/v1/delivery?app=com.test&country=us&os=android&nonce=n1", "other-key").Code)
+	assert.Equal(t, 2, served)
+
+	// Retries sending the time of the retry are the same request
+	assert.Equal(t, http.StatusOK, serve("/v1/delivery?app=com.test&country=us&os=android&nonce=n4&ts=1735689600", "").Code)
+	retry = serve("/v1/delivery?app=com.test&country=us&os=android&nonce=n4&ts=1735689605", "")
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(NonceReplayedHeader))
+	assert.Equal(t, http.StatusUnprocessableEntity, serve("/v1/delivery?app=com.test&country=de&os=android&nonce=n4&ts=1735689605", "").Code)
+	assert.Equal(t, 3, served)
+
+	// A retry arriving while the first request is served is rejected
+	inFlight = func(http.ResponseWriter) {
+		inFlight = nil
+		assert.Equal(t, http.StatusConflict, serve("/v1/delivery?app=com.test&country=us&os=android&nonce=n2", "").Code)
+	}
+	assert.Equal(t, http.StatusOK, serve("/v1/delivery?app=com.test&country=us&os=android&nonce=n2", "").Code)
+
+	// Server errors are not replayed
+	status = http.StatusInternalServerError
+	assert.Equal(t, http.StatusInternalServerError, serve("/v1/delivery?app=com.test&country=us&os=android&nonce=n3", "").Code)
+	status = http.StatusOK
+	retry = serve("/v1/delivery?app=com.test&country=us&os=android&nonce=n3", "")
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Empty(t, retry.Header().Get(NonceReplayedHeader))
+
+	// Requests without a nonce and other endpoints are served every time
+	served = 0
+	serve("/v1/delivery?app=com.test&country=us&os=android", "")
+	serve("/v1/delivery?app=com.test&country=us&os=android", "")
+	serve("/health?nonce=n1", "")
+	assert.Equal(t, 3, served)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/v1/delivery?app=com.test&country=us&os=android&nonce="+strings.Repeat("x", 129), "").Code)
+	assert.Equal(t, nonceCounter{NonceFirst: 6, NonceReplayed: 2, NonceMismatch: 2, NonceInProgress: 1}, recorder)
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+)
+
+// DeliveryCacheConfig configures the caching headers of delivery responses
+type DeliveryCacheConfig struct {
+	// MaxAge is how long caches may serve a delivery response
+	MaxAge time.Duration
+	// Vary lists the request headers besides X-API-Key the responses depend on, e.g. those read
+	// by the invalid traffic filters
+	Vary []string
+}
+
+// DeliveryCacheMiddleware sets Cache-Control, Age and Vary on delivery responses, so CDN edges can
+// answer repeated identical requests. Only responses the delivery service matched are cacheable:
+// failed, rejected and blocked requests and timeout fallbacks get no-store, like every response
+// to an API key, which is signed for and counted against the key. Responses to requests with a
+// user or session ID are private to the device. Without a time parameter campaigns are matched
+// at the server clock, so responses expire at the next hour at the latest, when time of day
+// targeting may serve other campaigns. Caches key responses by URL, which carries the targeting
+// parameters, and by the Vary headers.
+type DeliveryCacheMiddleware struct {
+	config DeliveryCacheConfig
+	vary   string
+	now    func() time.Time
+}
+
+// NewDeliveryCacheMiddleware creates a new delivery cache headers middleware
+func NewDeliveryCacheMiddleware(config DeliveryCacheConfig) *DeliveryCacheMiddleware {
+	return &DeliveryCacheMiddleware{
+		config: config,
+		vary:   strings.Join(append([]string{APIKeyHeader}, config.Vary...), ", "),
+		now:    time.Now,
+	}
+}
+
+// Middleware returns the HTTP middleware function setting the caching headers
+func (m *DeliveryCacheMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if normalizeEndpoint(r.URL.Path) != "/v1/delivery" || r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, outcome := reqcontext.WithDeliveryOutcome(r.Context())
+		wrapped := &cacheHeaderWriter{ResponseWriter: w, setHeaders: func(status int) {
+			m.setHeaders(w.Header(), r, status, outcome)
+		}}
+		next.ServeHTTP(wrapped, r.WithContext(ctx))
+	})
+}
+
+// setHeaders sets the caching headers of a response with the status
+func (m *DeliveryCacheMiddleware) setHeaders(header http.Header, r *http.Request, status int, outcome *reqcontext.DeliveryOutcome) {
+	if (status != http.StatusOK && status != http.StatusNoContent) || !outcome.Matched || r.Header.Get(APIKeyHeader) != "" {
+		header.Set("Cache-Control", "no-store")
+		return
+	}
+
+	query := r.URL.Query()
+	maxAge := m.config.MaxAge
+	if query.Get("time") == "" {
+		now := m.now()
+		maxAge = min(maxAge, now.Truncate(time.Hour).Add(time.Hour).Sub(now))
+	}
+	scope := "public"
+	if query.Get("user_id") != "" || query.Get("session_id") != "" {
+		scope = "private"
+	}
+
+	header.Set("Cache-Control", scope+", max-age="+strconv.Itoa(int(maxAge/time.Second)))
+	if outcome.Age >= time.Second {
+		header.Set("Age", strconv.Itoa(int(outcome.Age/time.Second)))
+	}
+	header.Add("Vary", m.vary)
+}
+
+// cacheHeaderWriter sets the caching headers once the status of the response is known
+type cacheHeaderWriter struct {
+	http.ResponseWriter
+	setHeaders  func(status int)
+	wroteHeader bool
+}
+
+func (cw *cacheHeaderWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		cw.setHeaders(code)
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cacheHeaderWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryCacheMiddleware(t *testing.T) {
+	m := NewDeliveryCacheMiddleware(DeliveryCacheConfig{MaxAge: time.Minute, Vary: []string{CarrierHeader}})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("country") == "" {
+			writeJSONError(w, http.StatusBadRequest, "country is required")
+			return
+		}
+		// Blocked requests are answered without reaching the delivery service
+		if query.Get("app") == "blocked" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if outcome := reqcontext.GetDeliveryOutcome(r.Context()); outcome != nil {
+			outcome.Matched = true
+			if query.Get("memo") != "" {
+				outcome.Age = 2500 * time.Millisecond
+			}
+		}
+		if query.Get("country") == "de" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`[{"cid":"spotify","img":"https://somelink","cta":"Download"}]`))
+	}))
+
+	tests := []struct {
+		name         string
+		path         string
+		apiKey       string
+		at           time.Time
+		wantControl  string
+		wantAge      string
+		wantVary     string
+		wantNoHeader bool
+	}{
+		{name: "delivery", path: "/v1/delivery?country=us&app=a", wantControl: "public, max-age=60", wantVary: "X-API-Key, X-Carrier"},
+		{name: "no campaigns", path: "/v1/delivery?country=de&app=a", wantControl: "public, max-age=60", wantVary: "X-API-Key, X-Carrier"},
+		{name: "remembered match", path: "/v1/delivery?country=us&app=a&memo=1", wantControl: "public, max-age=60", wantAge: "2", wantVary: "X-API-Key, X-Carrier"},
+		{name: "user", path: "/v1/delivery?country=us&app=a&user_id=u1", wantControl: "private, max-age=60", wantVary: "X-API-Key, X-Carrier"},
+		{name: "session", path: "/v1/delivery?country=us&app=a&session_id=s1", wantControl: "private, max-age=60", wantVary: "X-API-Key, X-Carrier"},
+		{name: "end of the hour", path: "/v1/delivery?country=us&app=a", at: now.Add(59*time.Minute + 45*time.Second), wantControl: "public, max-age=15", wantVary: "X-API-Key, X-Carrier"},
+		{name: "client time", path: "/v1/delivery?country=us&app=a&time=2025-01-01T12:59:45Z", at: now.Add(59*time.Minute + 45*time.Second), wantControl: "public, max-age=60", wantVary: "X-API-Key, X-Carrier"},
+		{name: "api key", path: "/v1/delivery?country=us&app=a", apiKey: "key-1", wantControl: "no-store"},
+		{name: "error", path: "/v1/delivery?app=a", wantControl: "no-store"},
+		{name: "blocked", path: "/v1/delivery?country=us&app=blocked", wantControl: "no-store"},
+		{name: "other endpoint", path: "/health?country=us&app=a", wantNoHeader: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			if !tt.at.IsZero() {
+				now = tt.at
+			}
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if tt.wantNoHeader {
+				assert.Empty(t, w.Header().Get("Cache-Control"))
+				return
+			}
+			assert.Equal(t, tt.wantControl, w.Header().Get("Cache-Control"))
+			assert.Equal(t, tt.wantAge, w.Header().Get("Age"))
+			assert.Equal(t, tt.wantVary, w.Header().Get("Vary"))
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+)
+
+// drainPollInterval is how often Drain checks the remaining requests
+const drainPollInterval = 100 * time.Millisecond
+
+// DrainMiddleware tracks in-flight requests so shutdown can wait for them to finish.
+// Once draining started, requests arriving on kept-alive connections get a 503 with
+// Connection: close so clients retry against another instance.
+type DrainMiddleware struct {
+	logger   log.Logger
+	metrics  *metrics.CachedMetrics
+	inFlight atomic.Int64
+	draining atomic.Bool
+}
+
+// NewDrainMiddleware creates a new drain middleware, metrics may be nil
+func NewDrainMiddleware(logger log.Logger, metrics *metrics.CachedMetrics) *DrainMiddleware {
+	return &DrainMiddleware{
+		logger:  logger,
+		metrics: metrics,
+	}
+}
+
+// Middleware returns the HTTP middleware function tracking in-flight requests
+func (m *DrainMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.draining.Load() {
+			w.Header().Set("Connection", "close")
+			writeJSONError(w, http.StatusServiceUnavailable, "server is shutting down")
+			return
+		}
+
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlight returns the number of requests being served
+func (m *DrainMiddleware) InFlight() int64 {
+	return m.inFlight.Load()
+}
+
+// Drain stops admitting requests and waits until in-flight requests finished or ctx is done,
+// logging and exporting the remaining requests every second
+func (m *DrainMiddleware) Drain(ctx context.Context) error {
+	m.draining.Store(true)
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	lastReport := time.Time{}
+	for {
+		remaining := m.InFlight()
+		if m.metrics != nil {
+			m.metrics.SetShutdownRemainingRequests(int(remaining))
+		}
+		if remaining == 0 {
+			return nil
+		}
+
+		if time.Since(lastReport) >= time.Second {
+			level.Info(m.logger).Log("msg", "waiting for in-flight requests", "remaining", remaining)
+			lastReport = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	drain := NewDrainMiddleware(log.NewNopLogger(), nil)
+	mw := drain.Middleware(handler)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/delivery", nil))
+	}()
+	<-started
+	assert.Equal(t, int64(1), drain.InFlight())
+
+	// Drain times out while the request is still running
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, drain.Drain(ctx), context.DeadlineExceeded)
+
+	// New requests are turned away while draining
+	w := httptest.NewRecorder()
+	mw.ServeHTTP(w, httptest.NewRequest("GET", "/v1/delivery", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "close", w.Header().Get("Connection"))
+
+	// Drain returns once the in-flight request finished
+	close(release)
+	<-done
+	assert.NoError(t, drain.Drain(context.Background()))
+	assert.Zero(t, drain.InFlight())
+}
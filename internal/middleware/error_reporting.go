@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/errorreporter"
+)
+
+// ErrorReportingMiddleware reports server errors and panics to an error reporter
+type ErrorReportingMiddleware struct {
+	reporter errorreporter.Reporter
+}
+
+// NewErrorReportingMiddleware creates a new error reporting middleware
+func NewErrorReportingMiddleware(reporter errorreporter.Reporter) *ErrorReportingMiddleware {
+	return &ErrorReportingMiddleware{
+		reporter: reporter,
+	}
+}
+
+// Middleware returns the HTTP middleware function for error reporting
+func (m *ErrorReportingMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tags := map[string]string{
+			"method": r.Method,
+			"path":   normalizeEndpoint(r.URL.Path),
+		}
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				tags["panic"] = "true"
+				m.reporter.Report(r.Context(), fmt.Errorf("panic: %v", rec), tags)
+				// Re-panic so recovery further up the chain still applies
+				panic(rec)
+			}
+		}()
+
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: 200}
+		next.ServeHTTP(wrapped, r)
+
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			tags["status_code"] = fmt.Sprint(wrapped.statusCode)
+			m.reporter.Report(r.Context(), fmt.Errorf("%s %s returned %d", r.Method, r.URL.Path, wrapped.statusCode), tags)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/events"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// eventsMiddleware publishes a delivery event for every answered delivery request
+type eventsMiddleware struct {
+	bus  *events.Bus
+	next service.CampaignDeliveryService
+}
+
+// NewEventsMiddleware creates a new delivery events middleware
+func NewEventsMiddleware(bus *events.Bus) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
+	return func(next service.CampaignDeliveryService) service.CampaignDeliveryService {
+		return &eventsMiddleware{
+			bus:  bus,
+			next: next,
+		}
+	}
+}
+
+// GetCampaigns implements service.DeliveryService, deliveries without a match are published
+// too so fill rates can be computed downstream
+func (mw *eventsMiddleware) GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+	campaigns, err := mw.next.GetCampaigns(ctx, req)
+	if err != nil {
+		return campaigns, err
+	}
+
+	campaignIDs := make([]string, len(campaigns))
+	for i, campaign := range campaigns {
+		campaignIDs[i] = campaign.CID
+	}
+	mw.bus.Publish(events.Event{
+		Type:        events.TypeDelivery,
+		Time:        time.Now().UTC(),
+		RequestID:   reqcontext.GetRequestID(ctx),
+		ClientID:    reqcontext.GetClientID(ctx),
+		CampaignIDs: campaignIDs,
+		Country:     req.Country,
+		OS:          req.OS,
+		App:         req.App,
+		State:       req.State,
+	})
+
+	return campaigns, nil
+}
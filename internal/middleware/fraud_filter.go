@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/fraud"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// CarrierHeader is the header clients report the mobile carrier of the device in
+const CarrierHeader = "X-Carrier"
+
+// FraudFilterMiddleware runs delivery requests through the invalid traffic filters before any
+// campaign is delivered. Blocked requests are answered like requests nothing matched, with 204,
+// so bots learn nothing from the response.
+type FraudFilterMiddleware struct {
+	chain   *fraud.Chain
+	proxies TrustedProxies
+	logger  log.Logger
+	// dryRun counts and logs blocked requests but delivers to them anyway
+	dryRun bool
+}
+
+// NewFraudFilterMiddleware creates a new invalid traffic filtering middleware, client IPs are
+// taken from X-Forwarded-For only behind proxies
+func NewFraudFilterMiddleware(chain *fraud.Chain, proxies TrustedProxies, logger log.Logger, dryRun bool) *FraudFilterMiddleware {
+	return &FraudFilterMiddleware{
+		chain:   chain,
+		proxies: proxies,
+		logger:  logger,
+		dryRun:  dryRun,
+	}
+}
+
+// Middleware returns the HTTP middleware function for invalid traffic filtering
+func (m *FraudFilterMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if normalizeEndpoint(r.URL.Path) != "/v1/delivery" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		traffic := fraud.Traffic{
+			IP:        m.proxies.ClientAddr(r),
+			UserAgent: r.UserAgent(),
+			Carrier:   r.Header.Get(CarrierHeader),
+			Request: models.DeliveryRequest{
+				App:     query.Get("app"),
+				Country: query.Get("country"),
+				OS:      query.Get("os"),
+				State:   query.Get("state"),
+			},
+		}
+
+		filter := m.chain.Check(traffic)
+		if filter == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		level.Debug(m.logger).Log("msg", "invalid traffic", "filter", filter, "dry_run", m.dryRun,
+			"request_id", reqcontext.GetRequestID(r.Context()), "ip", loggableIP(r, traffic.IP.String()), "user_agent", traffic.UserAgent)
+		if m.dryRun {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
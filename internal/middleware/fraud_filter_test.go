@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/fraud"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockedCounter counts blocked traffic by filter
+type blockedCounter map[string]int
+
+func (c blockedCounter) RecordTrafficBlocked(filter string) { c[filter]++ }
+
+func TestFraudFilterMiddleware(t *testing.T) {
+	countries, _ := fraud.ParseCarrierCountries([]string{"verizon:us"})
+	newHandler := func(recorder fraud.Recorder, dryRun bool) http.Handler {
+		chain := fraud.NewChain(recorder, fraud.NewBotAgentFilter(fraud.DefaultBotAgents), fraud.NewGeoCarrierFilter(countries))
+		return NewFraudFilterMiddleware(chain, nil, log.NewNopLogger(), dryRun).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	serve := func(handler http.Handler, path, userAgent, carrier string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("User-Agent", userAgent)
+		if carrier != "" {
+			req.Header.Set(CarrierHeader, carrier)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	recorder := blockedCounter{}
+	handler := newHandler(recorder, false)
+
+	// Blocked requests look like requests nothing matched
+	assert.Equal(t, http.StatusNoContent, serve(handler, "/v1/delivery?app=com.test&country=us&os=android", "curl/8.4.0", ""))
+	assert.Equal(t, http.StatusNoContent, serve(handler, "/v1/delivery?app=com.test&country=in&os=android", "Mozilla/5.0", "Verizon"))
+	assert.Equal(t, http.StatusOK, serve(handler, "/v1/delivery?app=com.test&country=us&os=android", "Mozilla/5.0", "Verizon"))
+	assert.Equal(t, blockedCounter{fraud.FilterBotAgent: 1, fraud.FilterGeoCarrier: 1}, recorder)
+
+	// Only deliveries are filtered
+	assert.Equal(t, http.StatusOK, serve(handler, "/health", "curl/8.4.0", ""))
+
+	// A dry run counts blocked requests but still delivers to them
+	recorder = blockedCounter{}
+	assert.Equal(t, http.StatusOK, serve(newHandler(recorder, true), "/v1/delivery?app=com.test&country=us&os=android", "curl/8.4.0", ""))
+	assert.Equal(t, blockedCounter{fraud.FilterBotAgent: 1}, recorder)
+}
+
+func TestFraudFilterMiddleware_ForwardedFor(t *testing.T) {
+	datacenters := []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}
+	newHandler := func(proxies TrustedProxies) http.Handler {
+		chain := fraud.NewChain(nil, fraud.NewDatacenterFilter(datacenters))
+		return NewFraudFilterMiddleware(chain, proxies, log.NewNopLogger(), false).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	serve := func(handler http.Handler, remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest("GET", "/v1/delivery?app=com.test&country=us&os=android", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A datacenter client can't pass as another by forging X-Forwarded-For
+	handler := newHandler(nil)
+	assert.Equal(t, http.StatusNoContent, serve(handler, "203.0.113.9:41000", "1.2.3.4"))
+	assert.Equal(t, http.StatusOK, serve(handler, "198.51.100.1:41000", "203.0.113.9"))
+
+	// Behind a trusted proxy the address it forwarded is checked
+	handler = newHandler(TrustedProxies{netip.MustParsePrefix("10.0.0.0/8")})
+	assert.Equal(t, http.StatusNoContent, serve(handler, "10.0.0.2:41000", "1.2.3.4, 203.0.113.9"))
+	assert.Equal(t, http.StatusOK, serve(handler, "10.0.0.2:41000", "203.0.113.9, 198.51.100.1"))
+}
@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// Headers used for idempotent requests
+const (
+	IdempotencyKeyHeader         = "Idempotency-Key"
+	IdempotentReplayedHeader     = "Idempotent-Replayed"
+	defaultIdempotencyMaxBody    = 1 << 20
+	defaultIdempotencyMaxEntries = 10000
+)
+
+// IdempotencyConfig configures the idempotency middleware
+type IdempotencyConfig struct {
+	// TTL is how long responses are kept for replay
+	TTL time.Duration
+	// PathPrefix limits the middleware to matching paths, e.g. /v1/admin/
+	PathPrefix string
+	// MaxBodyBytes caps the request body that is fingerprinted, larger requests get a 413
+	MaxBodyBytes int64
+	// MaxEntries caps the stored records, the oldest are forgotten beyond it
+	MaxEntries int
+}
+
+// idempotencyRecord is the stored outcome of a request made with an Idempotency-Key
+type idempotencyRecord struct {
+	key         string
+	fingerprint string
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// IdempotencyMiddleware honors the Idempotency-Key header on POST, PUT and PATCH requests.
+// The first response for a key is stored and replayed for retries with the same method,
+// path and body, so a retried mutation is applied only once. Reusing a key for a different
+// request gets a 422 and a retry while the first request is still running gets a 409.
+// Server errors are not stored so the request can be retried. Records are kept in memory,
+// which is enough for admin mutations that go to a single instance.
+type IdempotencyMiddleware struct {
+	config IdempotencyConfig
+
+	mu      sync.Mutex
+	records map[string]*list.Element
+	// order holds the records from the oldest, which expires first, to the newest
+	order *list.List
+	now   func() time.Time
+}
+
+// NewIdempotencyMiddleware creates a new idempotency middleware
+func NewIdempotencyMiddleware(config IdempotencyConfig) *IdempotencyMiddleware {
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaultIdempotencyMaxBody
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = defaultIdempotencyMaxEntries
+	}
+
+	return &IdempotencyMiddleware{
+		config:  config,
+		records: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Middleware returns the HTTP middleware function for idempotent requests
+func (m *IdempotencyMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutation(r.Method) || !strings.HasPrefix(r.URL.Path, m.config.PathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, m.config.MaxBodyBytes+1))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		if int64(len(body)) > m.config.MaxBodyBytes {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(r, body)
+		record, existing := m.reserve(key, fingerprint)
+		if existing {
+			switch {
+			case record.fingerprint != fingerprint:
+				writeJSONError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+			case !record.done:
+				writeJSONError(w, http.StatusConflict, "a request with this Idempotency-Key is still in progress")
+			default:
+				replay(w, record)
+			}
+			return
+		}
+
+		recorder := &recordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			// Release the key if the handler panicked or failed so the client can retry
+			if !completed || recorder.status >= http.StatusInternalServerError {
+				m.release(key)
+				return
+			}
+			m.complete(key, recorder)
+		}()
+
+		next.ServeHTTP(recorder, r)
+		completed = true
+	})
+}
+
+// reserve returns the record stored for key, or stores an in-progress record for it, evicting
+// expired records and then the oldest ones beyond the bound
+func (m *IdempotencyMiddleware) reserve(key, fingerprint string) (idempotencyRecord, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for front := m.order.Front(); front != nil && now.After(front.Value.(*idempotencyRecord).expires); front = m.order.Front() {
+		m.remove(front)
+	}
+
+	if element, ok := m.records[key]; ok {
+		return *element.Value.(*idempotencyRecord), true
+	}
+
+	for m.order.Len() >= m.config.MaxEntries {
+		m.remove(m.order.Front())
+	}
+	m.records[key] = m.order.PushBack(&idempotencyRecord{
+		key:         key,
+		fingerprint: fingerprint,
+		expires:     now.Add(m.config.TTL),
+	})
+	return idempotencyRecord{}, false
+}
+
+// complete stores the response for replay
+func (m *IdempotencyMiddleware) complete(key string, recorder *recordingResponseWriter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if element, ok := m.records[key]; ok {
+		record := element.Value.(*idempotencyRecord)
+		record.done = true
+		record.status = recorder.status
+		record.header = recorder.Header().Clone()
+		record.body = recorder.body.Bytes()
+	}
+}
+
+// release forgets key
+func (m *IdempotencyMiddleware) release(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if element, ok := m.records[key]; ok {
+		m.remove(element)
+	}
+}
+
+// remove forgets a record, m.mu must be held
+func (m *IdempotencyMiddleware) remove(element *list.Element) {
+	m.order.Remove(element)
+	delete(m.records, element.Value.(*idempotencyRecord).key)
+}
+
+// replay writes a stored response
+func replay(w http.ResponseWriter, record idempotencyRecord) {
+	for name, values := range record.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.status)
+	w.Write(record.body)
+}
+
+// requestFingerprint identifies a request by method, path, query and body
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+"\n"+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// isMutation reports whether method changes state
+func isMutation(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+// writeJSONError writes an error response with the given status
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.NewErrorResponse(message))
+}
+
+// recordingResponseWriter passes the response through while keeping a copy of it
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingResponseWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
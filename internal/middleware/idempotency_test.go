@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	created := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		created++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%d}`, created)
+	})
+	mw := NewIdempotencyMiddleware(IdempotencyConfig{PathPrefix: "/v1/admin/"}).Middleware(handler)
+
+	send := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		return w
+	}
+
+	// The first request is applied, the retry replays its response
+	first := send("POST", "/v1/admin/campaigns", "key-1", `{"name":"spotify"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, `{"id":1}`, first.Body.String())
+
+	retry := send("POST", "/v1/admin/campaigns", "key-1", `{"name":"spotify"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, `{"id":1}`, retry.Body.String())
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 1, created)
+
+	// Reusing the key for a different request is rejected
+	reused := send("POST", "/v1/admin/campaigns", "key-1", `{"name":"duolingo"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+
+	// Server errors are not stored, the retry runs again
+	assert.Equal(t, http.StatusInternalServerError, send("POST", "/v1/admin/campaigns", "key-2", "fail").Code)
+	assert.Equal(t, http.StatusCreated, send("POST", "/v1/admin/campaigns", "key-2", "ok").Code)
+	assert.Equal(t, 2, created)
+
+	// Requests without a key, other methods and other paths pass through
+	send("POST", "/v1/admin/campaigns", "", `{"name":"spotify"}`)
+	send("GET", "/v1/admin/campaigns", "key-1", "")
+	send("POST", "/v1/delivery", "key-1", `{"name":"spotify"}`)
+	assert.Equal(t, 5, created)
+}
+
+func TestIdempotencyMiddleware_InProgress(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := NewIdempotencyMiddleware(IdempotencyConfig{PathPrefix: "/v1/admin/"}).Middleware(handler)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("PUT", "/v1/admin/logging", strings.NewReader(`{"level":"debug"}`))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		return req
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mw.ServeHTTP(httptest.NewRecorder(), newRequest())
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	mw.ServeHTTP(w, newRequest())
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	close(release)
+	<-done
+}
+
+func TestIdempotencyMiddleware_Bounded(t *testing.T) {
+	created := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		created++
+		fmt.Fprintf(w, `{"id":%d}`, created)
+	})
+	idempotency := NewIdempotencyMiddleware(IdempotencyConfig{TTL: time.Hour, PathPrefix: "/v1/admin/", MaxEntries: 2})
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	idempotency.now = func() time.Time { return now }
+	mw := idempotency.Middleware(handler)
+
+	send := func(key string) string {
+		req := httptest.NewRequest("POST", "/v1/admin/campaigns", strings.NewReader(`{"name":"spotify"}`))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	// The oldest record is forgotten beyond the bound
+	send("key-1")
+	send("key-2")
+	send("key-3")
+	assert.Equal(t, 2, idempotency.order.Len())
+	assert.Equal(t, `{"id":3}`, send("key-3"))
+	assert.Equal(t, `{"id":4}`, send("key-1"))
+
+	// Expired records are evicted
+	now = now.Add(2 * time.Hour)
+	send("key-5")
+	assert.Equal(t, 1, idempotency.order.Len())
+	assert.Len(t, idempotency.records, 1)
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/loadshed"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+)
+
+// RejectReasonLoadShed is the rejection reason for requests dropped by the load shedder
+const RejectReasonLoadShed = "load_shed"
+
+// LoadShedConfig configures the load shedding middleware
+type LoadShedConfig struct {
+	// RetryAfter is advertised to shed clients
+	RetryAfter time.Duration
+	// Endpoints are the normalized endpoints subject to shedding, e.g. /v1/delivery
+	Endpoints []string
+}
+
+// LoadShedMiddleware rejects a fraction of requests with 503 while the shedder reports
+// overload, and feeds the latency of admitted requests back into the shedder
+type LoadShedMiddleware struct {
+	shedder    *loadshed.Shedder
+	metrics    *metrics.CachedMetrics
+	retryAfter string
+	endpoints  map[string]bool
+}
+
+// NewLoadShedMiddleware creates a new load shedding middleware, metrics may be nil
+func NewLoadShedMiddleware(shedder *loadshed.Shedder, config LoadShedConfig, metrics *metrics.CachedMetrics) *LoadShedMiddleware {
+	endpoints := make(map[string]bool, len(config.Endpoints))
+	for _, endpoint := range config.Endpoints {
+		endpoints[endpoint] = true
+	}
+
+	retryAfter := int(config.RetryAfter.Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	return &LoadShedMiddleware{
+		shedder:    shedder,
+		metrics:    metrics,
+		retryAfter: strconv.Itoa(retryAfter),
+		endpoints:  endpoints,
+	}
+}
+
+// Middleware returns the HTTP middleware function for load shedding
+func (m *LoadShedMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := normalizeEndpoint(r.URL.Path)
+		if !m.endpoints[endpoint] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if m.shedder.Shed() {
+			if m.metrics != nil {
+				m.metrics.RecordRequestRejected(endpoint, RejectReasonLoadShed)
+			}
+			writeUnavailable(w, m.retryAfter, "server overloaded")
+			return
+		}
+
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		m.shedder.Observe(time.Since(start))
+	})
+}
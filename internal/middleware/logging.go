@@ -6,22 +6,31 @@ import (
 
 	"github.com/go-kit/kit/log"
 	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/logger"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
 )
 
 // loggingMiddleware implements logging middleware for DeliveryService
 type loggingMiddleware struct {
-	logger log.Logger
-	next   service.CampaignDeliveryService
+	logger   log.Logger
+	controls *logger.Controls
+	next     service.CampaignDeliveryService
 }
 
 // NewLoggingMiddleware creates a new logging middleware
-func NewLoggingMiddleware(logger log.Logger) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
+func NewLoggingMiddleware(l log.Logger) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
+	return NewLoggingMiddlewareWithControls(l, nil)
+}
+
+// NewLoggingMiddlewareWithControls creates a logging middleware that samples successful
+// requests using the runtime adjustable rate in controls, failed requests are always logged
+func NewLoggingMiddlewareWithControls(l log.Logger, controls *logger.Controls) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
 	return func(next service.CampaignDeliveryService) service.CampaignDeliveryService {
 		return &loggingMiddleware{
-			logger: logger,
-			next:   next,
+			logger:   l,
+			controls: controls,
+			next:     next,
 		}
 	}
 }
@@ -29,6 +38,11 @@ func NewLoggingMiddleware(logger log.Logger) func(service.CampaignDeliveryServic
 // GetCampaigns implements service.DeliveryService with enhanced logging
 func (mw *loggingMiddleware) GetCampaigns(ctx context.Context, req models.DeliveryRequest) (campaigns []models.CampaignResponse, err error) {
 	defer func(begin time.Time) {
+		// Apply request log sampling, errors are always logged
+		if err == nil && mw.controls != nil && !mw.controls.Sample() {
+			return
+		}
+
 		// Get request context information
 		requestID := reqcontext.GetRequestID(ctx)
 		userAgent := reqcontext.GetUserAgent(ctx)
@@ -6,18 +6,42 @@ import (
 	"strings"
 	"time"
 
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+)
+
+// Client label modes for per-customer metrics
+const (
+	ClientLabelNone      = "none"
+	ClientLabelPublisher = "publisher"
+	ClientLabelAPIKey    = "api_key"
+)
+
+// Headers identifying the calling client
+const (
+	PublisherIDHeader = "X-Publisher-ID"
+	APIKeyHeader      = "X-API-Key"
 )
 
 // MetricsMiddleware wraps HTTP handlers to collect Prometheus metrics
 type MetricsMiddleware struct {
 	metrics *metrics.CachedMetrics
+	// clientLabel selects how requests are attributed to clients, see ClientLabel* constants
+	clientLabel string
 }
 
 // NewMetricsMiddleware creates a new metrics middleware
 func NewMetricsMiddleware(metrics *metrics.CachedMetrics) *MetricsMiddleware {
+	return NewMetricsMiddlewareWithClientLabel(metrics, ClientLabelNone)
+}
+
+// NewMetricsMiddlewareWithClientLabel creates a metrics middleware that additionally
+// records per-client metrics, identifying clients by publisher ID or API key
+func NewMetricsMiddlewareWithClientLabel(metrics *metrics.CachedMetrics, clientLabel string) *MetricsMiddleware {
 	return &MetricsMiddleware{
-		metrics: metrics,
+		metrics:     metrics,
+		clientLabel: clientLabel,
 	}
 }
 
@@ -34,6 +58,12 @@ func (m *MetricsMiddleware) Middleware(next http.Handler) http.Handler {
 		m.metrics.IncRequestsInFlight(method, endpoint)
 		defer m.metrics.DecRequestsInFlight(method, endpoint)
 
+		// Attribute the request to a client so service metrics can use it too
+		client := clientIdentity(r, m.clientLabel)
+		if client != "" {
+			r = r.WithContext(reqcontext.WithClientID(r.Context(), client))
+		}
+
 		// Wrap the response writer to capture status code
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: 200}
 
@@ -43,16 +73,24 @@ func (m *MetricsMiddleware) Middleware(next http.Handler) http.Handler {
 		// Record metrics
 		duration := time.Since(start).Seconds()
 		statusCode := strconv.Itoa(wrapped.statusCode)
+		m.metrics.RecordClientRequest(client, endpoint, statusCode)
+
+		// Attach the trace ID as an exemplar so dashboards can jump to a representative trace
+		if traceID := reqcontext.GetTraceID(r.Context()); traceID != "" {
+			m.metrics.RecordHTTPRequestWithExemplar(method, endpoint, statusCode, duration, traceID)
+			return
+		}
 
 		m.metrics.RecordHTTPRequest(method, endpoint, statusCode, duration)
 	})
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
+// responseWriter wraps http.ResponseWriter to capture status code and response size
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
 	written    bool
+	bytes      int
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -67,7 +105,26 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	if !rw.written {
 		rw.WriteHeader(200)
 	}
-	return rw.ResponseWriter.Write(b)
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// clientIdentity returns the metric label identifying the client of a request
+// API keys are hashed so secrets never end up in the metrics backend
+func clientIdentity(r *http.Request, clientLabel string) string {
+	switch clientLabel {
+	case ClientLabelPublisher:
+		return r.Header.Get(PublisherIDHeader)
+	case ClientLabelAPIKey:
+		apiKey := r.Header.Get(APIKeyHeader)
+		if apiKey == "" {
+			return ""
+		}
+		return quota.KeyID(apiKey)
+	default:
+		return ""
+	}
 }
 
 // normalizeEndpoint normalizes URL paths for consistent metric labels
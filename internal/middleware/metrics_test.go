@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIdentity(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/delivery", nil)
+	req.Header.Set(PublisherIDHeader, "pub-42")
+	req.Header.Set(APIKeyHeader, "secret-key")
+
+	if got := clientIdentity(req, ClientLabelNone); got != "" {
+		t.Errorf("expected no client label when disabled, got %q", got)
+	}
+	if got := clientIdentity(req, ClientLabelPublisher); got != "pub-42" {
+		t.Errorf("expected publisher ID, got %q", got)
+	}
+
+	got := clientIdentity(req, ClientLabelAPIKey)
+	if got == "" || got == "secret-key" || len(got) != 12 {
+		t.Errorf("expected a 12 character hash of the API key, got %q", got)
+	}
+	if again := clientIdentity(req, ClientLabelAPIKey); again != got {
+		t.Errorf("expected stable API key hash, got %q and %q", got, again)
+	}
+
+	if got := clientIdentity(httptest.NewRequest("GET", "/", nil), ClientLabelAPIKey); got != "" {
+		t.Errorf("expected empty client without API key, got %q", got)
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// RejectReasonQuota is the rejection reason for requests of API keys over their monthly quota
+const RejectReasonQuota = "quota"
+
+// Headers reporting the monthly quota of the API key of a request
+const (
+	QuotaLimitHeader     = "X-Quota-Limit"
+	QuotaRemainingHeader = "X-Quota-Remaining"
+	QuotaWarningHeader   = "X-Quota-Warning"
+)
+
+// QuotaMiddleware rejects delivery requests of API keys that used their monthly quota with 429
+// and warns keys close to it in a header. Requests without an API key are not limited.
+type QuotaMiddleware struct {
+	enforcer *quota.Enforcer
+	metrics  *metrics.CachedMetrics
+}
+
+// NewQuotaMiddleware creates a new quota middleware, metrics may be nil
+func NewQuotaMiddleware(enforcer *quota.Enforcer, metrics *metrics.CachedMetrics) *QuotaMiddleware {
+	return &QuotaMiddleware{
+		enforcer: enforcer,
+		metrics:  metrics,
+	}
+}
+
+// Middleware returns the HTTP middleware function for quota enforcement
+func (m *QuotaMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := normalizeEndpoint(r.URL.Path)
+		apiKey := r.Header.Get(APIKeyHeader)
+		if endpoint != "/v1/delivery" || apiKey == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		keyID := quota.KeyID(apiKey)
+		status := m.enforcer.Check(keyID)
+		if status.Limit > 0 {
+			w.Header().Set(QuotaLimitHeader, strconv.FormatInt(status.Limit, 10))
+			w.Header().Set(QuotaRemainingHeader, strconv.FormatInt(status.Remaining(), 10))
+		}
+
+		if status.Exceeded {
+			if m.metrics != nil {
+				m.metrics.RecordRequestRejected(endpoint, RejectReasonQuota)
+			}
+			retryAfter := int(time.Until(m.enforcer.ResetsAt()).Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("monthly quota of %d deliveries exceeded", status.Limit))
+			return
+		}
+		if status.Warning {
+			w.Header().Set(QuotaWarningHeader, fmt.Sprintf("%d of %d monthly deliveries used", status.Used, status.Limit))
+		}
+
+		next.ServeHTTP(w, r.WithContext(reqcontext.WithAPIKeyID(r.Context(), keyID)))
+	})
+}
+
+// quotaUsageMiddleware counts the campaigns delivered to the API key of a request against its quota
+type quotaUsageMiddleware struct {
+	enforcer *quota.Enforcer
+	next     service.CampaignDeliveryService
+}
+
+// NewQuotaUsageMiddleware creates a new quota usage middleware, it counts the requests that passed
+// the QuotaMiddleware
+func NewQuotaUsageMiddleware(enforcer *quota.Enforcer) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
+	return func(next service.CampaignDeliveryService) service.CampaignDeliveryService {
+		return &quotaUsageMiddleware{
+			enforcer: enforcer,
+			next:     next,
+		}
+	}
+}
+
+// GetCampaigns implements service.DeliveryService, counting each delivered campaign as an impression
+func (mw *quotaUsageMiddleware) GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+	campaigns, err := mw.next.GetCampaigns(ctx, req)
+	if err != nil || len(campaigns) == 0 {
+		return campaigns, err
+	}
+
+	if keyID := reqcontext.GetAPIKeyID(ctx); keyID != "" {
+		mw.enforcer.RecordDeliveries(keyID, len(campaigns))
+	}
+	return campaigns, nil
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deliveringService delivers two campaigns to every request
+type deliveringService struct{}
+
+func (deliveringService) GetCampaigns(context.Context, models.DeliveryRequest) ([]models.CampaignResponse, error) {
+	return []models.CampaignResponse{{CID: "spotify"}, {CID: "duolingo"}}, nil
+}
+
+func TestQuotaMiddleware(t *testing.T) {
+	enforcer := quota.NewEnforcer(quota.NewMemoryStore(), quota.Config{DefaultLimit: 5, WarnFraction: 0.4})
+	svc := NewQuotaUsageMiddleware(enforcer)(deliveringService{})
+	handler := NewQuotaMiddleware(enforcer, nil).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		svc.GetCampaigns(r.Context(), models.DeliveryRequest{})
+	}))
+
+	deliver := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/delivery", nil)
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := deliver("secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get(QuotaLimitHeader))
+	assert.Equal(t, "5", w.Header().Get(QuotaRemainingHeader))
+	assert.Empty(t, w.Header().Get(QuotaWarningHeader))
+
+	// The two delivered campaigns reach the warning threshold
+	w = deliver("secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get(QuotaRemainingHeader))
+	assert.Equal(t, "2 of 5 monthly deliveries used", w.Header().Get(QuotaWarningHeader))
+	assert.Equal(t, http.StatusOK, deliver("secret").Code)
+
+	w = deliver("secret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Equal(t, int64(6), enforcer.Check(quota.KeyID("secret")).Used)
+
+	// Other keys and requests without a key are not affected
+	assert.Equal(t, http.StatusOK, deliver("other").Code)
+	w = deliver("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(QuotaLimitHeader))
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reach"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// reachMiddleware counts each delivery request in the histograms reach is estimated from
+type reachMiddleware struct {
+	estimator *reach.Estimator
+	next      service.CampaignDeliveryService
+}
+
+// NewReachMiddleware creates a new reach histogram middleware
+func NewReachMiddleware(estimator *reach.Estimator) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
+	return func(next service.CampaignDeliveryService) service.CampaignDeliveryService {
+		return &reachMiddleware{
+			estimator: estimator,
+			next:      next,
+		}
+	}
+}
+
+// GetCampaigns implements service.DeliveryService, counting the request whether or not campaigns
+// matched it
+func (mw *reachMiddleware) GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+	mw.estimator.RecordRequest(req)
+	return mw.next.GetCampaigns(ctx, req)
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// RecoveryMiddleware recovers panics in HTTP handlers and returns a clean 500 response
+type RecoveryMiddleware struct {
+	logger  log.Logger
+	metrics *metrics.CachedMetrics
+}
+
+// NewRecoveryMiddleware creates a new panic recovery middleware
+func NewRecoveryMiddleware(logger log.Logger, metrics *metrics.CachedMetrics) *RecoveryMiddleware {
+	return &RecoveryMiddleware{
+		logger:  logger,
+		metrics: metrics,
+	}
+}
+
+// Middleware returns the HTTP middleware function for panic recovery
+func (m *RecoveryMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: 200}
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			// http.ErrAbortHandler is the sanctioned way to abort a response, let net/http handle it
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			endpoint := normalizeEndpoint(r.URL.Path)
+			level.Error(m.logger).Log(
+				"msg", "panic recovered",
+				"request_id", reqcontext.GetRequestID(r.Context()),
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", fmt.Sprint(rec),
+				"stack", string(debug.Stack()),
+			)
+
+			if m.metrics != nil {
+				m.metrics.RecordPanic(endpoint)
+			}
+
+			// Headers already sent, nothing sensible left to write
+			if wrapped.written {
+				return
+			}
+
+			wrapped.Header().Set("Content-Type", "application/json")
+			wrapped.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(wrapped).Encode(models.NewErrorResponse("internal server error"))
+		}()
+
+		next.ServeHTTP(wrapped, r)
+	})
+}
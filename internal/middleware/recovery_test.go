@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	mw := NewRecoveryMiddleware(log.NewLogfmtLogger(&buf), nil)
+
+	handler := mw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest("GET", "/v1/delivery", nil)
+	req = req.WithContext(reqcontext.WithRequestID(req.Context(), "req-123"))
+	w := httptest.NewRecorder()
+
+	assert.NotPanics(t, func() { handler.ServeHTTP(w, req) })
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var response models.ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "internal server error", response.Error)
+
+	assert.Contains(t, buf.String(), "request_id=req-123")
+	assert.Contains(t, buf.String(), "panic=boom")
+}
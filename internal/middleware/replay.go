@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/signing"
+)
+
+// RejectReasonReplay is the rejection reason for requests with a missing, unsigned or stale
+// timestamp
+const RejectReasonReplay = "replay"
+
+// TimestampParam is the request parameter carrying the Unix time in seconds the client made the
+// request at
+const TimestampParam = "ts"
+
+// ReplayProtectionConfig configures the replay protection middleware
+type ReplayProtectionConfig struct {
+	// MaxSkew is how far the timestamp of a request may be from the server clock either way
+	MaxSkew time.Duration
+	// Endpoints are the normalized endpoints protected, e.g. /v1/delivery
+	Endpoints []string
+	// Secrets are the signing secrets by API key ID, see quota.KeyID. Requests of keys without
+	// one are rejected.
+	Secrets map[string]string
+}
+
+// ReplayProtectionMiddleware rejects requests of API keys to the protected endpoints without a
+// timestamp, with one further than the allowed skew from the server clock, or not signed with
+// the signing secret of their key, so captured calls can't be replayed later with another
+// timestamp. Requests carry the signature of their method, path and query string, see
+// signing.RequestPayload, in X-Adbeacon-Signature, signed at their timestamp. Requests repeated
+// within the skew are left to the nonce deduplication. Requests without an API key are not
+// checked.
+type ReplayProtectionMiddleware struct {
+	config    ReplayProtectionConfig
+	endpoints map[string]bool
+	metrics   *metrics.CachedMetrics
+	now       func() time.Time
+}
+
+// NewReplayProtectionMiddleware creates a new replay protection middleware, metrics may be nil
+func NewReplayProtectionMiddleware(config ReplayProtectionConfig, metrics *metrics.CachedMetrics) *ReplayProtectionMiddleware {
+	endpoints := make(map[string]bool, len(config.Endpoints))
+	for _, endpoint := range config.Endpoints {
+		endpoints[endpoint] = true
+	}
+	return &ReplayProtectionMiddleware{
+		config:    config,
+		endpoints: endpoints,
+		metrics:   metrics,
+		now:       time.Now,
+	}
+}
+
+// Middleware returns the HTTP middleware function for replay protection
+func (m *ReplayProtectionMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := normalizeEndpoint(r.URL.Path)
+		if !m.endpoints[endpoint] || r.Header.Get(APIKeyHeader) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		raw := query.Get(TimestampParam)
+		_, err := strconv.ParseInt(raw, 10, 64)
+		switch {
+		case raw == "":
+			m.reject(w, endpoint, http.StatusBadRequest, "ts is required, the Unix time of the request in seconds")
+			return
+		case err != nil:
+			m.reject(w, endpoint, http.StatusBadRequest, "ts must be the Unix time of the request in seconds")
+			return
+		}
+		secret, ok := m.config.Secrets[quota.KeyID(r.Header.Get(APIKeyHeader))]
+		if !ok {
+			m.reject(w, endpoint, http.StatusUnauthorized, "API key has no signing secret, its requests can't be verified")
+			return
+		}
+
+		payload := signing.RequestPayload(r.Method, r.URL.Path, query)
+		switch err := signing.Verify(secret, raw, r.Header.Get(signing.SignatureHeader), payload, m.now(), m.config.MaxSkew); {
+		case errors.Is(err, signing.ErrExpiredSignature):
+			m.reject(w, endpoint, http.StatusUnauthorized, fmt.Sprintf("request timestamp is more than %s off the server clock", m.config.MaxSkew))
+			return
+		case err != nil:
+			m.reject(w, endpoint, http.StatusUnauthorized, "invalid request signature")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *ReplayProtectionMiddleware) reject(w http.ResponseWriter, endpoint string, status int, message string) {
+	if m.metrics != nil {
+		m.metrics.RecordRequestRejected(endpoint, RejectReasonReplay)
+	}
+	writeJSONError(w, status, message)
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/signing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayProtectionMiddleware(t *testing.T) {
+	m := NewReplayProtectionMiddleware(ReplayProtectionConfig{
+		MaxSkew:   5 * time.Minute,
+		Endpoints: []string{"/v1/delivery", "/v1/track/click"},
+		Secrets:   map[string]string{quota.KeyID("key-1"): "s3cret"},
+	}, nil)
+	m.now = func() time.Time { return time.Unix(1735689600, 0) }
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// sign returns the signature of a GET of path signed with secret at the ts of path
+	sign := func(secret, path string) string {
+		req := httptest.NewRequest("GET", path, nil)
+		query := req.URL.Query()
+		return signing.Sign(secret, query.Get(TimestampParam), signing.RequestPayload("GET", req.URL.Path, query))
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		apiKey     string
+		signature  string
+		wantStatus int
+	}{
+		{name: "current", path: "/v1/delivery?country=us&ts=1735689600", apiKey: "key-1", signature: sign("s3cret", "/v1/delivery?ts=1735689600&country=us"), wantStatus: http.StatusNoContent},
+		{name: "within the skew", path: "/v1/delivery?country=us&ts=1735689400", apiKey: "key-1", signature: sign("s3cret", "/v1/delivery?country=us&ts=1735689400"), wantStatus: http.StatusNoContent},
+		{name: "client clock ahead", path: "/v1/track/click?ts=1735689800", apiKey: "key-1", signature: sign("s3cret", "/v1/track/click?ts=1735689800"), wantStatus: http.StatusNoContent},
+		{name: "replayed later", path: "/v1/delivery?country=us&ts=1735689000", apiKey: "key-1", signature: sign("s3cret", "/v1/delivery?country=us&ts=1735689000"), wantStatus: http.StatusUnauthorized},
+		{name: "too far ahead", path: "/v1/delivery?country=us&ts=1735690000", apiKey: "key-1", signature: sign("s3cret", "/v1/delivery?country=us&ts=1735690000"), wantStatus: http.StatusUnauthorized},
+		{name: "replayed with a new timestamp", path: "/v1/delivery?country=us&ts=1735689600", apiKey: "key-1", signature: sign("s3cret", "/v1/delivery?country=us&ts=1735689000"), wantStatus: http.StatusUnauthorized},
+		{name: "changed query", path: "/v1/delivery?country=ca&ts=1735689600", apiKey: "key-1", signature: sign("s3cret", "/v1/delivery?country=us&ts=1735689600"), wantStatus: http.StatusUnauthorized},
+		{name: "other secret", path: "/v1/delivery?country=us&ts=1735689600", apiKey: "key-1", signature: sign("other", "/v1/delivery?country=us&ts=1735689600"), wantStatus: http.StatusUnauthorized},
+		{name: "unsigned", path: "/v1/delivery?country=us&ts=1735689600", apiKey: "key-1", wantStatus: http.StatusUnauthorized},
+		{name: "key without a secret", path: "/v1/delivery?country=us&ts=1735689600", apiKey: "key-2", signature: sign("s3cret", "/v1/delivery?country=us&ts=1735689600"), wantStatus: http.StatusUnauthorized},
+		{name: "missing timestamp", path: "/v1/delivery?country=us", apiKey: "key-1", wantStatus: http.StatusBadRequest},
+		{name: "invalid timestamp", path: "/v1/delivery?country=us&ts=2025-01-01T00:00:00Z", apiKey: "key-1", wantStatus: http.StatusBadRequest},
+		{name: "no API key", path: "/v1/delivery?country=us", wantStatus: http.StatusNoContent},
+		{name: "unprotected endpoint", path: "/v1/track/impression", apiKey: "key-1", wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if tt.signature != "" {
+				req.Header.Set(signing.SignatureHeader, tt.signature)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
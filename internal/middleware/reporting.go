@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reporting"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// reportingMiddleware counts the deliveries of each campaign for the hourly delivery reports
+type reportingMiddleware struct {
+	aggregator *reporting.Aggregator
+	next       service.CampaignDeliveryService
+}
+
+// NewReportingMiddleware creates a new reporting middleware
+func NewReportingMiddleware(aggregator *reporting.Aggregator) func(service.CampaignDeliveryService) service.CampaignDeliveryService {
+	return func(next service.CampaignDeliveryService) service.CampaignDeliveryService {
+		return &reportingMiddleware{
+			aggregator: aggregator,
+			next:       next,
+		}
+	}
+}
+
+// GetCampaigns implements service.DeliveryService, counting each delivered campaign in the request country
+func (mw *reportingMiddleware) GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
+	campaigns, err := mw.next.GetCampaigns(ctx, req)
+	if err != nil || len(campaigns) == 0 {
+		return campaigns, err
+	}
+
+	campaignIDs := make([]string, len(campaigns))
+	for i, campaign := range campaigns {
+		campaignIDs[i] = campaign.CID
+	}
+	mw.aggregator.RecordDeliveries(campaignIDs, req.Country)
+
+	return campaigns, nil
+}
@@ -29,6 +29,12 @@ func (m *RequestIDMiddleware) Middleware(next http.Handler) http.Handler {
 			ctx = reqcontext.NewRequestContext(ctx, r.UserAgent(), r.RemoteAddr)
 		}
 
+		// Carry the trace and span IDs from an upstream W3C traceparent header if present
+		if traceID := reqcontext.ParseTraceParent(r.Header.Get("traceparent")); traceID != "" {
+			ctx = reqcontext.WithTraceID(ctx, traceID)
+			ctx = reqcontext.WithSpanID(ctx, reqcontext.ParseTraceParentSpanID(r.Header.Get("traceparent")))
+		}
+
 		// Get the request ID for response header
 		requestID := reqcontext.GetRequestID(ctx)
 
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
@@ -33,6 +34,7 @@ func (mw *serviceMetricsMiddleware) GetCampaigns(ctx context.Context, req models
 	if err == nil {
 		// Record successful campaign delivery
 		mw.metrics.RecordCampaignDelivery(req.App, req.Country, req.OS, len(campaigns))
+		mw.metrics.RecordClientDelivery(reqcontext.GetClientID(ctx), len(campaigns))
 	}
 
 	return campaigns, err
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/signing"
+)
+
+// SigningMiddleware signs delivery responses to API keys with a signing secret, so SDKs can
+// verify no intermediary changed them. Responses carry the time they were signed at in
+// X-Adbeacon-Timestamp and the signature of "{timestamp}.{body}" in X-Adbeacon-Signature, see
+// signing.Sign. Error and empty responses are signed too, requests without an API key or of keys
+// without a secret are left alone.
+type SigningMiddleware struct {
+	// secrets are the signing secrets by API key ID, see quota.KeyID
+	secrets map[string]string
+	now     func() time.Time
+}
+
+// NewSigningMiddleware creates a new signing middleware with the signing secrets by API key ID
+func NewSigningMiddleware(secrets map[string]string) *SigningMiddleware {
+	return &SigningMiddleware{
+		secrets: secrets,
+		now:     time.Now,
+	}
+}
+
+// Middleware returns the HTTP middleware function for response signing
+func (m *SigningMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get(APIKeyHeader)
+		if apiKey == "" || normalizeEndpoint(r.URL.Path) != "/v1/delivery" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		secret, ok := m.secrets[quota.KeyID(apiKey)]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The signature covers the whole body, the response is held until it's complete
+		buffered := &bufferingResponseWriter{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buffered, r)
+
+		timestamp := strconv.FormatInt(m.now().Unix(), 10)
+		w.Header().Set(signing.TimestampHeader, timestamp)
+		w.Header().Set(signing.SignatureHeader, signing.Sign(secret, timestamp, buffered.body.Bytes()))
+		w.WriteHeader(buffered.status)
+		w.Write(buffered.body.Bytes())
+	})
+}
+
+// bufferingResponseWriter holds a response back, writing headers to the underlying writer's
+type bufferingResponseWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *bufferingResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *bufferingResponseWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+		rw.wroteHeader = true
+	}
+}
+
+func (rw *bufferingResponseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.body.Write(b)
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/signing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningMiddleware(t *testing.T) {
+	m := NewSigningMiddleware(map[string]string{quota.KeyID("key-1"): "s3cret"})
+	now := time.Unix(1735689600, 0)
+	m.now = func() time.Time { return now }
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("country") == "" {
+			writeJSONError(w, http.StatusBadRequest, "country is required")
+			return
+		}
+		if r.URL.Query().Get("country") == "de" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"cid":"spotify",`))
+		w.Write([]byte(`"img":"https://somelink","cta":"Download"}]`))
+	}))
+
+	serve := func(path, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		apiKey     string
+		wantStatus int
+		wantSigned bool
+	}{
+		{name: "delivery", path: "/v1/delivery?country=us", apiKey: "key-1", wantStatus: http.StatusOK, wantSigned: true},
+		{name: "no campaigns", path: "/v1/delivery?country=de", apiKey: "key-1", wantStatus: http.StatusNoContent, wantSigned: true},
+		{name: "error", path: "/v1/delivery", apiKey: "key-1", wantStatus: http.StatusBadRequest, wantSigned: true},
+		{name: "key without a secret", path: "/v1/delivery?country=us", apiKey: "key-2", wantStatus: http.StatusOK},
+		{name: "no key", path: "/v1/delivery?country=us", wantStatus: http.StatusOK},
+		{name: "other endpoint", path: "/health?country=us", apiKey: "key-1", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.path, tt.apiKey)
+			require.Equal(t, tt.wantStatus, w.Code)
+			if !tt.wantSigned {
+				assert.Empty(t, w.Header().Get(signing.SignatureHeader))
+				return
+			}
+			assert.Equal(t, "1735689600", w.Header().Get(signing.TimestampHeader))
+			assert.NoError(t, signing.Verify("s3cret", w.Header().Get(signing.TimestampHeader), w.Header().Get(signing.SignatureHeader), w.Body.Bytes(), now, time.Minute))
+		})
+	}
+
+	w := serve("/v1/delivery?country=us", "key-1")
+	assert.Equal(t, `[{"cid":"spotify","img":"https://somelink","cta":"Download"}]`, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+)
+
+// Rejection reasons of the size limit middleware
+const (
+	RejectReasonBodyTooLarge    = "body_too_large"
+	RejectReasonTooManyParams   = "too_many_query_params"
+	RejectReasonHeadersTooLarge = "headers_too_large"
+)
+
+// SizeLimitConfig configures the size limit middleware, a zero limit disables that check
+type SizeLimitConfig struct {
+	// MaxBodyBytes caps the request body, larger bodies get a 413
+	MaxBodyBytes int64
+	// MaxQueryParams caps the number of query parameter values, more get a 414
+	MaxQueryParams int
+	// MaxHeaderBytes caps the combined size of header names and values, larger headers get a 431
+	MaxHeaderBytes int
+}
+
+// SizeLimitMiddleware rejects abusive requests before they reach the handlers
+type SizeLimitMiddleware struct {
+	config  SizeLimitConfig
+	metrics *metrics.CachedMetrics
+}
+
+// NewSizeLimitMiddleware creates a new size limit middleware, metrics may be nil
+func NewSizeLimitMiddleware(config SizeLimitConfig, metrics *metrics.CachedMetrics) *SizeLimitMiddleware {
+	return &SizeLimitMiddleware{
+		config:  config,
+		metrics: metrics,
+	}
+}
+
+// Middleware returns the HTTP middleware function for size limits
+func (m *SizeLimitMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.config.MaxHeaderBytes > 0 && headerBytes(r.Header) > m.config.MaxHeaderBytes {
+			m.reject(w, r, http.StatusRequestHeaderFieldsTooLarge, RejectReasonHeadersTooLarge, "request headers too large")
+			return
+		}
+
+		// Separators are a cheap upper bound of the parameter count, only decode when it's exceeded
+		if m.config.MaxQueryParams > 0 && strings.Count(r.URL.RawQuery, "&")+1 > m.config.MaxQueryParams &&
+			queryParamCount(r) > m.config.MaxQueryParams {
+			m.reject(w, r, http.StatusRequestURITooLong, RejectReasonTooManyParams, "too many query parameters")
+			return
+		}
+
+		if m.config.MaxBodyBytes > 0 {
+			// Reject declared oversized bodies up front, and cut off bodies that lie about their size
+			if r.ContentLength > m.config.MaxBodyBytes {
+				m.reject(w, r, http.StatusRequestEntityTooLarge, RejectReasonBodyTooLarge, "request body too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, m.config.MaxBodyBytes)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reject writes the error response and counts the rejection
+func (m *SizeLimitMiddleware) reject(w http.ResponseWriter, r *http.Request, status int, reason, message string) {
+	if m.metrics != nil {
+		m.metrics.RecordRequestRejected(normalizeEndpoint(r.URL.Path), reason)
+	}
+	writeJSONError(w, status, message)
+}
+
+// headerBytes returns the combined size of header names and values
+func headerBytes(header http.Header) int {
+	size := 0
+	for name, values := range header {
+		for _, value := range values {
+			size += len(name) + len(value)
+		}
+	}
+	return size
+}
+
+// queryParamCount counts query parameter values
+func queryParamCount(r *http.Request) int {
+	if r.URL.RawQuery == "" {
+		return 0
+	}
+	count := 0
+	for _, values := range r.URL.Query() {
+		count += len(values)
+	}
+	return count
+}
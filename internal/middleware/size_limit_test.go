@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizeLimitMiddleware(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := NewSizeLimitMiddleware(SizeLimitConfig{
+		MaxBodyBytes:   10,
+		MaxQueryParams: 3,
+		MaxHeaderBytes: 100,
+	}, nil).Middleware(handler)
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		want    int
+	}{
+		{
+			name: "within limits",
+			request: func() *http.Request {
+				return httptest.NewRequest("POST", "/v1/admin/x?a=1&b=2", strings.NewReader("small"))
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "declared body too large",
+			request: func() *http.Request {
+				return httptest.NewRequest("POST", "/v1/admin/x", strings.NewReader("much too large body"))
+			},
+			want: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "undeclared body too large",
+			request: func() *http.Request {
+				req := httptest.NewRequest("POST", "/v1/admin/x", strings.NewReader("much too large body"))
+				req.ContentLength = -1
+				return req
+			},
+			want: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:    "too many query params",
+			request: func() *http.Request { return httptest.NewRequest("GET", "/v1/delivery?a=1&a=2&b=3&c=4", nil) },
+			want:    http.StatusRequestURITooLong,
+		},
+		{
+			name: "headers too large",
+			request: func() *http.Request {
+				req := httptest.NewRequest("GET", "/v1/delivery", nil)
+				req.Header.Set("X-Large", strings.Repeat("x", 200))
+				return req
+			},
+			want: http.StatusRequestHeaderFieldsTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mw.ServeHTTP(w, tt.request())
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
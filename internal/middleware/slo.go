@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/slo"
+)
+
+// SLOMiddleware records delivery request outcomes into an SLO tracker
+type SLOMiddleware struct {
+	tracker *slo.Tracker
+}
+
+// NewSLOMiddleware creates a new SLO middleware
+func NewSLOMiddleware(tracker *slo.Tracker) *SLOMiddleware {
+	return &SLOMiddleware{
+		tracker: tracker,
+	}
+}
+
+// Middleware returns the HTTP middleware function, only the delivery endpoint counts towards the SLO
+func (m *SLOMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if normalizeEndpoint(r.URL.Path) != "/v1/delivery" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: 200}
+
+		next.ServeHTTP(wrapped, r)
+
+		m.tracker.Record(time.Since(start), wrapped.statusCode >= http.StatusInternalServerError)
+	})
+}
@@ -15,8 +15,23 @@ type Campaign struct {
 	Status    CampaignStatus `json:"status" db:"status"`
 	CreatedAt time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+	// StartsAt and EndsAt bound when the scheduler keeps the campaign active, nil when unbounded
+	StartsAt *time.Time `json:"starts_at,omitempty" db:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at,omitempty" db:"ends_at"`
+	// DeliveryBudget is the number of deliveries after which the scheduler pauses the campaign,
+	// 0 for no limit
+	DeliveryBudget int64 `json:"delivery_budget,omitempty" db:"delivery_budget"`
+	// TrafficPct is the percentage of the matching traffic the campaign is served to, for staged
+	// rollouts, 0 for all of it
+	TrafficPct int `json:"traffic_pct,omitempty" db:"traffic_pct"`
+	// Category is the competitive category of the campaign, e.g. "ride_hailing". Responses serve
+	// at most one campaign of a category, compared case-insensitively. Empty for none.
+	Category string `json:"category,omitempty" db:"category"`
 }
 
+// MaxCategoryLength is the longest campaign category stored
+const MaxCategoryLength = 64
+
 // CampaignStatus represents the status of a campaign
 type CampaignStatus string
 
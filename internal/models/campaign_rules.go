@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -8,6 +9,9 @@ import (
 type CampaignWithRules struct {
 	Campaign
 	Rules []TargetingRule `json:"rules,omitempty"`
+	// Creatives are the localized creatives of the campaign, its image and CTA are served to
+	// requests in other languages, see CampaignWithRules.Creative
+	Creatives []Creative `json:"creatives,omitempty"`
 }
 
 // Global campaign matcher instance (can be configured)
@@ -29,7 +33,17 @@ func (cwr *CampaignWithRules) MatchesRequestWithMatcher(req DeliveryRequest, mat
 	return matcher.MatchesRequest(*cwr, req)
 }
 
-// ValidateRules validates all targeting rules for this campaign
+// CompileCampaigns compiles campaigns with the default campaign matcher
+func CompileCampaigns(campaigns []CampaignWithRules) []*CompiledCampaign {
+	compiled := make([]*CompiledCampaign, len(campaigns))
+	for i, campaign := range campaigns {
+		compiled[i] = defaultCampaignMatcher.Compile(campaign)
+	}
+	return compiled
+}
+
+// ValidateRules validates all targeting rules for this campaign, followed by the rule conflicts
+// found, see RuleConflicts
 func (cwr *CampaignWithRules) ValidateRules() []error {
 	var errors []error
 
@@ -39,7 +53,56 @@ func (cwr *CampaignWithRules) ValidateRules() []error {
 		}
 	}
 
-	return errors
+	return append(errors, cwr.RuleConflicts()...)
+}
+
+// Validate checks a campaign before it is stored: the required fields, the status and every
+// targeting rule including dimension dependencies, e.g. state rules require country rules
+func (cwr *CampaignWithRules) Validate() []error {
+	var errs []error
+
+	if cwr.ID == "" {
+		errs = append(errs, errors.New("cid is required"))
+	}
+	if cwr.Name == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if cwr.Status != StatusActive && cwr.Status != StatusInactive {
+		errs = append(errs, fmt.Errorf("status must be %s or %s, got %q", StatusActive, StatusInactive, cwr.Status))
+	}
+	if cwr.StartsAt != nil && cwr.EndsAt != nil && !cwr.EndsAt.After(*cwr.StartsAt) {
+		errs = append(errs, errors.New("ends_at must be after starts_at"))
+	}
+	if cwr.DeliveryBudget < 0 {
+		errs = append(errs, fmt.Errorf("delivery_budget must not be negative, got %d", cwr.DeliveryBudget))
+	}
+	if cwr.TrafficPct < 0 || cwr.TrafficPct > 100 {
+		errs = append(errs, fmt.Errorf("traffic_pct must be between 0 and 100, got %d", cwr.TrafficPct))
+	}
+	if len(cwr.Category) > MaxCategoryLength {
+		errs = append(errs, fmt.Errorf("category must be at most %d characters, got %d", MaxCategoryLength, len(cwr.Category)))
+	}
+	errs = append(errs, ValidateCreatives(cwr.Creatives)...)
+
+	return append(errs, cwr.ValidateTargeting()...)
+}
+
+// ValidateTargeting checks the rule type and values of every targeting rule and the dimension dependencies
+func (cwr *CampaignWithRules) ValidateTargeting() []error {
+	var errs []error
+
+	registry := defaultCampaignMatcher.Registry
+	for i, rule := range cwr.Rules {
+		if !rule.RuleType.IsValid() {
+			errs = append(errs, fmt.Errorf("rule %d: rule_type must be %s or %s, got %q", i, RuleTypeInclude, RuleTypeExclude, rule.RuleType))
+			continue
+		}
+		if err := registry.ValidateRuleWithDependencies(rule, cwr.Rules); err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", i, err))
+		}
+	}
+
+	return errs
 }
 
 // GetDimensionRegistry returns the default dimension registry
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -213,3 +214,66 @@ func TestCampaignWithRules_ToResponse(t *testing.T) {
 	assert.Equal(t, "https://example.com/spotify.jpg", response.Img)
 	assert.Equal(t, "Download", response.CTA)
 }
+
+func TestCampaignWithRules_Validate(t *testing.T) {
+	valid := Campaign{ID: "spotify", Name: "Spotify", Status: StatusActive}
+	scheduleEnd := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		campaign CampaignWithRules
+		wantErrs int
+	}{
+		{
+			name: "valid campaign",
+			campaign: CampaignWithRules{Campaign: valid, Rules: []TargetingRule{
+				{Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{"us"}},
+			}},
+			wantErrs: 0,
+		},
+		{
+			name:     "missing fields and status",
+			campaign: CampaignWithRules{},
+			wantErrs: 3,
+		},
+		{
+			name: "invalid rule type",
+			campaign: CampaignWithRules{Campaign: valid, Rules: []TargetingRule{
+				{Dimension: DimensionCountry, RuleType: "only", Values: []string{"us"}},
+			}},
+			wantErrs: 1,
+		},
+		{
+			name: "unknown dimension and empty values",
+			campaign: CampaignWithRules{Campaign: valid, Rules: []TargetingRule{
+				{Dimension: "planet", RuleType: RuleTypeInclude, Values: []string{"mars"}},
+				{Dimension: DimensionOS, RuleType: RuleTypeInclude},
+			}},
+			wantErrs: 2,
+		},
+		{
+			name: "schedule and budget",
+			campaign: CampaignWithRules{Campaign: Campaign{
+				ID: "spotify", Name: "Spotify", Status: StatusActive,
+				StartsAt: &scheduleEnd, EndsAt: &scheduleEnd, DeliveryBudget: -1,
+			}},
+			wantErrs: 2,
+		},
+		{
+			name:     "traffic percentage above 100",
+			campaign: CampaignWithRules{Campaign: Campaign{ID: "spotify", Name: "Spotify", Status: StatusActive, TrafficPct: 101}},
+			wantErrs: 1,
+		},
+		{
+			name:     "category too long",
+			campaign: CampaignWithRules{Campaign: Campaign{ID: "uber", Name: "Uber", Status: StatusActive, Category: strings.Repeat("x", MaxCategoryLength+1)}},
+			wantErrs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, tt.campaign.Validate(), tt.wantErrs)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Clock tells the current time. Time-based dimensions read the time of the request, which the
+// delivery service takes from a Clock unless the client supplied one, so matching is testable.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock
+type SystemClock struct{}
+
+// Now returns time.Now()
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock always returns the same time
+type FixedClock struct {
+	Time time.Time
+}
+
+// Now returns the fixed time
+func (c FixedClock) Now() time.Time {
+	return c.Time
+}
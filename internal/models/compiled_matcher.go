@@ -0,0 +1,175 @@
+package models
+
+// ValueMatcher reports whether a normalized request value matches a compiled targeting rule
+type ValueMatcher func(value string) bool
+
+// RuleCompiler is implemented by processors that compile rules ahead of matching, e.g. into a set
+// of normalized values, so matching doesn't normalize or parse rule values on every request.
+// Rules of other processors are matched with MatchesRule.
+type RuleCompiler interface {
+	CompileRule(rule TargetingRule) ValueMatcher
+}
+
+// compiledDimension holds the compiled rules of a campaign on one dimension
+type compiledDimension struct {
+	processor DimensionProcessor
+	// personal dimensions need consent, see PersonalDimensionProcessor
+	personal bool
+	// dependent dimensions are matched by their processor against the raw rules
+	dependent                  DependentDimensionProcessor
+	includeRules, excludeRules []TargetingRule
+	include, exclude           []ValueMatcher
+}
+
+// CompiledCampaign is a campaign with its rules compiled by a CampaignMatcher, matching it gives
+// the same result as matching the campaign
+type CompiledCampaign struct {
+	CampaignWithRules
+
+	registry   *DimensionRegistry
+	version    uint64
+	dimensions []compiledDimension
+	// targeted are the distinct dimensions of the rules, in rule order
+	targeted []string
+}
+
+// TargetedDimensions returns each distinct dimension targeted by the campaign
+func (c *CompiledCampaign) TargetedDimensions() []string {
+	return c.targeted
+}
+
+// CompiledBy reports whether the campaign was compiled with the current processors of registry
+func (c *CompiledCampaign) CompiledBy(registry *DimensionRegistry) bool {
+	return c.registry == registry && c.version == registry.Version()
+}
+
+// Compile compiles the rules of campaign with the processors of the matcher registry
+func (cm *CampaignMatcher) Compile(campaign CampaignWithRules) *CompiledCampaign {
+	compiled := &CompiledCampaign{
+		CampaignWithRules: campaign,
+		registry:          cm.Registry,
+		version:           cm.Registry.Version(),
+	}
+
+	byDimension := make(map[string]int, len(campaign.Rules))
+	for _, rule := range campaign.Rules {
+		dimensionName := string(rule.Dimension)
+		index, seen := byDimension[dimensionName]
+		if !seen {
+			compiled.targeted = append(compiled.targeted, dimensionName)
+			processor, exists := cm.Registry.GetProcessor(dimensionName)
+			if !exists {
+				// Skip unknown dimensions (backward compatibility)
+				byDimension[dimensionName] = -1
+				continue
+			}
+			index = len(compiled.dimensions)
+			byDimension[dimensionName] = index
+			dimension := compiledDimension{processor: processor}
+			if personal, ok := processor.(PersonalDimensionProcessor); ok {
+				dimension.personal = personal.RequiresConsent()
+			}
+			dimension.dependent, _ = processor.(DependentDimensionProcessor)
+			compiled.dimensions = append(compiled.dimensions, dimension)
+		}
+		if index < 0 {
+			continue
+		}
+
+		dimension := &compiled.dimensions[index]
+		switch rule.RuleType {
+		case RuleTypeInclude:
+			dimension.includeRules = append(dimension.includeRules, rule)
+			dimension.include = append(dimension.include, compileRule(dimension.processor, rule))
+		case RuleTypeExclude:
+			dimension.excludeRules = append(dimension.excludeRules, rule)
+			dimension.exclude = append(dimension.exclude, compileRule(dimension.processor, rule))
+		}
+	}
+	return compiled
+}
+
+// compileRule compiles rule with processor, falling back to its MatchesRule
+func compileRule(processor DimensionProcessor, rule TargetingRule) ValueMatcher {
+	if compiler, ok := processor.(RuleCompiler); ok {
+		return compiler.CompileRule(rule)
+	}
+	return func(value string) bool {
+		return processor.MatchesRule(value, rule)
+	}
+}
+
+// compileValueSet compiles a rule matching any of its values into a set of the normalized values
+func compileValueSet(processor DimensionProcessor, rule TargetingRule) ValueMatcher {
+	if len(rule.Values) == 1 {
+		only := Intern(processor.NormalizeValue(rule.Values[0]))
+		return func(value string) bool { return value == only }
+	}
+	values := make(map[string]struct{}, len(rule.Values))
+	for _, value := range rule.Values {
+		values[Intern(processor.NormalizeValue(value))] = struct{}{}
+	}
+	return func(value string) bool {
+		_, ok := values[value]
+		return ok
+	}
+}
+
+// MatchesCompiled checks if a compiled campaign matches a delivery request. Campaigns compiled
+// by another registry, or before processors were registered, are matched like MatchesRequest.
+func (cm *CampaignMatcher) MatchesCompiled(campaign *CompiledCampaign, req DeliveryRequest) bool {
+	if !campaign.CompiledBy(cm.Registry) {
+		return cm.MatchesRequest(campaign.CampaignWithRules, req)
+	}
+
+	// Only active campaigns can match
+	if !campaign.IsActive() {
+		return false
+	}
+
+	for i := range campaign.dimensions {
+		if !cm.compiledDimensionMatches(req, &campaign.dimensions[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// compiledDimensionMatches is dimensionMatches on compiled rules
+func (cm *CampaignMatcher) compiledDimensionMatches(req DeliveryRequest, dimension *compiledDimension) bool {
+	// Without consent personal dimensions are unknown, only campaigns not requiring a value match
+	if dimension.personal && req.NoConsent {
+		return len(dimension.include) == 0
+	}
+
+	if dimension.dependent != nil {
+		return cm.matchesDependentDimension(req, dimension.includeRules, dimension.excludeRules, dimension.dependent)
+	}
+
+	requestValue := dimension.processor.GetValue(req)
+	if requestValue == "" {
+		return len(dimension.include) == 0 // No value means only match if no include rules
+	}
+	// The request value is normalized once for all rules
+	requestValue = dimension.processor.NormalizeValue(requestValue)
+
+	if len(dimension.include) > 0 {
+		matched := false
+		for _, matches := range dimension.include {
+			if matches(requestValue) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for _, matches := range dimension.exclude {
+		if matches(requestValue) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompiledCampaignMatching(t *testing.T) {
+	registry := NewDimensionRegistry()
+	registry.RegisterProcessor(NewTimeOfDayProcessor())
+	matcher := NewCampaignMatcher(registry)
+
+	campaign := CampaignWithRules{
+		Campaign: Campaign{ID: "night-owls", Status: StatusActive},
+		Rules: []TargetingRule{
+			{Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{" US", "CA "}},
+			{Dimension: DimensionOS, RuleType: RuleTypeExclude, Values: []string{"iOS"}},
+			{Dimension: DimensionTimeOfDay, RuleType: RuleTypeInclude, Values: []string{"22-23", "0-2", "12"}},
+			{Dimension: DimensionState, RuleType: RuleTypeExclude, Values: []string{"ka"}},
+			{Dimension: "unknown", RuleType: RuleTypeInclude, Values: []string{"x"}},
+		},
+	}
+	compiled := matcher.Compile(campaign)
+
+	at := func(hour int) time.Time { return time.Date(2025, 1, 1, hour, 30, 0, 0, time.UTC) }
+	tests := []struct {
+		name string
+		req  DeliveryRequest
+		want bool
+	}{
+		{"late night", DeliveryRequest{Country: "us", OS: "android", Time: at(23)}, true},
+		{"after midnight", DeliveryRequest{Country: "CA", OS: "android", Time: at(1)}, true},
+		{"noon", DeliveryRequest{Country: "us", OS: "android", Time: at(12)}, true},
+		{"afternoon", DeliveryRequest{Country: "us", OS: "android", Time: at(15)}, false},
+		{"excluded os", DeliveryRequest{Country: "us", OS: "ios", Time: at(23)}, false},
+		{"other country", DeliveryRequest{Country: "in", OS: "android", Time: at(23)}, false},
+		{"no country", DeliveryRequest{OS: "android", Time: at(23)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matcher.MatchesCompiled(compiled, tt.req); got != tt.want {
+				t.Errorf("MatchesCompiled() = %v, want %v", got, tt.want)
+			}
+			if got := matcher.MatchesRequest(campaign, tt.req); got != tt.want {
+				t.Errorf("MatchesRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	want := []string{"country", "os", "time_of_day", "state", "unknown"}
+	if got := compiled.TargetedDimensions(); len(got) != len(want) {
+		t.Errorf("TargetedDimensions() = %v, want %v", got, want)
+	}
+
+	// Inactive campaigns never match
+	campaign.Status = StatusInactive
+	if matcher.MatchesCompiled(matcher.Compile(campaign), tests[0].req) {
+		t.Error("Expected the inactive campaign not to match")
+	}
+}
+
+func TestCompiledCampaignRegistryChanges(t *testing.T) {
+	registry := NewDimensionRegistry()
+	matcher := NewCampaignMatcher(registry)
+
+	campaign := CampaignWithRules{
+		Campaign: Campaign{ID: "mobile", Status: StatusActive},
+		Rules:    []TargetingRule{{Dimension: DimensionDeviceType, RuleType: RuleTypeInclude, Values: []string{"tablet"}}},
+	}
+	req := DeliveryRequest{Country: "us", OS: "android"}
+
+	// device_type isn't registered, its rules are skipped
+	compiled := matcher.Compile(campaign)
+	if !compiled.CompiledBy(registry) || !matcher.MatchesCompiled(compiled, req) {
+		t.Fatal("Expected the campaign to match while device_type is unknown")
+	}
+
+	// Registering it makes the compiled campaign stale, it's matched like the campaign
+	registry.RegisterProcessor(NewDeviceTypeProcessor())
+	if compiled.CompiledBy(registry) {
+		t.Error("Expected the compiled campaign to be stale")
+	}
+	if matcher.MatchesCompiled(compiled, req) {
+		t.Error("Expected the stale compiled campaign not to match without a device type")
+	}
+
+	// Campaigns compiled by another registry are matched like the campaign too
+	other := NewCampaignMatcher(NewDimensionRegistry())
+	if !other.MatchesCompiled(matcher.Compile(campaign), req) {
+		t.Error("Expected the campaign to match with the other registry")
+	}
+}
+
+func BenchmarkCompiledCampaignMatching(b *testing.B) {
+	registry := NewDimensionRegistry()
+	matcher := NewCampaignMatcher(registry)
+
+	compiled := matcher.Compile(CampaignWithRules{
+		Campaign: Campaign{ID: "bench-campaign", Status: StatusActive},
+		Rules: []TargetingRule{
+			{Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{"us", "ca", "uk"}},
+			{Dimension: DimensionOS, RuleType: RuleTypeInclude, Values: []string{"android", "ios"}},
+		},
+	})
+	request := DeliveryRequest{Country: "us", OS: "android", App: "com.example.app"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matcher.MatchesCompiled(compiled, request)
+	}
+}
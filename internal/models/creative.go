@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Creative is a localized creative of a campaign, served instead of the campaign's image and CTA
+// to requests in its language
+type Creative struct {
+	// Lang is the BCP 47 language tag of the creative, e.g. "es" or "pt-BR"
+	Lang string `json:"lang"`
+	// Img is the image URL of the creative, empty to serve the image of the campaign
+	Img string `json:"img,omitempty"`
+	CTA string `json:"cta"`
+}
+
+// MaxCreatives is the most localized creatives a campaign has
+const MaxCreatives = 50
+
+// NormalizeLanguage normalizes a BCP 47 language tag for comparison, "pt_BR" becomes "pt-br"
+func NormalizeLanguage(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// baseLanguage returns the primary language subtag of a normalized tag, "pt" for "pt-br"
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(tag, "-")
+	return base
+}
+
+// validLanguage reports whether a normalized tag is a language subtag of 2 or 3 letters followed
+// by subtags of 1 to 8 letters and digits, e.g. "zh-hant-tw"
+func validLanguage(tag string) bool {
+	for i, subtag := range strings.Split(tag, "-") {
+		if i == 0 && (len(subtag) < 2 || len(subtag) > 3) || len(subtag) < 1 || len(subtag) > 8 {
+			return false
+		}
+		for _, c := range subtag {
+			if !(c >= 'a' && c <= 'z' || i > 0 && c >= '0' && c <= '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Creative returns the creative of the campaign served to requests in lang, a normalized
+// language tag: the creative of the language, else that of its base language, else the first
+// one of a regional variant of the base language. Requests without a localized creative, or
+// without a language, get the campaign's own image and CTA.
+func (c *CampaignWithRules) Creative(lang string) Creative {
+	creative := Creative{Img: c.ImageURL, CTA: c.CTA}
+	if lang == "" || len(c.Creatives) == 0 {
+		return creative
+	}
+
+	base := baseLanguage(lang)
+	var baseMatch, variantMatch *Creative
+	for i := range c.Creatives {
+		tag := NormalizeLanguage(c.Creatives[i].Lang)
+		switch {
+		case tag == lang:
+			return c.localized(c.Creatives[i])
+		case tag == base && baseMatch == nil:
+			baseMatch = &c.Creatives[i]
+		case baseLanguage(tag) == base && variantMatch == nil:
+			variantMatch = &c.Creatives[i]
+		}
+	}
+	if baseMatch != nil {
+		return c.localized(*baseMatch)
+	}
+	if variantMatch != nil {
+		return c.localized(*variantMatch)
+	}
+	return creative
+}
+
+// localized returns a localized creative, with the campaign's image when it has none
+func (c *CampaignWithRules) localized(creative Creative) Creative {
+	if creative.Img == "" {
+		creative.Img = c.ImageURL
+	}
+	return creative
+}
+
+// ResponseFor converts the campaign to the CampaignResponse served to requests in lang, a
+// normalized language tag, see CampaignWithRules.Creative
+func (c *CampaignWithRules) ResponseFor(lang string) CampaignResponse {
+	if lang == "" || len(c.Creatives) == 0 {
+		return c.ToResponse()
+	}
+	creative := c.Creative(lang)
+	return CampaignResponse{CID: c.ID, Img: creative.Img, CTA: creative.CTA}
+}
+
+// ValidateCreatives checks the localized creatives of a campaign: each needs a well-formed
+// language tag, one creative per language, and a CTA
+func ValidateCreatives(creatives []Creative) []error {
+	var errs []error
+	if len(creatives) > MaxCreatives {
+		errs = append(errs, fmt.Errorf("creatives must be at most %d, got %d", MaxCreatives, len(creatives)))
+	}
+	seen := make(map[string]bool, len(creatives))
+	for i, creative := range creatives {
+		tag := NormalizeLanguage(creative.Lang)
+		switch {
+		case tag == "":
+			errs = append(errs, fmt.Errorf("creative %d: lang is required", i))
+		case !validLanguage(tag):
+			errs = append(errs, fmt.Errorf("creative %d: lang must be a BCP 47 language tag such as es or pt-BR, got %q", i, creative.Lang))
+		case seen[tag]:
+			errs = append(errs, fmt.Errorf("creative %d: lang %s has more than one creative", i, creative.Lang))
+		}
+		seen[tag] = true
+		if strings.TrimSpace(creative.CTA) == "" {
+			errs = append(errs, fmt.Errorf("creative %d: cta is required", i))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaignWithRules_Creative(t *testing.T) {
+	campaign := CampaignWithRules{
+		Campaign: Campaign{ID: "spotify", ImageURL: "https://img/en.png", CTA: "Download"},
+		Creatives: []Creative{
+			{Lang: "pt-BR", Img: "https://img/pt-br.png", CTA: "Baixar"},
+			{Lang: "pt_PT", CTA: "Transferir"},
+			{Lang: "es", Img: "https://img/es.png", CTA: "Descargar"},
+		},
+	}
+
+	tests := []struct {
+		lang string
+		want Creative
+	}{
+		{lang: "", want: Creative{Img: "https://img/en.png", CTA: "Download"}},
+		{lang: "pt-br", want: Creative{Lang: "pt-BR", Img: "https://img/pt-br.png", CTA: "Baixar"}},
+		// Tags are compared normalized, creatives without an image keep the campaign's
+		{lang: "pt-pt", want: Creative{Lang: "pt_PT", Img: "https://img/en.png", CTA: "Transferir"}},
+		// Without a creative of the region the first one of the language is served
+		{lang: "pt-ao", want: Creative{Lang: "pt-BR", Img: "https://img/pt-br.png", CTA: "Baixar"}},
+		{lang: "es-mx", want: Creative{Lang: "es", Img: "https://img/es.png", CTA: "Descargar"}},
+		{lang: "fr", want: Creative{Img: "https://img/en.png", CTA: "Download"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, campaign.Creative(tt.lang), tt.lang)
+	}
+
+	assert.Equal(t, CampaignResponse{CID: "spotify", Img: "https://img/es.png", CTA: "Descargar"}, campaign.ResponseFor("es"))
+	assert.Equal(t, campaign.ToResponse(), campaign.ResponseFor("fr"))
+}
+
+func TestValidateCreatives(t *testing.T) {
+	assert.Empty(t, ValidateCreatives(nil))
+	assert.Empty(t, ValidateCreatives([]Creative{{Lang: "es", CTA: "Descargar"}, {Lang: "zh-Hant-TW", CTA: "下載"}}))
+
+	errs := ValidateCreatives([]Creative{
+		{Lang: "", CTA: "Go"},
+		{Lang: "spanish", CTA: "Ir"},
+		{Lang: "es", CTA: "Ir"},
+		{Lang: "ES", CTA: " "},
+	})
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	assert.Equal(t, []string{
+		"creative 0: lang is required",
+		`creative 1: lang must be a BCP 47 language tag such as es or pt-BR, got "spanish"`,
+		"creative 3: lang ES has more than one creative",
+		"creative 3: cta is required",
+	}, messages)
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"strconv"
 	"strings"
-	"time"
 )
 
 // Example custom dimension processors to demonstrate extensibility
@@ -53,6 +52,11 @@ func (dtp *DeviceTypeProcessor) ValidateRule(rule TargetingRule) error {
 	return nil
 }
 
+// CompileRule compiles rule into a set of its normalized values
+func (dtp *DeviceTypeProcessor) CompileRule(rule TargetingRule) ValueMatcher {
+	return compileValueSet(dtp, rule)
+}
+
 func (dtp *DeviceTypeProcessor) MatchesRule(requestValue string, rule TargetingRule) bool {
 	normalizedRequest := dtp.NormalizeValue(requestValue)
 
@@ -66,6 +70,9 @@ func (dtp *DeviceTypeProcessor) MatchesRule(requestValue string, rule TargetingR
 	return false
 }
 
+// ageGroups are the age ranges age_group rules can target
+var ageGroups = []string{"13-17", "18-24", "25-34", "35-44", "45-54", "55-64", "65+"}
+
 // AgeGroupProcessor handles age-based targeting
 type AgeGroupProcessor struct{}
 
@@ -77,6 +84,11 @@ func (agp *AgeGroupProcessor) GetName() string {
 	return "age_group"
 }
 
+// RequiresConsent is true, the age of the user is personal data
+func (agp *AgeGroupProcessor) RequiresConsent() bool {
+	return true
+}
+
 func (agp *AgeGroupProcessor) GetValue(req DeliveryRequest) string {
 	// This would need to be added to DeliveryRequest struct
 	return ""
@@ -91,11 +103,10 @@ func (agp *AgeGroupProcessor) ValidateRule(rule TargetingRule) error {
 		return errors.New("age_group rule must have at least one value")
 	}
 
-	validGroups := []string{"13-17", "18-24", "25-34", "35-44", "45-54", "55-64", "65+"}
 	for _, value := range rule.Values {
 		normalized := agp.NormalizeValue(value)
 		found := false
-		for _, valid := range validGroups {
+		for _, valid := range ageGroups {
 			if normalized == valid {
 				found = true
 				break
@@ -109,6 +120,11 @@ func (agp *AgeGroupProcessor) ValidateRule(rule TargetingRule) error {
 	return nil
 }
 
+// CompileRule compiles rule into a set of its normalized values
+func (agp *AgeGroupProcessor) CompileRule(rule TargetingRule) ValueMatcher {
+	return compileValueSet(agp, rule)
+}
+
 func (agp *AgeGroupProcessor) MatchesRule(requestValue string, rule TargetingRule) bool {
 	normalizedRequest := agp.NormalizeValue(requestValue)
 
@@ -122,11 +138,19 @@ func (agp *AgeGroupProcessor) MatchesRule(requestValue string, rule TargetingRul
 	return false
 }
 
-// TimeOfDayProcessor handles time-based targeting
-type TimeOfDayProcessor struct{}
+// TimeOfDayProcessor handles time-based targeting on the hour of the request time
+type TimeOfDayProcessor struct {
+	// clock is read for requests without a time
+	clock Clock
+}
 
 func NewTimeOfDayProcessor() DimensionProcessor {
-	return &TimeOfDayProcessor{}
+	return NewTimeOfDayProcessorWithClock(SystemClock{})
+}
+
+// NewTimeOfDayProcessorWithClock creates a time of day processor reading clock for requests without a time
+func NewTimeOfDayProcessorWithClock(clock Clock) DimensionProcessor {
+	return &TimeOfDayProcessor{clock: clock}
 }
 
 func (todp *TimeOfDayProcessor) GetName() string {
@@ -134,8 +158,12 @@ func (todp *TimeOfDayProcessor) GetName() string {
 }
 
 func (todp *TimeOfDayProcessor) GetValue(req DeliveryRequest) string {
-	// Get current hour (0-23)
-	return strconv.Itoa(time.Now().Hour())
+	// Hour of the request time (0-23), in its own time zone
+	now := req.Time
+	if now.IsZero() {
+		now = todp.clock.Now()
+	}
+	return strconv.Itoa(now.Hour())
 }
 
 func (todp *TimeOfDayProcessor) NormalizeValue(value string) string {
@@ -148,34 +176,8 @@ func (todp *TimeOfDayProcessor) ValidateRule(rule TargetingRule) error {
 	}
 
 	for _, value := range rule.Values {
-		// Support hour ranges like "9-17" or individual hours like "14"
-		value = strings.TrimSpace(value)
-		if strings.Contains(value, "-") {
-			// Range validation
-			parts := strings.Split(value, "-")
-			if len(parts) != 2 {
-				return errors.New("time range must be in format 'start-end'")
-			}
-
-			start, err1 := strconv.Atoi(parts[0])
-			end, err2 := strconv.Atoi(parts[1])
-
-			if err1 != nil || err2 != nil {
-				return errors.New("time range values must be integers")
-			}
-
-			if start < 0 || start > 23 || end < 0 || end > 23 {
-				return errors.New("hour values must be between 0 and 23")
-			}
-		} else {
-			// Single hour validation
-			hour, err := strconv.Atoi(value)
-			if err != nil {
-				return errors.New("hour must be an integer")
-			}
-			if hour < 0 || hour > 23 {
-				return errors.New("hour must be between 0 and 23")
-			}
+		if _, _, err := parseHourRange(value); err != nil {
+			return err
 		}
 	}
 
@@ -184,34 +186,77 @@ func (todp *TimeOfDayProcessor) ValidateRule(rule TargetingRule) error {
 
 func (todp *TimeOfDayProcessor) MatchesRule(requestValue string, rule TargetingRule) bool {
 	currentHour, err := strconv.Atoi(requestValue)
-	if err != nil {
+	if err != nil || currentHour < 0 || currentHour > 23 {
 		return false
 	}
 
 	for _, ruleValue := range rule.Values {
-		ruleValue = strings.TrimSpace(ruleValue)
-
-		if strings.Contains(ruleValue, "-") {
-			// Range matching
-			parts := strings.Split(ruleValue, "-")
-			if len(parts) == 2 {
-				start, err1 := strconv.Atoi(parts[0])
-				end, err2 := strconv.Atoi(parts[1])
-
-				if err1 == nil && err2 == nil {
-					if currentHour >= start && currentHour <= end {
-						return true
-					}
-				}
-			}
-		} else {
-			// Exact hour matching
-			ruleHour, err := strconv.Atoi(ruleValue)
-			if err == nil && currentHour == ruleHour {
-				return true
-			}
+		start, end, err := parseHourRange(ruleValue)
+		if err != nil {
+			continue
+		}
+		if currentHour >= start && currentHour <= end {
+			return true
 		}
 	}
 
 	return false
 }
+
+// CompileRule compiles rule into a table of the hours it covers, so hour ranges are parsed once
+func (todp *TimeOfDayProcessor) CompileRule(rule TargetingRule) ValueMatcher {
+	var hours [24]bool
+	for _, ruleValue := range rule.Values {
+		start, end, err := parseHourRange(ruleValue)
+		if err != nil {
+			continue
+		}
+		for hour := start; hour <= end; hour++ {
+			hours[hour] = true
+		}
+	}
+	return func(value string) bool {
+		hour, err := strconv.Atoi(value)
+		return err == nil && hour >= 0 && hour <= 23 && hours[hour]
+	}
+}
+
+// parseHourRange parses an hour range like "9-17" or a single hour like "14", which is
+// returned as a range of one hour. Ranges don't wrap around midnight, "22-2" is invalid.
+func parseHourRange(value string) (int, int, error) {
+	// Support hour ranges like "9-17" or individual hours like "14"
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "-") {
+		// Range validation
+		parts := strings.Split(value, "-")
+		if len(parts) != 2 {
+			return 0, 0, errors.New("time range must be in format 'start-end'")
+		}
+
+		start, err1 := strconv.Atoi(parts[0])
+		end, err2 := strconv.Atoi(parts[1])
+
+		if err1 != nil || err2 != nil {
+			return 0, 0, errors.New("time range values must be integers")
+		}
+
+		if start < 0 || start > 23 || end < 0 || end > 23 {
+			return 0, 0, errors.New("hour values must be between 0 and 23")
+		}
+		// A reversed range matches no hour
+		if start > end {
+			return 0, 0, errors.New("time range start must not be after its end")
+		}
+		return start, end, nil
+	}
+
+	// Single hour validation
+	hour, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, 0, errors.New("hour must be an integer")
+	}
+	if hour < 0 || hour > 23 {
+		return 0, 0, errors.New("hour must be between 0 and 23")
+	}
+	return hour, hour, nil
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"slices"
 	"strings"
 )
@@ -21,8 +22,9 @@ func (cp *CountryProcessor) GetValue(req DeliveryRequest) string {
 	return req.Country
 }
 
+// NormalizeValue normalizes ISO 3166-1 codes to lowercase alpha-2, see NormalizeCountryCode
 func (cp *CountryProcessor) NormalizeValue(value string) string {
-	return strings.ToLower(strings.TrimSpace(value))
+	return NormalizeCountryCode(value)
 }
 
 func (cp *CountryProcessor) ValidateRule(rule TargetingRule) error {
@@ -30,16 +32,21 @@ func (cp *CountryProcessor) ValidateRule(rule TargetingRule) error {
 		return errors.New("country rule must have at least one value")
 	}
 
-	// Validate country codes (basic validation)
+	// Country codes must be ISO 3166-1 alpha-2 or alpha-3 codes
 	for _, value := range rule.Values {
-		if len(strings.TrimSpace(value)) < 2 {
-			return errors.New("country code must be at least 2 characters")
+		if !IsCountryCode(value) {
+			return fmt.Errorf("country code %q is not an ISO 3166-1 alpha-2 or alpha-3 code", value)
 		}
 	}
 
 	return nil
 }
 
+// CompileRule compiles rule into a set of its normalized values
+func (cp *CountryProcessor) CompileRule(rule TargetingRule) ValueMatcher {
+	return compileValueSet(cp, rule)
+}
+
 func (cp *CountryProcessor) MatchesRule(requestValue string, rule TargetingRule) bool {
 	normalizedRequest := cp.NormalizeValue(requestValue)
 
@@ -53,6 +60,9 @@ func (cp *CountryProcessor) MatchesRule(requestValue string, rule TargetingRule)
 	return false
 }
 
+// knownOSes are the canonical OS values, rules may name others
+var knownOSes = []string{"android", "ios", "windows", "macos", "linux", "web"}
+
 // OSProcessor handles operating system targeting
 type OSProcessor struct{}
 
@@ -68,8 +78,9 @@ func (osp *OSProcessor) GetValue(req DeliveryRequest) string {
 	return req.OS
 }
 
+// NormalizeValue lowercases OS values and maps aliases to the canonical OS, see NormalizeOS
 func (osp *OSProcessor) NormalizeValue(value string) string {
-	return strings.ToLower(strings.TrimSpace(value))
+	return NormalizeOS(value)
 }
 
 func (osp *OSProcessor) ValidateRule(rule TargetingRule) error {
@@ -78,10 +89,9 @@ func (osp *OSProcessor) ValidateRule(rule TargetingRule) error {
 	}
 
 	// Validate known OS values
-	validOS := []string{"android", "ios", "windows", "macos", "linux", "web"}
 	for _, value := range rule.Values {
 		normalized := osp.NormalizeValue(value)
-		if !slices.Contains(validOS, normalized) {
+		if !slices.Contains(knownOSes, normalized) {
 			// Allow unknown OS values but warn
 			continue
 		}
@@ -90,6 +100,11 @@ func (osp *OSProcessor) ValidateRule(rule TargetingRule) error {
 	return nil
 }
 
+// CompileRule compiles rule into a set of its normalized values
+func (osp *OSProcessor) CompileRule(rule TargetingRule) ValueMatcher {
+	return compileValueSet(osp, rule)
+}
+
 func (osp *OSProcessor) MatchesRule(requestValue string, rule TargetingRule) bool {
 	normalizedRequest := osp.NormalizeValue(requestValue)
 
@@ -144,6 +159,11 @@ func (ap *AppProcessor) ValidateRule(rule TargetingRule) error {
 	return nil
 }
 
+// CompileRule compiles rule into a set of its normalized values
+func (ap *AppProcessor) CompileRule(rule TargetingRule) ValueMatcher {
+	return compileValueSet(ap, rule)
+}
+
 func (ap *AppProcessor) MatchesRule(requestValue string, rule TargetingRule) bool {
 	normalizedRequest := ap.NormalizeValue(requestValue)
 
@@ -22,9 +22,20 @@ type DimensionProcessor interface {
 	MatchesRule(requestValue string, rule TargetingRule) bool
 }
 
+// PersonalDimensionProcessor is implemented by processors of dimensions describing the user, like
+// age_group, whose values are only used with consent where GDPR applies
+type PersonalDimensionProcessor interface {
+	DimensionProcessor
+
+	// RequiresConsent reports whether the dimension needs consent to personalised ads
+	RequiresConsent() bool
+}
+
 // DimensionRegistry manages all available dimension processors
 type DimensionRegistry struct {
 	processors map[string]DimensionProcessor
+	// version counts processor registrations, campaigns compiled before one are recompiled
+	version uint64
 }
 
 // NewDimensionRegistry creates a new dimension registry with built-in processors
@@ -45,6 +56,12 @@ func NewDimensionRegistry() *DimensionRegistry {
 // RegisterProcessor adds a new dimension processor to the registry
 func (dr *DimensionRegistry) RegisterProcessor(processor DimensionProcessor) {
 	dr.processors[processor.GetName()] = processor
+	dr.version++
+}
+
+// Version changes whenever a processor is registered
+func (dr *DimensionRegistry) Version() uint64 {
+	return dr.version
 }
 
 // GetProcessor retrieves a dimension processor by name
@@ -132,6 +149,11 @@ func (cm *CampaignMatcher) dimensionMatches(req DeliveryRequest, rules []Targeti
 		}
 	}
 
+	// Without consent personal dimensions are unknown, only campaigns not requiring a value match
+	if personal, ok := processor.(PersonalDimensionProcessor); ok && req.NoConsent && personal.RequiresConsent() {
+		return len(includeRules) == 0
+	}
+
 	// Check if this is a dependent dimension processor
 	if depProcessor, ok := processor.(DependentDimensionProcessor); ok {
 		return cm.matchesDependentDimension(req, includeRules, excludeRules, depProcessor)
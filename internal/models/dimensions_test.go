@@ -133,6 +133,26 @@ func TestDimensionProcessorValidation(t *testing.T) {
 			},
 			shouldBeValid: false,
 		},
+		{
+			name:      "Valid country rule - alpha-3 code",
+			processor: NewCountryProcessor(),
+			rule: TargetingRule{
+				Dimension: DimensionCountry,
+				RuleType:  RuleTypeInclude,
+				Values:    []string{"USA", "ind"},
+			},
+			shouldBeValid: true,
+		},
+		{
+			name:      "Invalid country rule - unknown code",
+			processor: NewCountryProcessor(),
+			rule: TargetingRule{
+				Dimension: DimensionCountry,
+				RuleType:  RuleTypeInclude,
+				Values:    []string{"us", "uk"},
+			},
+			shouldBeValid: false,
+		},
 		{
 			name:      "Valid OS rule",
 			processor: NewOSProcessor(),
@@ -213,6 +233,16 @@ func TestDimensionProcessorValidation(t *testing.T) {
 			},
 			shouldBeValid: false,
 		},
+		{
+			name:      "Invalid time of day rule - reversed range",
+			processor: NewTimeOfDayProcessor(),
+			rule: TargetingRule{
+				Dimension: DimensionTimeOfDay,
+				RuleType:  RuleTypeInclude,
+				Values:    []string{"22-2"},
+			},
+			shouldBeValid: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -254,6 +284,24 @@ func TestProcessorMatching(t *testing.T) {
 			},
 			shouldMatch: false,
 		},
+		{
+			name:         "Country alpha-3 request",
+			processor:    NewCountryProcessor(),
+			requestValue: "USA",
+			rule: TargetingRule{
+				Values: []string{"us", "ca"},
+			},
+			shouldMatch: true,
+		},
+		{
+			name:         "Country alpha-3 rule",
+			processor:    NewCountryProcessor(),
+			requestValue: "ca",
+			rule: TargetingRule{
+				Values: []string{"USA", "CAN"},
+			},
+			shouldMatch: true,
+		},
 		{
 			name:         "OS exact match",
 			processor:    NewOSProcessor(),
@@ -299,6 +347,15 @@ func TestProcessorMatching(t *testing.T) {
 			},
 			shouldMatch: false,
 		},
+		{
+			name:         "Time of day - reversed range",
+			processor:    NewTimeOfDayProcessor(),
+			requestValue: "1",
+			rule: TargetingRule{
+				Values: []string{"22-2"},
+			},
+			shouldMatch: false,
+		},
 		{
 			name:         "Time of day - exact hour match",
 			processor:    NewTimeOfDayProcessor(),
@@ -320,6 +377,22 @@ func TestProcessorMatching(t *testing.T) {
 	}
 }
 
+func TestTimeOfDayProcessorValue(t *testing.T) {
+	clock := FixedClock{Time: time.Date(2025, 1, 1, 14, 30, 0, 0, time.UTC)}
+	processor := NewTimeOfDayProcessorWithClock(clock)
+
+	// Requests without a time read the clock
+	if value := processor.GetValue(DeliveryRequest{}); value != "14" {
+		t.Errorf("Expected hour 14 from the clock, got %s", value)
+	}
+
+	// The request time is used in its own time zone
+	local := time.Date(2025, 1, 1, 22, 0, 0, 0, time.FixedZone("PST", -8*3600))
+	if value := processor.GetValue(DeliveryRequest{Time: local}); value != "22" {
+		t.Errorf("Expected hour 22 from the request time, got %s", value)
+	}
+}
+
 func TestIndexKeyGeneration(t *testing.T) {
 	registry := NewDimensionRegistry()
 	matcher := NewCampaignMatcher(registry)
@@ -513,3 +586,47 @@ func BenchmarkCampaignMatching(b *testing.B) {
 		matcher.MatchesRequest(campaign, request)
 	}
 }
+
+// knownDeviceProcessor is a personal device_type dimension, every request comes from a mobile
+type knownDeviceProcessor struct {
+	DimensionProcessor
+}
+
+func (knownDeviceProcessor) GetValue(DeliveryRequest) string { return "mobile" }
+func (knownDeviceProcessor) RequiresConsent() bool           { return true }
+
+func TestPersonalDimensionsRequireConsent(t *testing.T) {
+	registry := NewDimensionRegistry()
+	registry.RegisterProcessor(knownDeviceProcessor{NewDeviceTypeProcessor()})
+	matcher := NewCampaignMatcher(registry)
+
+	campaign := func(ruleType RuleType) CampaignWithRules {
+		return CampaignWithRules{
+			Campaign: Campaign{ID: "mobile", Status: StatusActive},
+			Rules: []TargetingRule{
+				{CampaignID: "mobile", Dimension: DimensionDeviceType, RuleType: ruleType, Values: []string{"mobile"}},
+			},
+		}
+	}
+	req := DeliveryRequest{Country: "de", OS: "android", App: "com.example.app"}
+
+	if !matcher.MatchesRequest(campaign(RuleTypeInclude), req) {
+		t.Error("Expected the include rule to match with consent")
+	}
+	if matcher.MatchesRequest(campaign(RuleTypeExclude), req) {
+		t.Error("Expected the exclude rule to block with consent")
+	}
+
+	// Without consent the dimension is unknown
+	req.NoConsent = true
+	if matcher.MatchesRequest(campaign(RuleTypeInclude), req) {
+		t.Error("Expected the include rule not to match without consent")
+	}
+	if !matcher.MatchesRequest(campaign(RuleTypeExclude), req) {
+		t.Error("Expected the exclude rule not to apply without consent")
+	}
+
+	if !(&AgeGroupProcessor{}).RequiresConsent() {
+		t.Error("Expected age_group to require consent")
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The fuzz targets below run their seed corpus with go test, fuzz one with e.g.
+// go test -fuzz=FuzzTimeOfDayProcessor ./internal/models
+
+// fuzzValues splits a fuzzed string into rule values, commas separate them as in query strings
+func fuzzValues(values string) []string {
+	return strings.Split(values, ",")
+}
+
+func FuzzTargetingRule(f *testing.F) {
+	f.Add("country", "include", "us,ca", "us", "android", "", "com.spotify")
+	f.Add("os", "exclude", " iOS ", "IN", "ios", "", "app")
+	f.Add("state", "include", "gj,ka", "in", "android", "GJ", "com.duolingo")
+	f.Add("state", "exclude", "tx", "us", "web", "tx", "")
+	f.Add("app", "include", "", "ü", "🤖", "\x00", "ünïcödé")
+	f.Add("unknown", "maybe", ",,", "", "", "", "")
+
+	registry := NewDimensionRegistry()
+	matcher := NewCampaignMatcher(registry)
+
+	f.Fuzz(func(t *testing.T, dimension, ruleType, values, country, os, state, app string) {
+		rule := TargetingRule{
+			CampaignID: "fuzz",
+			Dimension:  TargetDimension(dimension),
+			RuleType:   RuleType(ruleType),
+			Values:     fuzzValues(values),
+		}
+		err := rule.Validate()
+		normalized := rule.NormalizeValues()
+		if len(normalized) != len(rule.Values) {
+			t.Fatalf("NormalizeValues returned %d values for %d", len(normalized), len(rule.Values))
+		}
+
+		// The matcher must cope with any rule set, including ones that failed validation
+		campaign := CampaignWithRules{
+			Campaign: Campaign{ID: "fuzz", Status: StatusActive},
+			Rules: []TargetingRule{
+				rule,
+				{CampaignID: "fuzz", Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{country}},
+			},
+		}
+		registry.ValidateRuleWithDependencies(rule, campaign.Rules)
+		campaign.ValidateTargeting()
+
+		req := DeliveryRequest{Country: country, OS: os, State: state, App: app}
+		req.Validate()
+		req.NormalizeValues()
+		req.MatchesRule(rule)
+		matched := matcher.MatchesRequest(campaign, req)
+
+		// Compiled rules match exactly like the rules
+		if matcher.MatchesCompiled(matcher.Compile(campaign), req) != matched {
+			t.Fatalf("compiled campaign %+v matched request %+v differently", campaign, req)
+		}
+
+		// A valid exclude rule can only narrow the audience
+		if err == nil && rule.RuleType == RuleTypeExclude {
+			withoutExclude := campaign
+			withoutExclude.Rules = campaign.Rules[1:]
+			if matched && !matcher.MatchesRequest(withoutExclude, req) {
+				t.Fatalf("adding exclude rule %+v made request %+v match", rule, req)
+			}
+		}
+	})
+}
+
+func FuzzTimeOfDayProcessor(f *testing.F) {
+	f.Add("9-17", 14)
+	f.Add("22-2", 1)
+	f.Add("14", 14)
+	f.Add("-5", 0)
+	f.Add("5-", 5)
+	f.Add("1-2-3", 2)
+	f.Add("25-30", 26)
+	f.Add("+9-+17", 10)
+	f.Add(" 0 ", 0)
+	f.Add("٩-١٧", 10)
+	f.Add("9999999999999999999999-1", 1)
+
+	processor := NewTimeOfDayProcessor()
+
+	f.Fuzz(func(t *testing.T, value string, hour int) {
+		rule := TargetingRule{Dimension: DimensionTimeOfDay, RuleType: RuleTypeInclude, Values: []string{value}}
+		err := processor.ValidateRule(rule)
+		matched := processor.MatchesRule(strconv.Itoa(hour), rule)
+		if processor.(RuleCompiler).CompileRule(rule)(strconv.Itoa(hour)) != matched {
+			t.Fatalf("compiled value %q matched hour %d differently", value, hour)
+		}
+
+		if err != nil {
+			// An invalid value never matches
+			if matched {
+				t.Fatalf("invalid value %q matched hour %d", value, hour)
+			}
+			return
+		}
+
+		// A valid value matches at least one hour of the day, and only hours of the day
+		if matched && (hour < 0 || hour > 23) {
+			t.Fatalf("value %q matched hour %d", value, hour)
+		}
+		for h := 0; h < 24; h++ {
+			if processor.MatchesRule(strconv.Itoa(h), rule) {
+				return
+			}
+		}
+		t.Fatalf("valid value %q matches no hour of the day", value)
+	})
+}
+
+func FuzzStateProcessor(f *testing.F) {
+	f.Add("in", "gj", "gj")
+	f.Add(" IN ", " Gj ", "GJ")
+	f.Add("in", "tx", "tx")
+	f.Add("us", "gj", "gj")
+	f.Add("", "", "")
+	f.Add("ın", "ǵj", "ǵj")
+	f.Add("in\x00", "gj​", "gj")
+
+	processor := NewStateProcessor()
+
+	f.Fuzz(func(t *testing.T, country, state, ruleValue string) {
+		rule := TargetingRule{Dimension: DimensionState, RuleType: RuleTypeInclude, Values: []string{ruleValue}}
+		req := DeliveryRequest{Country: country, State: state}
+
+		normalized := processor.NormalizeValue(state)
+		if processor.NormalizeValue(normalized) != normalized {
+			t.Fatalf("NormalizeValue is not idempotent for %q", state)
+		}
+
+		processor.ValidateRule(rule)
+		validErr := processor.ValidateWithDependencies(rule, req)
+		matched := processor.MatchesRuleWithDependencies(rule, req)
+		if !matched {
+			return
+		}
+
+		// A match requires the request state to be a state of the request country, which
+		// also makes the rule valid in the context of that request
+		if normalized != processor.NormalizeValue(ruleValue) {
+			t.Fatalf("state %q matched rule value %q", state, ruleValue)
+		}
+		if validErr != nil {
+			t.Fatalf("request %+v matched rule %q rejected for it: %v", req, ruleValue, validErr)
+		}
+	})
+}
+
+// FuzzResponseAppendJSON checks the hand-written response encoding against encoding/json
+func FuzzResponseAppendJSON(f *testing.F) {
+	f.Add("spotify", "https://somelink", "Download")
+	f.Add("a<b>&c", "\"quoted\" \\ path", "line\nbreak\ttab\x00\x1f\x7f")
+	f.Add("\u2028\u2029", "\xff\xfe", "ünïcødé ✓ 🎉")
+
+	f.Fuzz(func(t *testing.T, cid, img, cta string) {
+		campaigns := DeliveryResponse{{CID: cid, Img: img, CTA: cta}, {CID: cta, Img: cid, CTA: img}}
+		errResp := ErrorResponse{Error: cid, Fields: []FieldError{{Field: img, Message: cta}}}
+
+		for _, v := range []interface {
+			AppendJSON([]byte) []byte
+		}{campaigns, errResp, NewErrorResponse(cta)} {
+			want, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := v.AppendJSON(nil); !bytes.Equal(got, want) {
+				t.Fatalf("AppendJSON() = %s, want %s", got, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// updateGolden rewrites the expected matches of the matching fixtures from the current behavior,
+// run `go test ./internal/models -run TestMatchingGolden -update` and review the diff
+var updateGolden = flag.Bool("update", false, "rewrite the expected matches of the matching fixtures")
+
+// matchingFixture is a file in testdata/matching declaring campaigns and the campaigns each request
+// is expected to match:
+//
+//	description: what the file covers
+//	campaigns:
+//	  - id: spotify
+//	    status: INACTIVE        # optional, ACTIVE by default
+//	    rules:
+//	      - dimension: country
+//	        type: include
+//	        values: [us, ca]
+//	cases:
+//	  - name: us android
+//	    request: {country: us, os: android, app: com.example.app, state: ""}
+//	    matches: [spotify]      # campaign ids in any order, [] for none
+type matchingFixture struct {
+	Description string            `yaml:"description"`
+	Campaigns   []fixtureCampaign `yaml:"campaigns"`
+	Cases       []fixtureCase     `yaml:"cases"`
+}
+
+type fixtureCampaign struct {
+	ID     string         `yaml:"id"`
+	Status CampaignStatus `yaml:"status"`
+	Rules  []fixtureRule  `yaml:"rules"`
+}
+
+type fixtureRule struct {
+	Dimension TargetDimension `yaml:"dimension"`
+	Type      RuleType        `yaml:"type"`
+	Values    []string        `yaml:"values"`
+}
+
+type fixtureCase struct {
+	Name    string `yaml:"name"`
+	Request struct {
+		Country string `yaml:"country"`
+		OS      string `yaml:"os"`
+		App     string `yaml:"app"`
+		State   string `yaml:"state"`
+	} `yaml:"request"`
+	Matches []string `yaml:"matches"`
+}
+
+// campaigns converts the fixture campaigns, failing the test on invalid rules so fixtures
+// cannot silently test rules the admin API would reject
+func (f matchingFixture) campaigns(t *testing.T) []CampaignWithRules {
+	campaigns := make([]CampaignWithRules, len(f.Campaigns))
+	for i, fc := range f.Campaigns {
+		campaign := CampaignWithRules{Campaign: Campaign{ID: fc.ID, Name: fc.ID, Status: fc.Status}}
+		if campaign.Status == "" {
+			campaign.Status = StatusActive
+		}
+		for _, rule := range fc.Rules {
+			campaign.Rules = append(campaign.Rules, TargetingRule{
+				CampaignID: fc.ID,
+				Dimension:  rule.Dimension,
+				RuleType:   rule.Type,
+				Values:     rule.Values,
+			})
+		}
+		require.Empty(t, campaign.Validate(), "campaign %s", fc.ID)
+		campaigns[i] = campaign
+	}
+	return campaigns
+}
+
+func TestMatchingGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "matching", "*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	// A fresh registry so processors registered by other tests do not leak in
+	matcher := NewCampaignMatcher(NewDimensionRegistry())
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			content, err := os.ReadFile(file)
+			require.NoError(t, err)
+
+			var fixture matchingFixture
+			decoder := yaml.NewDecoder(bytes.NewReader(content))
+			decoder.KnownFields(true)
+			require.NoError(t, decoder.Decode(&fixture))
+			campaigns := fixture.campaigns(t)
+
+			actual := make([][]string, len(fixture.Cases))
+			for i, c := range fixture.Cases {
+				req := DeliveryRequest{Country: c.Request.Country, OS: c.Request.OS, App: c.Request.App, State: c.Request.State}
+				req.NormalizeValues()
+
+				actual[i] = []string{}
+				for _, campaign := range campaigns {
+					if matcher.MatchesRequest(campaign, req) {
+						actual[i] = append(actual[i], campaign.ID)
+					}
+				}
+			}
+
+			if *updateGolden {
+				require.NoError(t, writeGoldenMatches(file, content, actual))
+				return
+			}
+
+			for i, c := range fixture.Cases {
+				t.Run(c.Name, func(t *testing.T) {
+					expected := append([]string{}, c.Matches...)
+					got := append([]string{}, actual[i]...)
+					sort.Strings(expected)
+					sort.Strings(got)
+					assert.Equal(t, expected, got, "request %+v", c.Request)
+				})
+			}
+		})
+	}
+}
+
+// writeGoldenMatches replaces the matches of every case in the fixture file, keeping its comments
+func writeGoldenMatches(file string, content []byte, matches [][]string) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return err
+	}
+
+	cases := mappingValue(doc.Content[0], "cases")
+	for i, caseNode := range cases.Content {
+		sequence := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+		for _, id := range matches[i] {
+			sequence.Content = append(sequence.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: id})
+		}
+
+		if existing := mappingValue(caseNode, "matches"); existing != nil {
+			*existing = *sequence
+		} else {
+			caseNode.Content = append(caseNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "matches"}, sequence)
+		}
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	return os.WriteFile(file, out.Bytes(), 0o644)
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
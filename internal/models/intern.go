@@ -0,0 +1,37 @@
+package models
+
+import (
+	"unique"
+)
+
+// Rule values repeat across campaigns: thousands of rules target "us" or "android", and every
+// load from the database or Redis decodes its own copy of each. Interning them when campaigns
+// are loaded leaves one copy of each value in the cached campaigns.
+
+// Intern returns the canonical copy of s, equal strings interned while the canonical copy is in
+// use share its memory
+func Intern(s string) string {
+	return unique.Make(s).Value()
+}
+
+// InternValues interns the dimension, type and values of the rule in place
+func (tr *TargetingRule) InternValues() {
+	tr.Dimension = TargetDimension(Intern(string(tr.Dimension)))
+	tr.RuleType = RuleType(Intern(string(tr.RuleType)))
+	for i, value := range tr.Values {
+		tr.Values[i] = Intern(value)
+	}
+}
+
+// InternCampaigns interns the rule values of campaigns in place, rules share the campaign ID
+func InternCampaigns(campaigns []CampaignWithRules) {
+	for i := range campaigns {
+		for j := range campaigns[i].Rules {
+			rule := &campaigns[i].Rules[j]
+			rule.InternValues()
+			if rule.CampaignID == campaigns[i].ID {
+				rule.CampaignID = campaigns[i].ID
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// sameString reports whether a and b share their bytes
+func sameString(a, b string) bool {
+	return unsafe.StringData(a) == unsafe.StringData(b)
+}
+
+func TestInternCampaigns(t *testing.T) {
+	// Decoded campaigns hold their own copies of equal values
+	us := func() string { return strings.Clone("us") }
+	campaigns := []CampaignWithRules{
+		{
+			Campaign: Campaign{ID: "spotify"},
+			Rules:    []TargetingRule{{CampaignID: strings.Clone("spotify"), Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{us(), "ca"}}},
+		},
+		{
+			Campaign: Campaign{ID: "duolingo"},
+			Rules:    []TargetingRule{{CampaignID: "duolingo", Dimension: TargetDimension(strings.Clone("country")), RuleType: RuleTypeInclude, Values: []string{us()}}},
+		},
+	}
+
+	InternCampaigns(campaigns)
+
+	first, second := campaigns[0].Rules[0], campaigns[1].Rules[0]
+	if first.Values[0] != "us" || !sameString(first.Values[0], second.Values[0]) {
+		t.Errorf("Expected both campaigns to share the interned value, got %q and %q", first.Values[0], second.Values[0])
+	}
+	if !sameString(string(first.Dimension), string(second.Dimension)) {
+		t.Error("Expected both rules to share the interned dimension")
+	}
+	if !sameString(first.CampaignID, campaigns[0].ID) {
+		t.Error("Expected the rule to share the campaign ID")
+	}
+	if first.Values[1] != "ca" {
+		t.Errorf("Expected the other values to be kept, got %q", first.Values[1])
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"strings"
+)
+
+// iso3166CSV lists the ISO 3166-1 countries as alpha-2 code, alpha-3 code and name
+//
+//go:embed data/iso3166-1.csv
+var iso3166CSV string
+
+// countryCodes maps the lowercase alpha-2 and alpha-3 code of every ISO 3166-1 country to its
+// lowercase alpha-2 code
+var countryCodes = parseCountryCodes(iso3166CSV)
+
+func parseCountryCodes(data string) map[string]string {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		panic("models: invalid ISO 3166-1 dataset: " + err.Error())
+	}
+
+	codes := make(map[string]string, 2*len(records))
+	for _, record := range records[1:] { // Skip the header
+		alpha2 := strings.ToLower(record[0])
+		codes[alpha2] = alpha2
+		codes[strings.ToLower(record[1])] = alpha2
+	}
+	return codes
+}
+
+// NormalizeCountryCode lowercases an ISO 3166-1 country code and converts alpha-3 codes to
+// alpha-2, so "USA", "US" and "us" all become "us". Unknown codes are only lowercased.
+func NormalizeCountryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if alpha2, ok := countryCodes[code]; ok {
+		return alpha2
+	}
+	return code
+}
+
+// IsCountryCode reports whether code is an ISO 3166-1 alpha-2 or alpha-3 code, in any case
+func IsCountryCode(code string) bool {
+	_, ok := countryCodes[strings.ToLower(strings.TrimSpace(code))]
+	return ok
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeCountryCode(t *testing.T) {
+	tests := map[string]string{
+		"us":    "us",
+		" US ":  "us",
+		"USA":   "us",
+		"usa":   "us",
+		"GBR":   "gb",
+		"ind":   "in",
+		"CIV":   "ci",
+		"uk":    "uk", // Not ISO 3166-1, left as is
+		"ZZZ":   "zzz",
+		"":      "",
+		"Spain": "spain",
+	}
+	for code, expected := range tests {
+		assert.Equal(t, expected, NormalizeCountryCode(code), "NormalizeCountryCode(%q)", code)
+	}
+}
+
+func TestIsCountryCode(t *testing.T) {
+	for _, code := range []string{"us", "US", "usa", " DEU ", "ax", "ALA"} {
+		assert.True(t, IsCountryCode(code), "Expected %q to be a country code", code)
+	}
+	for _, code := range []string{"", "u", "uk", "eu", "xx", "usaa", "germany"} {
+		assert.False(t, IsCountryCode(code), "Expected %q not to be a country code", code)
+	}
+}
+
+func TestCountryCodesDataset(t *testing.T) {
+	alpha2 := make(map[string]bool)
+	for code, normalized := range countryCodes {
+		assert.Len(t, normalized, 2, "Expected %q to normalize to an alpha-2 code", code)
+		assert.Equal(t, normalized, countryCodes[normalized], "Expected %q to be its own alpha-2 code", normalized)
+		alpha2[normalized] = true
+	}
+	assert.Len(t, alpha2, 249)
+	assert.Len(t, countryCodes, 2*249)
+}
+
+func TestCompiledCountryMatchingAlpha3(t *testing.T) {
+	matcher := NewCampaignMatcher(NewDimensionRegistry())
+	campaign := matcher.Compile(CampaignWithRules{
+		Campaign: Campaign{ID: "spotify", Status: StatusActive},
+		Rules:    []TargetingRule{{CampaignID: "spotify", Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{"USA", "can"}}},
+	})
+
+	for _, country := range []string{"us", "USA", "ca", "CAN"} {
+		req := DeliveryRequest{Country: country, OS: "android", App: "com.spotify"}
+		req.NormalizeValues()
+		assert.True(t, matcher.MatchesCompiled(campaign, req), "Expected %q to match", country)
+	}
+	req := DeliveryRequest{Country: "deu", OS: "android", App: "com.spotify"}
+	req.NormalizeValues()
+	assert.False(t, matcher.MatchesCompiled(campaign, req))
+}
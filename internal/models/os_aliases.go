@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"sync/atomic"
+)
+
+// SDKs report the same OS under many names, "iPhone OS", "iOS" and "iphoneos" are all iOS.
+// Aliases are mapped to the canonical name when OS values are normalized, so campaigns targeting
+// "ios" match them all.
+
+// defaultOSAliases maps the OS names reported by common SDKs to the canonical OS values
+var defaultOSAliases = map[string]string{
+	"iphone os":  "ios",
+	"iphoneos":   "ios",
+	"iphone":     "ios",
+	"ipados":     "ios",
+	"android os": "android",
+	"androidos":  "android",
+	"win":        "windows",
+	"win32":      "windows",
+	"win64":      "windows",
+	"windows nt": "windows",
+	"osx":        "macos",
+	"os x":       "macos",
+	"mac os x":   "macos",
+	"macosx":     "macos",
+	"mac os":     "macos",
+	"gnu/linux":  "linux",
+}
+
+// osAliases holds the default aliases merged with the ones set by SetOSAliases
+var osAliases atomic.Pointer[map[string]string]
+
+func init() {
+	osAliases.Store(&defaultOSAliases)
+}
+
+// NormalizeOS lowercases an OS value, collapses its whitespace and maps aliases to the
+// canonical OS, so "Mac OS X" becomes "macos"
+func NormalizeOS(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if strings.ContainsAny(value, " \t") {
+		value = strings.Join(strings.Fields(value), " ")
+	}
+	if canonical, ok := (*osAliases.Load())[value]; ok {
+		return canonical
+	}
+	return value
+}
+
+// SetOSAliases extends the default aliases with aliases, which win over the defaults. Set them
+// at startup, campaigns compiled before keep the aliases they were compiled with.
+func SetOSAliases(aliases map[string]string) {
+	merged := maps.Clone(defaultOSAliases)
+	for alias, canonical := range aliases {
+		merged[strings.Join(strings.Fields(strings.ToLower(alias)), " ")] = strings.ToLower(strings.TrimSpace(canonical))
+	}
+	osAliases.Store(&merged)
+}
+
+// ParseOSAliases parses alias:os entries, e.g. "tizen os:tizen", into an alias table for
+// SetOSAliases
+func ParseOSAliases(entries []string) (map[string]string, error) {
+	aliases := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		alias, canonical, ok := strings.Cut(entry, ":")
+		alias, canonical = strings.TrimSpace(alias), strings.TrimSpace(canonical)
+		if !ok || alias == "" || canonical == "" {
+			return nil, fmt.Errorf("invalid OS alias %q, expected alias:os", entry)
+		}
+		aliases[alias] = canonical
+	}
+	return aliases, nil
+}
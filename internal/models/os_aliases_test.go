@@ -0,0 +1,76 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeOS(t *testing.T) {
+	tests := map[string]string{
+		"Android":         "android",
+		" iOS ":           "ios",
+		"iPhone OS":       "ios",
+		"iphoneos":        "ios",
+		"Win":             "windows",
+		"OSX":             "macos",
+		"Mac OS X":        "macos",
+		"mac  os\tx":      "macos",
+		"web":             "web",
+		"Tizen":           "tizen",
+		"":                "",
+		"iPhone OS 17.2":  "iphone os 17.2", // Versions aren't stripped
+		"Windows Phone 8": "windows phone 8",
+	}
+	for value, expected := range tests {
+		assert.Equal(t, expected, NormalizeOS(value), "NormalizeOS(%q)", value)
+	}
+}
+
+func TestSetOSAliases(t *testing.T) {
+	t.Cleanup(func() { SetOSAliases(nil) })
+
+	SetOSAliases(map[string]string{"Tizen OS": "Tizen", "win": "windows-desktop"})
+
+	assert.Equal(t, "tizen", NormalizeOS("tizen  os"))
+	assert.Equal(t, "windows-desktop", NormalizeOS("WIN"), "Expected the extension to win over the defaults")
+	assert.Equal(t, "macos", NormalizeOS("osx"), "Expected the defaults to be kept")
+
+	SetOSAliases(nil)
+	assert.Equal(t, "tizen os", NormalizeOS("Tizen OS"))
+	assert.Equal(t, "windows", NormalizeOS("win"))
+}
+
+func TestParseOSAliases(t *testing.T) {
+	aliases, err := ParseOSAliases([]string{"tizen os:tizen", " kaios : kaios "})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"tizen os": "tizen", "kaios": "kaios"}, aliases)
+
+	for _, entry := range []string{"tizen", ":tizen", "tizen:", " : "} {
+		_, err := ParseOSAliases([]string{entry})
+		assert.Error(t, err, "Expected %q to be rejected", entry)
+	}
+}
+
+func TestOSAliasMatching(t *testing.T) {
+	matcher := NewCampaignMatcher(NewDimensionRegistry())
+	campaign := CampaignWithRules{
+		Campaign: Campaign{ID: "desktop", Status: StatusActive},
+		Rules: []TargetingRule{
+			{CampaignID: "desktop", Dimension: DimensionOS, RuleType: RuleTypeInclude, Values: []string{"OSX", "windows"}},
+		},
+	}
+	compiled := matcher.Compile(campaign)
+
+	for _, os := range []string{"macos", "Mac OS X", "Win", "windows NT"} {
+		req := DeliveryRequest{Country: "us", OS: os, App: "com.app"}
+		req.NormalizeValues()
+		assert.True(t, matcher.MatchesCompiled(compiled, req), "Expected %q to match the compiled campaign", os)
+		assert.True(t, matcher.MatchesRequest(campaign, req), "Expected %q to match", os)
+	}
+
+	req := DeliveryRequest{Country: "us", OS: "iPhone OS", App: "com.app"}
+	req.NormalizeValues()
+	assert.False(t, matcher.MatchesCompiled(compiled, req))
+}
@@ -1,9 +1,9 @@
 package models
 
 import (
-	"errors"
 	"slices"
 	"strings"
+	"time"
 )
 
 // DeliveryRequest represents a request for ad delivery
@@ -12,32 +12,42 @@ type DeliveryRequest struct {
 	OS      string `json:"os" validate:"required,oneof=android ios"`
 	App     string `json:"app" validate:"required"`
 	State   string `json:"state,omitempty"` // I have kept this omit emtpy as this can be optional.
+	// Time is when the request is served, in the client's time zone when the client supplied it.
+	// The delivery service sets it from its clock when zero.
+	Time time.Time `json:"time"`
+	// NoConsent marks requests GDPR applies to without consent to personalised ads, dimensions
+	// describing the user don't match them, see PersonalDimensionProcessor
+	NoConsent bool `json:"no_consent,omitempty"`
+	// UserID is an optional stable, pseudonymous ID of the user, staged rollouts bucket requests by
+	// it, see Campaign.InRollout
+	UserID string `json:"user_id,omitempty"`
+	// SessionID is an optional ID of the app session, the matched campaigns are served in an order
+	// of the session, see service.DeliveryService
+	SessionID string `json:"session_id,omitempty"`
+	// Lang is the optional BCP 47 language tag of the user, campaigns with a creative in the
+	// language serve it, see CampaignWithRules.Creative
+	Lang string `json:"lang,omitempty"`
+	// Slots is the number of ad slots the response fills, one campaign each, 0 to serve every
+	// matching campaign, see PodResponse
+	Slots int `json:"slots,omitempty"`
 }
 
-// Validate validates the delivery request
+// MaxSlots is the most ad slots a delivery request fills
+const MaxSlots = 10
+
+// Validate validates the delivery request against the default rules, see RequestValidator
 func (dr *DeliveryRequest) Validate() error {
-	if dr.Country == "" {
-		return errors.New("country is required")
-	}
-	if len(dr.Country) != 2 {
-		return errors.New("country must be a 2-letter code")
-	}
-	if dr.OS == "" {
-		return errors.New("os is required")
-	}
-	if dr.App == "" {
-		return errors.New("app is required")
-	}
 	// Not doing any validation as state can be empty
-	return nil
+	return defaultRequestValidator.Validate(*dr)
 }
 
 // NormalizeValues normalizes request values for consistent comparison
 func (dr *DeliveryRequest) NormalizeValues() {
-	dr.Country = strings.ToLower(strings.TrimSpace(dr.Country))
-	dr.OS = strings.ToLower(strings.TrimSpace(dr.OS))
+	dr.Country = NormalizeCountryCode(dr.Country)           // Alpha-3 codes become alpha-2
+	dr.OS = NormalizeOS(dr.OS)                              // OS aliases become the canonical OS
 	dr.App = strings.TrimSpace(dr.App)                      // App IDs are case-sensitive
 	dr.State = strings.ToLower(strings.TrimSpace(dr.State)) // State codes are normalized
+	dr.Lang = NormalizeLanguage(dr.Lang)                    // Language tags are case-insensitive
 }
 
 // ToMap converts the request to a map for extensible dimension processing
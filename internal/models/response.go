@@ -3,11 +3,17 @@ package models
 // ErrorResponse represents error response format
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Fields lists every invalid field of a rejected request
+	Fields []FieldError `json:"fields,omitempty"`
 }
 
 // DeliveryResponse represents the delivery API response
 type DeliveryResponse []CampaignResponse
 
+// PodResponse is the delivery API response to requests filling ad slots, campaign i fills slot
+// i+1. Slots are numbered from 1 and filled in order, slots without a campaign are left out.
+type PodResponse []CampaignResponse
+
 // NewErrorResponse creates a new error response
 func NewErrorResponse(message string) ErrorResponse {
 	return ErrorResponse{Error: message}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// The AppendJSON methods encode responses without reflection, appending to a caller owned buffer
+// so the delivery hot path doesn't allocate. The output is byte for byte what encoding/json
+// produces, including its HTML escaping.
+
+// AppendJSON appends the campaign as a JSON object to dst
+func (cr CampaignResponse) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"cid":`...)
+	dst = appendJSONString(dst, cr.CID)
+	dst = append(dst, `,"img":`...)
+	dst = appendJSONString(dst, cr.Img)
+	dst = append(dst, `,"cta":`...)
+	dst = appendJSONString(dst, cr.CTA)
+	return append(dst, '}')
+}
+
+// AppendJSON appends the campaigns as a JSON array to dst, null when the response is nil
+func (dr DeliveryResponse) AppendJSON(dst []byte) []byte {
+	if dr == nil {
+		return append(dst, "null"...)
+	}
+	dst = append(dst, '[')
+	for i, campaign := range dr {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = campaign.AppendJSON(dst)
+	}
+	return append(dst, ']')
+}
+
+// AppendJSON appends the slots as a JSON object to dst, {"slots":[{"slot":1,"cid":...},...]}
+func (pr PodResponse) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"slots":[`...)
+	for i, campaign := range pr {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, `{"slot":`...)
+		dst = strconv.AppendInt(dst, int64(i+1), 10)
+		dst = append(dst, `,"cid":`...)
+		dst = appendJSONString(dst, campaign.CID)
+		dst = append(dst, `,"img":`...)
+		dst = appendJSONString(dst, campaign.Img)
+		dst = append(dst, `,"cta":`...)
+		dst = appendJSONString(dst, campaign.CTA)
+		dst = append(dst, '}')
+	}
+	return append(dst, "]}"...)
+}
+
+// AppendJSON appends the field error as a JSON object to dst
+func (fe FieldError) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"field":`...)
+	dst = appendJSONString(dst, fe.Field)
+	dst = append(dst, `,"message":`...)
+	dst = appendJSONString(dst, fe.Message)
+	return append(dst, '}')
+}
+
+// AppendJSON appends the error as a JSON object to dst, leaving out fields when there are none
+func (er ErrorResponse) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"error":`...)
+	dst = appendJSONString(dst, er.Error)
+	if len(er.Fields) > 0 {
+		dst = append(dst, `,"fields":[`...)
+		for i, field := range er.Fields {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = field.AppendJSON(dst)
+		}
+		dst = append(dst, ']')
+	}
+	return append(dst, '}')
+}
+
+const jsonHex = "0123456789abcdef"
+
+// appendJSONString appends s as a quoted JSON string, escaping it like encoding/json: HTML
+// characters, U+2028 and U+2029 are escaped and invalid UTF-8 is replaced with U+FFFD
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if jsonSafe(b) {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', jsonHex[b>>4], jsonHex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', jsonHex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// jsonSafe reports whether the ASCII byte b can be written to a JSON string as is
+func jsonSafe(b byte) bool {
+	return b >= ' ' && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&'
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestResponseAppendJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{ AppendJSON([]byte) []byte }
+		want  string
+	}{
+		{"nil campaigns", DeliveryResponse(nil), `null`},
+		{"no campaigns", DeliveryResponse{}, `[]`},
+		{
+			"campaigns",
+			DeliveryResponse{{CID: "spotify", Img: "https://somelink", CTA: "Download"}, {CID: "duolingo", Img: "https://x?a=1&b=<2>", CTA: "Install \"now\""}},
+			`[{"cid":"spotify","img":"https://somelink","cta":"Download"},{"cid":"duolingo","img":"https://x?a=1\u0026b=\u003c2\u003e","cta":"Install \"now\""}]`,
+		},
+		{"no slots", PodResponse{}, `{"slots":[]}`},
+		{
+			"slots",
+			PodResponse{{CID: "spotify", Img: "https://somelink", CTA: "Download"}, {CID: "duolingo", Img: "https://x", CTA: "Install"}},
+			`{"slots":[{"slot":1,"cid":"spotify","img":"https://somelink","cta":"Download"},{"slot":2,"cid":"duolingo","img":"https://x","cta":"Install"}]}`,
+		},
+		{"error", NewErrorResponse("missing app param"), `{"error":"missing app param"}`},
+		{
+			"error with fields",
+			ErrorResponse{Error: "invalid request", Fields: []FieldError{{Field: "country", Message: "country is required"}}},
+			`{"error":"invalid request","fields":[{"field":"country","message":"country is required"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.value.AppendJSON(nil)); got != tt.want {
+				t.Errorf("AppendJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseAppendJSONAllocations(t *testing.T) {
+	campaigns := DeliveryResponse{{CID: "spotify", Img: "https://somelink", CTA: "Download"}, {CID: "duolingo", Img: "https://x", CTA: "Install"}}
+	buf := make([]byte, 0, 512)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = campaigns.AppendJSON(buf[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("AppendJSON allocated %v times per run, want 0", allocs)
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"hash/fnv"
+)
+
+// RolloutIdentity returns what staged rollouts bucket the request by: the user ID, or the request
+// dimensions when there is none or the user did not consent to personal data. Without a user ID
+// a rollout covers a share of the request segments rather than of the users.
+func (dr *DeliveryRequest) RolloutIdentity() string {
+	if dr.UserID != "" && !dr.NoConsent {
+		return "u:" + dr.UserID
+	}
+	return "r:" + dr.Country + "|" + dr.OS + "|" + dr.App + "|" + dr.State
+}
+
+// InRollout reports whether the campaign is served to a request with the identity, see
+// DeliveryRequest.RolloutIdentity. Identities are hashed with the campaign ID into 100 buckets
+// and the first TrafficPct buckets are served, so raising the percentage keeps serving the users
+// already in the rollout, and the rollouts of different campaigns reach different users.
+func (c *Campaign) InRollout(identity string) bool {
+	return InRollout(c.ID, c.TrafficPct, identity)
+}
+
+// InRollout reports whether a campaign with the ID and traffic percentage is served to a request
+// with the identity, see Campaign.InRollout
+func InRollout(campaignID string, trafficPct int, identity string) bool {
+	if trafficPct <= 0 || trafficPct >= 100 {
+		return true
+	}
+	return rolloutBucket(campaignID, identity) < trafficPct
+}
+
+// rolloutBucket hashes the campaign ID and identity into a bucket in [0, 100)
+func rolloutBucket(campaignID, identity string) int {
+	h := fnv.New64a()
+	h.Write([]byte(campaignID))
+	h.Write([]byte{0})
+	h.Write([]byte(identity))
+	return int(h.Sum64() % 100)
+}
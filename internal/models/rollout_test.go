@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolloutIdentity(t *testing.T) {
+	req := DeliveryRequest{Country: "us", OS: "android", App: "a", UserID: "u1"}
+	assert.Equal(t, "u:u1", req.RolloutIdentity())
+
+	// Without consent the user ID isn't used
+	req.NoConsent = true
+	assert.Equal(t, "r:us|android|a|", req.RolloutIdentity())
+
+	req = DeliveryRequest{Country: "us", OS: "android", App: "a"}
+	assert.Equal(t, "r:us|android|a|", req.RolloutIdentity())
+}
+
+func TestInRollout(t *testing.T) {
+	served := func(campaign Campaign) map[string]bool {
+		users := make(map[string]bool)
+		for i := 0; i < 10000; i++ {
+			identity := "u:" + strconv.Itoa(i)
+			if campaign.InRollout(identity) {
+				users[identity] = true
+			}
+		}
+		return users
+	}
+
+	assert.Len(t, served(Campaign{ID: "a"}), 10000)
+	assert.Len(t, served(Campaign{ID: "a", TrafficPct: 100}), 10000)
+
+	// About the percentage of users is served, raising it keeps the users served before
+	ten := served(Campaign{ID: "a", TrafficPct: 10})
+	assert.InDelta(t, 1000, len(ten), 150)
+	fifty := served(Campaign{ID: "a", TrafficPct: 50})
+	assert.InDelta(t, 5000, len(fifty), 300)
+	for user := range ten {
+		assert.True(t, fifty[user], user)
+	}
+
+	// Another campaign rolls out to other users
+	other := served(Campaign{ID: "b", TrafficPct: 10})
+	shared := 0
+	for user := range other {
+		if ten[user] {
+			shared++
+		}
+	}
+	assert.Less(t, shared, 300)
+}
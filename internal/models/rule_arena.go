@@ -0,0 +1,82 @@
+package models
+
+// Loading thousands of campaigns allocates a rules slice per campaign and a values slice per rule,
+// all kept until the next load replaces them and then collected at once. A ruleArena hands out
+// those slices from shared chunks instead, so a load leaves a few large objects behind rather than
+// one per rule.
+
+const (
+	// ruleChunkSize is the number of rules allocated per chunk
+	ruleChunkSize = 1024
+	// valueChunkSize is the number of rule values allocated per chunk
+	valueChunkSize = 4096
+)
+
+// ruleArena allocates rule and value slices from chunks. Chunks are never reused, a slice stays
+// valid as long as anything references it.
+type ruleArena struct {
+	rules  []TargetingRule
+	values []string
+	// pendingRules and pendingValues are the numbers of rules and values still to allocate, no
+	// chunk is larger than needed for them
+	pendingRules  int
+	pendingValues int
+}
+
+// allocRules returns a slice of n zero rules, capped so appending to it doesn't overwrite its
+// neighbours
+func (a *ruleArena) allocRules(n int) []TargetingRule {
+	if n > len(a.rules) {
+		a.rules = make([]TargetingRule, max(n, min(a.pendingRules, ruleChunkSize)))
+	}
+	rules := a.rules[:n:n]
+	a.rules = a.rules[n:]
+	a.pendingRules -= n
+	return rules
+}
+
+// allocValues returns a slice of n empty values, capped like allocRules
+func (a *ruleArena) allocValues(n int) []string {
+	if n > len(a.values) {
+		a.values = make([]string, max(n, min(a.pendingValues, valueChunkSize)))
+	}
+	values := a.values[:n:n]
+	a.values = a.values[n:]
+	a.pendingValues -= n
+	return values
+}
+
+// CompactCampaigns returns a copy of campaigns whose rules and rule values are allocated from
+// shared chunks, with the values interned and rules sharing the campaign ID. The copy shares no
+// slices with campaigns, which can be reused to decode the next load.
+func CompactCampaigns(campaigns []CampaignWithRules) []CampaignWithRules {
+	var arena ruleArena
+	for _, campaign := range campaigns {
+		arena.pendingRules += len(campaign.Rules)
+		for _, rule := range campaign.Rules {
+			arena.pendingValues += len(rule.Values)
+		}
+	}
+
+	compacted := make([]CampaignWithRules, len(campaigns))
+	for i, campaign := range campaigns {
+		compacted[i].Campaign = campaign.Campaign
+		// Few campaigns have localized creatives, they keep their decoded slice
+		compacted[i].Creatives = campaign.Creatives
+		if len(campaign.Rules) == 0 {
+			continue
+		}
+
+		rules := arena.allocRules(len(campaign.Rules))
+		for j, rule := range campaign.Rules {
+			rules[j] = rule
+			if rule.Values != nil {
+				rules[j].Values = arena.allocValues(len(rule.Values))
+				copy(rules[j].Values, rule.Values)
+			}
+		}
+		compacted[i].Rules = rules
+	}
+	InternCampaigns(compacted)
+	return compacted
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCompactCampaigns(t *testing.T) {
+	campaigns := []CampaignWithRules{
+		{
+			Campaign: Campaign{ID: "spotify", Name: "Spotify"},
+			Rules: []TargetingRule{
+				{CampaignID: strings.Clone("spotify"), Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{strings.Clone("us"), "ca"}},
+				{CampaignID: "spotify", Dimension: DimensionOS, RuleType: RuleTypeExclude, Values: []string{"ios"}},
+			},
+		},
+		{Campaign: Campaign{ID: "norules"}, Creatives: []Creative{{Lang: "es", CTA: "Descargar"}}},
+		{
+			Campaign: Campaign{ID: "duolingo"},
+			Rules:    []TargetingRule{{CampaignID: "duolingo", Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{strings.Clone("us")}}},
+		},
+	}
+
+	compacted := CompactCampaigns(campaigns)
+
+	if len(compacted) != len(campaigns) {
+		t.Fatalf("Expected %d campaigns, got %d", len(campaigns), len(compacted))
+	}
+	for i := range campaigns {
+		if compacted[i].Campaign != campaigns[i].Campaign {
+			t.Errorf("Expected campaign %d to be copied, got %+v", i, compacted[i].Campaign)
+		}
+		if len(compacted[i].Rules) != len(campaigns[i].Rules) {
+			t.Fatalf("Expected campaign %d to have %d rules, got %d", i, len(campaigns[i].Rules), len(compacted[i].Rules))
+		}
+	}
+
+	if len(compacted[1].Creatives) != 1 || compacted[1].Creatives[0].CTA != "Descargar" {
+		t.Errorf("Expected the creatives to be copied, got %+v", compacted[1].Creatives)
+	}
+
+	first := compacted[0].Rules[0]
+	if first.Values[0] != "us" || !sameString(first.Values[0], compacted[2].Rules[0].Values[0]) {
+		t.Error("Expected the values to be interned")
+	}
+	if !sameString(first.CampaignID, compacted[0].ID) {
+		t.Error("Expected the rule to share the campaign ID")
+	}
+
+	// The source can be reused without changing the copy
+	campaigns[0].Rules[0].Values[1] = "gb"
+	campaigns[0].Rules[1].Dimension = DimensionApp
+	if first.Values[1] != "ca" || compacted[0].Rules[1].Dimension != DimensionOS {
+		t.Error("Expected the compacted rules not to share slices with the source")
+	}
+
+	// Appending to a slice of a chunk doesn't overwrite its neighbours
+	_ = append(compacted[0].Rules[0].Values, "de")
+	if compacted[0].Rules[1].Values[0] != "ios" {
+		t.Errorf("Expected appending to values to leave the next rule alone, got %q", compacted[0].Rules[1].Values[0])
+	}
+	_ = append(compacted[0].Rules, TargetingRule{})
+	if compacted[2].Rules[0].CampaignID != "duolingo" {
+		t.Error("Expected appending to rules to leave the next campaign alone")
+	}
+}
+
+func TestCompactCampaigns_SpansChunks(t *testing.T) {
+	campaigns := make([]CampaignWithRules, 3*ruleChunkSize/2)
+	for i := range campaigns {
+		id := fmt.Sprintf("campaign-%d", i)
+		campaigns[i] = CampaignWithRules{
+			Campaign: Campaign{ID: id},
+			Rules:    []TargetingRule{{CampaignID: id, Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{"us", "ca", "de", id}}},
+		}
+	}
+
+	compacted := CompactCampaigns(campaigns)
+
+	for i, campaign := range compacted {
+		if len(campaign.Rules) != 1 || campaign.Rules[0].CampaignID != campaign.ID || campaign.Rules[0].Values[3] != campaign.ID {
+			t.Fatalf("Expected campaign %d to keep its rule, got %+v", i, campaign.Rules)
+		}
+	}
+}
+
+func BenchmarkCompactCampaigns(b *testing.B) {
+	campaigns := make([]CampaignWithRules, 1000)
+	for i := range campaigns {
+		id := fmt.Sprintf("campaign-%d", i)
+		campaigns[i] = CampaignWithRules{
+			Campaign: Campaign{ID: id},
+			Rules: []TargetingRule{
+				{CampaignID: id, Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{"us", "ca"}},
+				{CampaignID: id, Dimension: DimensionOS, RuleType: RuleTypeInclude, Values: []string{"android"}},
+			},
+		}
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CompactCampaigns(campaigns)
+	}
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+)
+
+// dimensionValues tracks the normalized values a campaign includes and excludes on one
+// dimension, with the first rule naming each
+type dimensionValues struct {
+	name         string
+	included     map[string]int
+	excluded     map[string]int
+	includeRules int
+}
+
+// RuleConflicts finds contradictory and dead targeting rules: values both included and excluded,
+// values repeated across rules, rules without values after normalization and dimensions whose
+// include rules can never match. Conflicts don't make a campaign invalid, they're warnings about
+// targeting that likely doesn't do what was meant. Values are compared after normalization, so
+// "USA" and "us" are the same country.
+func (cwr *CampaignWithRules) RuleConflicts() []error {
+	var conflicts []error
+	var dimensions []*dimensionValues
+	byName := make(map[string]*dimensionValues)
+
+	for i, rule := range cwr.Rules {
+		if !rule.RuleType.IsValid() {
+			continue // Reported by ValidateTargeting
+		}
+
+		name := string(rule.Dimension)
+		dimension, seen := byName[name]
+		if !seen {
+			dimension = &dimensionValues{name: name, included: make(map[string]int), excluded: make(map[string]int)}
+			byName[name] = dimension
+			dimensions = append(dimensions, dimension)
+		}
+
+		values, opposite, verb := dimension.included, dimension.excluded, "included"
+		if rule.RuleType == RuleTypeExclude {
+			values, opposite, verb = dimension.excluded, dimension.included, "excluded"
+		} else {
+			dimension.includeRules++
+		}
+
+		empty := true
+		for _, value := range rule.NormalizeValues() {
+			if value == "" {
+				continue
+			}
+			empty = false
+
+			if first, repeated := values[value]; repeated {
+				if first == i {
+					conflicts = append(conflicts, fmt.Errorf("rule %d: %s %q is repeated", i, name, value))
+				} else {
+					conflicts = append(conflicts, fmt.Errorf("rule %d: %s %q is already %s by rule %d", i, name, value, verb, first))
+				}
+				continue
+			}
+			values[value] = i
+
+			if other, conflicting := opposite[value]; conflicting {
+				conflicts = append(conflicts, fmt.Errorf("rules %d and %d: %s %q is both included and excluded, requests with it never match", other, i, name, value))
+			}
+		}
+		if empty {
+			conflicts = append(conflicts, fmt.Errorf("rule %d: %s %s rule has no values after normalization", i, name, rule.RuleType))
+		}
+	}
+
+	for _, dimension := range dimensions {
+		if dimension.includeRules == 0 {
+			continue
+		}
+		if len(dimension.included) == 0 {
+			conflicts = append(conflicts, fmt.Errorf("%s: include rules have no values, the campaign never matches", dimension.name))
+			continue
+		}
+		if dimension.allIncludedExcluded() {
+			conflicts = append(conflicts, fmt.Errorf("%s: every included value is also excluded, the campaign never matches", dimension.name))
+		}
+	}
+
+	return conflicts
+}
+
+// allIncludedExcluded reports whether every included value is excluded too
+func (d *dimensionValues) allIncludedExcluded() bool {
+	for value := range d.included {
+		if _, excluded := d.excluded[value]; !excluded {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleConflicts(t *testing.T) {
+	rule := func(dimension TargetDimension, ruleType RuleType, values ...string) TargetingRule {
+		return TargetingRule{CampaignID: "c", Dimension: dimension, RuleType: ruleType, Values: values}
+	}
+
+	tests := []struct {
+		name  string
+		rules []TargetingRule
+		want  []string
+	}{
+		{
+			name: "no conflicts",
+			rules: []TargetingRule{
+				rule(DimensionCountry, RuleTypeInclude, "us", "ca"),
+				rule(DimensionCountry, RuleTypeExclude, "in"),
+				rule(DimensionOS, RuleTypeInclude, "android"),
+			},
+		},
+		{
+			name: "included and excluded",
+			rules: []TargetingRule{
+				rule(DimensionCountry, RuleTypeInclude, "us", "ca"),
+				rule(DimensionCountry, RuleTypeExclude, "USA"),
+			},
+			want: []string{`rules 0 and 1: country "us" is both included and excluded, requests with it never match`},
+		},
+		{
+			name: "every included value excluded",
+			rules: []TargetingRule{
+				rule(DimensionOS, RuleTypeExclude, "ios"),
+				rule(DimensionOS, RuleTypeInclude, "iPhone OS"),
+			},
+			want: []string{
+				`rules 0 and 1: os "ios" is both included and excluded, requests with it never match`,
+				"os: every included value is also excluded, the campaign never matches",
+			},
+		},
+		{
+			name: "repeated values",
+			rules: []TargetingRule{
+				rule(DimensionCountry, RuleTypeInclude, "us", "USA"),
+				rule(DimensionCountry, RuleTypeInclude, "ca", "us"),
+			},
+			want: []string{
+				`rule 0: country "us" is repeated`,
+				`rule 1: country "us" is already included by rule 0`,
+			},
+		},
+		{
+			name: "no values after normalization",
+			rules: []TargetingRule{
+				rule(DimensionApp, RuleTypeInclude, " ", ""),
+				rule(DimensionApp, RuleTypeExclude, " "),
+				rule(DimensionOS, RuleTypeInclude, "android"),
+			},
+			want: []string{
+				"rule 0: app include rule has no values after normalization",
+				"rule 1: app exclude rule has no values after normalization",
+				"app: include rules have no values, the campaign never matches",
+			},
+		},
+		{
+			name: "empty include next to other includes",
+			rules: []TargetingRule{
+				rule(DimensionApp, RuleTypeInclude, "com.spotify"),
+				rule(DimensionApp, RuleTypeInclude, " "),
+			},
+			want: []string{"rule 1: app include rule has no values after normalization"},
+		},
+		{
+			name: "invalid rule types are skipped",
+			rules: []TargetingRule{
+				rule(DimensionCountry, RuleTypeInclude, "us"),
+				rule(DimensionCountry, RuleType("maybe"), "us"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			campaign := CampaignWithRules{Campaign: Campaign{ID: "c"}, Rules: tt.rules}
+
+			var got []string
+			for _, conflict := range campaign.RuleConflicts() {
+				got = append(got, conflict.Error())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateRulesReportsConflicts(t *testing.T) {
+	campaign := CampaignWithRules{
+		Campaign: Campaign{ID: "c", Name: "Conflicting", Status: StatusActive},
+		Rules: []TargetingRule{
+			{CampaignID: "c", Dimension: DimensionCountry, RuleType: RuleTypeInclude, Values: []string{"us"}},
+			{CampaignID: "c", Dimension: DimensionCountry, RuleType: RuleTypeExclude, Values: []string{"us"}},
+		},
+	}
+
+	errs := campaign.ValidateRules()
+	if assert.Len(t, errs, 2) {
+		assert.EqualError(t, errs[0], `rules 0 and 1: country "us" is both included and excluded, requests with it never match`)
+		assert.EqualError(t, errs[1], "country: every included value is also excluded, the campaign never matches")
+	}
+	assert.Empty(t, campaign.Validate(), "Expected conflicts not to make the campaign invalid")
+}
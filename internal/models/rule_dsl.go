@@ -0,0 +1,208 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RuleSyntaxError is a targeting expression that can't be parsed, Offset is the byte offset of
+// the problem in the expression
+type RuleSyntaxError struct {
+	Offset  int
+	Message string
+}
+
+func (e *RuleSyntaxError) Error() string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Message)
+}
+
+// ParseRules parses a targeting expression into rules, one per clause in order. Clauses are
+// joined with "and" and name a dimension, an operator and values:
+//
+//	country in (us, ca) and os = android and app not in (com.blocked.app)
+//
+// "in" and "=" include the values, "not in" and "!=" exclude them. "=" and "!=" take one value,
+// "in" and "not in" a parenthesized list. Values are written bare or double-quoted when they hold
+// spaces, commas or parentheses. Keywords are case-insensitive, dimensions and values are kept as
+// written and validated like any rule. An empty expression has no rules.
+func ParseRules(expr string) ([]TargetingRule, error) {
+	p := &ruleParser{expr: expr}
+	var rules []TargetingRule
+	p.skipSpace()
+	if p.pos == len(p.expr) {
+		return rules, nil
+	}
+	for {
+		rule, err := p.clause()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+
+		p.skipSpace()
+		if p.pos == len(p.expr) {
+			return rules, nil
+		}
+		word, start := p.word()
+		switch strings.ToLower(word) {
+		case "and":
+		case "or":
+			return nil, p.errorAt(start, `"or" is not supported, list alternatives with "in (...)"`)
+		default:
+			return nil, p.errorAt(start, fmt.Sprintf(`expected "and", found %s`, p.found(word)))
+		}
+	}
+}
+
+// ruleParser reads a targeting expression from left to right
+type ruleParser struct {
+	expr string
+	pos  int
+}
+
+// clause parses a dimension, an operator and its values
+func (p *ruleParser) clause() (TargetingRule, error) {
+	p.skipSpace()
+	dimension, start := p.word()
+	if !isDimensionName(dimension) {
+		return TargetingRule{}, p.errorAt(start, fmt.Sprintf("expected a dimension, found %s", p.found(dimension)))
+	}
+	rule := TargetingRule{Dimension: TargetDimension(dimension)}
+
+	p.skipSpace()
+	list := true
+	switch {
+	case strings.HasPrefix(p.expr[p.pos:], "!="):
+		p.pos += 2
+		rule.RuleType, list = RuleTypeExclude, false
+	case strings.HasPrefix(p.expr[p.pos:], "="):
+		p.pos++
+		rule.RuleType, list = RuleTypeInclude, false
+	default:
+		op, start := p.word()
+		switch strings.ToLower(op) {
+		case "in":
+			rule.RuleType = RuleTypeInclude
+		case "not":
+			p.skipSpace()
+			if in, start := p.word(); strings.ToLower(in) != "in" {
+				return TargetingRule{}, p.errorAt(start, fmt.Sprintf(`expected "in" after "not", found %s`, p.found(in)))
+			}
+			rule.RuleType = RuleTypeExclude
+		default:
+			return TargetingRule{}, p.errorAt(start, fmt.Sprintf(`expected "in", "not in", "=" or "!=" after %s, found %s`, dimension, p.found(op)))
+		}
+	}
+
+	var err error
+	if list {
+		rule.Values, err = p.valueList()
+	} else {
+		var value string
+		value, err = p.value()
+		rule.Values = []string{value}
+	}
+	return rule, err
+}
+
+// valueList parses a parenthesized, comma separated list of values
+func (p *ruleParser) valueList() ([]string, error) {
+	p.skipSpace()
+	if !p.consume('(') {
+		return nil, p.errorAt(p.pos, `expected "(" starting the list of values`)
+	}
+	var values []string
+	for {
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		p.skipSpace()
+		switch {
+		case p.consume(','):
+		case p.consume(')'):
+			return values, nil
+		case p.pos == len(p.expr):
+			return nil, p.errorAt(p.pos, `missing ")" closing the list of values`)
+		default:
+			return nil, p.errorAt(p.pos, `expected "," or ")" after a value`)
+		}
+	}
+}
+
+// value parses a bare or double-quoted value
+func (p *ruleParser) value() (string, error) {
+	p.skipSpace()
+	if p.pos < len(p.expr) && p.expr[p.pos] == '"' {
+		quoted, err := strconv.QuotedPrefix(p.expr[p.pos:])
+		if err != nil {
+			return "", p.errorAt(p.pos, "unterminated quoted value")
+		}
+		value, _ := strconv.Unquote(quoted)
+		p.pos += len(quoted)
+		return value, nil
+	}
+	value, start := p.word()
+	if value == "" {
+		return "", p.errorAt(start, "expected a value")
+	}
+	return value, nil
+}
+
+// word reads the bare word at the current position, returning it and where it starts
+func (p *ruleParser) word() (string, int) {
+	start := p.pos
+	for p.pos < len(p.expr) && !strings.ContainsRune(` ,()"=!`, rune(p.expr[p.pos])) && !unicode.IsSpace(rune(p.expr[p.pos])) {
+		p.pos++
+	}
+	return p.expr[start:p.pos], start
+}
+
+func (p *ruleParser) consume(c byte) bool {
+	if p.pos < len(p.expr) && p.expr[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *ruleParser) skipSpace() {
+	for p.pos < len(p.expr) && unicode.IsSpace(rune(p.expr[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *ruleParser) errorAt(offset int, message string) error {
+	return &RuleSyntaxError{Offset: offset, Message: message}
+}
+
+// isDimensionName reports whether word can name a dimension, lower case letters, digits and
+// underscores starting with a letter
+func isDimensionName(word string) bool {
+	if word == "" || word[0] < 'a' || word[0] > 'z' {
+		return false
+	}
+	for _, c := range word {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// found names what was found in an error message, word or else the character at the current
+// position
+func (p *ruleParser) found(word string) string {
+	switch {
+	case word != "":
+		return strconv.Quote(word)
+	case p.pos < len(p.expr):
+		return strconv.Quote(p.expr[p.pos : p.pos+1])
+	default:
+		return "the end"
+	}
+}
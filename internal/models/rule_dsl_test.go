@@ -0,0 +1,79 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules(t *testing.T) {
+	rule := func(dimension TargetDimension, ruleType RuleType, values ...string) TargetingRule {
+		return TargetingRule{Dimension: dimension, RuleType: ruleType, Values: values}
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want []TargetingRule
+	}{
+		{name: "empty", expr: "  "},
+		{
+			name: "every operator",
+			expr: "country in (us,ca) and os = android and app not in (com.blocked.app) and state != KA",
+			want: []TargetingRule{
+				rule(DimensionCountry, RuleTypeInclude, "us", "ca"),
+				rule(DimensionOS, RuleTypeInclude, "android"),
+				rule(DimensionApp, RuleTypeExclude, "com.blocked.app"),
+				rule(DimensionState, RuleTypeExclude, "KA"),
+			},
+		},
+		{
+			name: "spacing and keyword case",
+			expr: "\tcountry IN( us , ca )AND os NOT  IN (ios)\n",
+			want: []TargetingRule{rule(DimensionCountry, RuleTypeInclude, "us", "ca"), rule(DimensionOS, RuleTypeExclude, "ios")},
+		},
+		{
+			name: "quoted values",
+			expr: `device_type in ("smart tv", "a,b (c)") and time_of_day=9-17`,
+			want: []TargetingRule{rule(DimensionDeviceType, RuleTypeInclude, "smart tv", "a,b (c)"), rule(DimensionTimeOfDay, RuleTypeInclude, "9-17")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseRules(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rules)
+		})
+	}
+}
+
+func TestParseRules_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "country", want: `offset 7: expected "in", "not in", "=" or "!=" after country, found the end`},
+		{expr: "Country = us", want: `offset 0: expected a dimension, found "Country"`},
+		{expr: "(country = us)", want: `offset 0: expected a dimension, found "("`},
+		{expr: "country in us", want: `offset 11: expected "(" starting the list of values`},
+		{expr: "country in (us, ca", want: `offset 18: missing ")" closing the list of values`},
+		{expr: "country in (us ca)", want: `offset 15: expected "," or ")" after a value`},
+		{expr: "country in (us,)", want: `offset 15: expected a value`},
+		{expr: "country = us or os = ios", want: `offset 13: "or" is not supported, list alternatives with "in (...)"`},
+		{expr: "country = us os = ios", want: `offset 13: expected "and", found "os"`},
+		{expr: "country = us and", want: `offset 16: expected a dimension, found the end`},
+		{expr: "os not (ios)", want: `offset 7: expected "in" after "not", found "("`},
+		{expr: `os = "ios`, want: `offset 5: unterminated quoted value`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseRules(tt.expr)
+			var syntaxErr *RuleSyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			assert.Equal(t, tt.want, err.Error())
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RuleLint explains in plain words what the targeting rules of a campaign match, with warnings
+// about targeting that likely doesn't do what was meant
+type RuleLint struct {
+	Explanation []string `json:"explanation"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// closedDimensionValues are the values of the dimensions taking a known set of values
+var closedDimensionValues = map[TargetDimension][]string{
+	DimensionOS:       knownOSes,
+	DimensionAgeGroup: ageGroups,
+}
+
+// lintDimension gathers the normalized values a campaign includes and excludes on one dimension,
+// in the order the rules name them
+type lintDimension struct {
+	name     TargetDimension
+	included []string
+	excluded []string
+	// excludedBy is the first rule excluding each value
+	excludedBy map[string]int
+}
+
+// LintRules explains the targeting rules of the campaign dimension by dimension and warns about
+// them: the conflicts found by RuleConflicts, overlapping hour ranges, state rules no request can
+// match, exclusions without effect and targeting so broad it matches every request. Warnings
+// don't make the campaign invalid and the rules aren't validated, see Validate.
+func (cwr *CampaignWithRules) LintRules() RuleLint {
+	return cwr.LintRulesWithRegistry(defaultCampaignMatcher.Registry)
+}
+
+// LintRulesWithRegistry lints the targeting rules like LintRules with the dimensions of registry
+func (cwr *CampaignWithRules) LintRulesWithRegistry(registry *DimensionRegistry) RuleLint {
+	var lint RuleLint
+	warn := func(format string, args ...any) {
+		lint.Warnings = append(lint.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	if !cwr.IsActive() {
+		lint.Explanation = append(lint.Explanation, "the campaign isn't active, it matches no request until it is resumed")
+	}
+	if len(cwr.Rules) == 0 {
+		lint.Explanation = append(lint.Explanation, "every request matches, there are no targeting rules")
+		warn("no targeting rules, the campaign matches every request")
+	}
+
+	var dimensions []*lintDimension
+	byName := make(map[TargetDimension]*lintDimension)
+	for i, rule := range cwr.Rules {
+		processor, known := registry.GetProcessor(string(rule.Dimension))
+		if !known {
+			warn("rule %d: unknown dimension %s, the rule is ignored when matching", i, rule.Dimension)
+			continue
+		}
+		if !rule.RuleType.IsValid() {
+			continue // Reported by ValidateTargeting
+		}
+		dimension, seen := byName[rule.Dimension]
+		if !seen {
+			dimension = &lintDimension{name: rule.Dimension, excludedBy: make(map[string]int)}
+			byName[rule.Dimension] = dimension
+			dimensions = append(dimensions, dimension)
+		}
+		for _, value := range rule.Values {
+			value = processor.NormalizeValue(value)
+			if value == "" {
+				continue
+			}
+			if rule.RuleType == RuleTypeInclude && !slices.Contains(dimension.included, value) {
+				dimension.included = append(dimension.included, value)
+			}
+			if rule.RuleType == RuleTypeExclude && !slices.Contains(dimension.excluded, value) {
+				dimension.excluded = append(dimension.excluded, value)
+				dimension.excludedBy[value] = i
+			}
+		}
+	}
+
+	for _, conflict := range cwr.RuleConflicts() {
+		lint.Warnings = append(lint.Warnings, conflict.Error())
+	}
+
+	includes := false
+	for _, dimension := range dimensions {
+		lint.Explanation = append(lint.Explanation, dimension.explain())
+		if len(dimension.included) > 0 {
+			includes = true
+		}
+		processor, _ := registry.GetProcessor(string(dimension.name))
+		if personal, ok := processor.(PersonalDimensionProcessor); ok && personal.RequiresConsent() && len(dimension.included) > 0 {
+			lint.Explanation = append(lint.Explanation, fmt.Sprintf("requests without consent to personalised ads never match, %s is personal data", dimension.name))
+		}
+
+		switch dimension.name {
+		case DimensionTimeOfDay:
+			lint.Warnings = append(lint.Warnings, lintHours(dimension)...)
+		case DimensionState:
+			lint.Warnings = append(lint.Warnings, lintStates(dimension, byName[DimensionCountry])...)
+		default:
+			// Hour ranges and states are matched beyond their values, the checks above cover them
+			for _, value := range dimension.excluded {
+				if len(dimension.included) > 0 && !slices.Contains(dimension.included, value) {
+					warn("rule %d: %s %q is excluded but never included, excluding it has no effect", dimension.excludedBy[value], dimension.name, value)
+				}
+			}
+		}
+		if values, closed := closedDimensionValues[dimension.name]; closed && len(dimension.excluded) == 0 && coversAll(dimension.included, values) {
+			warn("%s includes every known value, the rules don't narrow the targeting", dimension.name)
+		}
+	}
+
+	if len(dimensions) > 0 && !includes {
+		warn("no include rules, the campaign matches every request that isn't excluded")
+	}
+	if len(dimensions) > 1 {
+		lint.Explanation = append(lint.Explanation, "a request must match every dimension above, requests without a value for a dimension with include rules don't match")
+	}
+	return lint
+}
+
+// explain describes the values the dimension matches
+func (d *lintDimension) explain() string {
+	var explanation string
+	switch {
+	case len(d.included) > 0 && len(d.excluded) > 0:
+		explanation = fmt.Sprintf("%s is one of %s and none of %s", d.name, strings.Join(d.included, ", "), strings.Join(d.excluded, ", "))
+	case len(d.included) > 0:
+		explanation = fmt.Sprintf("%s is one of %s", d.name, strings.Join(d.included, ", "))
+	case len(d.excluded) > 0:
+		explanation = fmt.Sprintf("%s is none of %s, requests without a value match too", d.name, strings.Join(d.excluded, ", "))
+	default:
+		explanation = fmt.Sprintf("%s has rules without values", d.name)
+	}
+	if d.name == DimensionTimeOfDay {
+		explanation += " (hours of the request time, ranges include both ends)"
+	}
+	return explanation
+}
+
+// lintHours warns about hour ranges overlapping each other and about included hours covering
+// the whole day or all excluded
+func lintHours(d *lintDimension) []string {
+	var warnings []string
+	for _, values := range [][]string{d.included, d.excluded} {
+		for i, a := range values {
+			for _, b := range values[i+1:] {
+				if hoursOf(a)&hoursOf(b) != 0 {
+					warnings = append(warnings, fmt.Sprintf("time_of_day ranges %q and %q overlap", a, b))
+				}
+			}
+		}
+	}
+
+	var included, excluded uint32
+	for _, value := range d.included {
+		included |= hoursOf(value)
+	}
+	for _, value := range d.excluded {
+		excluded |= hoursOf(value)
+	}
+	const allHours = 1<<24 - 1
+	switch {
+	case included == 0:
+		// Invalid ranges, reported by ValidateTargeting
+	case included&^excluded == 0 && !coversAll(d.excluded, d.included):
+		// RuleConflicts reports included ranges all excluded as written
+		warnings = append(warnings, "time_of_day: every included hour is excluded, the campaign never matches")
+	case included == allHours && excluded == 0:
+		warnings = append(warnings, "time_of_day includes every hour, the rules don't narrow the targeting")
+	}
+	return warnings
+}
+
+// hoursOf returns the hours of an hour range as bits, none when it is invalid
+func hoursOf(value string) uint32 {
+	start, end, err := parseHourRange(value)
+	if err != nil {
+		return 0
+	}
+	var hours uint32
+	for hour := start; hour <= end; hour++ {
+		hours |= 1 << hour
+	}
+	return hours
+}
+
+// lintStates warns about states no request can match: states are only matched in the country
+// they belong to, which has to be included and not excluded
+func lintStates(states, countries *lintDimension) []string {
+	countryStates := getCountryStatesMapping()
+	stateCountry := make(map[string]string)
+	for country, codes := range countryStates {
+		for _, code := range codes {
+			stateCountry[code] = country
+		}
+	}
+
+	var warnings []string
+	for _, state := range states.included {
+		country, known := stateCountry[state]
+		switch {
+		case !known:
+			warnings = append(warnings, fmt.Sprintf("state %q isn't a state of a country with state targeting, requests never match it", state))
+		case countries == nil:
+		case slices.Contains(countries.excluded, country):
+			warnings = append(warnings, fmt.Sprintf("state %q is in %s which is excluded, requests never match it", state, country))
+		case len(countries.included) > 0 && !slices.Contains(countries.included, country):
+			warnings = append(warnings, fmt.Sprintf("state %q is in %s which isn't included, requests never match it", state, country))
+		}
+	}
+	if countries != nil && len(states.included) > 0 && len(countries.included) > 0 {
+		withStates := slices.ContainsFunc(countries.included, func(country string) bool {
+			_, ok := countryStates[country]
+			return ok
+		})
+		if !withStates {
+			warnings = append(warnings, "none of the included countries has state targeting, the campaign never matches")
+		}
+	}
+	return warnings
+}
+
+// coversAll reports whether values holds every one of all
+func coversAll(values, all []string) bool {
+	for _, value := range all {
+		if !slices.Contains(values, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintRules(t *testing.T) {
+	rule := func(dimension TargetDimension, ruleType RuleType, values ...string) TargetingRule {
+		return TargetingRule{CampaignID: "c", Dimension: dimension, RuleType: ruleType, Values: values}
+	}
+	campaign := func(rules ...TargetingRule) *CampaignWithRules {
+		return &CampaignWithRules{Campaign: Campaign{ID: "c", Status: StatusActive}, Rules: rules}
+	}
+	// The extended dimensions are linted once registered
+	registry := NewDimensionRegistry()
+	registry.RegisterProcessor(NewAgeGroupProcessor())
+	registry.RegisterProcessor(NewTimeOfDayProcessor())
+
+	t.Run("explanation", func(t *testing.T) {
+		lint := campaign(
+			rule(DimensionCountry, RuleTypeInclude, "US", "CAN"),
+			rule(DimensionOS, RuleTypeExclude, "iOS"),
+			rule(DimensionAgeGroup, RuleTypeInclude, "18-24"),
+		).LintRulesWithRegistry(registry)
+		assert.Equal(t, []string{
+			"country is one of us, ca",
+			"os is none of ios, requests without a value match too",
+			"age_group is one of 18-24",
+			"requests without consent to personalised ads never match, age_group is personal data",
+			"a request must match every dimension above, requests without a value for a dimension with include rules don't match",
+		}, lint.Explanation)
+		assert.Empty(t, lint.Warnings)
+	})
+
+	tests := []struct {
+		name     string
+		campaign *CampaignWithRules
+		want     []string
+	}{
+		{
+			name:     "no rules",
+			campaign: campaign(),
+			want:     []string{"no targeting rules, the campaign matches every request"},
+		},
+		{
+			name:     "only exclude rules",
+			campaign: campaign(rule(DimensionApp, RuleTypeExclude, "com.blocked.app")),
+			want:     []string{"no include rules, the campaign matches every request that isn't excluded"},
+		},
+		{
+			name:     "every known value",
+			campaign: campaign(rule(DimensionOS, RuleTypeInclude, "android", "ios", "windows", "macos", "linux", "web")),
+			want:     []string{"os includes every known value, the rules don't narrow the targeting"},
+		},
+		{
+			name: "exclusion without effect",
+			campaign: campaign(
+				rule(DimensionCountry, RuleTypeInclude, "us"),
+				rule(DimensionCountry, RuleTypeExclude, "de"),
+			),
+			want: []string{`rule 1: country "de" is excluded but never included, excluding it has no effect`},
+		},
+		{
+			name: "overlapping hour ranges",
+			campaign: campaign(
+				rule(DimensionTimeOfDay, RuleTypeInclude, "9-17", "12-20", "0-2", "1"),
+			),
+			want: []string{`time_of_day ranges "9-17" and "12-20" overlap`, `time_of_day ranges "0-2" and "1" overlap`},
+		},
+		{
+			name:     "every hour",
+			campaign: campaign(rule(DimensionTimeOfDay, RuleTypeInclude, "0-23")),
+			want:     []string{"time_of_day includes every hour, the rules don't narrow the targeting"},
+		},
+		{
+			name: "included hours all excluded",
+			campaign: campaign(
+				rule(DimensionTimeOfDay, RuleTypeInclude, "9-12"),
+				rule(DimensionTimeOfDay, RuleTypeExclude, "8-17"),
+			),
+			want: []string{"time_of_day: every included hour is excluded, the campaign never matches"},
+		},
+		{
+			name: "unreachable states",
+			campaign: campaign(
+				rule(DimensionCountry, RuleTypeInclude, "us"),
+				rule(DimensionState, RuleTypeInclude, "ka", "tx"),
+			),
+			want: []string{
+				`state "ka" is in in which isn't included, requests never match it`,
+				`state "tx" isn't a state of a country with state targeting, requests never match it`,
+				"none of the included countries has state targeting, the campaign never matches",
+			},
+		},
+		{
+			name: "state of an excluded country",
+			campaign: campaign(
+				rule(DimensionCountry, RuleTypeExclude, "in"),
+				rule(DimensionState, RuleTypeInclude, "gj"),
+			),
+			want: []string{`state "gj" is in in which is excluded, requests never match it`},
+		},
+		{
+			name: "conflicts",
+			campaign: campaign(
+				rule(DimensionCountry, RuleTypeInclude, "us"),
+				rule(DimensionCountry, RuleTypeExclude, "USA"),
+			),
+			want: []string{
+				`rules 0 and 1: country "us" is both included and excluded, requests with it never match`,
+				"country: every included value is also excluded, the campaign never matches",
+			},
+		},
+		{
+			name:     "unknown dimension",
+			campaign: campaign(rule("planet", RuleTypeInclude, "mars")),
+			want:     []string{"rule 0: unknown dimension planet, the rule is ignored when matching"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.campaign.LintRulesWithRegistry(registry).Warnings)
+		})
+	}
+
+	// Dimensions that aren't registered are ignored when matching
+	lint := campaign(rule(DimensionTimeOfDay, RuleTypeInclude, "9-17")).LintRules()
+	assert.Equal(t, []string{"rule 0: unknown dimension time_of_day, the rule is ignored when matching"}, lint.Warnings)
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// RuleSetVersion is a rule set a campaign had, versions are numbered from 1 and the highest one
+// holds the current rules
+type RuleSetVersion struct {
+	CampaignID string          `json:"cid"`
+	Version    int             `json:"version"`
+	Rules      []TargetingRule `json:"rules"`
+	// Author is who made the change, as reported by the admin client
+	Author    string    `json:"author,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RuleChange lists the values added to and removed from the rules of one dimension and rule type
+type RuleChange struct {
+	Dimension TargetDimension `json:"dimension"`
+	RuleType  RuleType        `json:"rule_type"`
+	Added     []string        `json:"added,omitempty"`
+	Removed   []string        `json:"removed,omitempty"`
+}
+
+// ruleKey groups the rules of a dimension and rule type
+type ruleKey struct {
+	dimension TargetDimension
+	ruleType  RuleType
+}
+
+// DiffRules returns the values added and removed between two rule sets, sorted by dimension and
+// rule type. Values are compared as written, the rules of a dimension and rule type are merged.
+func DiffRules(from, to []TargetingRule) []RuleChange {
+	before, after := ruleValueSets(from), ruleValueSets(to)
+
+	keys := make([]ruleKey, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].dimension != keys[j].dimension {
+			return keys[i].dimension < keys[j].dimension
+		}
+		return keys[i].ruleType < keys[j].ruleType
+	})
+
+	var changes []RuleChange
+	for _, key := range keys {
+		change := RuleChange{
+			Dimension: key.dimension,
+			RuleType:  key.ruleType,
+			Added:     missingValues(after[key], before[key]),
+			Removed:   missingValues(before[key], after[key]),
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// ruleValueSets returns the values of the rules by dimension and rule type
+func ruleValueSets(rules []TargetingRule) map[ruleKey]map[string]bool {
+	sets := make(map[ruleKey]map[string]bool)
+	for _, rule := range rules {
+		key := ruleKey{dimension: rule.Dimension, ruleType: rule.RuleType}
+		if sets[key] == nil {
+			sets[key] = make(map[string]bool)
+		}
+		for _, value := range rule.Values {
+			sets[key][value] = true
+		}
+	}
+	return sets
+}
+
+// missingValues returns the values of set that other lacks, sorted
+func missingValues(set, other map[string]bool) []string {
+	var missing []string
+	for value := range set {
+		if !other[value] {
+			missing = append(missing, value)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
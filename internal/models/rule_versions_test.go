@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRules(t *testing.T) {
+	rule := func(dimension TargetDimension, ruleType RuleType, values ...string) TargetingRule {
+		return TargetingRule{CampaignID: "c", Dimension: dimension, RuleType: ruleType, Values: values}
+	}
+
+	tests := []struct {
+		name string
+		from []TargetingRule
+		to   []TargetingRule
+		want []RuleChange
+	}{
+		{
+			name: "unchanged",
+			from: []TargetingRule{rule(DimensionCountry, RuleTypeInclude, "us", "ca")},
+			to:   []TargetingRule{rule(DimensionCountry, RuleTypeInclude, "ca", "us")},
+		},
+		{
+			name: "values added and removed",
+			from: []TargetingRule{rule(DimensionCountry, RuleTypeInclude, "us", "ca")},
+			to:   []TargetingRule{rule(DimensionCountry, RuleTypeInclude, "us", "in", "de")},
+			want: []RuleChange{{Dimension: DimensionCountry, RuleType: RuleTypeInclude, Added: []string{"de", "in"}, Removed: []string{"ca"}}},
+		},
+		{
+			name: "from nothing",
+			to:   []TargetingRule{rule(DimensionOS, RuleTypeExclude, "ios"), rule(DimensionCountry, RuleTypeInclude, "us")},
+			want: []RuleChange{
+				{Dimension: DimensionCountry, RuleType: RuleTypeInclude, Added: []string{"us"}},
+				{Dimension: DimensionOS, RuleType: RuleTypeExclude, Added: []string{"ios"}},
+			},
+		},
+		{
+			name: "rule type switched",
+			from: []TargetingRule{rule(DimensionApp, RuleTypeInclude, "a")},
+			to:   []TargetingRule{rule(DimensionApp, RuleTypeExclude, "a")},
+			want: []RuleChange{
+				{Dimension: DimensionApp, RuleType: RuleTypeExclude, Added: []string{"a"}},
+				{Dimension: DimensionApp, RuleType: RuleTypeInclude, Removed: []string{"a"}},
+			},
+		},
+		{
+			name: "rules split",
+			from: []TargetingRule{rule(DimensionCountry, RuleTypeInclude, "us", "ca")},
+			to:   []TargetingRule{rule(DimensionCountry, RuleTypeInclude, "us"), rule(DimensionCountry, RuleTypeInclude, "ca")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DiffRules(tt.from, tt.to))
+		})
+	}
+}
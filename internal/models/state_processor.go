@@ -62,7 +62,7 @@ func (sp *StateProcessor) ValidateWithDependencies(rule TargetingRule, request D
 	}
 
 	// Get country from request
-	country := strings.ToLower(strings.TrimSpace(request.Country))
+	country := NormalizeCountryCode(request.Country)
 	if country == "" {
 		return errors.New("country is required for state targeting")
 	}
@@ -110,7 +110,7 @@ func (sp *StateProcessor) MatchesRule(requestValue string, rule TargetingRule) b
 
 // MatchesRuleWithDependencies checks if a request matches the rule considering dependencies
 func (sp *StateProcessor) MatchesRuleWithDependencies(rule TargetingRule, request DeliveryRequest) bool {
-	country := strings.ToLower(strings.TrimSpace(request.Country))
+	country := NormalizeCountryCode(request.Country)
 
 	// Does the country belongs to state check
 	validStates, exists := sp.countryStates[country]
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// FieldError describes a problem with a single request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError reports every problem found in a request at once
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error joins the field messages, e.g. "country is required; os is required"
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add records a problem with field
+func (e *ValidationError) add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// ValidationRules holds the per-deployment delivery request validation rules
+type ValidationRules struct {
+	// CountryCodeLengths are the accepted country code lengths, e.g. 2 for ISO alpha-2 or 2 and 3 to also allow alpha-3
+	CountryCodeLengths []int
+	// AllowedOS restricts the os parameter, empty allows any value
+	AllowedOS []string
+	// MaxAppLength caps the app parameter length, 0 disables the check
+	MaxAppLength int
+}
+
+// DefaultValidationRules requires 2-letter country codes and accepts any os and app
+func DefaultValidationRules() ValidationRules {
+	return ValidationRules{
+		CountryCodeLengths: []int{2},
+	}
+}
+
+// RequestValidator validates delivery requests against a set of rules
+type RequestValidator struct {
+	rules ValidationRules
+}
+
+// NewRequestValidator creates a new request validator
+func NewRequestValidator(rules ValidationRules) *RequestValidator {
+	allowedOS := make([]string, len(rules.AllowedOS))
+	for i, os := range rules.AllowedOS {
+		allowedOS[i] = strings.ToLower(strings.TrimSpace(os))
+	}
+	rules.AllowedOS = allowedOS
+
+	return &RequestValidator{
+		rules: rules,
+	}
+}
+
+var defaultRequestValidator = NewRequestValidator(DefaultValidationRules())
+
+// Validate checks every field of the request and returns a *ValidationError listing all
+// problems, or nil if the request is valid
+func (v *RequestValidator) Validate(req DeliveryRequest) error {
+	errs := &ValidationError{}
+
+	country := strings.TrimSpace(req.Country)
+	switch {
+	case country == "":
+		errs.add("country", "country is required")
+	case len(v.rules.CountryCodeLengths) > 0 && !slices.Contains(v.rules.CountryCodeLengths, len(country)):
+		errs.add("country", fmt.Sprintf("country must be a %s-letter code", joinLengths(v.rules.CountryCodeLengths)))
+	case !IsCountryCode(country):
+		errs.add("country", "country must be an ISO 3166-1 country code")
+	}
+
+	os := NormalizeOS(req.OS)
+	switch {
+	case os == "":
+		errs.add("os", "os is required")
+	case len(v.rules.AllowedOS) > 0 && !slices.Contains(v.rules.AllowedOS, os):
+		errs.add("os", fmt.Sprintf("os must be one of: %s", strings.Join(v.rules.AllowedOS, ", ")))
+	}
+
+	app := strings.TrimSpace(req.App)
+	switch {
+	case app == "":
+		errs.add("app", "app is required")
+	case v.rules.MaxAppLength > 0 && len(app) > v.rules.MaxAppLength:
+		errs.add("app", fmt.Sprintf("app must be at most %d characters", v.rules.MaxAppLength))
+	}
+
+	if len(errs.Fields) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// joinLengths formats accepted lengths as "2" or "2 or 3"
+func joinLengths(lengths []int) string {
+	parts := make([]string, len(lengths))
+	for i, length := range lengths {
+		parts[i] = fmt.Sprint(length)
+	}
+	return strings.Join(parts, " or ")
+}
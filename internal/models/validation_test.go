@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestValidator(t *testing.T) {
+	t.Run("reports all fields at once", func(t *testing.T) {
+		err := NewRequestValidator(DefaultValidationRules()).Validate(DeliveryRequest{Country: "USA"})
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, []FieldError{
+			{Field: "country", Message: "country must be a 2-letter code"},
+			{Field: "os", Message: "os is required"},
+			{Field: "app", Message: "app is required"},
+		}, validationErr.Fields)
+	})
+
+	t.Run("custom rules", func(t *testing.T) {
+		validator := NewRequestValidator(ValidationRules{
+			CountryCodeLengths: []int{2, 3},
+			AllowedOS:          []string{"Android", "iOS"},
+			MaxAppLength:       10,
+		})
+
+		assert.NoError(t, validator.Validate(DeliveryRequest{Country: "USA", OS: "ios", App: "com.app"}))
+
+		err := validator.Validate(DeliveryRequest{Country: "U", OS: "windows", App: "com.example.long"})
+		assert.EqualError(t, err, "country must be a 2 or 3-letter code; os must be one of: android, ios; app must be at most 10 characters")
+	})
+
+	t.Run("OS aliases", func(t *testing.T) {
+		validator := NewRequestValidator(ValidationRules{AllowedOS: []string{"android", "ios"}})
+
+		assert.NoError(t, validator.Validate(DeliveryRequest{Country: "us", OS: "iPhone OS", App: "com.app"}))
+		assert.EqualError(t, validator.Validate(DeliveryRequest{Country: "us", OS: "Mac OS X", App: "com.app"}),
+			"os must be one of: android, ios")
+	})
+
+	t.Run("unknown country code", func(t *testing.T) {
+		validator := NewRequestValidator(ValidationRules{CountryCodeLengths: []int{2, 3}})
+
+		assert.NoError(t, validator.Validate(DeliveryRequest{Country: "usa", OS: "ios", App: "com.app"}))
+		assert.EqualError(t, validator.Validate(DeliveryRequest{Country: "UK", OS: "ios", App: "com.app"}),
+			"country must be an ISO 3166-1 country code")
+		assert.EqualError(t, validator.Validate(DeliveryRequest{Country: "XYZ", OS: "ios", App: "com.app"}),
+			"country must be an ISO 3166-1 country code")
+	})
+
+	t.Run("valid request", func(t *testing.T) {
+		req := DeliveryRequest{Country: "US", OS: "Android", App: "com.test.app"}
+		assert.NoError(t, req.Validate())
+	})
+}
@@ -0,0 +1,180 @@
+// Package overview aggregates the state of the campaigns and of delivery into a single payload
+// for dashboards
+package overview
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/scheduler"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// Sources are the dependencies an overview is built from, nil sources leave their section out
+type Sources struct {
+	// Campaigns provides the campaign counts, expiring campaigns and budgets
+	Campaigns service.CampaignStore
+	// Deliveries counts the deliveries budgets are compared to
+	Deliveries scheduler.DeliveryCounter
+	// DeliveryStats provides the delivery totals since startup
+	DeliveryStats func() metrics.DeliveryStats
+	// TopNoFill provides the dimension values with the most requests matching no campaign
+	TopNoFill func(n int) map[string][]metrics.NoFillCount
+	// Freshness describes the campaign snapshot served to delivery requests
+	Freshness func() cache.Freshness
+}
+
+// Options select what the overview includes
+type Options struct {
+	// ExpiringWithin is how far ahead active campaigns count as expiring soon
+	ExpiringWithin time.Duration
+	// Top is the number of no-fill values reported per dimension
+	Top int
+}
+
+// CampaignCounts are the number of campaigns, in total and by status
+type CampaignCounts struct {
+	Total    int                           `json:"total"`
+	ByStatus map[models.CampaignStatus]int `json:"by_status"`
+}
+
+// ExpiringCampaign is an active campaign whose end time is close
+type ExpiringCampaign struct {
+	CID    string    `json:"cid"`
+	Name   string    `json:"name"`
+	EndsAt time.Time `json:"ends_at"`
+}
+
+// BudgetUtilization is how much of its delivery budget a campaign spent
+type BudgetUtilization struct {
+	CID         string                `json:"cid"`
+	Name        string                `json:"name"`
+	Status      models.CampaignStatus `json:"status"`
+	Budget      int64                 `json:"budget"`
+	Delivered   int64                 `json:"delivered"`
+	Utilization float64               `json:"utilization"`
+}
+
+// Overview is the dashboard payload, sections without a source are left out
+type Overview struct {
+	GeneratedAt time.Time                        `json:"generated_at"`
+	Campaigns   *CampaignCounts                  `json:"campaigns,omitempty"`
+	Expiring    []ExpiringCampaign               `json:"expiring,omitempty"`
+	Budgets     []BudgetUtilization              `json:"budgets,omitempty"`
+	Delivery    *metrics.DeliveryStats           `json:"delivery,omitempty"`
+	TopNoFill   map[string][]metrics.NoFillCount `json:"top_no_fill,omitempty"`
+	Cache       *cache.Freshness                 `json:"cache,omitempty"`
+}
+
+// Builder builds overviews from its sources
+type Builder struct {
+	sources Sources
+	now     func() time.Time
+}
+
+// NewBuilder creates a builder reading sources
+func NewBuilder(sources Sources) *Builder {
+	return &Builder{sources: sources, now: time.Now}
+}
+
+// Build reads every source once and aggregates them
+func (b *Builder) Build(ctx context.Context, options Options) (Overview, error) {
+	now := b.now()
+	overview := Overview{GeneratedAt: now.UTC()}
+
+	if b.sources.Campaigns != nil {
+		campaigns, err := b.sources.Campaigns.ListCampaigns(ctx)
+		if err != nil {
+			return Overview{}, fmt.Errorf("failed to list campaigns: %w", err)
+		}
+		overview.Campaigns = countCampaigns(campaigns)
+		overview.Expiring = expiringCampaigns(campaigns, now, options.ExpiringWithin)
+		if b.sources.Deliveries != nil {
+			if overview.Budgets, err = b.budgetUtilization(ctx, campaigns); err != nil {
+				return Overview{}, err
+			}
+		}
+	}
+	if b.sources.DeliveryStats != nil {
+		stats := b.sources.DeliveryStats()
+		overview.Delivery = &stats
+	}
+	if b.sources.TopNoFill != nil {
+		overview.TopNoFill = b.sources.TopNoFill(options.Top)
+	}
+	if b.sources.Freshness != nil {
+		freshness := b.sources.Freshness()
+		overview.Cache = &freshness
+	}
+	return overview, nil
+}
+
+func countCampaigns(campaigns []models.CampaignWithRules) *CampaignCounts {
+	counts := &CampaignCounts{
+		Total:    len(campaigns),
+		ByStatus: map[models.CampaignStatus]int{models.StatusActive: 0, models.StatusInactive: 0},
+	}
+	for _, campaign := range campaigns {
+		counts.ByStatus[campaign.Status]++
+	}
+	return counts
+}
+
+// expiringCampaigns returns the active campaigns ending within window after now, soonest first
+func expiringCampaigns(campaigns []models.CampaignWithRules, now time.Time, window time.Duration) []ExpiringCampaign {
+	var expiring []ExpiringCampaign
+	for _, campaign := range campaigns {
+		if !campaign.IsActive() || campaign.EndsAt == nil || campaign.EndsAt.Before(now) || campaign.EndsAt.After(now.Add(window)) {
+			continue
+		}
+		expiring = append(expiring, ExpiringCampaign{CID: campaign.ID, Name: campaign.Name, EndsAt: *campaign.EndsAt})
+	}
+	slices.SortFunc(expiring, func(a, b ExpiringCampaign) int {
+		return cmp.Or(a.EndsAt.Compare(b.EndsAt), cmp.Compare(a.CID, b.CID))
+	})
+	return expiring
+}
+
+// budgetUtilization returns the campaigns with a delivery budget by the fraction of it they
+// spent, most spent first. Deliveries are counted like the scheduler counts them.
+func (b *Builder) budgetUtilization(ctx context.Context, campaigns []models.CampaignWithRules) ([]BudgetUtilization, error) {
+	var since time.Time
+	for _, campaign := range campaigns {
+		if campaign.DeliveryBudget > 0 && (since.IsZero() || campaign.CreatedAt.Before(since)) {
+			since = campaign.CreatedAt
+		}
+	}
+	if since.IsZero() {
+		return nil, nil
+	}
+
+	delivered, err := b.sources.Deliveries.CampaignDeliveries(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count campaign deliveries: %w", err)
+	}
+
+	var budgets []BudgetUtilization
+	for _, campaign := range campaigns {
+		if campaign.DeliveryBudget <= 0 {
+			continue
+		}
+		budgets = append(budgets, BudgetUtilization{
+			CID:         campaign.ID,
+			Name:        campaign.Name,
+			Status:      campaign.Status,
+			Budget:      campaign.DeliveryBudget,
+			Delivered:   delivered[campaign.ID],
+			Utilization: float64(delivered[campaign.ID]) / float64(campaign.DeliveryBudget),
+		})
+	}
+	slices.SortFunc(budgets, func(a, b BudgetUtilization) int {
+		return cmp.Or(cmp.Compare(b.Utilization, a.Utilization), cmp.Compare(a.CID, b.CID))
+	})
+	return budgets, nil
+}
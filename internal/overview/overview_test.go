@@ -0,0 +1,93 @@
+package overview
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// campaignList is a campaign store listing a fixed set of campaigns
+type campaignList struct {
+	service.CampaignStore
+	campaigns []models.CampaignWithRules
+}
+
+func (l campaignList) ListCampaigns(context.Context) ([]models.CampaignWithRules, error) {
+	return l.campaigns, nil
+}
+
+// deliveryCounts is a delivery counter with fixed counts, it records the time asked for
+type deliveryCounts struct {
+	counts map[string]int64
+	since  time.Time
+}
+
+func (d *deliveryCounts) CampaignDeliveries(_ context.Context, since time.Time) (map[string]int64, error) {
+	d.since = since
+	return d.counts, nil
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	campaign := func(id string, status models.CampaignStatus, endsAt *time.Time, budget int64, created time.Duration) models.CampaignWithRules {
+		return models.CampaignWithRules{Campaign: models.Campaign{
+			ID: id, Name: id, Status: status, EndsAt: endsAt, DeliveryBudget: budget, CreatedAt: now.Add(created),
+		}}
+	}
+	store := campaignList{campaigns: []models.CampaignWithRules{
+		campaign("spotify", models.StatusActive, at(30*time.Hour), 1000, -48*time.Hour),
+		campaign("netflix", models.StatusActive, at(2*time.Hour), 0, -24*time.Hour),
+		campaign("duolingo", models.StatusActive, at(20*time.Hour), 200, -72*time.Hour),
+		campaign("ended", models.StatusActive, at(-time.Hour), 0, -72*time.Hour),
+		campaign("paused", models.StatusInactive, at(time.Hour), 0, -72*time.Hour),
+	}}
+	deliveries := &deliveryCounts{counts: map[string]int64{"spotify": 250, "duolingo": 150}}
+	builder := NewBuilder(Sources{
+		Campaigns:     store,
+		Deliveries:    deliveries,
+		DeliveryStats: func() metrics.DeliveryStats { return metrics.DeliveryStats{Requests: 10, Filled: 8, FillRate: 0.8} },
+		TopNoFill: func(n int) map[string][]metrics.NoFillCount {
+			return map[string][]metrics.NoFillCount{"country": []metrics.NoFillCount{{Value: "de", Requests: 2}, {Value: "fr", Requests: 1}}[:n]}
+		},
+		Freshness: func() cache.Freshness { return cache.Freshness{Built: true, Campaigns: 4} },
+	})
+	builder.now = func() time.Time { return now }
+
+	overview, err := builder.Build(context.Background(), Options{ExpiringWithin: 24 * time.Hour, Top: 1})
+	require.NoError(t, err)
+
+	assert.Equal(t, now, overview.GeneratedAt)
+	assert.Equal(t, &CampaignCounts{Total: 5, ByStatus: map[models.CampaignStatus]int{models.StatusActive: 4, models.StatusInactive: 1}}, overview.Campaigns)
+	// Paused and already ended campaigns aren't expiring
+	assert.Equal(t, []ExpiringCampaign{
+		{CID: "netflix", Name: "netflix", EndsAt: *at(2 * time.Hour)},
+		{CID: "duolingo", Name: "duolingo", EndsAt: *at(20 * time.Hour)},
+	}, overview.Expiring)
+	assert.Equal(t, []BudgetUtilization{
+		{CID: "duolingo", Name: "duolingo", Status: models.StatusActive, Budget: 200, Delivered: 150, Utilization: 0.75},
+		{CID: "spotify", Name: "spotify", Status: models.StatusActive, Budget: 1000, Delivered: 250, Utilization: 0.25},
+	}, overview.Budgets)
+	// Deliveries are counted from the oldest campaign with a budget
+	assert.Equal(t, now.Add(-72*time.Hour), deliveries.since)
+	assert.Equal(t, &metrics.DeliveryStats{Requests: 10, Filled: 8, FillRate: 0.8}, overview.Delivery)
+	assert.Equal(t, map[string][]metrics.NoFillCount{"country": {{Value: "de", Requests: 2}}}, overview.TopNoFill)
+	assert.Equal(t, &cache.Freshness{Built: true, Campaigns: 4}, overview.Cache)
+
+	// Sections without a source are left out
+	overview, err = NewBuilder(Sources{}).Build(context.Background(), Options{})
+	require.NoError(t, err)
+	assert.Nil(t, overview.Campaigns)
+	assert.Nil(t, overview.Budgets)
+	assert.Nil(t, overview.Cache)
+}
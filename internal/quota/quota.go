@@ -0,0 +1,264 @@
+package quota
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// monthFormat formats the months usage is counted in, in UTC
+const monthFormat = "2006-01"
+
+// KeyID identifies an API key without revealing it, it is also the api_key label of the
+// per-client metrics
+func KeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Month returns the month t is counted in
+func Month(t time.Time) string {
+	return t.UTC().Format(monthFormat)
+}
+
+// ParseLimits parses keyID:limit entries into monthly limits by key ID
+func ParseLimits(entries []string) (map[string]int64, error) {
+	limits := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		keyID, raw, ok := strings.Cut(entry, ":")
+		if !ok || keyID == "" {
+			return nil, fmt.Errorf("invalid quota %q, expected key_id:limit", entry)
+		}
+		limit, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid quota %q, the limit must be a non-negative number", entry)
+		}
+		limits[keyID] = limit
+	}
+	return limits, nil
+}
+
+// Config configures the monthly quotas
+type Config struct {
+	// DefaultLimit is the monthly deliveries of keys without their own limit, 0 is unlimited
+	DefaultLimit int64
+	// Limits are the monthly deliveries by key ID, 0 is unlimited
+	Limits map[string]int64
+	// WarnFraction is the fraction of a limit after which responses carry a warning
+	WarnFraction float64
+}
+
+// Status is the usage of an API key in a month
+type Status struct {
+	KeyID string `json:"key_id"`
+	Month string `json:"month"`
+	Used  int64  `json:"used"`
+	// Limit is 0 for unlimited keys
+	Limit int64 `json:"limit"`
+	// Warning is set once WarnFraction of the limit is used
+	Warning bool `json:"warning"`
+	// Exceeded is set once the limit is used, further requests are rejected
+	Exceeded bool `json:"exceeded"`
+}
+
+// Remaining returns the deliveries left in the month, -1 for unlimited keys
+func (s Status) Remaining() int64 {
+	if s.Limit == 0 {
+		return -1
+	}
+	return max(s.Limit-s.Used, 0)
+}
+
+// Store keeps the monthly usage of all replicas
+type Store interface {
+	// Add adds deliveries by key ID to the usage of month
+	Add(ctx context.Context, month string, deliveries map[string]int64) error
+	// Usage returns the usage of month by key ID
+	Usage(ctx context.Context, month string) (map[string]int64, error)
+}
+
+// usageKey identifies pending deliveries
+type usageKey struct {
+	month string
+	keyID string
+}
+
+// Enforcer counts delivered impressions per API key and decides whether a key is over its
+// monthly quota. Checks use the usage of all replicas as of the last flush plus the local
+// deliveries since, so keys can overshoot their limit by what is delivered in a flush interval.
+type Enforcer struct {
+	store  Store
+	config Config
+	now    func() time.Time
+
+	mu      sync.Mutex
+	month   string
+	usage   map[string]int64
+	pending map[usageKey]int64
+}
+
+// NewEnforcer creates an enforcer counting usage in store
+func NewEnforcer(store Store, config Config) *Enforcer {
+	return &Enforcer{
+		store:   store,
+		config:  config,
+		now:     time.Now,
+		usage:   make(map[string]int64),
+		pending: make(map[usageKey]int64),
+	}
+}
+
+// Check returns the current usage of keyID
+func (e *Enforcer) Check(keyID string) Status {
+	month := Month(e.now())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if month != e.month {
+		// The usage of the last flush belongs to the previous month
+		e.month = month
+		e.usage = make(map[string]int64)
+	}
+	return e.status(month, keyID, e.usage[keyID]+e.pending[usageKey{month: month, keyID: keyID}])
+}
+
+// status builds the status of a key that used used deliveries
+func (e *Enforcer) status(month, keyID string, used int64) Status {
+	limit, ok := e.config.Limits[keyID]
+	if !ok {
+		limit = e.config.DefaultLimit
+	}
+	status := Status{KeyID: keyID, Month: month, Used: used, Limit: limit}
+	if limit > 0 {
+		status.Exceeded = used >= limit
+		status.Warning = float64(used) >= e.config.WarnFraction*float64(limit)
+	}
+	return status
+}
+
+// RecordDeliveries counts count delivered impressions for keyID
+func (e *Enforcer) RecordDeliveries(keyID string, count int) {
+	key := usageKey{month: Month(e.now()), keyID: keyID}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending[key] += int64(count)
+}
+
+// Flush adds the pending deliveries to the store and refreshes the usage of the current month,
+// picking up the deliveries of other replicas. On failure the deliveries are kept for the next flush.
+func (e *Enforcer) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = make(map[usageKey]int64)
+	e.mu.Unlock()
+
+	byMonth := make(map[string]map[string]int64)
+	for key, count := range pending {
+		if byMonth[key.month] == nil {
+			byMonth[key.month] = make(map[string]int64)
+		}
+		byMonth[key.month][key.keyID] += count
+	}
+	for month, deliveries := range byMonth {
+		if err := e.store.Add(ctx, month, deliveries); err != nil {
+			e.restore(byMonth)
+			return fmt.Errorf("failed to flush quota usage: %w", err)
+		}
+		delete(byMonth, month)
+	}
+
+	month := Month(e.now())
+	usage, err := e.store.Usage(ctx, month)
+	if err != nil {
+		return fmt.Errorf("failed to load quota usage: %w", err)
+	}
+	e.mu.Lock()
+	e.month = month
+	e.usage = usage
+	e.mu.Unlock()
+	return nil
+}
+
+// restore merges deliveries by month that failed to flush back into the pending ones
+func (e *Enforcer) restore(byMonth map[string]map[string]int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for month, deliveries := range byMonth {
+		for keyID, count := range deliveries {
+			e.pending[usageKey{month: month, keyID: keyID}] += count
+		}
+	}
+}
+
+// Run flushes right away and then every interval until ctx is done, failed flushes are passed
+// to onError. Flush once more after stopping it to keep the last deliveries.
+func (e *Enforcer) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Flush(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Usage returns the usage of month of every key that was used or has a limit, by key ID
+func (e *Enforcer) Usage(ctx context.Context, month string) ([]Status, error) {
+	usage, err := e.store.Usage(ctx, month)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quota usage: %w", err)
+	}
+
+	e.mu.Lock()
+	for key, count := range e.pending {
+		if key.month == month {
+			usage[key.keyID] += count
+		}
+	}
+	e.mu.Unlock()
+	for keyID := range e.config.Limits {
+		if _, ok := usage[keyID]; !ok {
+			usage[keyID] = 0
+		}
+	}
+
+	statuses := make([]Status, 0, len(usage))
+	for keyID, used := range usage {
+		statuses = append(statuses, e.status(month, keyID, used))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].KeyID < statuses[j].KeyID })
+	return statuses, nil
+}
+
+// KeyUsage returns the usage of month of keyID, unused keys have a usage of 0
+func (e *Enforcer) KeyUsage(ctx context.Context, month, keyID string) (Status, error) {
+	usage, err := e.Usage(ctx, month)
+	if err != nil {
+		return Status{}, err
+	}
+	for _, status := range usage {
+		if status.KeyID == keyID {
+			return status, nil
+		}
+	}
+	return e.status(month, keyID, 0), nil
+}
+
+// ResetsAt returns when the quotas of the current month reset
+func (e *Enforcer) ResetsAt() time.Time {
+	now := e.now().UTC()
+	return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,135 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore fails to add usage while fail is set
+type failingStore struct {
+	*MemoryStore
+	fail bool
+}
+
+func (s *failingStore) Add(ctx context.Context, month string, deliveries map[string]int64) error {
+	if s.fail {
+		return errors.New("redis unavailable")
+	}
+	return s.MemoryStore.Add(ctx, month, deliveries)
+}
+
+// newTestEnforcer creates an enforcer with a controllable clock
+func newTestEnforcer(store Store, config Config) (*Enforcer, *time.Time) {
+	now := time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC)
+	enforcer := NewEnforcer(store, config)
+	enforcer.now = func() time.Time { return now }
+	return enforcer, &now
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits([]string{"3f2a9c1b7e4d:1000", "5b8e0a2c4d6f:0"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"3f2a9c1b7e4d": 1000, "5b8e0a2c4d6f": 0}, limits)
+
+	for _, entry := range []string{"3f2a9c1b7e4d", ":10", "3f2a9c1b7e4d:lots", "3f2a9c1b7e4d:-1"} {
+		_, err := ParseLimits([]string{entry})
+		assert.Error(t, err, entry)
+	}
+}
+
+func TestEnforcer_Limits(t *testing.T) {
+	enforcer, _ := newTestEnforcer(NewMemoryStore(), Config{
+		DefaultLimit: 10,
+		Limits:       map[string]int64{"premium": 0, "trial": 4},
+		WarnFraction: 0.5,
+	})
+
+	enforcer.RecordDeliveries("trial", 1)
+	assert.Equal(t, Status{KeyID: "trial", Month: "2025-01", Used: 1, Limit: 4}, enforcer.Check("trial"))
+	enforcer.RecordDeliveries("trial", 1)
+	status := enforcer.Check("trial")
+	assert.True(t, status.Warning)
+	assert.False(t, status.Exceeded)
+	assert.Equal(t, int64(2), status.Remaining())
+
+	enforcer.RecordDeliveries("trial", 3)
+	status = enforcer.Check("trial")
+	assert.True(t, status.Exceeded)
+	assert.Equal(t, int64(0), status.Remaining())
+
+	// Keys without their own limit get the default one, a limit of 0 is unlimited
+	assert.Equal(t, int64(10), enforcer.Check("other").Limit)
+	enforcer.RecordDeliveries("premium", 1000)
+	status = enforcer.Check("premium")
+	assert.False(t, status.Exceeded || status.Warning)
+	assert.Equal(t, int64(-1), status.Remaining())
+}
+
+func TestEnforcer_SharedUsage(t *testing.T) {
+	store := NewMemoryStore()
+	config := Config{DefaultLimit: 10, WarnFraction: 0.8}
+	replica1, _ := newTestEnforcer(store, config)
+	replica2, _ := newTestEnforcer(store, config)
+	ctx := context.Background()
+
+	replica1.RecordDeliveries("trial", 6)
+	replica2.RecordDeliveries("trial", 4)
+	assert.False(t, replica1.Check("trial").Exceeded)
+
+	// Flushing picks up the deliveries of the other replicas
+	require.NoError(t, replica1.Flush(ctx))
+	require.NoError(t, replica2.Flush(ctx))
+	require.NoError(t, replica1.Flush(ctx))
+	assert.True(t, replica1.Check("trial").Exceeded)
+	assert.True(t, replica2.Check("trial").Exceeded)
+
+	usage, err := replica1.Usage(ctx, "2025-01")
+	require.NoError(t, err)
+	assert.Equal(t, []Status{{KeyID: "trial", Month: "2025-01", Used: 10, Limit: 10, Warning: true, Exceeded: true}}, usage)
+}
+
+func TestEnforcer_MonthlyReset(t *testing.T) {
+	enforcer, now := newTestEnforcer(NewMemoryStore(), Config{DefaultLimit: 5, WarnFraction: 0.8})
+	ctx := context.Background()
+
+	enforcer.RecordDeliveries("trial", 5)
+	require.NoError(t, enforcer.Flush(ctx))
+	assert.True(t, enforcer.Check("trial").Exceeded)
+	assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), enforcer.ResetsAt())
+
+	*now = now.Add(2 * time.Hour)
+	status := enforcer.Check("trial")
+	assert.Equal(t, "2025-02", status.Month)
+	assert.Zero(t, status.Used)
+
+	// The usage of past months is kept
+	january, err := enforcer.KeyUsage(ctx, "2025-01", "trial")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), january.Used)
+	unused, err := enforcer.KeyUsage(ctx, "2025-01", "other")
+	require.NoError(t, err)
+	assert.Equal(t, Status{KeyID: "other", Month: "2025-01", Limit: 5}, unused)
+}
+
+func TestEnforcer_FlushFailure(t *testing.T) {
+	store := &failingStore{MemoryStore: NewMemoryStore(), fail: true}
+	enforcer, _ := newTestEnforcer(store, Config{DefaultLimit: 5})
+	ctx := context.Background()
+
+	enforcer.RecordDeliveries("trial", 3)
+	assert.Error(t, enforcer.Flush(ctx))
+	// Deliveries that failed to flush are still counted
+	assert.Equal(t, int64(3), enforcer.Check("trial").Used)
+
+	store.fail = false
+	enforcer.RecordDeliveries("trial", 1)
+	require.NoError(t, enforcer.Flush(ctx))
+	usage, err := store.Usage(ctx, "2025-01")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"trial": 4}, usage)
+}
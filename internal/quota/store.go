@@ -0,0 +1,96 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// usageTTL keeps a month of usage around for a year of billing lookups
+const usageTTL = 400 * 24 * time.Hour
+
+// RedisStore keeps the monthly usage in a Redis hash per month, shared by all replicas
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisStore creates a store keeping the usage in client under keyPrefix
+func NewRedisStore(client redis.Cmdable, keyPrefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: keyPrefix + "quota:"}
+}
+
+// usageKey returns the hash holding the usage of month
+func (s *RedisStore) usageKey(month string) string {
+	return s.prefix + month
+}
+
+// Add implements Store, deliveries are added in a transaction so a failed flush adds nothing
+func (s *RedisStore) Add(ctx context.Context, month string, deliveries map[string]int64) error {
+	key := s.usageKey(month)
+	pipe := s.client.TxPipeline()
+	for keyID, count := range deliveries {
+		pipe.HIncrBy(ctx, key, keyID, count)
+	}
+	pipe.Expire(ctx, key, usageTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to add quota usage: %w", err)
+	}
+	return nil
+}
+
+// Usage implements Store
+func (s *RedisStore) Usage(ctx context.Context, month string) (map[string]int64, error) {
+	values, err := s.client.HGetAll(ctx, s.usageKey(month)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota usage: %w", err)
+	}
+
+	usage := make(map[string]int64, len(values))
+	for keyID, value := range values {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota usage of %s: %w", keyID, err)
+		}
+		usage[keyID] = count
+	}
+	return usage, nil
+}
+
+// MemoryStore keeps the monthly usage in memory, for a single replica without Redis
+type MemoryStore struct {
+	mu    sync.Mutex
+	usage map[string]map[string]int64
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{usage: make(map[string]map[string]int64)}
+}
+
+// Add implements Store
+func (s *MemoryStore) Add(_ context.Context, month string, deliveries map[string]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.usage[month] == nil {
+		s.usage[month] = make(map[string]int64)
+	}
+	for keyID, count := range deliveries {
+		s.usage[month][keyID] += count
+	}
+	return nil
+}
+
+// Usage implements Store
+func (s *MemoryStore) Usage(_ context.Context, month string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usage := make(map[string]int64, len(s.usage[month]))
+	maps.Copy(usage, s.usage[month])
+	return usage, nil
+}
@@ -0,0 +1,34 @@
+package quota
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := NewRedisStore(client, "adbeacon:")
+	ctx := context.Background()
+
+	require.NoError(t, store.Add(ctx, "2025-01", map[string]int64{"trial": 3, "premium": 10}))
+	require.NoError(t, store.Add(ctx, "2025-01", map[string]int64{"trial": 2}))
+	require.NoError(t, store.Add(ctx, "2025-02", map[string]int64{"trial": 1}))
+
+	usage, err := store.Usage(ctx, "2025-01")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"trial": 5, "premium": 10}, usage)
+
+	usage, err = store.Usage(ctx, "2024-12")
+	require.NoError(t, err)
+	assert.Empty(t, usage)
+
+	// Usage is kept for billing lookups, but not forever
+	assert.Equal(t, usageTTL, server.TTL("adbeacon:quota:2025-01"))
+}
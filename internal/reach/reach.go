@@ -0,0 +1,343 @@
+// Package reach estimates how many delivery requests a day a campaign can reach, from daily
+// histograms of the request dimensions kept in Redis and shared by all replicas
+package reach
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// keyNamespace namespaces the daily histograms under the Redis key prefix,
+// {prefix}reach:{unix day}:{dimension} hashes values to requests and
+// {prefix}reach:{unix day}:requests counts all requests
+const keyNamespace = "reach:"
+
+// requestsKey is the histogram key suffix counting all requests of a day
+const requestsKey = "requests"
+
+// Dimensions are the request dimensions histograms are kept for. Rules on other dimensions
+// aren't estimated, they're reported in Estimate.Unestimated.
+var Dimensions = []models.TargetDimension{models.DimensionCountry, models.DimensionOS, models.DimensionApp}
+
+// DimensionEstimate is the share of requests satisfying the rules of one dimension on their own
+type DimensionEstimate struct {
+	Dimension string  `json:"dimension"`
+	MatchRate float64 `json:"match_rate"`
+}
+
+// Estimate is the estimated daily reach of a campaign
+type Estimate struct {
+	CampaignID string `json:"cid"`
+	// DailyRequests is the average number of requests a day
+	DailyRequests int64 `json:"daily_requests"`
+	// DailyReach is the average number of requests a day the campaign's rules match
+	DailyReach int64   `json:"daily_reach"`
+	MatchRate  float64 `json:"match_rate"`
+	// Days is the number of full days averaged, 0 when only today's requests were recorded and
+	// were extrapolated to a full day
+	Days int `json:"days"`
+	// Dimensions are sorted by dimension name
+	Dimensions []DimensionEstimate `json:"dimensions"`
+	// Unestimated lists the targeted dimensions without histograms, they're assumed to match
+	// every request
+	Unestimated []string  `json:"unestimated,omitempty"`
+	AsOf        time.Time `json:"as_of"`
+}
+
+// histogramEntry identifies a counter of pending requests
+type histogramEntry struct {
+	day       int64
+	dimension string
+	value     string
+}
+
+// Estimator counts delivery requests per dimension value in daily Redis histograms and estimates
+// campaign reach from them. Requests are counted in memory and flushed in one pipeline per
+// interval, so the delivery path never waits on Redis.
+type Estimator struct {
+	client redis.Cmdable
+	prefix string
+	days   int
+	now    func() time.Time
+
+	mu       sync.Mutex
+	pending  map[histogramEntry]int64
+	requests map[int64]int64 // unix day to requests
+}
+
+// NewEstimator creates an estimator storing histograms through client under keyPrefix,
+// estimates average the last days full days
+func NewEstimator(client redis.Cmdable, keyPrefix string, days int) *Estimator {
+	return &Estimator{
+		client:   client,
+		prefix:   keyPrefix + keyNamespace,
+		days:     days,
+		now:      time.Now,
+		pending:  make(map[histogramEntry]int64),
+		requests: make(map[int64]int64),
+	}
+}
+
+// RecordRequest counts the request in the histograms, it only touches memory
+func (e *Estimator) RecordRequest(req models.DeliveryRequest) {
+	req.NormalizeValues()
+	day := e.now().Unix() / 86400
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests[day]++
+	for _, dimension := range Dimensions {
+		e.pending[histogramEntry{day: day, dimension: string(dimension), value: req.GetDimensionValue(string(dimension))}]++
+	}
+}
+
+// Flush adds the pending requests to the Redis histograms. Histograms expire once they fall out
+// of the days estimates average. The histograms are updated in a transaction, on failure none
+// of them changed and the requests are kept for the next flush.
+func (e *Estimator) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	pending, requests := e.pending, e.requests
+	e.pending, e.requests = make(map[histogramEntry]int64), make(map[int64]int64)
+	e.mu.Unlock()
+
+	if len(requests) == 0 {
+		return nil
+	}
+
+	ttl := time.Duration(e.days+2) * 24 * time.Hour
+	pipe := e.client.TxPipeline()
+	for day, count := range requests {
+		key := e.histogramKey(day, requestsKey)
+		pipe.IncrBy(ctx, key, count)
+		pipe.Expire(ctx, key, ttl)
+	}
+	for entry, count := range pending {
+		key := e.histogramKey(entry.day, entry.dimension)
+		pipe.HIncrBy(ctx, key, entry.value, count)
+		pipe.Expire(ctx, key, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		e.restore(pending, requests)
+		return fmt.Errorf("failed to flush reach histograms: %w", err)
+	}
+	return nil
+}
+
+// restore merges requests that failed to flush back into the pending ones
+func (e *Estimator) restore(pending map[histogramEntry]int64, requests map[int64]int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for entry, count := range pending {
+		e.pending[entry] += count
+	}
+	for day, count := range requests {
+		e.requests[day] += count
+	}
+}
+
+// Run flushes every interval until ctx is done, failed flushes are passed to onError.
+// Flush once more after stopping it to keep the last requests.
+func (e *Estimator) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Flush(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Estimate estimates the daily requests matching the campaign's rules, whatever its status.
+// Each dimension's share of matching requests comes from its histograms and dimensions are
+// assumed independent, so the reach is the daily requests times the product of the shares.
+// Full days without recorded requests are skipped, when there's none today's requests are
+// extrapolated to a full day. A staged rollout scales the reach by its traffic percentage, the
+// dimension shares are left unscaled. Requests not flushed yet are not included.
+func (e *Estimator) Estimate(ctx context.Context, campaign models.CampaignWithRules) (Estimate, error) {
+	now := e.now()
+	today := now.Unix() / 86400
+
+	// Today first, then the full days from the most recent one
+	days := make([]int64, e.days+1)
+	for i := range days {
+		days[i] = today - int64(i)
+	}
+	histograms, totals, err := e.load(ctx, days)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	// Sum the full days with requests, or today when there are none
+	sampled := make(map[string]int64)
+	var requests int64
+	var full int
+	for i := 1; i < len(days); i++ {
+		if totals[i] == 0 {
+			continue
+		}
+		full++
+		requests += totals[i]
+		for value, count := range histograms[i] {
+			sampled[value] += count
+		}
+	}
+	dailyRequests := float64(requests)
+	if full > 0 {
+		dailyRequests /= float64(full)
+	} else {
+		requests = totals[0]
+		sampled = histograms[0]
+		dailyRequests = float64(requests) / math.Max(float64(now.Unix()%86400)/86400, 1.0/86400)
+	}
+
+	estimate := Estimate{
+		CampaignID:    campaign.ID,
+		DailyRequests: int64(math.Round(dailyRequests)),
+		Days:          full,
+		Dimensions:    []DimensionEstimate{},
+		AsOf:          now.UTC(),
+	}
+
+	matchRate := 1.0
+	for _, dimension := range targetedDimensions(campaign) {
+		if !histogramKept(dimension) {
+			estimate.Unestimated = append(estimate.Unestimated, dimension)
+			continue
+		}
+		rate := dimensionMatchRate(campaign, dimension, sampled, requests)
+		estimate.Dimensions = append(estimate.Dimensions, DimensionEstimate{Dimension: dimension, MatchRate: round(rate)})
+		matchRate *= rate
+	}
+	if requests == 0 {
+		matchRate = 0
+	}
+	if campaign.TrafficPct > 0 && campaign.TrafficPct < 100 {
+		matchRate *= float64(campaign.TrafficPct) / 100
+	}
+	estimate.MatchRate = round(matchRate)
+	estimate.DailyReach = int64(math.Round(dailyRequests * matchRate))
+	return estimate, nil
+}
+
+// load reads the histograms and request totals of days. Histogram values are keyed
+// {dimension}:{value}.
+func (e *Estimator) load(ctx context.Context, days []int64) ([]map[string]int64, []int64, error) {
+	pipe := e.client.Pipeline()
+	totalCmds := make([]*redis.StringCmd, len(days))
+	histogramCmds := make([][]*redis.StringStringMapCmd, len(days))
+	for i, day := range days {
+		totalCmds[i] = pipe.Get(ctx, e.histogramKey(day, requestsKey))
+		histogramCmds[i] = make([]*redis.StringStringMapCmd, len(Dimensions))
+		for j, dimension := range Dimensions {
+			histogramCmds[i][j] = pipe.HGetAll(ctx, e.histogramKey(day, string(dimension)))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, fmt.Errorf("failed to read reach histograms: %w", err)
+	}
+
+	histograms := make([]map[string]int64, len(days))
+	totals := make([]int64, len(days))
+	for i := range days {
+		totals[i], _ = totalCmds[i].Int64()
+		histograms[i] = make(map[string]int64)
+		for j, dimension := range Dimensions {
+			for value, count := range histogramCmds[i][j].Val() {
+				n, _ := strconv.ParseInt(count, 10, 64)
+				histograms[i][string(dimension)+":"+value] += n
+			}
+		}
+	}
+	return histograms, totals, nil
+}
+
+// dimensionMatchRate returns the share of the sampled requests whose value of dimension
+// satisfies the campaign's rules on it
+func dimensionMatchRate(campaign models.CampaignWithRules, dimension string, sampled map[string]int64, requests int64) float64 {
+	if requests == 0 {
+		return 0
+	}
+
+	rules := models.CampaignWithRules{Campaign: campaign.Campaign}
+	rules.Status = models.StatusActive
+	for _, rule := range campaign.Rules {
+		if string(rule.Dimension) == dimension {
+			rules.Rules = append(rules.Rules, rule)
+		}
+	}
+
+	prefix := dimension + ":"
+	var matched int64
+	for key, count := range sampled {
+		if len(key) < len(prefix) || key[:len(prefix)] != prefix {
+			continue
+		}
+		if rules.MatchesRequest(requestWith(dimension, key[len(prefix):])) {
+			matched += count
+		}
+	}
+	return float64(matched) / float64(requests)
+}
+
+// requestWith returns a request with only the value of dimension set
+func requestWith(dimension, value string) models.DeliveryRequest {
+	var req models.DeliveryRequest
+	switch models.TargetDimension(dimension) {
+	case models.DimensionCountry:
+		req.Country = value
+	case models.DimensionOS:
+		req.OS = value
+	case models.DimensionApp:
+		req.App = value
+	}
+	return req
+}
+
+// targetedDimensions returns the dimensions the campaign has rules on, sorted by name
+func targetedDimensions(campaign models.CampaignWithRules) []string {
+	seen := make(map[string]bool)
+	var dimensions []string
+	for _, rule := range campaign.Rules {
+		name := string(rule.Dimension)
+		if !seen[name] {
+			seen[name] = true
+			dimensions = append(dimensions, name)
+		}
+	}
+	sort.Strings(dimensions)
+	return dimensions
+}
+
+// histogramKept reports whether histograms are kept for dimension
+func histogramKept(dimension string) bool {
+	for _, kept := range Dimensions {
+		if string(kept) == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+// round rounds a rate to 4 decimals
+func round(rate float64) float64 {
+	return math.Round(rate*10000) / 10000
+}
+
+func (e *Estimator) histogramKey(day int64, name string) string {
+	return e.prefix + strconv.FormatInt(day, 10) + ":" + name
+}
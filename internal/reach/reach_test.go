@@ -0,0 +1,196 @@
+package reach
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestEstimator creates an estimator averaging 7 days on an in-process Redis server with a
+// controllable clock
+func newTestEstimator(t *testing.T) (*miniredis.Miniredis, *Estimator, *time.Time) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	now := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	estimator := NewEstimator(client, "adbeacon:", 7)
+	estimator.now = func() time.Time { return now }
+	return server, estimator, &now
+}
+
+// record records n requests
+func record(e *Estimator, n int, req models.DeliveryRequest) {
+	for i := 0; i < n; i++ {
+		e.RecordRequest(req)
+	}
+}
+
+func campaign(rules ...models.TargetingRule) models.CampaignWithRules {
+	return models.CampaignWithRules{Campaign: models.Campaign{ID: "c", Status: models.StatusInactive}, Rules: rules}
+}
+
+func rule(dimension models.TargetDimension, ruleType models.RuleType, values ...string) models.TargetingRule {
+	return models.TargetingRule{CampaignID: "c", Dimension: dimension, RuleType: ruleType, Values: values}
+}
+
+func TestEstimator_FullDays(t *testing.T) {
+	_, estimator, now := newTestEstimator(t)
+	ctx := context.Background()
+
+	// Two days ago 600 requests and yesterday 400, half of them from the US and a quarter on iOS
+	*now = now.Add(-48 * time.Hour)
+	record(estimator, 150, models.DeliveryRequest{Country: "USA", OS: "iOS", App: "a"})
+	record(estimator, 150, models.DeliveryRequest{Country: "us", OS: "android", App: "a"})
+	record(estimator, 300, models.DeliveryRequest{Country: "in", OS: "android", App: "b"})
+	*now = now.Add(24 * time.Hour)
+	record(estimator, 100, models.DeliveryRequest{Country: "us", OS: "android", App: "a"})
+	record(estimator, 100, models.DeliveryRequest{Country: "in", OS: "iphone os", App: "b"})
+	record(estimator, 200, models.DeliveryRequest{Country: "ca", OS: "android", App: "b"})
+	*now = now.Add(24 * time.Hour)
+	// Today's requests are left out while there are full days
+	record(estimator, 1000, models.DeliveryRequest{Country: "us", OS: "ios", App: "a"})
+	require.NoError(t, estimator.Flush(ctx))
+
+	tests := []struct {
+		name       string
+		campaign   models.CampaignWithRules
+		wantReach  int64
+		wantRate   float64
+		dimensions []DimensionEstimate
+	}{
+		{
+			name:      "untargeted",
+			campaign:  campaign(),
+			wantReach: 500,
+			wantRate:  1,
+		},
+		{
+			name:       "one dimension",
+			campaign:   campaign(rule(models.DimensionCountry, models.RuleTypeInclude, "us")),
+			wantReach:  200,
+			wantRate:   0.4,
+			dimensions: []DimensionEstimate{{Dimension: "country", MatchRate: 0.4}},
+		},
+		{
+			name: "independent dimensions",
+			campaign: campaign(
+				rule(models.DimensionOS, models.RuleTypeInclude, "ios"),
+				rule(models.DimensionCountry, models.RuleTypeExclude, "ca"),
+			),
+			wantReach:  100,
+			wantRate:   0.2,
+			dimensions: []DimensionEstimate{{Dimension: "country", MatchRate: 0.8}, {Dimension: "os", MatchRate: 0.25}},
+		},
+		{
+			name:       "apps",
+			campaign:   campaign(rule(models.DimensionApp, models.RuleTypeInclude, "a", "z")),
+			wantReach:  200,
+			wantRate:   0.4,
+			dimensions: []DimensionEstimate{{Dimension: "app", MatchRate: 0.4}},
+		},
+		{
+			name: "staged rollout",
+			campaign: func() models.CampaignWithRules {
+				c := campaign(rule(models.DimensionCountry, models.RuleTypeInclude, "us"))
+				c.TrafficPct = 25
+				return c
+			}(),
+			wantReach:  50,
+			wantRate:   0.1,
+			dimensions: []DimensionEstimate{{Dimension: "country", MatchRate: 0.4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, err := estimator.Estimate(ctx, tt.campaign)
+			require.NoError(t, err)
+			assert.Equal(t, "c", estimate.CampaignID)
+			assert.Equal(t, int64(500), estimate.DailyRequests)
+			assert.Equal(t, 2, estimate.Days)
+			assert.Equal(t, tt.wantReach, estimate.DailyReach)
+			assert.Equal(t, tt.wantRate, estimate.MatchRate)
+			if tt.dimensions == nil {
+				tt.dimensions = []DimensionEstimate{}
+			}
+			assert.Equal(t, tt.dimensions, estimate.Dimensions)
+			assert.Empty(t, estimate.Unestimated)
+		})
+	}
+}
+
+func TestEstimator_Unestimated(t *testing.T) {
+	_, estimator, now := newTestEstimator(t)
+	ctx := context.Background()
+
+	*now = now.Add(-24 * time.Hour)
+	record(estimator, 100, models.DeliveryRequest{Country: "us", OS: "android", App: "a", State: "us-ca"})
+	*now = now.Add(24 * time.Hour)
+	require.NoError(t, estimator.Flush(ctx))
+
+	// State has no histograms and is assumed to match every request
+	estimate, err := estimator.Estimate(ctx, campaign(
+		rule(models.DimensionCountry, models.RuleTypeInclude, "us"),
+		rule(models.DimensionState, models.RuleTypeInclude, "us-ny"),
+	))
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), estimate.DailyReach)
+	assert.Equal(t, []string{"state"}, estimate.Unestimated)
+}
+
+func TestEstimator_ExtrapolatesToday(t *testing.T) {
+	_, estimator, _ := newTestEstimator(t)
+	ctx := context.Background()
+
+	// Half the day passed without a full day recorded
+	record(estimator, 30, models.DeliveryRequest{Country: "us", OS: "android", App: "a"})
+	record(estimator, 70, models.DeliveryRequest{Country: "in", OS: "android", App: "a"})
+	require.NoError(t, estimator.Flush(ctx))
+
+	estimate, err := estimator.Estimate(ctx, campaign(rule(models.DimensionCountry, models.RuleTypeInclude, "us")))
+	require.NoError(t, err)
+	assert.Equal(t, 0, estimate.Days)
+	assert.Equal(t, int64(200), estimate.DailyRequests)
+	assert.Equal(t, int64(60), estimate.DailyReach)
+}
+
+func TestEstimator_NoRequests(t *testing.T) {
+	_, estimator, _ := newTestEstimator(t)
+
+	estimate, err := estimator.Estimate(context.Background(), campaign(rule(models.DimensionCountry, models.RuleTypeInclude, "us")))
+	require.NoError(t, err)
+	assert.Zero(t, estimate.DailyRequests)
+	assert.Zero(t, estimate.DailyReach)
+	assert.Zero(t, estimate.MatchRate)
+}
+
+func TestEstimator_FlushFailureKeepsRequests(t *testing.T) {
+	server, estimator, now := newTestEstimator(t)
+	ctx := context.Background()
+
+	*now = now.Add(-24 * time.Hour)
+	record(estimator, 10, models.DeliveryRequest{Country: "us", OS: "android", App: "a"})
+	server.SetError("unavailable")
+	require.Error(t, estimator.Flush(ctx))
+
+	server.SetError("")
+	record(estimator, 5, models.DeliveryRequest{Country: "us", OS: "android", App: "a"})
+	require.NoError(t, estimator.Flush(ctx))
+
+	*now = now.Add(24 * time.Hour)
+	estimate, err := estimator.Estimate(ctx, campaign())
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), estimate.DailyRequests)
+
+	// Histograms expire after the averaged days
+	assert.Equal(t, 9*24*time.Hour, server.TTL(estimator.histogramKey(now.Unix()/86400-1, requestsKey)))
+}
@@ -0,0 +1,98 @@
+package readiness
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StepStatus is the state of a single warm-up step
+type StepStatus struct {
+	Name  string `json:"name"`
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+}
+
+// Status is the readiness report served by /readyz
+type Status struct {
+	Ready bool         `json:"ready"`
+	Steps []StepStatus `json:"steps"`
+}
+
+// Gate keeps the service unready until every required warm-up step completed,
+// so load balancers don't route traffic to cold instances
+type Gate struct {
+	mu    sync.RWMutex
+	steps []StepStatus
+}
+
+// NewGate creates a gate requiring the named steps, in the order they are reported
+func NewGate(steps ...string) *Gate {
+	g := &Gate{}
+	for _, name := range steps {
+		g.steps = append(g.steps, StepStatus{Name: name})
+	}
+	return g
+}
+
+// Ready reports whether all steps completed
+func (g *Gate) Ready() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, step := range g.steps {
+		if !step.Done {
+			return false
+		}
+	}
+	return true
+}
+
+// Status returns the state of every step
+func (g *Gate) Status() Status {
+	g.mu.RLock()
+	steps := make([]StepStatus, len(g.steps))
+	copy(steps, g.steps)
+	g.mu.RUnlock()
+
+	return Status{
+		Ready: g.Ready(),
+		Steps: steps,
+	}
+}
+
+// Run calls fn until it succeeds, waiting retryInterval between attempts, then marks the
+// step done. The last error is reported in the status while the step is pending.
+func (g *Gate) Run(ctx context.Context, name string, retryInterval time.Duration, fn func(context.Context) error) error {
+	for {
+		err := fn(ctx)
+		g.update(name, err)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// update records the outcome of an attempt of the named step
+func (g *Gate) update(name string, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i := range g.steps {
+		if g.steps[i].Name != name {
+			continue
+		}
+		g.steps[i].Done = err == nil
+		g.steps[i].Error = ""
+		if err != nil {
+			g.steps[i].Error = err.Error()
+		}
+		return
+	}
+}
@@ -0,0 +1,49 @@
+package readiness
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGate(t *testing.T) {
+	gate := NewGate("migrations", "cache")
+	assert.False(t, gate.Ready())
+
+	assert.NoError(t, gate.Run(context.Background(), "migrations", time.Millisecond, func(ctx context.Context) error {
+		return nil
+	}))
+	assert.False(t, gate.Ready())
+
+	// A failing step is retried and its last error reported
+	attempts := 0
+	assert.NoError(t, gate.Run(context.Background(), "cache", time.Millisecond, func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("redis unavailable")
+		}
+		return nil
+	}))
+	assert.Equal(t, 3, attempts)
+	assert.True(t, gate.Ready())
+	assert.Equal(t, Status{Ready: true, Steps: []StepStatus{
+		{Name: "migrations", Done: true},
+		{Name: "cache", Done: true},
+	}}, gate.Status())
+}
+
+func TestGateRunCanceled(t *testing.T) {
+	gate := NewGate("self_test")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := gate.Run(ctx, "self_test", time.Hour, func(ctx context.Context) error {
+		return errors.New("no campaigns")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []StepStatus{{Name: "self_test", Error: "no campaigns"}}, gate.Status().Steps)
+	assert.False(t, gate.Ready())
+}
@@ -0,0 +1,134 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/config"
+)
+
+// What caused a reload
+const (
+	TriggerSignal = "sighup"
+	TriggerFile   = "file"
+)
+
+// ApplyFunc applies reloaded tunables, an error leaves the previous tunables in effect
+type ApplyFunc func(config.Tunables) error
+
+// Reloader re-reads the config file on SIGHUP or when it changes and applies the tunables,
+// logging an audit entry with the changed settings for every reload
+type Reloader struct {
+	path   string
+	apply  ApplyFunc
+	logger log.Logger
+
+	mu      sync.Mutex
+	current config.Tunables
+	modTime time.Time
+}
+
+// New creates a reloader for the config file at path, starting from the current tunables
+func New(path string, current config.Tunables, apply ApplyFunc, logger log.Logger) *Reloader {
+	r := &Reloader{
+		path:    path,
+		apply:   apply,
+		logger:  logger,
+		current: current,
+	}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+// Reload re-reads the config file and applies the tunables if any of them changed
+func (r *Reloader) Reload(trigger string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tunables, err := config.LoadTunables(r.path)
+	if err == nil {
+		err = r.apply(tunables)
+	}
+	if err != nil {
+		level.Error(r.logger).Log("msg", "configuration reload failed", "trigger", trigger, "file", r.path, "err", err)
+		return err
+	}
+
+	changes := diff(r.current, tunables)
+	r.current = tunables
+
+	changed := "none"
+	if len(changes) > 0 {
+		changed = strings.Join(changes, "; ")
+	}
+	level.Info(r.logger).Log("msg", "configuration reloaded", "trigger", trigger, "file", r.path, "changes", changed)
+	return nil
+}
+
+// Run reloads on SIGHUP and, with a positive pollInterval, whenever the config file's
+// modification time changes, until ctx is canceled
+func (r *Reloader) Run(ctx context.Context, pollInterval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var poll <-chan time.Time
+	if pollInterval > 0 {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.Reload(TriggerSignal)
+		case <-poll:
+			if r.fileChanged() {
+				r.Reload(TriggerFile)
+			}
+		}
+	}
+}
+
+// fileChanged reports whether the config file was modified since it was last seen
+func (r *Reloader) fileChanged() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if info.ModTime().Equal(r.modTime) {
+		return false
+	}
+	r.modTime = info.ModTime()
+	return true
+}
+
+// diff lists the tunables that differ as "Name: old -> new"
+func diff(previous, next config.Tunables) []string {
+	var changes []string
+	before, after := reflect.ValueOf(previous), reflect.ValueOf(next)
+	for i := 0; i < before.NumField(); i++ {
+		if before.Field(i).Interface() != after.Field(i).Interface() {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v",
+				before.Type().Field(i).Name, before.Field(i).Interface(), after.Field(i).Interface()))
+		}
+	}
+	return changes
+}
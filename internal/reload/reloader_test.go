@@ -0,0 +1,67 @@
+package reload
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	// Overload writes the process environment, restore it after the test
+	for _, key := range []string{"LOG_LEVEL", "ACCESS_LOG_SAMPLE_RATE", "CACHE_DEFAULT_TTL", "DELIVERY_RATE_LIMIT_RPS", "DELIVERY_RATE_LIMIT_BURST"} {
+		t.Setenv(key, "")
+	}
+
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte("LOG_LEVEL=debug\nDELIVERY_RATE_LIMIT_RPS=50\n"), 0o600))
+
+	current := config.Tunables{
+		LogLevel:            "info",
+		AccessLogSampleRate: 1,
+		CacheTTL:            5 * time.Minute,
+		RateLimitBurst:      100,
+	}
+
+	var applied config.Tunables
+	var buf bytes.Buffer
+	r := New(path, current, func(tunables config.Tunables) error {
+		applied = tunables
+		return nil
+	}, log.NewLogfmtLogger(&buf))
+
+	require.NoError(t, r.Reload(TriggerSignal))
+	assert.Equal(t, "debug", applied.LogLevel)
+	assert.Equal(t, 50.0, applied.RateLimit)
+	assert.Equal(t, 5*time.Minute, applied.CacheTTL)
+	assert.Contains(t, buf.String(), `msg="configuration reloaded" trigger=sighup`)
+	assert.Contains(t, buf.String(), "LogLevel: info -> debug; RateLimit: 0 -> 50")
+
+	t.Run("apply error keeps previous tunables", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("LOG_LEVEL=verbose\n"), 0o600))
+		r.apply = func(config.Tunables) error { return errors.New("invalid log level") }
+
+		assert.Error(t, r.Reload(TriggerFile))
+		assert.Equal(t, "debug", r.current.LogLevel)
+	})
+}
+
+func TestFileChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte("LOG_LEVEL=info\n"), 0o600))
+
+	r := New(path, config.Tunables{}, nil, log.NewNopLogger())
+	assert.False(t, r.fileChanged())
+
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	assert.True(t, r.fileChanged())
+	assert.False(t, r.fileChanged())
+}
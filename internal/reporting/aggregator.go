@@ -0,0 +1,130 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/tracking"
+)
+
+// rowKey identifies the hourly row an event is counted in
+type rowKey struct {
+	hour       time.Time
+	campaignID string
+	country    string
+}
+
+// Aggregator counts deliveries and tracked events in memory by hour, campaign and country and
+// periodically adds them to a Store, so the request path never waits on the database. It is a
+// tracking.Recorder.
+type Aggregator struct {
+	store Store
+	now   func() time.Time
+
+	mu      sync.Mutex
+	pending map[rowKey]*Row
+}
+
+// NewAggregator creates an aggregator adding its counts to store
+func NewAggregator(store Store) *Aggregator {
+	return &Aggregator{
+		store:   store,
+		now:     time.Now,
+		pending: make(map[rowKey]*Row),
+	}
+}
+
+// RecordDeliveries counts a delivery of each campaign in country
+func (a *Aggregator) RecordDeliveries(campaignIDs []string, country string) {
+	hour := a.now().UTC().Truncate(time.Hour)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, id := range campaignIDs {
+		a.row(hour, id, country).Deliveries++
+	}
+}
+
+// Record counts a tracked impression or click in the hour it happened
+func (a *Aggregator) Record(_ context.Context, event tracking.Event) {
+	eventTime := event.Time
+	if eventTime.IsZero() {
+		eventTime = a.now()
+	}
+	hour := eventTime.UTC().Truncate(time.Hour)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	row := a.row(hour, event.CampaignID, event.Country)
+	switch event.Type {
+	case tracking.EventImpression:
+		row.Impressions++
+	case tracking.EventClick:
+		row.Clicks++
+	}
+}
+
+// row returns the pending row of key, creating it, a.mu must be held
+func (a *Aggregator) row(hour time.Time, campaignID, country string) *Row {
+	key := rowKey{hour: hour, campaignID: campaignID, country: country}
+	row := a.pending[key]
+	if row == nil {
+		row = &Row{Hour: hour, CampaignID: campaignID, Country: country}
+		a.pending[key] = row
+	}
+	return row
+}
+
+// Flush adds the pending counts to the store. On failure the counts are kept for the next flush.
+func (a *Aggregator) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = make(map[rowKey]*Row)
+	a.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	rows := make([]Row, 0, len(pending))
+	for _, row := range pending {
+		rows = append(rows, *row)
+	}
+	if err := a.store.AddDeliveryStats(ctx, rows); err != nil {
+		a.restore(rows)
+		return fmt.Errorf("failed to flush delivery stats: %w", err)
+	}
+	return nil
+}
+
+// restore merges rows that failed to flush back into the pending ones
+func (a *Aggregator) restore(rows []Row) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, failed := range rows {
+		row := a.row(failed.Hour, failed.CampaignID, failed.Country)
+		row.Deliveries += failed.Deliveries
+		row.Impressions += failed.Impressions
+		row.Clicks += failed.Clicks
+	}
+}
+
+// Run flushes every interval until ctx is done, failed flushes are passed to onError.
+// Flush once more after stopping it to keep the last counts.
+func (a *Aggregator) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Flush(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/tracking"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore fails to add rows while fail is set
+type failingStore struct {
+	*MemoryStore
+	fail bool
+}
+
+func (s *failingStore) AddDeliveryStats(ctx context.Context, rows []Row) error {
+	if s.fail {
+		return errors.New("database unavailable")
+	}
+	return s.MemoryStore.AddDeliveryStats(ctx, rows)
+}
+
+func TestAggregator_HourlyRows(t *testing.T) {
+	store := NewMemoryStore()
+	aggregator := NewAggregator(store)
+	now := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	aggregator.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	aggregator.RecordDeliveries([]string{"spotify", "duolingo"}, "US")
+	aggregator.RecordDeliveries([]string{"spotify"}, "US")
+	aggregator.RecordDeliveries([]string{"spotify"}, "Germany")
+	aggregator.Record(ctx, tracking.Event{Type: tracking.EventImpression, CampaignID: "spotify", Country: "US", Time: now})
+	aggregator.Record(ctx, tracking.Event{Type: tracking.EventClick, CampaignID: "spotify", Country: "US", Time: now})
+	// Events are counted in the hour they happened, not the hour they arrived
+	aggregator.Record(ctx, tracking.Event{Type: tracking.EventImpression, CampaignID: "spotify", Country: "US", Time: now.Add(-time.Hour)})
+	require.NoError(t, aggregator.Flush(ctx))
+
+	hour := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rows, err := store.QueryDeliveryStats(ctx, Query{
+		From:    hour.Add(-time.Hour),
+		To:      hour.Add(time.Hour),
+		GroupBy: []string{GroupByHour, GroupByCampaign, GroupByCountry},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Row{
+		{Hour: hour.Add(-time.Hour), CampaignID: "spotify", Country: "US", Impressions: 1},
+		{Hour: hour, CampaignID: "duolingo", Country: "US", Deliveries: 1},
+		{Hour: hour, CampaignID: "spotify", Country: "Germany", Deliveries: 1},
+		{Hour: hour, CampaignID: "spotify", Country: "US", Deliveries: 2, Impressions: 1, Clicks: 1},
+	}, rows)
+
+	// Counts of later flushes are added to the same rows
+	aggregator.RecordDeliveries([]string{"duolingo"}, "US")
+	require.NoError(t, aggregator.Flush(ctx))
+	rows, err = store.QueryDeliveryStats(ctx, Query{From: hour, To: hour.Add(time.Hour), CampaignID: "duolingo"})
+	require.NoError(t, err)
+	assert.Equal(t, []Row{{Deliveries: 2}}, rows)
+}
+
+func TestAggregator_FlushFailure(t *testing.T) {
+	store := &failingStore{MemoryStore: NewMemoryStore(), fail: true}
+	aggregator := NewAggregator(store)
+	ctx := context.Background()
+
+	aggregator.RecordDeliveries([]string{"spotify", "spotify"}, "US")
+	assert.Error(t, aggregator.Flush(ctx))
+
+	// Counts of a failed flush are added by the next one
+	store.fail = false
+	aggregator.RecordDeliveries([]string{"spotify"}, "US")
+	require.NoError(t, aggregator.Flush(ctx))
+
+	now := time.Now()
+	rows, err := store.QueryDeliveryStats(ctx, Query{From: now.Add(-time.Hour), To: now.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, []Row{{Deliveries: 3}}, rows)
+
+	// Nothing pending, nothing to add
+	store.fail = true
+	assert.NoError(t, aggregator.Flush(ctx))
+}
@@ -0,0 +1,90 @@
+package reporting
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store keeping rows in memory, for the mock mode and tests
+type MemoryStore struct {
+	mu   sync.RWMutex
+	rows map[rowKey]Row
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{rows: make(map[rowKey]Row)}
+}
+
+// AddDeliveryStats adds the counts of rows to the stored rows
+func (s *MemoryStore) AddDeliveryStats(_ context.Context, rows []Row) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, row := range rows {
+		key := rowKey{hour: row.Hour.UTC().Truncate(time.Hour), campaignID: row.CampaignID, country: row.Country}
+		stored := s.rows[key]
+		stored.Hour, stored.CampaignID, stored.Country = key.hour, key.campaignID, key.country
+		stored.Deliveries += row.Deliveries
+		stored.Impressions += row.Impressions
+		stored.Clicks += row.Clicks
+		s.rows[key] = stored
+	}
+	return nil
+}
+
+// QueryDeliveryStats sums the stored rows of query
+func (s *MemoryStore) QueryDeliveryStats(_ context.Context, query Query) ([]Row, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := make(map[rowKey]*Row)
+	for key, row := range s.rows {
+		if key.hour.Before(query.From) || !key.hour.Before(query.To) {
+			continue
+		}
+		if query.CampaignID != "" && key.campaignID != query.CampaignID {
+			continue
+		}
+
+		var group rowKey
+		switch {
+		case query.Groups(GroupByHour):
+			group.hour = key.hour
+		case query.Groups(GroupByDay):
+			group.hour = key.hour.Truncate(24 * time.Hour)
+		}
+		if query.Groups(GroupByCampaign) {
+			group.campaignID = key.campaignID
+		}
+		if query.Groups(GroupByCountry) {
+			group.country = key.country
+		}
+
+		sum := groups[group]
+		if sum == nil {
+			sum = &Row{Hour: group.hour, CampaignID: group.campaignID, Country: group.country}
+			groups[group] = sum
+		}
+		sum.Deliveries += row.Deliveries
+		sum.Impressions += row.Impressions
+		sum.Clicks += row.Clicks
+	}
+
+	report := make([]Row, 0, len(groups))
+	for _, row := range groups {
+		report = append(report, *row)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if !a.Hour.Equal(b.Hour) {
+			return a.Hour.Before(b.Hour)
+		}
+		if a.CampaignID != b.CampaignID {
+			return a.CampaignID < b.CampaignID
+		}
+		return a.Country < b.Country
+	})
+	return report, nil
+}
@@ -0,0 +1,73 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Report dimensions rows can be grouped by
+const (
+	GroupByHour     = "hour"
+	GroupByDay      = "day"
+	GroupByCampaign = "campaign"
+	GroupByCountry  = "country"
+)
+
+// GroupByDimensions are the valid group_by values, in the order their columns are reported
+var GroupByDimensions = []string{GroupByHour, GroupByDay, GroupByCampaign, GroupByCountry}
+
+// ErrInvalidQuery is wrapped by the errors of Query.Validate
+var ErrInvalidQuery = errors.New("invalid report query")
+
+// Row counts the deliveries, impressions and clicks of a campaign in a country during an hour.
+// In report rows the dimensions that weren't grouped by are left empty, Hour holds the day when
+// grouped by day.
+type Row struct {
+	Hour        time.Time `json:"hour,omitempty"`
+	CampaignID  string    `json:"cid,omitempty"`
+	Country     string    `json:"country,omitempty"`
+	Deliveries  int64     `json:"deliveries"`
+	Impressions int64     `json:"impressions"`
+	Clicks      int64     `json:"clicks"`
+}
+
+// Query selects the hours in [From, To) and sums them by the GroupBy dimensions,
+// optionally for a single campaign
+type Query struct {
+	From       time.Time
+	To         time.Time
+	GroupBy    []string
+	CampaignID string
+}
+
+// Validate checks the time range and the group_by dimensions
+func (q Query) Validate() error {
+	if !q.From.Before(q.To) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+	}
+	for _, dimension := range q.GroupBy {
+		if !slices.Contains(GroupByDimensions, dimension) {
+			return fmt.Errorf("%w: unknown group_by %q, expected one of %v", ErrInvalidQuery, dimension, GroupByDimensions)
+		}
+	}
+	if q.Groups(GroupByHour) && q.Groups(GroupByDay) {
+		return fmt.Errorf("%w: group_by hour and day are exclusive", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// Groups reports whether the query groups by dimension
+func (q Query) Groups(dimension string) bool {
+	return slices.Contains(q.GroupBy, dimension)
+}
+
+// Store keeps the hourly rows and sums them for reports
+type Store interface {
+	// AddDeliveryStats adds the counts of rows to the stored rows of the same hour, campaign and country
+	AddDeliveryStats(ctx context.Context, rows []Row) error
+	// QueryDeliveryStats returns the summed rows of a validated query, ordered by the grouped dimensions
+	QueryDeliveryStats(ctx context.Context, query Query) ([]Row, error)
+}
@@ -0,0 +1,80 @@
+package reporting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery_Validate(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	tests := []struct {
+		name  string
+		query Query
+		valid bool
+	}{
+		{"no grouping", Query{From: from, To: to}, true},
+		{"all dimensions", Query{From: from, To: to, GroupBy: []string{"hour", "campaign", "country"}}, true},
+		{"empty range", Query{From: to, To: to}, false},
+		{"reversed range", Query{From: to, To: from}, false},
+		{"unknown dimension", Query{From: from, To: to, GroupBy: []string{"os"}}, false},
+		{"hour and day", Query{From: from, To: to, GroupBy: []string{"hour", "day"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidQuery)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_GroupBy(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, store.AddDeliveryStats(ctx, []Row{
+		{Hour: day.Add(10 * time.Hour), CampaignID: "spotify", Country: "US", Deliveries: 5, Impressions: 4, Clicks: 1},
+		{Hour: day.Add(11 * time.Hour), CampaignID: "spotify", Country: "Germany", Deliveries: 3, Impressions: 2},
+		{Hour: day.Add(11 * time.Hour), CampaignID: "duolingo", Country: "US", Deliveries: 2},
+		{Hour: day.Add(25 * time.Hour), CampaignID: "spotify", Country: "US", Deliveries: 1},
+	}))
+
+	query := Query{From: day, To: day.Add(48 * time.Hour)}
+
+	query.GroupBy = []string{GroupByDay}
+	rows, err := store.QueryDeliveryStats(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, []Row{
+		{Hour: day, Deliveries: 10, Impressions: 6, Clicks: 1},
+		{Hour: day.Add(24 * time.Hour), Deliveries: 1},
+	}, rows)
+
+	query.GroupBy = []string{GroupByCountry}
+	rows, err = store.QueryDeliveryStats(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, []Row{
+		{Country: "Germany", Deliveries: 3, Impressions: 2},
+		{Country: "US", Deliveries: 8, Impressions: 4, Clicks: 1},
+	}, rows)
+
+	// The range excludes its end
+	query = Query{From: day, To: day.Add(11 * time.Hour), GroupBy: []string{GroupByCampaign}}
+	rows, err = store.QueryDeliveryStats(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, []Row{{CampaignID: "spotify", Deliveries: 5, Impressions: 4, Clicks: 1}}, rows)
+
+	// Nothing in range, no rows
+	rows, err = store.QueryDeliveryStats(ctx, Query{From: day.Add(-time.Hour), To: day})
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/breaker"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// CircuitBreakerRepository fails fast with breaker.ErrOpen while the wrapped repository keeps failing
+type CircuitBreakerRepository struct {
+	next    service.CampaignRepository
+	breaker *breaker.CircuitBreaker
+}
+
+// NewCircuitBreakerRepository creates a new circuit breaker repository
+func NewCircuitBreakerRepository(repo service.CampaignRepository, cb *breaker.CircuitBreaker) service.CampaignRepository {
+	return &CircuitBreakerRepository{
+		next:    repo,
+		breaker: cb,
+	}
+}
+
+// GetActiveCampaignsWithRules implements service.CampaignRepository through the circuit breaker
+func (r *CircuitBreakerRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
+	var campaigns []models.CampaignWithRules
+	err := r.breaker.Execute(func() error {
+		var err error
+		campaigns, err = r.next.GetActiveCampaignsWithRules(ctx)
+		return err
+	})
+	return campaigns, err
+}
+
+// StreamActiveCampaignsWithRules implements service.CampaignStreamer through the circuit breaker,
+// errors of fn don't count as failures
+func (r *CircuitBreakerRepository) StreamActiveCampaignsWithRules(ctx context.Context, batchSize int, fn func(batch []models.CampaignWithRules) error) error {
+	callback := newStreamCallback(fn)
+	err := r.breaker.Execute(func() error {
+		return callback.repositoryError(service.StreamActiveCampaigns(ctx, r.next, batchSize, callback.call))
+	})
+	if err == nil {
+		return callback.err
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/errorreporter"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// ErrorReportingRepository wraps a repository and reports failures to an error reporter
+type ErrorReportingRepository struct {
+	next     service.CampaignRepository
+	reporter errorreporter.Reporter
+}
+
+// NewErrorReportingRepository creates a new error reporting repository
+func NewErrorReportingRepository(repo service.CampaignRepository, reporter errorreporter.Reporter) service.CampaignRepository {
+	return &ErrorReportingRepository{
+		next:     repo,
+		reporter: reporter,
+	}
+}
+
+// GetActiveCampaignsWithRules implements service.CampaignRepository with error reporting
+func (r *ErrorReportingRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
+	campaigns, err := r.next.GetActiveCampaignsWithRules(ctx)
+	if err != nil {
+		r.reporter.Report(ctx, err, map[string]string{
+			"component":  "repository",
+			"operation":  "GetActiveCampaignsWithRules",
+			"error_type": classifyDatabaseError(err),
+		})
+	}
+	return campaigns, err
+}
+
+// StreamActiveCampaignsWithRules implements service.CampaignStreamer with error reporting, errors
+// of fn aren't reported
+func (r *ErrorReportingRepository) StreamActiveCampaignsWithRules(ctx context.Context, batchSize int, fn func(batch []models.CampaignWithRules) error) error {
+	callback := newStreamCallback(fn)
+	err := service.StreamActiveCampaigns(ctx, r.next, batchSize, callback.call)
+	if repoErr := callback.repositoryError(err); repoErr != nil {
+		r.reporter.Report(ctx, repoErr, map[string]string{
+			"component":  "repository",
+			"operation":  "StreamActiveCampaignsWithRules",
+			"error_type": classifyDatabaseError(repoErr),
+		})
+	}
+	return err
+}
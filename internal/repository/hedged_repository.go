@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/hedge"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// HedgeTargetPostgres is the hedge metric target of database reads
+const HedgeTargetPostgres = "postgres"
+
+// HedgedRepository sends a second read when the first hasn't returned within delay and
+// uses whichever answers first, cutting tail latency caused by an occasionally slow replica.
+// Each hedge holds a second database connection while both reads are in flight.
+type HedgedRepository struct {
+	next     service.CampaignRepository
+	delay    time.Duration
+	recorder hedge.Recorder
+}
+
+// NewHedgedRepository creates a new hedged repository, recorder may be nil
+func NewHedgedRepository(repo service.CampaignRepository, delay time.Duration, recorder hedge.Recorder) service.CampaignRepository {
+	return &HedgedRepository{
+		next:     repo,
+		delay:    delay,
+		recorder: recorder,
+	}
+}
+
+// GetActiveCampaignsWithRules implements service.CampaignRepository with hedged reads
+func (r *HedgedRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
+	campaigns, winner, err := hedge.Do(ctx, r.delay, r.next.GetActiveCampaignsWithRules)
+	if winner != "" && r.recorder != nil {
+		r.recorder.RecordHedgedRead(HedgeTargetPostgres, winner)
+	}
+	return campaigns, err
+}
+
+// StreamActiveCampaignsWithRules implements service.CampaignStreamer without hedging, batches are
+// passed on as they're read so a second stream can't take over
+func (r *HedgedRepository) StreamActiveCampaignsWithRules(ctx context.Context, batchSize int, fn func(batch []models.CampaignWithRules) error) error {
+	return service.StreamActiveCampaigns(ctx, r.next, batchSize, fn)
+}
@@ -2,8 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/lib/pq"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
@@ -13,29 +17,84 @@ import (
 type InstrumentedRepository struct {
 	next    service.CampaignRepository
 	metrics *metrics.CachedMetrics
+	logger  log.Logger
+	// Queries taking longer than this are logged, 0 disables slow query logging
+	slowQueryThreshold time.Duration
 }
 
 // NewInstrumentedRepository creates a new instrumented repository
 func NewInstrumentedRepository(repo service.CampaignRepository, metrics *metrics.CachedMetrics) service.CampaignRepository {
+	return NewInstrumentedRepositoryWithLogger(repo, metrics, log.NewNopLogger(), 0)
+}
+
+// NewInstrumentedRepositoryWithLogger creates a new instrumented repository that logs slow queries
+func NewInstrumentedRepositoryWithLogger(repo service.CampaignRepository, metrics *metrics.CachedMetrics, logger log.Logger, slowQueryThreshold time.Duration) service.CampaignRepository {
 	return &InstrumentedRepository{
-		next:    repo,
-		metrics: metrics,
+		next:               repo,
+		metrics:            metrics,
+		logger:             logger,
+		slowQueryThreshold: slowQueryThreshold,
 	}
 }
 
 // GetActiveCampaignsWithRules implements service.CampaignRepository with metrics
 func (r *InstrumentedRepository) GetActiveCampaignsWithRules(ctx context.Context) (campaigns []models.CampaignWithRules, err error) {
 	defer func(begin time.Time) {
-		// Record database query metrics
-		r.metrics.RecordDatabaseQuery("select", "campaigns")
-		r.metrics.RecordDatabaseQuery("select", "targeting_rules")
-
-		// Record errors if any
-		if err != nil {
-			r.metrics.RecordDatabaseError("select", "query_error")
-		}
+		r.observe(time.Since(begin), len(campaigns), err)
 	}(time.Now())
 
 	campaigns, err = r.next.GetActiveCampaignsWithRules(ctx)
 	return
 }
+
+// StreamActiveCampaignsWithRules implements service.CampaignStreamer with metrics, a stream is
+// measured as one query taking until its last batch was consumed. Errors of fn aren't recorded.
+func (r *InstrumentedRepository) StreamActiveCampaignsWithRules(ctx context.Context, batchSize int, fn func(batch []models.CampaignWithRules) error) error {
+	callback := newStreamCallback(fn)
+	begin := time.Now()
+	err := service.StreamActiveCampaigns(ctx, r.next, batchSize, callback.call)
+	r.observe(time.Since(begin), callback.rows, callback.repositoryError(err))
+	return err
+}
+
+// observe records the metrics of a load of the active campaigns and logs it when slow
+func (r *InstrumentedRepository) observe(took time.Duration, rows int, err error) {
+	// Record database query metrics
+	r.metrics.RecordDatabaseQuery("select", "campaigns")
+	r.metrics.RecordDatabaseQuery("select", "targeting_rules")
+	r.metrics.ObserveDatabaseQuery(metrics.QueryActiveCampaignsWithRules, took.Seconds(), rows)
+
+	// Record errors if any
+	if err != nil {
+		r.metrics.RecordDatabaseError("select", classifyDatabaseError(err))
+	}
+
+	// Log slow queries
+	if r.slowQueryThreshold > 0 && took > r.slowQueryThreshold {
+		level.Warn(r.logger).Log(
+			"msg", "slow query",
+			"query", metrics.QueryActiveCampaignsWithRules,
+			"took", took,
+			"threshold", r.slowQueryThreshold,
+			"rows", rows,
+		)
+	}
+}
+
+// classifyDatabaseError maps an error to a low-cardinality error_type label
+func classifyDatabaseError(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+
+	// Use the SQLSTATE class name (e.g. "connection_exception") for postgres errors
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Class().Name()
+	}
+
+	return "query_error"
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyDatabaseError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "deadline exceeded",
+			err:  fmt.Errorf("failed to query campaigns: %w", context.DeadlineExceeded),
+			want: "timeout",
+		},
+		{
+			name: "context canceled",
+			err:  context.Canceled,
+			want: "canceled",
+		},
+		{
+			name: "postgres connection error",
+			err:  fmt.Errorf("failed to query campaigns: %w", &pq.Error{Code: "08006"}),
+			want: "connection_exception",
+		},
+		{
+			name: "unknown error",
+			err:  errors.New("boom"),
+			want: "query_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyDatabaseError(tt.err))
+		})
+	}
+}
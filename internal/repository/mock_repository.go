@@ -2,15 +2,23 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
 )
 
-// mockRepository implements service.CampaignRepository for testing
+// mockRepository implements service.CampaignRepository and service.CampaignStore for testing
+// and the mock mode, changes are kept in memory only
 type mockRepository struct {
 	campaigns []models.CampaignWithRules
+	// versions holds the rule set versions of each campaign, oldest first
+	versions map[string][]models.RuleSetVersion
+	mu       sync.RWMutex
 }
 
 // NewMockRepository creates a new mock repository with sample data
@@ -100,13 +108,31 @@ func NewMockRepository() service.CampaignRepository {
 		},
 	}
 
+	return NewMockRepositoryWithCampaigns(campaigns...)
+}
+
+// NewMockRepositoryWithCampaigns creates a new mock repository holding campaigns, each with
+// its rules as version 1
+func NewMockRepositoryWithCampaigns(campaigns ...models.CampaignWithRules) service.CampaignRepository {
+	now := time.Now()
+	versions := make(map[string][]models.RuleSetVersion, len(campaigns))
+	for _, campaign := range campaigns {
+		versions[campaign.ID] = []models.RuleSetVersion{
+			{CampaignID: campaign.ID, Version: 1, Rules: copyRules(campaign.Rules), CreatedAt: now},
+		}
+	}
+
 	return &mockRepository{
 		campaigns: campaigns,
+		versions:  versions,
 	}
 }
 
 // GetActiveCampaignsWithRules returns all active campaigns with their targeting rules
 func (r *mockRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	var activeCampaigns []models.CampaignWithRules
 
 	for _, campaign := range r.campaigns {
@@ -117,3 +143,196 @@ func (r *mockRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]mod
 
 	return activeCampaigns, nil
 }
+
+// ListCampaigns returns all campaigns with their targeting rules, regardless of status
+func (r *mockRepository) ListCampaigns(ctx context.Context) ([]models.CampaignWithRules, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	campaigns := make([]models.CampaignWithRules, len(r.campaigns))
+	copy(campaigns, r.campaigns)
+	return campaigns, nil
+}
+
+// CreateCampaign adds a campaign, returning service.ErrCampaignExists if the ID is taken
+func (r *mockRepository) CreateCampaign(ctx context.Context, campaign models.CampaignWithRules) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.campaigns {
+		if existing.ID == campaign.ID {
+			return service.ErrCampaignExists
+		}
+	}
+
+	now := time.Now()
+	campaign.CreatedAt = now
+	campaign.UpdatedAt = now
+	for i := range campaign.Rules {
+		campaign.Rules[i].CampaignID = campaign.ID
+		campaign.Rules[i].CreatedAt = now
+	}
+	r.campaigns = append(r.campaigns, campaign)
+	r.versions[campaign.ID] = []models.RuleSetVersion{
+		{CampaignID: campaign.ID, Version: 1, Rules: copyRules(campaign.Rules), CreatedAt: now},
+	}
+	return nil
+}
+
+// SetCampaignStatus changes the status of a campaign, returning service.ErrCampaignNotFound if it does not exist
+func (r *mockRepository) SetCampaignStatus(ctx context.Context, id string, status models.CampaignStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.campaigns {
+		if r.campaigns[i].ID == id {
+			r.campaigns[i].Status = status
+			r.campaigns[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return service.ErrCampaignNotFound
+}
+
+// SetCampaignStatuses changes the status of several campaigns, none of them when one does not
+// exist, see service.CampaignStore
+func (r *mockRepository) SetCampaignStatuses(ctx context.Context, ids []string, status models.CampaignStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	indexes := make(map[string]int, len(r.campaigns))
+	for i, campaign := range r.campaigns {
+		indexes[campaign.ID] = i
+	}
+	var missing []string
+	for _, id := range ids {
+		if _, ok := indexes[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", service.ErrCampaignNotFound, strings.Join(missing, ", "))
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		r.campaigns[indexes[id]].Status = status
+		r.campaigns[indexes[id]].UpdatedAt = now
+	}
+	return nil
+}
+
+// SetCampaignTrafficPct changes the traffic percentage of a campaign, returning
+// service.ErrCampaignNotFound if it does not exist
+func (r *mockRepository) SetCampaignTrafficPct(ctx context.Context, id string, trafficPct int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.campaigns {
+		if r.campaigns[i].ID == id {
+			r.campaigns[i].TrafficPct = trafficPct
+			return nil
+		}
+	}
+	return service.ErrCampaignNotFound
+}
+
+// SetCampaignCategory changes the competitive category of a campaign, returning
+// service.ErrCampaignNotFound if it does not exist
+func (r *mockRepository) SetCampaignCategory(ctx context.Context, id, category string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.campaigns {
+		if r.campaigns[i].ID == id {
+			r.campaigns[i].Category = category
+			return nil
+		}
+	}
+	return service.ErrCampaignNotFound
+}
+
+// SetCampaignImage changes the image URL of a campaign, returning
+// service.ErrCampaignNotFound if it does not exist
+func (r *mockRepository) SetCampaignImage(ctx context.Context, id, imageURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.campaigns {
+		if r.campaigns[i].ID == id {
+			r.campaigns[i].ImageURL = imageURL
+			return nil
+		}
+	}
+	return service.ErrCampaignNotFound
+}
+
+// SetCampaignCreatives replaces the localized creatives of a campaign, returning
+// service.ErrCampaignNotFound if it does not exist
+func (r *mockRepository) SetCampaignCreatives(ctx context.Context, id string, creatives []models.Creative) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.campaigns {
+		if r.campaigns[i].ID == id {
+			r.campaigns[i].Creatives = slices.Clone(creatives)
+			return nil
+		}
+	}
+	return service.ErrCampaignNotFound
+}
+
+// SetCampaignRules replaces the rules of a campaign and records them as its next version, see
+// service.CampaignStore. The campaign's UpdatedAt is left alone, it tracks status changes.
+func (r *mockRepository) SetCampaignRules(ctx context.Context, version models.RuleSetVersion) (models.RuleSetVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.campaigns {
+		if r.campaigns[i].ID != version.CampaignID {
+			continue
+		}
+
+		versions := r.versions[version.CampaignID]
+		version.Version = len(versions) + 1
+		version.CreatedAt = time.Now()
+		version.Rules = copyRules(version.Rules)
+		for j := range version.Rules {
+			version.Rules[j].CampaignID = version.CampaignID
+			version.Rules[j].CreatedAt = version.CreatedAt
+		}
+
+		r.campaigns[i].Rules = copyRules(version.Rules)
+		r.versions[version.CampaignID] = append(versions, version)
+		return version, nil
+	}
+	return models.RuleSetVersion{}, service.ErrCampaignNotFound
+}
+
+// ListRuleVersions returns the rule set versions of a campaign newest first, see
+// service.CampaignStore
+func (r *mockRepository) ListRuleVersions(ctx context.Context, id string) ([]models.RuleSetVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.versions[id]
+	if !ok {
+		return nil, service.ErrCampaignNotFound
+	}
+	versions := make([]models.RuleSetVersion, len(stored))
+	for i, version := range stored {
+		version.Rules = copyRules(version.Rules)
+		versions[len(stored)-1-i] = version
+	}
+	return versions, nil
+}
+
+// copyRules returns a copy of rules sharing no values with them
+func copyRules(rules []models.TargetingRule) []models.TargetingRule {
+	copied := make([]models.TargetingRule, len(rules))
+	for i, rule := range rules {
+		copied[i] = rule
+		copied[i].Values = append([]string(nil), rule.Values...)
+	}
+	return copied
+}
@@ -5,7 +5,9 @@ import (
 	"testing"
 
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewMockRepository(t *testing.T) {
@@ -172,3 +174,78 @@ func TestMockRepository_ContextHandling(t *testing.T) {
 	assert.NoError(t, err2)
 	assert.Equal(t, campaigns1, campaigns2) // Should return same data regardless of context
 }
+
+func TestMockRepository_SetCampaignStatuses(t *testing.T) {
+	store := NewMockRepository().(service.CampaignStore)
+	ctx := context.Background()
+
+	require.NoError(t, store.SetCampaignStatuses(ctx, []string{"spotify", "duolingo"}, models.StatusInactive))
+	statuses := mockStatuses(t, store)
+	assert.Equal(t, models.StatusInactive, statuses["spotify"])
+	assert.Equal(t, models.StatusInactive, statuses["duolingo"])
+	assert.Equal(t, models.StatusActive, statuses["subwaysurfer"])
+
+	// One missing campaign leaves every status unchanged
+	err := store.SetCampaignStatuses(ctx, []string{"spotify", "unknown", "subwaysurfer"}, models.StatusActive)
+	assert.ErrorIs(t, err, service.ErrCampaignNotFound)
+	assert.EqualError(t, err, "campaign not found: unknown")
+	assert.Equal(t, statuses, mockStatuses(t, store))
+}
+
+// mockStatuses returns the status of every campaign in store by ID
+func mockStatuses(t *testing.T, store service.CampaignStore) map[string]models.CampaignStatus {
+	t.Helper()
+
+	campaigns, err := store.ListCampaigns(context.Background())
+	require.NoError(t, err)
+	statuses := make(map[string]models.CampaignStatus, len(campaigns))
+	for _, campaign := range campaigns {
+		statuses[campaign.ID] = campaign.Status
+	}
+	return statuses
+}
+
+func TestMockRepository_RuleVersions(t *testing.T) {
+	store := NewMockRepository().(service.CampaignStore)
+	ctx := context.Background()
+
+	versions, err := store.ListRuleVersions(ctx, "spotify")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, 1, versions[0].Version)
+	assert.Equal(t, []string{"US", "Canada"}, versions[0].Rules[0].Values)
+
+	rules := []models.TargetingRule{{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"US"}}}
+	stored, err := store.SetCampaignRules(ctx, models.RuleSetVersion{CampaignID: "spotify", Rules: rules, Author: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored.Version)
+	assert.Equal(t, "alice", stored.Author)
+	assert.False(t, stored.CreatedAt.IsZero())
+
+	// The campaign has the new rules, the versions are newest first
+	campaigns, err := store.ListCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"US"}, campaigns[0].Rules[0].Values)
+	versions, err = store.ListRuleVersions(ctx, "spotify")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, []int{2, 1}, []int{versions[0].Version, versions[1].Version})
+
+	// Changing the rules passed in changes neither the campaign nor its versions
+	rules[0].Values[0] = "IN"
+	versions[0].Rules[0].Values[0] = "IN"
+	versions, err = store.ListRuleVersions(ctx, "spotify")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"US"}, versions[0].Rules[0].Values)
+
+	// Created campaigns start at version 1
+	require.NoError(t, store.CreateCampaign(ctx, models.CampaignWithRules{Campaign: models.Campaign{ID: "new", Name: "New", Status: models.StatusActive}}))
+	versions, err = store.ListRuleVersions(ctx, "new")
+	require.NoError(t, err)
+	assert.Equal(t, 1, versions[0].Version)
+
+	_, err = store.SetCampaignRules(ctx, models.RuleSetVersion{CampaignID: "unknown"})
+	assert.ErrorIs(t, err, service.ErrCampaignNotFound)
+	_, err = store.ListRuleVersions(ctx, "unknown")
+	assert.ErrorIs(t, err, service.ErrCampaignNotFound)
+}
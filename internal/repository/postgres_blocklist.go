@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/blocklist"
+)
+
+// ListBlocklist returns the blocklist entries ordered by ID, implementing blocklist.Store
+func (r *PostgresRepository) ListBlocklist(ctx context.Context) ([]blocklist.Entry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, kind, value, comment, created_at
+		FROM blocklist_entries
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklist: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []blocklist.Entry
+	for rows.Next() {
+		var entry blocklist.Entry
+		if err := rows.Scan(&entry.ID, &entry.Kind, &entry.Value, &entry.Comment, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan blocklist entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	return entries, nil
+}
+
+// AddBlocklistEntry inserts an entry, returning blocklist.ErrEntryExists if its kind and value are already blocked
+func (r *PostgresRepository) AddBlocklistEntry(ctx context.Context, entry blocklist.Entry) (blocklist.Entry, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO blocklist_entries (kind, value, comment)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind, value) DO NOTHING
+		RETURNING id, created_at
+	`, entry.Kind, entry.Value, entry.Comment).Scan(&entry.ID, &entry.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return blocklist.Entry{}, blocklist.ErrEntryExists
+	}
+	if err != nil {
+		return blocklist.Entry{}, fmt.Errorf("failed to insert blocklist entry: %w", err)
+	}
+	return entry, nil
+}
+
+// RemoveBlocklistEntry deletes an entry, returning blocklist.ErrEntryNotFound if there is none with id
+func (r *PostgresRepository) RemoveBlocklistEntry(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM blocklist_entries WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete blocklist entry: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return blocklist.ErrEntryNotFound
+	}
+	return nil
+}
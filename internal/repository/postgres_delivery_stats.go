@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/database"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reporting"
+)
+
+// reportColumns are the delivery_stats expressions of the report dimensions, days are UTC days
+var reportColumns = map[string]string{
+	reporting.GroupByHour:     "hour",
+	reporting.GroupByDay:      "date_trunc('day', hour AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'",
+	reporting.GroupByCampaign: "campaign_id",
+	reporting.GroupByCountry:  "country",
+}
+
+// AddDeliveryStats adds the counts of rows to delivery_stats in a single transaction, implementing reporting.Store
+func (r *PostgresRepository) AddDeliveryStats(ctx context.Context, rows []reporting.Row) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO delivery_stats (hour, campaign_id, country, deliveries, impressions, clicks)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (hour, campaign_id, country) DO UPDATE SET
+			deliveries = delivery_stats.deliveries + EXCLUDED.deliveries,
+			impressions = delivery_stats.impressions + EXCLUDED.impressions,
+			clicks = delivery_stats.clicks + EXCLUDED.clicks
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare delivery stats insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row.Hour.UTC(), row.CampaignID, row.Country, row.Deliveries, row.Impressions, row.Clicks); err != nil {
+			return fmt.Errorf("failed to insert delivery stats: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delivery stats: %w", err)
+	}
+	return nil
+}
+
+// QueryDeliveryStats sums delivery_stats by the grouped dimensions, implementing reporting.Store
+func (r *PostgresRepository) QueryDeliveryStats(ctx context.Context, query reporting.Query) ([]reporting.Row, error) {
+	// Grouped columns in a fixed order, the dimensions come from a whitelist
+	var columns []string
+	var dimensions []string
+	for _, dimension := range reporting.GroupByDimensions {
+		if query.Groups(dimension) {
+			columns = append(columns, reportColumns[dimension])
+			dimensions = append(dimensions, dimension)
+		}
+	}
+
+	args := []any{query.From.UTC(), query.To.UTC()}
+	where := "hour >= $1 AND hour < $2"
+	if query.CampaignID != "" {
+		args = append(args, query.CampaignID)
+		where += " AND campaign_id = $3"
+	}
+
+	sql := "SELECT "
+	for _, column := range columns {
+		sql += column + ", "
+	}
+	sql += "SUM(deliveries), SUM(impressions), SUM(clicks) FROM delivery_stats WHERE " + where
+	if len(columns) > 0 {
+		sql += " GROUP BY " + strings.Join(columns, ", ")
+	}
+	// Without grouping an empty range would still sum to a single row of NULLs
+	sql += " HAVING COUNT(*) > 0"
+	if len(columns) > 0 {
+		sql += " ORDER BY " + strings.Join(columns, ", ")
+	}
+
+	rows, err := r.db.QueryContext(ctx, database.AnnotateQuery(ctx, sql), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delivery stats: %w", err)
+	}
+	defer rows.Close()
+
+	report := make([]reporting.Row, 0)
+	for rows.Next() {
+		var row reporting.Row
+		dest := make([]any, 0, len(dimensions)+3)
+		for _, dimension := range dimensions {
+			switch dimension {
+			case reporting.GroupByHour, reporting.GroupByDay:
+				dest = append(dest, &row.Hour)
+			case reporting.GroupByCampaign:
+				dest = append(dest, &row.CampaignID)
+			case reporting.GroupByCountry:
+				dest = append(dest, &row.Country)
+			}
+		}
+		dest = append(dest, &row.Deliveries, &row.Impressions, &row.Clicks)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery stats: %w", err)
+		}
+		row.Hour = row.Hour.UTC()
+		report = append(report, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over delivery stats: %w", err)
+	}
+	return report, nil
+}
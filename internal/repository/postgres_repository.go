@@ -2,7 +2,12 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/lib/pq"
@@ -11,7 +16,7 @@ import (
 	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
 )
 
-// PostgresRepository implements service.CampaignRepository using PostgreSQL
+// PostgresRepository implements service.CampaignRepository and service.CampaignStore using PostgreSQL
 type PostgresRepository struct {
 	db *database.DB
 }
@@ -25,15 +30,396 @@ func NewPostgresRepository(db *database.DB) service.CampaignRepository {
 
 // GetActiveCampaignsWithRules retrieves all active campaigns with their targeting rules
 func (r *PostgresRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
-	// First, get all active campaigns
-	campaignsQuery := `
-		SELECT id, name, image_url, cta, status, created_at, updated_at
+	return r.queryCampaignsWithRules(ctx, r.db, `
+		SELECT id, name, image_url, cta, status, created_at, updated_at, starts_at, ends_at, delivery_budget, traffic_pct, category, creatives
 		FROM campaigns
 		WHERE status = 'ACTIVE'
-		ORDER BY updated_at DESC
-	`
+		ORDER BY updated_at DESC, id DESC
+	`)
+}
+
+// StreamActiveCampaignsWithRules retrieves the active campaigns with their targeting rules in
+// batches, implementing service.CampaignStreamer. Batches are pages read with keyset pagination
+// in one read-only repeatable read transaction, so campaigns changing meanwhile are neither
+// skipped nor repeated.
+func (r *PostgresRepository) StreamActiveCampaignsWithRules(ctx context.Context, batchSize int, fn func(batch []models.CampaignWithRules) error) error {
+	if batchSize <= 0 {
+		campaigns, err := r.GetActiveCampaignsWithRules(ctx)
+		if err != nil {
+			return err
+		}
+		return fn(campaigns)
+	}
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	campaigns, err := r.queryCampaignsWithRules(ctx, tx, `
+		SELECT id, name, image_url, cta, status, created_at, updated_at, starts_at, ends_at, delivery_budget, traffic_pct, category, creatives
+		FROM campaigns
+		WHERE status = 'ACTIVE'
+		ORDER BY updated_at DESC, id DESC
+		LIMIT $1
+	`, batchSize)
+	for err == nil && len(campaigns) > 0 {
+		last := campaigns[len(campaigns)-1]
+		if err := fn(campaigns); err != nil {
+			return err
+		}
+		if len(campaigns) < batchSize {
+			break
+		}
+
+		campaigns, err = r.queryCampaignsWithRules(ctx, tx, `
+			SELECT id, name, image_url, cta, status, created_at, updated_at, starts_at, ends_at, delivery_budget, traffic_pct, category, creatives
+			FROM campaigns
+			WHERE status = 'ACTIVE' AND (updated_at, id) < ($1, $2)
+			ORDER BY updated_at DESC, id DESC
+			LIMIT $3
+		`, last.UpdatedAt, last.ID, batchSize)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit campaign stream: %w", err)
+	}
+	return nil
+}
+
+// ListCampaigns retrieves all campaigns with their targeting rules, regardless of status
+func (r *PostgresRepository) ListCampaigns(ctx context.Context) ([]models.CampaignWithRules, error) {
+	return r.queryCampaignsWithRules(ctx, r.db, `
+		SELECT id, name, image_url, cta, status, created_at, updated_at, starts_at, ends_at, delivery_budget, traffic_pct, category, creatives
+		FROM campaigns
+		ORDER BY id
+	`)
+}
+
+// CreateCampaign inserts a campaign with its targeting rules in a single transaction,
+// returning service.ErrCampaignExists if the ID is taken
+func (r *PostgresRepository) CreateCampaign(ctx context.Context, campaign models.CampaignWithRules) error {
+	creatives, err := encodeCreatives(campaign.Creatives)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO campaigns (id, name, image_url, cta, status, starts_at, ends_at, delivery_budget, traffic_pct, category, creatives)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO NOTHING
+	`, campaign.ID, campaign.Name, campaign.ImageURL, campaign.CTA, campaign.Status, campaign.StartsAt, campaign.EndsAt, campaign.DeliveryBudget, campaign.TrafficPct, campaign.Category, creatives)
+	if err != nil {
+		return fmt.Errorf("failed to insert campaign: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return service.ErrCampaignExists
+	}
+
+	if err := insertRules(ctx, tx, campaign.ID, campaign.Rules); err != nil {
+		return err
+	}
+	if _, err := insertRuleVersion(ctx, tx, models.RuleSetVersion{CampaignID: campaign.ID, Version: 1, Rules: campaign.Rules}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit campaign: %w", err)
+	}
+	return nil
+}
+
+// SetCampaignRules replaces the rules of a campaign and records them as its next version in a
+// single transaction, see service.CampaignStore. The campaign row is locked so concurrent changes
+// get consecutive versions, its updated_at is left alone as it tracks status changes.
+func (r *PostgresRepository) SetCampaignRules(ctx context.Context, version models.RuleSetVersion) (models.RuleSetVersion, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.RuleSetVersion{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRowContext(ctx, `
+		SELECT id FROM campaigns
+		WHERE id = $1
+		FOR UPDATE
+	`, version.CampaignID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.RuleSetVersion{}, service.ErrCampaignNotFound
+	}
+	if err != nil {
+		return models.RuleSetVersion{}, fmt.Errorf("failed to lock campaign: %w", err)
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(version), 0) + 1 FROM campaign_rule_versions
+		WHERE campaign_id = $1
+	`, version.CampaignID).Scan(&version.Version); err != nil {
+		return models.RuleSetVersion{}, fmt.Errorf("failed to number rule version: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM targeting_rules
+		WHERE campaign_id = $1
+	`, version.CampaignID); err != nil {
+		return models.RuleSetVersion{}, fmt.Errorf("failed to delete targeting rules: %w", err)
+	}
+	if err := insertRules(ctx, tx, version.CampaignID, version.Rules); err != nil {
+		return models.RuleSetVersion{}, err
+	}
+	if version, err = insertRuleVersion(ctx, tx, version); err != nil {
+		return models.RuleSetVersion{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.RuleSetVersion{}, fmt.Errorf("failed to commit targeting rules: %w", err)
+	}
+	return version, nil
+}
 
-	rows, err := r.db.QueryContext(ctx, campaignsQuery)
+// ListRuleVersions returns the rule set versions of a campaign newest first, see
+// service.CampaignStore
+func (r *PostgresRepository) ListRuleVersions(ctx context.Context, id string) ([]models.RuleSetVersion, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT version, rules, author, comment, created_at
+		FROM campaign_rule_versions
+		WHERE campaign_id = $1
+		ORDER BY version DESC
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rule versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []models.RuleSetVersion
+	for rows.Next() {
+		version := models.RuleSetVersion{CampaignID: id}
+		var rules []byte
+		if err := rows.Scan(&version.Version, &rules, &version.Author, &version.Comment, &version.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rule version: %w", err)
+		}
+		if err := json.Unmarshal(rules, &version.Rules); err != nil {
+			return nil, fmt.Errorf("failed to decode rules of version %d: %w", version.Version, err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rule versions: %w", err)
+	}
+
+	// Every campaign has version 1, none means it does not exist
+	if len(versions) == 0 {
+		return nil, service.ErrCampaignNotFound
+	}
+	return versions, nil
+}
+
+// insertRules inserts the targeting rules of a campaign
+func insertRules(ctx context.Context, tx *sql.Tx, campaignID string, rules []models.TargetingRule) error {
+	for _, rule := range rules {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO targeting_rules (campaign_id, dimension, rule_type, values)
+			VALUES ($1, $2, $3, $4)
+		`, campaignID, rule.Dimension, rule.RuleType, pq.Array(rule.Values))
+		if err != nil {
+			return fmt.Errorf("failed to insert targeting rule: %w", err)
+		}
+	}
+	return nil
+}
+
+// insertRuleVersion records a rule set version, returning it with its creation time set. Rules
+// are stored as ListCampaigns returns them, without their IDs and creation times.
+func insertRuleVersion(ctx context.Context, tx *sql.Tx, version models.RuleSetVersion) (models.RuleSetVersion, error) {
+	rules := make([]models.TargetingRule, len(version.Rules))
+	for i, rule := range version.Rules {
+		rules[i] = models.TargetingRule{CampaignID: version.CampaignID, Dimension: rule.Dimension, RuleType: rule.RuleType, Values: rule.Values}
+	}
+	encoded, err := json.Marshal(rules)
+	if err != nil {
+		return models.RuleSetVersion{}, fmt.Errorf("failed to encode rules: %w", err)
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO campaign_rule_versions (campaign_id, version, rules, author, comment)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, version.CampaignID, version.Version, encoded, version.Author, version.Comment).Scan(&version.CreatedAt); err != nil {
+		return models.RuleSetVersion{}, fmt.Errorf("failed to insert rule version: %w", err)
+	}
+	version.Rules = rules
+	return version, nil
+}
+
+// SetCampaignStatus changes the status of a campaign, returning service.ErrCampaignNotFound if it does not exist
+func (r *PostgresRepository) SetCampaignStatus(ctx context.Context, id string, status models.CampaignStatus) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE campaigns SET status = $2
+		WHERE id = $1
+	`, id, status)
+	if err != nil {
+		return fmt.Errorf("failed to update campaign status: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return service.ErrCampaignNotFound
+	}
+	return nil
+}
+
+// SetCampaignTrafficPct changes the traffic percentage of a campaign, returning
+// service.ErrCampaignNotFound if it does not exist
+func (r *PostgresRepository) SetCampaignTrafficPct(ctx context.Context, id string, trafficPct int) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE campaigns SET traffic_pct = $2
+		WHERE id = $1
+	`, id, trafficPct)
+	if err != nil {
+		return fmt.Errorf("failed to update campaign traffic percentage: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return service.ErrCampaignNotFound
+	}
+	return nil
+}
+
+// SetCampaignCategory changes the competitive category of a campaign, returning
+// service.ErrCampaignNotFound if it does not exist
+func (r *PostgresRepository) SetCampaignCategory(ctx context.Context, id, category string) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE campaigns SET category = $2
+		WHERE id = $1
+	`, id, category)
+	if err != nil {
+		return fmt.Errorf("failed to update campaign category: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return service.ErrCampaignNotFound
+	}
+	return nil
+}
+
+// SetCampaignImage changes the image URL of a campaign, returning
+// service.ErrCampaignNotFound if it does not exist
+func (r *PostgresRepository) SetCampaignImage(ctx context.Context, id, imageURL string) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE campaigns SET image_url = $2
+		WHERE id = $1
+	`, id, imageURL)
+	if err != nil {
+		return fmt.Errorf("failed to update campaign image: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return service.ErrCampaignNotFound
+	}
+	return nil
+}
+
+// SetCampaignCreatives replaces the localized creatives of a campaign, returning
+// service.ErrCampaignNotFound if it does not exist
+func (r *PostgresRepository) SetCampaignCreatives(ctx context.Context, id string, creatives []models.Creative) error {
+	encoded, err := encodeCreatives(creatives)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE campaigns SET creatives = $2
+		WHERE id = $1
+	`, id, encoded)
+	if err != nil {
+		return fmt.Errorf("failed to update campaign creatives: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return service.ErrCampaignNotFound
+	}
+	return nil
+}
+
+// encodeCreatives encodes localized creatives for the creatives column, none as an empty array
+func encodeCreatives(creatives []models.Creative) ([]byte, error) {
+	if creatives == nil {
+		creatives = []models.Creative{}
+	}
+	encoded, err := json.Marshal(creatives)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode creatives: %w", err)
+	}
+	return encoded, nil
+}
+
+// SetCampaignStatuses changes the status of several campaigns in a single transaction, none of
+// them when one does not exist, see service.CampaignStore
+func (r *PostgresRepository) SetCampaignStatuses(ctx context.Context, ids []string, status models.CampaignStatus) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the campaigns first, so none is deleted between the check and the update
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id FROM campaigns
+		WHERE id = ANY($1)
+		FOR UPDATE
+	`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to lock campaigns: %w", err)
+	}
+	found := make(map[string]bool, len(ids))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan campaign ID: %w", err)
+		}
+		found[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to lock campaigns: %w", err)
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", service.ErrCampaignNotFound, strings.Join(missing, ", "))
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE campaigns SET status = $2
+		WHERE id = ANY($1)
+	`, pq.Array(ids), status); err != nil {
+		return fmt.Errorf("failed to update campaign statuses: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit campaign statuses: %w", err)
+	}
+	return nil
+}
+
+// queryer runs queries on the database or within a transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// queryCampaignsWithRules runs campaignsQuery with args on q and attaches the targeting rules of
+// the returned campaigns
+func (r *PostgresRepository) queryCampaignsWithRules(ctx context.Context, q queryer, campaignsQuery string, args ...any) ([]models.CampaignWithRules, error) {
+	rows, err := q.QueryContext(ctx, database.AnnotateQuery(ctx, campaignsQuery), args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query campaigns: %w", err)
 	}
@@ -45,6 +431,7 @@ func (r *PostgresRepository) GetActiveCampaignsWithRules(ctx context.Context) ([
 	for rows.Next() {
 		var campaignWithRules models.CampaignWithRules
 		var createdAt, updatedAt time.Time
+		var creatives []byte
 
 		err := rows.Scan(
 			&campaignWithRules.ID,
@@ -54,10 +441,22 @@ func (r *PostgresRepository) GetActiveCampaignsWithRules(ctx context.Context) ([
 			&campaignWithRules.Status,
 			&createdAt,
 			&updatedAt,
+			&campaignWithRules.StartsAt,
+			&campaignWithRules.EndsAt,
+			&campaignWithRules.DeliveryBudget,
+			&campaignWithRules.TrafficPct,
+			&campaignWithRules.Category,
+			&creatives,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan campaign: %w", err)
 		}
+		if err := json.Unmarshal(creatives, &campaignWithRules.Creatives); err != nil {
+			return nil, fmt.Errorf("failed to decode creatives of campaign %s: %w", campaignWithRules.ID, err)
+		}
+		if len(campaignWithRules.Creatives) == 0 {
+			campaignWithRules.Creatives = nil
+		}
 
 		campaignWithRules.CreatedAt = createdAt
 		campaignWithRules.UpdatedAt = updatedAt
@@ -84,20 +483,26 @@ func (r *PostgresRepository) GetActiveCampaignsWithRules(ctx context.Context) ([
 		ORDER BY campaign_id, id
 	`
 
-	rulesRows, err := r.db.QueryContext(ctx, rulesQuery, pq.Array(campaignIDs))
+	rulesRows, err := q.QueryContext(ctx, database.AnnotateQuery(ctx, rulesQuery), pq.Array(campaignIDs))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query targeting rules: %w", err)
 	}
 	defer rulesRows.Close()
 
-	// Group rules by campaign ID
-	rulesByCampaign := make(map[string][]models.TargetingRule)
+	// Scan the rules into a pooled slice, they're copied into compact chunks below
+	rules := scanScratch.Get().(*[]models.TargetingRule)
+	defer func() {
+		clear(*rules)
+		*rules = (*rules)[:0]
+		scanScratch.Put(rules)
+	}()
+
 	for rulesRows.Next() {
-		var rule models.TargetingRule
-		var campaignID string
+		*rules = append(*rules, models.TargetingRule{})
+		rule := &(*rules)[len(*rules)-1]
 
 		err := rulesRows.Scan(
-			&campaignID,
+			&rule.CampaignID,
 			&rule.Dimension,
 			&rule.RuleType,
 			pq.Array(&rule.Values),
@@ -105,21 +510,35 @@ func (r *PostgresRepository) GetActiveCampaignsWithRules(ctx context.Context) ([
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan targeting rule: %w", err)
 		}
-
-		rule.CampaignID = campaignID
-		rulesByCampaign[campaignID] = append(rulesByCampaign[campaignID], rule)
 	}
 
 	if err := rulesRows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating over targeting rules: %w", err)
 	}
 
-	// Assign rules to campaigns
+	// Assign rules to campaigns, the rules of a campaign are adjacent as they're ordered by campaign ID
+	campaignIndex := make(map[string]int, len(campaigns))
 	for i := range campaigns {
-		if rules, exists := rulesByCampaign[campaigns[i].ID]; exists {
-			campaigns[i].Rules = rules
+		campaignIndex[campaigns[i].ID] = i
+	}
+	for start := 0; start < len(*rules); {
+		campaignID := (*rules)[start].CampaignID
+		end := start + 1
+		for end < len(*rules) && (*rules)[end].CampaignID == campaignID {
+			end++
+		}
+		if i, exists := campaignIndex[campaignID]; exists {
+			campaigns[i].Rules = (*rules)[start:end]
 		}
+		start = end
 	}
 
-	return campaigns, nil
+	// Every row holds its own copy of the rule values, the cache keeps one of each
+	return models.CompactCampaigns(campaigns), nil
+}
+
+// scanScratch holds the targeting rules scanned by a query until they're compacted, so loads
+// don't grow a slice of rules per campaign each time
+var scanScratch = sync.Pool{
+	New: func() any { return new([]models.TargetingRule) },
 }
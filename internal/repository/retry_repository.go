@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// RetryConfig configures retries of repository reads
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts including the first, 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubling on each further retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff
+	MaxDelay time.Duration
+}
+
+// RetryRecorder records repository retries, metrics.CachedMetrics implements it
+type RetryRecorder interface {
+	RecordRepositoryRetry(errorType string)
+}
+
+// RetryRepository retries reads that failed with transient errors using jittered
+// exponential backoff. Reads are side-effect free, so retrying them is always safe.
+type RetryRepository struct {
+	next     service.CampaignRepository
+	config   RetryConfig
+	recorder RetryRecorder
+}
+
+// NewRetryRepository creates a new retrying repository, recorder may be nil
+func NewRetryRepository(repo service.CampaignRepository, config RetryConfig, recorder RetryRecorder) service.CampaignRepository {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.MaxDelay < config.BaseDelay {
+		config.MaxDelay = config.BaseDelay
+	}
+
+	return &RetryRepository{
+		next:     repo,
+		config:   config,
+		recorder: recorder,
+	}
+}
+
+// GetActiveCampaignsWithRules implements service.CampaignRepository with retries
+func (r *RetryRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
+	var campaigns []models.CampaignWithRules
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		campaigns, err = r.next.GetActiveCampaignsWithRules(ctx)
+		if err == nil || attempt >= r.config.MaxAttempts || !isTransientError(err) {
+			return campaigns, err
+		}
+
+		// Give up rather than retry past the caller's deadline
+		delay := r.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return campaigns, err
+		}
+
+		if r.recorder != nil {
+			r.recorder.RecordRepositoryRetry(classifyDatabaseError(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return campaigns, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// StreamActiveCampaignsWithRules implements service.CampaignStreamer with retries. A stream is
+// only retried until its first batch was passed to fn, later failures are returned.
+func (r *RetryRepository) StreamActiveCampaignsWithRules(ctx context.Context, batchSize int, fn func(batch []models.CampaignWithRules) error) error {
+	callback := newStreamCallback(fn)
+
+	for attempt := 1; ; attempt++ {
+		err := service.StreamActiveCampaigns(ctx, r.next, batchSize, callback.call)
+		if err == nil || callback.batches > 0 || attempt >= r.config.MaxAttempts || !isTransientError(err) {
+			return err
+		}
+
+		delay := r.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		if r.recorder != nil {
+			r.recorder.RecordRepositoryRetry(classifyDatabaseError(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns the delay before the given retry using full jitter
+func (r *RetryRepository) backoff(attempt int) time.Duration {
+	delay := r.config.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > r.config.MaxDelay {
+		delay = r.config.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// isTransientError reports whether an error is likely to succeed on retry
+func isTransientError(err error) bool {
+	// The caller gave up or the budget is spent, retrying can't help
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", // connection_exception
+			"40", // transaction_rollback: serialization failures and deadlocks
+			"53", // insufficient_resources: e.g. too_many_connections
+			"57": // operator_intervention: e.g. admin_shutdown during failover
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyRepository fails with err until it has been called failures times
+type flakyRepository struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (r *flakyRepository) GetActiveCampaignsWithRules(ctx context.Context) ([]models.CampaignWithRules, error) {
+	r.calls++
+	if r.calls <= r.failures {
+		return nil, r.err
+	}
+	return []models.CampaignWithRules{{Campaign: models.Campaign{ID: "spotify"}}}, nil
+}
+
+type countingRecorder struct {
+	retries []string
+}
+
+func (r *countingRecorder) RecordRepositoryRetry(errorType string) {
+	r.retries = append(r.retries, errorType)
+}
+
+func TestRetryRepository(t *testing.T) {
+	connErr := fmt.Errorf("failed to query campaigns: %w", &pq.Error{Code: "08006"})
+	config := RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		next := &flakyRepository{failures: 2, err: connErr}
+		recorder := &countingRecorder{}
+
+		campaigns, err := NewRetryRepository(next, config, recorder).GetActiveCampaignsWithRules(context.Background())
+
+		assert.NoError(t, err)
+		assert.Len(t, campaigns, 1)
+		assert.Equal(t, 3, next.calls)
+		assert.Equal(t, []string{"connection_exception", "connection_exception"}, recorder.retries)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		next := &flakyRepository{failures: 5, err: connErr}
+
+		_, err := NewRetryRepository(next, config, nil).GetActiveCampaignsWithRules(context.Background())
+
+		assert.ErrorIs(t, err, connErr)
+		assert.Equal(t, 3, next.calls)
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		next := &flakyRepository{failures: 1, err: errors.New("syntax error")}
+
+		_, err := NewRetryRepository(next, config, nil).GetActiveCampaignsWithRules(context.Background())
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, next.calls)
+	})
+
+	t.Run("respects the caller deadline", func(t *testing.T) {
+		next := &flakyRepository{failures: 5, err: connErr}
+		slow := RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := NewRetryRepository(next, slow, nil).GetActiveCampaignsWithRules(ctx)
+
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+}
+
+// flakyStreamer streams two batches, failing with err after failAfter batches until it has been
+// called failures times
+type flakyStreamer struct {
+	flakyRepository
+	failAfter int
+}
+
+func (r *flakyStreamer) StreamActiveCampaignsWithRules(ctx context.Context, batchSize int, fn func(batch []models.CampaignWithRules) error) error {
+	r.calls++
+	for i, id := range []string{"spotify", "duolingo"} {
+		if i == r.failAfter && r.calls <= r.failures {
+			return r.err
+		}
+		if err := fn([]models.CampaignWithRules{{Campaign: models.Campaign{ID: id}}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRetryRepository_Stream(t *testing.T) {
+	connErr := fmt.Errorf("failed to query campaigns: %w", &pq.Error{Code: "08006"})
+	config := RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	stream := func(repo *RetryRepository) ([]string, error) {
+		var ids []string
+		err := repo.StreamActiveCampaignsWithRules(context.Background(), 1, func(batch []models.CampaignWithRules) error {
+			for _, campaign := range batch {
+				ids = append(ids, campaign.ID)
+			}
+			return nil
+		})
+		return ids, err
+	}
+
+	t.Run("retries before the first batch", func(t *testing.T) {
+		next := &flakyStreamer{flakyRepository: flakyRepository{failures: 2, err: connErr}}
+
+		ids, err := stream(NewRetryRepository(next, config, nil).(*RetryRepository))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"spotify", "duolingo"}, ids)
+		assert.Equal(t, 3, next.calls)
+	})
+
+	t.Run("does not retry after a batch was passed on", func(t *testing.T) {
+		next := &flakyStreamer{flakyRepository: flakyRepository{failures: 2, err: connErr}, failAfter: 1}
+
+		ids, err := stream(NewRetryRepository(next, config, nil).(*RetryRepository))
+
+		assert.ErrorIs(t, err, connErr)
+		assert.Equal(t, []string{"spotify"}, ids)
+		assert.Equal(t, 1, next.calls)
+	})
+
+	t.Run("does not retry errors of the callback", func(t *testing.T) {
+		next := &flakyStreamer{}
+		errFull := errors.New("too many campaigns")
+
+		err := NewRetryRepository(next, config, nil).(*RetryRepository).StreamActiveCampaignsWithRules(context.Background(), 1, func(batch []models.CampaignWithRules) error {
+			return errFull
+		})
+
+		assert.ErrorIs(t, err, errFull)
+		assert.Equal(t, 1, next.calls)
+	})
+}
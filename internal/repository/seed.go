@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/database"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// SeedCampaigns inserts campaigns with their targeting rules in a single transaction.
+// Campaigns that already exist are left untouched, so seeding can be repeated safely.
+// It returns the number of campaigns inserted.
+func SeedCampaigns(ctx context.Context, db *database.DB, campaigns []models.CampaignWithRules) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin seed transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	inserted := 0
+	for _, campaign := range campaigns {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO campaigns (id, name, image_url, cta, status)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (id) DO NOTHING
+		`, campaign.ID, campaign.Name, campaign.ImageURL, campaign.CTA, campaign.Status)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert campaign %s: %w", campaign.ID, err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			continue
+		}
+
+		for _, rule := range campaign.Rules {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO targeting_rules (campaign_id, dimension, rule_type, values)
+				VALUES ($1, $2, $3, $4)
+			`, campaign.ID, rule.Dimension, rule.RuleType, pq.Array(rule.Values))
+			if err != nil {
+				return 0, fmt.Errorf("failed to insert targeting rule of campaign %s: %w", campaign.ID, err)
+			}
+		}
+		inserted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit seed transaction: %w", err)
+	}
+	return inserted, nil
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// streamCallback wraps the callback of a campaign stream, counting what it was passed and
+// keeping its error, so decorators can tell repository failures from failures of the consumer
+type streamCallback struct {
+	fn      func(batch []models.CampaignWithRules) error
+	batches int
+	rows    int
+	err     error
+}
+
+func newStreamCallback(fn func(batch []models.CampaignWithRules) error) *streamCallback {
+	return &streamCallback{fn: fn}
+}
+
+func (c *streamCallback) call(batch []models.CampaignWithRules) error {
+	c.batches++
+	c.rows += len(batch)
+	c.err = c.fn(batch)
+	return c.err
+}
+
+// repositoryError returns the error of a stream unless it came from the callback
+func (c *streamCallback) repositoryError(err error) error {
+	if c.err != nil && errors.Is(err, c.err) {
+		return nil
+	}
+	return err
+}
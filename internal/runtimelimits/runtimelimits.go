@@ -0,0 +1,129 @@
+package runtimelimits
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/KimMachineGun/automemlimit/memlimit"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/automaxprocs/maxprocs"
+)
+
+// Sources of the runtime limits, used as the source label
+const (
+	// SourceEnv is a limit set by the GOMAXPROCS or GOMEMLIMIT environment variable
+	SourceEnv = "env"
+	// SourceCgroup is a limit derived from the CPU quota or memory limit of the container
+	SourceCgroup = "cgroup"
+	// SourceRuntime is the Go runtime's own default, e.g. outside containers or when disabled
+	SourceRuntime = "runtime"
+)
+
+// Config holds what to derive from the container's cgroup limits
+type Config struct {
+	// AutoMaxProcs sets GOMAXPROCS to the CPU quota, rounded down and at least 1
+	AutoMaxProcs bool
+	// AutoMemLimit sets GOMEMLIMIT to MemLimitRatio of the memory limit, leaving the rest for
+	// memory the Go runtime doesn't manage
+	AutoMemLimit  bool
+	MemLimitRatio float64
+}
+
+// Limits are the runtime limits in effect after Apply
+type Limits struct {
+	GOMAXPROCS       int
+	GOMAXPROCSSource string
+	// MemoryLimit is the soft memory limit of the Go runtime in bytes, 0 when unlimited
+	MemoryLimit       int64
+	MemoryLimitSource string
+}
+
+// Apply sets GOMAXPROCS and GOMEMLIMIT from the cgroup limits of the process as configured, unless
+// the environment sets them. Limits that can't be read are left to the runtime and reported in
+// the returned error, the other one is still applied.
+func Apply(config Config, logger log.Logger) (Limits, error) {
+	return apply(config, logger, memlimit.FromCgroup)
+}
+
+// apply is Apply with the memory limit read from memoryLimit
+func apply(config Config, logger log.Logger, memoryLimit memlimit.Provider) (Limits, error) {
+	limits := Limits{GOMAXPROCSSource: SourceRuntime, MemoryLimitSource: SourceRuntime}
+	var errs []error
+
+	if _, ok := os.LookupEnv("GOMAXPROCS"); ok {
+		limits.GOMAXPROCSSource = SourceEnv
+	} else if config.AutoMaxProcs {
+		before := runtime.GOMAXPROCS(0)
+		printf := func(format string, args ...any) {
+			level.Debug(logger).Log("msg", fmt.Sprintf(format, args...))
+		}
+		if _, err := maxprocs.Set(maxprocs.Logger(printf)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set GOMAXPROCS from the CPU quota: %w", err))
+		} else if runtime.GOMAXPROCS(0) != before {
+			limits.GOMAXPROCSSource = SourceCgroup
+		}
+	}
+	limits.GOMAXPROCS = runtime.GOMAXPROCS(0)
+
+	if _, ok := os.LookupEnv("GOMEMLIMIT"); ok {
+		limits.MemoryLimitSource = SourceEnv
+	} else if config.AutoMemLimit {
+		limit, err := memlimit.SetGoMemLimitWithOpts(
+			memlimit.WithProvider(memoryLimit),
+			memlimit.WithRatio(config.MemLimitRatio),
+		)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to set GOMEMLIMIT from the memory limit: %w", err))
+		} else if limit > 0 {
+			limits.MemoryLimitSource = SourceCgroup
+		}
+	}
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		limits.MemoryLimit = limit
+	}
+
+	return limits, errors.Join(errs...)
+}
+
+// Collector exports the runtime limits in effect as adbeacon_runtime_gomaxprocs and
+// adbeacon_runtime_memory_limit_bytes, labeled with the source of the limit
+type Collector struct {
+	limits     Limits
+	gomaxprocs *prometheus.Desc
+	memLimit   *prometheus.Desc
+}
+
+// NewCollector creates a collector of limits, register it with Prometheus to export them
+func NewCollector(limits Limits) *Collector {
+	return &Collector{
+		limits: limits,
+		gomaxprocs: prometheus.NewDesc(
+			"adbeacon_runtime_gomaxprocs",
+			"GOMAXPROCS of the Go runtime",
+			[]string{"source"}, nil,
+		),
+		memLimit: prometheus.NewDesc(
+			"adbeacon_runtime_memory_limit_bytes",
+			"Soft memory limit (GOMEMLIMIT) of the Go runtime in bytes, 0 when unlimited",
+			[]string{"source"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.gomaxprocs
+	ch <- c.memLimit
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.gomaxprocs, prometheus.GaugeValue, float64(c.limits.GOMAXPROCS), c.limits.GOMAXPROCSSource)
+	ch <- prometheus.MustNewConstMetric(c.memLimit, prometheus.GaugeValue, float64(c.limits.MemoryLimit), c.limits.MemoryLimitSource)
+}
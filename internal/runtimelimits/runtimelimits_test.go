@@ -0,0 +1,97 @@
+package runtimelimits
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/KimMachineGun/automemlimit/memlimit"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unsetenv unsets key for the test, restoring it afterwards
+func unsetenv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	require.NoError(t, os.Unsetenv(key))
+}
+
+// restoreMemoryLimit restores the soft memory limit of the runtime after the test
+func restoreMemoryLimit(t *testing.T) {
+	previous := debug.SetMemoryLimit(-1)
+	t.Cleanup(func() { debug.SetMemoryLimit(previous) })
+}
+
+func TestApply_MemoryLimitFromCgroup(t *testing.T) {
+	unsetenv(t, "GOMEMLIMIT")
+	unsetenv(t, "AUTOMEMLIMIT")
+	restoreMemoryLimit(t)
+
+	limits, err := apply(Config{AutoMemLimit: true, MemLimitRatio: 0.5}, log.NewNopLogger(), memlimit.Limit(1<<30))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(512<<20), limits.MemoryLimit)
+	assert.Equal(t, SourceCgroup, limits.MemoryLimitSource)
+	assert.Equal(t, int64(512<<20), debug.SetMemoryLimit(-1))
+}
+
+func TestApply_NoMemoryLimit(t *testing.T) {
+	unsetenv(t, "GOMEMLIMIT")
+	unsetenv(t, "AUTOMEMLIMIT")
+	restoreMemoryLimit(t)
+	debug.SetMemoryLimit(math.MaxInt64)
+
+	unlimited := func() (uint64, error) { return 0, memlimit.ErrNoLimit }
+	limits, err := apply(Config{AutoMemLimit: true, MemLimitRatio: 0.9}, log.NewNopLogger(), unlimited)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(0), limits.MemoryLimit)
+	assert.Equal(t, SourceRuntime, limits.MemoryLimitSource)
+}
+
+func TestApply_EnvironmentWins(t *testing.T) {
+	t.Setenv("GOMAXPROCS", "2")
+	t.Setenv("GOMEMLIMIT", "1GiB")
+	restoreMemoryLimit(t)
+	debug.SetMemoryLimit(1 << 30)
+
+	limits, err := apply(Config{AutoMaxProcs: true, AutoMemLimit: true, MemLimitRatio: 0.5}, log.NewNopLogger(), memlimit.Limit(4<<30))
+	require.NoError(t, err)
+
+	assert.Equal(t, SourceEnv, limits.GOMAXPROCSSource)
+	assert.Equal(t, runtime.GOMAXPROCS(0), limits.GOMAXPROCS)
+	assert.Equal(t, SourceEnv, limits.MemoryLimitSource)
+	assert.Equal(t, int64(1<<30), limits.MemoryLimit, "Expected the limit set by the environment to be kept")
+}
+
+func TestApply_Disabled(t *testing.T) {
+	unsetenv(t, "GOMAXPROCS")
+	unsetenv(t, "GOMEMLIMIT")
+	restoreMemoryLimit(t)
+	debug.SetMemoryLimit(math.MaxInt64)
+	procs := runtime.GOMAXPROCS(0)
+
+	limits, err := apply(Config{}, log.NewNopLogger(), memlimit.Limit(1<<30))
+	require.NoError(t, err)
+
+	assert.Equal(t, Limits{GOMAXPROCS: procs, GOMAXPROCSSource: SourceRuntime, MemoryLimitSource: SourceRuntime}, limits)
+}
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector(Limits{GOMAXPROCS: 2, GOMAXPROCSSource: SourceCgroup, MemoryLimit: 512 << 20, MemoryLimitSource: SourceEnv})
+
+	expected := `
+# HELP adbeacon_runtime_gomaxprocs GOMAXPROCS of the Go runtime
+# TYPE adbeacon_runtime_gomaxprocs gauge
+adbeacon_runtime_gomaxprocs{source="cgroup"} 2
+# HELP adbeacon_runtime_memory_limit_bytes Soft memory limit (GOMEMLIMIT) of the Go runtime in bytes, 0 when unlimited
+# TYPE adbeacon_runtime_memory_limit_bytes gauge
+adbeacon_runtime_memory_limit_bytes{source="env"} 5.36870912e+08
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
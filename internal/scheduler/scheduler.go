@@ -0,0 +1,202 @@
+// Package scheduler flips the status of campaigns at their start and end times and when their
+// delivery budget is spent, so campaign timing doesn't depend on an external cron calling the
+// admin API
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// Reasons of status changes, logged and returned by Tick
+const (
+	ReasonNotStarted  = "not_started"
+	ReasonStarted     = "started"
+	ReasonEnded       = "ended"
+	ReasonBudgetSpent = "budget_spent"
+)
+
+// DeliveryCounter counts the deliveries of campaigns since they were created
+type DeliveryCounter interface {
+	// CampaignDeliveries returns the deliveries of each campaign since since, campaigns without
+	// deliveries may be left out
+	CampaignDeliveries(ctx context.Context, since time.Time) (map[string]int64, error)
+}
+
+// Lease elects the replica running a tick, Acquire reports whether this replica holds it until
+// the next tick
+type Lease interface {
+	Acquire(ctx context.Context) (bool, error)
+}
+
+// Config holds the optional dependencies of a Scheduler
+type Config struct {
+	// Deliveries counts the deliveries budgets are checked against, budgets aren't enforced
+	// when nil
+	Deliveries DeliveryCounter
+	// Lease keeps replicas from running the same tick, every replica runs it when nil
+	Lease Lease
+}
+
+// Change is a status change made by the scheduler
+type Change struct {
+	CampaignID string
+	Status     models.CampaignStatus
+	Reason     string
+}
+
+// Scheduler pauses campaigns before their start time, after their end time and once their
+// delivery budget is spent, and activates them at their start time. A campaign is activated
+// once: when its status was changed after the start time, e.g. paused from the admin API, the
+// scheduler leaves it alone. Changes are made through the campaign store, then invalidate is
+// called to drop the cached campaigns.
+type Scheduler struct {
+	config     Config
+	store      service.CampaignStore
+	invalidate func(ctx context.Context) error
+	logger     log.Logger
+	now        func() time.Time
+}
+
+// NewScheduler creates a scheduler changing campaigns in store
+func NewScheduler(config Config, store service.CampaignStore, invalidate func(ctx context.Context) error, logger log.Logger) *Scheduler {
+	return &Scheduler{
+		config:     config,
+		store:      store,
+		invalidate: invalidate,
+		logger:     logger,
+		now:        time.Now,
+	}
+}
+
+// Tick changes the status of the campaigns due for it and returns the changes made. Nothing
+// changes when another replica holds the lease.
+func (s *Scheduler) Tick(ctx context.Context) ([]Change, error) {
+	if s.config.Lease != nil {
+		held, err := s.config.Lease.Acquire(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire scheduler lease: %w", err)
+		}
+		if !held {
+			return nil, nil
+		}
+	}
+
+	campaigns, err := s.store.ListCampaigns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+	delivered, err := s.deliveries(ctx, campaigns)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	var changes []Change
+	for _, campaign := range campaigns {
+		if status, reason := nextStatus(campaign.Campaign, delivered[campaign.ID], now); status != campaign.Status {
+			changes = append(changes, Change{CampaignID: campaign.ID, Status: status, Reason: reason})
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	// One store call per status, each changes all its campaigns or none
+	for _, status := range []models.CampaignStatus{models.StatusInactive, models.StatusActive} {
+		var ids []string
+		for _, change := range changes {
+			if change.Status == status {
+				ids = append(ids, change.CampaignID)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		if err := s.store.SetCampaignStatuses(ctx, ids, status); err != nil {
+			return nil, fmt.Errorf("failed to set campaigns %s: %w", status, err)
+		}
+	}
+	for _, change := range changes {
+		level.Info(s.logger).Log("msg", "campaign status scheduled", "cid", change.CampaignID, "status", change.Status, "reason", change.Reason)
+	}
+
+	if err := s.invalidate(ctx); err != nil {
+		return changes, fmt.Errorf("failed to invalidate campaigns: %w", err)
+	}
+	return changes, nil
+}
+
+// deliveries returns the deliveries of the campaigns with a budget, nil when there are none or
+// budgets aren't enforced
+func (s *Scheduler) deliveries(ctx context.Context, campaigns []models.CampaignWithRules) (map[string]int64, error) {
+	if s.config.Deliveries == nil {
+		return nil, nil
+	}
+
+	var since time.Time
+	for _, campaign := range campaigns {
+		if campaign.DeliveryBudget > 0 && (since.IsZero() || campaign.CreatedAt.Before(since)) {
+			since = campaign.CreatedAt
+		}
+	}
+	if since.IsZero() {
+		return nil, nil
+	}
+
+	delivered, err := s.config.Deliveries.CampaignDeliveries(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count campaign deliveries: %w", err)
+	}
+	return delivered, nil
+}
+
+// nextStatus returns the status the campaign should have at now with delivered deliveries and
+// the reason for it, the current status when the scheduler leaves the campaign alone
+func nextStatus(campaign models.Campaign, delivered int64, now time.Time) (models.CampaignStatus, string) {
+	ended := campaign.EndsAt != nil && !now.Before(*campaign.EndsAt)
+	spent := campaign.DeliveryBudget > 0 && delivered >= campaign.DeliveryBudget
+	notStarted := campaign.StartsAt != nil && now.Before(*campaign.StartsAt)
+
+	if campaign.IsActive() {
+		switch {
+		case notStarted:
+			return models.StatusInactive, ReasonNotStarted
+		case ended:
+			return models.StatusInactive, ReasonEnded
+		case spent:
+			return models.StatusInactive, ReasonBudgetSpent
+		}
+		return campaign.Status, ""
+	}
+
+	// Activate at the start time, unless the status changed since
+	if campaign.StartsAt != nil && !notStarted && !ended && !spent && campaign.UpdatedAt.Before(*campaign.StartsAt) {
+		return models.StatusActive, ReasonStarted
+	}
+	return campaign.Status, ""
+}
+
+// Run ticks every interval until ctx is done, failed ticks are passed to onError
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Tick(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deliveryCounts is a DeliveryCounter returning fixed counts
+type deliveryCounts map[string]int64
+
+func (d deliveryCounts) CampaignDeliveries(ctx context.Context, since time.Time) (map[string]int64, error) {
+	return d, nil
+}
+
+// leaseFunc adapts a function to a Lease
+type leaseFunc func() (bool, error)
+
+func (f leaseFunc) Acquire(ctx context.Context) (bool, error) {
+	return f()
+}
+
+// newTestScheduler creates a scheduler on a store holding the sample campaigns plus campaigns,
+// counting its invalidations
+func newTestScheduler(t *testing.T, config Config, campaigns ...models.Campaign) (*Scheduler, service.CampaignStore, *int) {
+	t.Helper()
+
+	store := repository.NewMockRepository().(service.CampaignStore)
+	for _, campaign := range campaigns {
+		require.NoError(t, store.CreateCampaign(context.Background(), models.CampaignWithRules{Campaign: campaign}))
+	}
+	invalidations := 0
+	invalidate := func(ctx context.Context) error {
+		invalidations++
+		return nil
+	}
+	return NewScheduler(config, store, invalidate, log.NewNopLogger()), store, &invalidations
+}
+
+func statuses(t *testing.T, store service.CampaignStore) map[string]models.CampaignStatus {
+	t.Helper()
+
+	campaigns, err := store.ListCampaigns(context.Background())
+	require.NoError(t, err)
+	statuses := make(map[string]models.CampaignStatus, len(campaigns))
+	for _, campaign := range campaigns {
+		statuses[campaign.ID] = campaign.Status
+	}
+	return statuses
+}
+
+func at(t time.Time) *time.Time {
+	return &t
+}
+
+func TestScheduler_StartAndEnd(t *testing.T) {
+	created := time.Now()
+	scheduler, store, invalidations := newTestScheduler(t, Config{},
+		models.Campaign{ID: "launch", Status: models.StatusActive, StartsAt: at(created.Add(time.Hour)), EndsAt: at(created.Add(3 * time.Hour))},
+		models.Campaign{ID: "scheduled", Status: models.StatusInactive, StartsAt: at(created.Add(2 * time.Hour))},
+	)
+	ctx := context.Background()
+
+	// Campaigns created active before their start time are paused until it
+	changes, err := scheduler.Tick(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{CampaignID: "launch", Status: models.StatusInactive, Reason: ReasonNotStarted}}, changes)
+	assert.Equal(t, 1, *invalidations)
+
+	// Nothing is due, nothing is invalidated
+	changes, err = scheduler.Tick(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, 1, *invalidations)
+
+	scheduler.now = func() time.Time { return created.Add(2 * time.Hour) }
+	changes, err = scheduler.Tick(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Change{
+		{CampaignID: "launch", Status: models.StatusActive, Reason: ReasonStarted},
+		{CampaignID: "scheduled", Status: models.StatusActive, Reason: ReasonStarted},
+	}, changes)
+	assert.Equal(t, 2, *invalidations)
+
+	scheduler.now = func() time.Time { return created.Add(3 * time.Hour) }
+	changes, err = scheduler.Tick(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{CampaignID: "launch", Status: models.StatusInactive, Reason: ReasonEnded}}, changes)
+
+	current := statuses(t, store)
+	assert.Equal(t, models.StatusInactive, current["launch"])
+	assert.Equal(t, models.StatusActive, current["scheduled"])
+	// Campaigns without a schedule are left alone
+	assert.Equal(t, models.StatusActive, current["spotify"])
+}
+
+func TestScheduler_ManualChangesAfterStart(t *testing.T) {
+	created := time.Now()
+	scheduler, store, _ := newTestScheduler(t, Config{},
+		models.Campaign{ID: "started", Status: models.StatusInactive, StartsAt: at(created.Add(-time.Hour))},
+	)
+	ctx := context.Background()
+
+	// Created paused after its start time, the scheduler doesn't activate it
+	changes, err := scheduler.Tick(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// Paused through the admin API after being started, it stays paused
+	scheduler.now = func() time.Time { return created.Add(time.Hour) }
+	require.NoError(t, store.SetCampaignStatus(ctx, "started", models.StatusInactive))
+	changes, err = scheduler.Tick(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestScheduler_Budgets(t *testing.T) {
+	deliveries := deliveryCounts{"capped": 100, "spotify": 1000}
+	scheduler, store, _ := newTestScheduler(t, Config{Deliveries: deliveries},
+		models.Campaign{ID: "capped", Status: models.StatusActive, DeliveryBudget: 100},
+		models.Campaign{ID: "room", Status: models.StatusActive, DeliveryBudget: 100},
+	)
+
+	changes, err := scheduler.Tick(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{CampaignID: "capped", Status: models.StatusInactive, Reason: ReasonBudgetSpent}}, changes)
+	assert.Equal(t, models.StatusActive, statuses(t, store)["room"])
+
+	// Budgets aren't enforced without a delivery counter
+	scheduler, _, _ = newTestScheduler(t, Config{},
+		models.Campaign{ID: "capped", Status: models.StatusActive, DeliveryBudget: 1},
+	)
+	changes, err = scheduler.Tick(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestScheduler_Lease(t *testing.T) {
+	held := false
+	var leaseErr error
+	scheduler, store, invalidations := newTestScheduler(t, Config{Lease: leaseFunc(func() (bool, error) { return held, leaseErr })},
+		models.Campaign{ID: "ended", Status: models.StatusActive, EndsAt: at(time.Now().Add(-time.Hour))},
+	)
+	ctx := context.Background()
+
+	// Another replica holds the lease
+	changes, err := scheduler.Tick(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, models.StatusActive, statuses(t, store)["ended"])
+
+	leaseErr = errors.New("unavailable")
+	_, err = scheduler.Tick(ctx)
+	assert.ErrorContains(t, err, "failed to acquire scheduler lease")
+
+	held, leaseErr = true, nil
+	changes, err = scheduler.Tick(ctx)
+	require.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, 1, *invalidations)
+}
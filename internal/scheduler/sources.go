@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reporting"
+)
+
+// reportDeliveries counts deliveries in the hourly rows of a reporting store
+type reportDeliveries struct {
+	store reporting.Store
+	now   func() time.Time
+}
+
+// NewReportDeliveries creates a counter summing the delivery stats of store. The stats are
+// flushed by each replica's aggregator, deliveries not flushed yet are not counted.
+func NewReportDeliveries(store reporting.Store) DeliveryCounter {
+	return &reportDeliveries{store: store, now: time.Now}
+}
+
+// CampaignDeliveries implements DeliveryCounter, counting whole hours from the one since falls in
+func (r *reportDeliveries) CampaignDeliveries(ctx context.Context, since time.Time) (map[string]int64, error) {
+	rows, err := r.store.QueryDeliveryStats(ctx, reporting.Query{
+		From:    since.Truncate(time.Hour),
+		To:      r.now().Truncate(time.Hour).Add(time.Hour),
+		GroupBy: []string{reporting.GroupByCampaign},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delivered := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		delivered[row.CampaignID] += row.Deliveries
+	}
+	return delivered, nil
+}
+
+// redisLease is a Lease held by the replica that set its key, until the key expires
+type redisLease struct {
+	client redis.Cmdable
+	key    string
+	owner  string
+	ttl    time.Duration
+}
+
+// NewRedisLease creates a lease on key, held for ttl after each acquisition. Make ttl longer
+// than the tick interval so the holder keeps the lease while it's running.
+func NewRedisLease(client redis.Cmdable, key string, ttl time.Duration) Lease {
+	owner := make([]byte, 8)
+	rand.Read(owner)
+	return &redisLease{client: client, key: key, owner: hex.EncodeToString(owner), ttl: ttl}
+}
+
+// Acquire implements Lease, taking the lease when it's free and extending it when held
+func (l *redisLease) Acquire(ctx context.Context) (bool, error) {
+	acquired, err := l.client.SetNX(ctx, l.key, l.owner, l.ttl).Result()
+	if err != nil || acquired {
+		return acquired, err
+	}
+
+	holder, err := l.client.Get(ctx, l.key).Result()
+	switch {
+	case err == redis.Nil:
+		// Expired in between, the next tick tries again
+		return false, nil
+	case err != nil:
+		return false, err
+	case holder != l.owner:
+		return false, nil
+	}
+	return true, l.client.PExpire(ctx, l.key, l.ttl).Err()
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reporting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportDeliveries(t *testing.T) {
+	store := reporting.NewMemoryStore()
+	now := time.Date(2025, 1, 2, 12, 30, 0, 0, time.UTC)
+	ctx := context.Background()
+	require.NoError(t, store.AddDeliveryStats(ctx, []reporting.Row{
+		{Hour: now.Add(-48 * time.Hour).Truncate(time.Hour), CampaignID: "spotify", Country: "us", Deliveries: 1000},
+		{Hour: now.Add(-2 * time.Hour).Truncate(time.Hour), CampaignID: "spotify", Country: "us", Deliveries: 30},
+		{Hour: now.Truncate(time.Hour), CampaignID: "spotify", Country: "ca", Deliveries: 12},
+		{Hour: now.Truncate(time.Hour), CampaignID: "duolingo", Country: "in", Deliveries: 5},
+	}))
+
+	counter := &reportDeliveries{store: store, now: func() time.Time { return now }}
+	delivered, err := counter.CampaignDeliveries(ctx, now.Add(-3*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"spotify": 42, "duolingo": 5}, delivered)
+}
+
+func TestRedisLease(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	defer client.Close()
+	ctx := context.Background()
+
+	first := NewRedisLease(client, "lease", time.Minute)
+	second := NewRedisLease(client, "lease", time.Minute)
+
+	held, err := first.Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, held)
+	held, err = second.Acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, held)
+
+	// The holder extends the lease on each acquisition
+	server.FastForward(50 * time.Second)
+	held, err = first.Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, held)
+	assert.Equal(t, time.Minute, server.TTL("lease"))
+
+	// Once it expires, another replica takes over
+	server.FastForward(time.Minute)
+	held, err = second.Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, held)
+	held, err = first.Acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, held)
+
+	server.SetError("unavailable")
+	_, err = first.Acquire(ctx)
+	assert.Error(t, err)
+}
@@ -0,0 +1,14 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned when a provider has no secret with the requested name
+var ErrNotFound = errors.New("secret not found")
+
+// Provider resolves secrets by name from an external secret store
+type Provider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
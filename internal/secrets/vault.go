@@ -0,0 +1,101 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultConfig holds configuration for the Vault provider
+type VaultConfig struct {
+	// Addr is the Vault server address, e.g. https://vault.internal:8200
+	Addr  string
+	Token string
+	// Mount is the KV version 2 secrets engine mount, e.g. secret
+	Mount string
+	// Path is the secret within the mount whose fields are the secrets, e.g. adbeacon
+	Path    string
+	Timeout time.Duration
+}
+
+// vaultProvider reads secrets from the fields of a single Vault KV version 2 secret
+type vaultProvider struct {
+	url    string
+	token  string
+	client *http.Client
+
+	mu     sync.Mutex
+	fields map[string]string
+}
+
+// NewVaultProvider creates a provider reading the fields of the KV version 2 secret at config.Path
+func NewVaultProvider(config VaultConfig) (Provider, error) {
+	if config.Addr == "" || config.Token == "" {
+		return nil, fmt.Errorf("vault address and token are required")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	return &vaultProvider{
+		url:    fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(config.Addr, "/"), strings.Trim(config.Mount, "/"), strings.Trim(config.Path, "/")),
+		token:  config.Token,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// GetSecret returns the field name of the secret, it is read from Vault on first use
+func (p *vaultProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.fields == nil {
+		fields, err := p.read(ctx)
+		if err != nil {
+			return "", err
+		}
+		p.fields = fields
+	}
+
+	value, ok := p.fields[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// read fetches the latest version of the secret
+func (p *vaultProvider) read(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read vault secret: status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode vault secret: %w", err)
+	}
+	if body.Data.Data == nil {
+		return map[string]string{}, nil
+	}
+	return body.Data.Data, nil
+}
@@ -0,0 +1,45 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultProvider(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/v1/secret/data/adbeacon", r.URL.Path)
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"db_password":"s3cret"},"metadata":{"version":2}}}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewVaultProvider(VaultConfig{Addr: server.URL + "/", Token: "root", Mount: "secret", Path: "adbeacon"})
+	require.NoError(t, err)
+
+	value, err := provider.GetSecret(context.Background(), "db_password")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", value)
+
+	// The secret is read once
+	_, err = provider.GetSecret(context.Background(), "redis_password")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, requests)
+
+	t.Run("forbidden", func(t *testing.T) {
+		provider, err := NewVaultProvider(VaultConfig{Addr: server.URL, Token: "wrong", Mount: "secret", Path: "adbeacon"})
+		require.NoError(t, err)
+
+		_, err = provider.GetSecret(context.Background(), "db_password")
+		assert.ErrorContains(t, err, "status 403")
+	})
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// ErrLimitExceeded is matched by every *LimitError
+var ErrLimitExceeded = errors.New("campaign limit exceeded")
+
+// Limits of CampaignLimits, used as LimitError.Limit and metric label
+const (
+	LimitActiveCampaigns  = "active_campaigns"
+	LimitRulesPerCampaign = "rules_per_campaign"
+	LimitValuesPerRule    = "values_per_rule"
+)
+
+// CampaignLimits bound the campaign data deliveries match against, protecting delivery latency
+// from pathological campaigns. A limit of 0 disables it.
+type CampaignLimits struct {
+	// MaxActiveCampaigns is the number of active campaigns served
+	MaxActiveCampaigns int
+	// MaxRulesPerCampaign is the number of targeting rules of a campaign
+	MaxRulesPerCampaign int
+	// MaxValuesPerRule is the number of values of a targeting rule
+	MaxValuesPerRule int
+}
+
+// LimitError reports campaign data exceeding one of the CampaignLimits
+type LimitError struct {
+	// Limit is LimitActiveCampaigns, LimitRulesPerCampaign or LimitValuesPerRule
+	Limit string
+	// CampaignID and Rule, its index, name the campaign and rule exceeding the limit
+	CampaignID string
+	Rule       int
+	// Count is the number of campaigns, rules or values, exceeding Max
+	Count int
+	Max   int
+}
+
+// Error describes the limit exceeded, e.g. "campaign spotify has 60 rules, more than the limit
+// of 50 per campaign"
+func (e *LimitError) Error() string {
+	switch e.Limit {
+	case LimitRulesPerCampaign:
+		return fmt.Sprintf("campaign %s has %d rules, more than the limit of %d per campaign", e.CampaignID, e.Count, e.Max)
+	case LimitValuesPerRule:
+		return fmt.Sprintf("rule %d of campaign %s has %d values, more than the limit of %d per rule", e.Rule, e.CampaignID, e.Count, e.Max)
+	default:
+		return fmt.Sprintf("%d active campaigns, more than the limit of %d", e.Count, e.Max)
+	}
+}
+
+// Is matches ErrLimitExceeded
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// CheckRules returns a *LimitError when the campaign has too many rules or a rule too many values
+func (l CampaignLimits) CheckRules(campaign models.CampaignWithRules) error {
+	if l.MaxRulesPerCampaign > 0 && len(campaign.Rules) > l.MaxRulesPerCampaign {
+		return &LimitError{Limit: LimitRulesPerCampaign, CampaignID: campaign.ID, Count: len(campaign.Rules), Max: l.MaxRulesPerCampaign}
+	}
+	if l.MaxValuesPerRule > 0 {
+		for i, rule := range campaign.Rules {
+			if len(rule.Values) > l.MaxValuesPerRule {
+				return &LimitError{Limit: LimitValuesPerRule, CampaignID: campaign.ID, Rule: i, Count: len(rule.Values), Max: l.MaxValuesPerRule}
+			}
+		}
+	}
+	return nil
+}
+
+// checkActive returns a *LimitError when count active campaigns are too many
+func (l CampaignLimits) checkActive(count int) error {
+	if l.MaxActiveCampaigns > 0 && count > l.MaxActiveCampaigns {
+		return &LimitError{Limit: LimitActiveCampaigns, Count: count, Max: l.MaxActiveCampaigns}
+	}
+	return nil
+}
+
+// limitedStore refuses campaign changes exceeding its limits
+type limitedStore struct {
+	CampaignStore
+	limits     CampaignLimits
+	onExceeded func(err *LimitError)
+}
+
+// NewLimitedStore wraps store to refuse creating campaigns, changing rules and activating
+// campaigns beyond limits with a *LimitError, calling onExceeded with it when not nil. The number
+// of active campaigns is counted before each change, so concurrent changes may exceed it by a few.
+func NewLimitedStore(store CampaignStore, limits CampaignLimits, onExceeded func(err *LimitError)) CampaignStore {
+	return &limitedStore{CampaignStore: store, limits: limits, onExceeded: onExceeded}
+}
+
+// CreateCampaign implements CampaignStore within the limits
+func (s *limitedStore) CreateCampaign(ctx context.Context, campaign models.CampaignWithRules) error {
+	if err := s.limits.CheckRules(campaign); err != nil {
+		return s.exceeded(err)
+	}
+	if campaign.Status == models.StatusActive {
+		if err := s.checkActivating(ctx, nil); err != nil {
+			return err
+		}
+	}
+	return s.CampaignStore.CreateCampaign(ctx, campaign)
+}
+
+// SetCampaignStatus implements CampaignStore within the limits
+func (s *limitedStore) SetCampaignStatus(ctx context.Context, id string, status models.CampaignStatus) error {
+	if status == models.StatusActive {
+		if err := s.checkActivating(ctx, []string{id}); err != nil {
+			return err
+		}
+	}
+	return s.CampaignStore.SetCampaignStatus(ctx, id, status)
+}
+
+// SetCampaignStatuses implements CampaignStore within the limits
+func (s *limitedStore) SetCampaignStatuses(ctx context.Context, ids []string, status models.CampaignStatus) error {
+	if status == models.StatusActive {
+		if err := s.checkActivating(ctx, ids); err != nil {
+			return err
+		}
+	}
+	return s.CampaignStore.SetCampaignStatuses(ctx, ids, status)
+}
+
+// SetCampaignRules implements CampaignStore within the limits
+func (s *limitedStore) SetCampaignRules(ctx context.Context, version models.RuleSetVersion) (models.RuleSetVersion, error) {
+	if err := s.limits.CheckRules(models.CampaignWithRules{Campaign: models.Campaign{ID: version.CampaignID}, Rules: version.Rules}); err != nil {
+		return models.RuleSetVersion{}, s.exceeded(err)
+	}
+	return s.CampaignStore.SetCampaignRules(ctx, version)
+}
+
+// checkActivating checks the active campaigns stay within the limit once the campaigns of ids are
+// activated, nil ids for a new campaign
+func (s *limitedStore) checkActivating(ctx context.Context, ids []string) error {
+	if s.limits.MaxActiveCampaigns <= 0 {
+		return nil
+	}
+	campaigns, err := s.CampaignStore.ListCampaigns(ctx)
+	if err != nil {
+		return err
+	}
+
+	activating := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		activating[id] = true
+	}
+	active, added := 0, 0
+	if ids == nil {
+		added = 1
+	}
+	for _, campaign := range campaigns {
+		switch {
+		case campaign.Status == models.StatusActive:
+			active++
+		case activating[campaign.ID]:
+			added++
+		}
+	}
+	// Campaigns already active, or missing, don't count against the limit
+	if added == 0 {
+		return nil
+	}
+	if err := s.limits.checkActive(active + added); err != nil {
+		return s.exceeded(err)
+	}
+	return nil
+}
+
+// exceeded reports the *LimitError err to onExceeded and returns it
+func (s *limitedStore) exceeded(err error) error {
+	var limitErr *LimitError
+	if s.onExceeded != nil && errors.As(err, &limitErr) {
+		s.onExceeded(limitErr)
+	}
+	return err
+}
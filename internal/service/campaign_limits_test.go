@@ -0,0 +1,28 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaignLimits_CheckRules(t *testing.T) {
+	rule := func(values ...string) models.TargetingRule {
+		return models.TargetingRule{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: values}
+	}
+	campaign := models.CampaignWithRules{Campaign: models.Campaign{ID: "spotify"}, Rules: []models.TargetingRule{rule("US"), rule("CA", "MX", "BR")}}
+
+	assert.NoError(t, CampaignLimits{}.CheckRules(campaign))
+	assert.NoError(t, CampaignLimits{MaxRulesPerCampaign: 2, MaxValuesPerRule: 3}.CheckRules(campaign))
+
+	err := CampaignLimits{MaxRulesPerCampaign: 1}.CheckRules(campaign)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.EqualError(t, err, "campaign spotify has 2 rules, more than the limit of 1 per campaign")
+
+	err = CampaignLimits{MaxValuesPerRule: 2}.CheckRules(campaign)
+	var limitErr *LimitError
+	assert.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, LimitError{Limit: LimitValuesPerRule, CampaignID: "spotify", Rule: 1, Count: 3, Max: 2}, *limitErr)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// Errors returned by CampaignStore implementations
+var (
+	ErrCampaignNotFound = errors.New("campaign not found")
+	ErrCampaignExists   = errors.New("campaign already exists")
+)
+
+// CampaignStore manages campaigns for the admin API, unlike CampaignRepository it
+// also sees inactive campaigns
+type CampaignStore interface {
+	// ListCampaigns returns all campaigns with their targeting rules, regardless of status
+	ListCampaigns(ctx context.Context) ([]models.CampaignWithRules, error)
+	// CreateCampaign stores a new campaign with its rules, returning ErrCampaignExists if the ID is taken
+	CreateCampaign(ctx context.Context, campaign models.CampaignWithRules) error
+	// SetCampaignStatus changes the status of a campaign, returning ErrCampaignNotFound if it does not exist
+	SetCampaignStatus(ctx context.Context, id string, status models.CampaignStatus) error
+	// SetCampaignStatuses changes the status of several campaigns at once, either all of them or
+	// none when one does not exist, returning ErrCampaignNotFound naming the missing ones
+	SetCampaignStatuses(ctx context.Context, ids []string, status models.CampaignStatus) error
+	// SetCampaignTrafficPct changes the percentage of its matching traffic a campaign is served
+	// to, returning ErrCampaignNotFound if it does not exist
+	SetCampaignTrafficPct(ctx context.Context, id string, trafficPct int) error
+	// SetCampaignCategory changes the competitive category of a campaign, empty for none,
+	// returning ErrCampaignNotFound if it does not exist
+	SetCampaignCategory(ctx context.Context, id, category string) error
+	// SetCampaignImage changes the image URL of a campaign, empty for none, returning
+	// ErrCampaignNotFound if it does not exist
+	SetCampaignImage(ctx context.Context, id, imageURL string) error
+	// SetCampaignCreatives replaces the localized creatives of a campaign, none to serve its image
+	// and CTA to every request, returning ErrCampaignNotFound if it does not exist
+	SetCampaignCreatives(ctx context.Context, id string, creatives []models.Creative) error
+	// SetCampaignRules replaces the targeting rules of a campaign with those of version and
+	// records them as its next version, returning the version stored with its number and time
+	// set, or ErrCampaignNotFound if the campaign does not exist
+	SetCampaignRules(ctx context.Context, version models.RuleSetVersion) (models.RuleSetVersion, error)
+	// ListRuleVersions returns the rule set versions of a campaign newest first, the first one
+	// holding its current rules, or ErrCampaignNotFound if it does not exist
+	ListRuleVersions(ctx context.Context, id string) ([]models.RuleSetVersion, error)
+}
+
+// CampaignFilter selects campaigns for bulk operations, empty fields match every campaign
+type CampaignFilter struct {
+	// IDPrefix matches campaign IDs starting with it
+	IDPrefix string `json:"id_prefix,omitempty"`
+	// Name matches campaign names containing it, ignoring case
+	Name string `json:"name,omitempty"`
+	// Status matches campaigns with this status
+	Status models.CampaignStatus `json:"status,omitempty"`
+}
+
+// IsEmpty reports whether the filter matches every campaign
+func (f CampaignFilter) IsEmpty() bool {
+	return f == CampaignFilter{}
+}
+
+// Matches reports whether the campaign passes every field of the filter
+func (f CampaignFilter) Matches(campaign models.Campaign) bool {
+	return strings.HasPrefix(campaign.ID, f.IDPrefix) &&
+		strings.Contains(strings.ToLower(campaign.Name), strings.ToLower(f.Name)) &&
+		(f.Status == "" || campaign.Status == f.Status)
+}
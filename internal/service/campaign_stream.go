@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// CampaignStreamer is implemented by repositories that can load the active campaigns in batches,
+// so a large result set doesn't have to be scanned into memory all at once
+type CampaignStreamer interface {
+	// StreamActiveCampaignsWithRules calls fn with the active campaigns and their targeting rules
+	// in batches of at most batchSize, in the order of GetActiveCampaignsWithRules and all from
+	// the same snapshot. fn may keep the batches. An error of fn stops the stream and is
+	// returned as is. A batchSize of 0 or less loads the campaigns in a single batch.
+	StreamActiveCampaignsWithRules(ctx context.Context, batchSize int, fn func(batch []models.CampaignWithRules) error) error
+}
+
+// StreamActiveCampaigns streams the active campaigns of repo, see CampaignStreamer. Repositories
+// not implementing it load the campaigns at once and pass them to fn in one batch.
+func StreamActiveCampaigns(ctx context.Context, repo CampaignRepository, batchSize int, fn func(batch []models.CampaignWithRules) error) error {
+	if streamer, ok := repo.(CampaignStreamer); ok {
+		return streamer.StreamActiveCampaignsWithRules(ctx, batchSize, fn)
+	}
+
+	campaigns, err := repo.GetActiveCampaignsWithRules(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(campaigns)
+}
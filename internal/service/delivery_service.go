@@ -3,10 +3,22 @@ package service
 import (
 	"context"
 	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 )
 
+// ErrRetrieveCampaigns is returned when campaigns could not be loaded from the repository
+var ErrRetrieveCampaigns = errors.New("failed to retrieve campaigns")
+
+// dimensionBuffers pools the buffers collecting the dimensions of matched campaigns, a buffer
+// only lives for the request matching
+var dimensionBuffers = sync.Pool{New: func() any { return new([]string) }}
+
 // CampaignDeliveryService defines the interface for campaign delivery service
 type CampaignDeliveryService interface {
 	GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error)
@@ -23,10 +35,56 @@ type OptimizedCampaignRepository interface {
 	GetCampaignsByRequest(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignWithRules, error)
 }
 
+// CompiledCampaignRepository extends OptimizedCampaignRepository with campaigns compiled ahead of
+// matching, see models.CompiledCampaign
+type CompiledCampaignRepository interface {
+	OptimizedCampaignRepository
+	GetCompiledCampaignsByRequest(ctx context.Context, req models.DeliveryRequest) ([]*models.CompiledCampaign, error)
+}
+
+// Match sources reported to the MatchRecorder
+const (
+	MatchSourceIndex    = "index"
+	MatchSourceFullScan = "full_scan"
+)
+
+// MatchRecorder receives matching-stage measurements for each delivery request
+type MatchRecorder interface {
+	// RecordMatching records how many campaigns were evaluated and matched, and how long matching took
+	RecordMatching(source string, evaluated, matched int, duration time.Duration)
+	// RecordDimensionMatch records a matched campaign that targets the given dimension
+	RecordDimensionMatch(dimension string)
+}
+
+// Decision describes how a delivery request was answered, for offline targeting analysis
+type Decision struct {
+	Request models.DeliveryRequest
+	// Source is how candidates were loaded, MatchSourceIndex or MatchSourceFullScan
+	Source string
+	// Candidates is the number of campaigns evaluated against the request
+	Candidates  int
+	CampaignIDs []string
+	// LookupDuration is the time spent loading candidates, MatchDuration evaluating them
+	LookupDuration time.Duration
+	MatchDuration  time.Duration
+	Err            error
+}
+
+// DecisionRecorder receives the decision of each delivery request. RecordDecision is called
+// on the request path and must not block.
+type DecisionRecorder interface {
+	RecordDecision(ctx context.Context, decision Decision)
+}
+
 // DeliveryService handles ad delivery requests
 type DeliveryService struct {
 	repository CampaignRepository
 	matcher    *models.CampaignMatcher
+	recorder   MatchRecorder
+	decisions  DecisionRecorder
+	clock      models.Clock
+	memo       *MatchMemo
+	parallel   *ParallelMatcher
 }
 
 // NewDeliveryService creates a new delivery service
@@ -38,6 +96,7 @@ func NewDeliveryService(repo CampaignRepository) *DeliveryService {
 	return &DeliveryService{
 		repository: repo,
 		matcher:    matcher,
+		clock:      models.SystemClock{},
 	}
 }
 
@@ -46,48 +105,277 @@ func NewDeliveryServiceWithMatcher(repo CampaignRepository, matcher *models.Camp
 	return &DeliveryService{
 		repository: repo,
 		matcher:    matcher,
+		clock:      models.SystemClock{},
 	}
 }
 
-// GetCampaigns finds all campaigns that match the delivery request
+// GetCampaigns finds all campaigns that match the delivery request, the request is
+// expected to be validated already (see endpoint.ValidationMiddleware)
 func (s *DeliveryService) GetCampaigns(ctx context.Context, req models.DeliveryRequest) ([]models.CampaignResponse, error) {
-	// Validate request
-	if err := req.Validate(); err != nil {
-		return nil, err
-	}
-
 	// Normalize values for consistent comparison
 	req.NormalizeValues()
 
+	// Resolve the request time once, so every campaign is matched at the same instant
+	if req.Time.IsZero() {
+		req.Time = s.now(ctx)
+	}
+
+	// Bursts of identical requests are answered from the memo
+	var key string
+	if s.memo != nil {
+		key = memoKey(req)
+		if entry, ok := s.memo.get(key); ok {
+			return s.memoized(ctx, req, entry), nil
+		}
+	}
+
 	// Try optimized lookup first if repository supports it
 	var campaignsWithRules []models.CampaignWithRules
+	var compiledCampaigns []*models.CompiledCampaign
 	var err error
+	source := MatchSourceFullScan
+	lookupStart := time.Now()
 
-	if optimizedRepo, ok := s.repository.(OptimizedCampaignRepository); ok {
+	if compiledRepo, ok := s.repository.(CompiledCampaignRepository); ok {
+		// Use fast index-based lookup of precompiled campaigns
+		source = MatchSourceIndex
+		compiledCampaigns, err = compiledRepo.GetCompiledCampaignsByRequest(ctx, req)
+	} else if optimizedRepo, ok := s.repository.(OptimizedCampaignRepository); ok {
 		// Use fast index-based lookup
+		source = MatchSourceIndex
 		campaignsWithRules, err = optimizedRepo.GetCampaignsByRequest(ctx, req)
-		if err != nil {
-			return nil, errors.New("failed to retrieve campaigns")
-		}
 	} else {
 		// Fallback to loading all campaigns
 		campaignsWithRules, err = s.repository.GetActiveCampaignsWithRules(ctx)
-		if err != nil {
-			return nil, errors.New("failed to retrieve campaigns")
-		}
+	}
+	lookupDuration := time.Since(lookupStart)
+	if err != nil {
+		s.recordDecision(ctx, Decision{Request: req, Source: source, LookupDuration: lookupDuration, Err: ErrRetrieveCampaigns})
+		return nil, ErrRetrieveCampaigns
 	}
 
 	// Filter campaigns that match the request using extensible matcher
+	matchStart := time.Now()
 	var matchingCampaigns []models.CampaignResponse
-	for _, campaign := range campaignsWithRules {
-		if s.matcher.MatchesRequest(campaign, req) {
-			matchingCampaigns = append(matchingCampaigns, campaign.ToResponse())
+	dimensionBuffer := dimensionBuffers.Get().(*[]string)
+	defer releaseDimensionBuffer(dimensionBuffer)
+
+	candidates := len(campaignsWithRules)
+	matches := func(i int) bool { return s.matcher.MatchesRequest(campaignsWithRules[i], req) }
+	if compiledCampaigns != nil {
+		candidates = len(compiledCampaigns)
+		matches = func(i int) bool { return s.matcher.MatchesCompiled(compiledCampaigns[i], req) }
+	}
+
+	// Large candidate sets are matched in parallel first, the matches are collected in order
+	if s.parallel.applies(candidates) {
+		parallelMatches := make([]bool, candidates)
+		if err := s.parallel.match(ctx, parallelMatches, matches); err != nil {
+			s.recordDecision(ctx, Decision{Request: req, Source: source, Candidates: candidates, LookupDuration: lookupDuration, Err: err})
+			return nil, err
+		}
+		matches = func(i int) bool { return parallelMatches[i] }
+	}
+
+	// The dimensions of each matched campaign are appended to the buffer, the serving constraints
+	// are only collected once a campaign has a staged rollout or a category
+	matchedDimensions := (*dimensionBuffer)[:0]
+	var constraints []servingConstraint
+	for i := 0; i < candidates; i++ {
+		if !matches(i) {
+			continue
+		}
+		start := len(matchedDimensions)
+		var campaign models.CampaignWithRules
+		if compiledCampaigns != nil {
+			campaign = compiledCampaigns[i].CampaignWithRules
+			matchedDimensions = append(matchedDimensions, compiledCampaigns[i].TargetedDimensions()...)
+		} else {
+			campaign = campaignsWithRules[i]
+			matchedDimensions = appendTargetedDimensions(matchedDimensions, campaign)
+		}
+		matchingCampaigns = append(matchingCampaigns, campaign.ResponseFor(req.Lang))
+		constraint := servingConstraint{trafficPct: campaign.TrafficPct, category: strings.ToLower(campaign.Category)}
+		if constraint != (servingConstraint{}) && constraints == nil {
+			constraints = make([]servingConstraint, len(matchingCampaigns)-1, len(matchingCampaigns))
+		}
+		if constraints != nil {
+			constraints = append(constraints, constraint)
 		}
+		s.recordDimensionMatches(matchedDimensions[start:])
+	}
+	*dimensionBuffer = matchedDimensions
+
+	matchDuration := time.Since(matchStart)
+	if s.memo != nil {
+		s.memo.put(key, slices.Clone(matchingCampaigns), slices.Clone(matchedDimensions), constraints)
+	}
+	// The memo keeps the matches of every identity, the constraints are applied per request
+	matchingCampaigns = assemble(req, matchingCampaigns, constraints)
+
+	if s.recorder != nil {
+		s.recorder.RecordMatching(source, candidates, len(matchingCampaigns), matchDuration)
+	}
+	if s.decisions != nil {
+		campaignIDs := make([]string, len(matchingCampaigns))
+		for i, campaign := range matchingCampaigns {
+			campaignIDs[i] = campaign.CID
+		}
+		s.recordDecision(ctx, Decision{
+			Request:        req,
+			Source:         source,
+			Candidates:     candidates,
+			CampaignIDs:    campaignIDs,
+			LookupDuration: lookupDuration,
+			MatchDuration:  matchDuration,
+		})
+	}
+	if outcome := reqcontext.GetDeliveryOutcome(ctx); outcome != nil {
+		outcome.Matched = true
 	}
 
 	return matchingCampaigns, nil
 }
 
+// memoized answers a request from a remembered match, recording it like a matched one
+func (s *DeliveryService) memoized(ctx context.Context, req models.DeliveryRequest, entry *memoEntry) []models.CampaignResponse {
+	s.recordDimensionMatches(entry.dimensions)
+	// Callers own the returned slice, the entry may answer more requests
+	campaigns := assemble(req, slices.Clone(entry.campaigns), entry.constraints)
+	if s.decisions != nil {
+		campaignIDs := make([]string, len(campaigns))
+		for i, campaign := range campaigns {
+			campaignIDs[i] = campaign.CID
+		}
+		s.recordDecision(ctx, Decision{Request: req, Source: MatchSourceMemo, CampaignIDs: campaignIDs})
+	}
+	if outcome := reqcontext.GetDeliveryOutcome(ctx); outcome != nil {
+		outcome.Matched = true
+		outcome.Age = s.memo.age(entry)
+	}
+	return campaigns
+}
+
+// servingConstraint is what assembling the response needs to know of a matched campaign
+type servingConstraint struct {
+	// trafficPct is the percentage of its matching traffic the campaign is served to, 0 for all
+	trafficPct int
+	// category is the lowercased competitive category, empty for none
+	category string
+}
+
+// assemble composes the response of the matched campaigns in place: campaigns of requests with a
+// session ID are ordered by session, see sessionOrder, the serving constraints are applied and
+// requests for slots get their slots filled, see composePod. constraints holds the constraints
+// of each campaign, nil when none has any.
+func assemble(req models.DeliveryRequest, campaigns []models.CampaignResponse, constraints []servingConstraint) []models.CampaignResponse {
+	if req.SessionID != "" {
+		constraints = sessionOrder(req.SessionID, campaigns, constraints)
+	}
+	if constraints != nil {
+		campaigns = applyConstraints(req, campaigns, constraints)
+	}
+	if req.Slots > 0 {
+		campaigns = composePod(campaigns, req.Slots)
+	}
+	return campaigns
+}
+
+// applyConstraints drops the campaigns whose staged rollout leaves the request out, then those of
+// a competitive category already served by an earlier campaign, in place. Rollouts go first so a
+// campaign left out doesn't keep its competitors out too.
+func applyConstraints(req models.DeliveryRequest, campaigns []models.CampaignResponse, constraints []servingConstraint) []models.CampaignResponse {
+	var identity string
+	var categories []string
+	served := campaigns[:0]
+	for i, campaign := range campaigns {
+		constraint := constraints[i]
+		if constraint.trafficPct > 0 {
+			if identity == "" {
+				identity = req.RolloutIdentity()
+			}
+			if !models.InRollout(campaign.CID, constraint.trafficPct, identity) {
+				continue
+			}
+		}
+		if constraint.category != "" {
+			if slices.Contains(categories, constraint.category) {
+				continue
+			}
+			categories = append(categories, constraint.category)
+		}
+		served = append(served, campaign)
+	}
+	return served
+}
+
+// SetParallelMatcher sets the matcher of large candidate sets, nil matches every set sequentially
+func (s *DeliveryService) SetParallelMatcher(parallel *ParallelMatcher) {
+	s.parallel = parallel
+}
+
+// SetMatchMemo sets the memo answering repeated requests, nil disables memoization
+func (s *DeliveryService) SetMatchMemo(memo *MatchMemo) {
+	s.memo = memo
+}
+
+// SetDecisionRecorder sets the recorder that receives the decision of each request
+func (s *DeliveryService) SetDecisionRecorder(recorder DecisionRecorder) {
+	s.decisions = recorder
+}
+
+func (s *DeliveryService) recordDecision(ctx context.Context, decision Decision) {
+	if s.decisions != nil {
+		s.decisions.RecordDecision(ctx, decision)
+	}
+}
+
+// SetClock sets the clock of requests without a time, a clock in the request context takes precedence
+func (s *DeliveryService) SetClock(clock models.Clock) {
+	s.clock = clock
+}
+
+// now returns the time of a request without one
+func (s *DeliveryService) now(ctx context.Context) time.Time {
+	if clock := reqcontext.GetClock(ctx); clock != nil {
+		return clock.Now()
+	}
+	return s.clock.Now()
+}
+
+// SetMatchRecorder sets the recorder that receives matching-stage measurements
+func (s *DeliveryService) SetMatchRecorder(recorder MatchRecorder) {
+	s.recorder = recorder
+}
+
+// recordDimensionMatches records the dimensions targeted by matched campaigns
+func (s *DeliveryService) recordDimensionMatches(dimensions []string) {
+	if s.recorder == nil {
+		return
+	}
+	for _, dimension := range dimensions {
+		s.recorder.RecordDimensionMatch(dimension)
+	}
+}
+
+// appendTargetedDimensions appends each distinct dimension targeted by campaign to dst
+func appendTargetedDimensions(dst []string, campaign models.CampaignWithRules) []string {
+	start := len(dst)
+	for _, rule := range campaign.Rules {
+		if !slices.Contains(dst[start:], string(rule.Dimension)) {
+			dst = append(dst, string(rule.Dimension))
+		}
+	}
+	return dst
+}
+
+// releaseDimensionBuffer returns a dimension buffer to the pool, dropping its strings
+func releaseDimensionBuffer(buffer *[]string) {
+	clear(*buffer)
+	*buffer = (*buffer)[:0]
+	dimensionBuffers.Put(buffer)
+}
+
 // RegisterCustomDimension allows registering new dimension processors at runtime
 func (ds *DeliveryService) RegisterCustomDimension(processor models.DimensionProcessor) {
 	if ds.matcher != nil && ds.matcher.Registry != nil {
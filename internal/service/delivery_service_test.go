@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -28,55 +32,6 @@ func TestNewDeliveryService(t *testing.T) {
 	assert.IsType(t, &DeliveryService{}, service)
 }
 
-func TestDeliveryService_GetCampaigns_InvalidRequest(t *testing.T) {
-	mockRepo := &MockCampaignRepository{}
-	service := NewDeliveryService(mockRepo)
-
-	tests := []struct {
-		name    string
-		request models.DeliveryRequest
-		wantErr string
-	}{
-		{
-			name: "missing app",
-			request: models.DeliveryRequest{
-				Country: "US",
-				OS:      "Android",
-			},
-			wantErr: "app is required",
-		},
-		{
-			name: "missing country",
-			request: models.DeliveryRequest{
-				App: "com.test.app",
-				OS:  "Android",
-			},
-			wantErr: "country is required",
-		},
-		{
-			name: "missing os",
-			request: models.DeliveryRequest{
-				App:     "com.test.app",
-				Country: "US",
-			},
-			wantErr: "os is required",
-		},
-		{
-			name:    "all missing",
-			request: models.DeliveryRequest{},
-			wantErr: "country is required",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			_, err := service.GetCampaigns(context.Background(), tt.request)
-			assert.Error(t, err)
-			assert.Contains(t, err.Error(), tt.wantErr)
-		})
-	}
-}
-
 func TestDeliveryService_GetCampaigns_RepositoryError(t *testing.T) {
 	mockRepo := &MockCampaignRepository{}
 	service := NewDeliveryService(mockRepo)
@@ -252,6 +207,381 @@ func TestDeliveryService_GetCampaigns_RequestNormalization(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// recordingMatchRecorder captures matching-stage measurements
+type recordingMatchRecorder struct {
+	source     string
+	evaluated  int
+	matched    int
+	dimensions map[string]int
+}
+
+func (r *recordingMatchRecorder) RecordMatching(source string, evaluated, matched int, duration time.Duration) {
+	r.source = source
+	r.evaluated = evaluated
+	r.matched = matched
+}
+
+func (r *recordingMatchRecorder) RecordDimensionMatch(dimension string) {
+	r.dimensions[dimension]++
+}
+
+func TestDeliveryService_GetCampaigns_RecordsMatching(t *testing.T) {
+	mockRepo := &MockCampaignRepository{}
+	service := NewDeliveryService(mockRepo)
+	recorder := &recordingMatchRecorder{dimensions: make(map[string]int)}
+	service.SetMatchRecorder(recorder)
+
+	campaigns := []models.CampaignWithRules{
+		createTestCampaign("spotify", models.StatusActive, []models.TargetingRule{
+			{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"US"}},
+		}),
+		createTestCampaign("subwaysurfer", models.StatusActive, []models.TargetingRule{
+			{Dimension: models.DimensionOS, RuleType: models.RuleTypeInclude, Values: []string{"Android"}},
+			{Dimension: models.DimensionOS, RuleType: models.RuleTypeExclude, Values: []string{"iOS"}},
+			{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"US"}},
+		}),
+		createTestCampaign("duolingo", models.StatusActive, []models.TargetingRule{
+			{Dimension: models.DimensionCountry, RuleType: models.RuleTypeExclude, Values: []string{"US"}},
+		}),
+	}
+
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(campaigns, nil)
+
+	request := models.DeliveryRequest{
+		App:     "com.test.app",
+		Country: "US",
+		OS:      "Android",
+	}
+
+	result, err := service.GetCampaigns(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+
+	assert.Equal(t, MatchSourceFullScan, recorder.source)
+	assert.Equal(t, 3, recorder.evaluated)
+	assert.Equal(t, 2, recorder.matched)
+	// Each matched campaign counts a dimension once, even with multiple rules on it
+	assert.Equal(t, map[string]int{"country": 2, "os": 1}, recorder.dimensions)
+
+	mockRepo.AssertExpectations(t)
+}
+
+// recordingDecisionRecorder captures delivery decisions
+type recordingDecisionRecorder struct {
+	decisions []Decision
+}
+
+func (r *recordingDecisionRecorder) RecordDecision(ctx context.Context, decision Decision) {
+	r.decisions = append(r.decisions, decision)
+}
+
+func TestDeliveryService_GetCampaigns_RecordsDecision(t *testing.T) {
+	mockRepo := &MockCampaignRepository{}
+	service := NewDeliveryService(mockRepo)
+	recorder := &recordingDecisionRecorder{}
+	service.SetDecisionRecorder(recorder)
+
+	campaigns := []models.CampaignWithRules{
+		createTestCampaign("spotify", models.StatusActive, []models.TargetingRule{
+			{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"US"}},
+		}),
+		createTestCampaign("duolingo", models.StatusActive, []models.TargetingRule{
+			{Dimension: models.DimensionCountry, RuleType: models.RuleTypeExclude, Values: []string{"US"}},
+		}),
+	}
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(campaigns, nil).Once()
+
+	request := models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android"}
+	_, err := service.GetCampaigns(context.Background(), request)
+	assert.NoError(t, err)
+
+	if assert.Len(t, recorder.decisions, 1) {
+		decision := recorder.decisions[0]
+		assert.Equal(t, MatchSourceFullScan, decision.Source)
+		assert.Equal(t, 2, decision.Candidates)
+		assert.Equal(t, []string{"spotify"}, decision.CampaignIDs)
+		// The decision carries the normalized request at its resolved time
+		assert.Equal(t, "us", decision.Request.Country)
+		assert.False(t, decision.Request.Time.IsZero())
+		assert.NoError(t, decision.Err)
+	}
+
+	// Failed lookups are recorded too
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return([]models.CampaignWithRules{}, errors.New("database error")).Once()
+	_, err = service.GetCampaigns(context.Background(), request)
+	assert.Error(t, err)
+	if assert.Len(t, recorder.decisions, 2) {
+		assert.ErrorIs(t, recorder.decisions[1].Err, ErrRetrieveCampaigns)
+		assert.Zero(t, recorder.decisions[1].Candidates)
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeliveryService_GetCampaigns_RequestTime(t *testing.T) {
+	registry := models.NewDimensionRegistry()
+	registry.RegisterProcessor(models.NewTimeOfDayProcessor())
+	mockRepo := &MockCampaignRepository{}
+	service := NewDeliveryServiceWithMatcher(mockRepo, models.NewCampaignMatcher(registry))
+
+	campaigns := []models.CampaignWithRules{
+		createTestCampaign("night", models.StatusActive, []models.TargetingRule{
+			{Dimension: models.DimensionTimeOfDay, RuleType: models.RuleTypeInclude, Values: []string{"22-23", "0-2"}},
+		}),
+	}
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(campaigns, nil)
+
+	noon := models.FixedClock{Time: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	night := models.FixedClock{Time: time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC)}
+	request := models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android"}
+
+	tests := []struct {
+		name        string
+		clock       models.Clock
+		ctxClock    models.Clock
+		requestTime time.Time
+		shouldMatch bool
+	}{
+		{name: "service clock inside range", clock: night, shouldMatch: true},
+		{name: "service clock outside range", clock: noon, shouldMatch: false},
+		{name: "context clock overrides service clock", clock: noon, ctxClock: night, shouldMatch: true},
+		{
+			name:  "client time in its own time zone",
+			clock: noon,
+			// 19:30 UTC is 01:00 in India
+			requestTime: time.Date(2025, 1, 1, 1, 0, 0, 0, time.FixedZone("IST", 5*3600+1800)),
+			shouldMatch: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service.SetClock(tt.clock)
+			ctx := context.Background()
+			if tt.ctxClock != nil {
+				ctx = reqcontext.WithClock(ctx, tt.ctxClock)
+			}
+			req := request
+			req.Time = tt.requestTime
+
+			result, err := service.GetCampaigns(ctx, req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.shouldMatch, len(result) == 1)
+		})
+	}
+}
+
+func TestDeliveryService_GetCampaigns_StagedRollout(t *testing.T) {
+	mockRepo := &MockCampaignRepository{}
+	service := NewDeliveryService(mockRepo)
+	service.SetMatchMemo(NewMatchMemo(time.Minute, 100, nil))
+
+	canary := createTestCampaign("canary", models.StatusActive, nil)
+	canary.TrafficPct = 30
+	campaigns := []models.CampaignWithRules{createTestCampaign("full", models.StatusActive, nil), canary}
+	// The memo answers every user after the first request, the rollout is applied per user
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(campaigns, nil).Once()
+
+	ctx := reqcontext.WithClock(context.Background(), models.FixedClock{Time: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)})
+	served := func(userID string) []string {
+		result, err := service.GetCampaigns(ctx, models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android", UserID: userID})
+		assert.NoError(t, err)
+		var ids []string
+		for _, campaign := range result {
+			ids = append(ids, campaign.CID)
+		}
+		return ids
+	}
+
+	inCanary := 0
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		ids := served(userID)
+		assert.Contains(t, ids, "full")
+		if len(ids) == 2 {
+			inCanary++
+		}
+		// Users keep their answer
+		assert.Equal(t, ids, served(userID))
+	}
+	assert.InDelta(t, 300, inCanary, 60)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeliveryService_GetCampaigns_CompetitiveSeparation(t *testing.T) {
+	mockRepo := &MockCampaignRepository{}
+	service := NewDeliveryService(mockRepo)
+	service.SetMatchMemo(NewMatchMemo(time.Minute, 100, nil))
+
+	uber := createTestCampaign("uber", models.StatusActive, nil)
+	uber.Category = "ride_hailing"
+	lyft := createTestCampaign("lyft", models.StatusActive, nil)
+	lyft.Category = "Ride_Hailing"
+	canary := createTestCampaign("bolt", models.StatusActive, nil)
+	canary.Category = "ride_hailing"
+	canary.TrafficPct = 50
+	spotify := createTestCampaign("spotify", models.StatusActive, nil)
+	spotify.Category = "music"
+	campaigns := []models.CampaignWithRules{canary, uber, spotify, lyft, createTestCampaign("duolingo", models.StatusActive, nil)}
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(campaigns, nil).Once()
+
+	ctx := reqcontext.WithClock(context.Background(), models.FixedClock{Time: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)})
+	served := func(userID string) []string {
+		result, err := service.GetCampaigns(ctx, models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android", UserID: userID})
+		assert.NoError(t, err)
+		var ids []string
+		for _, campaign := range result {
+			ids = append(ids, campaign.CID)
+		}
+		return ids
+	}
+
+	// The first campaign of a category served wins, a campaign left out of its rollout doesn't
+	// keep its competitors out, also when answered from the memo
+	var inCanary, outOfCanary bool
+	for i := 0; i < 100 && !(inCanary && outOfCanary); i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		if models.InRollout("bolt", 50, "u:"+userID) {
+			inCanary = true
+			assert.Equal(t, []string{"bolt", "spotify", "duolingo"}, served(userID))
+		} else {
+			outOfCanary = true
+			assert.Equal(t, []string{"uber", "spotify", "duolingo"}, served(userID))
+		}
+	}
+	assert.True(t, inCanary && outOfCanary)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeliveryService_GetCampaigns_SessionOrder(t *testing.T) {
+	mockRepo := &MockCampaignRepository{}
+	service := NewDeliveryService(mockRepo)
+	service.SetMatchMemo(NewMatchMemo(time.Minute, 100, nil))
+
+	uber := createTestCampaign("uber", models.StatusActive, nil)
+	uber.Category = "ride_hailing"
+	lyft := createTestCampaign("lyft", models.StatusActive, nil)
+	lyft.Category = "ride_hailing"
+	campaigns := []models.CampaignWithRules{uber, lyft, createTestCampaign("spotify", models.StatusActive, nil), createTestCampaign("duolingo", models.StatusActive, nil)}
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(campaigns, nil).Once()
+	// An update moves lyft first, as loading campaigns by last update does
+	reloaded := []models.CampaignWithRules{lyft, uber, campaigns[2], campaigns[3]}
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(reloaded, nil)
+
+	ctx := reqcontext.WithClock(context.Background(), models.FixedClock{Time: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)})
+	served := func(sessionID string) []string {
+		result, err := service.GetCampaigns(ctx, models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android", SessionID: sessionID})
+		assert.NoError(t, err)
+		var ids []string
+		for _, campaign := range result {
+			ids = append(ids, campaign.CID)
+		}
+		return ids
+	}
+
+	// Without a session the load order is served, the first campaign of a category wins
+	assert.Equal(t, []string{"uber", "spotify", "duolingo"}, served(""))
+
+	orders := map[string]bool{}
+	sessions := map[string][]string{}
+	for i := 0; i < 20; i++ {
+		sessionID := fmt.Sprintf("session-%d", i)
+		ids := served(sessionID)
+		assert.Len(t, ids, 3)
+		sessions[sessionID] = ids
+		orders[strings.Join(ids, ",")] = true
+		// Sessions keep their order, also when answered from the memo
+		assert.Equal(t, ids, served(sessionID))
+	}
+	assert.Greater(t, len(orders), 1, "sessions should see different orders")
+
+	// Sessions keep their order when campaigns load in a different order
+	service.SetMatchMemo(nil)
+	for sessionID, ids := range sessions {
+		assert.Equal(t, ids, served(sessionID))
+	}
+	assert.Equal(t, []string{"lyft", "spotify", "duolingo"}, served(""))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeliveryService_GetCampaigns_Lang(t *testing.T) {
+	mockRepo := &MockCampaignRepository{}
+	service := NewDeliveryService(mockRepo)
+	service.SetMatchMemo(NewMatchMemo(time.Minute, 100, nil))
+
+	spotify := createTestCampaign("spotify", models.StatusActive, nil)
+	spotify.Creatives = []models.Creative{
+		{Lang: "es", Img: "https://example.com/spotify-es.jpg", CTA: "Descargar"},
+		{Lang: "pt-BR", CTA: "Baixar"},
+	}
+	campaigns := []models.CampaignWithRules{spotify, createTestCampaign("duolingo", models.StatusActive, nil)}
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(campaigns, nil)
+
+	ctx := reqcontext.WithClock(context.Background(), models.FixedClock{Time: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)})
+	served := func(lang string) []models.CampaignResponse {
+		result, err := service.GetCampaigns(ctx, models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android", Lang: lang})
+		assert.NoError(t, err)
+		return result
+	}
+
+	duolingo := models.CampaignResponse{CID: "duolingo", Img: "https://example.com/duolingo.jpg", CTA: "Install"}
+	assert.Equal(t, []models.CampaignResponse{{CID: "spotify", Img: "https://example.com/spotify.jpg", CTA: "Install"}, duolingo}, served(""))
+	// Regional variants get the creative of their language, also when answered from the memo
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, []models.CampaignResponse{{CID: "spotify", Img: "https://example.com/spotify-es.jpg", CTA: "Descargar"}, duolingo}, served("es-mx"))
+	}
+	// Creatives without an image keep the campaign's
+	assert.Equal(t, []models.CampaignResponse{{CID: "spotify", Img: "https://example.com/spotify.jpg", CTA: "Baixar"}, duolingo}, served("pt-br"))
+	assert.Equal(t, "Baixar", served("pt")[0].CTA)
+	// Languages without a creative fall back to the campaign's
+	assert.Equal(t, "Install", served("fr")[0].CTA)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeliveryService_GetCampaigns_Slots(t *testing.T) {
+	mockRepo := &MockCampaignRepository{}
+	service := NewDeliveryService(mockRepo)
+	service.SetMatchMemo(NewMatchMemo(time.Minute, 100, nil))
+
+	uber := createTestCampaign("uber", models.StatusActive, nil)
+	uber.Category = "ride_hailing"
+	lyft := createTestCampaign("lyft", models.StatusActive, nil)
+	lyft.Category = "ride_hailing"
+	// A second campaign of the same creative
+	spotifyFamily := createTestCampaign("spotify-family", models.StatusActive, nil)
+	spotifyFamily.ImageURL = "https://example.com/spotify.jpg"
+	campaigns := []models.CampaignWithRules{
+		uber, lyft, createTestCampaign("spotify", models.StatusActive, nil), spotifyFamily,
+		createTestCampaign("duolingo", models.StatusActive, nil), createTestCampaign("netflix", models.StatusActive, nil),
+	}
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(campaigns, nil).Once()
+
+	ctx := reqcontext.WithClock(context.Background(), models.FixedClock{Time: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)})
+	served := func(slots int) []string {
+		result, err := service.GetCampaigns(ctx, models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android", Slots: slots})
+		assert.NoError(t, err)
+		var ids []string
+		for _, campaign := range result {
+			ids = append(ids, campaign.CID)
+		}
+		return ids
+	}
+
+	// Without slots every campaign but the competitor is served
+	assert.Equal(t, []string{"uber", "spotify", "spotify-family", "duolingo", "netflix"}, served(0))
+	// Slots are filled in order, skipping competitors and repeated creatives
+	assert.Equal(t, []string{"uber", "spotify", "duolingo"}, served(3))
+	assert.Equal(t, []string{"uber"}, served(1))
+	// Slots without a campaign are left out
+	assert.Equal(t, []string{"uber", "spotify", "duolingo", "netflix"}, served(models.MaxSlots))
+
+	mockRepo.AssertExpectations(t)
+}
+
 // Helper function to create test campaigns
 func createTestCampaign(id string, status models.CampaignStatus, rules []models.TargetingRule) models.CampaignWithRules {
 	return models.CampaignWithRules{
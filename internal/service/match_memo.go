@@ -0,0 +1,160 @@
+package service
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// MatchSourceMemo is the match source of requests answered from the MatchMemo
+const MatchSourceMemo = "memo"
+
+// Outcomes of memo lookups reported to the MemoRecorder
+const (
+	MemoHit     = "hit"
+	MemoMiss    = "miss"
+	MemoEvicted = "evicted"
+)
+
+// MemoRecorder counts memo hits, misses and entries evicted to stay within the memory bound
+type MemoRecorder interface {
+	RecordMatchMemo(outcome string)
+}
+
+// memoEntry is the remembered match of a request fingerprint, before the serving constraints are applied
+type memoEntry struct {
+	key        string
+	campaigns  []models.CampaignResponse
+	dimensions []string
+	// constraints are the serving constraints of the campaigns, nil when none has any
+	constraints []servingConstraint
+	expires     time.Time
+}
+
+// MatchMemo remembers the campaigns matched for recent requests for a short time, so bursts of
+// identical requests skip the lookup and matching. Answers can be up to the TTL stale, on top
+// of the staleness of the campaign cache. Entries all live for the same TTL, so the oldest
+// entry is also the first to expire and is evicted when the memo is full.
+type MatchMemo struct {
+	ttl        time.Duration
+	maxEntries int
+	recorder   MemoRecorder
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // of *memoEntry, oldest first
+}
+
+// NewMatchMemo creates a memo keeping at most maxEntries matches for ttl, recorder may be nil
+func NewMatchMemo(ttl time.Duration, maxEntries int, recorder MemoRecorder) *MatchMemo {
+	return &MatchMemo{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		recorder:   recorder,
+		now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// memoKey fingerprints a normalized request by everything matching depends on: the request
+// dimensions, the consent and the hour of the request time in its own time zone, and by the
+// language the remembered responses are localized to
+func memoKey(req models.DeliveryRequest) string {
+	var b strings.Builder
+	b.Grow(len(req.Country) + len(req.OS) + len(req.App) + len(req.State) + len(req.Lang) + 25)
+	b.WriteString(req.Country)
+	b.WriteByte('|')
+	b.WriteString(req.OS)
+	b.WriteByte('|')
+	b.WriteString(req.App)
+	b.WriteByte('|')
+	b.WriteString(req.State)
+	b.WriteByte('|')
+	b.WriteString(req.Time.Format("2006010215-0700"))
+	b.WriteByte('|')
+	b.WriteString(strconv.FormatBool(req.NoConsent))
+	b.WriteByte('|')
+	b.WriteString(req.Lang)
+	return b.String()
+}
+
+// get returns the remembered match of key
+func (m *MatchMemo) get(key string) (*memoEntry, bool) {
+	m.mu.Lock()
+	element, ok := m.entries[key]
+	if ok && !m.now().Before(element.Value.(*memoEntry).expires) {
+		m.remove(element)
+		ok = false
+	}
+	m.mu.Unlock()
+
+	if ok {
+		m.record(MemoHit)
+		return element.Value.(*memoEntry), true
+	}
+	m.record(MemoMiss)
+	return nil, false
+}
+
+// age returns how long ago the match of entry was made
+func (m *MatchMemo) age(entry *memoEntry) time.Duration {
+	return m.ttl - entry.expires.Sub(m.now())
+}
+
+// put remembers the match of key, evicting expired entries and then the oldest ones beyond the bound
+func (m *MatchMemo) put(key string, campaigns []models.CampaignResponse, dimensions []string, constraints []servingConstraint) {
+	now := m.now()
+	entry := &memoEntry{key: key, campaigns: campaigns, dimensions: dimensions, constraints: constraints, expires: now.Add(m.ttl)}
+
+	evicted := 0
+	m.mu.Lock()
+	if element, ok := m.entries[key]; ok {
+		m.remove(element)
+	}
+	for front := m.order.Front(); front != nil && !now.Before(front.Value.(*memoEntry).expires); front = m.order.Front() {
+		m.remove(front)
+	}
+	for m.order.Len() >= m.maxEntries && m.order.Len() > 0 {
+		m.remove(m.order.Front())
+		evicted++
+	}
+	m.entries[key] = m.order.PushBack(entry)
+	m.mu.Unlock()
+
+	for range evicted {
+		m.record(MemoEvicted)
+	}
+}
+
+// remove deletes an entry, m.mu must be held
+func (m *MatchMemo) remove(element *list.Element) {
+	m.order.Remove(element)
+	delete(m.entries, element.Value.(*memoEntry).key)
+}
+
+// Len returns the number of remembered matches, including expired ones not evicted yet
+func (m *MatchMemo) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+// Reset forgets all remembered matches, e.g. after campaigns changed
+func (m *MatchMemo) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = make(map[string]*list.Element)
+	m.order.Init()
+}
+
+func (m *MatchMemo) record(outcome string) {
+	if m.recorder != nil {
+		m.recorder.RecordMatchMemo(outcome)
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// countingMemoRecorder counts memo outcomes
+type countingMemoRecorder map[string]int
+
+func (r countingMemoRecorder) RecordMatchMemo(outcome string) { r[outcome]++ }
+
+func TestMatchMemo_Expiry(t *testing.T) {
+	recorder := countingMemoRecorder{}
+	memo := NewMatchMemo(time.Second, 10, recorder)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	memo.now = func() time.Time { return now }
+
+	memo.put("us|android", []models.CampaignResponse{{CID: "spotify"}}, []string{"country"}, nil)
+	entry, ok := memo.get("us|android")
+	assert.True(t, ok)
+	assert.Equal(t, []models.CampaignResponse{{CID: "spotify"}}, entry.campaigns)
+
+	_, ok = memo.get("us|ios")
+	assert.False(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = memo.get("us|android")
+	assert.False(t, ok)
+	assert.Zero(t, memo.Len())
+
+	assert.Equal(t, countingMemoRecorder{MemoHit: 1, MemoMiss: 2}, recorder)
+}
+
+func TestMatchMemo_MaxEntries(t *testing.T) {
+	recorder := countingMemoRecorder{}
+	memo := NewMatchMemo(time.Minute, 2, recorder)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	memo.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		memo.put(key, nil, nil, nil)
+		now = now.Add(time.Second)
+	}
+
+	// The oldest entry made room
+	assert.Equal(t, 2, memo.Len())
+	_, ok := memo.get("a")
+	assert.False(t, ok)
+	_, ok = memo.get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 1, recorder[MemoEvicted])
+
+	// Expired entries are evicted before live ones, without counting as evictions
+	now = now.Add(time.Minute)
+	memo.put("d", nil, nil, nil)
+	assert.Equal(t, 1, memo.Len())
+	assert.Equal(t, 1, recorder[MemoEvicted])
+
+	memo.Reset()
+	assert.Zero(t, memo.Len())
+}
+
+func TestMemoKey(t *testing.T) {
+	at := time.Date(2025, 1, 1, 21, 30, 0, 0, time.FixedZone("IST", 5*3600+1800))
+	req := models.DeliveryRequest{Country: "in", OS: "android", App: "com.spotify", Time: at}
+
+	// Requests in the same local hour share a key
+	later := req
+	later.Time = at.Add(20 * time.Minute)
+	assert.Equal(t, memoKey(req), memoKey(later))
+
+	for name, change := range map[string]func(*models.DeliveryRequest){
+		"app":        func(r *models.DeliveryRequest) { r.App = "com.duolingo" },
+		"state":      func(r *models.DeliveryRequest) { r.State = "ka" },
+		"next hour":  func(r *models.DeliveryRequest) { r.Time = at.Add(time.Hour) },
+		"time zone":  func(r *models.DeliveryRequest) { r.Time = at.UTC() },
+		"no consent": func(r *models.DeliveryRequest) { r.NoConsent = true },
+		"language":   func(r *models.DeliveryRequest) { r.Lang = "es" },
+	} {
+		other := req
+		change(&other)
+		assert.NotEqual(t, memoKey(req), memoKey(other), name)
+	}
+}
+
+func TestDeliveryService_GetCampaigns_MatchMemo(t *testing.T) {
+	mockRepo := &MockCampaignRepository{}
+	service := NewDeliveryService(mockRepo)
+	matches := &recordingMatchRecorder{dimensions: make(map[string]int)}
+	decisions := &recordingDecisionRecorder{}
+	memoRecorder := countingMemoRecorder{}
+	service.SetMatchRecorder(matches)
+	service.SetDecisionRecorder(decisions)
+	memo := NewMatchMemo(time.Minute, 100, memoRecorder)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	memo.now = func() time.Time { return now }
+	service.SetMatchMemo(memo)
+
+	campaigns := []models.CampaignWithRules{
+		createTestCampaign("spotify", models.StatusActive, []models.TargetingRule{
+			{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"US"}},
+		}),
+	}
+	// The repository is only asked once
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(campaigns, nil).Once()
+
+	ctx := reqcontext.WithClock(context.Background(), models.FixedClock{Time: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)})
+	request := models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android"}
+	outcomeCtx, outcome := reqcontext.WithDeliveryOutcome(ctx)
+	first, err := service.GetCampaigns(outcomeCtx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, reqcontext.DeliveryOutcome{Matched: true}, *outcome)
+
+	// Normalization happens before the lookup, so differently cased requests hit
+	request.Country = "us"
+	second, err := service.GetCampaigns(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	// Callers own the returned campaigns
+	second[0].CID = "changed"
+	now = now.Add(10 * time.Second)
+	outcomeCtx, outcome = reqcontext.WithDeliveryOutcome(ctx)
+	third, err := service.GetCampaigns(outcomeCtx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, "spotify", third[0].CID)
+	// Remembered matches are as old as the match
+	assert.Equal(t, reqcontext.DeliveryOutcome{Matched: true, Age: 10 * time.Second}, *outcome)
+
+	assert.Equal(t, countingMemoRecorder{MemoMiss: 1, MemoHit: 2}, memoRecorder)
+	// Hits still count the matched dimensions and their decisions
+	assert.Equal(t, map[string]int{"country": 3}, matches.dimensions)
+	if assert.Len(t, decisions.decisions, 3) {
+		assert.Equal(t, MatchSourceMemo, decisions.decisions[2].Source)
+		assert.Equal(t, []string{"spotify"}, decisions.decisions[2].CampaignIDs)
+	}
+
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// matchCheckInterval is the number of campaigns a partition matches between context checks
+const matchCheckInterval = 64
+
+// ParallelMatcher matches large candidate sets in partitions, on a pool of workers shared by
+// every request so concurrent large requests don't multiply the goroutines
+type ParallelMatcher struct {
+	threshold int
+	// workers holds a token per busy worker, partitions without a free worker run on the
+	// goroutine of the request
+	workers chan struct{}
+}
+
+// NewParallelMatcher creates a matcher that partitions candidate sets of at least threshold
+// campaigns across up to workers goroutines besides the one of the request
+func NewParallelMatcher(threshold, workers int) *ParallelMatcher {
+	return &ParallelMatcher{
+		threshold: threshold,
+		workers:   make(chan struct{}, workers),
+	}
+}
+
+// applies reports whether candidate sets of n campaigns are matched in parallel
+func (p *ParallelMatcher) applies(n int) bool {
+	return p != nil && p.threshold > 0 && n >= p.threshold && cap(p.workers) > 0
+}
+
+// match sets matched[i] for each candidate i that matches. The candidates are split into a
+// partition per worker plus one for the calling goroutine, which also runs the partitions no
+// worker was free for. Partitions stop once ctx is done and its error is returned.
+func (p *ParallelMatcher) match(ctx context.Context, matched []bool, matches func(i int) bool) error {
+	partitions := cap(p.workers) + 1
+	size := (len(matched) + partitions - 1) / partitions
+
+	var wg sync.WaitGroup
+	for start := size; start < len(matched); start += size {
+		end := min(start+size, len(matched))
+		select {
+		case p.workers <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-p.workers
+					wg.Done()
+				}()
+				matchPartition(ctx, matched[start:end], start, matches)
+			}()
+		default:
+			matchPartition(ctx, matched[start:end], start, matches)
+		}
+	}
+	matchPartition(ctx, matched[:min(size, len(matched))], 0, matches)
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// matchPartition sets the matches of the candidates from offset, stopping once ctx is done
+func matchPartition(ctx context.Context, matched []bool, offset int, matches func(i int) bool) {
+	for i := range matched {
+		if i%matchCheckInterval == 0 && ctx.Err() != nil {
+			return
+		}
+		matched[i] = matches(offset + i)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelMatcher_Match(t *testing.T) {
+	for _, workers := range []int{1, 3, 8} {
+		for _, n := range []int{1, 7, 100, 1001} {
+			t.Run(fmt.Sprintf("%d workers %d candidates", workers, n), func(t *testing.T) {
+				matched := make([]bool, n)
+				err := NewParallelMatcher(1, workers).match(context.Background(), matched, func(i int) bool { return i%3 == 0 })
+				require.NoError(t, err)
+				for i := range matched {
+					assert.Equal(t, i%3 == 0, matched[i], "candidate %d", i)
+				}
+			})
+		}
+	}
+}
+
+func TestParallelMatcher_BusyWorkers(t *testing.T) {
+	parallel := NewParallelMatcher(1, 2)
+
+	// Without a free worker every partition runs on the calling goroutine
+	parallel.workers <- struct{}{}
+	parallel.workers <- struct{}{}
+
+	matched := make([]bool, 10)
+	require.NoError(t, parallel.match(context.Background(), matched, func(i int) bool { return i >= 5 }))
+	assert.Equal(t, []bool{false, false, false, false, false, true, true, true, true, true}, matched)
+}
+
+func TestParallelMatcher_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	matched := make([]bool, 1000)
+	err := NewParallelMatcher(1, 4).match(ctx, matched, func(i int) bool {
+		calls++
+		return true
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, calls)
+}
+
+func TestParallelMatcher_Applies(t *testing.T) {
+	assert.False(t, (*ParallelMatcher)(nil).applies(5000))
+	assert.False(t, NewParallelMatcher(100, 4).applies(99))
+	assert.True(t, NewParallelMatcher(100, 4).applies(100))
+	assert.False(t, NewParallelMatcher(100, 0).applies(100))
+	assert.False(t, NewParallelMatcher(0, 4).applies(100))
+}
+
+func TestDeliveryService_GetCampaigns_ParallelMatching(t *testing.T) {
+	campaigns := make([]models.CampaignWithRules, 500)
+	for i := range campaigns {
+		country := "US"
+		if i%4 == 0 {
+			country = "CA"
+		}
+		campaigns[i] = createTestCampaign(fmt.Sprintf("campaign-%d", i), models.StatusActive, []models.TargetingRule{
+			{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{country}},
+		})
+	}
+	mockRepo := &MockCampaignRepository{}
+	mockRepo.On("GetActiveCampaignsWithRules", mock.Anything).Return(campaigns, nil)
+	request := models.DeliveryRequest{App: "com.test.app", Country: "US", OS: "Android"}
+
+	sequential, err := NewDeliveryService(mockRepo).GetCampaigns(context.Background(), request)
+	require.NoError(t, err)
+	require.Len(t, sequential, 375)
+
+	// Parallel matching answers the same campaigns in the same order
+	service := NewDeliveryService(mockRepo)
+	service.SetParallelMatcher(NewParallelMatcher(100, 4))
+	recorder := &recordingMatchRecorder{dimensions: make(map[string]int)}
+	service.SetMatchRecorder(recorder)
+
+	parallel, err := service.GetCampaigns(context.Background(), request)
+	require.NoError(t, err)
+	assert.Equal(t, sequential, parallel)
+	assert.Equal(t, 500, recorder.evaluated)
+	assert.Equal(t, map[string]int{"country": 375}, recorder.dimensions)
+
+	// Cancelled requests stop matching
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = service.GetCampaigns(ctx, request)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"slices"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// composePod fills up to slots ad slots with the campaigns in their order, in place, campaign i
+// filling slot i+1. The campaigns already honor the staged rollouts and competitive separation,
+// see applyConstraints. A pod shows a campaign once and a creative once, so campaigns with the
+// image of an earlier campaign are skipped rather than repeating the ad in the next slot.
+func composePod(campaigns []models.CampaignResponse, slots int) []models.CampaignResponse {
+	pod := campaigns[:0]
+	for _, campaign := range campaigns {
+		if len(pod) == slots {
+			break
+		}
+		if slices.ContainsFunc(pod, func(filled models.CampaignResponse) bool {
+			return filled.CID == campaign.CID || (campaign.Img != "" && filled.Img == campaign.Img)
+		}) {
+			continue
+		}
+		pod = append(pod, campaign)
+	}
+	return pod
+}
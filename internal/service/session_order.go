@@ -0,0 +1,58 @@
+package service
+
+import (
+	"hash/fnv"
+	"slices"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// sessionOrder orders matched campaigns by a hash of the session ID and campaign ID, in place,
+// so every screen of a session sees its campaigns in the same order without the server storing
+// anything: the order doesn't depend on the order campaigns are loaded in, which changes when a
+// campaign is updated, and campaigns added or removed mid-session leave the others in place.
+// Different sessions see the campaigns in different orders. constraints is reordered in a copy,
+// it may belong to the memo, nil stays nil.
+func sessionOrder(sessionID string, campaigns []models.CampaignResponse, constraints []servingConstraint) []servingConstraint {
+	if len(campaigns) < 2 {
+		return constraints
+	}
+	ranks := make([]uint64, len(campaigns))
+	order := make([]int, len(campaigns))
+	for i, campaign := range campaigns {
+		ranks[i] = sessionRank(sessionID, campaign.CID)
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case ranks[a] < ranks[b]:
+			return -1
+		case ranks[a] > ranks[b]:
+			return 1
+		}
+		return 0
+	})
+
+	ordered := make([]models.CampaignResponse, len(campaigns))
+	for i, j := range order {
+		ordered[i] = campaigns[j]
+	}
+	copy(campaigns, ordered)
+	if constraints == nil {
+		return nil
+	}
+	orderedConstraints := make([]servingConstraint, len(constraints))
+	for i, j := range order {
+		orderedConstraints[i] = constraints[j]
+	}
+	return orderedConstraints
+}
+
+// sessionRank hashes the session ID and campaign ID into the rank of the campaign in the session
+func sessionRank(sessionID, campaignID string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(sessionID))
+	h.Write([]byte{0})
+	h.Write([]byte(campaignID))
+	return h.Sum64()
+}
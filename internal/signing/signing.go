@@ -0,0 +1,82 @@
+// Package signing signs payloads with HMAC-SHA256 over a timestamp and the payload, the scheme
+// of webhook requests, signed delivery responses and the API key requests replay protection
+// verifies
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers carrying the signature of a payload and the time it was signed at
+const (
+	TimestampHeader = "X-Adbeacon-Timestamp"
+	SignatureHeader = "X-Adbeacon-Signature"
+)
+
+// DefaultMaxAge is how old a signature Verify accepts by default, replays of older payloads
+// are rejected
+const DefaultMaxAge = 5 * time.Minute
+
+// Errors returned by Verify
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpiredSignature = errors.New("signature timestamp too old or in the future")
+)
+
+// Sign returns the signature of a payload signed at timestamp, Unix seconds, the hex
+// HMAC-SHA256 of "{timestamp}.{payload}" prefixed with "sha256="
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks that signature is the signature of payload signed at timestamp with secret,
+// within maxAge of now either way. The age is only checked once the signature is, so the
+// timestamp it is checked against is the signed one.
+func Verify(secret, timestamp, signature string, payload []byte, now time.Time, maxAge time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, payload))) {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > maxAge || age < -maxAge {
+		return ErrExpiredSignature
+	}
+	return nil
+}
+
+// RequestPayload returns the payload signed for a request: its method, path and query string
+// with the parameters sorted by name (see url.Values.Encode), separated by newlines
+func RequestPayload(method, path string, query url.Values) []byte {
+	return []byte(method + "\n" + path + "\n" + query.Encode())
+}
+
+// ParseSecrets parses key_id:secret entries into secrets by key ID. Errors name the entry by
+// position, never its secret.
+func ParseSecrets(entries []string) (map[string]string, error) {
+	secrets := make(map[string]string, len(entries))
+	for i, entry := range entries {
+		keyID, secret, ok := strings.Cut(entry, ":")
+		switch {
+		case !ok || keyID == "" || secret == "":
+			return nil, fmt.Errorf("entry %d isn't key_id:secret", i+1)
+		case secrets[keyID] != "":
+			return nil, fmt.Errorf("key ID %s has more than one secret", keyID)
+		}
+		secrets[keyID] = secret
+	}
+	return secrets, nil
+}
@@ -0,0 +1,66 @@
+package signing
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	body := []byte(`[{"cid":"spotify"}]`)
+	signature := Sign("s3cret", "1735689600", body)
+	require.Regexp(t, `^sha256=[0-9a-f]{64}$`, signature)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{name: "valid", secret: "s3cret", timestamp: "1735689600", signature: signature, body: body},
+		{name: "a few minutes old", secret: "s3cret", timestamp: "1735689400", signature: Sign("s3cret", "1735689400", body), body: body},
+		{name: "other secret", secret: "other", timestamp: "1735689600", signature: signature, body: body, want: ErrInvalidSignature},
+		{name: "changed body", secret: "s3cret", timestamp: "1735689600", signature: signature, body: []byte(`[{"cid":"netflix"}]`), want: ErrInvalidSignature},
+		{name: "changed timestamp", secret: "s3cret", timestamp: "1735689601", signature: signature, body: body, want: ErrInvalidSignature},
+		{name: "old timestamp", secret: "s3cret", timestamp: "1735689000", signature: Sign("s3cret", "1735689000", body), body: body, want: ErrExpiredSignature},
+		{name: "future timestamp", secret: "s3cret", timestamp: "1735690200", signature: Sign("s3cret", "1735690200", body), body: body, want: ErrExpiredSignature},
+		{name: "old timestamp of another signature", secret: "s3cret", timestamp: "1735689000", signature: signature, body: body, want: ErrInvalidSignature},
+		{name: "invalid timestamp", secret: "s3cret", timestamp: "yesterday", signature: signature, body: body, want: ErrInvalidSignature},
+		{name: "missing signature", secret: "s3cret", timestamp: "1735689600", body: body, want: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, tt.signature, tt.body, now, 5*time.Minute)
+			if tt.want == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestPayload(t *testing.T) {
+	query := url.Values{"ts": {"1735689600"}, "country": {"us"}, "app": {"com.example app"}}
+	assert.Equal(t, "GET\n/v1/delivery\napp=com.example+app&country=us&ts=1735689600", string(RequestPayload("GET", "/v1/delivery", query)))
+}
+
+func TestParseSecrets(t *testing.T) {
+	secrets, err := ParseSecrets([]string{"3f2a9c1b7e4d:s3cret", "5b8e:with:colons"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"3f2a9c1b7e4d": "s3cret", "5b8e": "with:colons"}, secrets)
+
+	for _, entries := range [][]string{{"3f2a9c1b7e4d"}, {":s3cret"}, {"3f2a9c1b7e4d:"}, {"5b8e:one", "5b8e:two"}} {
+		_, err := ParseSecrets(entries)
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "s3cret")
+		assert.NotContains(t, err.Error(), "two")
+	}
+}
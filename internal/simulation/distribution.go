@@ -0,0 +1,61 @@
+package simulation
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Distribution picks values with configured relative weights
+type Distribution struct {
+	values []string
+	// cumulative[i] is the sum of the weights of values[0..i]
+	cumulative []float64
+}
+
+// ParseDistribution parses a comma separated list of value:weight pairs, e.g. "us:60,in:30,de:10".
+// Values without a weight get weight 1 and an empty spec yields an empty distribution.
+func ParseDistribution(spec string) (*Distribution, error) {
+	d := &Distribution{}
+	if strings.TrimSpace(spec) == "" {
+		return d, nil
+	}
+
+	total := 0.0
+	for _, part := range strings.Split(spec, ",") {
+		value, weightText, hasWeight := strings.Cut(strings.TrimSpace(part), ":")
+		if value == "" {
+			return nil, fmt.Errorf("empty value in %q", spec)
+		}
+
+		weight := 1.0
+		if hasWeight {
+			var err error
+			weight, err = strconv.ParseFloat(weightText, 64)
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight %q for %s, must be a positive number", weightText, value)
+			}
+		}
+
+		total += weight
+		d.values = append(d.values, value)
+		d.cumulative = append(d.cumulative, total)
+	}
+	return d, nil
+}
+
+// Pick returns a value drawn with the configured weights, or "" for an empty distribution
+func (d *Distribution) Pick(rng *rand.Rand) string {
+	if len(d.values) == 0 {
+		return ""
+	}
+
+	target := rng.Float64() * d.cumulative[len(d.cumulative)-1]
+	for i, bound := range d.cumulative {
+		if target < bound {
+			return d.values[i]
+		}
+	}
+	return d.values[len(d.values)-1]
+}
@@ -0,0 +1,77 @@
+// Package simulation estimates the reach of a proposed campaign by matching it against
+// a sample of historical or synthetic delivery requests, without storing or serving it
+package simulation
+
+import (
+	"math"
+	"sort"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// DimensionResult is the number of requests satisfying the rules of one dimension on their own
+type DimensionResult struct {
+	Dimension string  `json:"dimension"`
+	Matched   int     `json:"matched"`
+	MatchRate float64 `json:"match_rate"`
+}
+
+// Result is the estimated reach of a campaign over a request sample
+type Result struct {
+	Requests  int     `json:"requests"`
+	Matched   int     `json:"matched"`
+	MatchRate float64 `json:"match_rate"`
+	// Dimensions shows which dimension narrows the reach most, sorted by dimension name
+	Dimensions []DimensionResult `json:"dimensions"`
+}
+
+// Run matches campaign against every request as the delivery service would. The campaign is
+// simulated as active whatever its status, so paused and proposed campaigns can be evaluated.
+func Run(campaign models.CampaignWithRules, requests []models.DeliveryRequest) Result {
+	campaign.Status = models.StatusActive
+
+	// Each dimension is matched on its own, with the rules of the other dimensions removed
+	dimensions := make(map[string]*models.CampaignWithRules)
+	for _, rule := range campaign.Rules {
+		name := string(rule.Dimension)
+		if dimensions[name] == nil {
+			dimensions[name] = &models.CampaignWithRules{Campaign: campaign.Campaign}
+		}
+		dimensions[name].Rules = append(dimensions[name].Rules, rule)
+	}
+
+	result := Result{Requests: len(requests)}
+	dimensionMatched := make(map[string]int, len(dimensions))
+	for _, req := range requests {
+		req.NormalizeValues()
+		if campaign.MatchesRequest(req) {
+			result.Matched++
+		}
+		for name, dimensionCampaign := range dimensions {
+			if dimensionCampaign.MatchesRequest(req) {
+				dimensionMatched[name]++
+			}
+		}
+	}
+
+	result.MatchRate = rate(result.Matched, result.Requests)
+	result.Dimensions = make([]DimensionResult, 0, len(dimensions))
+	for name := range dimensions {
+		result.Dimensions = append(result.Dimensions, DimensionResult{
+			Dimension: name,
+			Matched:   dimensionMatched[name],
+			MatchRate: rate(dimensionMatched[name], result.Requests),
+		})
+	}
+	sort.Slice(result.Dimensions, func(i, j int) bool { return result.Dimensions[i].Dimension < result.Dimensions[j].Dimension })
+
+	return result
+}
+
+// rate returns matched/total rounded to 4 decimals, 0 for an empty sample
+func rate(matched, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(matched)/float64(total)*10000) / 10000
+}
@@ -0,0 +1,113 @@
+package simulation
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDistribution(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       string
+		wantValues []string
+		wantErr    bool
+	}{
+		{name: "weighted", spec: "us:60, in:40", wantValues: []string{"us", "in"}},
+		{name: "default weight", spec: "android,ios:2", wantValues: []string{"android", "ios"}},
+		{name: "empty", spec: "", wantValues: nil},
+		{name: "zero weight", spec: "us:0", wantErr: true},
+		{name: "invalid weight", spec: "us:lots", wantErr: true},
+		{name: "empty value", spec: "us,,in", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDistribution(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantValues, d.values)
+		})
+	}
+}
+
+func TestDistributionPick(t *testing.T) {
+	d, err := ParseDistribution("us:90,in:10")
+	require.NoError(t, err)
+
+	counts := map[string]int{}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		counts[d.Pick(rng)]++
+	}
+	assert.InDelta(t, 9000, counts["us"], 300)
+	assert.InDelta(t, 1000, counts["in"], 300)
+
+	// The same seed yields the same sequence
+	a, b := rand.New(rand.NewSource(7)), rand.New(rand.NewSource(7))
+	for i := 0; i < 100; i++ {
+		require.Equal(t, d.Pick(a), d.Pick(b))
+	}
+
+	empty, err := ParseDistribution("")
+	require.NoError(t, err)
+	assert.Empty(t, empty.Pick(rng))
+}
+
+func TestSyntheticRequests(t *testing.T) {
+	requests, err := SyntheticRequests(SyntheticConfig{Count: 50, Seed: 3, Countries: "us"})
+	require.NoError(t, err)
+	require.Len(t, requests, 50)
+	for _, req := range requests {
+		assert.Equal(t, "us", req.Country)
+		assert.NotEmpty(t, req.OS)
+		assert.NotEmpty(t, req.App)
+		assert.Empty(t, req.State)
+	}
+
+	again, err := SyntheticRequests(SyntheticConfig{Count: 50, Seed: 3, Countries: "us"})
+	require.NoError(t, err)
+	assert.Equal(t, requests, again)
+
+	_, err = SyntheticRequests(SyntheticConfig{Count: MaxSyntheticRequests + 1})
+	assert.Error(t, err)
+	_, err = SyntheticRequests(SyntheticConfig{Count: 10, OS: "android:x"})
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	campaign := models.CampaignWithRules{
+		Campaign: models.Campaign{ID: "proposed", Status: models.StatusInactive},
+		Rules: []models.TargetingRule{
+			{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Values: []string{"us", "ca"}},
+			{Dimension: models.DimensionOS, RuleType: models.RuleTypeInclude, Values: []string{"android"}},
+		},
+	}
+	requests := []models.DeliveryRequest{
+		{Country: "US", OS: "Android", App: "a"},
+		{Country: "ca", OS: "ios", App: "a"},
+		{Country: "in", OS: "android", App: "a"},
+		{Country: "de", OS: "ios", App: "a"},
+	}
+
+	result := Run(campaign, requests)
+
+	// Inactive campaigns are simulated as active and requests are normalized
+	assert.Equal(t, 4, result.Requests)
+	assert.Equal(t, 1, result.Matched)
+	assert.Equal(t, 0.25, result.MatchRate)
+	assert.Equal(t, []DimensionResult{
+		{Dimension: "country", Matched: 2, MatchRate: 0.5},
+		{Dimension: "os", Matched: 2, MatchRate: 0.5},
+	}, result.Dimensions)
+
+	empty := Run(models.CampaignWithRules{}, nil)
+	assert.Zero(t, empty.MatchRate)
+	assert.Empty(t, empty.Dimensions)
+}
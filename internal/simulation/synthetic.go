@@ -0,0 +1,103 @@
+package simulation
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// Default dimension value distributions of synthetic traffic, roughly the mix of the sample campaigns
+const (
+	DefaultCountries = "us:50,in:25,de:15,ca:10"
+	DefaultOS        = "android:70,ios:30"
+	DefaultApps      = "com.spotify.music:30,com.duolingo:30,com.gametion.ludokinggame:20,com.example.other:20"
+)
+
+// MaxSyntheticRequests caps the number of synthetic requests generated for a single simulation
+const MaxSyntheticRequests = 100000
+
+// SyntheticConfig describes synthetic delivery traffic. Each field holding a distribution is a comma
+// separated list of value:weight pairs, see ParseDistribution, and an empty States omits the state.
+type SyntheticConfig struct {
+	Count     int    `json:"count"`
+	Seed      int64  `json:"seed"`
+	Countries string `json:"countries,omitempty"`
+	OS        string `json:"os,omitempty"`
+	Apps      string `json:"apps,omitempty"`
+	States    string `json:"states,omitempty"`
+}
+
+// Generator draws delivery requests from dimension value distributions. It is not safe for
+// concurrent use, a seed always yields the same request sequence.
+type Generator struct {
+	rng       *rand.Rand
+	countries *Distribution
+	oses      *Distribution
+	apps      *Distribution
+	states    *Distribution
+}
+
+// NewGenerator creates a generator for config, empty country, os and app distributions use the defaults.
+// Count is not used by the generator.
+func NewGenerator(config SyntheticConfig) (*Generator, error) {
+	specs := []struct {
+		name     string
+		spec     string
+		fallback string
+	}{
+		{"countries", config.Countries, DefaultCountries},
+		{"os", config.OS, DefaultOS},
+		{"apps", config.Apps, DefaultApps},
+		{"states", config.States, ""},
+	}
+
+	distributions := make([]*Distribution, len(specs))
+	for i, s := range specs {
+		spec := s.spec
+		if spec == "" {
+			spec = s.fallback
+		}
+		d, err := ParseDistribution(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.name, err)
+		}
+		distributions[i] = d
+	}
+
+	return &Generator{
+		rng:       rand.New(rand.NewSource(config.Seed)),
+		countries: distributions[0],
+		oses:      distributions[1],
+		apps:      distributions[2],
+		states:    distributions[3],
+	}, nil
+}
+
+// Next returns the next synthetic delivery request
+func (g *Generator) Next() models.DeliveryRequest {
+	return models.DeliveryRequest{
+		Country: g.countries.Pick(g.rng),
+		OS:      g.oses.Pick(g.rng),
+		App:     g.apps.Pick(g.rng),
+		State:   g.states.Pick(g.rng),
+	}
+}
+
+// SyntheticRequests generates config.Count requests, at most MaxSyntheticRequests
+func SyntheticRequests(config SyntheticConfig) ([]models.DeliveryRequest, error) {
+	if config.Count <= 0 || config.Count > MaxSyntheticRequests {
+		return nil, fmt.Errorf("count must be between 1 and %d, got %d", MaxSyntheticRequests, config.Count)
+	}
+
+	generator, err := NewGenerator(config)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]models.DeliveryRequest, config.Count)
+	for i := range requests {
+		requests[i] = generator.Next()
+	}
+	return requests, nil
+}
@@ -0,0 +1,59 @@
+package slo
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Descriptors for the SLO collector
+var (
+	availabilityDesc = prometheus.NewDesc(
+		"adbeacon_slo_availability_ratio",
+		"Fraction of delivery requests without a server error over the window",
+		[]string{"window"}, nil,
+	)
+	latencyComplianceDesc = prometheus.NewDesc(
+		"adbeacon_slo_latency_compliance_ratio",
+		"Fraction of delivery requests faster than the latency target over the window",
+		[]string{"window"}, nil,
+	)
+	burnRateDesc = prometheus.NewDesc(
+		"adbeacon_slo_error_budget_burn_rate",
+		"Error budget burn rate over the window (1 = consuming budget exactly at the allowed rate)",
+		[]string{"slo", "window"}, nil,
+	)
+	objectiveDesc = prometheus.NewDesc(
+		"adbeacon_slo_objective_ratio",
+		"Configured service level objective",
+		[]string{"slo"}, nil,
+	)
+	latencyTargetDesc = prometheus.NewDesc(
+		"adbeacon_slo_latency_target_seconds",
+		"Configured latency target for the latency SLO",
+		nil, nil,
+	)
+)
+
+// Describe implements prometheus.Collector
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- availabilityDesc
+	ch <- latencyComplianceDesc
+	ch <- burnRateDesc
+	ch <- objectiveDesc
+	ch <- latencyTargetDesc
+}
+
+// Collect implements prometheus.Collector
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	summary := t.Summary()
+
+	for _, window := range summary.Windows {
+		ch <- prometheus.MustNewConstMetric(availabilityDesc, prometheus.GaugeValue, window.Availability, window.Window)
+		ch <- prometheus.MustNewConstMetric(latencyComplianceDesc, prometheus.GaugeValue, window.LatencyCompliance, window.Window)
+		ch <- prometheus.MustNewConstMetric(burnRateDesc, prometheus.GaugeValue, window.AvailabilityBurnRate, "availability", window.Window)
+		ch <- prometheus.MustNewConstMetric(burnRateDesc, prometheus.GaugeValue, window.LatencyBurnRate, "latency", window.Window)
+	}
+
+	ch <- prometheus.MustNewConstMetric(objectiveDesc, prometheus.GaugeValue, t.config.AvailabilityObjective, "availability")
+	ch <- prometheus.MustNewConstMetric(objectiveDesc, prometheus.GaugeValue, t.config.LatencyObjective, "latency")
+	ch <- prometheus.MustNewConstMetric(latencyTargetDesc, prometheus.GaugeValue, t.config.LatencyTarget.Seconds())
+}
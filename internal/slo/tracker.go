@@ -0,0 +1,148 @@
+package slo
+
+import (
+	"sync"
+	"time"
+)
+
+// Config holds the service level objectives
+type Config struct {
+	// AvailabilityObjective is the target fraction of non-5xx requests (e.g. 0.999)
+	AvailabilityObjective float64
+	// LatencyTarget is the latency a request must stay under to count as fast
+	LatencyTarget time.Duration
+	// LatencyObjective is the target fraction of requests faster than LatencyTarget (e.g. 0.99 for p99)
+	LatencyObjective float64
+}
+
+// Windows over which SLIs and burn rates are reported, these match the
+// short windows of the common multi-window burn-rate alerting setup
+var Windows = []time.Duration{5 * time.Minute, time.Hour}
+
+// bucketWidth is the resolution of the rolling windows
+const bucketWidth = time.Minute
+
+// bucket holds request outcomes for one bucketWidth interval
+type bucket struct {
+	start  time.Time
+	total  int64
+	errors int64
+	slow   int64
+}
+
+// WindowSummary is the SLI and error budget state over a single window
+type WindowSummary struct {
+	Window               string  `json:"window"`
+	Requests             int64   `json:"requests"`
+	Availability         float64 `json:"availability"`
+	AvailabilityBurnRate float64 `json:"availability_burn_rate"`
+	LatencyCompliance    float64 `json:"latency_compliance"`
+	LatencyBurnRate      float64 `json:"latency_burn_rate"`
+}
+
+// Summary is the full SLO report
+type Summary struct {
+	AvailabilityObjective float64         `json:"availability_objective"`
+	LatencyTarget         string          `json:"latency_target"`
+	LatencyObjective      float64         `json:"latency_objective"`
+	Windows               []WindowSummary `json:"windows"`
+}
+
+// Tracker records request outcomes in rolling windows and computes SLIs and burn rates
+type Tracker struct {
+	config  Config
+	buckets []bucket
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+// NewTracker creates a new SLO tracker
+func NewTracker(config Config) *Tracker {
+	longest := Windows[len(Windows)-1]
+	return &Tracker{
+		config:  config,
+		buckets: make([]bucket, int(longest/bucketWidth)),
+		now:     time.Now,
+	}
+}
+
+// Record records the outcome of a single request
+func (t *Tracker) Record(duration time.Duration, serverError bool) {
+	now := t.now().Truncate(bucketWidth)
+	idx := int(now.Unix()/int64(bucketWidth.Seconds())) % len(t.buckets)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := &t.buckets[idx]
+	if !b.start.Equal(now) {
+		// The slot holds data from a previous cycle, reset it
+		*b = bucket{start: now}
+	}
+
+	b.total++
+	if serverError {
+		b.errors++
+	}
+	if duration > t.config.LatencyTarget {
+		b.slow++
+	}
+}
+
+// Summary computes SLIs and burn rates for all windows
+func (t *Tracker) Summary() Summary {
+	summary := Summary{
+		AvailabilityObjective: t.config.AvailabilityObjective,
+		LatencyTarget:         t.config.LatencyTarget.String(),
+		LatencyObjective:      t.config.LatencyObjective,
+	}
+
+	for _, window := range Windows {
+		summary.Windows = append(summary.Windows, t.windowSummary(window))
+	}
+
+	return summary
+}
+
+// windowSummary aggregates buckets within the window ending now
+func (t *Tracker) windowSummary(window time.Duration) WindowSummary {
+	cutoff := t.now().Add(-window)
+
+	var total, errors, slow int64
+	t.mu.Lock()
+	for _, b := range t.buckets {
+		if b.total == 0 || !b.start.After(cutoff) {
+			continue
+		}
+		total += b.total
+		errors += b.errors
+		slow += b.slow
+	}
+	t.mu.Unlock()
+
+	summary := WindowSummary{
+		Window:            window.String(),
+		Requests:          total,
+		Availability:      1,
+		LatencyCompliance: 1,
+	}
+
+	if total > 0 {
+		summary.Availability = 1 - float64(errors)/float64(total)
+		summary.LatencyCompliance = 1 - float64(slow)/float64(total)
+	}
+
+	summary.AvailabilityBurnRate = burnRate(summary.Availability, t.config.AvailabilityObjective)
+	summary.LatencyBurnRate = burnRate(summary.LatencyCompliance, t.config.LatencyObjective)
+
+	return summary
+}
+
+// burnRate is how fast the error budget is consumed, 1 means exactly on budget
+func burnRate(sli, objective float64) float64 {
+	budget := 1 - objective
+	if budget <= 0 {
+		return 0
+	}
+	return (1 - sli) / budget
+}
@@ -0,0 +1,69 @@
+package slo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTracker_Summary(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(Config{
+		AvailabilityObjective: 0.99,
+		LatencyTarget:         50 * time.Millisecond,
+		LatencyObjective:      0.9,
+	})
+	tracker.now = func() time.Time { return now }
+
+	// 100 requests 30 minutes ago: all failed and slow, outside the 5m window
+	now = now.Add(-30 * time.Minute)
+	for i := 0; i < 100; i++ {
+		tracker.Record(100*time.Millisecond, true)
+	}
+
+	// 100 requests now: 2 errors, 20 slow
+	now = now.Add(30 * time.Minute)
+	for i := 0; i < 100; i++ {
+		duration := 10 * time.Millisecond
+		if i < 20 {
+			duration = 100 * time.Millisecond
+		}
+		tracker.Record(duration, i < 2)
+	}
+
+	summary := tracker.Summary()
+	if len(summary.Windows) != len(Windows) {
+		t.Fatalf("expected %d windows, got %d", len(Windows), len(summary.Windows))
+	}
+
+	short := summary.Windows[0]
+	if short.Requests != 100 {
+		t.Errorf("expected 100 requests in short window, got %d", short.Requests)
+	}
+	assertClose(t, "availability", short.Availability, 0.98)
+	assertClose(t, "availability burn rate", short.AvailabilityBurnRate, 2)
+	assertClose(t, "latency compliance", short.LatencyCompliance, 0.8)
+	assertClose(t, "latency burn rate", short.LatencyBurnRate, 2)
+
+	long := summary.Windows[1]
+	if long.Requests != 200 {
+		t.Errorf("expected 200 requests in long window, got %d", long.Requests)
+	}
+	assertClose(t, "long availability", long.Availability, 0.49)
+
+	// Two hours later everything has aged out
+	now = now.Add(2 * time.Hour)
+	summary = tracker.Summary()
+	for _, w := range summary.Windows {
+		if w.Requests != 0 || w.Availability != 1 || w.AvailabilityBurnRate != 0 {
+			t.Errorf("expected empty window %s, got %+v", w.Window, w)
+		}
+	}
+}
+
+func assertClose(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s: expected %v, got %v", name, want, got)
+	}
+}
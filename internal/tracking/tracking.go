@@ -0,0 +1,119 @@
+package tracking
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// EventType is the kind of a tracked event
+type EventType string
+
+const (
+	// EventImpression is recorded when a delivered campaign rendered
+	EventImpression EventType = "impression"
+	// EventClick is recorded when a delivered campaign was clicked
+	EventClick EventType = "click"
+)
+
+// Event is a tracked impression or click of a campaign. RequestID is the X-Request-ID of the
+// delivery that served the campaign, it joins events to deliveries.
+type Event struct {
+	Type       EventType `json:"type"`
+	CampaignID string    `json:"campaign_id"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Country    string    `json:"country,omitempty"`
+	OS         string    `json:"os,omitempty"`
+	App        string    `json:"app,omitempty"`
+	State      string    `json:"state,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Recorder receives tracked events. Record must not block, tracking responses are served
+// from the rendering path of the ad.
+type Recorder interface {
+	Record(ctx context.Context, event Event)
+}
+
+// RecorderFunc adapts a function to a Recorder
+type RecorderFunc func(ctx context.Context, event Event)
+
+// Record calls f
+func (f RecorderFunc) Record(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+// MultiRecorder passes every event to each of its recorders in order
+type MultiRecorder []Recorder
+
+// Record passes event to each recorder
+func (m MultiRecorder) Record(ctx context.Context, event Event) {
+	for _, recorder := range m {
+		recorder.Record(ctx, event)
+	}
+}
+
+// CounterRecorder counts events, e.g. in Prometheus
+type CounterRecorder interface {
+	RecordTrackedEvent(eventType string)
+}
+
+// NewCounterRecorder creates a recorder counting events by type
+func NewCounterRecorder(counter CounterRecorder) Recorder {
+	return RecorderFunc(func(_ context.Context, event Event) {
+		counter.RecordTrackedEvent(string(event.Type))
+	})
+}
+
+// NewLogRecorder creates a recorder writing one log line per event
+func NewLogRecorder(logger kitlog.Logger) Recorder {
+	return RecorderFunc(func(_ context.Context, event Event) {
+		level.Info(logger).Log(
+			"msg", "tracked event",
+			"type", event.Type,
+			"campaign_id", event.CampaignID,
+			"request_id", event.RequestID,
+			"country", event.Country,
+			"os", event.OS,
+			"app", event.App,
+			"state", event.State,
+		)
+	})
+}
+
+// RedirectPolicy decides which click destinations may be redirected to, so the click
+// endpoint can't be used as an open redirect
+type RedirectPolicy struct {
+	hosts []string
+}
+
+// NewRedirectPolicy allows redirects to the given hosts and their subdomains, no redirects
+// are allowed without hosts
+func NewRedirectPolicy(hosts []string) *RedirectPolicy {
+	normalized := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			normalized = append(normalized, host)
+		}
+	}
+	return &RedirectPolicy{hosts: normalized}
+}
+
+// Allowed reports whether destination is an absolute http(s) URL on an allowed host
+func (p *RedirectPolicy) Allowed(destination *url.URL) bool {
+	if destination.Scheme != "http" && destination.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(destination.Hostname())
+	if host == "" {
+		return false
+	}
+	return slices.ContainsFunc(p.hosts, func(allowed string) bool {
+		return host == allowed || strings.HasSuffix(host, "."+allowed)
+	})
+}
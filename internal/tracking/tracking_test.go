@@ -0,0 +1,61 @@
+package tracking
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	policy := NewRedirectPolicy([]string{" Example.com ", "", "play.google.com"})
+
+	tests := []struct {
+		destination string
+		allowed     bool
+	}{
+		{"https://example.com/landing", true},
+		{"http://EXAMPLE.com:8080/landing", true},
+		{"https://www.example.com/", true},
+		{"https://play.google.com/store/apps/details?id=com.spotify", true},
+		{"https://google.com/", false},
+		{"https://notexample.com/", false},
+		{"https://example.com.evil.io/", false},
+		{"javascript://example.com/%0aalert(1)", false},
+		{"ftp://example.com/file", false},
+		{"//example.com/landing", false},
+	}
+
+	for _, tt := range tests {
+		destination, err := url.Parse(tt.destination)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.allowed, policy.Allowed(destination), tt.destination)
+	}
+
+	// Without hosts nothing is allowed
+	destination, _ := url.Parse("https://example.com/")
+	assert.False(t, NewRedirectPolicy(nil).Allowed(destination))
+}
+
+type countingRecorder map[string]int
+
+func (c countingRecorder) RecordTrackedEvent(eventType string) {
+	c[eventType]++
+}
+
+func TestMultiRecorder(t *testing.T) {
+	counts := countingRecorder{}
+	var events []Event
+	recorder := MultiRecorder{
+		NewCounterRecorder(counts),
+		RecorderFunc(func(_ context.Context, event Event) { events = append(events, event) }),
+	}
+
+	recorder.Record(context.Background(), Event{Type: EventImpression, CampaignID: "spotify"})
+	recorder.Record(context.Background(), Event{Type: EventClick, CampaignID: "spotify"})
+	recorder.Record(context.Background(), Event{Type: EventImpression, CampaignID: "duolingo"})
+
+	assert.Equal(t, countingRecorder{"impression": 2, "click": 1}, counts)
+	assert.Len(t, events, 3)
+}
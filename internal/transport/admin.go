@@ -0,0 +1,87 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/config"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/logger"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/slo"
+)
+
+// createSLOHandler creates a handler reporting SLIs and error budget burn rates
+func createSLOHandler(tracker *slo.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, tracker.Summary())
+	}
+}
+
+// loggingSettings is the request and response body of the /v1/admin/logging endpoint
+// Fields omitted from a PUT request are left unchanged
+type loggingSettings struct {
+	Level      *string  `json:"level,omitempty"`
+	SampleRate *float64 `json:"sample_rate,omitempty"`
+}
+
+// createLoggingHandler creates a handler to read and change log level and request log sampling at runtime
+func createLoggingHandler(controls *logger.Controls) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var settings loggingSettings
+			if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid request body"))
+				return
+			}
+
+			// Validate everything before applying anything so a bad request changes nothing
+			if settings.Level != nil {
+				if _, err := logger.ParseLevel(*settings.Level); err != nil {
+					writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+					return
+				}
+			}
+			if settings.SampleRate != nil && (*settings.SampleRate < 0 || *settings.SampleRate > 1) {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("sample rate must be between 0 and 1"))
+				return
+			}
+
+			if settings.Level != nil {
+				controls.SetLevel(*settings.Level)
+			}
+			if settings.SampleRate != nil {
+				controls.SetSampleRate(*settings.SampleRate)
+			}
+		}
+
+		level := controls.Level()
+		sampleRate := controls.SampleRate()
+		writeJSON(w, http.StatusOK, loggingSettings{Level: &level, SampleRate: &sampleRate})
+	}
+}
+
+// configSnapshot is the response body of the /v1/admin/config endpoint
+type configSnapshot struct {
+	Config   config.Config    `json:"config"`
+	Tunables *config.Tunables `json:"tunables,omitempty"`
+}
+
+// createConfigHandler creates a handler reporting the loaded configuration with secrets masked,
+// and the tunables currently in effect if tunables is set
+func createConfigHandler(cfg *config.Config, tunables func() config.Tunables) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot := configSnapshot{Config: cfg.Masked()}
+		if tunables != nil {
+			current := tunables()
+			snapshot.Tunables = &current
+		}
+		writeJSON(w, http.StatusOK, snapshot)
+	}
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,616 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kit/log"
+	"github.com/go-redis/redis/v8"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/blocklist"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/campaignstats"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/config"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/logger"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/overview"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reach"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/simulation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingAdminEndpoint(t *testing.T) {
+	controls := logger.NewControls("info", 1)
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{LogControls: controls})
+
+	tests := []struct {
+		name           string
+		body           string
+		wantStatus     int
+		wantLevel      string
+		wantSampleRate float64
+	}{
+		{name: "change level", body: `{"level":"debug"}`, wantStatus: http.StatusOK, wantLevel: "debug", wantSampleRate: 1},
+		{name: "change sample rate", body: `{"sample_rate":0.25}`, wantStatus: http.StatusOK, wantLevel: "debug", wantSampleRate: 0.25},
+		{name: "invalid level", body: `{"level":"loud","sample_rate":0.5}`, wantStatus: http.StatusBadRequest, wantLevel: "debug", wantSampleRate: 0.25},
+		{name: "invalid sample rate", body: `{"level":"warn","sample_rate":2}`, wantStatus: http.StatusBadRequest, wantLevel: "debug", wantSampleRate: 0.25},
+		{name: "invalid body", body: `not json`, wantStatus: http.StatusBadRequest, wantLevel: "debug", wantSampleRate: 0.25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/v1/admin/logging", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			// Rejected requests must not partially apply
+			assert.Equal(t, tt.wantLevel, controls.Level())
+			assert.Equal(t, tt.wantSampleRate, controls.SampleRate())
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/v1/admin/logging", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "debug", response["level"])
+	assert.Equal(t, 0.25, response["sample_rate"])
+}
+
+func TestConfigAdminEndpoint(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.GeneralConfig.Port = 8080
+	cfg.DatabaseConfig.User = "adbeacon"
+	cfg.DatabaseConfig.Password = "s3cret"
+	cfg.CacheConfig.RedisPassword = ""
+	cfg.SigningConfig.Secrets = []string{"3f2a9c1b7e4d:s3cret"}
+	cfg.AdminConfig.Token = "s3cret"
+	cfg.WebhookConfig.URLs = []string{"https://hooks.slack.com/services/T000/B000/s3cret", "https://crm.example.com"}
+	cfg.AnomalyConfig.WebhookURL = "https://events.pagerduty.com/integration/s3cret/enqueue?token=s3cret"
+
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{
+		Config:   cfg,
+		Tunables: func() config.Tunables { return config.Tunables{LogLevel: "debug"} },
+	})
+
+	req := httptest.NewRequest("GET", "/v1/admin/config", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cret")
+
+	var response configSnapshot
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 8080, response.Config.GeneralConfig.Port)
+	assert.Equal(t, "adbeacon", response.Config.DatabaseConfig.User)
+	assert.Equal(t, "********", response.Config.DatabaseConfig.Password)
+	assert.Equal(t, "********", response.Config.AdminConfig.Token)
+	// Unset secrets stay empty so operators can tell them apart
+	assert.Empty(t, response.Config.CacheConfig.RedisPassword)
+	assert.Equal(t, []string{"3f2a9c1b7e4d:********"}, response.Config.SigningConfig.Secrets)
+	// Webhook URLs keep their host only
+	assert.Equal(t, []string{"https://hooks.slack.com/********", "https://crm.example.com"}, response.Config.WebhookConfig.URLs)
+	assert.Equal(t, "https://events.pagerduty.com/********", response.Config.AnomalyConfig.WebhookURL)
+	assert.Equal(t, "debug", response.Tunables.LogLevel)
+
+	// The loaded configuration itself is unchanged
+	assert.Equal(t, "s3cret", cfg.DatabaseConfig.Password)
+}
+
+func TestAdminEndpointsDisabledByDefault(t *testing.T) {
+	handler := NewHTTPHandler(endpoint.DeliveryEndpoints{}, log.NewNopLogger())
+
+	for _, path := range []string{"/v1/admin/logging", "/v1/admin/slo", "/v1/admin/config", "/v1/admin/campaigns", "/v1/admin/stats", "/v1/admin/overview"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
+
+func TestCampaignAdminEndpoints(t *testing.T) {
+	store := repository.NewMockRepository().(service.CampaignStore)
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Campaigns: store})
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	campaign := `{"cid":"netflix","name":"Netflix","img":"https://img","cta":"Watch","rules":[{"dimension":"country","rule_type":"include","values":["us"]}]}`
+	assert.Equal(t, http.StatusCreated, serve("POST", "/v1/admin/campaigns", campaign).Code)
+	assert.Equal(t, http.StatusConflict, serve("POST", "/v1/admin/campaigns", campaign).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/v1/admin/campaigns", `{"cid":"empty"}`).Code)
+
+	w := serve("POST", "/v1/admin/campaigns/netflix/pause", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"cid":"netflix","status":"INACTIVE"}`, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, serve("POST", "/v1/admin/campaigns/unknown/pause", "").Code)
+
+	w = serve("PUT", "/v1/admin/campaigns/netflix/traffic", `{"traffic_pct":10}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"cid":"netflix","traffic_pct":10}`, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, serve("PUT", "/v1/admin/campaigns/netflix/traffic", `{"traffic_pct":101}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve("PUT", "/v1/admin/campaigns/unknown/traffic", `{"traffic_pct":10}`).Code)
+
+	w = serve("PUT", "/v1/admin/campaigns/netflix/category", `{"category":" streaming "}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"cid":"netflix","category":"streaming"}`, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, serve("PUT", "/v1/admin/campaigns/netflix/category", `{"category":"`+strings.Repeat("x", 65)+`"}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve("PUT", "/v1/admin/campaigns/unknown/category", `{"category":"streaming"}`).Code)
+
+	w = serve("PUT", "/v1/admin/campaigns/netflix/image", `{"img":" https://img/v2 "}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"cid":"netflix","img":"https://img/v2"}`, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, serve("PUT", "/v1/admin/campaigns/unknown/image", `{"img":"https://img"}`).Code)
+
+	// Paused campaigns are still listed
+	w = serve("GET", "/v1/admin/campaigns", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var campaigns []models.CampaignWithRules
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &campaigns))
+	statuses := map[string]models.CampaignStatus{}
+	for _, c := range campaigns {
+		statuses[c.ID] = c.Status
+	}
+	assert.Equal(t, models.StatusInactive, statuses["netflix"])
+	assert.Equal(t, models.StatusActive, statuses["spotify"])
+	for _, c := range campaigns {
+		if c.ID == "netflix" {
+			assert.Equal(t, 10, c.TrafficPct)
+		}
+	}
+}
+
+func TestBulkStatusEndpoint(t *testing.T) {
+	store := repository.NewMockRepository().(service.CampaignStore)
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Campaigns: store})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "by ID", body: `{"status":"INACTIVE","cids":["spotify","duolingo"]}`, wantStatus: http.StatusOK, wantBody: `{"status":"INACTIVE","cids":["spotify","duolingo"]}`},
+		{name: "unknown ID", body: `{"status":"ACTIVE","cids":["spotify","unknown"]}`, wantStatus: http.StatusNotFound},
+		{name: "by filter", body: `{"status":"ACTIVE","filter":{"status":"INACTIVE"}}`, wantStatus: http.StatusOK, wantBody: `{"status":"ACTIVE","cids":["spotify","duolingo"]}`},
+		{name: "filter by name", body: `{"status":"INACTIVE","filter":{"name":"subway"}}`, wantStatus: http.StatusOK, wantBody: `{"status":"INACTIVE","cids":["subwaysurfer"]}`},
+		{name: "filter without matches", body: `{"status":"INACTIVE","filter":{"id_prefix":"netflix"}}`, wantStatus: http.StatusOK, wantBody: `{"status":"INACTIVE","cids":[]}`},
+		{name: "empty filter", body: `{"status":"INACTIVE","filter":{}}`, wantStatus: http.StatusBadRequest},
+		{name: "IDs and filter", body: `{"status":"INACTIVE","cids":["spotify"],"filter":{"id_prefix":"s"}}`, wantStatus: http.StatusBadRequest},
+		{name: "no campaigns", body: `{"status":"INACTIVE"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid status", body: `{"status":"PAUSED","cids":["spotify"]}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid body", body: `{`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v1/admin/campaigns:bulkStatus", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+
+	// The unknown ID changed nothing, the filters applied to the statuses left by earlier changes
+	campaigns, err := store.ListCampaigns(context.Background())
+	require.NoError(t, err)
+	statuses := map[string]models.CampaignStatus{}
+	for _, c := range campaigns {
+		statuses[c.ID] = c.Status
+	}
+	assert.Equal(t, map[string]models.CampaignStatus{"spotify": models.StatusActive, "duolingo": models.StatusActive, "subwaysurfer": models.StatusInactive}, statuses)
+}
+
+func TestCampaignLimits(t *testing.T) {
+	var exceeded []string
+	limits := service.CampaignLimits{MaxActiveCampaigns: 3, MaxRulesPerCampaign: 2, MaxValuesPerRule: 3}
+	store := service.NewLimitedStore(repository.NewMockRepository().(service.CampaignStore), limits, func(err *service.LimitError) {
+		exceeded = append(exceeded, err.Limit)
+	})
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Campaigns: store})
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// The three sample campaigns are active
+	w := serve("POST", "/v1/admin/campaigns", `{"cid":"netflix","name":"Netflix","img":"https://img","cta":"Watch"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"4 active campaigns, more than the limit of 3"}`, w.Body.String())
+	require.Equal(t, http.StatusCreated, serve("POST", "/v1/admin/campaigns", `{"cid":"netflix","name":"Netflix","img":"https://img","cta":"Watch","status":"INACTIVE"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, serve("POST", "/v1/admin/campaigns/netflix/resume", "").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, serve("POST", "/v1/admin/campaigns:bulkStatus", `{"status":"ACTIVE","cids":["netflix","spotify"]}`).Code)
+
+	// Resuming active campaigns is always fine, room has to be made for others
+	assert.Equal(t, http.StatusOK, serve("POST", "/v1/admin/campaigns/spotify/resume", "").Code)
+	require.Equal(t, http.StatusOK, serve("POST", "/v1/admin/campaigns/spotify/pause", "").Code)
+	assert.Equal(t, http.StatusOK, serve("POST", "/v1/admin/campaigns/netflix/resume", "").Code)
+
+	w = serve("PUT", "/v1/admin/campaigns/netflix/rules", `{"rules":[{"dimension":"country","rule_type":"include","values":["US","IN","BR","MX"]}]}`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"rule 0 of campaign netflix has 4 values, more than the limit of 3 per rule"}`, w.Body.String())
+	w = serve("PUT", "/v1/admin/campaigns/netflix/rules", `{"targeting":"country in (US) and os in (android) and app in (com.example)"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"campaign netflix has 3 rules, more than the limit of 2 per campaign"}`, w.Body.String())
+	assert.Equal(t, http.StatusOK, serve("PUT", "/v1/admin/campaigns/netflix/rules", `{"rules":[{"dimension":"country","rule_type":"include","values":["US","IN"]}]}`).Code)
+
+	assert.Equal(t, []string{
+		service.LimitActiveCampaigns, service.LimitActiveCampaigns, service.LimitActiveCampaigns,
+		service.LimitValuesPerRule, service.LimitRulesPerCampaign,
+	}, exceeded)
+}
+
+func TestRuleVersionEndpoints(t *testing.T) {
+	store := repository.NewMockRepository().(service.CampaignStore)
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Campaigns: store})
+
+	serve := func(method, path, body, author string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if author != "" {
+			req.Header.Set("X-Admin-User", author)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Replace the geo list of spotify, version 1 includes US and Canada
+	w := serve("PUT", "/v1/admin/campaigns/spotify/rules", `{"rules":[{"dimension":"country","rule_type":"include","values":["US","IN"]}],"comment":"add India"}`, "alice")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var changed ruleVersion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &changed))
+	assert.Equal(t, 2, changed.Version)
+	assert.Equal(t, "alice", changed.Author)
+	assert.Equal(t, "add India", changed.Comment)
+	assert.Equal(t, 1, changed.Against)
+	assert.Equal(t, []models.RuleChange{{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Added: []string{"IN"}, Removed: []string{"Canada"}}}, changed.Changes)
+
+	w = serve("PUT", "/v1/admin/campaigns/spotify/rules", `{"rules":[{"dimension":"planet","rule_type":"include","values":["mars"]}]}`, "alice")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve("PUT", "/v1/admin/campaigns/unknown/rules", `{"rules":[]}`, "alice")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve("PUT", "/v1/admin/campaigns/spotify/rules", `{"targeting":"country in (US, IN"}`, "alice")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Roll back to version 1, recorded as version 3
+	w = serve("POST", "/v1/admin/campaigns/spotify/rules/versions/1/rollback", "", "bob")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var rolledBack ruleVersion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rolledBack))
+	assert.Equal(t, 3, rolledBack.Version)
+	assert.Equal(t, "bob", rolledBack.Author)
+	assert.Equal(t, "rollback to version 1", rolledBack.Comment)
+	assert.Equal(t, []models.RuleChange{{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Added: []string{"Canada"}, Removed: []string{"IN"}}}, rolledBack.Changes)
+
+	campaigns, err := store.ListCampaigns(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"US", "Canada"}, campaigns[0].Rules[0].Values)
+
+	// The history is newest first, each version diffed against the previous one
+	w = serve("GET", "/v1/admin/campaigns/spotify/rules/versions", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []ruleVersion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history, 3)
+	assert.Equal(t, []int{3, 2, 1}, []int{history[0].Version, history[1].Version, history[2].Version})
+	assert.Equal(t, 0, history[2].Against)
+	assert.Equal(t, []models.RuleChange{{Dimension: models.DimensionCountry, RuleType: models.RuleTypeInclude, Added: []string{"Canada", "US"}}}, history[2].Changes)
+
+	// Versions can be diffed against any other
+	w = serve("GET", "/v1/admin/campaigns/spotify/rules/versions/3?against=1", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var diffed ruleVersion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &diffed))
+	assert.Equal(t, 1, diffed.Against)
+	assert.Empty(t, diffed.Changes)
+
+	for _, tt := range []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{"GET", "/v1/admin/campaigns/spotify/rules/versions/9", http.StatusNotFound},
+		{"GET", "/v1/admin/campaigns/spotify/rules/versions/2?against=9", http.StatusNotFound},
+		{"GET", "/v1/admin/campaigns/spotify/rules/versions/2?against=x", http.StatusBadRequest},
+		{"GET", "/v1/admin/campaigns/spotify/rules/versions/0", http.StatusBadRequest},
+		{"GET", "/v1/admin/campaigns/unknown/rules/versions", http.StatusNotFound},
+		{"POST", "/v1/admin/campaigns/spotify/rules/versions/9/rollback", http.StatusNotFound},
+	} {
+		assert.Equal(t, tt.wantStatus, serve(tt.method, tt.path, "", "").Code, tt.path)
+	}
+}
+
+func TestCampaignReachEndpoint(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	estimator := reach.NewEstimator(client, "adbeacon:", 7)
+	store := repository.NewMockRepository().(service.CampaignStore)
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Campaigns: store, Reach: estimator})
+
+	for i := 0; i < 4; i++ {
+		estimator.RecordRequest(models.DeliveryRequest{Country: "us", OS: "android", App: "a"})
+	}
+	estimator.RecordRequest(models.DeliveryRequest{Country: "in", OS: "ios", App: "a"})
+	require.NoError(t, estimator.Flush(context.Background()))
+
+	req := httptest.NewRequest("GET", "/v1/admin/campaigns/spotify/reach", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var estimate reach.Estimate
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &estimate))
+	assert.Equal(t, "spotify", estimate.CampaignID)
+	assert.Equal(t, 0.8, estimate.MatchRate)
+	assert.Equal(t, []reach.DimensionEstimate{{Dimension: "country", MatchRate: 0.8}}, estimate.Dimensions)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/admin/campaigns/unknown/reach", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Redis being unavailable is reported, not answered with zeros
+	server.Close()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestCampaignStatsEndpoint(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	tracker := campaignstats.NewTracker(client, "adbeacon:")
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{CampaignStats: tracker})
+
+	tracker.RecordDeliveries([]string{"spotify", "spotify", "duolingo"})
+	require.NoError(t, tracker.Flush(context.Background()))
+
+	req := httptest.NewRequest("GET", "/v1/admin/campaigns/spotify/stats", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats campaignstats.Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, "spotify", stats.CampaignID)
+	assert.Equal(t, map[string]int64{"1m": 2, "1h": 2, "24h": 2}, stats.Deliveries)
+
+	// Redis being unavailable is reported, not answered with zeros
+	server.Close()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestOverviewEndpoint(t *testing.T) {
+	store := repository.NewMockRepository().(service.CampaignStore)
+	builder := overview.NewBuilder(overview.Sources{
+		Campaigns:     store,
+		DeliveryStats: func() metrics.DeliveryStats { return metrics.DeliveryStats{Requests: 10, Filled: 7, FillRate: 0.7} },
+	})
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Overview: builder})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := serve("/v1/admin/overview?expiring_within=48h&top=3")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body overview.Overview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	campaigns, err := store.ListCampaigns(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, body.Campaigns)
+	assert.Equal(t, len(campaigns), body.Campaigns.Total)
+	assert.Equal(t, &metrics.DeliveryStats{Requests: 10, Filled: 7, FillRate: 0.7}, body.Delivery)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/v1/admin/overview?expiring_within=tomorrow").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/v1/admin/overview?top=0").Code)
+}
+
+func TestRulesValidationEndpoint(t *testing.T) {
+	handler := NewHTTPHandler(endpoint.DeliveryEndpoints{}, log.NewNopLogger())
+
+	tests := []struct {
+		name         string
+		body         string
+		wantValid    bool
+		wantWarnings int
+	}{
+		{name: "valid", body: `{"cid":"c","name":"C","rules":[{"dimension":"os","rule_type":"include","values":["android"]}]}`, wantValid: true},
+		{
+			name:         "conflicting rules",
+			body:         `{"cid":"c","name":"C","status":"ACTIVE","rules":[{"dimension":"country","rule_type":"include","values":["us"]},{"dimension":"country","rule_type":"exclude","values":["USA"]}]}`,
+			wantValid:    true,
+			wantWarnings: 2,
+		},
+		{name: "unknown dimension", body: `{"cid":"c","name":"C","rules":[{"dimension":"planet","rule_type":"include","values":["mars"]}]}`, wantValid: false},
+		{name: "state without country", body: `{"cid":"c","name":"C","rules":[{"dimension":"state","rule_type":"include","values":["us-ca"]}]}`, wantValid: false},
+		{name: "targeting expression", body: `{"cid":"c","name":"C","targeting":"country in (us, ca) and os != ios"}`, wantValid: true},
+		{name: "invalid targeting values", body: `{"cid":"c","name":"C","targeting":"planet = mars"}`, wantValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v1/admin/rules/validate", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			var response ruleValidation
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.wantValid, response.Valid, response.Errors)
+			assert.Len(t, response.Warnings, tt.wantWarnings)
+		})
+	}
+
+	// Expressions that don't parse and rules given twice are rejected outright
+	for _, body := range []string{
+		`{"cid":"c","name":"C","targeting":"country in (us"}`,
+		`{"cid":"c","name":"C","targeting":"os = ios","rules":[{"dimension":"os","rule_type":"include","values":["ios"]}]}`,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/v1/admin/rules/validate", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/v1/admin/rules/validate", strings.NewReader(`{"targeting":"os in ios"}`)))
+	assert.JSONEq(t, `{"error":"invalid targeting: offset 6: expected \"(\" starting the list of values"}`, w.Body.String())
+}
+
+func TestSimulationEndpoint(t *testing.T) {
+	handler := NewHTTPHandler(endpoint.DeliveryEndpoints{}, log.NewNopLogger())
+	campaign := `{"rules":[{"dimension":"country","rule_type":"include","values":["us"]},{"dimension":"os","rule_type":"include","values":["android"]}]}`
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantMatched int
+	}{
+		{
+			name:        "given requests",
+			body:        `{"campaign":` + campaign + `,"requests":[{"country":"us","os":"android","app":"a"},{"country":"us","os":"ios","app":"a"},{"country":"in","os":"android","app":"a"}]}`,
+			wantStatus:  http.StatusOK,
+			wantMatched: 1,
+		},
+		{
+			name:        "synthetic requests",
+			body:        `{"campaign":` + campaign + `,"synthetic":{"count":100,"countries":"us","os":"android"}}`,
+			wantStatus:  http.StatusOK,
+			wantMatched: 100,
+		},
+		{name: "no requests", body: `{"campaign":` + campaign + `}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid synthetic", body: `{"campaign":` + campaign + `,"synthetic":{"count":0}}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid rules", body: `{"campaign":{"rules":[{"dimension":"planet","rule_type":"include","values":["mars"]}]},"synthetic":{"count":10}}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v1/admin/simulate", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var result simulation.Result
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.Equal(t, tt.wantMatched, result.Matched)
+			assert.Len(t, result.Dimensions, 2)
+		})
+	}
+}
+
+func TestBlocklistEndpoints(t *testing.T) {
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{
+		Blocklist: blocklist.New(blocklist.NewMemoryStore()),
+	})
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	// Values are normalized like delivery requests
+	w := serve("POST", "/v1/admin/blocklist", `{"kind":"country","value":"DEU","comment":"sanctions"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var added blocklist.Entry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &added))
+	assert.Equal(t, "de", added.Value)
+	assert.Equal(t, "sanctions", added.Comment)
+
+	assert.Equal(t, http.StatusConflict, serve("POST", "/v1/admin/blocklist", `{"kind":"country","value":"de"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/v1/admin/blocklist", `{"kind":"ip_range","value":"10.0.0/8"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/v1/admin/blocklist", `{`).Code)
+	require.Equal(t, http.StatusCreated, serve("POST", "/v1/admin/blocklist", `{"kind":"ip_range","value":"10.1.2.3/8"}`).Code)
+
+	w = serve("GET", "/v1/admin/blocklist", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var entries []blocklist.Entry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	require.Len(t, entries, 2)
+	assert.Equal(t, "10.0.0.0/8", entries[1].Value)
+
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", fmt.Sprintf("/v1/admin/blocklist/%d", added.ID), "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("DELETE", fmt.Sprintf("/v1/admin/blocklist/%d", added.ID), "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("DELETE", "/v1/admin/blocklist/abc", "").Code)
+}
+
+func TestBundleEndpoints(t *testing.T) {
+	store := repository.NewMockRepository().(service.CampaignStore)
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Campaigns: store})
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(adminUserHeader, "alice")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("GET", "/v1/admin/campaigns:export", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var exported struct {
+		Version   int                        `json:"version"`
+		Campaigns []models.CampaignWithRules `json:"campaigns"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &exported))
+	assert.Equal(t, 1, exported.Version)
+	assert.Len(t, exported.Campaigns, 3)
+
+	netflix := `{"version":1,"campaigns":[{"cid":"netflix","name":"Netflix","cta":"Watch","status":"ACTIVE",` +
+		`"rules":[{"dimension":"country","rule_type":"include","values":["US"]}]}]}`
+
+	// A dry run only reports the changes
+	w = serve("POST", "/v1/admin/campaigns:import?dry_run=true", netflix)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"create":["netflix"],"update":[],"unchanged":0,"extra":["duolingo","spotify","subwaysurfer"]}`, w.Body.String())
+	campaigns, err := store.ListCampaigns(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 3)
+
+	w = serve("POST", "/v1/admin/campaigns:import", netflix)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	campaigns, err = store.ListCampaigns(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 4)
+
+	w = serve("POST", "/v1/admin/campaigns:import", strings.Replace(netflix, `"Netflix"`, `"Netflix Originals"`, 1))
+	require.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"conflicts":[{"cid":"netflix","fields":["name"]}]`)
+
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/v1/admin/campaigns:import", `{"version":2,"campaigns":[]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/v1/admin/campaigns:import", `{"version":1,"campaigns":[{"name":"No ID"}]}`).Code)
+	w = serve("POST", "/v1/admin/campaigns:import", strings.Replace(strings.Replace(netflix, `"US"`, `"Canada"`, 1), "netflix", "hulu", 1))
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"invalid":[{"cid":"hulu"`)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/v1/admin/campaigns:import?dry_run=maybe", netflix).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/v1/admin/campaigns:import", `{`).Code)
+}
@@ -0,0 +1,80 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/blocklist"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// createListBlocklistHandler creates a handler listing the blocklist entries
+func createListBlocklistHandler(list *blocklist.Blocklist) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries, err := list.List(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+		if entries == nil {
+			entries = []blocklist.Entry{}
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+// createAddBlocklistEntryHandler creates a handler blocking an app, country or IP range for every
+// campaign. The cache is invalidated afterwards so the other replicas reload the blocklist.
+func createAddBlocklistEntryHandler(list *blocklist.Blocklist, c cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var entry blocklist.Entry
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid request body"))
+			return
+		}
+
+		stored, err := list.Add(r.Context(), entry)
+		switch {
+		case errors.Is(err, blocklist.ErrInvalidEntry):
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+			return
+		case errors.Is(err, blocklist.ErrEntryExists):
+			writeJSON(w, http.StatusConflict, models.NewErrorResponse(err.Error()))
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		invalidateCache(r.Context(), c)
+		writeJSON(w, http.StatusCreated, stored)
+	}
+}
+
+// createRemoveBlocklistEntryHandler creates a handler removing the blocklist entry {id}
+func createRemoveBlocklistEntryHandler(list *blocklist.Blocklist, c cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid blocklist entry id"))
+			return
+		}
+
+		err = list.Remove(r.Context(), id)
+		switch {
+		case errors.Is(err, blocklist.ErrEntryNotFound):
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse(err.Error()))
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		invalidateCache(r.Context(), c)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
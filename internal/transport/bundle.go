@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/bundle"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/creative"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// createExportHandler creates a handler writing every campaign with its rules as a bundle
+func createExportHandler(store service.CampaignStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		exported, err := bundle.Export(r.Context(), store, time.Now())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, exported)
+	}
+}
+
+// createImportHandler creates a handler importing a bundle, answering with what it changed. With
+// dry_run=true nothing is changed. A bundle conflicting with the stored campaigns is refused with
+// 409 and one creating or updating invalid campaigns with 422, both with the diff naming them.
+// Exceeding the campaign limits stops the import with 422, the campaigns before are imported.
+// Rule changes are recorded as new versions by the user in the X-Admin-User header, the cache is
+// invalidated once afterwards. With a validator the images of created campaigns and the changed
+// images of updated ones are checked and rewritten to the CDN before anything is imported, those
+// failing make their campaigns invalid. Dry runs don't fetch images.
+func createImportHandler(store service.CampaignStore, c cache.Cache, validator *creative.Validator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body bundle.Bundle
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid request body"))
+			return
+		}
+		dryRun, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		if err != nil && r.URL.Query().Has("dry_run") {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("dry_run must be a boolean"))
+			return
+		}
+		if problems := body.Validate(); len(problems) > 0 {
+			messages := make([]string, len(problems))
+			for i, problem := range problems {
+				messages[i] = problem.Error()
+			}
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid bundle: "+strings.Join(messages, "; ")))
+			return
+		}
+
+		if dryRun {
+			diff, err := bundle.Plan(r.Context(), store, body)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+				return
+			}
+			writeJSON(w, http.StatusOK, diff)
+			return
+		}
+
+		var prepare bundle.PrepareImage
+		if validator != nil {
+			prepare = validator.Prepare
+		}
+		diff, err := bundle.Import(r.Context(), store, body, r.Header.Get(adminUserHeader), prepare)
+		switch {
+		case errors.Is(err, bundle.ErrConflicts):
+			writeJSON(w, http.StatusConflict, diff)
+			return
+		case errors.Is(err, bundle.ErrInvalid):
+			writeJSON(w, http.StatusUnprocessableEntity, diff)
+			return
+		case err != nil:
+			// Part of the bundle may be imported already
+			invalidateCache(r.Context(), c)
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrLimitExceeded) {
+				status = http.StatusUnprocessableEntity
+			}
+			writeJSON(w, status, models.NewErrorResponse(err.Error()))
+			return
+		}
+		invalidateCache(r.Context(), c)
+		writeJSON(w, http.StatusOK, diff)
+	}
+}
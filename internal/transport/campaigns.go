@@ -0,0 +1,567 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/campaignstats"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/creative"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reach"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/simulation"
+)
+
+// ruleValidation is the response body of the /v1/admin/rules/validate endpoint, warnings are
+// rule conflicts that don't make the campaign invalid
+type ruleValidation struct {
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// campaignStatus is the response body of the campaign pause and resume endpoints
+type campaignStatus struct {
+	CID    string                `json:"cid"`
+	Status models.CampaignStatus `json:"status"`
+}
+
+// campaignTraffic is the request and response body of the /v1/admin/campaigns/{id}/traffic endpoint
+type campaignTraffic struct {
+	CID        string `json:"cid"`
+	TrafficPct int    `json:"traffic_pct"`
+}
+
+// campaignCategory is the request and response body of the /v1/admin/campaigns/{id}/category endpoint
+type campaignCategory struct {
+	CID      string `json:"cid"`
+	Category string `json:"category"`
+}
+
+// campaignImage is the request and response body of the /v1/admin/campaigns/{id}/image endpoint
+type campaignImage struct {
+	CID string `json:"cid"`
+	Img string `json:"img"`
+}
+
+// campaignCreatives is the request and response body of the /v1/admin/campaigns/{id}/creatives endpoint
+type campaignCreatives struct {
+	CID       string            `json:"cid"`
+	Creatives []models.Creative `json:"creatives"`
+}
+
+// bulkStatusRequest is the request body of the /v1/admin/campaigns:bulkStatus endpoint, naming the
+// campaigns either by ID or with a filter
+type bulkStatusRequest struct {
+	Status models.CampaignStatus   `json:"status"`
+	CIDs   []string                `json:"cids,omitempty"`
+	Filter *service.CampaignFilter `json:"filter,omitempty"`
+}
+
+// bulkStatus is the response body of the /v1/admin/campaigns:bulkStatus endpoint
+type bulkStatus struct {
+	Status models.CampaignStatus `json:"status"`
+	CIDs   []string              `json:"cids"`
+}
+
+// errInvalidBody is returned when a request body isn't the expected JSON
+var errInvalidBody = errors.New("invalid request body")
+
+// campaignRequest is a campaign in a request body, its rules are given either as rules or as a
+// targeting expression, see models.ParseRules
+type campaignRequest struct {
+	models.CampaignWithRules
+	Targeting string `json:"targeting,omitempty"`
+}
+
+// parseTargeting returns rules, or the rules of the targeting expression when there is one
+func parseTargeting(rules []models.TargetingRule, targeting string) ([]models.TargetingRule, error) {
+	if targeting == "" {
+		return rules, nil
+	}
+	if len(rules) > 0 {
+		return nil, errors.New("rules and targeting are mutually exclusive")
+	}
+	rules, err := models.ParseRules(targeting)
+	if err != nil {
+		return nil, fmt.Errorf("invalid targeting: %w", err)
+	}
+	return rules, nil
+}
+
+// decodeCampaign decodes a campaign from the request body, campaigns without a status are active.
+// The error is either errInvalidBody or describes an invalid targeting expression.
+func decodeCampaign(r *http.Request) (models.CampaignWithRules, error) {
+	var body campaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return body.CampaignWithRules, errInvalidBody
+	}
+	return body.campaign()
+}
+
+// campaign returns the campaign with the rules of its targeting expression, pointing at it
+func (c campaignRequest) campaign() (models.CampaignWithRules, error) {
+	campaign := c.CampaignWithRules
+	var err error
+	if campaign.Rules, err = parseTargeting(campaign.Rules, c.Targeting); err != nil {
+		return campaign, err
+	}
+	if campaign.Status == "" {
+		campaign.Status = models.StatusActive
+	}
+	for i := range campaign.Rules {
+		campaign.Rules[i].CampaignID = campaign.ID
+	}
+	return campaign, nil
+}
+
+// validationMessages returns the messages of the problems found in campaign
+func validationMessages(campaign models.CampaignWithRules) []string {
+	var messages []string
+	for _, err := range campaign.Validate() {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+// createRulesValidationHandler creates a handler checking a campaign and its targeting rules without storing it
+func createRulesValidationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		campaign, err := decodeCampaign(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		messages := validationMessages(campaign)
+		var warnings []string
+		for _, conflict := range campaign.RuleConflicts() {
+			warnings = append(warnings, conflict.Error())
+		}
+		writeJSON(w, http.StatusOK, ruleValidation{Valid: len(messages) == 0, Errors: messages, Warnings: warnings})
+	}
+}
+
+// simulationRequest is the request body of the /v1/admin/simulate endpoint, the campaign is
+// matched against either the given requests or synthetic ones
+type simulationRequest struct {
+	Campaign  campaignRequest             `json:"campaign"`
+	Requests  []models.DeliveryRequest    `json:"requests,omitempty"`
+	Synthetic *simulation.SyntheticConfig `json:"synthetic,omitempty"`
+}
+
+// createSimulationHandler creates a handler estimating how much of a request sample a proposed
+// campaign would match, overall and per dimension, without storing it
+func createSimulationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body simulationRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid request body"))
+			return
+		}
+
+		// Only the targeting rules matter, the campaign does not need an ID or name
+		campaign, err := body.Campaign.campaign()
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+			return
+		}
+		var problems []string
+		for _, err := range campaign.ValidateTargeting() {
+			problems = append(problems, err.Error())
+		}
+		if len(problems) > 0 {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid campaign: "+strings.Join(problems, "; ")))
+			return
+		}
+
+		requests := body.Requests
+		switch {
+		case body.Synthetic != nil && len(requests) > 0:
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("requests and synthetic are mutually exclusive"))
+			return
+		case body.Synthetic != nil:
+			if requests, err = simulation.SyntheticRequests(*body.Synthetic); err != nil {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid synthetic traffic: "+err.Error()))
+				return
+			}
+		case len(requests) == 0:
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("requests or synthetic is required"))
+			return
+		case len(requests) > simulation.MaxSyntheticRequests:
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(fmt.Sprintf("at most %d requests can be simulated", simulation.MaxSyntheticRequests)))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, simulation.Run(campaign, requests))
+	}
+}
+
+// createListCampaignsHandler creates a handler listing all campaigns, including inactive ones
+func createListCampaignsHandler(store service.CampaignStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		campaigns, err := store.ListCampaigns(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+		if campaigns == nil {
+			campaigns = []models.CampaignWithRules{}
+		}
+		writeJSON(w, http.StatusOK, campaigns)
+	}
+}
+
+// createCreateCampaignHandler creates a handler storing a new campaign, the cache is invalidated
+// afterwards so deliveries pick it up without waiting for the cache TTL. With a validator the
+// images, the campaign's and those of its creatives, are checked and stored rewritten to the CDN.
+func createCreateCampaignHandler(store service.CampaignStore, c cache.Cache, validator *creative.Validator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		campaign, err := decodeCampaign(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+			return
+		}
+		if messages := validationMessages(campaign); len(messages) > 0 {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid campaign: "+strings.Join(messages, "; ")))
+			return
+		}
+		if validator != nil {
+			if campaign.ImageURL, err = validator.Prepare(r.Context(), campaign.ImageURL); err != nil {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+				return
+			}
+			if err = prepareCreatives(r.Context(), validator, campaign.Creatives); err != nil {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+				return
+			}
+		}
+
+		switch err := store.CreateCampaign(r.Context(), campaign); {
+		case errors.Is(err, service.ErrCampaignExists):
+			writeJSON(w, http.StatusConflict, models.NewErrorResponse(err.Error()))
+			return
+		case errors.Is(err, service.ErrLimitExceeded):
+			writeJSON(w, http.StatusUnprocessableEntity, models.NewErrorResponse(err.Error()))
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		invalidateCache(r.Context(), c)
+		writeJSON(w, http.StatusCreated, campaign)
+	}
+}
+
+// createCampaignStatusHandler creates a handler setting the status of the campaign named in the path,
+// the cache is invalidated afterwards so deliveries see the change without waiting for the cache TTL
+func createCampaignStatusHandler(store service.CampaignStore, c cache.Cache, status models.CampaignStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		switch err := store.SetCampaignStatus(r.Context(), id, status); {
+		case errors.Is(err, service.ErrCampaignNotFound):
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse(err.Error()))
+			return
+		case errors.Is(err, service.ErrLimitExceeded):
+			writeJSON(w, http.StatusUnprocessableEntity, models.NewErrorResponse(err.Error()))
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		invalidateCache(r.Context(), c)
+		writeJSON(w, http.StatusOK, campaignStatus{CID: id, Status: status})
+	}
+}
+
+// createCampaignTrafficHandler creates a handler changing the traffic percentage of the campaign
+// named in the path, to widen or narrow its staged rollout. The cache is invalidated afterwards.
+func createCampaignTrafficHandler(store service.CampaignStore, c cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body campaignTraffic
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid request body"))
+			return
+		}
+		if body.TrafficPct < 0 || body.TrafficPct > 100 {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(fmt.Sprintf("traffic_pct must be between 0 and 100, got %d", body.TrafficPct)))
+			return
+		}
+
+		id := mux.Vars(r)["id"]
+		switch err := store.SetCampaignTrafficPct(r.Context(), id, body.TrafficPct); {
+		case errors.Is(err, service.ErrCampaignNotFound):
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse(err.Error()))
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		invalidateCache(r.Context(), c)
+		writeJSON(w, http.StatusOK, campaignTraffic{CID: id, TrafficPct: body.TrafficPct})
+	}
+}
+
+// createCampaignCategoryHandler creates a handler changing the competitive category of the
+// campaign named in the path, empty for none. The cache is invalidated afterwards.
+func createCampaignCategoryHandler(store service.CampaignStore, c cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body campaignCategory
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid request body"))
+			return
+		}
+		body.Category = strings.TrimSpace(body.Category)
+		if len(body.Category) > models.MaxCategoryLength {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(fmt.Sprintf("category must be at most %d characters, got %d", models.MaxCategoryLength, len(body.Category))))
+			return
+		}
+
+		id := mux.Vars(r)["id"]
+		switch err := store.SetCampaignCategory(r.Context(), id, body.Category); {
+		case errors.Is(err, service.ErrCampaignNotFound):
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse(err.Error()))
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		invalidateCache(r.Context(), c)
+		writeJSON(w, http.StatusOK, campaignCategory{CID: id, Category: body.Category})
+	}
+}
+
+// createCampaignImageHandler creates a handler changing the image URL of the campaign named in
+// the path, empty for none. With a validator the image is checked and stored rewritten to the
+// CDN. The cache is invalidated afterwards.
+func createCampaignImageHandler(store service.CampaignStore, c cache.Cache, validator *creative.Validator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body campaignImage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid request body"))
+			return
+		}
+		body.Img = strings.TrimSpace(body.Img)
+		if validator != nil {
+			var err error
+			if body.Img, err = validator.Prepare(r.Context(), body.Img); err != nil {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+				return
+			}
+		}
+
+		id := mux.Vars(r)["id"]
+		switch err := store.SetCampaignImage(r.Context(), id, body.Img); {
+		case errors.Is(err, service.ErrCampaignNotFound):
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse(err.Error()))
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		invalidateCache(r.Context(), c)
+		writeJSON(w, http.StatusOK, campaignImage{CID: id, Img: body.Img})
+	}
+}
+
+// createCampaignCreativesHandler creates a handler replacing the localized creatives of the
+// campaign named in the path, none to serve its own image and CTA in every language. With a
+// validator the images are checked and stored rewritten to the CDN. The cache is invalidated
+// afterwards.
+func createCampaignCreativesHandler(store service.CampaignStore, c cache.Cache, validator *creative.Validator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body campaignCreatives
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid request body"))
+			return
+		}
+		for i := range body.Creatives {
+			body.Creatives[i].Lang = strings.TrimSpace(body.Creatives[i].Lang)
+			body.Creatives[i].Img = strings.TrimSpace(body.Creatives[i].Img)
+		}
+		if errs := models.ValidateCreatives(body.Creatives); len(errs) > 0 {
+			messages := make([]string, len(errs))
+			for i, err := range errs {
+				messages[i] = err.Error()
+			}
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid creatives: "+strings.Join(messages, "; ")))
+			return
+		}
+		if validator != nil {
+			if err := prepareCreatives(r.Context(), validator, body.Creatives); err != nil {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+				return
+			}
+		}
+
+		id := mux.Vars(r)["id"]
+		switch err := store.SetCampaignCreatives(r.Context(), id, body.Creatives); {
+		case errors.Is(err, service.ErrCampaignNotFound):
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse(err.Error()))
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		if body.Creatives == nil {
+			body.Creatives = []models.Creative{}
+		}
+		invalidateCache(r.Context(), c)
+		writeJSON(w, http.StatusOK, campaignCreatives{CID: id, Creatives: body.Creatives})
+	}
+}
+
+// prepareCreatives checks the images of creatives with the validator, rewriting them to the CDN
+func prepareCreatives(ctx context.Context, validator *creative.Validator, creatives []models.Creative) error {
+	for i := range creatives {
+		img, err := validator.Prepare(ctx, creatives[i].Img)
+		if err != nil {
+			return fmt.Errorf("creative %d: %w", i, err)
+		}
+		creatives[i].Img = img
+	}
+	return nil
+}
+
+// createBulkStatusHandler creates a handler setting the status of the listed campaigns, or of all
+// campaigns matching a filter, at once. Either every campaign changes or none does, and the cache
+// is invalidated once afterwards.
+func createBulkStatusHandler(store service.CampaignStore, c cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body bulkStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid request body"))
+			return
+		}
+
+		switch {
+		case body.Status != models.StatusActive && body.Status != models.StatusInactive:
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(fmt.Sprintf("status must be %s or %s", models.StatusActive, models.StatusInactive)))
+			return
+		case len(body.CIDs) > 0 && body.Filter != nil:
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("cids and filter are mutually exclusive"))
+			return
+		case len(body.CIDs) == 0 && body.Filter == nil:
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("cids or filter is required"))
+			return
+		case body.Filter != nil && body.Filter.IsEmpty():
+			// Guards against changing every campaign by mistake
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("filter must set at least one field"))
+			return
+		}
+
+		ids := body.CIDs
+		if body.Filter != nil {
+			campaigns, err := store.ListCampaigns(r.Context())
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+				return
+			}
+			ids = []string{}
+			for _, campaign := range campaigns {
+				if body.Filter.Matches(campaign.Campaign) {
+					ids = append(ids, campaign.ID)
+				}
+			}
+		}
+		if len(ids) == 0 {
+			writeJSON(w, http.StatusOK, bulkStatus{Status: body.Status, CIDs: ids})
+			return
+		}
+
+		switch err := store.SetCampaignStatuses(r.Context(), ids, body.Status); {
+		case errors.Is(err, service.ErrCampaignNotFound):
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse(err.Error()))
+			return
+		case errors.Is(err, service.ErrLimitExceeded):
+			writeJSON(w, http.StatusUnprocessableEntity, models.NewErrorResponse(err.Error()))
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		invalidateCache(r.Context(), c)
+		writeJSON(w, http.StatusOK, bulkStatus{Status: body.Status, CIDs: ids})
+	}
+}
+
+// createCacheInvalidationHandler creates a handler clearing all cached campaigns and indexes
+func createCacheInvalidationHandler(c cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := c.InvalidateAll(r.Context()); err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// createDeliveryStatsHandler creates a handler reporting the delivery totals since startup
+func createDeliveryStatsHandler(stats func() metrics.DeliveryStats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, stats())
+	}
+}
+
+// createCampaignStatsHandler creates a handler reporting the deliveries of the campaign named in
+// the path over the rolling windows, campaigns without deliveries report zeros
+func createCampaignStatsHandler(tracker *campaignstats.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := tracker.Get(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, models.NewErrorResponse(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, stats)
+	}
+}
+
+// createCampaignReachHandler creates a handler estimating the daily reach of the campaign named in
+// the path, whatever its status
+func createCampaignReachHandler(store service.CampaignStore, estimator *reach.Estimator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		campaigns, err := store.ListCampaigns(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+		for _, campaign := range campaigns {
+			if campaign.ID != id {
+				continue
+			}
+			estimate, err := estimator.Estimate(r.Context(), campaign)
+			if err != nil {
+				writeJSON(w, http.StatusServiceUnavailable, models.NewErrorResponse(err.Error()))
+				return
+			}
+			writeJSON(w, http.StatusOK, estimate)
+			return
+		}
+		writeJSON(w, http.StatusNotFound, models.NewErrorResponse(service.ErrCampaignNotFound.Error()))
+	}
+}
+
+// invalidateCache clears the cache after a campaign change, if there is one. A failure only
+// delays the change until the cached entries expire, so it does not fail the request.
+func invalidateCache(ctx context.Context, c cache.Cache) {
+	if c != nil {
+		c.InvalidateAll(ctx)
+	}
+}
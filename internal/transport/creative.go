@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/creative"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+)
+
+// createCreativeHandler creates a handler serving the image of a campaign through the creative
+// proxy, the localized one for the lang parameter, answering 304 to requests whose If-None-Match
+// lists the current ETag
+func createCreativeHandler(proxy *creative.Proxy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lang := models.NormalizeLanguage(r.URL.Query().Get("lang"))
+		image, err := proxy.Get(r.Context(), mux.Vars(r)["id"], lang)
+		switch {
+		case errors.Is(err, creative.ErrNotFound):
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse("creative not found"))
+			return
+		case errors.Is(err, creative.ErrUpstream):
+			writeJSON(w, http.StatusBadGateway, models.NewErrorResponse(err.Error()))
+			return
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		w.Header().Set("ETag", image.ETag)
+		if image.NoStore {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(image.MaxAge.Seconds())))
+		}
+		if etagMatches(r.Header.Get("If-None-Match"), image.ETag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", image.ContentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(image.Body)))
+		// Browsers must not sniff a different type than the checked image type
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		w.Write(image.Body)
+	}
+}
+
+// createBrokenCreativesHandler creates a handler listing the active campaigns whose image failed
+// its last check
+func createBrokenCreativesHandler(checker *creative.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, checker.Broken())
+	}
+}
+
+// etagMatches reports whether an If-None-Match header lists etag or is *, weak validators
+// match their strong counterpart
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
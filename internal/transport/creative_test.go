@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/creative"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// creativeCampaigns is a creative.CampaignSource serving a fixed list of campaigns
+type creativeCampaigns []models.CampaignWithRules
+
+func (c creativeCampaigns) GetActiveCampaignsWithRules(context.Context) ([]models.CampaignWithRules, error) {
+	return c, nil
+}
+
+func TestCreativeEndpoint(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page.html" {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<script></script>"))
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "max-age=300")
+		w.Write([]byte("png-bytes"))
+	}))
+	defer upstream.Close()
+
+	proxy := creative.New(creativeCampaigns{
+		{Campaign: models.Campaign{ID: "spotify", ImageURL: upstream.URL + "/banner.png"}},
+		{Campaign: models.Campaign{ID: "html", ImageURL: upstream.URL + "/page.html"}},
+	}, creative.Config{CacheBytes: 1 << 20, MaxCreativeBytes: 1 << 10, DefaultTTL: time.Minute, Timeout: time.Second}, nil)
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Creatives: proxy})
+
+	serve := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/v1/creative/spotify", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "png-bytes", w.Body.String())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// Clients holding the current image revalidate without downloading it again
+	w = serve("/v1/creative/spotify", `"stale", W/`+etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, http.StatusOK, serve("/v1/creative/spotify", `"stale"`).Code)
+
+	assert.Equal(t, http.StatusBadGateway, serve("/v1/creative/html", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("/v1/creative/unknown", "").Code)
+}
+
+func TestCreativeValidation(t *testing.T) {
+	store := repository.NewMockRepository().(service.CampaignStore)
+	validator := creative.NewValidator(creative.ValidatorConfig{MaxBytes: 1 << 10, Timeout: time.Second})
+	checker := creative.NewChecker(validator, creativeCampaigns{
+		{Campaign: models.Campaign{ID: "netflix", ImageURL: "http://img.example.com/banner.png"}},
+	}, nil, log.NewNopLogger())
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{
+		Campaigns:         store,
+		CreativeValidator: validator,
+		CreativeChecks:    checker,
+	})
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	// Images are checked when campaigns are created and when their image changes
+	w := serve("POST", "/v1/admin/campaigns", `{"cid":"netflix","name":"Netflix","img":"http://img.example.com/banner.png","cta":"Watch"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "img must be an https URL")
+	assert.Equal(t, http.StatusCreated, serve("POST", "/v1/admin/campaigns", `{"cid":"netflix","name":"Netflix","cta":"Watch"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("PUT", "/v1/admin/campaigns/netflix/image", `{"img":"http://img.example.com/banner.png"}`).Code)
+	// and when a bundle imports them
+	w = serve("POST", "/v1/admin/campaigns:import", `{"version":1,"campaigns":[{"cid":"hulu","name":"Hulu","img":"http://img.example.com/hulu.png","cta":"Watch","status":"ACTIVE"}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "img must be an https URL")
+
+	// Broken images of active campaigns are listed after a check
+	w = serve("GET", "/v1/admin/creatives/broken", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+	require.NoError(t, checker.Check(context.Background()))
+	w = serve("GET", "/v1/admin/creatives/broken", "")
+	var broken []creative.BrokenCreative
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &broken))
+	require.Len(t, broken, 1)
+	assert.Equal(t, "netflix", broken[0].CID)
+}
@@ -0,0 +1,57 @@
+package transport
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+)
+
+// NewDebugHandler creates the handler for the internal diagnostics listener.
+// It must never be exposed on the public port as pprof can leak sensitive data.
+func NewDebugHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	// Profiling endpoints
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	// Runtime variables (memstats, cmdline and anything published via expvar)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	// Build information
+	mux.HandleFunc("/debug/buildinfo", buildInfoHandler)
+
+	return mux
+}
+
+// buildInfoHandler reports the Go version and VCS information embedded at build time
+func buildInfoHandler(w http.ResponseWriter, r *http.Request) {
+	response := map[string]any{
+		"go_version": runtime.Version(),
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		response["path"] = info.Path
+		response["module_version"] = info.Main.Version
+
+		// vcs.revision, vcs.time and vcs.modified are stamped by the go tool
+		vcs := make(map[string]string)
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs", "vcs.revision", "vcs.time", "vcs.modified":
+				vcs[setting.Key] = setting.Value
+			}
+		}
+		response["vcs"] = vcs
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
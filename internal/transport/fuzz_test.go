@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// FuzzDecodeGetCampaignsRequest feeds arbitrary query strings to the delivery endpoint, fuzz it with
+// go test -fuzz=FuzzDecodeGetCampaignsRequest ./internal/transport
+func FuzzDecodeGetCampaignsRequest(f *testing.F) {
+	f.Add("app=com.spotify&country=us&os=android")
+	f.Add("app=com.duolingo&country=IN&os=Android&state=GJ")
+	f.Add("country=usa&os=web")
+	f.Add("country=%C3%BC%C3%9F&os=%F0%9F%A4%96&app=%00")
+	f.Add("country=us&country=in&os=ios&app=a&state=")
+	f.Add("app=%zz&country=;&os")
+	f.Add("app=a&country=us&os=ios&time=2025-01-01T21:30:00+05:30")
+	f.Add("app=a&country=us&os=ios&time=2025-13-01T25:61:00Z")
+	f.Add("")
+
+	deliveryService := service.NewDeliveryService(repository.NewMockRepository())
+	handler := NewHTTPHandler(endpoint.MakeDeliveryEndpoints(deliveryService), log.NewNopLogger())
+
+	f.Fuzz(func(t *testing.T, rawQuery string) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/delivery", nil)
+		req.URL.RawQuery = rawQuery
+
+		// Only an invalid time fails decoding, reported as a validation error
+		var validationErr *models.ValidationError
+		decoded, err := decodeGetCampaignsRequest(context.Background(), req)
+		if err != nil && !errors.As(err, &validationErr) {
+			t.Fatalf("decode failed for %q: %v", rawQuery, err)
+		}
+		if err == nil {
+			delivery := decoded.(*endpoint.GetCampaignsRequest).DeliveryRequest
+			query, _ := url.ParseQuery(rawQuery)
+			if delivery.Country != query.Get("country") || delivery.OS != query.Get("os") ||
+				delivery.App != query.Get("app") || delivery.State != query.Get("state") {
+				t.Fatalf("decoded %+v from %q", delivery, rawQuery)
+			}
+		}
+
+		// Malformed input is the client's fault, it must never fail the server
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		switch w.Code {
+		case http.StatusOK, http.StatusNoContent, http.StatusBadRequest:
+		default:
+			t.Fatalf("query %q got status %d: %s", rawQuery, w.Code, w.Body.String())
+		}
+	})
+}
@@ -2,16 +2,39 @@ package transport
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	kitendpoint "github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
 	"github.com/gorilla/mux"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/blocklist"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/breaker"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/campaignstats"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/config"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/creative"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/database"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/health"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/logger"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/metrics"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/overview"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reach"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/readiness"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reporting"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/slo"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/tracking"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/version"
 )
 
 // NewHTTPHandler creates HTTP handlers for delivery service
@@ -26,12 +49,70 @@ func NewHTTPHandlerWithDB(endpoints endpoint.DeliveryEndpoints, logger log.Logge
 
 // NewHTTPHandlerWithCache creates HTTP handlers with both database and cache health checks
 func NewHTTPHandlerWithCache(endpoints endpoint.DeliveryEndpoints, logger log.Logger, db *database.DB, cache cache.Cache) http.Handler {
+	return NewHTTPHandlerWithOptions(endpoints, logger, HandlerOptions{DB: db, Cache: cache})
+}
+
+// HandlerOptions holds the optional dependencies of the HTTP handler
+// Nil dependencies disable the corresponding health checks and admin endpoints
+type HandlerOptions struct {
+	DB    *database.DB
+	Cache cache.Cache
+	// SLOTracker enables the /v1/admin/slo endpoint
+	SLOTracker *slo.Tracker
+	// LogControls enables the /v1/admin/logging endpoint
+	LogControls *logger.Controls
+	// HealthHistory enables /health?verbose=true, it should be the history of Health
+	HealthHistory *health.Tracker
+	// Health holds the component checks reported on /health, when nil DB and Cache are checked
+	// as critical components smoothed by HealthHistory
+	Health *health.Registry
+	// Readiness enables /readyz, failing until all warm-up steps completed
+	Readiness *readiness.Gate
+	// Config enables the /v1/admin/config endpoint, secrets are masked
+	Config *config.Config
+	// Tunables reports the tunables currently in effect on /v1/admin/config, they may
+	// differ from Config after a reload or a change through /v1/admin/logging
+	Tunables func() config.Tunables
+	// Campaigns enables listing, creating, pausing and resuming campaigns, changing their traffic
+	// percentage, category, image and localized creatives, changing, listing and rolling back
+	// their rule versions and exporting and importing them as bundles under /v1/admin/campaigns
+	Campaigns service.CampaignStore
+	// CreativeValidator checks the images of created campaigns, changed images and localized
+	// creatives, imported ones included, and rewrites them to the CDN
+	CreativeValidator *creative.Validator
+	// CreativeChecks enables the /v1/admin/creatives/broken endpoint
+	CreativeChecks *creative.Checker
+	// DeliveryStats enables the /v1/admin/stats endpoint
+	DeliveryStats func() metrics.DeliveryStats
+	// Overview enables the /v1/admin/overview dashboard endpoint
+	Overview *overview.Builder
+	// CampaignStats enables the /v1/admin/campaigns/{id}/stats endpoint
+	CampaignStats *campaignstats.Tracker
+	// Reach enables the /v1/admin/campaigns/{id}/reach endpoint, together with Campaigns
+	Reach *reach.Estimator
+	// Reports enables the /v1/admin/reports endpoint
+	Reports reporting.Store
+	// Quotas enables the /v1/admin/quotas endpoints reporting the monthly usage of API keys
+	Quotas *quota.Enforcer
+	// Blocklist enables listing, adding and removing the apps, countries and IP ranges blocked for
+	// every campaign under /v1/admin/blocklist
+	Blocklist *blocklist.Blocklist
+	// Tracking enables the /v1/track/impression and /v1/track/click endpoints
+	Tracking tracking.Recorder
+	// ClickRedirects limits the destinations of /v1/track/click, no destination is allowed when nil
+	ClickRedirects *tracking.RedirectPolicy
+	// Creatives enables the /v1/creative/{id} endpoint serving campaign images from this origin
+	Creatives *creative.Proxy
+}
+
+// NewHTTPHandlerWithOptions creates HTTP handlers with the given optional dependencies
+func NewHTTPHandlerWithOptions(endpoints endpoint.DeliveryEndpoints, logger log.Logger, opts HandlerOptions) http.Handler {
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorEncoder(encodeError),
 	}
 
 	getCampaignsHandler := httptransport.NewServer(
-		endpoints.GetCampaignsEndpoint,
+		releasingRequest(endpoints.GetCampaignsEndpoint),
 		decodeGetCampaignsRequest,
 		encodeGetCampaignsResponse,
 		options...,
@@ -42,31 +123,157 @@ func NewHTTPHandlerWithCache(endpoints endpoint.DeliveryEndpoints, logger log.Lo
 	// Main delivery endpoint
 	r.Handle("/v1/delivery", getCampaignsHandler).Methods("GET")
 
-	// Health check endpoint with database and cache checks
-	r.HandleFunc("/health", createHealthHandler(db, cache)).Methods("GET")
+	// Health check endpoint running the registered component checks
+	healthRegistry := opts.Health
+	if healthRegistry == nil {
+		healthRegistry = defaultHealthRegistry(opts)
+	}
+	r.HandleFunc("/health", createHealthHandler(healthRegistry, opts.HealthHistory)).Methods("GET")
+
+	// Build information of the running binary
+	r.HandleFunc("/version", createVersionHandler()).Methods("GET")
+
+	// Impression and click tracking
+	if opts.Tracking != nil {
+		clickRedirects := opts.ClickRedirects
+		if clickRedirects == nil {
+			clickRedirects = tracking.NewRedirectPolicy(nil)
+		}
+		r.HandleFunc("/v1/track/impression", createImpressionHandler(opts.Tracking)).Methods("GET")
+		r.HandleFunc("/v1/track/click", createClickHandler(opts.Tracking, clickRedirects)).Methods("GET")
+	}
+
+	// Campaign images served through the caching creative proxy
+	if opts.Creatives != nil {
+		r.HandleFunc("/v1/creative/{id}", createCreativeHandler(opts.Creatives)).Methods("GET")
+	}
+
+	// Readiness endpoint for load balancers
+	if opts.Readiness != nil {
+		r.HandleFunc("/readyz", createReadinessHandler(opts.Readiness)).Methods("GET")
+	}
+
+	// Admin endpoints
+	if opts.SLOTracker != nil {
+		r.HandleFunc("/v1/admin/slo", createSLOHandler(opts.SLOTracker)).Methods("GET")
+	}
+	if opts.LogControls != nil {
+		r.HandleFunc("/v1/admin/logging", createLoggingHandler(opts.LogControls)).Methods("GET", "PUT")
+	}
+	if opts.Config != nil {
+		r.HandleFunc("/v1/admin/config", createConfigHandler(opts.Config, opts.Tunables)).Methods("GET")
+	}
+	r.HandleFunc("/v1/admin/rules/validate", createRulesValidationHandler()).Methods("POST")
+	r.HandleFunc("/v1/admin/simulate", createSimulationHandler()).Methods("POST")
+	if opts.Campaigns != nil {
+		r.HandleFunc("/v1/admin/campaigns", createListCampaignsHandler(opts.Campaigns)).Methods("GET")
+		r.HandleFunc("/v1/admin/campaigns", createCreateCampaignHandler(opts.Campaigns, opts.Cache, opts.CreativeValidator)).Methods("POST")
+		r.HandleFunc("/v1/admin/campaigns/{id}/pause", createCampaignStatusHandler(opts.Campaigns, opts.Cache, models.StatusInactive)).Methods("POST")
+		r.HandleFunc("/v1/admin/campaigns/{id}/resume", createCampaignStatusHandler(opts.Campaigns, opts.Cache, models.StatusActive)).Methods("POST")
+		r.HandleFunc("/v1/admin/campaigns:bulkStatus", createBulkStatusHandler(opts.Campaigns, opts.Cache)).Methods("POST")
+		r.HandleFunc("/v1/admin/campaigns:export", createExportHandler(opts.Campaigns)).Methods("GET")
+		r.HandleFunc("/v1/admin/campaigns:import", createImportHandler(opts.Campaigns, opts.Cache, opts.CreativeValidator)).Methods("POST")
+		r.HandleFunc("/v1/admin/campaigns/{id}/traffic", createCampaignTrafficHandler(opts.Campaigns, opts.Cache)).Methods("PUT")
+		r.HandleFunc("/v1/admin/campaigns/{id}/category", createCampaignCategoryHandler(opts.Campaigns, opts.Cache)).Methods("PUT")
+		r.HandleFunc("/v1/admin/campaigns/{id}/image", createCampaignImageHandler(opts.Campaigns, opts.Cache, opts.CreativeValidator)).Methods("PUT")
+		r.HandleFunc("/v1/admin/campaigns/{id}/creatives", createCampaignCreativesHandler(opts.Campaigns, opts.Cache, opts.CreativeValidator)).Methods("PUT")
+		r.HandleFunc("/v1/admin/campaigns/{id}/rules", createSetRulesHandler(opts.Campaigns, opts.Cache)).Methods("PUT")
+		r.HandleFunc("/v1/admin/campaigns/{id}/rules/versions", createListRuleVersionsHandler(opts.Campaigns)).Methods("GET")
+		r.HandleFunc("/v1/admin/campaigns/{id}/rules/versions/{version}", createRuleVersionHandler(opts.Campaigns)).Methods("GET")
+		r.HandleFunc("/v1/admin/campaigns/{id}/rules/versions/{version}/rollback", createRulesRollbackHandler(opts.Campaigns, opts.Cache)).Methods("POST")
+	}
+	if opts.Cache != nil {
+		r.HandleFunc("/v1/admin/cache/invalidate", createCacheInvalidationHandler(opts.Cache)).Methods("POST")
+	}
+	if opts.DeliveryStats != nil {
+		r.HandleFunc("/v1/admin/stats", createDeliveryStatsHandler(opts.DeliveryStats)).Methods("GET")
+	}
+	if opts.Overview != nil {
+		r.HandleFunc("/v1/admin/overview", createOverviewHandler(opts.Overview)).Methods("GET")
+	}
+	if opts.CampaignStats != nil {
+		r.HandleFunc("/v1/admin/campaigns/{id}/stats", createCampaignStatsHandler(opts.CampaignStats)).Methods("GET")
+	}
+	if opts.Reach != nil && opts.Campaigns != nil {
+		r.HandleFunc("/v1/admin/campaigns/{id}/reach", createCampaignReachHandler(opts.Campaigns, opts.Reach)).Methods("GET")
+	}
+	if opts.CreativeChecks != nil {
+		r.HandleFunc("/v1/admin/creatives/broken", createBrokenCreativesHandler(opts.CreativeChecks)).Methods("GET")
+	}
+	if opts.Reports != nil {
+		r.HandleFunc("/v1/admin/reports", createReportHandler(opts.Reports)).Methods("GET")
+	}
+	if opts.Quotas != nil {
+		r.HandleFunc("/v1/admin/quotas", createQuotaUsageHandler(opts.Quotas)).Methods("GET")
+		r.HandleFunc("/v1/admin/quotas/{id}", createQuotaUsageHandler(opts.Quotas)).Methods("GET")
+	}
+	if opts.Blocklist != nil {
+		r.HandleFunc("/v1/admin/blocklist", createListBlocklistHandler(opts.Blocklist)).Methods("GET")
+		r.HandleFunc("/v1/admin/blocklist", createAddBlocklistEntryHandler(opts.Blocklist, opts.Cache)).Methods("POST")
+		r.HandleFunc("/v1/admin/blocklist/{id}", createRemoveBlocklistEntryHandler(opts.Blocklist, opts.Cache)).Methods("DELETE")
+	}
 
 	return r
 }
 
 // decodeGetCampaignsRequest decodes HTTP request to GetCampaignsRequest
-func decodeGetCampaignsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+func decodeGetCampaignsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	query := r.URL.Query()
 
-	req := endpoint.GetCampaignsRequest{
-		DeliveryRequest: models.DeliveryRequest{
-			App:     query.Get("app"),
-			Country: query.Get("country"),
-			OS:      query.Get("os"),
-			State:   query.Get("state"),
-		},
+	req := models.DeliveryRequest{
+		App:     query.Get("app"),
+		Country: query.Get("country"),
+		OS:      query.Get("os"),
+		State:   query.Get("state"),
+		// Only staged rollouts use the optional user ID
+		UserID: query.Get("user_id"),
+		// Sessions see their campaigns in the same order on every screen
+		SessionID: query.Get("session_id"),
+		// Campaigns with a creative in the language serve it
+		Lang: query.Get("lang"),
+		// The consent middleware evaluated the gdpr and gdpr_consent parameters
+		NoConsent: !reqcontext.GetConsent(ctx).AllowsPersonalData(),
+	}
+
+	// The optional client-local time, time-based targeting uses the current time without it
+	if raw := query.Get("time"); raw != "" {
+		// An unescaped + in the UTC offset arrives as a space, RFC 3339 never contains one
+		requestTime, err := time.Parse(time.RFC3339, strings.Replace(raw, " ", "+", 1))
+		if err != nil {
+			return nil, &models.ValidationError{Fields: []models.FieldError{
+				{Field: "time", Message: "time must be an RFC 3339 timestamp, e.g. 2025-01-01T21:30:00+05:30"},
+			}}
+		}
+		req.Time = requestTime
+	}
+
+	// The optional number of ad slots to fill, every matching campaign is served without it
+	if raw := query.Get("slots"); raw != "" {
+		slots, err := strconv.Atoi(raw)
+		if err != nil || slots < 1 || slots > models.MaxSlots {
+			return nil, &models.ValidationError{Fields: []models.FieldError{
+				{Field: "slots", Message: fmt.Sprintf("slots must be a number from 1 to %d", models.MaxSlots)},
+			}}
+		}
+		req.Slots = slots
 	}
 
-	return req, nil
+	return endpoint.NewGetCampaignsRequest(req), nil
+}
+
+// releasingRequest releases the decoded request once the delivery endpoint returned, the
+// response encoder doesn't need it
+func releasingRequest(next kitendpoint.Endpoint) kitendpoint.Endpoint {
+	return func(ctx context.Context, request any) (any, error) {
+		defer request.(*endpoint.GetCampaignsRequest).Release()
+		return next(ctx, request)
+	}
 }
 
 // encodeGetCampaignsResponse encodes GetCampaignsResponse to HTTP response
 func encodeGetCampaignsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-	resp := response.(endpoint.GetCampaignsResponse)
+	resp := response.(*endpoint.GetCampaignsResponse)
+	defer resp.Release()
 
 	// Handle validation errors
 	if resp.Err != nil {
@@ -83,20 +290,46 @@ func encodeGetCampaignsResponse(ctx context.Context, w http.ResponseWriter, resp
 	// Return successful response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	return json.NewEncoder(w).Encode(resp.Campaigns)
+	if resp.Slots > 0 {
+		return writeAppendedJSON(w, models.PodResponse(resp.Campaigns))
+	}
+	return writeAppendedJSON(w, models.DeliveryResponse(resp.Campaigns))
 }
 
 // encodeError encodes error to HTTP response
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Check for validation errors - these should return 400 Bad Request
+	// The request ran out of its deadline budget
+	if errors.Is(err, endpoint.ErrTimeout) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		writeAppendedJSON(w, models.NewErrorResponse(err.Error()))
+		return
+	}
+
+	// The endpoint is rate limited or its circuit breaker is open
+	if errors.Is(err, endpoint.ErrRateLimited) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		writeAppendedJSON(w, models.NewErrorResponse(err.Error()))
+		return
+	}
+	if errors.Is(err, breaker.ErrOpen) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		writeAppendedJSON(w, models.NewErrorResponse(err.Error()))
+		return
+	}
+
+	// Validation errors list every invalid field
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		w.WriteHeader(http.StatusBadRequest)
+		writeAppendedJSON(w, models.ErrorResponse{Error: err.Error(), Fields: validationErr.Fields})
+		return
+	}
+
+	// Check for plain validation errors - these should return 400 Bad Request
 	errorMsg := err.Error()
-	if errorMsg == "country is required" ||
-		errorMsg == "country must be a 2-letter code" ||
-		errorMsg == "os is required" ||
-		errorMsg == "app is required" ||
-		errorMsg == "missing app param" ||
+	if errorMsg == "missing app param" ||
 		errorMsg == "missing country param" ||
 		errorMsg == "missing os param" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -105,75 +338,66 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 
-	errorResponse := models.NewErrorResponse(err.Error())
-	json.NewEncoder(w).Encode(errorResponse)
+	writeAppendedJSON(w, models.NewErrorResponse(err.Error()))
 }
 
-// createHealthHandler creates a health handler with optional database and cache checks
-func createHealthHandler(db *database.DB, cache cache.Cache) http.HandlerFunc {
+// createReadinessHandler reports 503 with the pending warm-up steps until the gate is ready
+func createReadinessHandler(gate *readiness.Gate) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
+		status := gate.Status()
+		statusCode := http.StatusOK
+		if !status.Ready {
+			statusCode = http.StatusServiceUnavailable
+		}
+		writeJSON(w, statusCode, status)
+	}
+}
+
+// createVersionHandler creates a handler answering with the build information of the server
+func createVersionHandler() http.HandlerFunc {
+	info := version.Get()
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, info)
+	}
+}
+
+// defaultHealthRegistry checks the database and cache given in opts, both critical
+func defaultHealthRegistry(opts HandlerOptions) *health.Registry {
+	registry := health.NewRegistry(opts.HealthHistory)
+	if opts.DB != nil {
+		registry.Register("database", health.Critical, health.DatabaseChecker(opts.DB))
+	}
+	if opts.Cache != nil {
+		registry.Register("cache", health.Critical, health.CacheChecker(opts.Cache))
+	}
+	return registry
+}
+
+// createHealthHandler creates a health handler reporting the components of the registry
+// under their names. It responds 503 when unhealthy and 200 otherwise, also when degraded.
+// With a history tracker /health?verbose=true includes the recent check history.
+func createHealthHandler(registry *health.Registry, history *health.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := registry.Check(r.Context())
 
 		response := map[string]any{
-			"status":  "healthy",
+			"status":  report.Status,
 			"service": "adbeacon",
-			"version": "1.0.0",
+			"version": version.Version,
 		}
-
-		overallHealthy := true
-
-		// Check database health if available
-		if db != nil {
-			if err := db.HealthCheck(); err != nil {
-				response["status"] = "unhealthy"
-				response["database"] = map[string]any{
-					"status": "unhealthy",
-					"error":  err.Error(),
-				}
-				overallHealthy = false
-			} else {
-				// Add connection stats
-				stats := db.GetConnectionStats()
-				response["database"] = map[string]any{
-					"status": "healthy",
-					"stats": map[string]any{
-						"open_connections":     stats.OpenConnections,
-						"in_use":               stats.InUse,
-						"idle":                 stats.Idle,
-						"wait_count":           stats.WaitCount,
-						"wait_duration":        stats.WaitDuration.String(),
-						"max_idle_closed":      stats.MaxIdleClosed,
-						"max_idle_time_closed": stats.MaxIdleTimeClosed,
-						"max_lifetime_closed":  stats.MaxLifetimeClosed,
-					},
-				}
-			}
+		for name, component := range report.Components {
+			response[name] = component
 		}
 
-		// Check cache health if available
-		if cache != nil {
-			cacheHealth := cache.HealthCheck(ctx)
-			response["cache"] = cacheHealth
-
-			// Update overall status based on cache health
-			if cacheHealth.Overall == "unhealthy" {
-				response["status"] = "unhealthy"
-				overallHealthy = false
-			} else if cacheHealth.Overall == "degraded" && response["status"] != "unhealthy" {
-				response["status"] = "degraded"
-			}
+		// Include the recent check history on request
+		if history != nil && r.URL.Query().Get("verbose") == "true" {
+			response["history"] = history.Snapshot()
 		}
 
-		// Set appropriate HTTP status code
 		statusCode := http.StatusOK
-		if !overallHealthy {
+		if report.Status == health.StatusUnhealthy {
 			statusCode = http.StatusServiceUnavailable
-		} else if response["status"] == "degraded" {
-			statusCode = http.StatusOK // 200 OK but with degraded status
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(statusCode)
-		json.NewEncoder(w).Encode(response)
+		writeJSON(w, statusCode, response)
 	}
 }
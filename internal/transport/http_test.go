@@ -9,11 +9,18 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/gorilla/mux"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/breaker"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/consent"
+	reqcontext "github.com/prajwalbharadwajbm/adbeacon/internal/context"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/health"
 	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/readiness"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/version"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -38,6 +45,19 @@ func TestNewHTTPHandler(t *testing.T) {
 	assert.IsType(t, &mux.Router{}, handler)
 }
 
+func TestVersionEndpoint(t *testing.T) {
+	handler := NewHTTPHandler(endpoint.DeliveryEndpoints{}, log.NewNopLogger())
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var info version.Info
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, version.Get(), info)
+}
+
 func TestHealthEndpoint(t *testing.T) {
 	logger := log.NewNopLogger()
 	endpoints := endpoint.DeliveryEndpoints{}
@@ -57,7 +77,7 @@ func TestHealthEndpoint(t *testing.T) {
 
 	assert.Equal(t, "adbeacon", response["service"])
 	assert.Equal(t, "healthy", response["status"])
-	assert.Equal(t, "1.0.0", response["version"])
+	assert.Equal(t, version.Version, response["version"])
 }
 
 func TestDecodeGetCampaignsRequest_Success(t *testing.T) {
@@ -65,18 +85,94 @@ func TestDecodeGetCampaignsRequest_Success(t *testing.T) {
 	values.Set("app", "com.test.app")
 	values.Set("country", "US")
 	values.Set("os", "Android")
+	values.Set("user_id", "u-42")
+	values.Set("session_id", "s-7")
+	values.Set("lang", "pt-BR")
 
 	req := httptest.NewRequest("GET", "/v1/delivery?"+values.Encode(), nil)
 
 	result, err := decodeGetCampaignsRequest(context.Background(), req)
 
 	assert.NoError(t, err)
-	assert.IsType(t, endpoint.GetCampaignsRequest{}, result)
+	assert.IsType(t, &endpoint.GetCampaignsRequest{}, result)
 
-	getCampaignsReq := result.(endpoint.GetCampaignsRequest)
+	getCampaignsReq := result.(*endpoint.GetCampaignsRequest)
 	assert.Equal(t, "com.test.app", getCampaignsReq.DeliveryRequest.App)
 	assert.Equal(t, "US", getCampaignsReq.DeliveryRequest.Country)
 	assert.Equal(t, "Android", getCampaignsReq.DeliveryRequest.OS)
+	assert.Equal(t, "u-42", getCampaignsReq.DeliveryRequest.UserID)
+	assert.Equal(t, "s-7", getCampaignsReq.DeliveryRequest.SessionID)
+	assert.Equal(t, "pt-BR", getCampaignsReq.DeliveryRequest.Lang)
+}
+
+func TestDecodeGetCampaignsRequest_Time(t *testing.T) {
+	decode := func(rawQuery string) (models.DeliveryRequest, error) {
+		req := httptest.NewRequest("GET", "/v1/delivery?"+rawQuery, nil)
+		result, err := decodeGetCampaignsRequest(context.Background(), req)
+		if err != nil {
+			return models.DeliveryRequest{}, err
+		}
+		return result.(*endpoint.GetCampaignsRequest).DeliveryRequest, nil
+	}
+
+	// Without a time the service clock decides
+	delivery, err := decode("app=a&country=us&os=ios")
+	assert.NoError(t, err)
+	assert.True(t, delivery.Time.IsZero())
+
+	// The client's offset is kept, also when its + was not escaped
+	for _, rawQuery := range []string{"time=2025-01-01T21:30:00%2B05:30", "time=2025-01-01T21:30:00+05:30"} {
+		delivery, err = decode(rawQuery)
+		assert.NoError(t, err, rawQuery)
+		assert.Equal(t, 21, delivery.Time.Hour(), rawQuery)
+		_, offset := delivery.Time.Zone()
+		assert.Equal(t, 5*3600+1800, offset, rawQuery)
+	}
+
+	_, err = decode("time=yesterday")
+	var validationErr *models.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "time", validationErr.Fields[0].Field)
+}
+
+func TestDecodeGetCampaignsRequest_Slots(t *testing.T) {
+	decode := func(rawQuery string) (models.DeliveryRequest, error) {
+		req := httptest.NewRequest("GET", "/v1/delivery?app=a&country=us&os=ios&"+rawQuery, nil)
+		result, err := decodeGetCampaignsRequest(context.Background(), req)
+		if err != nil {
+			return models.DeliveryRequest{}, err
+		}
+		return result.(*endpoint.GetCampaignsRequest).DeliveryRequest, nil
+	}
+
+	delivery, err := decode("")
+	assert.NoError(t, err)
+	assert.Zero(t, delivery.Slots)
+
+	delivery, err = decode("slots=3")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, delivery.Slots)
+
+	for _, rawQuery := range []string{"slots=0", "slots=11", "slots=two", "slots=-1"} {
+		_, err = decode(rawQuery)
+		var validationErr *models.ValidationError
+		if assert.ErrorAs(t, err, &validationErr, rawQuery) {
+			assert.Equal(t, "slots", validationErr.Fields[0].Field)
+		}
+	}
+}
+
+func TestDecodeGetCampaignsRequest_Consent(t *testing.T) {
+	decode := func(c consent.Consent) models.DeliveryRequest {
+		req := httptest.NewRequest("GET", "/v1/delivery?app=a&country=de&os=ios", nil)
+		result, err := decodeGetCampaignsRequest(reqcontext.WithConsent(context.Background(), c), req)
+		assert.NoError(t, err)
+		return result.(*endpoint.GetCampaignsRequest).DeliveryRequest
+	}
+
+	assert.False(t, decode(consent.Consent{}).NoConsent)
+	assert.False(t, decode(consent.Consent{Applies: true, Status: consent.StatusGranted}).NoConsent)
+	assert.True(t, decode(consent.Consent{Applies: true, Status: consent.StatusDenied}).NoConsent)
 }
 
 func TestDecodeGetCampaignsRequest_MissingParams(t *testing.T) {
@@ -116,7 +212,7 @@ func TestDecodeGetCampaignsRequest_MissingParams(t *testing.T) {
 
 			// Decode should succeed but create request with missing fields
 			assert.NoError(t, err)
-			getCampaignsReq := result.(endpoint.GetCampaignsRequest)
+			getCampaignsReq := result.(*endpoint.GetCampaignsRequest)
 
 			// Verify the request has empty fields (which will fail validation later)
 			switch tt.wantErr {
@@ -137,7 +233,7 @@ func TestEncodeGetCampaignsResponse_Success(t *testing.T) {
 		{CID: "duolingo", Img: "https://example.com/duolingo.jpg", CTA: "Install"},
 	}
 
-	response := endpoint.GetCampaignsResponse{
+	response := &endpoint.GetCampaignsResponse{
 		Campaigns: campaigns,
 		Err:       nil,
 	}
@@ -155,8 +251,41 @@ func TestEncodeGetCampaignsResponse_Success(t *testing.T) {
 	assert.Equal(t, campaigns, decodedCampaigns)
 }
 
+func TestEncodeGetCampaignsResponse_MatchesEncodingJSON(t *testing.T) {
+	campaigns := []models.CampaignResponse{
+		{CID: "spotify", Img: "https://example.com/spotify.jpg?a=1&b=2", CTA: "Download <now>"},
+	}
+	var want bytes.Buffer
+	assert.NoError(t, json.NewEncoder(&want).Encode(campaigns))
+
+	// The pooled buffer is reused between responses
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		assert.NoError(t, encodeGetCampaignsResponse(context.Background(), w, &endpoint.GetCampaignsResponse{Campaigns: campaigns}))
+		assert.Equal(t, want.String(), w.Body.String())
+	}
+}
+
+func TestEncodeGetCampaignsResponse_Slots(t *testing.T) {
+	response := &endpoint.GetCampaignsResponse{
+		Campaigns: []models.CampaignResponse{
+			{CID: "spotify", Img: "https://example.com/spotify.jpg", CTA: "Download"},
+			{CID: "duolingo", Img: "https://example.com/duolingo.jpg", CTA: "Install"},
+		},
+		Slots: 3,
+	}
+
+	w := httptest.NewRecorder()
+	assert.NoError(t, encodeGetCampaignsResponse(context.Background(), w, response))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"slots":[
+		{"slot":1,"cid":"spotify","img":"https://example.com/spotify.jpg","cta":"Download"},
+		{"slot":2,"cid":"duolingo","img":"https://example.com/duolingo.jpg","cta":"Install"}
+	]}`, w.Body.String())
+}
+
 func TestEncodeGetCampaignsResponse_EmptyResults(t *testing.T) {
-	response := endpoint.GetCampaignsResponse{
+	response := &endpoint.GetCampaignsResponse{
 		Campaigns: []models.CampaignResponse{},
 		Err:       nil,
 	}
@@ -170,7 +299,7 @@ func TestEncodeGetCampaignsResponse_EmptyResults(t *testing.T) {
 }
 
 func TestEncodeGetCampaignsResponse_ValidationError(t *testing.T) {
-	response := endpoint.GetCampaignsResponse{
+	response := &endpoint.GetCampaignsResponse{
 		Campaigns: []models.CampaignResponse{},
 		Err:       errors.New("missing app param"),
 	}
@@ -189,7 +318,7 @@ func TestEncodeGetCampaignsResponse_ValidationError(t *testing.T) {
 }
 
 func TestEncodeGetCampaignsResponse_InternalError(t *testing.T) {
-	response := endpoint.GetCampaignsResponse{
+	response := &endpoint.GetCampaignsResponse{
 		Campaigns: []models.CampaignResponse{},
 		Err:       errors.New("database connection failed"),
 	}
@@ -207,6 +336,40 @@ func TestEncodeGetCampaignsResponse_InternalError(t *testing.T) {
 	assert.Equal(t, "database connection failed", errorResponse.Error)
 }
 
+func TestEncodeError_Timeout(t *testing.T) {
+	w := httptest.NewRecorder()
+	encodeError(context.Background(), endpoint.ErrTimeout, w)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
+
+func TestEncodeError_ValidationFields(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := (&models.DeliveryRequest{Country: "US"}).Validate()
+	encodeError(context.Background(), err, w)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errorResponse models.ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &errorResponse))
+	assert.Equal(t, "os is required; app is required", errorResponse.Error)
+	assert.Equal(t, []models.FieldError{
+		{Field: "os", Message: "os is required"},
+		{Field: "app", Message: "app is required"},
+	}, errorResponse.Fields)
+}
+
+func TestEncodeError_Overload(t *testing.T) {
+	w := httptest.NewRecorder()
+	encodeError(context.Background(), endpoint.ErrRateLimited, w)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = httptest.NewRecorder()
+	encodeError(context.Background(), breaker.ErrOpen, w)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
 func TestDeliveryEndpoint_Integration(t *testing.T) {
 	logger := log.NewNopLogger()
 
@@ -218,9 +381,9 @@ func TestDeliveryEndpoint_Integration(t *testing.T) {
 		{CID: "spotify", Img: "https://example.com/spotify.jpg", CTA: "Download"},
 	}
 
-	mockEndpoints.On("GetCampaignsEndpoint", mock.Anything, mock.MatchedBy(func(req endpoint.GetCampaignsRequest) bool {
+	mockEndpoints.On("GetCampaignsEndpoint", mock.Anything, mock.MatchedBy(func(req *endpoint.GetCampaignsRequest) bool {
 		return req.DeliveryRequest.App == "com.test.app" && req.DeliveryRequest.Country == "US" && req.DeliveryRequest.OS == "Android"
-	})).Return(endpoint.GetCampaignsResponse{
+	})).Return(&endpoint.GetCampaignsResponse{
 		Campaigns: expectedCampaigns,
 		Err:       nil,
 	}, nil)
@@ -252,7 +415,7 @@ func TestDeliveryEndpoint_ValidationError_Integration(t *testing.T) {
 	logger := log.NewNopLogger()
 
 	mockEndpoints := &MockEndpoints{}
-	mockEndpoints.On("GetCampaignsEndpoint", mock.Anything, mock.Anything).Return(endpoint.GetCampaignsResponse{
+	mockEndpoints.On("GetCampaignsEndpoint", mock.Anything, mock.Anything).Return(&endpoint.GetCampaignsResponse{
 		Campaigns: []models.CampaignResponse{},
 		Err:       errors.New("missing country param"),
 	}, nil)
@@ -281,7 +444,7 @@ func TestDeliveryEndpoint_NoResults_Integration(t *testing.T) {
 	logger := log.NewNopLogger()
 
 	mockEndpoints := &MockEndpoints{}
-	mockEndpoints.On("GetCampaignsEndpoint", mock.Anything, mock.Anything).Return(endpoint.GetCampaignsResponse{
+	mockEndpoints.On("GetCampaignsEndpoint", mock.Anything, mock.Anything).Return(&endpoint.GetCampaignsResponse{
 		Campaigns: []models.CampaignResponse{},
 		Err:       nil,
 	}, nil)
@@ -328,3 +491,73 @@ func TestHTTPHandler_NotFound(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
+
+func TestHealthEndpoint_VerboseHistory(t *testing.T) {
+	history := health.NewTracker(health.Config{HistorySize: 5})
+	history.Record("cache", health.StatusHealthy, nil)
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{HealthHistory: history})
+
+	req := httptest.NewRequest("GET", "/health?verbose=true", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Contains(t, response, "history")
+
+	req = httptest.NewRequest("GET", "/health", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	response = nil
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotContains(t, response, "history")
+}
+
+func TestHealthEndpoint_Registry(t *testing.T) {
+	registry := health.NewRegistry(nil)
+	registry.Register("enrichment", health.NonCritical, health.CheckerFunc(func(ctx context.Context) health.CheckResult {
+		return health.CheckResult{Status: health.StatusUnhealthy, Err: errors.New("timeout")}
+	}))
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Health: registry})
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// A failing non-critical component degrades the server without failing the check
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "degraded", response["status"])
+	assert.Equal(t, map[string]interface{}{"status": "unhealthy", "critical": false, "error": "timeout"}, response["enrichment"])
+
+	registry.Register("database", health.Critical, health.CheckerFunc(func(ctx context.Context) health.CheckResult {
+		return health.CheckResult{Status: health.StatusUnhealthy}
+	}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestReadinessEndpoint(t *testing.T) {
+	gate := readiness.NewGate("cache")
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Readiness: gate})
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	assert.NoError(t, gate.Run(context.Background(), "cache", time.Millisecond, func(ctx context.Context) error {
+		return nil
+	}))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var status readiness.Status
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.True(t, status.Ready)
+}
@@ -0,0 +1,36 @@
+package transport
+
+import (
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize keeps buffers grown by unusually large responses out of the pool
+const maxPooledBufferSize = 64 << 10
+
+// jsonBuffers pools the buffers delivery responses are encoded into
+var jsonBuffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
+
+// jsonAppender is a response that encodes itself without reflection
+type jsonAppender interface {
+	AppendJSON(dst []byte) []byte
+}
+
+// writeAppendedJSON writes v followed by a newline, the same body json.Encoder writes, using a
+// pooled buffer
+func writeAppendedJSON[T jsonAppender](w io.Writer, v T) error {
+	bufp := jsonBuffers.Get().(*[]byte)
+	buf := append(v.AppendJSON((*bufp)[:0]), '\n')
+	_, err := w.Write(buf)
+
+	if cap(buf) <= maxPooledBufferSize {
+		*bufp = buf
+		jsonBuffers.Put(bufp)
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/overview"
+)
+
+// maxOverviewTop bounds the number of no-fill values per dimension on /v1/admin/overview
+const maxOverviewTop = 50
+
+// createOverviewHandler creates a handler aggregating campaign and delivery health for
+// dashboards. expiring_within (a duration, 24h by default) selects how far ahead campaigns count
+// as expiring and top (5 by default) the number of no-fill values reported per dimension.
+func createOverviewHandler(builder *overview.Builder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		options := overview.Options{ExpiringWithin: 24 * time.Hour, Top: 5}
+		if raw := query.Get("expiring_within"); raw != "" {
+			within, err := time.ParseDuration(raw)
+			if err != nil || within <= 0 {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("expiring_within must be a positive duration, e.g. 48h"))
+				return
+			}
+			options.ExpiringWithin = within
+		}
+		if raw := query.Get("top"); raw != "" {
+			top, err := strconv.Atoi(raw)
+			if err != nil || top < 1 || top > maxOverviewTop {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(fmt.Sprintf("top must be between 1 and %d", maxOverviewTop)))
+				return
+			}
+			options.Top = top
+		}
+
+		result, err := builder.Build(r.Context(), options)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,42 @@
+package transport
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+)
+
+// createQuotaUsageHandler creates a handler listing the usage and limits of the API keys in a month,
+// the current month unless month=YYYY-MM is given. With an {id} route variable only that key is reported.
+func createQuotaUsageHandler(enforcer *quota.Enforcer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		month := quota.Month(time.Now())
+		if raw := r.URL.Query().Get("month"); raw != "" {
+			if _, err := time.Parse("2006-01", raw); err != nil {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid month, expected YYYY-MM"))
+				return
+			}
+			month = raw
+		}
+
+		if keyID, ok := mux.Vars(r)["id"]; ok {
+			status, err := enforcer.KeyUsage(r.Context(), month, keyID)
+			if err != nil {
+				writeJSON(w, http.StatusServiceUnavailable, models.NewErrorResponse(err.Error()))
+				return
+			}
+			writeJSON(w, http.StatusOK, status)
+			return
+		}
+
+		usage, err := enforcer.Usage(r.Context(), month)
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, models.NewErrorResponse(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, usage)
+	}
+}
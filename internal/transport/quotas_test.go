@@ -0,0 +1,48 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaUsageEndpoints(t *testing.T) {
+	store := quota.NewMemoryStore()
+	require.NoError(t, store.Add(context.Background(), "2025-01", map[string]int64{"trial": 40}))
+	enforcer := quota.NewEnforcer(store, quota.Config{Limits: map[string]int64{"trial": 50, "premium": 1000}, WarnFraction: 0.8})
+	enforcer.RecordDeliveries("trial", 2)
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Quotas: enforcer})
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	// Keys with a limit are listed even when unused
+	w := serve("/v1/admin/quotas?month=2025-01")
+	require.Equal(t, http.StatusOK, w.Code)
+	var usage []quota.Status
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	assert.Equal(t, []quota.Status{
+		{KeyID: "premium", Month: "2025-01", Limit: 1000},
+		{KeyID: "trial", Month: "2025-01", Used: 40, Limit: 50, Warning: true},
+	}, usage)
+
+	w = serve("/v1/admin/quotas/trial")
+	require.Equal(t, http.StatusOK, w.Code)
+	var status quota.Status
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, quota.Status{KeyID: "trial", Month: quota.Month(time.Now()), Used: 2, Limit: 50}, status)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/v1/admin/quotas?month=january").Code)
+}
@@ -0,0 +1,145 @@
+package transport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reporting"
+)
+
+// defaultReportRange is the time range of a report without from
+const defaultReportRange = 24 * time.Hour
+
+// reportResponse is the JSON body of the /v1/admin/reports endpoint
+type reportResponse struct {
+	From    time.Time       `json:"from"`
+	To      time.Time       `json:"to"`
+	GroupBy []string        `json:"group_by"`
+	Rows    []reporting.Row `json:"rows"`
+}
+
+// createReportHandler creates a handler summing the hourly delivery stats of a time range by the
+// group_by dimensions, as JSON or as CSV with format=csv or an Accept: text/csv header
+func createReportHandler(store reporting.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseReportQuery(r, time.Now())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		rows, err := store.QueryDeliveryStats(r.Context(), query)
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		if wantsCSV(r) {
+			writeReportCSV(w, query, rows)
+			return
+		}
+		writeJSON(w, http.StatusOK, reportResponse{From: query.From, To: query.To, GroupBy: query.GroupBy, Rows: rows})
+	}
+}
+
+// parseReportQuery reads the from, to, group_by and campaign parameters, the range defaults to the
+// day before now
+func parseReportQuery(r *http.Request, now time.Time) (reporting.Query, error) {
+	params := r.URL.Query()
+
+	query := reporting.Query{
+		To:         now.UTC(),
+		CampaignID: params.Get("campaign"),
+		GroupBy:    make([]string, 0),
+	}
+	if raw := params.Get("to"); raw != "" {
+		to, err := parseReportTime(raw)
+		if err != nil {
+			return query, fmt.Errorf("%w: invalid to: %v", reporting.ErrInvalidQuery, err)
+		}
+		query.To = to
+	}
+	query.From = query.To.Add(-defaultReportRange)
+	if raw := params.Get("from"); raw != "" {
+		from, err := parseReportTime(raw)
+		if err != nil {
+			return query, fmt.Errorf("%w: invalid from: %v", reporting.ErrInvalidQuery, err)
+		}
+		query.From = from
+	}
+
+	for _, dimension := range strings.Split(params.Get("group_by"), ",") {
+		if dimension = strings.TrimSpace(dimension); dimension != "" {
+			query.GroupBy = append(query.GroupBy, dimension)
+		}
+	}
+
+	return query, query.Validate()
+}
+
+// parseReportTime parses an RFC 3339 time or a UTC date
+func parseReportTime(raw string) (time.Time, error) {
+	// An unescaped + in the UTC offset arrives as a space, RFC 3339 never contains one
+	raw = strings.Replace(raw, " ", "+", 1)
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, errors.New("expected an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	return t, nil
+}
+
+// wantsCSV reports whether a report was requested as CSV
+func wantsCSV(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "csv"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+// writeReportCSV writes rows as CSV with a column per grouped dimension followed by the counts
+func writeReportCSV(w http.ResponseWriter, query reporting.Query, rows []reporting.Row) {
+	var dimensions []string
+	for _, dimension := range reporting.GroupByDimensions {
+		if query.Groups(dimension) {
+			dimensions = append(dimensions, dimension)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="delivery-report.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	out.Write(append(dimensions, "deliveries", "impressions", "clicks"))
+	for _, row := range rows {
+		record := make([]string, 0, len(dimensions)+3)
+		for _, dimension := range dimensions {
+			switch dimension {
+			case reporting.GroupByHour:
+				record = append(record, row.Hour.Format(time.RFC3339))
+			case reporting.GroupByDay:
+				record = append(record, row.Hour.Format(time.DateOnly))
+			case reporting.GroupByCampaign:
+				record = append(record, row.CampaignID)
+			case reporting.GroupByCountry:
+				record = append(record, row.Country)
+			}
+		}
+		record = append(record,
+			strconv.FormatInt(row.Deliveries, 10),
+			strconv.FormatInt(row.Impressions, 10),
+			strconv.FormatInt(row.Clicks, 10),
+		)
+		out.Write(record)
+	}
+	out.Flush()
+}
@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/reporting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportEndpoint(t *testing.T) {
+	store := reporting.NewMemoryStore()
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, store.AddDeliveryStats(context.Background(), []reporting.Row{
+		{Hour: day.Add(10 * time.Hour), CampaignID: "spotify", Country: "US", Deliveries: 5, Impressions: 4, Clicks: 1},
+		{Hour: day.Add(11 * time.Hour), CampaignID: "duolingo", Country: "Germany", Deliveries: 2},
+	}))
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{Reports: store})
+
+	serve := func(target string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/v1/admin/reports?from=2025-01-01&to=2025-01-02&group_by=campaign", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var report reportResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, day, report.From)
+	assert.Equal(t, []string{"campaign"}, report.GroupBy)
+	assert.Equal(t, []reporting.Row{
+		{CampaignID: "duolingo", Deliveries: 2},
+		{CampaignID: "spotify", Deliveries: 5, Impressions: 4, Clicks: 1},
+	}, report.Rows)
+
+	// CSV with a column per grouped dimension, by parameter or Accept header
+	const csvReport = "hour,country,deliveries,impressions,clicks\n" +
+		"2025-01-01T10:00:00Z,US,5,4,1\n" +
+		"2025-01-01T11:00:00Z,Germany,2,0,0\n"
+	w = serve("/v1/admin/reports?from=2025-01-01T00:00:00Z&to=2025-01-02T00:00:00Z&group_by=hour,country&format=csv", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, csvReport, w.Body.String())
+	w = serve("/v1/admin/reports?from=2025-01-01&to=2025-01-02&group_by=hour,country", "text/csv")
+	assert.Equal(t, csvReport, w.Body.String())
+
+	w = serve("/v1/admin/reports?from=2025-01-01&to=2025-01-02&group_by=day&campaign=spotify&format=csv", "")
+	assert.Equal(t, "day,deliveries,impressions,clicks\n2025-01-01,5,4,1\n", w.Body.String())
+
+	// Invalid queries are rejected
+	for _, target := range []string{
+		"/v1/admin/reports?from=yesterday",
+		"/v1/admin/reports?from=2025-01-02&to=2025-01-01",
+		"/v1/admin/reports?group_by=os",
+		"/v1/admin/reports?group_by=hour,day",
+	} {
+		assert.Equal(t, http.StatusBadRequest, serve(target, "").Code, target)
+	}
+}
@@ -0,0 +1,235 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/cache"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// adminUserHeader names who makes a rule change, it's recorded as the author of the version
+const adminUserHeader = "X-Admin-User"
+
+// rulesUpdate is the request body of the /v1/admin/campaigns/{id}/rules endpoint, the rules are
+// given either as rules or as a targeting expression
+type rulesUpdate struct {
+	Rules     []models.TargetingRule `json:"rules"`
+	Targeting string                 `json:"targeting,omitempty"`
+	Comment   string                 `json:"comment,omitempty"`
+}
+
+// ruleVersion is a rule set version with its changes against an older one, the previous version
+// unless another was asked for
+type ruleVersion struct {
+	models.RuleSetVersion
+	// Against is the version the changes are against, 0 for version 1 whose changes add all its rules
+	Against int                 `json:"against"`
+	Changes []models.RuleChange `json:"changes"`
+}
+
+// newRuleVersion returns version with its changes against older, nil for none
+func newRuleVersion(version models.RuleSetVersion, older *models.RuleSetVersion) ruleVersion {
+	var against int
+	var rules []models.TargetingRule
+	if older != nil {
+		against, rules = older.Version, older.Rules
+	}
+	changes := models.DiffRules(rules, version.Rules)
+	if changes == nil {
+		changes = []models.RuleChange{}
+	}
+	return ruleVersion{RuleSetVersion: version, Against: against, Changes: changes}
+}
+
+// versionChanges returns the version at index i of versions, sorted newest first, with its
+// changes against the previous one
+func versionChanges(versions []models.RuleSetVersion, i int) ruleVersion {
+	if i+1 < len(versions) {
+		return newRuleVersion(versions[i], &versions[i+1])
+	}
+	return newRuleVersion(versions[i], nil)
+}
+
+// findVersion returns the index of version in versions, -1 when it is not there
+func findVersion(versions []models.RuleSetVersion, version int) int {
+	for i := range versions {
+		if versions[i].Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeStoreError writes the response of a failed campaign store call
+func writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrCampaignNotFound) {
+		writeJSON(w, http.StatusNotFound, models.NewErrorResponse(err.Error()))
+		return
+	}
+	if errors.Is(err, service.ErrLimitExceeded) {
+		writeJSON(w, http.StatusUnprocessableEntity, models.NewErrorResponse(err.Error()))
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+}
+
+// setRules stores rules as the next version of the campaign's rule set and writes it with its
+// changes against the version it replaced, the cache is invalidated afterwards
+func setRules(w http.ResponseWriter, r *http.Request, store service.CampaignStore, c cache.Cache, version models.RuleSetVersion) {
+	version.Author = r.Header.Get(adminUserHeader)
+	stored, err := store.SetCampaignRules(r.Context(), version)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	invalidateCache(r.Context(), c)
+
+	// The version replaced is the one numbered just below, whatever was stored since
+	versions, err := store.ListRuleVersions(r.Context(), stored.CampaignID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if i := findVersion(versions, stored.Version-1); i >= 0 {
+		writeJSON(w, http.StatusOK, newRuleVersion(stored, &versions[i]))
+		return
+	}
+	writeJSON(w, http.StatusOK, newRuleVersion(stored, nil))
+}
+
+// createSetRulesHandler creates a handler replacing the targeting rules of the campaign named in
+// the path, recording them as a new version by the user in the X-Admin-User header
+func createSetRulesHandler(store service.CampaignStore, c cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body rulesUpdate
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid request body"))
+			return
+		}
+
+		rules, err := parseTargeting(body.Rules, body.Targeting)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+			return
+		}
+		campaign := models.CampaignWithRules{Campaign: models.Campaign{ID: mux.Vars(r)["id"]}, Rules: rules}
+		for i := range campaign.Rules {
+			campaign.Rules[i].CampaignID = campaign.ID
+		}
+		var problems []string
+		for _, err := range campaign.ValidateTargeting() {
+			problems = append(problems, err.Error())
+		}
+		if len(problems) > 0 {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("invalid rules: "+strings.Join(problems, "; ")))
+			return
+		}
+
+		setRules(w, r, store, c, models.RuleSetVersion{CampaignID: campaign.ID, Rules: campaign.Rules, Comment: body.Comment})
+	}
+}
+
+// createListRuleVersionsHandler creates a handler listing the rule set versions of the campaign
+// named in the path newest first, each with its changes against the previous one
+func createListRuleVersionsHandler(store service.CampaignStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		versions, err := store.ListRuleVersions(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+
+		history := make([]ruleVersion, len(versions))
+		for i := range versions {
+			history[i] = versionChanges(versions, i)
+		}
+		writeJSON(w, http.StatusOK, history)
+	}
+}
+
+// parseVersion parses the rule set version in the path
+func parseVersion(r *http.Request) (int, error) {
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("version must be a positive integer")
+	}
+	return version, nil
+}
+
+// createRuleVersionHandler creates a handler returning a rule set version of the campaign named in
+// the path with its changes against the previous version, or against the version given by the
+// against query parameter
+func createRuleVersionHandler(store service.CampaignStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		number, err := parseVersion(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+			return
+		}
+		against := 0
+		if value := r.URL.Query().Get("against"); value != "" {
+			if against, err = strconv.Atoi(value); err != nil || against < 1 {
+				writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("against must be a positive integer"))
+				return
+			}
+		}
+
+		versions, err := store.ListRuleVersions(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		i := findVersion(versions, number)
+		if i < 0 {
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse(fmt.Sprintf("rule version %d not found", number)))
+			return
+		}
+		if against == 0 {
+			writeJSON(w, http.StatusOK, versionChanges(versions, i))
+			return
+		}
+		j := findVersion(versions, against)
+		if j < 0 {
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse(fmt.Sprintf("rule version %d not found", against)))
+			return
+		}
+		writeJSON(w, http.StatusOK, newRuleVersion(versions[i], &versions[j]))
+	}
+}
+
+// createRulesRollbackHandler creates a handler restoring the rules of a version of the campaign
+// named in the path. The rollback is recorded as a new version, so it can be rolled back too.
+func createRulesRollbackHandler(store service.CampaignStore, c cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		number, err := parseVersion(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+			return
+		}
+
+		id := mux.Vars(r)["id"]
+		versions, err := store.ListRuleVersions(r.Context(), id)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		i := findVersion(versions, number)
+		if i < 0 {
+			writeJSON(w, http.StatusNotFound, models.NewErrorResponse(fmt.Sprintf("rule version %d not found", number)))
+			return
+		}
+
+		setRules(w, r, store, c, models.RuleSetVersion{
+			CampaignID: id,
+			Rules:      versions[i].Rules,
+			Comment:    fmt.Sprintf("rollback to version %d", number),
+		})
+	}
+}
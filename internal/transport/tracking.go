@@ -0,0 +1,87 @@
+package transport
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/tracking"
+)
+
+// transparentGIF is the 1x1 transparent pixel answered by the impression endpoint
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// decodeTrackingEvent decodes the campaign, the delivery request ID and the dimensions of a
+// tracked event from the query string, dimensions are normalized like delivery requests
+func decodeTrackingEvent(r *http.Request, eventType tracking.EventType) (tracking.Event, bool) {
+	query := r.URL.Query()
+	dimensions := models.DeliveryRequest{
+		Country: query.Get("country"),
+		OS:      query.Get("os"),
+		App:     query.Get("app"),
+		State:   query.Get("state"),
+	}
+	dimensions.NormalizeValues()
+
+	event := tracking.Event{
+		Type:       eventType,
+		CampaignID: query.Get("campaign"),
+		RequestID:  query.Get("request_id"),
+		Country:    dimensions.Country,
+		OS:         dimensions.OS,
+		App:        dimensions.App,
+		State:      dimensions.State,
+		Time:       time.Now().UTC(),
+	}
+	return event, event.CampaignID != ""
+}
+
+// createImpressionHandler creates a handler recording an impression and answering with a
+// transparent pixel, so it can be embedded as an image next to the rendered campaign
+func createImpressionHandler(recorder tracking.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		event, ok := decodeTrackingEvent(r, tracking.EventImpression)
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("campaign is required"))
+			return
+		}
+		recorder.Record(r.Context(), event)
+
+		// Every render must reach the server, caches would swallow impressions
+		w.Header().Set("Content-Type", "image/gif")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write(transparentGIF)
+	}
+}
+
+// createClickHandler creates a handler recording a click and redirecting to the url parameter,
+// only destinations allowed by policy are redirected to
+func createClickHandler(recorder tracking.Recorder, policy *tracking.RedirectPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		event, ok := decodeTrackingEvent(r, tracking.EventClick)
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("campaign is required"))
+			return
+		}
+
+		destination, err := url.Parse(r.URL.Query().Get("url"))
+		if err != nil || !destination.IsAbs() {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("url must be an absolute URL"))
+			return
+		}
+		if !policy.Allowed(destination) {
+			writeJSON(w, http.StatusBadRequest, models.NewErrorResponse("url host is not allowed"))
+			return
+		}
+		recorder.Record(r.Context(), event)
+
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, destination.String(), http.StatusFound)
+	}
+}
@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/tracking"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackingEndpoints(t *testing.T) {
+	var events []tracking.Event
+	recorder := tracking.RecorderFunc(func(_ context.Context, event tracking.Event) {
+		events = append(events, event)
+	})
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{
+		Tracking:       recorder,
+		ClickRedirects: tracking.NewRedirectPolicy([]string{"example.com"}),
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	// Impressions answer with a pixel that must not be cached
+	w := serve("/v1/track/impression?campaign=spotify&request_id=req-1&country=US&os=Android&app=com.app&state=")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/gif", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, transparentGIF, w.Body.Bytes())
+	require.Len(t, events, 1)
+	assert.Equal(t, tracking.EventImpression, events[0].Type)
+	assert.Equal(t, "spotify", events[0].CampaignID)
+	assert.Equal(t, "req-1", events[0].RequestID)
+	assert.Equal(t, "us", events[0].Country)
+	assert.Equal(t, "android", events[0].OS)
+	assert.Equal(t, "com.app", events[0].App)
+	assert.False(t, events[0].Time.IsZero())
+
+	// Clicks redirect to allowed destinations
+	w = serve("/v1/track/click?campaign=spotify&request_id=req-1&url=https%3A%2F%2Fwww.example.com%2Flanding%3Fref%3Dad")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://www.example.com/landing?ref=ad", w.Header().Get("Location"))
+	require.Len(t, events, 2)
+	assert.Equal(t, tracking.EventClick, events[1].Type)
+
+	// Invalid events are rejected without being recorded
+	for _, path := range []string{
+		"/v1/track/impression?request_id=req-1",
+		"/v1/track/click?url=https%3A%2F%2Fexample.com%2F",
+		"/v1/track/click?campaign=spotify",
+		"/v1/track/click?campaign=spotify&url=%2Flanding",
+		"/v1/track/click?campaign=spotify&url=https%3A%2F%2Fevil.io%2F",
+	} {
+		w = serve(path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+	assert.Len(t, events, 2)
+}
+
+func TestTrackingEndpointsDisabled(t *testing.T) {
+	handler := NewHTTPHandlerWithOptions(endpoint.DeliveryEndpoints{}, log.NewNopLogger(), HandlerOptions{})
+
+	for _, path := range []string{"/v1/track/impression?campaign=spotify", "/v1/track/click?campaign=spotify"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
@@ -0,0 +1,68 @@
+// Package version holds the build information of the binaries, set at link time with
+//
+//	go build -ldflags "-X github.com/prajwalbharadwajbm/adbeacon/internal/version.Version=1.4.0 \
+//	  -X github.com/prajwalbharadwajbm/adbeacon/internal/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/prajwalbharadwajbm/adbeacon/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X, see the package documentation
+var (
+	// Version is the release version, dev for builds outside a release
+	Version = "dev"
+	// Commit is the git commit built, empty to read it from the VCS information Go embeds
+	Commit = ""
+	// BuildDate is when the binary was built in RFC 3339, empty to use the commit time
+	BuildDate = ""
+)
+
+// Info is the build information of the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the build information. A commit and build date not set at link time are taken
+// from the VCS information of the build, "unknown" without one.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if info.Commit == "" || info.BuildDate == "" {
+		if build, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range build.Settings {
+				switch {
+				case setting.Key == "vcs.revision" && info.Commit == "":
+					info.Commit = setting.Value[:min(len(setting.Value), 12)]
+				case setting.Key == "vcs.time" && info.BuildDate == "":
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// String describes the build in one line, e.g. "1.4.0 (commit 3f2a9c1, built
+// 2025-01-01T12:00:00Z, go1.24.1 linux/amd64)"
+func (i Info) String() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s %s)", i.Version, i.Commit, i.BuildDate, i.GoVersion, i.Platform)
+}
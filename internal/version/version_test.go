@@ -0,0 +1,29 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, BuildDate = v, c, d }(Version, Commit, BuildDate)
+	Version, Commit, BuildDate = "1.4.0", "3f2a9c1", "2025-01-01T12:00:00Z"
+
+	info := Get()
+	assert.Equal(t, Info{
+		Version:   "1.4.0",
+		Commit:    "3f2a9c1",
+		BuildDate: "2025-01-01T12:00:00Z",
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}, info)
+	assert.Equal(t, "1.4.0 (commit 3f2a9c1, built 2025-01-01T12:00:00Z, "+runtime.Version()+" "+runtime.GOOS+"/"+runtime.GOARCH+")", info.String())
+
+	// Test binaries carry no VCS information
+	Commit, BuildDate = "", ""
+	info = Get()
+	assert.Equal(t, "unknown", info.Commit)
+	assert.Equal(t, "unknown", info.BuildDate)
+}
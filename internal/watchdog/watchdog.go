@@ -0,0 +1,111 @@
+package watchdog
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Resources checked by the watchdog, used as the resource label
+const (
+	ResourceGoroutines = "goroutines"
+	ResourceHeap       = "heap"
+)
+
+// Config holds watchdog thresholds, a zero threshold disables that check
+type Config struct {
+	Interval      time.Duration
+	MaxGoroutines int
+	MaxHeapBytes  uint64
+}
+
+// Watchdog periodically checks goroutine count and heap size, logging and
+// counting every check that finds a threshold exceeded
+type Watchdog struct {
+	config   Config
+	logger   log.Logger
+	exceeded *prometheus.CounterVec
+
+	// Overridable for tests
+	goroutines func() int
+	heapBytes  func() uint64
+}
+
+// New creates a new watchdog, register it with Prometheus to export its counter
+func New(config Config, logger log.Logger) *Watchdog {
+	if config.Interval <= 0 {
+		config.Interval = 15 * time.Second
+	}
+
+	return &Watchdog{
+		config: config,
+		logger: logger,
+		exceeded: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adbeacon_watchdog_threshold_exceeded_total",
+				Help: "Total number of watchdog checks that found a resource above its threshold",
+			},
+			[]string{"resource"},
+		),
+		goroutines: runtime.NumGoroutine,
+		heapBytes: func() uint64 {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			return stats.HeapAlloc
+		},
+	}
+}
+
+// Run checks resources every interval until ctx is canceled
+func (w *Watchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check compares current usage against the thresholds
+func (w *Watchdog) check() {
+	if w.config.MaxGoroutines > 0 {
+		if count := w.goroutines(); count > w.config.MaxGoroutines {
+			w.exceeded.WithLabelValues(ResourceGoroutines).Inc()
+			level.Warn(w.logger).Log(
+				"msg", "goroutine count above threshold",
+				"goroutines", count,
+				"threshold", w.config.MaxGoroutines,
+			)
+		}
+	}
+
+	if w.config.MaxHeapBytes > 0 {
+		if heap := w.heapBytes(); heap > w.config.MaxHeapBytes {
+			w.exceeded.WithLabelValues(ResourceHeap).Inc()
+			level.Warn(w.logger).Log(
+				"msg", "heap size above threshold",
+				"heap_bytes", heap,
+				"threshold", w.config.MaxHeapBytes,
+			)
+		}
+	}
+}
+
+// Describe implements prometheus.Collector
+func (w *Watchdog) Describe(ch chan<- *prometheus.Desc) {
+	w.exceeded.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (w *Watchdog) Collect(ch chan<- prometheus.Metric) {
+	w.exceeded.Collect(ch)
+}
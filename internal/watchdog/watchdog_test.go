@@ -0,0 +1,42 @@
+package watchdog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdog_Check(t *testing.T) {
+	var buf bytes.Buffer
+	w := New(Config{MaxGoroutines: 100, MaxHeapBytes: 1 << 20}, log.NewLogfmtLogger(&buf))
+	w.goroutines = func() int { return 50 }
+	w.heapBytes = func() uint64 { return 1 << 10 }
+
+	w.check()
+	assert.Equal(t, 0, buf.Len())
+
+	w.goroutines = func() int { return 150 }
+	w.heapBytes = func() uint64 { return 2 << 20 }
+	w.check()
+	w.check()
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(w.exceeded.WithLabelValues(ResourceGoroutines)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(w.exceeded.WithLabelValues(ResourceHeap)))
+	assert.Contains(t, buf.String(), "goroutine count above threshold")
+	assert.Contains(t, buf.String(), "heap size above threshold")
+}
+
+func TestWatchdog_DisabledThresholds(t *testing.T) {
+	var buf bytes.Buffer
+	w := New(Config{}, log.NewLogfmtLogger(&buf))
+	w.goroutines = func() int { return 1 << 20 }
+	w.heapBytes = func() uint64 { return 1 << 40 }
+
+	w.check()
+
+	assert.Equal(t, 0, buf.Len())
+	assert.Equal(t, 0, testutil.CollectAndCount(w))
+}
@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+)
+
+// notifyingStore notifies the campaign changes made through a CampaignStore
+type notifyingStore struct {
+	service.CampaignStore
+	dispatcher *Dispatcher
+}
+
+// NewNotifyingStore wraps store to notify dispatcher of every created, paused and resumed campaign
+func NewNotifyingStore(store service.CampaignStore, dispatcher *Dispatcher) service.CampaignStore {
+	return &notifyingStore{CampaignStore: store, dispatcher: dispatcher}
+}
+
+// CreateCampaign implements service.CampaignStore, notifying campaign.created
+func (s *notifyingStore) CreateCampaign(ctx context.Context, campaign models.CampaignWithRules) error {
+	if err := s.CampaignStore.CreateCampaign(ctx, campaign); err != nil {
+		return err
+	}
+
+	event := NewEvent(EventCampaignCreated, campaign.ID, campaign.Status)
+	event.Campaign = &campaign
+	s.dispatcher.Notify(event)
+	return nil
+}
+
+// SetCampaignStatus implements service.CampaignStore, notifying campaign.paused or campaign.resumed
+func (s *notifyingStore) SetCampaignStatus(ctx context.Context, id string, status models.CampaignStatus) error {
+	if err := s.CampaignStore.SetCampaignStatus(ctx, id, status); err != nil {
+		return err
+	}
+
+	eventType := EventCampaignPaused
+	if status == models.StatusActive {
+		eventType = EventCampaignResumed
+	}
+	s.dispatcher.Notify(NewEvent(eventType, id, status))
+	return nil
+}
+
+// SetCampaignStatuses implements service.CampaignStore, notifying campaign.paused or
+// campaign.resumed for each campaign
+func (s *notifyingStore) SetCampaignStatuses(ctx context.Context, ids []string, status models.CampaignStatus) error {
+	if err := s.CampaignStore.SetCampaignStatuses(ctx, ids, status); err != nil {
+		return err
+	}
+
+	eventType := EventCampaignPaused
+	if status == models.StatusActive {
+		eventType = EventCampaignResumed
+	}
+	for _, id := range ids {
+		s.dispatcher.Notify(NewEvent(eventType, id, status))
+	}
+	return nil
+}
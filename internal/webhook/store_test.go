@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyingStore(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	dispatcher := NewDispatcher(Config{URLs: []string{server.URL}}, server.Client(), log.NewNopLogger(), nil)
+	store := NewNotifyingStore(repository.NewMockRepository().(service.CampaignStore), dispatcher)
+	ctx := context.Background()
+
+	netflix := models.CampaignWithRules{Campaign: models.Campaign{ID: "netflix", Name: "Netflix", Status: models.StatusActive}}
+	require.NoError(t, store.CreateCampaign(ctx, netflix))
+	require.NoError(t, store.SetCampaignStatus(ctx, "netflix", models.StatusInactive))
+	require.NoError(t, store.SetCampaignStatus(ctx, "netflix", models.StatusActive))
+	require.NoError(t, store.SetCampaignStatuses(ctx, []string{"netflix", "spotify"}, models.StatusInactive))
+
+	// Failed changes aren't notified
+	assert.ErrorIs(t, store.CreateCampaign(ctx, netflix), service.ErrCampaignExists)
+	assert.ErrorIs(t, store.SetCampaignStatus(ctx, "unknown", models.StatusInactive), service.ErrCampaignNotFound)
+	assert.ErrorIs(t, store.SetCampaignStatuses(ctx, []string{"netflix", "unknown"}, models.StatusActive), service.ErrCampaignNotFound)
+
+	require.NoError(t, dispatcher.Close(ctx))
+	require.Len(t, rc.bodies, 5)
+	var events []Event
+	for _, body := range rc.bodies {
+		var event Event
+		require.NoError(t, json.Unmarshal(body, &event))
+		events = append(events, event)
+	}
+	assert.Equal(t, EventCampaignCreated, events[0].Type)
+	require.NotNil(t, events[0].Campaign)
+	assert.Equal(t, "Netflix", events[0].Campaign.Name)
+	assert.Equal(t, EventCampaignPaused, events[1].Type)
+	assert.Equal(t, EventCampaignResumed, events[2].Type)
+	assert.Equal(t, models.StatusActive, events[2].Status)
+	for _, event := range events[3:] {
+		assert.Equal(t, EventCampaignPaused, event.Type)
+	}
+}
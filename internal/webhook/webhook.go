@@ -0,0 +1,262 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/signing"
+)
+
+// Campaign lifecycle event types
+const (
+	EventCampaignCreated = "campaign.created"
+	EventCampaignPaused  = "campaign.paused"
+	EventCampaignResumed = "campaign.resumed"
+)
+
+// Headers of webhook requests
+const (
+	EventHeader     = "X-Adbeacon-Event"
+	DeliveryHeader  = "X-Adbeacon-Delivery"
+	TimestampHeader = signing.TimestampHeader
+	SignatureHeader = signing.SignatureHeader
+)
+
+// Outcomes of webhook deliveries, used as metric labels
+const (
+	OutcomeDelivered = "delivered"
+	OutcomeRetried   = "retried"
+	// OutcomeDeadLettered deliveries were given up on and logged, because every attempt failed,
+	// the queue was full or the dispatcher closed
+	OutcomeDeadLettered = "dead_lettered"
+)
+
+// Event is the JSON body POSTed to the webhook URLs
+type Event struct {
+	// ID is unique per event and the same for every URL and retry, receivers can deduplicate on it
+	ID         string                `json:"id"`
+	Type       string                `json:"type"`
+	Time       time.Time             `json:"time"`
+	CampaignID string                `json:"cid"`
+	Status     models.CampaignStatus `json:"status,omitempty"`
+	// Campaign is the created campaign with its rules, only set for campaign.created
+	Campaign *models.CampaignWithRules `json:"campaign,omitempty"`
+}
+
+// NewEvent creates an event of eventType about a campaign
+func NewEvent(eventType, campaignID string, status models.CampaignStatus) Event {
+	return Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		Time:       time.Now().UTC(),
+		CampaignID: campaignID,
+		Status:     status,
+	}
+}
+
+// Recorder counts webhook deliveries by outcome, metrics.CachedMetrics implements it
+type Recorder interface {
+	RecordWebhook(outcome string)
+}
+
+// Config holds the destinations and retry settings of a Dispatcher
+type Config struct {
+	// URLs receive every event
+	URLs []string
+	// Secret signs the requests, unsigned when empty
+	Secret string
+	// QueueSize bounds the events waiting for delivery, further events are dead-lettered
+	QueueSize int
+	// Timeout bounds a single delivery attempt
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts per URL before an event is dead-lettered
+	MaxAttempts int
+	// RetryDelay is the backoff before the first retry, doubled for every further one
+	RetryDelay time.Duration
+}
+
+// delivery is an event queued with its encoded body
+type delivery struct {
+	event Event
+	body  []byte
+}
+
+// Dispatcher POSTs events to the configured URLs from a single goroutine. Notifying never
+// blocks, events that can't be delivered are logged as dead letters with their full body
+// so they can be replayed by hand.
+type Dispatcher struct {
+	config   Config
+	client   *http.Client
+	logger   log.Logger
+	recorder Recorder
+	queue    chan delivery
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewDispatcher creates a dispatcher and starts its delivery loop, zero config values are
+// replaced by defaults. A nil client uses http.DefaultClient.
+func NewDispatcher(config Config, client *http.Client, logger log.Logger, recorder Recorder) *Dispatcher {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1000
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	d := &Dispatcher{
+		config:   config,
+		client:   client,
+		logger:   logger,
+		recorder: recorder,
+		queue:    make(chan delivery, config.QueueSize),
+		done:     make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Notify queues an event for delivery to every URL
+func (d *Dispatcher) Notify(event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.deadLetter(delivery{event: event}, "", fmt.Errorf("failed to encode event: %w", err))
+		return
+	}
+	queued := delivery{event: event, body: body}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		d.deadLetter(queued, "", fmt.Errorf("dispatcher closed"))
+		return
+	}
+
+	select {
+	case d.queue <- queued:
+	default:
+		d.deadLetter(queued, "", fmt.Errorf("queue full"))
+	}
+}
+
+// Len returns the number of queued events
+func (d *Dispatcher) Len() int {
+	return len(d.queue)
+}
+
+// Close stops accepting events and waits until the queued events are delivered or ctx is done
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run delivers queued events in order until the queue is closed and drained
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for queued := range d.queue {
+		for _, url := range d.config.URLs {
+			d.deliver(queued, url)
+		}
+	}
+}
+
+// deliver POSTs an event to url, retrying with exponential backoff
+func (d *Dispatcher) deliver(queued delivery, url string) {
+	delay := d.config.RetryDelay
+	for attempt := 1; ; attempt++ {
+		retryable, err := d.post(queued, url)
+		if err == nil {
+			d.record(OutcomeDelivered)
+			return
+		}
+		if !retryable || attempt >= d.config.MaxAttempts {
+			d.deadLetter(queued, url, err)
+			return
+		}
+		d.record(OutcomeRetried)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post makes a single delivery attempt, it reports whether a failure is worth retrying
+func (d *Dispatcher) post(queued delivery, url string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(queued.body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, queued.event.Type)
+	req.Header.Set(DeliveryHeader, queued.event.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	if d.config.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.config.Secret, timestamp, queued.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook request failed: %w", err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	// Server errors and throttling may pass, other client errors won't
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return retryable, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+// deadLetter logs an event that won't be delivered to url, or to any URL when url is empty
+func (d *Dispatcher) deadLetter(queued delivery, url string, err error) {
+	d.record(OutcomeDeadLettered)
+	level.Error(d.logger).Log("msg", "webhook dead-lettered", "event_id", queued.event.ID, "type", queued.event.Type,
+		"url", url, "err", err, "body", string(queued.body))
+}
+
+func (d *Dispatcher) record(outcome string) {
+	if d.recorder != nil {
+		d.recorder.RecordWebhook(outcome)
+	}
+}
+
+// Sign returns the signature of a webhook body sent at timestamp, the hex HMAC-SHA256 of
+// "{timestamp}.{body}" prefixed with "sha256=". Receivers compute it over the raw body and the
+// X-Adbeacon-Timestamp header, and should reject old timestamps to prevent replays.
+func Sign(secret, timestamp string, body []byte) string {
+	return signing.Sign(secret, timestamp, body)
+}
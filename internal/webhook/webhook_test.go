@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRecorder counts webhook deliveries by outcome
+type countingRecorder struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (r *countingRecorder) RecordWebhook(outcome string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = make(map[string]int)
+	}
+	r.counts[outcome]++
+}
+
+// receiver is a webhook endpoint answering with the queued statuses, then 200
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	if len(rc.statuses) > 0 {
+		w.WriteHeader(rc.statuses[0])
+		rc.statuses = rc.statuses[1:]
+	}
+}
+
+func TestDispatcher_SignedDelivery(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	recorder := &countingRecorder{}
+	dispatcher := NewDispatcher(Config{URLs: []string{server.URL, server.URL + "/second"}, Secret: "s3cret", MaxAttempts: 1}, server.Client(), log.NewNopLogger(), recorder)
+
+	event := NewEvent(EventCampaignPaused, "spotify", models.StatusInactive)
+	dispatcher.Notify(event)
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	// Every URL receives the event
+	require.Len(t, rc.requests, 2)
+	assert.Equal(t, "/second", rc.requests[1].URL.Path)
+	assert.Equal(t, map[string]int{OutcomeDelivered: 2}, recorder.counts)
+
+	req, body := rc.requests[0], rc.bodies[0]
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, EventCampaignPaused, req.Header.Get(EventHeader))
+	assert.Equal(t, event.ID, req.Header.Get(DeliveryHeader))
+	assert.Equal(t, Sign("s3cret", req.Header.Get(TimestampHeader), body), req.Header.Get(SignatureHeader))
+
+	var received Event
+	require.NoError(t, json.Unmarshal(body, &received))
+	assert.Equal(t, "spotify", received.CampaignID)
+	assert.Equal(t, models.StatusInactive, received.Status)
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	recorder := &countingRecorder{}
+	dispatcher := NewDispatcher(Config{URLs: []string{server.URL}, MaxAttempts: 3, RetryDelay: time.Millisecond}, server.Client(), log.NewNopLogger(), recorder)
+
+	dispatcher.Notify(NewEvent(EventCampaignResumed, "spotify", models.StatusActive))
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	// Retries carry the same delivery ID, unsigned without a secret
+	require.Len(t, rc.requests, 3)
+	assert.Equal(t, rc.requests[0].Header.Get(DeliveryHeader), rc.requests[2].Header.Get(DeliveryHeader))
+	assert.Empty(t, rc.requests[0].Header.Get(SignatureHeader))
+	assert.Equal(t, map[string]int{OutcomeRetried: 2, OutcomeDelivered: 1}, recorder.counts)
+}
+
+func TestDispatcher_DeadLetters(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusInternalServerError}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	recorder := &countingRecorder{}
+	var logs bytes.Buffer
+	dispatcher := NewDispatcher(Config{URLs: []string{server.URL}, MaxAttempts: 2, RetryDelay: time.Millisecond}, server.Client(), log.NewLogfmtLogger(&logs), recorder)
+
+	// Rejected events aren't retried, failing ones are until the attempts run out
+	dispatcher.Notify(NewEvent(EventCampaignPaused, "spotify", models.StatusInactive))
+	dispatcher.Notify(NewEvent(EventCampaignPaused, "duolingo", models.StatusInactive))
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Len(t, rc.requests, 3)
+	assert.Equal(t, map[string]int{OutcomeRetried: 1, OutcomeDeadLettered: 2}, recorder.counts)
+	// Dead letters are logged with their body so they can be replayed
+	assert.Equal(t, 2, strings.Count(logs.String(), `msg="webhook dead-lettered"`))
+	assert.Contains(t, logs.String(), `\"cid\":\"duolingo\"`)
+
+	// Events notified after closing are dead-lettered too
+	dispatcher.Notify(NewEvent(EventCampaignPaused, "netflix", models.StatusInactive))
+	assert.Equal(t, 3, recorder.counts[OutcomeDeadLettered])
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_delivery_stats_campaign_hour;
+DROP TABLE IF EXISTS delivery_stats;
@@ -0,0 +1,13 @@
+-- Hourly delivery, impression and click counts per campaign and country, written by the reporting aggregator
+CREATE TABLE delivery_stats (
+    hour TIMESTAMP WITH TIME ZONE NOT NULL,
+    campaign_id VARCHAR(255) NOT NULL,
+    country VARCHAR(64) NOT NULL DEFAULT '',
+    deliveries BIGINT NOT NULL DEFAULT 0,
+    impressions BIGINT NOT NULL DEFAULT 0,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (hour, campaign_id, country)
+);
+
+-- Reports of a single campaign
+CREATE INDEX idx_delivery_stats_campaign_hour ON delivery_stats(campaign_id, hour);
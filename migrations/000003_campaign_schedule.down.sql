@@ -0,0 +1,5 @@
+ALTER TABLE campaigns
+    DROP CONSTRAINT IF EXISTS campaigns_schedule_order,
+    DROP COLUMN IF EXISTS delivery_budget,
+    DROP COLUMN IF EXISTS ends_at,
+    DROP COLUMN IF EXISTS starts_at;
@@ -0,0 +1,6 @@
+-- Optional start and end times and delivery budgets, enforced by the campaign scheduler
+ALTER TABLE campaigns
+    ADD COLUMN starts_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN ends_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN delivery_budget BIGINT NOT NULL DEFAULT 0 CHECK (delivery_budget >= 0),
+    ADD CONSTRAINT campaigns_schedule_order CHECK (ends_at IS NULL OR starts_at IS NULL OR ends_at > starts_at);
@@ -0,0 +1,25 @@
+-- Every rule set a campaign had, the highest version holds its current rules
+CREATE TABLE campaign_rule_versions (
+    campaign_id VARCHAR(255) NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK (version > 0),
+    rules JSONB NOT NULL,
+    author VARCHAR(255) NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (campaign_id, version)
+);
+
+-- The existing rules become version 1
+INSERT INTO campaign_rule_versions (campaign_id, version, rules)
+SELECT c.id, 1, COALESCE(
+    (SELECT jsonb_agg(jsonb_build_object(
+        'campaign_id', r.campaign_id,
+        'dimension', r.dimension,
+        'rule_type', r.rule_type,
+        'values', to_jsonb(r.values)
+    ) ORDER BY r.id)
+    FROM targeting_rules r
+    WHERE r.campaign_id = c.id),
+    '[]'::jsonb
+)
+FROM campaigns c;
@@ -0,0 +1,3 @@
+-- Percentage of the matching traffic a campaign is served to for staged rollouts, 0 for all of it
+ALTER TABLE campaigns
+    ADD COLUMN traffic_pct SMALLINT NOT NULL DEFAULT 0 CHECK (traffic_pct BETWEEN 0 AND 100);
@@ -0,0 +1,9 @@
+-- Apps, countries and IP ranges blocked for every campaign, checked before campaigns are matched
+CREATE TABLE blocklist_entries (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('app', 'country', 'ip_range')),
+    value VARCHAR(255) NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kind, value)
+);
@@ -0,0 +1,3 @@
+-- Competitive category of a campaign, responses serve at most one campaign of a category
+ALTER TABLE campaigns
+    ADD COLUMN category VARCHAR(64) NOT NULL DEFAULT '';
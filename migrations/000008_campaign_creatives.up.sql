@@ -0,0 +1,4 @@
+-- Localized creatives of a campaign, [{"lang": "es", "img": "...", "cta": "..."}], served to
+-- requests in their language instead of the campaign's image and CTA
+ALTER TABLE campaigns
+    ADD COLUMN creatives JSONB NOT NULL DEFAULT '[]';
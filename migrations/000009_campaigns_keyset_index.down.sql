@@ -0,0 +1,2 @@
+CREATE INDEX idx_campaigns_status_updated ON campaigns(status, updated_at);
+DROP INDEX IF EXISTS idx_campaigns_status_updated_id;
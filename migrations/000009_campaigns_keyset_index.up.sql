@@ -0,0 +1,3 @@
+-- Pages of active campaigns are read by (updated_at, id) when the cache loads them in batches
+CREATE INDEX idx_campaigns_status_updated_id ON campaigns(status, updated_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_campaigns_status_updated;
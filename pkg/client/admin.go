@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ListCampaigns returns every campaign with its targeting rules, including inactive ones
+func (c *Client) ListCampaigns(ctx context.Context) ([]AdminCampaign, error) {
+	var campaigns []AdminCampaign
+	err := c.do(ctx, http.MethodGet, "/v1/admin/campaigns", nil, &campaigns)
+	return campaigns, err
+}
+
+// CreateCampaign stores a new campaign and returns it as stored, a campaign with the same ID is
+// rejected with a 409 *APIError
+func (c *Client) CreateCampaign(ctx context.Context, campaign AdminCampaign) (AdminCampaign, error) {
+	var created AdminCampaign
+	err := c.do(ctx, http.MethodPost, "/v1/admin/campaigns", campaign, &created)
+	return created, err
+}
+
+// PauseCampaign stops serving the campaign
+func (c *Client) PauseCampaign(ctx context.Context, cid string) (StatusChange, error) {
+	var change StatusChange
+	err := c.do(ctx, http.MethodPost, campaignPath(cid, "pause"), nil, &change)
+	return change, err
+}
+
+// ResumeCampaign serves the campaign again
+func (c *Client) ResumeCampaign(ctx context.Context, cid string) (StatusChange, error) {
+	var change StatusChange
+	err := c.do(ctx, http.MethodPost, campaignPath(cid, "resume"), nil, &change)
+	return change, err
+}
+
+// SetTrafficPct serves the campaign to pct percent of the matching traffic, 0 for all of it
+func (c *Client) SetTrafficPct(ctx context.Context, cid string, pct int) error {
+	body := struct {
+		TrafficPct int `json:"traffic_pct"`
+	}{pct}
+	return c.do(ctx, http.MethodPut, campaignPath(cid, "traffic"), body, nil)
+}
+
+// SetCategory sets the competitive category of the campaign, empty for none
+func (c *Client) SetCategory(ctx context.Context, cid, category string) error {
+	body := struct {
+		Category string `json:"category"`
+	}{category}
+	return c.do(ctx, http.MethodPut, campaignPath(cid, "category"), body, nil)
+}
+
+// SetImage sets the image of the campaign, the server may store it rewritten to its CDN
+func (c *Client) SetImage(ctx context.Context, cid, img string) error {
+	body := struct {
+		Img string `json:"img"`
+	}{img}
+	return c.do(ctx, http.MethodPut, campaignPath(cid, "image"), body, nil)
+}
+
+// SetCreatives replaces the localized creatives of the campaign, none to serve its image and
+// CTA in every language. The server may store the images rewritten to its CDN.
+func (c *Client) SetCreatives(ctx context.Context, cid string, creatives []Creative) error {
+	if creatives == nil {
+		creatives = []Creative{}
+	}
+	body := struct {
+		Creatives []Creative `json:"creatives"`
+	}{creatives}
+	return c.do(ctx, http.MethodPut, campaignPath(cid, "creatives"), body, nil)
+}
+
+// SetRules replaces the targeting rules of the campaign, returning the new rule set version
+func (c *Client) SetRules(ctx context.Context, cid string, update RulesUpdate) (RuleVersion, error) {
+	var version RuleVersion
+	err := c.do(ctx, http.MethodPut, campaignPath(cid, "rules"), update, &version)
+	return version, err
+}
+
+// ListRuleVersions returns the rule set versions of the campaign newest first
+func (c *Client) ListRuleVersions(ctx context.Context, cid string) ([]RuleVersion, error) {
+	var versions []RuleVersion
+	err := c.do(ctx, http.MethodGet, campaignPath(cid, "rules/versions"), nil, &versions)
+	return versions, err
+}
+
+// InvalidateCache drops the cached campaigns, deliveries read them from the database again
+func (c *Client) InvalidateCache(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/v1/admin/cache/invalidate", nil, nil)
+}
+
+// campaignPath returns the path of a campaign endpoint
+func campaignPath(cid, endpoint string) string {
+	return "/v1/admin/campaigns/" + url.PathEscape(cid) + "/" + endpoint
+}
@@ -0,0 +1,270 @@
+// Package client is a Go client for the delivery and admin APIs of an adbeacon server, for
+// services calling adbeacon instead of building the HTTP requests themselves. Calls are retried
+// on network errors, 429 and 5xx responses: delivery calls carry a nonce and admin changes an
+// Idempotency-Key, both kept across retries, so the server applies a retried call only once.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the options
+const (
+	DefaultTimeout      = 5 * time.Second
+	DefaultMaxRetries   = 2
+	DefaultRetryBackoff = 100 * time.Millisecond
+	// maxRetryWait caps the wait between attempts, whatever the backoff or Retry-After
+	maxRetryWait = 5 * time.Second
+)
+
+// Client calls the delivery and admin APIs of an adbeacon server, it is safe for concurrent use
+type Client struct {
+	baseURL      string
+	http         *http.Client
+	apiKey       string
+	adminUser    string
+	adminToken   string
+	maxRetries   int
+	retryBackoff time.Duration
+	// signingSecret signs delivery requests and verifies the signatures of their responses when set
+	signingSecret string
+	now           func() time.Time
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout bounds each attempt of a call, DefaultTimeout unless set. The context of the call
+// bounds it with its retries.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) { c.http.Timeout = timeout }
+}
+
+// WithHTTPClient sends the requests with a copy of httpClient, e.g. for a custom transport. Its
+// timeout is kept unless it has none, a timeout set with WithTimeout applies to it.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		copied := *httpClient
+		if copied.Timeout == 0 {
+			copied.Timeout = c.http.Timeout
+		}
+		c.http = &copied
+	}
+}
+
+// WithRetries retries failed calls up to maxRetries times, waiting backoff doubled after every
+// attempt with jitter, or as long as a Retry-After header asks. 0 disables retries.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = max(maxRetries, 0)
+		c.retryBackoff = backoff
+	}
+}
+
+// WithAPIKey sends key in the X-API-Key header, which quotas and metrics are kept by
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithAdminUser sends user in the X-Admin-User header, the server records it as the author of
+// admin changes
+func WithAdminUser(user string) Option {
+	return func(c *Client) { c.adminUser = user }
+}
+
+// WithAdminToken sends token as a bearer token on admin API calls, the server rejects them
+// without the admin token. Delivery calls never carry it.
+func WithAdminToken(token string) Option {
+	return func(c *Client) { c.adminToken = token }
+}
+
+// WithSigningSecret verifies the signature of successful delivery responses with secret, the
+// signing secret of the API key. Responses that aren't signed, signed with another secret or signed more
+// than SignatureMaxAge ago fail with ErrInvalidSignature. Delivery requests are signed with it too,
+// servers with replay protection reject those of API keys that aren't.
+func WithSigningSecret(secret string) Option {
+	return func(c *Client) { c.signingSecret = secret }
+}
+
+// New creates a client for the server at baseURL, e.g. http://adbeacon:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		http:         &http.Client{Timeout: DefaultTimeout},
+		maxRetries:   DefaultMaxRetries,
+		retryBackoff: DefaultRetryBackoff,
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is an error response of the server
+type APIError struct {
+	StatusCode int
+	// Message is the error reported by the server, the status text when it reported none
+	Message string
+	// Fields lists every invalid field of a rejected delivery request
+	Fields []FieldError
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("adbeacon: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response, e.g. for an unknown campaign
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// errorResponse is the body of error responses
+type errorResponse struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// do sends a request with body encoded as JSON, if not nil, retrying it when it fails, and
+// decodes the response into out, if not nil.
+// Changes sent to the admin API carry an Idempotency-Key, the same for every attempt.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	header := make(http.Header)
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		header.Set("X-API-Key", c.apiKey)
+	}
+	if c.adminUser != "" {
+		header.Set("X-Admin-User", c.adminUser)
+	}
+	if strings.HasPrefix(path, "/v1/admin/") {
+		if c.adminToken != "" {
+			header.Set("Authorization", "Bearer "+c.adminToken)
+		}
+		if method != http.MethodGet {
+			header.Set("Idempotency-Key", newToken())
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(encoded))
+		if err != nil {
+			return err
+		}
+		req.Header = header.Clone()
+		if c.signingSecret != "" && req.URL.Path == "/v1/delivery" {
+			c.sign(req)
+		}
+
+		status, retryAfter, err := c.send(req, out)
+		if err == nil || attempt >= c.maxRetries || !retryable(ctx, status) {
+			return err
+		}
+
+		wait := c.backoff(attempt)
+		if retryAfter > wait {
+			wait = min(retryAfter, maxRetryWait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// send sends one attempt of a request, returning the status code and the Retry-After wait of
+// the response
+func (c *Client) send(req *http.Request, out any) (int, time.Duration, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var body errorResponse
+		if json.NewDecoder(resp.Body).Decode(&body) == nil && body.Error != "" {
+			apiErr.Message, apiErr.Fields = body.Error, body.Fields
+		}
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return resp.StatusCode, retryAfter, apiErr
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, 0, err
+	}
+	if c.signingSecret != "" && req.URL.Path == "/v1/delivery" {
+		if err := c.verify(resp.Header, body); err != nil {
+			return resp.StatusCode, 0, err
+		}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return resp.StatusCode, 0, nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return resp.StatusCode, 0, fmt.Errorf("adbeacon: invalid response to %s %s: %w", req.Method, req.URL.Path, err)
+	}
+	return resp.StatusCode, 0, nil
+}
+
+// retryable reports whether an attempt failing with status is worth retrying: network errors
+// and attempts timing out, rate limiting and server errors other than 501. Nothing is retried
+// once the context of the call is done.
+func retryable(ctx context.Context, status int) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if status == 0 {
+		return true
+	}
+	return status == http.StatusTooManyRequests || (status >= 500 && status != http.StatusNotImplemented)
+}
+
+// backoff returns the wait after the attempt, the backoff doubled per attempt with up to half
+// of it added as jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := time.Duration(float64(c.retryBackoff) * math.Pow(2, float64(attempt)))
+	if wait <= 0 {
+		return 0
+	}
+	wait += time.Duration(mathrand.Int63n(int64(wait)/2 + 1))
+	return min(wait, maxRetryWait)
+}
+
+// newToken returns a random token for nonces and idempotency keys
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,297 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/middleware"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/quota"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClientAgainstServer runs the client against the server handlers, so the models of the
+// client can't drift from the API
+func TestClientAgainstServer(t *testing.T) {
+	repo := repository.NewMockRepository()
+	server := httptest.NewServer(middleware.NewAdminAuthMiddleware("s3cret").Middleware(transport.NewHTTPHandlerWithOptions(
+		endpoint.MakeDeliveryEndpoints(service.NewDeliveryService(repo), endpoint.ValidationMiddleware(models.NewRequestValidator(models.DefaultValidationRules()))),
+		log.NewNopLogger(),
+		transport.HandlerOptions{Campaigns: repo.(service.CampaignStore)},
+	)))
+	defer server.Close()
+	ctx := context.Background()
+	c := New(server.URL, WithAdminUser("alice"), WithAdminToken("s3cret"), WithRetries(0, 0))
+
+	campaigns, err := c.Deliver(ctx, DeliveryRequest{App: "com.example.app", Country: "us", OS: "android"})
+	require.NoError(t, err)
+	assert.Contains(t, campaigns, Campaign{CID: "spotify", Img: "https://somelink", CTA: "Download"})
+
+	_, err = c.Deliver(ctx, DeliveryRequest{App: "com.example.app", OS: "ios"})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.NotEmpty(t, apiErr.Fields)
+	assert.Equal(t, "country", apiErr.Fields[0].Field)
+
+	created, err := c.CreateCampaign(ctx, AdminCampaign{CID: "netflix", Name: "Netflix", Img: "https://img.example.com/netflix.png", CTA: "Watch", Status: StatusActive, Targeting: "country in (us, ca) and os = android"})
+	require.NoError(t, err)
+	assert.Equal(t, "netflix", created.CID)
+	require.Len(t, created.Rules, 2)
+	assert.Equal(t, "country", created.Rules[0].Dimension)
+	assert.Equal(t, RuleTypeInclude, created.Rules[0].RuleType)
+	assert.Equal(t, []string{"us", "ca"}, created.Rules[0].Values)
+
+	_, err = c.CreateCampaign(ctx, AdminCampaign{CID: "netflix", Name: "Netflix"})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+
+	change, err := c.PauseCampaign(ctx, "netflix")
+	require.NoError(t, err)
+	assert.Equal(t, StatusChange{CID: "netflix", Status: StatusInactive}, change)
+	_, err = c.ResumeCampaign(ctx, "unknown")
+	assert.True(t, IsNotFound(err))
+
+	require.NoError(t, c.SetTrafficPct(ctx, "netflix", 25))
+	require.NoError(t, c.SetCategory(ctx, "netflix", "streaming"))
+	require.NoError(t, c.SetImage(ctx, "netflix", "https://img.example.com/banner.png"))
+	require.NoError(t, c.SetCreatives(ctx, "netflix", []Creative{{Lang: "es", CTA: "Ver ahora"}}))
+	err = c.SetCreatives(ctx, "netflix", []Creative{{Lang: "spanish", CTA: "Ver"}})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+
+	version, err := c.SetRules(ctx, "netflix", RulesUpdate{Targeting: "country = us", Comment: "us only"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, version.Version)
+	assert.Equal(t, "alice", version.Author)
+	assert.Equal(t, 1, version.Against)
+	assert.Equal(t, []RuleChange{
+		{Dimension: "country", RuleType: RuleTypeInclude, Removed: []string{"ca"}},
+		{Dimension: "os", RuleType: RuleTypeInclude, Removed: []string{"android"}},
+	}, version.Changes)
+
+	versions, err := c.ListRuleVersions(ctx, "netflix")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "us only", versions[0].Comment)
+
+	stored, err := c.ListCampaigns(ctx)
+	require.NoError(t, err)
+	var netflix AdminCampaign
+	for _, campaign := range stored {
+		if campaign.CID == "netflix" {
+			netflix = campaign
+		}
+	}
+	assert.Equal(t, StatusInactive, netflix.Status)
+	assert.Equal(t, 25, netflix.TrafficPct)
+	assert.Equal(t, "streaming", netflix.Category)
+	assert.Equal(t, "https://img.example.com/banner.png", netflix.Img)
+	assert.Equal(t, []Creative{{Lang: "es", CTA: "Ver ahora"}}, netflix.Creatives)
+
+	// Admin calls without the admin token are rejected
+	_, err = New(server.URL, WithAdminUser("alice"), WithRetries(0, 0)).ListCampaigns(ctx)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+// recordingServer fails the first failures requests with status and records the headers and
+// queries of every request
+type recordingServer struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	headers  []http.Header
+	queries  []string
+}
+
+func (s *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers = append(s.headers, r.Header.Clone())
+	s.queries = append(s.queries, r.URL.RawQuery)
+	if len(s.headers) <= s.failures {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(s.status)
+		w.Write([]byte(`{"error":"try again"}`))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		status       int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "no failures", failures: 0, status: http.StatusServiceUnavailable, wantAttempts: 1},
+		{name: "unavailable then served", failures: 2, status: http.StatusServiceUnavailable, wantAttempts: 3},
+		{name: "rate limited then served", failures: 1, status: http.StatusTooManyRequests, wantAttempts: 2},
+		{name: "out of retries", failures: 5, status: http.StatusBadGateway, wantErr: true, wantAttempts: 3},
+		{name: "client errors aren't retried", failures: 5, status: http.StatusConflict, wantErr: true, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &recordingServer{failures: tt.failures, status: tt.status}
+			server := httptest.NewServer(recorder)
+			defer server.Close()
+			c := New(server.URL, WithRetries(2, time.Millisecond), WithAPIKey("key-1"), WithAdminToken("s3cret"))
+
+			err := c.InvalidateCache(context.Background())
+			if tt.wantErr {
+				var apiErr *APIError
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.status, apiErr.StatusCode)
+				assert.Equal(t, "try again", apiErr.Message)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, recorder.headers, tt.wantAttempts)
+			// Every attempt is the same request to the idempotency middleware
+			for _, header := range recorder.headers {
+				assert.Equal(t, "key-1", header.Get("X-API-Key"))
+				assert.Equal(t, "Bearer s3cret", header.Get("Authorization"))
+				assert.NotEmpty(t, header.Get("Idempotency-Key"))
+				assert.Equal(t, recorder.headers[0].Get("Idempotency-Key"), header.Get("Idempotency-Key"))
+			}
+		})
+	}
+}
+
+func TestDeliverRetriesWithTheSameNonce(t *testing.T) {
+	recorder := &recordingServer{failures: 1, status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+	c := New(server.URL, WithRetries(2, time.Millisecond), WithAdminToken("s3cret"))
+
+	gdpr := true
+	campaigns, err := c.Deliver(context.Background(), DeliveryRequest{
+		App: "com.example.app", Country: "de", OS: "android",
+		Time: time.Date(2025, 1, 1, 21, 30, 0, 0, time.FixedZone("", 19800)),
+		GDPR: &gdpr, ConsentString: "CP...",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, campaigns)
+	require.Len(t, recorder.queries, 2)
+	assert.Equal(t, recorder.queries[0], recorder.queries[1])
+	assert.Contains(t, recorder.queries[0], "nonce=")
+	assert.Contains(t, recorder.queries[0], "ts=")
+	assert.Contains(t, recorder.queries[0], "time=2025-01-01T21%3A30%3A00%2B05%3A30")
+	assert.Contains(t, recorder.queries[0], "gdpr=1&gdpr_consent=CP...")
+	assert.Empty(t, recorder.headers[0].Get("Idempotency-Key"))
+	assert.Empty(t, recorder.headers[0].Get("Authorization"))
+}
+
+func TestClientStopsRetryingWhenTheContextIsDone(t *testing.T) {
+	recorder := &recordingServer{failures: 5, status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+	c := New(server.URL, WithRetries(5, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := c.InvalidateCache(ctx)
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Len(t, recorder.headers, 1)
+
+	// Network errors aren't API errors
+	server.Close()
+	err = New(server.URL, WithRetries(1, time.Millisecond)).InvalidateCache(context.Background())
+	require.Error(t, err)
+	assert.False(t, errors.As(err, new(*APIError)))
+}
+
+func TestDeliverVerifiesSignatures(t *testing.T) {
+	repo := repository.NewMockRepository()
+	handler := transport.NewHTTPHandlerWithOptions(endpoint.MakeDeliveryEndpoints(service.NewDeliveryService(repo)), log.NewNopLogger(), transport.HandlerOptions{})
+	signed := middleware.NewSigningMiddleware(map[string]string{quota.KeyID("key-1"): "s3cret"}).Middleware(handler)
+	tampered := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := httptest.NewRecorder()
+		signed.ServeHTTP(recorder, r)
+		for name, values := range recorder.Header() {
+			w.Header()[name] = values
+		}
+		w.Write(bytes.Replace(recorder.Body.Bytes(), []byte("somelink"), []byte("evillink"), 1))
+	})
+	req := DeliveryRequest{App: "com.example.app", Country: "us", OS: "android"}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		opts    []Option
+		wantErr error
+	}{
+		{name: "signed", handler: signed, opts: []Option{WithAPIKey("key-1"), WithSigningSecret("s3cret")}},
+		{name: "other secret", handler: signed, opts: []Option{WithAPIKey("key-1"), WithSigningSecret("other")}, wantErr: ErrInvalidSignature},
+		{name: "unsigned", handler: signed, opts: []Option{WithAPIKey("key-2"), WithSigningSecret("s3cret")}, wantErr: ErrInvalidSignature},
+		{name: "tampered", handler: tampered, opts: []Option{WithAPIKey("key-1"), WithSigningSecret("s3cret")}, wantErr: ErrInvalidSignature},
+		{name: "not verified", handler: tampered, opts: []Option{WithAPIKey("key-1")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			campaigns, err := New(server.URL, tt.opts...).Deliver(context.Background(), req)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, campaigns)
+		})
+	}
+
+	// Signatures too old are rejected
+	server := httptest.NewServer(signed)
+	defer server.Close()
+	c := New(server.URL, WithAPIKey("key-1"), WithSigningSecret("s3cret"))
+	c.now = func() time.Time { return time.Now().Add(10 * time.Minute) }
+	_, err := c.Deliver(context.Background(), req)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestDeliverSignsRequests(t *testing.T) {
+	repo := repository.NewMockRepository()
+	handler := transport.NewHTTPHandlerWithOptions(endpoint.MakeDeliveryEndpoints(service.NewDeliveryService(repo)), log.NewNopLogger(), transport.HandlerOptions{})
+	secrets := map[string]string{quota.KeyID("key-1"): "s3cret"}
+	protected := middleware.NewSigningMiddleware(secrets).Middleware(middleware.NewReplayProtectionMiddleware(middleware.ReplayProtectionConfig{
+		MaxSkew:   time.Minute,
+		Endpoints: []string{"/v1/delivery"},
+		Secrets:   secrets,
+	}, nil).Middleware(handler))
+	server := httptest.NewServer(protected)
+	defer server.Close()
+	req := DeliveryRequest{App: "com.example.app", Country: "us", OS: "android"}
+
+	campaigns, err := New(server.URL, WithAPIKey("key-1"), WithSigningSecret("s3cret")).Deliver(context.Background(), req)
+	require.NoError(t, err)
+	assert.NotEmpty(t, campaigns)
+
+	_, err = New(server.URL, WithAPIKey("key-1")).Deliver(context.Background(), req)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	_, err = New(server.URL, WithAPIKey("key-1"), WithSigningSecret("other")).Deliver(context.Background(), req)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Deliver returns the campaigns the delivery API serves for req, none when no campaign matches.
+// Invalid requests are rejected with an *APIError listing the invalid fields.
+func (c *Client) Deliver(ctx context.Context, req DeliveryRequest) ([]Campaign, error) {
+	var campaigns []Campaign
+	if err := c.do(ctx, http.MethodGet, "/v1/delivery?"+c.deliveryQuery(req).Encode(), nil, &campaigns); err != nil {
+		return nil, err
+	}
+	return campaigns, nil
+}
+
+// DeliverSlots fills up to slots ad slots, at most MaxSlots, with the campaigns the delivery API
+// serves for req. A pod shows a campaign and a creative once and a competitive category once,
+// slots without a campaign are left out.
+func (c *Client) DeliverSlots(ctx context.Context, req DeliveryRequest, slots int) ([]Slot, error) {
+	query := c.deliveryQuery(req)
+	query.Set("slots", strconv.Itoa(slots))
+
+	var pod struct {
+		Slots []Slot `json:"slots"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/delivery?"+query.Encode(), nil, &pod); err != nil {
+		return nil, err
+	}
+	return pod.Slots, nil
+}
+
+// deliveryQuery returns the query parameters of a delivery call
+func (c *Client) deliveryQuery(req DeliveryRequest) url.Values {
+	query := url.Values{}
+	query.Set("app", req.App)
+	query.Set("country", req.Country)
+	query.Set("os", req.OS)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	if req.UserID != "" {
+		query.Set("user_id", req.UserID)
+	}
+	if req.SessionID != "" {
+		query.Set("session_id", req.SessionID)
+	}
+	if req.Lang != "" {
+		query.Set("lang", req.Lang)
+	}
+	if !req.Time.IsZero() {
+		query.Set("time", req.Time.Format(time.RFC3339))
+	}
+	if req.GDPR != nil {
+		query.Set("gdpr", "0")
+		if *req.GDPR {
+			query.Set("gdpr", "1")
+		}
+	}
+	if req.ConsentString != "" {
+		query.Set("gdpr_consent", req.ConsentString)
+	}
+	// The same nonce for every attempt, the server serves the campaigns of the call once
+	nonce := req.Nonce
+	if nonce == "" {
+		nonce = newToken()
+	}
+	query.Set("nonce", nonce)
+	// Servers with replay protection reject calls of API keys too far from their clock
+	query.Set("ts", strconv.FormatInt(c.now().Unix(), 10))
+	return query
+}
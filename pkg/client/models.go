@@ -0,0 +1,146 @@
+package client
+
+import "time"
+
+// Campaign is a campaign served by the delivery API
+type Campaign struct {
+	CID string `json:"cid"`
+	Img string `json:"img"`
+	CTA string `json:"cta"`
+}
+
+// Slot is an ad slot filled by Client.DeliverSlots, slots are numbered from 1
+type Slot struct {
+	Slot int `json:"slot"`
+	Campaign
+}
+
+// MaxSlots is the most ad slots a delivery call fills
+const MaxSlots = 10
+
+// DeliveryRequest is the request to the delivery API, App, Country and OS are required
+type DeliveryRequest struct {
+	App     string
+	Country string
+	OS      string
+	// State is the state code, for campaigns targeting states of the country
+	State string
+	// UserID is the stable pseudonymous user ID campaigns with a traffic percentage are rolled
+	// out by
+	UserID string
+	// SessionID is the ID of the app session, the campaigns are served in the same order to every
+	// call of a session
+	SessionID string
+	// Lang is the BCP 47 language tag of the user, e.g. "es" or "pt-BR", campaigns with a
+	// creative in the language serve its image and CTA
+	Lang string
+	// Time is the client-local time, time of day targeting uses the server clock without it
+	Time time.Time
+	// Nonce identifies the call, the server serves retries with the same nonce once. A random
+	// nonce is used for every call without one.
+	Nonce string
+	// GDPR, when set, tells whether GDPR applies to the request, with ConsentString the TCF v2
+	// consent string of the user
+	GDPR          *bool
+	ConsentString string
+}
+
+// FieldError is an invalid field of a rejected delivery request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Campaign statuses
+const (
+	StatusActive   = "ACTIVE"
+	StatusInactive = "INACTIVE"
+)
+
+// Rule types
+const (
+	RuleTypeInclude = "include"
+	RuleTypeExclude = "exclude"
+)
+
+// AdminCampaign is a campaign with its targeting rules as managed by the admin API
+type AdminCampaign struct {
+	CID       string    `json:"cid"`
+	Name      string    `json:"name"`
+	Img       string    `json:"img"`
+	CTA       string    `json:"cta"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// StartsAt and EndsAt bound when the campaign is kept active, nil when unbounded
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	// DeliveryBudget is the number of deliveries after which the campaign is paused, 0 for no
+	// limit
+	DeliveryBudget int64 `json:"delivery_budget,omitempty"`
+	// TrafficPct is the percentage of the matching traffic the campaign is served to, 0 for all
+	TrafficPct int `json:"traffic_pct,omitempty"`
+	// Category is the competitive category, responses serve at most one campaign of a category
+	Category string `json:"category,omitempty"`
+	// Creatives are the localized creatives, served instead of Img and CTA to requests in
+	// their language
+	Creatives []Creative `json:"creatives,omitempty"`
+	Rules     []Rule     `json:"rules,omitempty"`
+	// Targeting gives the rules of a new campaign as a text expression instead of Rules, e.g.
+	// "country in (us, ca) and os = android". It is only sent, never returned.
+	Targeting string `json:"targeting,omitempty"`
+}
+
+// Creative is a localized creative of a campaign, without an image the campaign's is served
+type Creative struct {
+	Lang string `json:"lang"`
+	Img  string `json:"img,omitempty"`
+	CTA  string `json:"cta"`
+}
+
+// Rule is a targeting rule including or excluding values of a dimension
+type Rule struct {
+	ID         int64     `json:"id"`
+	CampaignID string    `json:"campaign_id"`
+	Dimension  string    `json:"dimension"`
+	RuleType   string    `json:"rule_type"`
+	Values     []string  `json:"values"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// StatusChange is the status of a campaign after pausing or resuming it
+type StatusChange struct {
+	CID    string `json:"cid"`
+	Status string `json:"status"`
+}
+
+// RulesUpdate replaces the targeting rules of a campaign, given either as Rules or as a
+// Targeting expression
+type RulesUpdate struct {
+	Rules     []Rule `json:"rules"`
+	Targeting string `json:"targeting,omitempty"`
+	// Comment is recorded with the new rule set version
+	Comment string `json:"comment,omitempty"`
+}
+
+// RuleVersion is a version of the targeting rules of a campaign with its changes against an
+// older version
+type RuleVersion struct {
+	CID       string    `json:"cid"`
+	Version   int       `json:"version"`
+	Rules     []Rule    `json:"rules"`
+	Author    string    `json:"author,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Against is the version the changes are against, 0 for version 1
+	Against int          `json:"against"`
+	Changes []RuleChange `json:"changes"`
+}
+
+// RuleChange lists the values added to and removed from the rules of one dimension and rule type
+type RuleChange struct {
+	Dimension string   `json:"dimension"`
+	RuleType  string   `json:"rule_type"`
+	Added     []string `json:"added,omitempty"`
+	Removed   []string `json:"removed,omitempty"`
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of signed delivery responses, requests carry their signature in SignatureHeader
+const (
+	TimestampHeader = "X-Adbeacon-Timestamp"
+	SignatureHeader = "X-Adbeacon-Signature"
+)
+
+// SignatureMaxAge is how far the signing time of a response may be from the clock of the client
+const SignatureMaxAge = 5 * time.Minute
+
+// ErrInvalidSignature is returned for delivery responses failing signature verification, see
+// WithSigningSecret. They may have been changed on the way and are never retried.
+var ErrInvalidSignature = errors.New("adbeacon: invalid delivery response signature")
+
+// sign sets the signature of a delivery request: the hex HMAC-SHA256 of
+// "{ts}.{method}\n{path}\n{query}" with the signing secret, prefixed with "sha256=", where ts is
+// the ts parameter and query the query string with the parameters sorted by name
+func (c *Client) sign(req *http.Request) {
+	query := req.URL.Query()
+	mac := hmac.New(sha256.New, []byte(c.signingSecret))
+	mac.Write([]byte(query.Get("ts") + "." + req.Method + "\n" + req.URL.Path + "\n" + query.Encode()))
+	req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+// verify checks the signature of a delivery response: the hex HMAC-SHA256 of
+// "{timestamp}.{body}" with the signing secret, prefixed with "sha256="
+func (c *Client) verify(header http.Header, body []byte) error {
+	timestamp := header.Get(TimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := c.now().Sub(time.Unix(seconds, 0)); age > SignatureMaxAge || age < -SignatureMaxAge {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(c.signingSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(want)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Package testserver runs a fake adbeacon server for the tests of services calling adbeacon. It
+// serves the delivery and campaign admin APIs with the targeting, validation, consent and nonce
+// handling of the real server, from campaigns held in memory that tests set up as fixtures.
+package testserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/dedup"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/endpoint"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/middleware"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/models"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/repository"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/service"
+	"github.com/prajwalbharadwajbm/adbeacon/internal/transport"
+	"github.com/prajwalbharadwajbm/adbeacon/pkg/client"
+)
+
+// nonceWindow is how long delivery nonces are deduplicated, as the server does by default
+const nonceWindow = time.Minute
+
+// Server is a fake adbeacon server listening on a local address. Campaigns added as fixtures
+// or through the admin API are served right away, there is no cache.
+type Server struct {
+	// URL is the base URL of the server, e.g. http://127.0.0.1:50123
+	URL string
+
+	server *httptest.Server
+	store  service.CampaignStore
+
+	mu sync.Mutex
+	// failures are the statuses the next requests fail with, in order
+	failures   []int
+	deliveries []client.DeliveryRequest
+}
+
+// New starts a server serving campaigns, closed when the test ends. Campaigns without a status
+// are active, invalid campaigns fail the test.
+func New(t testing.TB, campaigns ...client.AdminCampaign) *Server {
+	t.Helper()
+	repo := repository.NewMockRepositoryWithCampaigns()
+	s := &Server{store: repo.(service.CampaignStore)}
+
+	endpoints := endpoint.MakeDeliveryEndpoints(service.NewDeliveryService(repo),
+		endpoint.ValidationMiddleware(models.NewRequestValidator(models.DefaultValidationRules())))
+	var handler http.Handler = transport.NewHTTPHandlerWithOptions(endpoints, log.NewNopLogger(), transport.HandlerOptions{
+		Campaigns: s.store,
+	})
+	handler = middleware.NewIdempotencyMiddleware(middleware.IdempotencyConfig{PathPrefix: "/v1/admin/"}).Middleware(handler)
+	handler = middleware.NewDeduplicationMiddleware(dedup.NewMemoryStore(), nonceWindow, nil, log.NewNopLogger()).Middleware(handler)
+	handler = middleware.NewConsentMiddleware(0, nil).Middleware(handler)
+	s.server = httptest.NewServer(s.intercept(handler))
+	s.URL = s.server.URL
+	t.Cleanup(s.Close)
+
+	for _, campaign := range campaigns {
+		if err := s.AddCampaign(campaign); err != nil {
+			t.Fatalf("testserver: %v", err)
+		}
+	}
+	return s
+}
+
+// Close shuts the server down, tests don't need to call it
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns a client of the server, with opts applied to the defaults of client.New
+func (s *Server) Client(opts ...client.Option) *client.Client {
+	return client.New(s.URL, opts...)
+}
+
+// AddCampaign stores a campaign, validated like those created through the admin API. Its rules
+// are given either as Rules or as a Targeting expression, it is active unless given a status.
+func (s *Server) AddCampaign(campaign client.AdminCampaign) error {
+	if campaign.Status == "" {
+		campaign.Status = client.StatusActive
+	}
+	// The JSON of the client is the API contract, the campaign is read as the server reads it
+	encoded, err := json.Marshal(campaign)
+	if err != nil {
+		return err
+	}
+	var stored models.CampaignWithRules
+	if err := json.Unmarshal(encoded, &stored); err != nil {
+		return fmt.Errorf("campaign %s: %w", campaign.CID, err)
+	}
+	if campaign.Targeting != "" {
+		if len(campaign.Rules) > 0 {
+			return fmt.Errorf("campaign %s: rules and targeting are mutually exclusive", campaign.CID)
+		}
+		if stored.Rules, err = models.ParseRules(campaign.Targeting); err != nil {
+			return fmt.Errorf("campaign %s: invalid targeting: %w", campaign.CID, err)
+		}
+	}
+	for i := range stored.Rules {
+		stored.Rules[i].CampaignID = stored.ID
+	}
+	if errs := stored.Validate(); len(errs) > 0 {
+		return fmt.Errorf("campaign %s: %w", campaign.CID, errors.Join(errs...))
+	}
+	return s.store.CreateCampaign(context.Background(), stored)
+}
+
+// SetStatus pauses or resumes a campaign, with client.StatusInactive or client.StatusActive
+func (s *Server) SetStatus(cid, status string) error {
+	return s.store.SetCampaignStatus(context.Background(), cid, models.CampaignStatus(status))
+}
+
+// FailNext fails the next n requests with status and an error body, e.g. to test the retries of
+// a client with http.StatusServiceUnavailable
+func (s *Server) FailNext(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range n {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// Deliveries returns the delivery requests received so far, failed ones and retries included,
+// oldest first
+func (s *Server) Deliveries() []client.DeliveryRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]client.DeliveryRequest(nil), s.deliveries...)
+}
+
+// intercept records delivery requests and fails the requests programmed with FailNext
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		if r.URL.Path == "/v1/delivery" {
+			s.deliveries = append(s.deliveries, deliveryRequest(r))
+		}
+		status := 0
+		if len(s.failures) > 0 {
+			status, s.failures = s.failures[0], s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		if status == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.NewErrorResponse("testserver: injected failure"))
+	})
+}
+
+// deliveryRequest reads a delivery request from its query parameters
+func deliveryRequest(r *http.Request) client.DeliveryRequest {
+	query := r.URL.Query()
+	req := client.DeliveryRequest{
+		App:           query.Get("app"),
+		Country:       query.Get("country"),
+		OS:            query.Get("os"),
+		State:         query.Get("state"),
+		UserID:        query.Get("user_id"),
+		SessionID:     query.Get("session_id"),
+		Lang:          query.Get("lang"),
+		Nonce:         query.Get("nonce"),
+		ConsentString: query.Get("gdpr_consent"),
+	}
+	if raw := query.Get("time"); raw != "" {
+		req.Time, _ = time.Parse(time.RFC3339, strings.Replace(raw, " ", "+", 1))
+	}
+	if gdpr := query.Get("gdpr"); gdpr != "" {
+		applies := gdpr == "1"
+		req.GDPR = &applies
+	}
+	return req
+}
@@ -0,0 +1,95 @@
+package testserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prajwalbharadwajbm/adbeacon/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerDelivers(t *testing.T) {
+	s := New(t,
+		client.AdminCampaign{CID: "spotify", Name: "Spotify", Img: "https://img.example.com/spotify.png", CTA: "Download", Targeting: "country in (us, ca)"},
+		client.AdminCampaign{CID: "duolingo", Name: "Duolingo", Img: "https://img.example.com/duolingo.png", CTA: "Install", Targeting: "os = android"},
+	)
+	ctx := context.Background()
+	c := s.Client()
+
+	campaigns, err := c.Deliver(ctx, client.DeliveryRequest{App: "com.example.app", Country: "us", OS: "ios"})
+	require.NoError(t, err)
+	assert.Equal(t, []client.Campaign{{CID: "spotify", Img: "https://img.example.com/spotify.png", CTA: "Download"}}, campaigns)
+
+	slots, err := c.DeliverSlots(ctx, client.DeliveryRequest{App: "com.example.app", Country: "us", OS: "android"}, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []client.Slot{
+		{Slot: 1, Campaign: client.Campaign{CID: "spotify", Img: "https://img.example.com/spotify.png", CTA: "Download"}},
+		{Slot: 2, Campaign: client.Campaign{CID: "duolingo", Img: "https://img.example.com/duolingo.png", CTA: "Install"}},
+	}, slots)
+
+	campaigns, err = c.Deliver(ctx, client.DeliveryRequest{App: "com.example.app", Country: "de", OS: "ios"})
+	require.NoError(t, err)
+	assert.Empty(t, campaigns)
+
+	// Fixtures added later and status changes are served right away
+	require.NoError(t, s.AddCampaign(client.AdminCampaign{CID: "netflix", Name: "Netflix", Img: "https://img.example.com/netflix.png", CTA: "Watch"}))
+	require.NoError(t, s.SetStatus("spotify", client.StatusInactive))
+	campaigns, err = c.Deliver(ctx, client.DeliveryRequest{App: "com.example.app", Country: "us", OS: "ios"})
+	require.NoError(t, err)
+	assert.Equal(t, []client.Campaign{{CID: "netflix", Img: "https://img.example.com/netflix.png", CTA: "Watch"}}, campaigns)
+
+	_, err = c.Deliver(ctx, client.DeliveryRequest{App: "com.example.app", Country: "usa", OS: "ios"})
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+
+	admin, err := c.ListCampaigns(ctx)
+	require.NoError(t, err)
+	assert.Len(t, admin, 3)
+}
+
+func TestServerRejectsInvalidFixtures(t *testing.T) {
+	s := New(t)
+	assert.ErrorContains(t, s.AddCampaign(client.AdminCampaign{CID: "spotify", Name: "Spotify", Targeting: "country in (us"}), "invalid targeting")
+	assert.Error(t, s.AddCampaign(client.AdminCampaign{CID: "spotify", Name: "Spotify", Targeting: "planet = mars"}))
+	assert.ErrorContains(t, s.AddCampaign(client.AdminCampaign{
+		CID: "spotify", Name: "Spotify", Targeting: "country = us",
+		Rules: []client.Rule{{Dimension: "os", RuleType: client.RuleTypeInclude, Values: []string{"ios"}}},
+	}), "mutually exclusive")
+	require.NoError(t, s.AddCampaign(client.AdminCampaign{CID: "spotify", Name: "Spotify"}))
+	assert.Error(t, s.AddCampaign(client.AdminCampaign{CID: "spotify", Name: "Spotify"}))
+}
+
+func TestServerFailures(t *testing.T) {
+	s := New(t, client.AdminCampaign{CID: "spotify", Name: "Spotify", Img: "https://img.example.com/spotify.png", CTA: "Download"})
+	s.FailNext(2, http.StatusServiceUnavailable)
+
+	gdpr := false
+	req := client.DeliveryRequest{
+		App: "com.example.app", Country: "us", OS: "ios", UserID: "user-1", SessionID: "session-1", Lang: "pt-BR", GDPR: &gdpr,
+		Time: time.Date(2025, 1, 1, 21, 30, 0, 0, time.FixedZone("", 19800)),
+	}
+	campaigns, err := s.Client(client.WithRetries(2, time.Millisecond)).Deliver(context.Background(), req)
+	require.NoError(t, err)
+	assert.Len(t, campaigns, 1)
+
+	deliveries := s.Deliveries()
+	require.Len(t, deliveries, 3)
+	// Retries of a call reuse its nonce
+	assert.NotEmpty(t, deliveries[0].Nonce)
+	assert.Equal(t, deliveries[0].Nonce, deliveries[2].Nonce)
+	assert.Equal(t, req.UserID, deliveries[2].UserID)
+	assert.Equal(t, req.SessionID, deliveries[2].SessionID)
+	assert.Equal(t, req.Lang, deliveries[2].Lang)
+	assert.True(t, req.Time.Equal(deliveries[2].Time))
+	assert.Equal(t, &gdpr, deliveries[2].GDPR)
+
+	s.FailNext(1, http.StatusInternalServerError)
+	_, err = s.Client(client.WithRetries(0, 0)).Deliver(context.Background(), req)
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+}